@@ -0,0 +1,89 @@
+package git
+
+import (
+	"context"
+	"sync"
+)
+
+// autoGCState holds the state of the automatic gc of a repository.
+type autoGCState struct {
+	m       sync.Mutex
+	wg      sync.WaitGroup
+	running bool
+	err     error
+}
+
+// runAutoGC runs the gc maintenance task if Repository.AutoGC is enabled and
+// the repository has more loose objects than gc.auto or more packs than
+// gc.autoPackLimit, as git gc --auto does after the operations writing
+// objects. The PreAutoGC callback and the pre-auto-gc hook can veto it. With
+// gc.autoDetach, the default, it's run in the background, and a gc already
+// running isn't started again. If force, as after a refetch, the gc is run
+// whatever the number of loose objects and packs.
+func (r *Repository) runAutoGC(force bool) {
+	if !r.AutoGC {
+		return
+	}
+
+	r.autoGC.m.Lock()
+	defer r.autoGC.m.Unlock()
+	if r.autoGC.running {
+		return
+	}
+
+	cfg, err := r.Config()
+	if err != nil {
+		r.autoGC.err = err
+		return
+	}
+
+	needed, err := r.needsGCTask(0)
+	if err != nil || !(needed || force) {
+		r.autoGC.err = err
+		return
+	}
+
+	if !r.allowAutoGC() {
+		return
+	}
+
+	if !cfg.GC.AutoDetach {
+		r.autoGC.err = r.gc(context.Background(), nil)
+		return
+	}
+
+	r.autoGC.running = true
+	r.autoGC.wg.Add(1)
+	go func() {
+		defer r.autoGC.wg.Done()
+		err := r.gc(context.Background(), nil)
+
+		r.autoGC.m.Lock()
+		defer r.autoGC.m.Unlock()
+		r.autoGC.running, r.autoGC.err = false, err
+	}()
+}
+
+// allowAutoGC returns false if the PreAutoGC callback or the pre-auto-gc hook
+// fail, vetoing the automatic gc.
+func (r *Repository) allowAutoGC() bool {
+	if fn := r.Hooks.PreAutoGC; fn != nil {
+		if err := fn(r); err != nil {
+			return false
+		}
+	}
+
+	return runHook(r.HookRunner, PreAutoGCHook, nil, nil) == nil
+}
+
+// WaitAutoGC waits for the automatic gc running in the background, if any,
+// returning the error of the last automatic gc, see Repository.AutoGC.
+func (r *Repository) WaitAutoGC() error {
+	r.autoGC.wg.Wait()
+
+	r.autoGC.m.Lock()
+	defer r.autoGC.m.Unlock()
+	err := r.autoGC.err
+	r.autoGC.err = nil
+	return err
+}
@@ -0,0 +1,181 @@
+package git
+
+import (
+	"errors"
+	"io"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type AutoGCSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&AutoGCSuite{})
+
+func (s *AutoGCSuite) newRepository(c *C, detach bool) (*Repository, *filesystem.Storage) {
+	sto, err := filesystem.NewStorage(memfs.New())
+	c.Assert(err, IsNil)
+
+	r, err := Init(sto, memfs.New())
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.GC.Auto = 1
+	cfg.GC.AutoDetach = detach
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	r.AutoGC = true
+	return r, sto
+}
+
+func (s *AutoGCSuite) commit(c *C, r *Repository) {
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(w.Filesystem, "foo", []byte("foo"), 0644), IsNil)
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	_, err = w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+}
+
+func (s *AutoGCSuite) assertPacked(c *C, sto *filesystem.Storage, packed bool) {
+	var loose int
+	err := sto.ForEachObjectHash(func(plumbing.Hash) error {
+		loose++
+		return nil
+	})
+	c.Assert(err, IsNil)
+
+	packs, err := sto.ObjectPacks()
+	c.Assert(err, IsNil)
+
+	if packed {
+		c.Assert(loose, Equals, 0)
+		c.Assert(packs, HasLen, 1)
+	} else {
+		c.Assert(loose > 0, Equals, true)
+		c.Assert(packs, HasLen, 0)
+	}
+}
+
+func (s *AutoGCSuite) TestCommit(c *C) {
+	r, sto := s.newRepository(c, false)
+
+	s.commit(c, r)
+	s.assertPacked(c, sto, true)
+	c.Assert(r.WaitAutoGC(), IsNil)
+}
+
+func (s *AutoGCSuite) TestCommitDetached(c *C) {
+	r, sto := s.newRepository(c, true)
+
+	s.commit(c, r)
+	c.Assert(r.WaitAutoGC(), IsNil)
+	s.assertPacked(c, sto, true)
+}
+
+func (s *AutoGCSuite) TestDisabled(c *C) {
+	r, sto := s.newRepository(c, false)
+	r.AutoGC = false
+
+	s.commit(c, r)
+	s.assertPacked(c, sto, false)
+}
+
+func (s *AutoGCSuite) TestBelowThreshold(c *C) {
+	r, sto := s.newRepository(c, false)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.GC.Auto = 1000
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	s.commit(c, r)
+	s.assertPacked(c, sto, false)
+}
+
+func (s *AutoGCSuite) TestPreAutoGCVeto(c *C) {
+	r, sto := s.newRepository(c, false)
+
+	var called int
+	r.Hooks.PreAutoGC = func(hr *Repository) error {
+		c.Assert(hr, Equals, r)
+		called++
+		return errors.New("not now")
+	}
+
+	s.commit(c, r)
+	c.Assert(called, Equals, 1)
+	s.assertPacked(c, sto, false)
+	c.Assert(r.WaitAutoGC(), IsNil)
+}
+
+func (s *AutoGCSuite) TestPreAutoGCHook(c *C) {
+	r, sto := s.newRepository(c, false)
+	r.HookRunner = &ScriptHookRunner{Funcs: map[string]HookFunc{
+		PreAutoGCHook: func([]string, io.Reader) error {
+			return errors.New("not now")
+		},
+	}}
+
+	s.commit(c, r)
+	s.assertPacked(c, sto, false)
+}
+
+func (s *AutoGCSuite) TestFetch(c *C) {
+	r, sto := s.newRepository(c, true)
+	r.AutoGC = false
+	s.commit(c, r)
+
+	_, err := r.CreateRemote(&config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{s.GetBasicLocalRepositoryURL()},
+	})
+	c.Assert(err, IsNil)
+
+	r.AutoGC = true
+	c.Assert(r.Fetch(&FetchOptions{}), IsNil)
+	c.Assert(r.WaitAutoGC(), IsNil)
+	s.assertPacked(c, sto, true)
+}
+
+func (s *AutoGCSuite) TestRefetch(c *C) {
+	r, sto := s.newRepository(c, false)
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.GC.Auto = 0
+	cfg.GC.AutoPackLimit = 0
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+	s.commit(c, r)
+
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{s.GetBasicLocalRepositoryURL()},
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(r.Fetch(&FetchOptions{}), IsNil)
+	c.Assert(r.WaitAutoGC(), IsNil)
+
+	var loose int
+	err = sto.ForEachObjectHash(func(plumbing.Hash) error {
+		loose++
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(loose > 0, Equals, true)
+
+	c.Assert(r.Fetch(&FetchOptions{Refetch: true}), Equals, NoErrAlreadyUpToDate)
+	c.Assert(r.WaitAutoGC(), IsNil)
+	s.assertPacked(c, sto, true)
+}
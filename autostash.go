@@ -0,0 +1,381 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+var (
+	// ErrAutostashConflict is returned, as an *AutostashConflictError, when
+	// the local changes stashed before an operation can't be reapplied once
+	// the operation is completed. The operation isn't reverted, and the
+	// changes are kept at the stash.
+	ErrAutostashConflict = errors.New("autostash conflict")
+)
+
+// AutostashConflictError is the ErrAutostashConflict of the given files. It
+// matches ErrAutostashConflict with errors.Is.
+type AutostashConflictError struct {
+	// Stash is the commit of the stashed changes, stored at refs/stash.
+	Stash plumbing.Hash
+	// Paths are the files changed in a different way by the operation and
+	// the stashed changes.
+	Paths []string
+}
+
+func (e *AutostashConflictError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrAutostashConflict, strings.Join(e.Paths, ", "))
+}
+
+// Is returns true if target is ErrAutostashConflict.
+func (e *AutostashConflictError) Is(target error) bool {
+	return target == ErrAutostashConflict
+}
+
+// withAutostash runs op with the local changes of the tracked files stashed,
+// reapplying them once op returns. If op fails, its error is returned even if
+// the changes can't be reapplied, since they are kept at the stash anyway.
+func (w *Worktree) withAutostash(committer *object.Signature, op func() error) error {
+	stash, err := w.autostash(committer)
+	if err != nil {
+		return err
+	}
+
+	opErr := op()
+	if stash.IsZero() {
+		return opErr
+	}
+
+	err = w.applyAutostash(stash)
+	if opErr != nil && opErr != NoErrAlreadyUpToDate {
+		return opErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return opErr
+}
+
+// autostash stashes the changes of the tracked files, at the index and at the
+// worktree, as git stash create does, and restores the files from HEAD. It
+// returns the stash commit, or a zero hash if there are no changes to stash.
+func (w *Worktree) autostash(committer *object.Signature) (plumbing.Hash, error) {
+	head, err := w.r.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return plumbing.ZeroHash, nil
+	}
+
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	status, err := w.Status()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	paths := stashedPaths(status, idx)
+	if len(paths) == 0 {
+		return plumbing.ZeroHash, nil
+	}
+
+	commit, err := w.r.CommitObject(head.Hash())
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	h := &buildTreeHelper{fs: w.Filesystem, s: w.r.Storer}
+	indexTree, err := h.BuildTree(idx)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	worktreeTree, err := w.stashWorktreeTree(indexTree, status, idx, paths)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	branch := "(no branch)"
+	if head.Name().IsBranch() {
+		branch = head.Name().Short()
+	}
+
+	subject := strings.SplitN(commit.Message, "\n", 2)[0]
+	sig := stashSignature(commit, committer)
+	i, err := storeCommit(w.r.Storer, &object.Commit{
+		Author:       sig,
+		Committer:    sig,
+		Message:      fmt.Sprintf("index on %s: %s %s\n", branch, commit.Hash.String()[:7], subject),
+		TreeHash:     indexTree,
+		ParentHashes: []plumbing.Hash{commit.Hash},
+	})
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	stash, err := storeCommit(w.r.Storer, &object.Commit{
+		Author:       sig,
+		Committer:    sig,
+		Message:      fmt.Sprintf("On %s: autostash\n", branch),
+		TreeHash:     worktreeTree,
+		ParentHashes: []plumbing.Hash{commit.Hash, i},
+	})
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return stash, w.RestorePaths(&RestoreOptions{
+		Source:   commit.Hash,
+		Staged:   true,
+		Worktree: true,
+		Paths:    paths,
+	})
+}
+
+// stashedPaths returns the tracked files changed at the index or at the
+// worktree, excluding the submodules.
+func stashedPaths(status Status, idx *index.Index) []string {
+	var paths []string
+	for name, fs := range status {
+		if fs.Staging == Untracked {
+			continue
+		}
+
+		if fs.Staging == Unmodified && fs.Worktree != Modified && fs.Worktree != Deleted {
+			continue
+		}
+
+		if e, err := idx.Entry(name); err == nil && e.Mode == filemode.Submodule {
+			continue
+		}
+
+		paths = append(paths, name)
+	}
+
+	sort.Strings(paths)
+	return paths
+}
+
+// stashWorktreeTree returns the tree of the index with the changes of the
+// files at the worktree.
+func (w *Worktree) stashWorktreeTree(indexTree plumbing.Hash, status Status, idx *index.Index, paths []string) (plumbing.Hash, error) {
+	t, err := w.r.TreeObject(indexTree)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	b := object.NewTreeBuilder(w.r.Storer, t)
+	for _, name := range paths {
+		switch status[name].Worktree {
+		case Deleted:
+			err = b.Remove(name)
+		case Modified:
+			err = w.stashWorktreeFile(b, idx, name)
+		}
+
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	return b.Build()
+}
+
+func (w *Worktree) stashWorktreeFile(b *object.TreeBuilder, idx *index.Index, name string) error {
+	fi, err := w.Filesystem.Lstat(name)
+	if err != nil {
+		return err
+	}
+
+	mode, err := filemode.NewFromOSFileMode(fi.Mode())
+	if err != nil {
+		return err
+	}
+
+	if e, err := idx.Entry(name); err == nil && mode != filemode.Symlink {
+		mode = e.Mode
+	}
+
+	h, err := w.copyFileToStorage(name)
+	if err != nil {
+		return err
+	}
+
+	return b.Add(name, mode, h)
+}
+
+// applyAutostash reapplies the changes stashed by autostash on top of HEAD,
+// file by file. If they conflict with the changes of HEAD since the stash,
+// nothing is reapplied and the stash is stored at refs/stash.
+func (w *Worktree) applyAutostash(stash plumbing.Hash) error {
+	sc, err := w.r.CommitObject(stash)
+	if err != nil {
+		return err
+	}
+
+	trees := make([]*object.Tree, 3)
+	for i := 0; i < 2; i++ {
+		p, err := sc.Parent(i)
+		if err != nil {
+			return err
+		}
+
+		if trees[i], err = p.Tree(); err != nil {
+			return err
+		}
+	}
+
+	if trees[2], err = sc.Tree(); err != nil {
+		return err
+	}
+
+	base, stagedTree, worktreeTree := trees[0], trees[1], trees[2]
+
+	ref, err := w.r.Head()
+	if err != nil {
+		return err
+	}
+
+	head, err := w.r.CommitObject(ref.Hash())
+	if err != nil {
+		return err
+	}
+
+	ours, err := head.Tree()
+	if err != nil {
+		return err
+	}
+
+	conflicts := make(map[string]bool)
+	merged := make([]plumbing.Hash, 2)
+	for i, theirs := range []*object.Tree{worktreeTree, stagedTree} {
+		merged[i], err = mergeTrees(w.r.Storer, base, ours, theirs)
+		if c, ok := err.(*treeMergeConflictError); ok {
+			for _, name := range c.paths {
+				conflicts[name] = true
+			}
+
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(conflicts) != 0 {
+		if err := w.r.storeStash(sc); err != nil {
+			return err
+		}
+
+		paths := make([]string, 0, len(conflicts))
+		for name := range conflicts {
+			paths = append(paths, name)
+		}
+
+		sort.Strings(paths)
+		return &AutostashConflictError{Stash: stash, Paths: paths}
+	}
+
+	if err := w.restoreStashTree(sc, head, ours, merged[0], false); err != nil {
+		return err
+	}
+
+	return w.restoreStashTree(sc, head, ours, merged[1], true)
+}
+
+// restoreStashTree restores, at the index if staged or at the worktree
+// otherwise, the files of the tree differing from the tree of HEAD.
+func (w *Worktree) restoreStashTree(stash, head *object.Commit, ours *object.Tree, tree plumbing.Hash, staged bool) error {
+	t, err := w.r.TreeObject(tree)
+	if err != nil {
+		return err
+	}
+
+	changes, err := object.DiffTree(ours, t)
+	if err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	paths := make([]string, 0, len(changes))
+	for _, ch := range changes {
+		name := ch.To.Name
+		if name == "" {
+			name = ch.From.Name
+		}
+
+		paths = append(paths, name)
+	}
+
+	// the files are restored from a commit of the tree
+	source, err := storeCommit(w.r.Storer, &object.Commit{
+		Author:       stash.Author,
+		Committer:    stash.Committer,
+		Message:      stash.Message,
+		TreeHash:     tree,
+		ParentHashes: []plumbing.Hash{head.Hash},
+	})
+	if err != nil {
+		return err
+	}
+
+	return w.RestorePaths(&RestoreOptions{
+		Source:   source,
+		Staged:   staged,
+		Worktree: !staged,
+		Paths:    paths,
+	})
+}
+
+// storeStash stores the stash commit at refs/stash, recording it at its
+// reflog, as git stash store does.
+func (r *Repository) storeStash(stash *object.Commit) error {
+	var old plumbing.Hash
+	ref, err := r.Storer.Reference(stashReference)
+	if err == nil {
+		old = ref.Hash()
+	} else if err != plumbing.ErrReferenceNotFound {
+		return err
+	}
+
+	if err := r.Storer.SetReference(plumbing.NewHashReference(stashReference, stash.Hash)); err != nil {
+		return err
+	}
+
+	return appendReflog(r, stashReference, &reflogEntry{
+		old:     old,
+		new:     stash.Hash,
+		message: "autostash",
+	}, &stash.Committer)
+}
+
+// stashSignature returns the given committer or, if nil, the committer of
+// the commit with the current time.
+func stashSignature(c *object.Commit, committer *object.Signature) object.Signature {
+	if committer != nil {
+		return *committer
+	}
+
+	sig := c.Committer
+	sig.When = time.Now()
+	return sig
+}
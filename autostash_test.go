@@ -0,0 +1,170 @@
+package git
+
+import (
+	"io/ioutil"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type AutostashSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&AutostashSuite{})
+
+// branches creates a repository with master at a commit writing foo and bar,
+// and the branch b changing bar, checking out master.
+func (s *AutostashSuite) branches(c *C) (*Repository, *Worktree) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	s.write(c, w, "foo", "foo")
+	s.write(c, w, "bar", "bar")
+	_, err = w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	b := plumbing.ReferenceName("refs/heads/b")
+	c.Assert(w.Checkout(&CheckoutOptions{Branch: b, Create: true}), IsNil)
+	s.write(c, w, "bar", "baz")
+	_, err = w.Commit("bar\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	c.Assert(w.Checkout(&CheckoutOptions{Branch: plumbing.Master}), IsNil)
+	return r, w
+}
+
+func (s *AutostashSuite) write(c *C, w *Worktree, name, content string) {
+	c.Assert(util.WriteFile(w.Filesystem, name, []byte(content), 0644), IsNil)
+	_, err := w.Add(name)
+	c.Assert(err, IsNil)
+}
+
+func (s *AutostashSuite) assertContent(c *C, w *Worktree, name, content string) {
+	f, err := w.Filesystem.Open(name)
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	b, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, content)
+}
+
+func (s *AutostashSuite) TestCheckout(c *C) {
+	r, w := s.branches(c)
+
+	c.Assert(util.WriteFile(w.Filesystem, "foo", []byte("qux"), 0644), IsNil)
+	s.write(c, w, "new", "new")
+
+	err := w.Checkout(&CheckoutOptions{Branch: "refs/heads/b"})
+	c.Assert(err, Equals, ErrUnstagedChanges)
+
+	err = w.Checkout(&CheckoutOptions{Branch: "refs/heads/b", Autostash: true})
+	c.Assert(err, IsNil)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, plumbing.ReferenceName("refs/heads/b"))
+
+	s.assertContent(c, w, "foo", "qux")
+	s.assertContent(c, w, "bar", "baz")
+	s.assertContent(c, w, "new", "new")
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 2)
+	c.Assert(status.File("foo").Staging, Equals, Unmodified)
+	c.Assert(status.File("foo").Worktree, Equals, Modified)
+	c.Assert(status.File("new").Staging, Equals, Added)
+	c.Assert(status.File("new").Worktree, Equals, Unmodified)
+
+	_, err = r.Reference(stashReference, false)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *AutostashSuite) TestCheckoutConflict(c *C) {
+	r, w := s.branches(c)
+
+	c.Assert(util.WriteFile(w.Filesystem, "bar", []byte("qux"), 0644), IsNil)
+
+	err := w.Checkout(&CheckoutOptions{Branch: "refs/heads/b", Autostash: true})
+	c.Assert(err, NotNil)
+
+	conflict, ok := err.(*AutostashConflictError)
+	c.Assert(ok, Equals, true)
+	c.Assert(conflict.Is(ErrAutostashConflict), Equals, true)
+	c.Assert(conflict.Paths, DeepEquals, []string{"bar"})
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, plumbing.ReferenceName("refs/heads/b"))
+	s.assertContent(c, w, "bar", "baz")
+
+	stash, err := r.Reference(stashReference, false)
+	c.Assert(err, IsNil)
+	c.Assert(stash.Hash(), Equals, conflict.Stash)
+
+	commit, err := r.CommitObject(conflict.Stash)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "On master: autostash\n")
+	c.Assert(commit.NumParents(), Equals, 2)
+
+	f, err := commit.File("bar")
+	c.Assert(err, IsNil)
+	content, err := f.Contents()
+	c.Assert(err, IsNil)
+	c.Assert(content, Equals, "qux")
+}
+
+func (s *AutostashSuite) TestCheckoutClean(c *C) {
+	r, w := s.branches(c)
+
+	err := w.Checkout(&CheckoutOptions{Branch: "refs/heads/b", Autostash: true})
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+
+	_, err = r.Reference(stashReference, false)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *AutostashSuite) TestRebase(c *C) {
+	r, w := s.branches(c)
+
+	s.write(c, w, "foo", "qux")
+	_, err := w.Commit("qux\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(w.Filesystem, "foo", []byte("local"), 0644), IsNil)
+
+	b, err := r.Reference("refs/heads/b", false)
+	c.Assert(err, IsNil)
+
+	err = w.Rebase(&RebaseOptions{Upstream: b.Hash()})
+	c.Assert(err, Equals, ErrWorktreeNotClean)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Rebase.AutoStash = true
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	c.Assert(w.Rebase(&RebaseOptions{Upstream: b.Hash()}), IsNil)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	commit, err := r.CommitObject(head.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(commit.ParentHashes, DeepEquals, []plumbing.Hash{b.Hash()})
+
+	s.assertContent(c, w, "foo", "local")
+	s.assertContent(c, w, "bar", "baz")
+}
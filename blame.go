@@ -1,17 +1,28 @@
 package git
 
 import (
-	"bytes"
+	"bufio"
+	"container/heap"
+	"context"
+	"encoding/hex"
 	"errors"
-	"fmt"
-	"strconv"
+	"io"
+	"sort"
 	"strings"
 	"time"
-	"unicode/utf8"
+	"unicode"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/mailmap"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
 	"gopkg.in/src-d/go-git.v4/utils/diff"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+var (
+	// ErrInvalidIgnoreRev is returned reading an ignore revs file with a
+	// line not being a full commit hash.
+	ErrInvalidIgnoreRev = errors.New("invalid commit hash at ignore revs file")
 )
 
 // BlameResult represents the result of a Blame operation.
@@ -24,83 +35,171 @@ type BlameResult struct {
 	Lines []*Line
 }
 
+// BlameHunk is a range of consecutive lines of the blamed file, attributed to
+// the same commit.
+type BlameHunk struct {
+	// Commit is the commit the lines are attributed to.
+	Commit *object.Commit
+	// Path is the path of the file the lines come from at Commit.
+	Path string
+	// Start is the index of the first line of the hunk at the blamed file,
+	// starting at 0.
+	Start int
+	// OrigStart is the index of the first line of the hunk at the file of
+	// Commit, starting at 0.
+	OrigStart int
+	// Lines are the lines of the hunk.
+	Lines []*Line
+	// Boundary is true if Commit is older than BlameOptions.Since, so the
+	// lines may have been introduced before it.
+	Boundary bool
+}
+
 // Blame returns a BlameResult with the information about the last author of
 // each line from file `path` at commit `c`.
 func Blame(c *object.Commit, path string) (*BlameResult, error) {
-	// The file to blame is identified by the input arguments:
-	// commit and path. commit is a Commit object obtained from a Repository. Path
-	// represents a path to a specific file contained into the repository.
-	//
-	// Blaming a file is a two step process:
-	//
-	// 1. Create a linear history of the commits affecting a file. We use
-	// revlist.New for that.
-	//
-	// 2. Then build a graph with a node for every line in every file in
-	// the history of the file.
-	//
-	// Each node is assigned a commit: Start by the nodes in the first
-	// commit. Assign that commit as the creator of all its lines.
-	//
-	// Then jump to the nodes in the next commit, and calculate the diff
-	// between the two files. Newly created lines get
-	// assigned the new commit as its origin. Modified lines also get
-	// this new commit. Untouched lines retain the old commit.
-	//
-	// All this work is done in the assignOrigin function which holds all
-	// the internal relevant data in a "blame" struct, that is not
-	// exported.
-	//
-	// TODO: ways to improve the efficiency of this function:
-	// 1. Improve revlist
-	// 2. Improve how to traverse the history (example a backward traversal will
-	// be much more efficient)
-	//
-	// TODO: ways to improve the function in general:
-	// 1. Add memoization between revlist and assign.
-	// 2. It is using much more memory than needed, see the TODOs below.
-
-	b := new(blame)
-	b.fRev = c
-	b.path = path
-
-	// get all the file revisions
-	if err := b.fillRevs(); err != nil {
-		return nil, err
+	return BlameContext(context.Background(), c, path)
+}
+
+// BlameContext returns a BlameResult with the information about the last
+// author of each line from file `path` at commit `c`.
+//
+// The provided Context must be non-nil. If the context expires before the
+// operation is complete, an error is returned.
+func BlameContext(ctx context.Context, c *object.Commit, path string) (*BlameResult, error) {
+	return BlameWithOptions(ctx, c, path, &BlameOptions{})
+}
+
+// BlameWithOptions returns a BlameResult with the information about the last
+// author of each line from file `path` at commit `c`, as git blame does.
+//
+// The history is walked backwards from c, newer commits first, each commit
+// holding the lines not attributed yet. The lines a commit doesn't change
+// with respect to a parent are passed to the parent, the rest are attributed
+// to the commit, so the walk stops once every line is attributed, and only the
+// revisions of the file actually changing the lines are diffed.
+//
+// The provided Context must be non-nil. If the context expires before the
+// operation is complete, an error is returned.
+func BlameWithOptions(ctx context.Context, c *object.Commit, path string, o *BlameOptions) (*BlameResult, error) {
+	var m *mailmap.Mailmap
+	if o.UseMailmap {
+		var err error
+		if m, err = commitMailmap(c); err != nil {
+			return nil, err
+		}
 	}
 
-	// calculate the line tracking graph and fill in
-	// file contents in data.
-	if err := b.fillGraphAndData(); err != nil {
+	return blame(ctx, c, path, o, m)
+}
+
+// blame blames the file as BlameWithOptions does, with the given mailmap if
+// UseMailmap is set.
+func blame(ctx context.Context, c *object.Commit, path string, o *BlameOptions, m *mailmap.Mailmap) (*BlameResult, error) {
+	if err := o.Validate(); err != nil {
 		return nil, err
 	}
 
-	file, err := b.fRev.File(b.path)
+	b := &blamer{
+		ctx:     ctx,
+		o:       o,
+		mailmap: m,
+		ignored: make(map[plumbing.Hash]bool, len(o.IgnoreRevs)),
+		files:   make(map[plumbing.Hash]*blameFile),
+		queue:   blameQueue{suspects: make(map[blameKey]*blameSuspect)},
+	}
+
+	for _, h := range o.IgnoreRevs {
+		b.ignored[h] = true
+	}
+
+	f, err := c.File(path)
 	if err != nil {
 		return nil, err
 	}
-	finalLines, err := file.Lines()
-	if err != nil {
+
+	if b.final, err = f.Lines(); err != nil {
 		return nil, err
 	}
 
-	// Each node (line) holds the commit where it was introduced or
-	// last modified. To achieve that we use the FORWARD algorithm
-	// described in Zimmermann, et al. "Mining Version Archives for
-	// Co-changed Lines", in proceedings of the Mining Software
-	// Repositories workshop, Shanghai, May 22-23, 2006.
-	lines, err := newLines(finalLines, b.sliceGraph(len(b.graph)-1))
-	if err != nil {
+	b.lines = make([]*Line, len(b.final))
+	if len(b.final) > 0 {
+		b.assign(c, path, b.file(f), []*blameEntry{{n: len(b.final)}})
+	}
+
+	if err := b.run(); err != nil {
 		return nil, err
 	}
 
 	return &BlameResult{
 		Path:  path,
 		Rev:   c.Hash,
-		Lines: lines,
+		Lines: b.lines,
 	}, nil
 }
 
+// Blame returns a BlameResult of the file `path` at commit `c`, as
+// BlameWithOptions does, also ignoring the commits listed at the files of
+// blame.ignoreRevsFile, read from the worktree.
+func (r *Repository) Blame(c *object.Commit, path string, o *BlameOptions) (*BlameResult, error) {
+	return r.BlameContext(context.Background(), c, path, o)
+}
+
+// BlameContext returns a BlameResult of the file `path` at commit `c`, as
+// BlameWithOptions does, also ignoring the commits listed at the files of
+// blame.ignoreRevsFile, read from the worktree.
+//
+// The provided Context must be non-nil. If the context expires before the
+// operation is complete, an error is returned.
+func (r *Repository) BlameContext(ctx context.Context, c *object.Commit, path string, o *BlameOptions) (*BlameResult, error) {
+	if o == nil {
+		o = &BlameOptions{}
+	}
+
+	cfg, err := r.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.Blame.IgnoreRevsFiles) > 0 {
+		if r.wt == nil {
+			return nil, ErrIsBareRepository
+		}
+
+		opts := *o
+		opts.IgnoreRevs = append([]plumbing.Hash(nil), o.IgnoreRevs...)
+		for _, name := range cfg.Blame.IgnoreRevsFiles {
+			hashes, err := r.readIgnoreRevsFile(name)
+			if err != nil {
+				return nil, err
+			}
+
+			opts.IgnoreRevs = append(opts.IgnoreRevs, hashes...)
+		}
+
+		o = &opts
+	}
+
+	var m *mailmap.Mailmap
+	if o.UseMailmap {
+		if m, err = r.Mailmap(); err != nil {
+			return nil, err
+		}
+	}
+
+	return blame(ctx, c, path, o, m)
+}
+
+func (r *Repository) readIgnoreRevsFile(name string) (hashes []plumbing.Hash, err error) {
+	f, err := r.wt.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	return ReadIgnoreRevs(f)
+}
+
 // Line values represent the contents and author of a line in BlamedResult values.
 type Line struct {
 	// Author is the email address of the last author that modified the line.
@@ -122,170 +221,592 @@ func newLine(author, text string, date time.Time, hash plumbing.Hash) *Line {
 	}
 }
 
-func newLines(contents []string, commits []*object.Commit) ([]*Line, error) {
-	if len(contents) != len(commits) {
-		return nil, errors.New("contents and commits have different length")
+// blamer holds the state of a blame.
+type blamer struct {
+	ctx context.Context
+	o   *BlameOptions
+	// final are the lines of the blamed file.
+	final []string
+	// lines are the blamed lines, nil until attributed.
+	lines []*Line
+	// ignored are the commits of BlameOptions.IgnoreRevs.
+	ignored map[plumbing.Hash]bool
+	// files are the files read, by blob hash.
+	files map[plumbing.Hash]*blameFile
+	// queue are the suspects holding lines not attributed yet.
+	queue blameQueue
+	// mailmap, if not nil, maps the identities of the attributed commits.
+	mailmap *mailmap.Mailmap
+}
+
+// blameFile is a revision of a file, its lines are read on demand.
+type blameFile struct {
+	file  *object.File
+	text  string
+	read  bool
+	lines []string
+}
+
+func (b *blamer) file(f *object.File) *blameFile {
+	if bf, ok := b.files[f.Hash]; ok {
+		return bf
 	}
-	result := make([]*Line, 0, len(contents))
-	for i := range contents {
-		l := newLine(commits[i].Author.Email, contents[i], commits[i].Author.When, commits[i].Hash)
-		result = append(result, l)
+
+	bf := &blameFile{file: f}
+	b.files[f.Hash] = bf
+	return bf
+}
+
+func (f *blameFile) contents() (string, error) {
+	if f.read {
+		return f.text, nil
+	}
+
+	text, err := f.file.Contents()
+	if err != nil {
+		return "", err
 	}
-	return result, nil
+
+	f.text, f.lines, f.read = text, splitLines(text), true
+	return text, nil
 }
 
-// this struct is internally used by the blame function to hold its
-// inputs, outputs and state.
-type blame struct {
-	// the path of the file to blame
-	path string
-	// the commit of the final revision of the file to blame
-	fRev *object.Commit
-	// the chain of revisions affecting the the file to blame
-	revs []*object.Commit
-	// the contents of the file across all its revisions
-	data []string
-	// the graph of the lines in the file across all the revisions
-	graph [][]*object.Commit
+// fileAt returns the file at path in the commit c, nil if it doesn't exist.
+func (b *blamer) fileAt(c *object.Commit, path string) (*blameFile, error) {
+	f, err := c.File(path)
+	if err == object.ErrFileNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return b.file(f), nil
 }
 
-// calculate the history of a file "path", starting from commit "from", sorted by commit date.
-func (b *blame) fillRevs() error {
-	var err error
+// run processes the suspects, newer first, until every line is attributed.
+func (b *blamer) run() error {
+	for b.queue.Len() > 0 {
+		if err := b.ctx.Err(); err != nil {
+			return err
+		}
+
+		s := heap.Pop(&b.queue).(*blameSuspect)
+		if !b.o.Since.IsZero() && s.commit.Committer.When.Before(b.o.Since) {
+			if err := b.attribute(s, s.entries, true); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := b.pass(s); err != nil {
+			return err
+		}
+	}
 
-	b.revs, err = references(b.fRev, b.path)
-	return err
+	return nil
 }
 
-// build graph of a file from its revision history
-func (b *blame) fillGraphAndData() error {
-	//TODO: not all commits are needed, only the current rev and the prev
-	b.graph = make([][]*object.Commit, len(b.revs))
-	b.data = make([]string, len(b.revs)) // file contents in all the revisions
-	// for every revision of the file, starting with the first
-	// one...
-	for i, rev := range b.revs {
-		// get the contents of the file
-		file, err := rev.File(b.path)
+// pass passes the lines of the suspect not changed by its commit to its
+// parents, and attributes the rest to the commit. If the file is the same at
+// a parent every line is passed to it.
+func (b *blamer) pass(s *blameSuspect) error {
+	var parents []*object.Commit
+	var files []*blameFile
+	err := s.commit.Parents().ForEach(func(p *object.Commit) error {
+		f, err := b.fileAt(p, s.path)
 		if err != nil {
+			return err
+		}
+
+		parents = append(parents, p)
+		files = append(files, f)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, f := range files {
+		if f != nil && f.file.Hash == s.file.file.Hash {
+			b.assign(parents[i], s.path, f, s.entries)
 			return nil
 		}
-		b.data[i], err = file.Contents()
+	}
+
+	entries := s.entries
+	for i, f := range files {
+		if f == nil || len(entries) == 0 {
+			continue
+		}
+
+		var passed []*blameEntry
+		entries, passed, err = b.split(s, f, entries)
 		if err != nil {
 			return err
 		}
-		nLines := countLines(b.data[i])
-		// create a node for each line
-		b.graph[i] = make([]*object.Commit, nLines)
-		// assign a commit to each node
-		// if this is the first revision, then the node is assigned to
-		// this first commit.
-		if i == 0 {
-			for j := 0; j < nLines; j++ {
-				b.graph[i][j] = (*object.Commit)(b.revs[i])
+
+		b.assign(parents[i], s.path, f, passed)
+	}
+
+	if b.o.DetectMoves || b.o.DetectCopies {
+		for i, f := range files {
+			if f == nil || len(entries) == 0 {
+				continue
+			}
+
+			var passed []*blameEntry
+			entries, passed, err = b.match(s, f, entries, b.o.MoveScore)
+			if err != nil {
+				return err
 			}
-		} else {
-			// if this is not the first commit, then assign to the old
-			// commit or to the new one, depending on what the diff
-			// says.
-			b.assignOrigin(i, i-1)
+
+			b.assign(parents[i], s.path, f, passed)
 		}
 	}
-	return nil
+
+	if b.o.DetectCopies {
+		for _, p := range parents {
+			if len(entries) == 0 {
+				break
+			}
+
+			if entries, err = b.passCopies(s, p, entries); err != nil {
+				return err
+			}
+		}
+	}
+
+	if b.ignored[s.commit.Hash] && len(files) > 0 && files[0] != nil && len(entries) > 0 {
+		var passed []*blameEntry
+		entries, passed, err = b.passIgnored(s, files[0], entries)
+		if err != nil {
+			return err
+		}
+
+		b.assign(parents[0], s.path, files[0], passed)
+	}
+
+	return b.attribute(s, entries, false)
+}
+
+// passIgnored splits the entries of the suspect, changed by its ignored
+// commit, between the lines kept and the lines passed to the lines they
+// replace at the file of the first parent, guessed by their offset at the
+// block of changed lines, as git blame --ignore-rev does. The lines added
+// without replacing any line are kept.
+func (b *blamer) passIgnored(s *blameSuspect, parent *blameFile, entries []*blameEntry) (kept, passed []*blameEntry, err error) {
+	mapping, err := b.lineMapping(parent, s.file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, e := range entries {
+		for i := 0; i < e.n; i++ {
+			l := e.origStart + i
+
+			prev := l - 1
+			for prev >= 0 && mapping[prev] < 0 {
+				prev--
+			}
+
+			prevSrc := -1
+			if prev >= 0 {
+				prevSrc = mapping[prev]
+			}
+
+			next := len(parent.lines)
+			for d := l + 1; d < len(mapping); d++ {
+				if mapping[d] >= 0 {
+					next = mapping[d]
+					break
+				}
+			}
+
+			if p := prevSrc + l - prev; p < next {
+				passed = appendEntry(passed, e.start+i, p, 1)
+			} else {
+				kept = appendEntry(kept, e.start+i, l, 1)
+			}
+		}
+	}
+
+	return kept, passed, nil
 }
 
-// sliceGraph returns a slice of commits (one per line) for a particular
-// revision of a file (0=first revision).
-func (b *blame) sliceGraph(i int) []*object.Commit {
-	fVs := b.graph[i]
-	result := make([]*object.Commit, 0, len(fVs))
-	for _, v := range fVs {
-		c := object.Commit(*v)
-		result = append(result, &c)
-	}
-	return result
-}
-
-// Assigns origin to vertexes in current (c) rev from data in its previous (p)
-// revision
-func (b *blame) assignOrigin(c, p int) {
-	// assign origin based on diff info
-	hunks := diff.Do(b.data[p], b.data[c])
-	sl := -1 // source line
-	dl := -1 // destination line
-	for h := range hunks {
-		hLines := countLines(hunks[h].Text)
-		for hl := 0; hl < hLines; hl++ {
-			switch {
-			case hunks[h].Type == 0:
-				sl++
-				dl++
-				b.graph[c][dl] = b.graph[p][sl]
-			case hunks[h].Type == 1:
-				dl++
-				b.graph[c][dl] = (*object.Commit)(b.revs[c])
-			case hunks[h].Type == -1:
-				sl++
-			default:
-				panic("unreachable")
+// appendEntry appends the lines to the entries, merging them into the last
+// entry if contiguous.
+func appendEntry(entries []*blameEntry, start, origStart, n int) []*blameEntry {
+	if len(entries) > 0 {
+		last := entries[len(entries)-1]
+		if last.start+last.n == start && last.origStart+last.n == origStart {
+			last.n += n
+			return entries
+		}
+	}
+
+	return append(entries, &blameEntry{start: start, origStart: origStart, n: n})
+}
+
+// passCopies passes the blocks of lines of the entries found at the files
+// modified by the commit of the suspect with respect to the parent, as they
+// were at the parent, returning the entries left.
+func (b *blamer) passCopies(s *blameSuspect, parent *object.Commit, entries []*blameEntry) ([]*blameEntry, error) {
+	from, err := parent.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := s.commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := object.DiffTree(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ch := range changes {
+		if len(entries) == 0 {
+			break
+		}
+
+		if ch.From.Name == "" || ch.From.Name == s.path {
+			continue
+		}
+
+		f, _, err := ch.Files()
+		if err != nil {
+			return nil, err
+		}
+
+		if f == nil {
+			continue
+		}
+
+		bf := b.file(f)
+		var passed []*blameEntry
+		entries, passed, err = b.match(s, bf, entries, b.o.CopyScore)
+		if err != nil {
+			return nil, err
+		}
+
+		b.assign(parent, ch.From.Name, bf, passed)
+	}
+
+	return entries, nil
+}
+
+// match splits the entries of the suspect between the lines kept and the
+// blocks of lines found anywhere at the file f, scoring at least min. The
+// best scoring block of every entry is taken first, and the lines before and
+// after it are matched again, as git blame does.
+func (b *blamer) match(s *blameSuspect, f *blameFile, entries []*blameEntry, min int) (kept, passed []*blameEntry, err error) {
+	if _, err := s.file.contents(); err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := f.contents(); err != nil {
+		return nil, nil, err
+	}
+
+	pending := append([]*blameEntry(nil), entries...)
+	for len(pending) > 0 {
+		e := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		src := s.file.lines[e.origStart : e.origStart+e.n]
+		i, j, n, score := bestBlock(src, f.lines)
+		if n == 0 || score < min {
+			kept = append(kept, e)
+			continue
+		}
+
+		passed = append(passed, &blameEntry{start: e.start + i, origStart: j, n: n})
+		if i > 0 {
+			pending = append(pending, &blameEntry{start: e.start, origStart: e.origStart, n: i})
+		}
+
+		if rest := e.n - i - n; rest > 0 {
+			pending = append(pending, &blameEntry{start: e.start + i + n, origStart: e.origStart + i + n, n: rest})
+		}
+	}
+
+	sort.Slice(kept, func(i, j int) bool { return kept[i].start < kept[j].start })
+	return kept, passed, nil
+}
+
+// bestBlock returns the block of consecutive lines of src found at dst with
+// the highest score, the number of alphanumeric characters of its lines, as
+// its index at src and dst, its length and its score. Of the blocks with the
+// same score the longest is returned.
+func bestBlock(src, dst []string) (i, j, n, score int) {
+	scores := make([]int, len(src))
+	for k, l := range src {
+		scores[k] = alnumCount(l)
+	}
+
+	// prev and cur hold the length and the score of the blocks ending at
+	// each line of dst, for the previous and the current line of src.
+	prev := make([][2]int, len(dst)+1)
+	cur := make([][2]int, len(dst)+1)
+	for si := range src {
+		for di := range dst {
+			if src[si] != dst[di] {
+				cur[di+1] = [2]int{}
+				continue
+			}
+
+			cur[di+1] = [2]int{prev[di][0] + 1, prev[di][1] + scores[si]}
+			// the lines without alphanumeric characters, such as a closing
+			// brace, extend the block as long as it's the best one
+			if l, sc := cur[di+1][0], cur[di+1][1]; sc > score || sc == score && l > n {
+				i, j, n, score = si-l+1, di-l+1, l, sc
 			}
 		}
+
+		prev, cur = cur, prev
 	}
+
+	return i, j, n, score
 }
 
-// GoString prints the results of a Blame using git-blame's style.
-func (b *blame) GoString() string {
-	var buf bytes.Buffer
+func alnumCount(s string) int {
+	var n int
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// split splits the entries of the suspect between the lines kept, changed by
+// its commit with respect to the parent file, and the lines passed to the
+// parent file.
+func (b *blamer) split(s *blameSuspect, parent *blameFile, entries []*blameEntry) (kept, passed []*blameEntry, err error) {
+	mapping, err := b.lineMapping(parent, s.file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, e := range entries {
+		for i := 0; i < e.n; {
+			p := mapping[e.origStart+i]
+			j := i + 1
+			if p < 0 {
+				for j < e.n && mapping[e.origStart+j] < 0 {
+					j++
+				}
+
+				kept = append(kept, &blameEntry{start: e.start + i, origStart: e.origStart + i, n: j - i})
+			} else {
+				for j < e.n && mapping[e.origStart+j] == p+j-i {
+					j++
+				}
+
+				passed = append(passed, &blameEntry{start: e.start + i, origStart: p, n: j - i})
+			}
+
+			i = j
+		}
+	}
+
+	return kept, passed, nil
+}
 
-	file, err := b.fRev.File(b.path)
+// lineMapping returns, for each line of dst, the index of the same line at
+// src, or -1 if the line is not at src.
+func (b *blamer) lineMapping(src, dst *blameFile) ([]int, error) {
+	srcText, err := src.contents()
 	if err != nil {
-		panic("PrettyPrint: internal error in repo.Data")
+		return nil, err
 	}
-	contents, err := file.Contents()
+
+	dstText, err := dst.contents()
 	if err != nil {
-		panic("PrettyPrint: internal error in repo.Data")
+		return nil, err
 	}
 
-	lines := strings.Split(contents, "\n")
-	// max line number length
-	mlnl := len(strconv.Itoa(len(lines)))
-	// max author length
-	mal := b.maxAuthorLength()
-	format := fmt.Sprintf("%%s (%%-%ds %%%dd) %%s\n",
-		mal, mlnl)
+	mapping := make([]int, 0, len(dst.lines))
+	sl := 0
+	for _, h := range diff.Do(srcText, dstText) {
+		n := countLines(h.Text)
+		switch h.Type {
+		case 0:
+			for i := 0; i < n; i++ {
+				mapping = append(mapping, sl+i)
+			}
+
+			sl += n
+		case 1:
+			for i := 0; i < n; i++ {
+				mapping = append(mapping, -1)
+			}
+		case -1:
+			sl += n
+		}
+	}
 
-	fVs := b.graph[len(b.graph)-1]
-	for ln, v := range fVs {
-		fmt.Fprintf(&buf, format, v.Hash.String()[:8],
-			prettyPrintAuthor(fVs[ln]), ln+1, lines[ln])
+	for len(mapping) < len(dst.lines) {
+		mapping = append(mapping, -1)
 	}
-	return buf.String()
+
+	return mapping[:len(dst.lines)], nil
 }
 
-// utility function to pretty print the author.
-func prettyPrintAuthor(c *object.Commit) string {
-	return fmt.Sprintf("%s %s", c.Author.Name, c.Author.When.Format("2006-01-02"))
+// assign makes the commit the suspect of the given entries, at the file at
+// path.
+func (b *blamer) assign(c *object.Commit, path string, f *blameFile, entries []*blameEntry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	key := blameKey{c.Hash, path}
+	if s, ok := b.queue.suspects[key]; ok {
+		s.add(entries)
+		return
+	}
+
+	s := &blameSuspect{commit: c, path: path, file: f}
+	s.add(entries)
+	heap.Push(&b.queue, s)
 }
 
-// utility function to calculate the number of runes needed
-// to print the longest author name in the blame of a file.
-func (b *blame) maxAuthorLength() int {
-	memo := make(map[plumbing.Hash]struct{}, len(b.graph)-1)
-	fVs := b.graph[len(b.graph)-1]
-	m := 0
-	for ln := range fVs {
-		if _, ok := memo[fVs[ln].Hash]; ok {
+// attribute attributes the entries of the suspect to its commit, reporting
+// them to BlameOptions.Incremental.
+func (b *blamer) attribute(s *blameSuspect, entries []*blameEntry, boundary bool) error {
+	c := s.commit
+	if b.mailmap != nil {
+		c = mailmapCommit(b.mailmap, c)
+	}
+
+	for _, e := range entries {
+		for i := 0; i < e.n; i++ {
+			text := b.final[e.start+i]
+			b.lines[e.start+i] = newLine(c.Author.Email, text, c.Author.When, c.Hash)
+		}
+
+		if b.o.Incremental == nil {
 			continue
 		}
-		memo[fVs[ln].Hash] = struct{}{}
-		m = max(m, utf8.RuneCountInString(prettyPrintAuthor(fVs[ln])))
+
+		err := b.o.Incremental(&BlameHunk{
+			Commit:    c,
+			Path:      s.path,
+			Start:     e.start,
+			OrigStart: e.origStart,
+			Lines:     b.lines[e.start : e.start+e.n],
+			Boundary:  boundary,
+		})
+		if err != nil {
+			return err
+		}
 	}
-	return m
+
+	return nil
 }
 
-func max(a, b int) int {
-	if a > b {
-		return a
+// blameEntry is a range of consecutive lines of the blamed file held by a
+// suspect.
+type blameEntry struct {
+	// start is the index of the first line at the blamed file.
+	start int
+	// origStart is the index of the first line at the file of the suspect.
+	origStart int
+	// n is the number of lines.
+	n int
+}
+
+type blameKey struct {
+	commit plumbing.Hash
+	path   string
+}
+
+// blameSuspect is a commit holding lines of the blamed file not attributed
+// yet, which may have been introduced by it or by its parents.
+type blameSuspect struct {
+	commit  *object.Commit
+	path    string
+	file    *blameFile
+	entries []*blameEntry
+}
+
+// add adds the entries to the suspect, keeping them sorted and merging the
+// contiguous ones.
+func (s *blameSuspect) add(entries []*blameEntry) {
+	all := append(s.entries, entries...)
+	sort.Slice(all, func(i, j int) bool { return all[i].start < all[j].start })
+
+	s.entries = all[:0]
+	for _, e := range all {
+		if n := len(s.entries); n > 0 {
+			last := s.entries[n-1]
+			if last.start+last.n == e.start && last.origStart+last.n == e.origStart {
+				last.n += e.n
+				continue
+			}
+		}
+
+		s.entries = append(s.entries, e)
 	}
-	return b
+}
+
+// blameQueue is a heap of suspects, the newer commits first.
+type blameQueue struct {
+	list     []*blameSuspect
+	suspects map[blameKey]*blameSuspect
+}
+
+func (q *blameQueue) Len() int { return len(q.list) }
+
+func (q *blameQueue) Less(i, j int) bool {
+	return q.list[i].commit.Committer.When.After(q.list[j].commit.Committer.When)
+}
+
+func (q *blameQueue) Swap(i, j int) { q.list[i], q.list[j] = q.list[j], q.list[i] }
+
+func (q *blameQueue) Push(x interface{}) {
+	s := x.(*blameSuspect)
+	q.list = append(q.list, s)
+	q.suspects[blameKey{s.commit.Hash, s.path}] = s
+}
+
+func (q *blameQueue) Pop() interface{} {
+	n := len(q.list)
+	s := q.list[n-1]
+	q.list = q.list[:n-1]
+	delete(q.suspects, blameKey{s.commit.Hash, s.path})
+	return s
+}
+
+// ReadIgnoreRevs reads the commits listed at an ignore revs file, as the
+// files of blame.ignoreRevsFile, one full hash per line. The empty lines and
+// the comments, starting with '#', are skipped.
+func ReadIgnoreRevs(r io.Reader) ([]plumbing.Hash, error) {
+	var hashes []plumbing.Hash
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if _, err := hex.DecodeString(line); err != nil || len(line) != 40 {
+			return nil, ErrInvalidIgnoreRev
+		}
+
+		hashes = append(hashes, plumbing.NewHash(line))
+	}
+
+	return hashes, scanner.Err()
 }
@@ -1,9 +1,18 @@
 package git
 
 import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
 
 	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
 	"gopkg.in/src-d/go-git-fixtures.v3"
 )
 
@@ -39,6 +48,327 @@ func (s *BlameSuite) TestBlame(c *C) {
 	}
 }
 
+func (s *BlameSuite) TestBlameContextCancel(c *C) {
+	t := blameTests[0]
+	r := s.NewRepositoryFromPackfile(fixtures.ByURL(t.repo).One())
+
+	commit, err := r.CommitObject(plumbing.NewHash(t.rev))
+	c.Assert(err, IsNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = BlameContext(ctx, commit, t.path)
+	c.Assert(err, Equals, context.Canceled)
+}
+
+func (s *BlameSuite) TestBlameIncremental(c *C) {
+	for _, t := range blameTests {
+		r := s.NewRepositoryFromPackfile(fixtures.ByURL(t.repo).One())
+
+		commit, err := r.CommitObject(plumbing.NewHash(t.rev))
+		c.Assert(err, IsNil)
+
+		var hunks []*BlameHunk
+		obt, err := BlameWithOptions(context.Background(), commit, t.path, &BlameOptions{
+			Incremental: func(h *BlameHunk) error {
+				hunks = append(hunks, h)
+				return nil
+			},
+		})
+		c.Assert(err, IsNil)
+
+		seen := make([]bool, len(obt.Lines))
+		for _, h := range hunks {
+			c.Assert(h.Path, Equals, t.path)
+			c.Assert(h.Boundary, Equals, false)
+			for i, l := range h.Lines {
+				c.Assert(seen[h.Start+i], Equals, false)
+				seen[h.Start+i] = true
+				c.Assert(l, Equals, obt.Lines[h.Start+i])
+				c.Assert(l.Hash, Equals, h.Commit.Hash)
+			}
+		}
+
+		for i := range seen {
+			c.Assert(seen[i], Equals, true, Commentf("line %d not reported", i))
+		}
+	}
+}
+
+func (s *BlameSuite) TestBlameIncrementalError(c *C) {
+	t := blameTests[0]
+	r := s.NewRepositoryFromPackfile(fixtures.ByURL(t.repo).One())
+
+	commit, err := r.CommitObject(plumbing.NewHash(t.rev))
+	c.Assert(err, IsNil)
+
+	expected := errors.New("foo")
+	_, err = BlameWithOptions(context.Background(), commit, t.path, &BlameOptions{
+		Incremental: func(*BlameHunk) error { return expected },
+	})
+	c.Assert(err, Equals, expected)
+}
+
+func (s *BlameSuite) TestBlameSince(c *C) {
+	for _, t := range blameTests {
+		r := s.NewRepositoryFromPackfile(fixtures.ByURL(t.repo).One())
+
+		commit, err := r.CommitObject(plumbing.NewHash(t.rev))
+		c.Assert(err, IsNil)
+
+		since := commit.Committer.When
+		var boundary int
+		obt, err := BlameWithOptions(context.Background(), commit, t.path, &BlameOptions{
+			Since: since,
+			Incremental: func(h *BlameHunk) error {
+				if h.Boundary {
+					c.Assert(h.Commit.Committer.When.Before(since), Equals, true)
+					boundary += len(h.Lines)
+				}
+
+				return nil
+			},
+		})
+		c.Assert(err, IsNil)
+
+		var older int
+		for i, l := range obt.Lines {
+			c.Assert(l, NotNil)
+			if l.Hash.String() == t.blames[i] {
+				continue
+			}
+
+			older++
+		}
+
+		c.Assert(older <= boundary, Equals, true)
+	}
+}
+
+func (s *BlameSuite) commitFiles(c *C, r *Repository, files map[string]string) plumbing.Hash {
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	for name, content := range files {
+		c.Assert(util.WriteFile(w.Filesystem, name, []byte(content), 0644), IsNil)
+		_, err = w.Add(name)
+		c.Assert(err, IsNil)
+	}
+
+	h, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+	return h
+}
+
+const (
+	blameBlockA = "func foo() int {\n\tvalue := fooBarQux(1, 2, 3)\n\treturn value * fooFactor\n}\n"
+	blameBlockB = "func bar() int {\n\tvalue := barQuxFoo(4, 5, 6)\n\treturn value * barFactor\n}\n"
+)
+
+func (s *BlameSuite) newMovesRepository(c *C) (r *Repository, first, second *object.Commit) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	h1 := s.commitFiles(c, r, map[string]string{
+		"a.go": blameBlockA + "\n" + blameBlockB,
+	})
+	h2 := s.commitFiles(c, r, map[string]string{
+		"a.go": blameBlockB + "\n" + blameBlockA,
+		"b.go": "package b\n\n" + blameBlockA,
+	})
+
+	first, err = r.CommitObject(h1)
+	c.Assert(err, IsNil)
+	second, err = r.CommitObject(h2)
+	c.Assert(err, IsNil)
+	return r, first, second
+}
+
+func (s *BlameSuite) blamedHashes(res *BlameResult) []plumbing.Hash {
+	var hashes []plumbing.Hash
+	for _, l := range res.Lines {
+		hashes = append(hashes, l.Hash)
+	}
+
+	return hashes
+}
+
+func (s *BlameSuite) TestBlameDetectMoves(c *C) {
+	_, first, second := s.newMovesRepository(c)
+
+	res, err := Blame(second, "a.go")
+	c.Assert(err, IsNil)
+	moved := 0
+	for _, h := range s.blamedHashes(res) {
+		if h == second.Hash {
+			moved++
+		}
+	}
+	c.Assert(moved > 0, Equals, true)
+
+	res, err = BlameWithOptions(context.Background(), second, "a.go", &BlameOptions{
+		DetectMoves: true,
+	})
+	c.Assert(err, IsNil)
+	for i, h := range s.blamedHashes(res) {
+		if res.Lines[i].Text == "" {
+			continue
+		}
+
+		c.Assert(h, Equals, first.Hash, Commentf("line %d", i))
+	}
+}
+
+func (s *BlameSuite) TestBlameDetectMovesScore(c *C) {
+	_, _, second := s.newMovesRepository(c)
+
+	res, err := BlameWithOptions(context.Background(), second, "a.go", &BlameOptions{
+		DetectMoves: true,
+		MoveScore:   1000,
+	})
+	c.Assert(err, IsNil)
+
+	moved := 0
+	for _, h := range s.blamedHashes(res) {
+		if h == second.Hash {
+			moved++
+		}
+	}
+	c.Assert(moved > 0, Equals, true)
+}
+
+func (s *BlameSuite) TestBlameDetectCopies(c *C) {
+	_, first, second := s.newMovesRepository(c)
+
+	res, err := BlameWithOptions(context.Background(), second, "b.go", &BlameOptions{
+		DetectMoves: true,
+	})
+	c.Assert(err, IsNil)
+	for _, h := range s.blamedHashes(res) {
+		c.Assert(h, Equals, second.Hash)
+	}
+
+	var paths []string
+	res, err = BlameWithOptions(context.Background(), second, "b.go", &BlameOptions{
+		DetectCopies: true,
+		Incremental: func(h *BlameHunk) error {
+			if h.Commit.Hash == first.Hash {
+				paths = append(paths, h.Path)
+			}
+
+			return nil
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"a.go"})
+
+	hashes := s.blamedHashes(res)
+	c.Assert(hashes[0], Equals, second.Hash)
+	c.Assert(hashes[1], Equals, second.Hash)
+	for _, h := range hashes[2:] {
+		c.Assert(h, Equals, first.Hash)
+	}
+}
+
+func (s *BlameSuite) newReformatRepository(c *C) (r *Repository, hashes []plumbing.Hash) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	for _, content := range []string{
+		"foo(1,2)\nbar(3,4)\nqux\n",
+		"foo(1, 2)\nbar(3, 4)\nqux\n",
+		"foo(1, 2)\nbar(3, 4)\nqux\nbaz\n",
+	} {
+		hashes = append(hashes, s.commitFiles(c, r, map[string]string{"foo.go": content}))
+	}
+
+	return r, hashes
+}
+
+func (s *BlameSuite) TestBlameIgnoreRevs(c *C) {
+	r, hashes := s.newReformatRepository(c)
+
+	head, err := r.CommitObject(hashes[2])
+	c.Assert(err, IsNil)
+
+	res, err := Blame(head, "foo.go")
+	c.Assert(err, IsNil)
+	c.Assert(s.blamedHashes(res), DeepEquals, []plumbing.Hash{
+		hashes[1], hashes[1], hashes[0], hashes[2],
+	})
+
+	res, err = BlameWithOptions(context.Background(), head, "foo.go", &BlameOptions{
+		IgnoreRevs: []plumbing.Hash{hashes[1]},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(s.blamedHashes(res), DeepEquals, []plumbing.Hash{
+		hashes[0], hashes[0], hashes[0], hashes[2],
+	})
+	c.Assert(res.Lines[0].Text, Equals, "foo(1, 2)")
+}
+
+func (s *BlameSuite) TestBlameIgnoreRevsAdded(c *C) {
+	r, hashes := s.newReformatRepository(c)
+
+	head, err := r.CommitObject(hashes[2])
+	c.Assert(err, IsNil)
+
+	res, err := BlameWithOptions(context.Background(), head, "foo.go", &BlameOptions{
+		IgnoreRevs: []plumbing.Hash{hashes[2]},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(s.blamedHashes(res)[3], Equals, hashes[2])
+}
+
+func (s *BlameSuite) TestRepositoryBlameIgnoreRevsFile(c *C) {
+	r, hashes := s.newReformatRepository(c)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, ".git-blame-ignore-revs", []byte(
+		"# reformat\n"+hashes[1].String()+"\n",
+	), 0644)
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Blame.IgnoreRevsFiles = []string{".git-blame-ignore-revs"}
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	head, err := r.CommitObject(hashes[2])
+	c.Assert(err, IsNil)
+
+	res, err := r.Blame(head, "foo.go", nil)
+	c.Assert(err, IsNil)
+	c.Assert(s.blamedHashes(res), DeepEquals, []plumbing.Hash{
+		hashes[0], hashes[0], hashes[0], hashes[2],
+	})
+
+	cfg.Blame.IgnoreRevsFiles = []string{"missing"}
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	_, err = r.Blame(head, "foo.go", nil)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *BlameSuite) TestReadIgnoreRevs(c *C) {
+	hashes, err := ReadIgnoreRevs(strings.NewReader(`# formatting
+6ecf0ef2c2dffb796033e5a02219af86ec6584e5
+
+  918c48b83bd081e863dbe1b80f8998f058cd8294 # gofmt
+`))
+	c.Assert(err, IsNil)
+	c.Assert(hashes, DeepEquals, []plumbing.Hash{
+		plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+		plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294"),
+	})
+
+	_, err = ReadIgnoreRevs(strings.NewReader("6ecf0ef\n"))
+	c.Assert(err, Equals, ErrInvalidIgnoreRev)
+}
+
 func (s *BlameSuite) mockBlame(c *C, t blameTest, r *Repository) (blame *BlameResult) {
 	commit, err := r.CommitObject(plumbing.NewHash(t.rev))
 	c.Assert(err, IsNil, Commentf("%v: repo=%s, rev=%s", err, t.repo, t.rev))
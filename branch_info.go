@@ -0,0 +1,189 @@
+package git
+
+import (
+	"sort"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+const (
+	autoSetupMergeAlways = "always"
+	autoSetupMergeFalse  = "false"
+)
+
+// BranchInfo is a local branch along with its upstream, the data shown by
+// `git branch -vv`.
+type BranchInfo struct {
+	// Name is the reference of the branch, e.g. refs/heads/master.
+	Name plumbing.ReferenceName
+	// Hash is the commit the branch points to.
+	Hash plumbing.Hash
+	// Config is the configuration of the branch, nil if it has none.
+	Config *config.Branch
+	// Upstream is the branch tracked, empty if the branch has no upstream.
+	Upstream plumbing.ReferenceName
+	// UpstreamHash is the commit Upstream points to, zero if it is gone.
+	UpstreamHash plumbing.Hash
+	// UpstreamGone is true if Upstream is configured but doesn't exist.
+	UpstreamGone bool
+	// Ahead is the number of commits reachable from Hash and not from
+	// UpstreamHash.
+	Ahead int
+	// Behind is the number of commits reachable from UpstreamHash and not
+	// from Hash.
+	Behind int
+}
+
+// BranchInfo returns the branch with the given short name, e.g. master,
+// along with its configuration, its upstream and the commits ahead and
+// behind of it. plumbing.ErrReferenceNotFound is returned if the branch
+// doesn't exist.
+func (r *Repository) BranchInfo(name string) (*BranchInfo, error) {
+	cfg, err := r.Storer.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	ref, err := r.Reference(branchReferenceName(name), true)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.branchInfo(cfg, ref)
+}
+
+// BranchesInfo returns the info of all the local branches, as BranchInfo
+// does, sorted by name.
+func (r *Repository) BranchesInfo() ([]*BranchInfo, error) {
+	cfg, err := r.Storer.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := r.Branches()
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []*BranchInfo
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+
+		info, err := r.branchInfo(cfg, ref)
+		if err != nil {
+			return err
+		}
+
+		infos = append(infos, info)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+
+	return infos, nil
+}
+
+func (r *Repository) branchInfo(cfg *config.Config, ref *plumbing.Reference) (*BranchInfo, error) {
+	info := &BranchInfo{Name: ref.Name(), Hash: ref.Hash()}
+	if err := r.resolveUpstream(cfg, info); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// resolveUpstream fills the configuration, the upstream and the ahead and
+// behind counts of the branch at info, from its name and its hash, the counts
+// are not computed if the hash is zero.
+func (r *Repository) resolveUpstream(cfg *config.Config, info *BranchInfo) error {
+	b, ok := cfg.Branches[info.Name.Short()]
+	if !ok {
+		return nil
+	}
+
+	info.Config = b
+	info.Upstream = upstreamReference(cfg, b)
+	if info.Upstream == "" {
+		return nil
+	}
+
+	ref, err := r.Reference(info.Upstream, true)
+	if err == plumbing.ErrReferenceNotFound {
+		info.UpstreamGone = true
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	info.UpstreamHash = ref.Hash()
+	if info.Hash.IsZero() {
+		return nil
+	}
+
+	info.Ahead, info.Behind, err = r.AheadBehind(info.Hash, info.UpstreamHash)
+	return err
+}
+
+// setupTracking configures the upstream of a branch created from start, as
+// git does following branch.autoSetupMerge: a remote-tracking branch is
+// tracked by default, and a local branch only if it is set to "always".
+func (r *Repository) setupTracking(branch, start plumbing.ReferenceName) error {
+	cfg, err := r.Storer.Config()
+	if err != nil {
+		return err
+	}
+
+	mode := cfg.Branch.AutoSetupMerge
+	if mode == autoSetupMergeFalse {
+		return nil
+	}
+
+	b := trackingBranch(cfg, start)
+	if b == nil || (b.Remote == "." && mode != autoSetupMergeAlways) {
+		return nil
+	}
+
+	b.Name = branch.Short()
+	cfg.Branches[b.Name] = b
+	return r.Storer.SetConfig(cfg)
+}
+
+// trackingBranch returns the configuration of a branch tracking start, nil
+// if start is neither a local branch nor fetched from a remote.
+func trackingBranch(cfg *config.Config, start plumbing.ReferenceName) *config.Branch {
+	if start.IsBranch() {
+		return &config.Branch{Remote: ".", Merge: start}
+	}
+
+	names := make([]string, 0, len(cfg.Remotes))
+	for name := range cfg.Remotes {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		for _, rs := range cfg.Remotes[name].Fetch {
+			rs = rs.Reverse()
+			if !rs.Match(start) {
+				continue
+			}
+
+			if merge := rs.Dst(start); merge.IsBranch() {
+				return &config.Branch{Remote: name, Merge: merge}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package git
+
+import (
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+)
+
+type BranchInfoSuite struct {
+	BaseSuite
+	r *Repository
+}
+
+var _ = Suite(&BranchInfoSuite{})
+
+// SetUpTest creates a repository with two commits at master, fetched as
+// origin/master, and a local commit at feature on top of the first one.
+func (s *BranchInfoSuite) SetUpTest(c *C) {
+	var err error
+	s.r, err = Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	_, err = s.r.CreateRemote(&config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{"https://example.com/foo.git"},
+	})
+	c.Assert(err, IsNil)
+
+	first := s.commit(c, "refs/heads/master", "foo")
+	s.commit(c, "refs/heads/master", "bar")
+
+	master, err := s.r.Reference("refs/heads/master", false)
+	c.Assert(err, IsNil)
+	c.Assert(s.r.Storer.SetReference(
+		plumbing.NewHashReference("refs/remotes/origin/master", master.Hash()),
+	), IsNil)
+
+	c.Assert(s.r.Storer.SetReference(
+		plumbing.NewHashReference("refs/heads/feature", first),
+	), IsNil)
+	s.commit(c, "refs/heads/feature", "qux")
+}
+
+func (s *BranchInfoSuite) commit(c *C, branch plumbing.ReferenceName, path string) plumbing.Hash {
+	h, err := s.r.CommitChanges(path+"\n", &CommitChangesOptions{
+		Branch:  branch,
+		Author:  defaultSignature(),
+		Changes: []FileChange{{Path: path, Content: strings.NewReader(path)}},
+	})
+	c.Assert(err, IsNil)
+	return h
+}
+
+func (s *BranchInfoSuite) TestBranchInfo(c *C) {
+	c.Assert(s.r.CreateBranch(&config.Branch{
+		Name:   "feature",
+		Remote: "origin",
+		Merge:  "refs/heads/master",
+	}), IsNil)
+
+	info, err := s.r.BranchInfo("feature")
+	c.Assert(err, IsNil)
+	c.Assert(info.Name, Equals, plumbing.ReferenceName("refs/heads/feature"))
+	c.Assert(info.Config, NotNil)
+	c.Assert(info.Config.Remote, Equals, "origin")
+	c.Assert(info.Upstream, Equals, plumbing.ReferenceName("refs/remotes/origin/master"))
+	c.Assert(info.UpstreamGone, Equals, false)
+	c.Assert(info.Ahead, Equals, 1)
+	c.Assert(info.Behind, Equals, 1)
+
+	upstream, err := s.r.Reference(info.Upstream, false)
+	c.Assert(err, IsNil)
+	c.Assert(info.UpstreamHash, Equals, upstream.Hash())
+}
+
+func (s *BranchInfoSuite) TestBranchInfoNoUpstream(c *C) {
+	info, err := s.r.BranchInfo("master")
+	c.Assert(err, IsNil)
+	c.Assert(info.Config, IsNil)
+	c.Assert(info.Upstream, Equals, plumbing.ReferenceName(""))
+	c.Assert(info.UpstreamHash.IsZero(), Equals, true)
+
+	_, err = s.r.BranchInfo("foo")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *BranchInfoSuite) TestBranchInfoUpstreamGone(c *C) {
+	c.Assert(s.r.CreateBranch(&config.Branch{
+		Name:   "feature",
+		Remote: "origin",
+		Merge:  "refs/heads/feature",
+	}), IsNil)
+
+	info, err := s.r.BranchInfo("feature")
+	c.Assert(err, IsNil)
+	c.Assert(info.Upstream, Equals, plumbing.ReferenceName("refs/remotes/origin/feature"))
+	c.Assert(info.UpstreamGone, Equals, true)
+	c.Assert(info.Ahead, Equals, 0)
+	c.Assert(info.Behind, Equals, 0)
+}
+
+func (s *BranchInfoSuite) TestBranchesInfo(c *C) {
+	c.Assert(s.r.CreateBranch(&config.Branch{
+		Name:   "master",
+		Remote: ".",
+		Merge:  "refs/heads/feature",
+	}), IsNil)
+
+	infos, err := s.r.BranchesInfo()
+	c.Assert(err, IsNil)
+	c.Assert(infos, HasLen, 2)
+	c.Assert(infos[0].Name, Equals, plumbing.ReferenceName("refs/heads/feature"))
+	c.Assert(infos[0].Upstream, Equals, plumbing.ReferenceName(""))
+	c.Assert(infos[1].Name, Equals, plumbing.ReferenceName("refs/heads/master"))
+	c.Assert(infos[1].Upstream, Equals, plumbing.ReferenceName("refs/heads/feature"))
+	c.Assert(infos[1].Ahead, Equals, 1)
+	c.Assert(infos[1].Behind, Equals, 1)
+}
+
+func (s *BranchInfoSuite) TestCheckoutStartRemoteBranch(c *C) {
+	w, err := s.r.Worktree()
+	c.Assert(err, IsNil)
+
+	err = w.Checkout(&CheckoutOptions{
+		Branch: "refs/heads/foo",
+		Create: true,
+		Start:  "refs/remotes/origin/master",
+	})
+	c.Assert(err, IsNil)
+
+	info, err := s.r.BranchInfo("foo")
+	c.Assert(err, IsNil)
+	c.Assert(info.Config, NotNil)
+	c.Assert(info.Config.Remote, Equals, "origin")
+	c.Assert(info.Config.Merge, Equals, plumbing.ReferenceName("refs/heads/master"))
+	c.Assert(info.Upstream, Equals, plumbing.ReferenceName("refs/remotes/origin/master"))
+	c.Assert(info.Hash, Equals, info.UpstreamHash)
+
+	head, err := s.r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, plumbing.ReferenceName("refs/heads/foo"))
+}
+
+func (s *BranchInfoSuite) TestCheckoutStartLocalBranch(c *C) {
+	w, err := s.r.Worktree()
+	c.Assert(err, IsNil)
+
+	err = w.Checkout(&CheckoutOptions{
+		Branch: "refs/heads/foo",
+		Create: true,
+		Start:  "refs/heads/feature",
+	})
+	c.Assert(err, IsNil)
+
+	_, err = s.r.Branch("foo")
+	c.Assert(err, Equals, ErrBranchNotFound)
+
+	cfg, err := s.r.Config()
+	c.Assert(err, IsNil)
+	cfg.Branch.AutoSetupMerge = "always"
+	c.Assert(s.r.Storer.SetConfig(cfg), IsNil)
+
+	err = w.Checkout(&CheckoutOptions{
+		Branch: "refs/heads/bar",
+		Create: true,
+		Start:  "refs/heads/feature",
+	})
+	c.Assert(err, IsNil)
+
+	b, err := s.r.Branch("bar")
+	c.Assert(err, IsNil)
+	c.Assert(b.Remote, Equals, ".")
+	c.Assert(b.Merge, Equals, plumbing.ReferenceName("refs/heads/feature"))
+}
+
+func (s *BranchInfoSuite) TestCheckoutStartAutoSetupMergeFalse(c *C) {
+	cfg, err := s.r.Config()
+	c.Assert(err, IsNil)
+	cfg.Branch.AutoSetupMerge = "false"
+	c.Assert(s.r.Storer.SetConfig(cfg), IsNil)
+
+	w, err := s.r.Worktree()
+	c.Assert(err, IsNil)
+
+	err = w.Checkout(&CheckoutOptions{
+		Branch: "refs/heads/foo",
+		Create: true,
+		Start:  "refs/remotes/origin/master",
+	})
+	c.Assert(err, IsNil)
+
+	_, err = s.r.Branch("foo")
+	c.Assert(err, Equals, ErrBranchNotFound)
+}
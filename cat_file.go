@@ -0,0 +1,121 @@
+package git
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+// CatFileBatch reads object names from in, one per line, and writes to out
+// the hash, the type and the size of every object, as
+// `git cat-file --batch-check` does, or also their contents if
+// o.Contents is set, as `git cat-file --batch` does. A name is a hash or any
+// revision accepted by ResolveRevision; the names that can't be resolved are
+// written followed by "missing". The objects are read with a single
+// storer.ObjectBatch, reusing the open packfiles between objects.
+func (r *Repository) CatFileBatch(in io.Reader, out io.Writer, o *CatFileOptions) (err error) {
+	if o == nil {
+		o = &CatFileOptions{}
+	}
+
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	b, err := storer.NewObjectBatch(r.Storer)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(b, &err)
+
+	w := bufio.NewWriter(out)
+	s := bufio.NewScanner(in)
+	for s.Scan() {
+		if err := r.catFile(w, b, s.Text(), o); err != nil {
+			return err
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+func (r *Repository) catFile(w *bufio.Writer, b storer.ObjectBatch, name string, o *CatFileOptions) error {
+	h, ok := r.resolveObjectName(name)
+	if !ok {
+		_, err := fmt.Fprintf(w, "%s missing\n", name)
+		return err
+	}
+
+	if !o.Contents {
+		info, err := b.ObjectInfo(h)
+		if err == plumbing.ErrObjectNotFound {
+			_, err = fmt.Fprintf(w, "%s missing\n", name)
+			return err
+		}
+
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(w, "%s %s %d\n", info.Hash, info.Type, info.Size)
+		return err
+	}
+
+	obj, err := b.EncodedObject(plumbing.AnyObject, h)
+	if err == plumbing.ErrObjectNotFound {
+		_, err = fmt.Fprintf(w, "%s missing\n", name)
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "%s %s %d\n", obj.Hash(), obj.Type(), obj.Size()); err != nil {
+		return err
+	}
+
+	if err := writeObjectContent(w, obj); err != nil {
+		return err
+	}
+
+	return w.WriteByte('\n')
+}
+
+func writeObjectContent(w io.Writer, obj plumbing.EncodedObject) (err error) {
+	rd, err := obj.Reader()
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(rd, &err)
+
+	_, err = io.Copy(w, rd)
+	return err
+}
+
+// resolveObjectName returns the hash named by a full hash or by a revision,
+// a full hash is not required to exist.
+func (r *Repository) resolveObjectName(name string) (plumbing.Hash, bool) {
+	if len(name) == 40 {
+		if h := plumbing.NewHash(name); h.String() == name {
+			return h, true
+		}
+	}
+
+	h, err := r.ResolveRevision(plumbing.Revision(name))
+	if err != nil {
+		return plumbing.ZeroHash, false
+	}
+
+	return *h, true
+}
@@ -0,0 +1,65 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	. "gopkg.in/check.v1"
+)
+
+type CatFileSuite struct {
+	BaseSuite
+	r      *Repository
+	commit plumbing.Hash
+}
+
+var _ = Suite(&CatFileSuite{})
+
+func (s *CatFileSuite) SetUpTest(c *C) {
+	var err error
+	s.r, err = PlainInit(c.MkDir(), true)
+	c.Assert(err, IsNil)
+
+	s.commit, err = s.r.CommitChanges("foo\n", &CommitChangesOptions{
+		Author:  defaultSignature(),
+		Changes: []FileChange{{Path: "foo", Content: strings.NewReader("foo")}},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *CatFileSuite) TestCatFileBatchCheck(c *C) {
+	commit, err := s.r.CommitObject(s.commit)
+	c.Assert(err, IsNil)
+
+	in := strings.NewReader(fmt.Sprintf("master\n%s\n19102815663d23f8b75a47e7a01965dcdc96468c\nfoo\n%s\n",
+		commit.TreeHash, plumbing.ZeroHash))
+
+	out := bytes.NewBuffer(nil)
+	err = s.r.CatFileBatch(in, out, nil)
+	c.Assert(err, IsNil)
+
+	c.Assert(out.String(), Equals, fmt.Sprintf(
+		"%s commit %d\n%s tree 31\n19102815663d23f8b75a47e7a01965dcdc96468c blob 3\nfoo missing\n%s missing\n",
+		s.commit, s.size(c, s.commit), commit.TreeHash, plumbing.ZeroHash,
+	))
+}
+
+func (s *CatFileSuite) TestCatFileBatchContents(c *C) {
+	in := strings.NewReader("19102815663d23f8b75a47e7a01965dcdc96468c\nfoo\n")
+
+	out := bytes.NewBuffer(nil)
+	err := s.r.CatFileBatch(in, out, &CatFileOptions{Contents: true})
+	c.Assert(err, IsNil)
+
+	c.Assert(out.String(), Equals,
+		"19102815663d23f8b75a47e7a01965dcdc96468c blob 3\nfoo\nfoo missing\n")
+}
+
+func (s *CatFileSuite) size(c *C, h plumbing.Hash) int64 {
+	obj, err := s.r.Storer.EncodedObject(plumbing.AnyObject, h)
+	c.Assert(err, IsNil)
+	return obj.Size()
+}
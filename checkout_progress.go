@@ -0,0 +1,116 @@
+package git
+
+import (
+	"sync"
+)
+
+// CheckoutPhase is a step of the update of the worktree during a checkout,
+// a reset or a clone.
+type CheckoutPhase int8
+
+const (
+	// CheckoutComparing is the comparison of the index with the worktree,
+	// finding the files to be removed and written.
+	CheckoutComparing CheckoutPhase = iota
+	// CheckoutRemoving is the removal of the files not present at the
+	// checked out tree.
+	CheckoutRemoving
+	// CheckoutWriting is the writing of the files of the checked out tree.
+	CheckoutWriting
+	// CheckoutDone is reported once the worktree and the index are updated.
+	CheckoutDone
+)
+
+// String returns the name of the phase.
+func (p CheckoutPhase) String() string {
+	switch p {
+	case CheckoutComparing:
+		return "comparing"
+	case CheckoutRemoving:
+		return "removing"
+	case CheckoutWriting:
+		return "writing"
+	case CheckoutDone:
+		return "done"
+	}
+
+	return "unknown"
+}
+
+// CheckoutStatus is the progress of the update of the worktree.
+type CheckoutStatus struct {
+	// Phase is the current step of the update.
+	Phase CheckoutPhase
+	// Files is the number of files written.
+	Files int
+	// TotalFiles is the number of files to be written, known once the
+	// CheckoutWriting phase starts.
+	TotalFiles int
+	// Bytes is the size of the files written.
+	Bytes int64
+	// Name is the path of the last file written, if any.
+	Name string
+}
+
+// CheckoutProgress receives the progress of the update of the worktree. It is
+// called at the start of every phase and after every file is written, the
+// calls are never concurrent, even when the files are written by several
+// goroutines.
+type CheckoutProgress interface {
+	UpdateCheckout(s CheckoutStatus)
+}
+
+// CheckoutProgressFunc is a function implementing CheckoutProgress.
+type CheckoutProgressFunc func(s CheckoutStatus)
+
+// UpdateCheckout calls f(s).
+func (f CheckoutProgressFunc) UpdateCheckout(s CheckoutStatus) {
+	f(s)
+}
+
+// checkoutReporter sends the CheckoutStatus to a CheckoutProgress, which may
+// be nil.
+type checkoutReporter struct {
+	progress CheckoutProgress
+
+	m      sync.Mutex
+	status CheckoutStatus
+}
+
+func (r *checkoutReporter) phase(p CheckoutPhase) {
+	if r.progress == nil {
+		return
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.status.Phase = p
+	r.progress.UpdateCheckout(r.status)
+}
+
+func (r *checkoutReporter) writing(total int) {
+	if r.progress == nil {
+		return
+	}
+
+	r.m.Lock()
+	r.status.TotalFiles = total
+	r.m.Unlock()
+
+	r.phase(CheckoutWriting)
+}
+
+// written counts the file, even without a CheckoutProgress, since the totals
+// are traced too.
+func (r *checkoutReporter) written(name string, size int64) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.status.Files++
+	r.status.Bytes += size
+	r.status.Name = name
+	if r.progress != nil {
+		r.progress.UpdateCheckout(r.status)
+	}
+}
@@ -0,0 +1,74 @@
+package git
+
+import (
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type CheckoutProgressSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&CheckoutProgressSuite{})
+
+func (s *CheckoutProgressSuite) TestCheckoutProgress(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	for _, name := range []string{"foo", "bar", "qux/baz"} {
+		err := util.WriteFile(w.Filesystem, name, []byte(name), 0644)
+		c.Assert(err, IsNil)
+		_, err = w.Add(name)
+		c.Assert(err, IsNil)
+	}
+
+	first, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "bar", []byte("modified"), 0644)
+	c.Assert(err, IsNil)
+	_, err = w.Add("bar")
+	c.Assert(err, IsNil)
+	_, err = w.Remove("qux/baz")
+	c.Assert(err, IsNil)
+	_, err = w.Commit("bar\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	var status []CheckoutStatus
+	err = w.Checkout(&CheckoutOptions{
+		Hash: first,
+		Progress: CheckoutProgressFunc(func(s CheckoutStatus) {
+			status = append(status, s)
+		}),
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(status, HasLen, 6)
+	c.Assert(status[0].Phase, Equals, CheckoutComparing)
+	c.Assert(status[1].Phase, Equals, CheckoutRemoving)
+	c.Assert(status[2], DeepEquals, CheckoutStatus{Phase: CheckoutWriting, TotalFiles: 2})
+
+	c.Assert(status[3].Files, Equals, 1)
+	c.Assert(status[3].Name, Equals, "bar")
+	c.Assert(status[4].Files, Equals, 2)
+	c.Assert(status[4].Name, Equals, "qux/baz")
+
+	c.Assert(status[5], DeepEquals, CheckoutStatus{
+		Phase:      CheckoutDone,
+		Files:      2,
+		TotalFiles: 2,
+		Bytes:      int64(len("bar") + len("qux/baz")),
+		Name:       "qux/baz",
+	})
+}
+
+func (s *CheckoutProgressSuite) TestCheckoutPhaseString(c *C) {
+	c.Assert(CheckoutWriting.String(), Equals, "writing")
+	c.Assert(CheckoutPhase(42).String(), Equals, "unknown")
+}
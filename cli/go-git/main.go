@@ -8,9 +8,10 @@ import (
 )
 
 const (
-	bin            = "go-git"
-	receivePackBin = "git-receive-pack"
-	uploadPackBin  = "git-upload-pack"
+	bin              = "go-git"
+	receivePackBin   = "git-receive-pack"
+	uploadPackBin    = "git-upload-pack"
+	uploadArchiveBin = "git-upload-archive"
 )
 
 func main() {
@@ -19,11 +20,14 @@ func main() {
 		os.Args = append([]string{"git", "receive-pack"}, os.Args[1:]...)
 	case uploadPackBin:
 		os.Args = append([]string{"git", "upload-pack"}, os.Args[1:]...)
+	case uploadArchiveBin:
+		os.Args = append([]string{"git", "upload-archive"}, os.Args[1:]...)
 	}
 
 	parser := flags.NewNamedParser(bin, flags.Default)
 	parser.AddCommand("receive-pack", "", "", &CmdReceivePack{})
 	parser.AddCommand("upload-pack", "", "", &CmdUploadPack{})
+	parser.AddCommand("upload-archive", "", "", &CmdUploadArchive{})
 	parser.AddCommand("version", "Show the version information.", "", &CmdVersion{})
 
 	_, err := parser.Parse()
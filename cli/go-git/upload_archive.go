@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/file"
+)
+
+type CmdUploadArchive struct {
+	cmd
+
+	Args struct {
+		GitDir string `positional-arg-name:"git-dir" required:"true"`
+	} `positional-args:"yes"`
+}
+
+func (CmdUploadArchive) Usage() string {
+	//TODO: usage: git upload-archive <dir>
+	return fmt.Sprintf("usage: %s <git-dir>", os.Args[0])
+}
+
+func (c *CmdUploadArchive) Execute(args []string) error {
+	gitDir, err := filepath.Abs(c.Args.GitDir)
+	if err != nil {
+		return err
+	}
+
+	if err := file.ServeUploadArchive(gitDir); err != nil {
+		fmt.Fprintln(os.Stderr, "ERR:", err)
+		os.Exit(128)
+	}
+
+	return nil
+}
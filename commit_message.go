@@ -0,0 +1,197 @@
+package git
+
+import (
+	"io/ioutil"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+const (
+	defaultCommentChar = "#"
+	// autoCommentChars are the candidates of core.commentChar=auto.
+	autoCommentChars = "#;@!$%^&|:"
+	scissorsLine     = " ------------------------ >8 ------------------------"
+)
+
+// CleanupMessage cleans up the commit message as the given mode says, the
+// comment lines starting with commentChar, "#" if empty. An empty mode is
+// CleanupVerbatim.
+func CleanupMessage(msg string, mode CleanupMode, commentChar string) string {
+	if commentChar == "" {
+		commentChar = defaultCommentChar
+	}
+
+	switch mode {
+	case "", CleanupVerbatim:
+		return msg
+	case CleanupScissors:
+		msg = cutScissors(msg, commentChar)
+	}
+
+	var buf strings.Builder
+	var blank bool
+	for _, line := range strings.Split(msg, "\n") {
+		if mode == CleanupStrip && strings.HasPrefix(line, commentChar) {
+			continue
+		}
+
+		line = strings.TrimRight(line, " \t\r\v\f")
+		if line == "" {
+			blank = true
+			continue
+		}
+
+		if blank && buf.Len() != 0 {
+			buf.WriteByte('\n')
+		}
+
+		blank = false
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+
+	return buf.String()
+}
+
+func cutScissors(msg, commentChar string) string {
+	scissors := commentChar + scissorsLine
+	if strings.HasPrefix(msg, scissors+"\n") || msg == scissors {
+		return ""
+	}
+
+	if i := strings.Index(msg, "\n"+scissors+"\n"); i != -1 {
+		return msg[:i+1]
+	}
+
+	if strings.HasSuffix(msg, "\n"+scissors) {
+		return msg[:len(msg)-len(scissors)]
+	}
+
+	return msg
+}
+
+// commentChar returns the comment character of the message given by
+// core.commentChar.
+func commentChar(cfg *config.Config, msg string) string {
+	c := cfg.Core.CommentChar
+	if c == "" {
+		return defaultCommentChar
+	}
+
+	if c != "auto" {
+		return c
+	}
+
+	for _, candidate := range autoCommentChars {
+		used := false
+		for _, line := range strings.Split(msg, "\n") {
+			if strings.HasPrefix(line, string(candidate)) {
+				used = true
+				break
+			}
+		}
+
+		if !used {
+			return string(candidate)
+		}
+	}
+
+	return defaultCommentChar
+}
+
+// cleanupCommitMessage cleans up the message of the commit with the mode of
+// the options or of commit.cleanup.
+func (w *Worktree) cleanupCommitMessage(msg string, opts *CommitOptions) (string, error) {
+	cfg, err := w.r.Config()
+	if err != nil {
+		return "", err
+	}
+
+	mode := opts.Cleanup
+	if mode == "" {
+		mode = CleanupMode(cfg.Commit.Cleanup)
+		if mode != "" {
+			if err := mode.validate(); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if mode == CleanupDefault {
+		mode = CleanupWhitespace
+	}
+
+	return CleanupMessage(msg, mode, commentChar(cfg, msg)), nil
+}
+
+// addCommitTrailers adds the trailers of the options, and the Signed-off-by
+// one with Signoff, to the message.
+func addCommitTrailers(msg string, opts *CommitOptions) string {
+	trailers := opts.Trailers
+	if opts.Signoff {
+		trailers = append(trailers[:len(trailers):len(trailers)], object.Trailer{
+			Key:   "Signed-off-by",
+			Value: opts.Committer.Name + " <" + opts.Committer.Email + ">",
+		})
+	}
+
+	if len(trailers) == 0 {
+		return msg
+	}
+
+	return object.AddTrailers(msg, trailers...)
+}
+
+// CommitTemplate returns the content of the file at commit.template, to be
+// used as the initial commit message, or an empty string if it isn't set. A
+// relative path is relative to the root of the worktree.
+func (r *Repository) CommitTemplate() (string, error) {
+	cfg, err := r.userConfig()
+	if err != nil {
+		return "", err
+	}
+
+	if cfg.Commit.Template == "" {
+		return "", nil
+	}
+
+	content, err := r.readConfigPath(cfg.Commit.Template)
+	return string(content), err
+}
+
+// userConfig returns the config of the repository merged with the ones of the
+// user and the system, if the repository is stored at the OS filesystem.
+func (r *Repository) userConfig() (*config.Config, error) {
+	if isOSStorage(r) {
+		return r.ConfigScoped(config.SystemScope)
+	}
+
+	return r.Config()
+}
+
+// readConfigPath reads the file at a path given by the config, expanding ~/ to
+// the home of the user, a relative path being relative to the root of the
+// worktree.
+func (r *Repository) readConfigPath(path string) ([]byte, error) {
+	if strings.HasPrefix(path, "~/") {
+		usr, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+
+		path = filepath.Join(usr.HomeDir, path[2:])
+	}
+
+	switch {
+	case filepath.IsAbs(path):
+		return ioutil.ReadFile(path)
+	case r.wt != nil:
+		return readFile(r.wt, path)
+	default:
+		return nil, ErrIsBareRepository
+	}
+}
@@ -0,0 +1,126 @@
+package git
+
+import (
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type CommitMessageSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&CommitMessageSuite{})
+
+func (s *CommitMessageSuite) TestCleanupMessage(c *C) {
+	msg := "\n\nfoo  \n\n\n# comment\nbar\t\n\n" +
+		"# ------------------------ >8 ------------------------\ndiff\n\n"
+
+	c.Assert(CleanupMessage(msg, CleanupVerbatim, ""), Equals, msg)
+	c.Assert(CleanupMessage(msg, "", ""), Equals, msg)
+	c.Assert(CleanupMessage(msg, CleanupWhitespace, ""), Equals,
+		"foo\n\n# comment\nbar\n\n# ------------------------ >8 ------------------------\ndiff\n")
+	c.Assert(CleanupMessage(msg, CleanupStrip, ""), Equals, "foo\n\nbar\n\ndiff\n")
+	c.Assert(CleanupMessage(msg, CleanupStrip, ";"), Equals,
+		"foo\n\n# comment\nbar\n\n# ------------------------ >8 ------------------------\ndiff\n")
+	c.Assert(CleanupMessage(msg, CleanupScissors, ""), Equals, "foo\n\n# comment\nbar\n")
+	c.Assert(CleanupMessage("\n \n", CleanupWhitespace, ""), Equals, "")
+}
+
+func (s *CommitMessageSuite) TestCommentChar(c *C) {
+	cfg := config.NewConfig()
+	c.Assert(commentChar(cfg, "foo"), Equals, "#")
+
+	cfg.Core.CommentChar = ";"
+	c.Assert(commentChar(cfg, "foo"), Equals, ";")
+
+	cfg.Core.CommentChar = "auto"
+	c.Assert(commentChar(cfg, "#1 foo\n;bar\n"), Equals, "@")
+}
+
+func (s *CommitMessageSuite) TestCommitCleanup(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	msg := "foo  \n\n# comment\n"
+	h, err := w.Commit(msg, &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+	s.assertMessage(c, r, h, msg)
+
+	h, err = w.Commit(msg, &CommitOptions{Author: defaultSignature(), Cleanup: CleanupDefault})
+	c.Assert(err, IsNil)
+	s.assertMessage(c, r, h, "foo\n\n# comment\n")
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Commit.Cleanup = "strip"
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	h, err = w.Commit(msg, &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+	s.assertMessage(c, r, h, "foo\n")
+
+	_, err = w.Commit(msg, &CommitOptions{Author: defaultSignature(), Cleanup: "foo"})
+	c.Assert(err, Equals, ErrInvalidCleanupMode)
+
+	cfg.Commit.Cleanup = "foo"
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	_, err = w.Commit(msg, &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, Equals, ErrInvalidCleanupMode)
+}
+
+func (s *CommitMessageSuite) TestCommitTrailers(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	h, err := w.Commit("foo\n", &CommitOptions{
+		Author:   defaultSignature(),
+		Signoff:  true,
+		Trailers: []object.Trailer{{Key: "Co-authored-by", Value: "bar <bar@bar.bar>"}},
+	})
+	c.Assert(err, IsNil)
+	s.assertMessage(c, r, h, "foo\n\nCo-authored-by: bar <bar@bar.bar>\nSigned-off-by: foo <foo@foo.foo>\n")
+
+	commit, err := r.CommitObject(h)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Trailers(), HasLen, 2)
+}
+
+func (s *CommitMessageSuite) TestCommitTemplate(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	template, err := r.CommitTemplate()
+	c.Assert(err, IsNil)
+	c.Assert(template, Equals, "")
+
+	c.Assert(util.WriteFile(fs, ".gitmessage", []byte("\n# subject\n"), 0644), IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Commit.Template = ".gitmessage"
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	template, err = r.CommitTemplate()
+	c.Assert(err, IsNil)
+	c.Assert(template, Equals, "\n# subject\n")
+}
+
+func (s *CommitMessageSuite) assertMessage(c *C, r *Repository, h plumbing.Hash, msg string) {
+	commit, err := r.CommitObject(h)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, msg)
+}
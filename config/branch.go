@@ -21,6 +21,12 @@ type Branch struct {
 	Remote string
 	// Merge is the local refspec for the branch
 	Merge plumbing.ReferenceName
+	// Description is the description of the branch, as set by
+	// `git branch --edit-description`.
+	Description string
+	// Rebase controls if the pulls into the branch rebase it instead of
+	// merging, overriding pull.rebase: "true", "merges" or "false".
+	Rebase string
 
 	raw *format.Subsection
 }
@@ -57,6 +63,18 @@ func (b *Branch) marshal() *format.Subsection {
 		b.raw.SetOption(mergeKey, string(b.Merge))
 	}
 
+	if b.Description == "" {
+		b.raw.RemoveOption(descriptionKey)
+	} else {
+		b.raw.SetOption(descriptionKey, b.Description)
+	}
+
+	if b.Rebase == "" {
+		b.raw.RemoveOption(rebaseKey)
+	} else {
+		b.raw.SetOption(rebaseKey, b.Rebase)
+	}
+
 	return b.raw
 }
 
@@ -66,6 +84,8 @@ func (b *Branch) unmarshal(s *format.Subsection) error {
 	b.Name = b.raw.Name
 	b.Remote = b.raw.Options.Get(remoteSection)
 	b.Merge = plumbing.ReferenceName(b.raw.Options.Get(mergeKey))
+	b.Description = b.raw.Options.Get(descriptionKey)
+	b.Rebase = b.raw.Options.Get(rebaseKey)
 
 	return b.Validate()
 }
@@ -74,3 +74,46 @@ func (b *BranchSuite) TestUnmarshall(c *C) {
 	c.Assert(branch.Remote, Equals, "fork")
 	c.Assert(branch.Merge, Equals, plumbing.ReferenceName("refs/heads/branch-tracking-on-clone"))
 }
+
+func (b *BranchSuite) TestDescription(c *C) {
+	input := []byte(`[core]
+	bare = false
+[branch "feature"]
+	description = "foo\nbar\n"
+`)
+
+	cfg := NewConfig()
+	err := cfg.Unmarshal(input)
+	c.Assert(err, IsNil)
+	branch := cfg.Branches["feature"]
+	c.Assert(branch.Description, Equals, "foo\nbar\n")
+
+	output, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, string(input))
+
+	branch.Description = ""
+	output, err = cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "[core]\n\tbare = false\n[branch \"feature\"]\n")
+}
+
+func (b *BranchSuite) TestRebase(c *C) {
+	input := []byte(`[core]
+	bare = false
+[branch "feature"]
+	rebase = merges
+[pull]
+	rebase = true
+`)
+
+	cfg := NewConfig()
+	err := cfg.Unmarshal(input)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Branches["feature"].Rebase, Equals, "merges")
+	c.Assert(cfg.Pull.Rebase, Equals, "true")
+
+	output, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, string(input))
+}
@@ -16,6 +16,8 @@ const (
 	DefaultFetchRefSpec = "+refs/heads/*:refs/remotes/%s/*"
 	// DefaultPushRefSpec is the default refspec used for push.
 	DefaultPushRefSpec = "refs/heads/*:refs/heads/*"
+	// MirrorRefSpec is the refspec used to mirror all the references.
+	MirrorRefSpec = "+refs/*:refs/*"
 )
 
 // ConfigStorer generic storage of Config object
@@ -24,6 +26,18 @@ type ConfigStorer interface {
 	SetConfig(*Config) error
 }
 
+// WorktreeConfigStorer is a ConfigStorer storing the config of the worktree
+// apart, at config.worktree, when extensions.worktreeConfig is enabled.
+type WorktreeConfigStorer interface {
+	ConfigStorer
+	// WorktreeConfig returns the config, as Config does, but storing its
+	// options at the config of the worktree. Without the extension, it's
+	// the same as Config.
+	WorktreeConfig() (*Config, error)
+	// SetWorktreeConfig stores a config returned by WorktreeConfig.
+	SetWorktreeConfig(*Config) error
+}
+
 var (
 	ErrInvalid               = errors.New("config invalid key in remote or branch")
 	ErrRemoteConfigNotFound  = errors.New("remote config not found")
@@ -40,6 +54,53 @@ type Config struct {
 		IsBare bool
 		// Worktree is the path to the root of the working tree.
 		Worktree string
+		// HooksPath is the path to the directory where the hooks are looked
+		// for, by default `hooks` at the git directory is used.
+		HooksPath string
+		// UntrackedCache controls the 'Untracked cache' index extension used
+		// by the worktree status, "true" enables it, "false" removes it and
+		// empty or "keep" maintains it only if the index already has one.
+		UntrackedCache string
+		// FSMonitor is the path to the program reporting the files changed
+		// at the worktree, following the protocol of the fsmonitor hooks.
+		FSMonitor string
+		// ExcludesFile is the path to a file with patterns of the files to
+		// ignore at the worktree, in addition to the .gitignore files.
+		ExcludesFile string
+		// Symlinks controls whether the symbolic links are checked out as
+		// links, "false" checks them out as plain files with the link target
+		// as content. If empty, the support of the worktree filesystem is
+		// probed on the first checkout of a link, and "false" is set if the
+		// filesystem doesn't support them.
+		Symlinks string
+		// CommentChar is the character starting the comment lines of the
+		// commit messages, removed by their cleanup, "#" if empty. With
+		// "auto", the first of "#;@!$%^&|:" not starting any line is used.
+		CommentChar string
+		// BigFileThreshold is the size, in bytes, above which the files are
+		// streamed from the storage instead of being held in memory, stored
+		// without delta compression and reported as binary by the diffs.
+		// DefaultBigFileThreshold by default, or if zero.
+		BigFileThreshold int64
+	}
+
+	Commit struct {
+		// Template is the path to the file whose content is the initial
+		// commit message.
+		Template string
+		// Cleanup is the default cleanup of the commit messages: "strip",
+		// "whitespace", "verbatim", "scissors" or "default".
+		Cleanup string
+	}
+
+	Mailmap struct {
+		// File is the path to a mailmap file read after the .mailmap of the
+		// worktree and Blob, overriding their mappings.
+		File string
+		// Blob is the blob of a mailmap file, as a revision and a path, such
+		// as HEAD:.mailmap, read after the .mailmap of the worktree. It is
+		// HEAD:.mailmap by default in the bare repositories.
+		Blob string
 	}
 
 	Pack struct {
@@ -49,6 +110,92 @@ type Config struct {
 		Window uint
 	}
 
+	GC struct {
+		// Auto is the number of loose objects above which the repository
+		// is considered to need a gc, 6700 by default. A value of 0 turns
+		// it off.
+		Auto int
+		// AutoPackLimit is the number of packs above which the repository
+		// is considered to need a gc, 50 by default. A value of 0 turns it
+		// off.
+		AutoPackLimit int
+		// AutoDetach runs the automatic gc in the background, true by
+		// default.
+		AutoDetach bool
+	}
+
+	Fetch struct {
+		// Prune deletes, on every fetch, the remote-tracking references no
+		// longer existing at the remote, unless remote.<name>.prune is set.
+		Prune bool
+		// PruneTags deletes, on every fetch, the local tags no longer
+		// existing at the remote, unless remote.<name>.pruneTags is set.
+		PruneTags bool
+		// WriteCommitGraph adds, after every fetch and clone, the fetched
+		// commits to the commit-graph.
+		WriteCommitGraph bool
+	}
+
+	Branch struct {
+		// AutoSetupMerge controls the upstream set to the branches created
+		// from a start point: "true" or empty sets it when the start point
+		// is a remote-tracking branch, "always" also when it is a local
+		// branch and "false" never sets it.
+		AutoSetupMerge string
+	}
+
+	Pull struct {
+		// Rebase controls if the pulls rebase the current branch onto the
+		// pulled commit instead of merging it, unless branch.<name>.rebase
+		// is set: "true" rebases, "merges" rebases keeping the local merge
+		// commits and "false" or empty merges.
+		Rebase string
+	}
+
+	Rebase struct {
+		// AutoStash stashes the local changes before the rebases, reapplying
+		// them once completed.
+		AutoStash bool
+	}
+
+	Merge struct {
+		// AutoStash stashes the local changes before the merges, reapplying
+		// them once completed.
+		AutoStash bool
+	}
+
+	Init struct {
+		// DefaultBranch is the name of the branch HEAD points to in the
+		// repositories created, master by default.
+		DefaultBranch string
+		// TemplateDir is the directory whose files are copied into the git
+		// directory of the repositories created.
+		TemplateDir string
+	}
+
+	Submodule struct {
+		// FetchJobs is the number of submodules fetched and updated in
+		// parallel, they are updated one at a time if 0.
+		FetchJobs int
+	}
+
+	Blame struct {
+		// IgnoreRevsFiles are the paths, relative to the worktree, of the
+		// files listing the commits ignored by the blames, one full hash
+		// per line.
+		IgnoreRevsFiles []string
+	}
+
+	Extensions struct {
+		// WorktreeConfig enables the config of the worktree, config.worktree
+		// at the git directory, overriding the options of the repository
+		// config.
+		WorktreeConfig bool
+		// PreciousObjects forbids deleting any object of the repository,
+		// as its objects are shared with other repositories.
+		PreciousObjects bool
+	}
+
 	// Remotes list of repository remotes, the key of the map is the name
 	// of the remote, should equal to RemoteConfig.Name.
 	Remotes map[string]*RemoteConfig
@@ -58,10 +205,30 @@ type Config struct {
 	// Branches list of branches, the key is the branch name and should
 	// equal Branch.Name
 	Branches map[string]*Branch
+	// URLs list of url rewrite rules, the key is the base url and should
+	// equal URL.Name
+	URLs map[string]*URL
+	// HTTP the settings of the requests sent to every HTTP remote.
+	HTTP HTTP
+	// HTTPURLs list of HTTP settings for specific urls, the key is the url
+	// and should equal HTTP.URL
+	HTTPURLs map[string]*HTTP
 	// Raw contains the raw information of a config file. The main goal is
 	// preserve the parsed information from the original format, to avoid
 	// dropping unsupported fields.
 	Raw *format.Config
+
+	// external are the options, sections and subsections of Raw not read
+	// from the file the config is stored at, such as the included ones,
+	// Marshal skips them unless they are changed.
+	external map[interface{}]bool
+	// origins are the files the options of Raw are read from.
+	origins map[*format.Option]Origin
+	// scope is the scope of the config, for the options without origin.
+	scope Scope
+	// layout is the layout of the file the config is read from, preserved
+	// by Marshal.
+	layout *format.Layout
 }
 
 // NewConfig returns a new empty Config.
@@ -70,10 +237,16 @@ func NewConfig() *Config {
 		Remotes:    make(map[string]*RemoteConfig),
 		Submodules: make(map[string]*Submodule),
 		Branches:   make(map[string]*Branch),
+		URLs:       make(map[string]*URL),
+		HTTPURLs:   make(map[string]*HTTP),
 		Raw:        format.New(),
 	}
 
+	config.Core.BigFileThreshold = DefaultBigFileThreshold
 	config.Pack.Window = DefaultPackWindow
+	config.GC.Auto = DefaultGCAuto
+	config.GC.AutoPackLimit = DefaultGCAutoPackLimit
+	config.GC.AutoDetach = true
 
 	return config
 }
@@ -100,57 +273,234 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	for name, u := range c.URLs {
+		if u.Name != name {
+			return ErrInvalid
+		}
+
+		if err := u.Validate(); err != nil {
+			return err
+		}
+	}
+
+	for url, h := range c.HTTPURLs {
+		if h.URL != url {
+			return ErrInvalid
+		}
+	}
+
 	return nil
 }
 
 const (
-	remoteSection    = "remote"
-	submoduleSection = "submodule"
-	branchSection    = "branch"
-	coreSection      = "core"
-	packSection      = "pack"
-	fetchKey         = "fetch"
-	urlKey           = "url"
-	bareKey          = "bare"
-	worktreeKey      = "worktree"
-	windowKey        = "window"
-	mergeKey         = "merge"
+	remoteSection       = "remote"
+	submoduleSection    = "submodule"
+	branchSection       = "branch"
+	urlSection          = "url"
+	httpSection         = "http"
+	coreSection         = "core"
+	packSection         = "pack"
+	gcSection           = "gc"
+	fetchSection        = "fetch"
+	extensionsSection   = "extensions"
+	initSection         = "init"
+	blameSection        = "blame"
+	pullSection         = "pull"
+	rebaseSection       = "rebase"
+	mergeSection        = "merge"
+	commitSection       = "commit"
+	mailmapSection      = "mailmap"
+	fetchKey            = "fetch"
+	urlKey              = "url"
+	pushurlKey          = "pushurl"
+	bareKey             = "bare"
+	worktreeKey         = "worktree"
+	hooksPathKey        = "hooksPath"
+	untrackedCacheKey   = "untrackedCache"
+	fsMonitorKey        = "fsmonitor"
+	excludesFileKey     = "excludesFile"
+	symlinksKey         = "symlinks"
+	windowKey           = "window"
+	autoKey             = "auto"
+	autoPackLimitKey    = "autoPackLimit"
+	autoDetachKey       = "autoDetach"
+	mergeKey            = "merge"
+	autoSetupMergeKey   = "autoSetupMerge"
+	descriptionKey      = "description"
+	pruneKey            = "prune"
+	pruneTagsKey        = "pruneTags"
+	mirrorKey           = "mirror"
+	insteadOfKey        = "insteadOf"
+	pushInsteadOfKey    = "pushInsteadOf"
+	extraHeaderKey      = "extraHeader"
+	sslVerifyKey        = "sslVerify"
+	sslCertKey          = "sslCert"
+	sslKeyKey           = "sslKey"
+	sslCAInfoKey        = "sslCAInfo"
+	sslVersionKey       = "sslVersion"
+	userAgentKey        = "userAgent"
+	followRedirectsKey  = "followRedirects"
+	lowSpeedLimitKey    = "lowSpeedLimit"
+	lowSpeedTimeKey     = "lowSpeedTime"
+	worktreeConfigKey   = "worktreeConfig"
+	defaultBranchKey    = "defaultBranch"
+	templateDirKey      = "templateDir"
+	ignoreRevsFileKey   = "ignoreRevsFile"
+	fetchJobsKey        = "fetchJobs"
+	rebaseKey           = "rebase"
+	autoStashKey        = "autoStash"
+	commentCharKey      = "commentChar"
+	templateKey         = "template"
+	cleanupKey          = "cleanup"
+	fileKey             = "file"
+	blobKey             = "blob"
+	writeCommitGraphKey = "writeCommitGraph"
+	bigFileThresholdKey = "bigFileThreshold"
 
 	// DefaultPackWindow holds the number of previous objects used to
 	// generate deltas. The value 10 is the same used by git command.
 	DefaultPackWindow = uint(10)
+	// DefaultBigFileThreshold holds the size, in bytes, above which the files
+	// are considered big. The value 512MiB is the same used by git command.
+	DefaultBigFileThreshold = int64(512 << 20)
+	// DefaultGCAuto holds the number of loose objects above which a gc is
+	// needed. The value 6700 is the same used by git command.
+	DefaultGCAuto = 6700
+	// DefaultGCAutoPackLimit holds the number of packs above which a gc is
+	// needed. The value 50 is the same used by git command.
+	DefaultGCAutoPackLimit = 50
 )
 
 // Unmarshal parses a git-config file and stores it.
 func (c *Config) Unmarshal(b []byte) error {
 	r := bytes.NewBuffer(b)
 	d := format.NewDecoder(r)
+	d.Layout = &format.Layout{}
 
 	c.Raw = format.New()
 	if err := d.Decode(c.Raw); err != nil {
 		return err
 	}
 
-	c.unmarshalCore()
+	c.layout = d.Layout
+
+	c.external = nil
+	c.origins = nil
+	return c.unmarshal()
+}
+
+func (c *Config) unmarshal() error {
+	if err := c.unmarshalCore(); err != nil {
+		return err
+	}
+
+	c.unmarshalFetch()
+	c.unmarshalExtensions()
+	c.Init.DefaultBranch = c.Raw.Section(initSection).Options.Get(defaultBranchKey)
+	c.Init.TemplateDir = c.Raw.Section(initSection).Options.Get(templateDirKey)
+	c.Blame.IgnoreRevsFiles = append([]string(nil), c.Raw.Section(blameSection).Options.GetAll(ignoreRevsFileKey)...)
 	if err := c.unmarshalPack(); err != nil {
 		return err
 	}
+
+	if err := c.unmarshalGC(); err != nil {
+		return err
+	}
 	unmarshalSubmodules(c.Raw, c.Submodules)
+	if err := c.unmarshalSubmodule(); err != nil {
+		return err
+	}
 
+	c.Branch.AutoSetupMerge = c.Raw.Section(branchSection).Options.Get(autoSetupMergeKey)
+	c.Pull.Rebase = c.Raw.Section(pullSection).Options.Get(rebaseKey)
+	c.Rebase.AutoStash = c.Raw.Section(rebaseSection).Options.Get(autoStashKey) == "true"
+	c.Merge.AutoStash = c.Raw.Section(mergeSection).Options.Get(autoStashKey) == "true"
+	c.Commit.Template = c.Raw.Section(commitSection).Options.Get(templateKey)
+	c.Commit.Cleanup = c.Raw.Section(commitSection).Options.Get(cleanupKey)
+	c.Mailmap.File = c.Raw.Section(mailmapSection).Options.Get(fileKey)
+	c.Mailmap.Blob = c.Raw.Section(mailmapSection).Options.Get(blobKey)
 	if err := c.unmarshalBranches(); err != nil {
 		return err
 	}
 
+	if err := c.unmarshalURLs(); err != nil {
+		return err
+	}
+
+	if err := c.unmarshalHTTP(); err != nil {
+		return err
+	}
+
 	return c.unmarshalRemotes()
 }
 
-func (c *Config) unmarshalCore() {
+func (c *Config) unmarshalCore() error {
 	s := c.Raw.Section(coreSection)
 	if s.Options.Get(bareKey) == "true" {
 		c.Core.IsBare = true
 	}
 
 	c.Core.Worktree = s.Options.Get(worktreeKey)
+	c.Core.HooksPath = s.Options.Get(hooksPathKey)
+	c.Core.UntrackedCache = s.Options.Get(untrackedCacheKey)
+	c.Core.FSMonitor = s.Options.Get(fsMonitorKey)
+	c.Core.ExcludesFile = s.Options.Get(excludesFileKey)
+	c.Core.Symlinks = s.Options.Get(symlinksKey)
+	c.Core.CommentChar = s.Options.Get(commentCharKey)
+
+	c.Core.BigFileThreshold = DefaultBigFileThreshold
+	if threshold := s.Options.Get(bigFileThresholdKey); threshold != "" {
+		v, err := parseSize(threshold)
+		if err != nil {
+			return err
+		}
+
+		c.Core.BigFileThreshold = v
+	}
+
+	return nil
+}
+
+// parseSize parses a size of git config, in bytes, optionally suffixed by k,
+// m or g, scaling it by 1024, 1024^2 or 1024^3.
+func parseSize(value string) (int64, error) {
+	if value == "" {
+		return 0, strconv.ErrSyntax
+	}
+
+	unit := int64(1)
+	switch value[len(value)-1] {
+	case 'k', 'K':
+		unit = 1 << 10
+	case 'm', 'M':
+		unit = 1 << 20
+	case 'g', 'G':
+		unit = 1 << 30
+	}
+
+	if unit != 1 {
+		value = value[:len(value)-1]
+	}
+
+	v, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return v * unit, nil
+}
+
+func (c *Config) unmarshalFetch() {
+	s := c.Raw.Section(fetchSection)
+	c.Fetch.Prune = s.Options.Get(pruneKey) == "true"
+	c.Fetch.PruneTags = s.Options.Get(pruneTagsKey) == "true"
+	c.Fetch.WriteCommitGraph = s.Options.Get(writeCommitGraphKey) == "true"
+}
+
+func (c *Config) unmarshalExtensions() {
+	s := c.Raw.Section(extensionsSection)
+	c.Extensions.WorktreeConfig = s.Options.Get(worktreeConfigKey) == "true"
+	c.Extensions.PreciousObjects = s.Options.Get(preciousObjectsKey) == "true"
 }
 
 func (c *Config) unmarshalPack() error {
@@ -168,6 +518,29 @@ func (c *Config) unmarshalPack() error {
 	return nil
 }
 
+func (c *Config) unmarshalGC() error {
+	s := c.Raw.Section(gcSection)
+	c.GC.Auto, c.GC.AutoPackLimit = DefaultGCAuto, DefaultGCAutoPackLimit
+	if auto := s.Options.Get(autoKey); auto != "" {
+		v, err := strconv.Atoi(auto)
+		if err != nil {
+			return err
+		}
+		c.GC.Auto = v
+	}
+
+	if limit := s.Options.Get(autoPackLimitKey); limit != "" {
+		v, err := strconv.Atoi(limit)
+		if err != nil {
+			return err
+		}
+		c.GC.AutoPackLimit = v
+	}
+
+	c.GC.AutoDetach = s.Options.Get(autoDetachKey) != "false"
+	return nil
+}
+
 func (c *Config) unmarshalRemotes() error {
 	s := c.Raw.Section(remoteSection)
 	for _, sub := range s.Subsections {
@@ -182,6 +555,22 @@ func (c *Config) unmarshalRemotes() error {
 	return nil
 }
 
+func (c *Config) unmarshalSubmodule() error {
+	c.Submodule.FetchJobs = 0
+	jobs := c.Raw.Section(submoduleSection).Options.Get(fetchJobsKey)
+	if jobs == "" {
+		return nil
+	}
+
+	n, err := strconv.Atoi(jobs)
+	if err != nil {
+		return err
+	}
+
+	c.Submodule.FetchJobs = n
+	return nil
+}
+
 func unmarshalSubmodules(fc *format.Config, submodules map[string]*Submodule) {
 	s := fc.Section(submoduleSection)
 	for _, sub := range s.Subsections {
@@ -210,16 +599,65 @@ func (c *Config) unmarshalBranches() error {
 	return nil
 }
 
+func (c *Config) unmarshalURLs() error {
+	s := c.Raw.Section(urlSection)
+	for _, sub := range s.Subsections {
+		u := &URL{}
+
+		if err := u.unmarshal(sub); err != nil {
+			return err
+		}
+
+		c.URLs[u.Name] = u
+	}
+	return nil
+}
+
+func (c *Config) unmarshalHTTP() error {
+	s := c.Raw.Section(httpSection)
+	if err := c.HTTP.unmarshal(&format.Subsection{Options: s.Options}); err != nil {
+		return err
+	}
+
+	for _, sub := range s.Subsections {
+		h := &HTTP{}
+
+		if err := h.unmarshal(sub); err != nil {
+			return err
+		}
+
+		c.HTTPURLs[h.URL] = h
+	}
+	return nil
+}
+
 // Marshal returns Config encoded as a git-config file.
 func (c *Config) Marshal() ([]byte, error) {
 	c.marshalCore()
+	c.marshalFetch()
+	c.marshalExtensions()
+	c.marshalInit()
+	c.marshalBlame()
 	c.marshalPack()
+	c.marshalGC()
 	c.marshalRemotes()
 	c.marshalSubmodules()
 	c.marshalBranches()
+	c.marshalPull()
+	c.marshalAutoStash()
+	c.marshalCommit()
+	c.marshalMailmap()
+	c.marshalURLs()
+	c.marshalHTTP()
+
+	raw := c.Raw
+	if len(c.external) > 0 {
+		c.markImpliedFetch()
+		raw = withoutExternal(raw, c.external)
+	}
 
 	buf := bytes.NewBuffer(nil)
-	if err := format.NewEncoder(buf).Encode(c.Raw); err != nil {
+	if err := format.NewEncoder(buf).EncodeLayout(raw, c.layout); err != nil {
 		return nil, err
 	}
 
@@ -233,6 +671,118 @@ func (c *Config) marshalCore() {
 	if c.Core.Worktree != "" {
 		s.SetOption(worktreeKey, c.Core.Worktree)
 	}
+
+	if c.Core.HooksPath != "" {
+		s.SetOption(hooksPathKey, c.Core.HooksPath)
+	}
+
+	if c.Core.UntrackedCache != "" {
+		s.SetOption(untrackedCacheKey, c.Core.UntrackedCache)
+	}
+
+	if c.Core.FSMonitor != "" {
+		s.SetOption(fsMonitorKey, c.Core.FSMonitor)
+	}
+
+	if c.Core.ExcludesFile != "" {
+		s.SetOption(excludesFileKey, c.Core.ExcludesFile)
+	}
+
+	if c.Core.Symlinks != "" {
+		s.SetOption(symlinksKey, c.Core.Symlinks)
+	}
+
+	if c.Core.CommentChar != "" {
+		s.SetOption(commentCharKey, c.Core.CommentChar)
+	}
+
+	// the size is kept as written, with its unit, if unchanged
+	t := c.Core.BigFileThreshold
+	current, err := parseSize(s.Options.Get(bigFileThresholdKey))
+	switch {
+	case err == nil && (current == t || t == 0 && current == DefaultBigFileThreshold):
+	case t == 0 || t == DefaultBigFileThreshold:
+		s.RemoveOption(bigFileThresholdKey)
+	default:
+		s.SetOption(bigFileThresholdKey, strconv.FormatInt(t, 10))
+	}
+}
+
+func (c *Config) marshalFetch() {
+	s := c.Raw.Section(fetchSection)
+	if c.Fetch.Prune {
+		s.SetOption(pruneKey, "true")
+	} else {
+		s.RemoveOption(pruneKey)
+	}
+
+	if c.Fetch.PruneTags {
+		s.SetOption(pruneTagsKey, "true")
+	} else {
+		s.RemoveOption(pruneTagsKey)
+	}
+
+	if c.Fetch.WriteCommitGraph {
+		s.SetOption(writeCommitGraphKey, "true")
+	} else {
+		s.RemoveOption(writeCommitGraphKey)
+	}
+}
+
+func (c *Config) marshalExtensions() {
+	s := c.Raw.Section(extensionsSection)
+	if c.Extensions.WorktreeConfig {
+		s.SetOption(worktreeConfigKey, "true")
+	} else {
+		s.RemoveOption(worktreeConfigKey)
+	}
+
+	if c.Extensions.PreciousObjects {
+		s.SetOption(preciousObjectsKey, "true")
+	} else {
+		s.RemoveOption(preciousObjectsKey)
+	}
+}
+
+func (c *Config) marshalInit() {
+	s := c.Raw.Section(initSection)
+	if c.Init.DefaultBranch != "" {
+		s.SetOption(defaultBranchKey, c.Init.DefaultBranch)
+	}
+
+	if c.Init.TemplateDir != "" {
+		s.SetOption(templateDirKey, c.Init.TemplateDir)
+	}
+}
+
+func (c *Config) marshalCommit() {
+	s := c.Raw.Section(commitSection)
+	if c.Commit.Template != "" {
+		s.SetOption(templateKey, c.Commit.Template)
+	}
+
+	if c.Commit.Cleanup != "" {
+		s.SetOption(cleanupKey, c.Commit.Cleanup)
+	}
+}
+
+func (c *Config) marshalMailmap() {
+	s := c.Raw.Section(mailmapSection)
+	if c.Mailmap.File != "" {
+		s.SetOption(fileKey, c.Mailmap.File)
+	}
+
+	if c.Mailmap.Blob != "" {
+		s.SetOption(blobKey, c.Mailmap.Blob)
+	}
+}
+
+func (c *Config) marshalBlame() {
+	s := c.Raw.Section(blameSection)
+	s.RemoveOption(ignoreRevsFileKey)
+	for _, f := range c.Blame.IgnoreRevsFiles {
+		s.AddOption(ignoreRevsFileKey, f)
+	}
 }
 
 func (c *Config) marshalPack() {
@@ -242,6 +792,27 @@ func (c *Config) marshalPack() {
 	}
 }
 
+func (c *Config) marshalGC() {
+	s := c.Raw.Section(gcSection)
+	if c.GC.Auto != DefaultGCAuto {
+		s.SetOption(autoKey, strconv.Itoa(c.GC.Auto))
+	} else {
+		s.RemoveOption(autoKey)
+	}
+
+	if c.GC.AutoPackLimit != DefaultGCAutoPackLimit {
+		s.SetOption(autoPackLimitKey, strconv.Itoa(c.GC.AutoPackLimit))
+	} else {
+		s.RemoveOption(autoPackLimitKey)
+	}
+
+	if c.GC.AutoDetach {
+		s.RemoveOption(autoDetachKey)
+	} else {
+		s.SetOption(autoDetachKey, "false")
+	}
+}
+
 func (c *Config) marshalRemotes() {
 	s := c.Raw.Section(remoteSection)
 	newSubsections := make(format.Subsections, 0, len(c.Remotes))
@@ -282,10 +853,20 @@ func (c *Config) marshalSubmodules() {
 		s.Subsections[i] = section
 		i++
 	}
+
+	if c.Submodule.FetchJobs != 0 {
+		s.SetOption(fetchJobsKey, strconv.Itoa(c.Submodule.FetchJobs))
+	} else {
+		s.RemoveOption(fetchJobsKey)
+	}
 }
 
 func (c *Config) marshalBranches() {
 	s := c.Raw.Section(branchSection)
+	if c.Branch.AutoSetupMerge != "" {
+		s.SetOption(autoSetupMergeKey, c.Branch.AutoSetupMerge)
+	}
+
 	newSubsections := make(format.Subsections, 0, len(c.Branches))
 	added := make(map[string]bool)
 	for _, subsection := range s.Subsections {
@@ -311,6 +892,92 @@ func (c *Config) marshalBranches() {
 	s.Subsections = newSubsections
 }
 
+func (c *Config) marshalPull() {
+	if c.Pull.Rebase != "" {
+		c.Raw.Section(pullSection).SetOption(rebaseKey, c.Pull.Rebase)
+	}
+}
+
+func (c *Config) marshalAutoStash() {
+	c.setAutoStash(rebaseSection, c.Rebase.AutoStash)
+	c.setAutoStash(mergeSection, c.Merge.AutoStash)
+}
+
+// setAutoStash sets the autoStash option of the section, removing the section
+// if it's left empty.
+func (c *Config) setAutoStash(section string, set bool) {
+	if set {
+		c.Raw.Section(section).SetOption(autoStashKey, "true")
+		return
+	}
+
+	s := c.Raw.Section(section)
+	s.RemoveOption(autoStashKey)
+	if len(s.Options) == 0 && len(s.Subsections) == 0 {
+		c.Raw.RemoveSection(section)
+	}
+}
+
+func (c *Config) marshalURLs() {
+	s := c.Raw.Section(urlSection)
+	newSubsections := make(format.Subsections, 0, len(c.URLs))
+	added := make(map[string]bool)
+	for _, subsection := range s.Subsections {
+		if u, ok := c.URLs[subsection.Name]; ok {
+			newSubsections = append(newSubsections, u.marshal())
+			added[subsection.Name] = true
+		}
+	}
+
+	names := make([]string, 0, len(c.URLs))
+	for name := range c.URLs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !added[name] {
+			newSubsections = append(newSubsections, c.URLs[name].marshal())
+		}
+	}
+
+	s.Subsections = newSubsections
+}
+
+func (c *Config) marshalHTTP() {
+	s := c.Raw.Section(httpSection)
+	if c.HTTP.raw == nil {
+		c.HTTP.raw = &format.Subsection{Options: s.Options}
+	}
+
+	s.Options = c.HTTP.marshal().Options
+
+	newSubsections := make(format.Subsections, 0, len(c.HTTPURLs))
+	added := make(map[string]bool)
+	for _, subsection := range s.Subsections {
+		if h, ok := c.HTTPURLs[subsection.Name]; ok {
+			newSubsections = append(newSubsections, h.marshal())
+			added[subsection.Name] = true
+		}
+	}
+
+	urls := make([]string, 0, len(c.HTTPURLs))
+	for url := range c.HTTPURLs {
+		urls = append(urls, url)
+	}
+
+	sort.Strings(urls)
+
+	for _, url := range urls {
+		if !added[url] {
+			newSubsections = append(newSubsections, c.HTTPURLs[url].marshal())
+		}
+	}
+
+	s.Subsections = newSubsections
+}
+
 // RemoteConfig contains the configuration for a given remote repository.
 type RemoteConfig struct {
 	// Name of the remote
@@ -318,8 +985,24 @@ type RemoteConfig struct {
 	// URLs the URLs of a remote repository. It must be non-empty. Fetch will
 	// always use the first URL, while push will use all of them.
 	URLs []string
+	// PushURLs, if not empty, are the URLs used by push instead of URLs.
+	PushURLs []string
 	// Fetch the default set of "refspec" for fetch operation
 	Fetch []RefSpec
+	// Prune is "true" or "false" to delete, or not, on every fetch the
+	// remote-tracking references no longer existing at the remote, empty
+	// to follow fetch.prune.
+	Prune string
+	// PruneTags is "true" or "false" to delete, or not, on every fetch the
+	// local tags no longer existing at the remote, empty to follow
+	// fetch.pruneTags.
+	PruneTags string
+	// Mirror, if true, makes every push to the remote mirror the local
+	// references, as set up by a mirror clone.
+	Mirror bool
+	// Agent, if not empty, replaces the transfer.agent of the repository
+	// as the agent the clients identify with to the remote.
+	Agent string
 
 	// raw representation of the subsection, filled by marshal or unmarshal are
 	// called
@@ -364,7 +1047,12 @@ func (c *RemoteConfig) unmarshal(s *format.Subsection) error {
 
 	c.Name = c.raw.Name
 	c.URLs = append([]string(nil), c.raw.Options.GetAll(urlKey)...)
+	c.PushURLs = append([]string(nil), c.raw.Options.GetAll(pushurlKey)...)
 	c.Fetch = fetch
+	c.Prune = c.raw.Options.Get(pruneKey)
+	c.PruneTags = c.raw.Options.Get(pruneTagsKey)
+	c.Mirror = c.raw.Options.Get(mirrorKey) == "true"
+	c.Agent = c.raw.Options.Get(agentKey)
 
 	return nil
 }
@@ -381,6 +1069,12 @@ func (c *RemoteConfig) marshal() *format.Subsection {
 		c.raw.SetOption(urlKey, c.URLs...)
 	}
 
+	if len(c.PushURLs) == 0 {
+		c.raw.RemoveOption(pushurlKey)
+	} else {
+		c.raw.SetOption(pushurlKey, c.PushURLs...)
+	}
+
 	if len(c.Fetch) == 0 {
 		c.raw.RemoveOption(fetchKey)
 	} else {
@@ -392,5 +1086,29 @@ func (c *RemoteConfig) marshal() *format.Subsection {
 		c.raw.SetOption(fetchKey, values...)
 	}
 
+	if c.Prune == "" {
+		c.raw.RemoveOption(pruneKey)
+	} else {
+		c.raw.SetOption(pruneKey, c.Prune)
+	}
+
+	if c.PruneTags == "" {
+		c.raw.RemoveOption(pruneTagsKey)
+	} else {
+		c.raw.SetOption(pruneTagsKey, c.PruneTags)
+	}
+
+	if c.Mirror {
+		c.raw.SetOption(mirrorKey, "true")
+	} else {
+		c.raw.RemoveOption(mirrorKey)
+	}
+
+	if c.Agent == "" {
+		c.raw.RemoveOption(agentKey)
+	} else {
+		c.raw.SetOption(agentKey, c.Agent)
+	}
+
 	return c.raw
 }
@@ -13,23 +13,40 @@ func (s *ConfigSuite) TestUnmarshall(c *C) {
 	input := []byte(`[core]
         bare = true
 		worktree = foo
+		hooksPath = .githooks
+		untrackedCache = true
+		fsmonitor = .git/hooks/query-watchman
+		excludesfile = ~/.gitignore
+		symlinks = false
 [pack]
 		window = 20
+[fetch]
+		prune = true
+		writeCommitGraph = true
 [remote "origin"]
         url = git@github.com:mcuadros/go-git.git
         fetch = +refs/heads/*:refs/remotes/origin/*
+		prunetags = false
 [remote "alt"]
 		url = git@github.com:mcuadros/go-git.git
 		url = git@github.com:src-d/go-git.git
+		pushurl = git@github.com:pashh/go-git.git
 		fetch = +refs/heads/*:refs/remotes/origin/*
 		fetch = +refs/pull/*:refs/remotes/origin/pull/*
+		mirror = true
 [submodule "qux"]
         path = qux
         url = https://github.com/foo/qux.git
 		branch = bar
+[branch]
+		autosetupmerge = always
 [branch "master"]
         remote = origin
         merge = refs/heads/master
+[url "ssh://git@mirror.example.com/"]
+		insteadOf = https://github.com/
+		insteadOf = git://github.com/
+		pushInsteadOf = https://example.com/
 `)
 
 	cfg := NewConfig()
@@ -38,26 +55,45 @@ func (s *ConfigSuite) TestUnmarshall(c *C) {
 
 	c.Assert(cfg.Core.IsBare, Equals, true)
 	c.Assert(cfg.Core.Worktree, Equals, "foo")
+	c.Assert(cfg.Core.HooksPath, Equals, ".githooks")
+	c.Assert(cfg.Core.UntrackedCache, Equals, "true")
+	c.Assert(cfg.Core.FSMonitor, Equals, ".git/hooks/query-watchman")
+	c.Assert(cfg.Core.ExcludesFile, Equals, "~/.gitignore")
+	c.Assert(cfg.Core.Symlinks, Equals, "false")
 	c.Assert(cfg.Pack.Window, Equals, uint(20))
+	c.Assert(cfg.Fetch.Prune, Equals, true)
+	c.Assert(cfg.Fetch.PruneTags, Equals, false)
+	c.Assert(cfg.Fetch.WriteCommitGraph, Equals, true)
 	c.Assert(cfg.Remotes, HasLen, 2)
 	c.Assert(cfg.Remotes["origin"].Name, Equals, "origin")
 	c.Assert(cfg.Remotes["origin"].URLs, DeepEquals, []string{"git@github.com:mcuadros/go-git.git"})
 	c.Assert(cfg.Remotes["origin"].Fetch, DeepEquals, []RefSpec{"+refs/heads/*:refs/remotes/origin/*"})
+	c.Assert(cfg.Remotes["origin"].Prune, Equals, "")
+	c.Assert(cfg.Remotes["origin"].PruneTags, Equals, "false")
+	c.Assert(cfg.Remotes["origin"].Mirror, Equals, false)
 	c.Assert(cfg.Remotes["alt"].Name, Equals, "alt")
 	c.Assert(cfg.Remotes["alt"].URLs, DeepEquals, []string{"git@github.com:mcuadros/go-git.git", "git@github.com:src-d/go-git.git"})
 	c.Assert(cfg.Remotes["alt"].Fetch, DeepEquals, []RefSpec{"+refs/heads/*:refs/remotes/origin/*", "+refs/pull/*:refs/remotes/origin/pull/*"})
+	c.Assert(cfg.Remotes["alt"].PushURLs, DeepEquals, []string{"git@github.com:pashh/go-git.git"})
+	c.Assert(cfg.Remotes["alt"].Mirror, Equals, true)
 	c.Assert(cfg.Submodules, HasLen, 1)
 	c.Assert(cfg.Submodules["qux"].Name, Equals, "qux")
 	c.Assert(cfg.Submodules["qux"].URL, Equals, "https://github.com/foo/qux.git")
 	c.Assert(cfg.Submodules["qux"].Branch, Equals, "bar")
 	c.Assert(cfg.Branches["master"].Remote, Equals, "origin")
 	c.Assert(cfg.Branches["master"].Merge, Equals, plumbing.ReferenceName("refs/heads/master"))
+	c.Assert(cfg.Branch.AutoSetupMerge, Equals, "always")
+	c.Assert(cfg.URLs, HasLen, 1)
+	c.Assert(cfg.URLs["ssh://git@mirror.example.com/"].InsteadOf, DeepEquals, []string{"https://github.com/", "git://github.com/"})
+	c.Assert(cfg.URLs["ssh://git@mirror.example.com/"].PushInsteadOf, DeepEquals, []string{"https://example.com/"})
 }
 
 func (s *ConfigSuite) TestMarshall(c *C) {
 	output := []byte(`[core]
 	bare = true
 	worktree = bar
+[fetch]
+	prune = true
 [pack]
 	window = 20
 [remote "alt"]
@@ -65,6 +101,7 @@ func (s *ConfigSuite) TestMarshall(c *C) {
 	url = git@github.com:src-d/go-git.git
 	fetch = +refs/heads/*:refs/remotes/origin/*
 	fetch = +refs/pull/*:refs/remotes/origin/pull/*
+	mirror = true
 [remote "origin"]
 	url = git@github.com:mcuadros/go-git.git
 [submodule "qux"]
@@ -72,21 +109,25 @@ func (s *ConfigSuite) TestMarshall(c *C) {
 [branch "master"]
 	remote = origin
 	merge = refs/heads/master
+[url "ssh://git@mirror.example.com/"]
+	insteadOf = https://github.com/
 `)
 
 	cfg := NewConfig()
 	cfg.Core.IsBare = true
 	cfg.Core.Worktree = "bar"
 	cfg.Pack.Window = 20
+	cfg.Fetch.Prune = true
 	cfg.Remotes["origin"] = &RemoteConfig{
 		Name: "origin",
 		URLs: []string{"git@github.com:mcuadros/go-git.git"},
 	}
 
 	cfg.Remotes["alt"] = &RemoteConfig{
-		Name:  "alt",
-		URLs:  []string{"git@github.com:mcuadros/go-git.git", "git@github.com:src-d/go-git.git"},
-		Fetch: []RefSpec{"+refs/heads/*:refs/remotes/origin/*", "+refs/pull/*:refs/remotes/origin/pull/*"},
+		Name:   "alt",
+		URLs:   []string{"git@github.com:mcuadros/go-git.git", "git@github.com:src-d/go-git.git"},
+		Fetch:  []RefSpec{"+refs/heads/*:refs/remotes/origin/*", "+refs/pull/*:refs/remotes/origin/pull/*"},
+		Mirror: true,
 	}
 
 	cfg.Submodules["qux"] = &Submodule{
@@ -100,6 +141,11 @@ func (s *ConfigSuite) TestMarshall(c *C) {
 		Merge:  "refs/heads/master",
 	}
 
+	cfg.URLs["ssh://git@mirror.example.com/"] = &URL{
+		Name:      "ssh://git@mirror.example.com/",
+		InsteadOf: []string{"https://github.com/"},
+	}
+
 	b, err := cfg.Marshal()
 	c.Assert(err, IsNil)
 
@@ -131,6 +177,37 @@ func (s *ConfigSuite) TestUnmarshallMarshall(c *C) {
 	c.Assert(string(output), DeepEquals, string(input))
 }
 
+func (s *ConfigSuite) TestUnmarshallMarshallLayout(c *C) {
+	input := []byte(`# written by hand
+[core]
+	bare = false ; not a bare repository
+
+[remote "origin"]
+    url = git@github.com:mcuadros/go-git.git
+    fetch = +refs/heads/*:refs/remotes/origin/*
+`)
+
+	cfg := NewConfig()
+	err := cfg.Unmarshal(input)
+	c.Assert(err, IsNil)
+
+	cfg.Remotes["origin"].URLs = []string{"https://github.com/src-d/go-git.git"}
+	cfg.Branches["master"] = &Branch{Name: "master", Remote: "origin"}
+
+	output, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, `# written by hand
+[core]
+	bare = false ; not a bare repository
+
+[remote "origin"]
+    url = https://github.com/src-d/go-git.git
+    fetch = +refs/heads/*:refs/remotes/origin/*
+[branch "master"]
+	remote = origin
+`)
+}
+
 func (s *ConfigSuite) TestValidateConfig(c *C) {
 	config := &Config{
 		Remotes: map[string]*RemoteConfig{
@@ -235,3 +312,103 @@ func (s *ConfigSuite) TestRemoteConfigDefaultValues(c *C) {
 	c.Assert(config.Raw, NotNil)
 	c.Assert(config.Pack.Window, Equals, DefaultPackWindow)
 }
+
+func (s *ConfigSuite) TestBlameIgnoreRevsFiles(c *C) {
+	cfg := NewConfig()
+	c.Assert(cfg.Unmarshal([]byte("[blame]\n\tignoreRevsFile = .git-blame-ignore-revs\n\tignoreRevsFile = .reformat\n")), IsNil)
+	c.Assert(cfg.Blame.IgnoreRevsFiles, DeepEquals, []string{".git-blame-ignore-revs", ".reformat"})
+
+	cfg.Blame.IgnoreRevsFiles = []string{".reformat"}
+	_, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Raw.Section(blameSection).Options.GetAll(ignoreRevsFileKey), DeepEquals, []string{".reformat"})
+}
+
+func (s *ConfigSuite) TestSubmoduleFetchJobs(c *C) {
+	cfg := NewConfig()
+	c.Assert(cfg.Unmarshal([]byte("[submodule]\n\tfetchJobs = 4\n[submodule \"foo\"]\n\turl = bar\n")), IsNil)
+	c.Assert(cfg.Submodule.FetchJobs, Equals, 4)
+	c.Assert(cfg.Submodules, HasLen, 1)
+
+	cfg.Submodule.FetchJobs = 0
+	_, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Raw.Section(submoduleSection).Option(fetchJobsKey), Equals, "")
+	c.Assert(cfg.Raw.Section(submoduleSection).Subsections, HasLen, 1)
+}
+
+func (s *ConfigSuite) TestAutoStash(c *C) {
+	input := []byte(`[core]
+	bare = false
+[rebase]
+	autoStash = true
+[merge]
+	autoStash = false
+`)
+
+	cfg := NewConfig()
+	c.Assert(cfg.Unmarshal(input), IsNil)
+	c.Assert(cfg.Rebase.AutoStash, Equals, true)
+	c.Assert(cfg.Merge.AutoStash, Equals, false)
+
+	cfg.Rebase.AutoStash = false
+	cfg.Merge.AutoStash = true
+	output, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "[core]\n\tbare = false\n[merge]\n\tautoStash = true\n")
+}
+
+func (s *ConfigSuite) TestCommitAndMailmap(c *C) {
+	input := []byte(`[core]
+	bare = false
+	commentChar = %
+[commit]
+	template = ~/.gitmessage
+	cleanup = strip
+[mailmap]
+	file = ~/.mailmap
+	blob = HEAD:.mailmap
+`)
+
+	cfg := NewConfig()
+	c.Assert(cfg.Unmarshal(input), IsNil)
+	c.Assert(cfg.Core.CommentChar, Equals, "%")
+	c.Assert(cfg.Commit.Template, Equals, "~/.gitmessage")
+	c.Assert(cfg.Commit.Cleanup, Equals, "strip")
+	c.Assert(cfg.Mailmap.File, Equals, "~/.mailmap")
+	c.Assert(cfg.Mailmap.Blob, Equals, "HEAD:.mailmap")
+
+	output, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, string(input))
+}
+
+func (s *ConfigSuite) TestBigFileThreshold(c *C) {
+	cfg := NewConfig()
+	c.Assert(cfg.Core.BigFileThreshold, Equals, DefaultBigFileThreshold)
+
+	input := []byte(`[core]
+	bare = false
+	bigFileThreshold = 1m
+`)
+
+	c.Assert(cfg.Unmarshal(input), IsNil)
+	c.Assert(cfg.Core.BigFileThreshold, Equals, int64(1<<20))
+
+	output, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, string(input))
+
+	cfg.Core.BigFileThreshold = 2048
+	output, err = cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "[core]\n\tbare = false\n\tbigFileThreshold = 2048\n")
+
+	cfg.Core.BigFileThreshold = DefaultBigFileThreshold
+	output, err = cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "[core]\n\tbare = false\n")
+
+	err = NewConfig().Unmarshal([]byte("[core]\n\tbigFileThreshold = 1x\n"))
+	c.Assert(err, NotNil)
+}
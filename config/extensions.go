@@ -0,0 +1,86 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	repositoryFormatVersionKey = "repositoryformatversion"
+	preciousObjectsKey         = "preciousObjects"
+	objectFormatKey            = "objectFormat"
+	refStorageKey              = "refStorage"
+)
+
+// supportedExtensions are the extensions known, lowercased, with the values
+// supported, any if empty.
+var supportedExtensions = map[string]string{
+	"noop":                              "",
+	"noop-v1":                           "",
+	"partialclone":                      "",
+	strings.ToLower(worktreeConfigKey):  "",
+	strings.ToLower(preciousObjectsKey): "",
+	strings.ToLower(objectFormatKey):    "sha1",
+	strings.ToLower(refStorageKey):      "files",
+}
+
+var (
+	// ErrUnsupportedRepositoryFormat is returned by CheckExtensions when the
+	// core.repositoryformatversion of the repository is unknown.
+	ErrUnsupportedRepositoryFormat = errors.New("unsupported repository format version")
+	// ErrUnsupportedExtension is returned by CheckExtensions when the
+	// repository requires an extension not supported.
+	ErrUnsupportedExtension = errors.New("unsupported repository extension")
+)
+
+// UnsupportedExtensionError is the ErrUnsupportedExtension of a given
+// extensions.* option. It matches ErrUnsupportedExtension with errors.Is.
+type UnsupportedExtensionError struct {
+	Name  string
+	Value string
+}
+
+func (e *UnsupportedExtensionError) Error() string {
+	return fmt.Sprintf("%s: %s = %s", ErrUnsupportedExtension, e.Name, e.Value)
+}
+
+// Is returns true if target is ErrUnsupportedExtension.
+func (e *UnsupportedExtensionError) Is(target error) bool {
+	return target == ErrUnsupportedExtension
+}
+
+// CheckExtensions returns an error if the repository can't be handled, since
+// it's configured for features not supported, as git does. With the default
+// core.repositoryformatversion, 0, the extensions are ignored; with 1, every
+// extensions.* option must be known, and supported with its value; and the
+// greater versions are unknown.
+func (c *Config) CheckExtensions() error {
+	version := c.Raw.Section(coreSection).Options.Get(repositoryFormatVersionKey)
+	if version == "" {
+		return nil
+	}
+
+	v, err := strconv.Atoi(version)
+	if err != nil || v < 0 || v > 1 {
+		return ErrUnsupportedRepositoryFormat
+	}
+
+	if v == 0 {
+		return nil
+	}
+
+	for _, o := range c.Raw.Section(extensionsSection).Options {
+		if !isSupportedExtension(o.Key, o.Value) {
+			return &UnsupportedExtensionError{Name: o.Key, Value: o.Value}
+		}
+	}
+
+	return nil
+}
+
+func isSupportedExtension(name, value string) bool {
+	supported, ok := supportedExtensions[strings.ToLower(name)]
+	return ok && (supported == "" || strings.EqualFold(value, supported))
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type ExtensionsSuite struct{}
+
+var _ = Suite(&ExtensionsSuite{})
+
+func (s *ExtensionsSuite) TestCheckExtensions(c *C) {
+	for _, t := range []struct {
+		text string
+		err  error
+	}{
+		{"[core]\n\tbare = false\n", nil},
+		{"[core]\n\trepositoryformatversion = 0\n[extensions]\n\tfoo = bar\n", nil},
+		{"[core]\n\trepositoryformatversion = 1\n[extensions]\n\tworktreeConfig = true\n\tpreciousObjects = true\n\tobjectFormat = sha1\n", nil},
+		{"[core]\n\trepositoryformatversion = 1\n[extensions]\n\tfoo = bar\n", ErrUnsupportedExtension},
+		{"[core]\n\trepositoryformatversion = 1\n[extensions]\n\tobjectFormat = sha256\n", ErrUnsupportedExtension},
+		{"[core]\n\trepositoryformatversion = 1\n[extensions]\n\trefStorage = reftable\n", ErrUnsupportedExtension},
+		{"[core]\n\trepositoryformatversion = 2\n", ErrUnsupportedRepositoryFormat},
+		{"[core]\n\trepositoryformatversion = foo\n", ErrUnsupportedRepositoryFormat},
+	} {
+		cfg := NewConfig()
+		c.Assert(cfg.Unmarshal([]byte(t.text)), IsNil)
+
+		err := cfg.CheckExtensions()
+		if extErr, ok := err.(*UnsupportedExtensionError); ok {
+			c.Assert(extErr.Is(t.err), Equals, true, Commentf("config: %q, error: %v", t.text, err))
+		} else {
+			c.Assert(err, Equals, t.err, Commentf("config: %q", t.text))
+		}
+	}
+}
+
+func (s *ExtensionsSuite) TestUnsupportedExtensionError(c *C) {
+	cfg := NewConfig()
+	err := cfg.Unmarshal([]byte("[core]\n\trepositoryformatversion = 1\n[extensions]\n\tfoo = bar\n"))
+	c.Assert(err, IsNil)
+
+	err = cfg.CheckExtensions()
+	extErr, ok := err.(*UnsupportedExtensionError)
+	c.Assert(ok, Equals, true)
+	c.Assert(extErr.Name, Equals, "foo")
+	c.Assert(extErr.Value, Equals, "bar")
+	c.Assert(err, ErrorMatches, "unsupported repository extension: foo = bar")
+}
@@ -0,0 +1,169 @@
+package config
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	format "gopkg.in/src-d/go-git.v4/plumbing/format/config"
+)
+
+// HTTP contains the settings of the requests sent to HTTP remotes, as set by
+// the http.* options or, for the urls matching URL, the http.<url>.* ones.
+type HTTP struct {
+	// URL the settings apply to, empty for the http section.
+	URL string
+	// ExtraHeaders are the "Name: value" headers added to every request, an
+	// empty value resets the ones set by a less specific section.
+	ExtraHeaders []string
+	// SSLVerify is "false" to skip the verification of the server
+	// certificate, empty for the default.
+	SSLVerify string
+	// SSLCert and SSLKey are the paths of the client certificate and its key.
+	SSLCert string
+	SSLKey  string
+	// SSLCAInfo is the path of the certificate authorities bundle the server
+	// certificate is verified against.
+	SSLCAInfo string
+	// SSLVersion is the minimum TLS version, as "tlsv1.2".
+	SSLVersion string
+	// UserAgent replaces the default User-Agent.
+	UserAgent string
+	// FollowRedirects is "true", "false" or "initial", empty for the default.
+	FollowRedirects string
+	// LowSpeedLimit and LowSpeedTime, in bytes per second and seconds,
+	// abort the transfers slower than LowSpeedLimit during LowSpeedTime.
+	LowSpeedLimit int
+	LowSpeedTime  int
+
+	raw *format.Subsection
+}
+
+func (h *HTTP) marshal() *format.Subsection {
+	if h.raw == nil {
+		h.raw = &format.Subsection{}
+	}
+
+	h.raw.Name = h.URL
+
+	if len(h.ExtraHeaders) == 0 {
+		h.raw.RemoveOption(extraHeaderKey)
+	} else {
+		h.raw.SetOption(extraHeaderKey, h.ExtraHeaders...)
+	}
+
+	for _, o := range []struct{ key, value string }{
+		{sslVerifyKey, h.SSLVerify},
+		{sslCertKey, h.SSLCert},
+		{sslKeyKey, h.SSLKey},
+		{sslCAInfoKey, h.SSLCAInfo},
+		{sslVersionKey, h.SSLVersion},
+		{userAgentKey, h.UserAgent},
+		{followRedirectsKey, h.FollowRedirects},
+		{lowSpeedLimitKey, formatPositive(h.LowSpeedLimit)},
+		{lowSpeedTimeKey, formatPositive(h.LowSpeedTime)},
+	} {
+		if o.value == "" {
+			h.raw.RemoveOption(o.key)
+		} else {
+			h.raw.SetOption(o.key, o.value)
+		}
+	}
+
+	return h.raw
+}
+
+func (h *HTTP) unmarshal(s *format.Subsection) error {
+	h.raw = s
+
+	h.URL = h.raw.Name
+	h.ExtraHeaders = append([]string(nil), h.raw.Options.GetAll(extraHeaderKey)...)
+	h.SSLVerify = h.raw.Options.Get(sslVerifyKey)
+	h.SSLCert = h.raw.Options.Get(sslCertKey)
+	h.SSLKey = h.raw.Options.Get(sslKeyKey)
+	h.SSLCAInfo = h.raw.Options.Get(sslCAInfoKey)
+	h.SSLVersion = h.raw.Options.Get(sslVersionKey)
+	h.UserAgent = h.raw.Options.Get(userAgentKey)
+	h.FollowRedirects = h.raw.Options.Get(followRedirectsKey)
+
+	var err error
+	if h.LowSpeedLimit, err = parseInt(h.raw.Options.Get(lowSpeedLimitKey)); err != nil {
+		return err
+	}
+
+	h.LowSpeedTime, err = parseInt(h.raw.Options.Get(lowSpeedTimeKey))
+	return err
+}
+
+// HTTPFor returns the HTTP settings of the requests sent to url: the ones of
+// the http section overridden by the ones of every matching http.<url>
+// subsection, from the least to the most specific.
+func (c *Config) HTTPFor(url string) *HTTP {
+	sections := []*HTTP{&c.HTTP}
+	for _, h := range c.HTTPURLs {
+		if matchHTTPURL(h.URL, url) {
+			sections = append(sections, h)
+		}
+	}
+
+	sort.SliceStable(sections[1:], func(i, j int) bool {
+		return len(sections[i+1].URL) < len(sections[j+1].URL)
+	})
+
+	result := &HTTP{URL: url}
+	for _, h := range sections {
+		for _, header := range h.ExtraHeaders {
+			if header == "" {
+				result.ExtraHeaders = nil
+				continue
+			}
+
+			result.ExtraHeaders = append(result.ExtraHeaders, header)
+		}
+
+		override(&result.SSLVerify, h.SSLVerify)
+		override(&result.SSLCert, h.SSLCert)
+		override(&result.SSLKey, h.SSLKey)
+		override(&result.SSLCAInfo, h.SSLCAInfo)
+		override(&result.SSLVersion, h.SSLVersion)
+		override(&result.UserAgent, h.UserAgent)
+		override(&result.FollowRedirects, h.FollowRedirects)
+		if h.LowSpeedLimit > 0 {
+			result.LowSpeedLimit = h.LowSpeedLimit
+		}
+
+		if h.LowSpeedTime > 0 {
+			result.LowSpeedTime = h.LowSpeedTime
+		}
+	}
+
+	return result
+}
+
+// matchHTTPURL returns true if url is pattern or is below it.
+func matchHTTPURL(pattern, url string) bool {
+	pattern = strings.TrimSuffix(pattern, "/")
+	return url == pattern || strings.HasPrefix(url, pattern+"/")
+}
+
+func override(dst *string, value string) {
+	if value != "" {
+		*dst = value
+	}
+}
+
+func parseInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	return strconv.Atoi(value)
+}
+
+func formatPositive(value int) string {
+	if value <= 0 {
+		return ""
+	}
+
+	return strconv.Itoa(value)
+}
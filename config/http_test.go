@@ -0,0 +1,110 @@
+package config
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type HTTPSuite struct{}
+
+var _ = Suite(&HTTPSuite{})
+
+func (s *HTTPSuite) TestUnmarshall(c *C) {
+	input := []byte(`[http]
+	sslVerify = false
+	extraHeader = X-Foo: foo
+	lowSpeedLimit = 1000
+	lowSpeedTime = 30
+[http "https://example.com/"]
+	extraHeader = Authorization: Bearer token
+	userAgent = go-git/test
+	sslCert = /tmp/cert.pem
+	sslKey = /tmp/key.pem
+	sslCAInfo = /tmp/ca.pem
+	sslVersion = tlsv1.2
+	followRedirects = true
+`)
+
+	cfg := NewConfig()
+	err := cfg.Unmarshal(input)
+	c.Assert(err, IsNil)
+
+	c.Assert(cfg.HTTP.SSLVerify, Equals, "false")
+	c.Assert(cfg.HTTP.ExtraHeaders, DeepEquals, []string{"X-Foo: foo"})
+	c.Assert(cfg.HTTP.LowSpeedLimit, Equals, 1000)
+	c.Assert(cfg.HTTP.LowSpeedTime, Equals, 30)
+	c.Assert(cfg.HTTPURLs, HasLen, 1)
+
+	h := cfg.HTTPURLs["https://example.com/"]
+	c.Assert(h.URL, Equals, "https://example.com/")
+	c.Assert(h.ExtraHeaders, DeepEquals, []string{"Authorization: Bearer token"})
+	c.Assert(h.UserAgent, Equals, "go-git/test")
+	c.Assert(h.SSLCert, Equals, "/tmp/cert.pem")
+	c.Assert(h.SSLKey, Equals, "/tmp/key.pem")
+	c.Assert(h.SSLCAInfo, Equals, "/tmp/ca.pem")
+	c.Assert(h.SSLVersion, Equals, "tlsv1.2")
+	c.Assert(h.FollowRedirects, Equals, "true")
+}
+
+func (s *HTTPSuite) TestUnmarshallInvalidLowSpeedLimit(c *C) {
+	input := []byte(`[http]
+	lowSpeedLimit = foo
+`)
+
+	cfg := NewConfig()
+	err := cfg.Unmarshal(input)
+	c.Assert(err, NotNil)
+}
+
+func (s *HTTPSuite) TestMarshall(c *C) {
+	output := []byte(`[http]
+	sslVerify = false
+[http "https://example.com/"]
+	extraHeader = X-Foo: foo
+	extraHeader = X-Bar: bar
+	lowSpeedLimit = 1000
+	lowSpeedTime = 30
+`)
+
+	cfg := NewConfig()
+	cfg.HTTP.SSLVerify = "false"
+	cfg.HTTPURLs["https://example.com/"] = &HTTP{
+		URL:           "https://example.com/",
+		ExtraHeaders:  []string{"X-Foo: foo", "X-Bar: bar"},
+		LowSpeedLimit: 1000,
+		LowSpeedTime:  30,
+	}
+
+	b, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "[core]\n\tbare = false\n"+string(output))
+}
+
+func (s *HTTPSuite) TestHTTPFor(c *C) {
+	cfg := NewConfig()
+	cfg.HTTP.ExtraHeaders = []string{"X-Foo: foo"}
+	cfg.HTTP.UserAgent = "go-git/default"
+	cfg.HTTPURLs["https://example.com"] = &HTTP{
+		URL:       "https://example.com",
+		UserAgent: "go-git/example",
+		SSLVerify: "false",
+	}
+	cfg.HTTPURLs["https://example.com/foo/"] = &HTTP{
+		URL:          "https://example.com/foo/",
+		ExtraHeaders: []string{"", "X-Bar: bar"},
+		UserAgent:    "go-git/foo",
+	}
+
+	h := cfg.HTTPFor("https://example.com/foo/bar.git")
+	c.Assert(h.ExtraHeaders, DeepEquals, []string{"X-Bar: bar"})
+	c.Assert(h.UserAgent, Equals, "go-git/foo")
+	c.Assert(h.SSLVerify, Equals, "false")
+
+	h = cfg.HTTPFor("https://example.com/foobar.git")
+	c.Assert(h.ExtraHeaders, DeepEquals, []string{"X-Foo: foo"})
+	c.Assert(h.UserAgent, Equals, "go-git/example")
+
+	h = cfg.HTTPFor("https://example.org/foo/bar.git")
+	c.Assert(h.ExtraHeaders, DeepEquals, []string{"X-Foo: foo"})
+	c.Assert(h.UserAgent, Equals, "go-git/default")
+	c.Assert(h.SSLVerify, Equals, "")
+}
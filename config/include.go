@@ -0,0 +1,356 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	format "gopkg.in/src-d/go-git.v4/plumbing/format/config"
+)
+
+const (
+	includeSection   = "include"
+	includeIfSection = "includeIf"
+
+	gitDirCondition          = "gitdir:"
+	gitDirFoldCaseCondition  = "gitdir/i:"
+	onBranchCondition        = "onbranch:"
+	hasConfigRemoteCondition = "hasconfig:remote.*.url:"
+
+	// maxIncludeDepth is the maximum nesting of the included files, as in
+	// git.
+	maxIncludeDepth = 10
+)
+
+var (
+	// ErrIncludeDepth is returned when the included files are nested too
+	// deep, usually because a file ends up including itself.
+	ErrIncludeDepth = errors.New("exceeded maximum include depth")
+)
+
+// IncludeOptions are the settings used to resolve the include.path and
+// includeIf.<condition>.path options of a config file.
+type IncludeOptions struct {
+	// Path is the path of the config file, the relative included paths are
+	// resolved against its directory. If empty, they are ignored.
+	Path string
+	// GitDir is the path of the git directory, matched by the gitdir: and
+	// gitdir/i: conditions. If empty, those conditions never match.
+	GitDir string
+	// Branch is the name of the checked out branch, such as "master",
+	// matched by the onbranch: condition. If empty, it never matches.
+	Branch string
+	// ReadFile reads the included files, ioutil.ReadFile by default. The
+	// files not found are ignored, as git does.
+	ReadFile func(path string) ([]byte, error)
+	// Scope is the scope of the config file, reported by Config.Origin.
+	Scope Scope
+}
+
+// UnmarshalIncludes parses a git-config file as Unmarshal does, also reading
+// the files included by its include.path options, and by the
+// includeIf.<condition>.path ones whose condition matches: gitdir:,
+// gitdir/i:, onbranch: and hasconfig:remote.*.url:. The included options
+// take effect at the position of the option including them.
+//
+// Marshal doesn't write the included options back, unless they are changed,
+// so a config read this way can be stored as usual.
+func (c *Config) UnmarshalIncludes(b []byte, o *IncludeOptions) error {
+	if o == nil {
+		o = &IncludeOptions{}
+	}
+
+	r := &includeResolver{
+		o:        o,
+		raw:      format.New(),
+		external: make(map[interface{}]bool),
+		origins:  make(map[*format.Option]Origin),
+		urls:     remoteURLs(b),
+	}
+
+	if err := r.decode(b, o.Path, 0); err != nil {
+		return err
+	}
+
+	c.Raw = r.raw
+	c.layout = r.layout
+	c.external = r.external
+	c.origins = r.origins
+	c.scope = o.Scope
+	return c.unmarshal()
+}
+
+// includeResolver decodes a config file and the files included by it into
+// raw, keeping the options, sections and subsections read from the included
+// files at external and the file every option is read from at origins.
+type includeResolver struct {
+	o        *IncludeOptions
+	raw      *format.Config
+	external map[interface{}]bool
+	origins  map[*format.Option]Origin
+	// layout is the layout of the config file, without the included ones.
+	layout *format.Layout
+	// urls are the remote urls matched by the hasconfig:remote.*.url:
+	// conditions.
+	urls []string
+}
+
+func (r *includeResolver) decode(b []byte, path string, depth int) error {
+	d := format.NewDecoder(bytes.NewReader(b))
+	if depth == 0 {
+		r.layout = &format.Layout{}
+		d.Layout = r.layout
+	}
+
+	d.OptionAdded = func(section, subsection string, opt *format.Option) error {
+		r.origins[opt] = Origin{Scope: r.o.Scope, Path: path}
+		if depth > 0 {
+			r.external[opt] = true
+		}
+
+		if !opt.IsKey(pathKey) || !r.isIncluding(section, subsection, path) {
+			return nil
+		}
+
+		return r.include(r.resolvePath(opt.Value, path), depth+1)
+	}
+
+	return d.Decode(r.raw)
+}
+
+// isIncluding returns true if the options of the given section and
+// subsection include a file, that is if it's the include section or an
+// includeIf one whose condition matches.
+func (r *includeResolver) isIncluding(section, subsection, path string) bool {
+	if subsection == "" {
+		return strings.EqualFold(section, includeSection)
+	}
+
+	if !strings.EqualFold(section, includeIfSection) {
+		return false
+	}
+
+	return r.matchCondition(subsection, path)
+}
+
+func (r *includeResolver) include(path string, depth int) error {
+	if path == "" {
+		return nil
+	}
+
+	if depth > maxIncludeDepth {
+		return ErrIncludeDepth
+	}
+
+	readFile := r.o.ReadFile
+	if readFile == nil {
+		readFile = ioutil.ReadFile
+	}
+
+	b, err := readFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	existing := make(map[interface{}]bool)
+	for _, s := range r.raw.Sections {
+		existing[s] = true
+		for _, ss := range s.Subsections {
+			existing[ss] = true
+		}
+	}
+
+	if err := r.decode(b, path, depth); err != nil {
+		return err
+	}
+
+	for _, s := range r.raw.Sections {
+		if !existing[s] {
+			r.external[s] = true
+		}
+
+		for _, ss := range s.Subsections {
+			if !existing[ss] {
+				r.external[ss] = true
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *includeResolver) matchCondition(condition, path string) bool {
+	switch {
+	case strings.HasPrefix(condition, gitDirCondition):
+		return r.matchGitDir(condition[len(gitDirCondition):], path, false)
+	case strings.HasPrefix(condition, gitDirFoldCaseCondition):
+		return r.matchGitDir(condition[len(gitDirFoldCaseCondition):], path, true)
+	case strings.HasPrefix(condition, onBranchCondition):
+		if r.o.Branch == "" {
+			return false
+		}
+
+		pattern := condition[len(onBranchCondition):]
+		if strings.HasSuffix(pattern, "/") {
+			pattern += "**"
+		}
+
+		return matchGlob(pattern, r.o.Branch, false)
+	case strings.HasPrefix(condition, hasConfigRemoteCondition):
+		pattern := condition[len(hasConfigRemoteCondition):]
+		for _, url := range r.urls {
+			if matchGlob(pattern, url, false) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matchGitDir matches the git directory against the pattern of a gitdir:
+// condition, which is relative to the included file if it starts with "./",
+// and to any directory if it isn't absolute.
+func (r *includeResolver) matchGitDir(pattern, path string, foldCase bool) bool {
+	if r.o.GitDir == "" {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(pattern, "~/"):
+		dir := strings.HasSuffix(pattern, "/")
+		pattern = filepath.ToSlash(expandHome(pattern))
+		if dir {
+			pattern += "/"
+		}
+	case strings.HasPrefix(pattern, "./"):
+		if path == "" {
+			return false
+		}
+
+		dir := strings.HasSuffix(pattern, "/")
+		pattern = filepath.ToSlash(filepath.Join(filepath.Dir(path), pattern[2:]))
+		if dir {
+			pattern += "/"
+		}
+	case !strings.HasPrefix(pattern, "/"):
+		pattern = "**/" + pattern
+	}
+
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+
+	return matchGlob(pattern, filepath.ToSlash(r.o.GitDir), foldCase)
+}
+
+// resolvePath returns the path of an included file, value being relative to
+// the directory of the including file at path, or to the home directory if
+// it starts with "~/".
+func (r *includeResolver) resolvePath(value, path string) string {
+	if strings.HasPrefix(value, "~/") {
+		return expandHome(value)
+	}
+
+	if filepath.IsAbs(value) {
+		return value
+	}
+
+	if path == "" {
+		return ""
+	}
+
+	return filepath.Join(filepath.Dir(path), value)
+}
+
+func expandHome(path string) string {
+	u, err := user.Current()
+	if err != nil {
+		return path
+	}
+
+	return filepath.Join(u.HomeDir, path[2:])
+}
+
+// matchGlob matches name against a pattern where "*" matches anything but
+// "/", "**" anything and "?" a single character but "/".
+func matchGlob(pattern, name string, foldCase bool) bool {
+	var expr bytes.Buffer
+	if foldCase {
+		expr.WriteString("(?i)")
+	}
+
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			expr.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			expr.WriteString("[^/]*")
+		case pattern[i] == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+
+	expr.WriteString("$")
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return false
+	}
+
+	return re.MatchString(name)
+}
+
+// remoteURLs returns the remote urls of the config file b, ignoring the
+// errors, which are reported when decoding it.
+func remoteURLs(b []byte) []string {
+	raw := format.New()
+	if err := format.NewDecoder(bytes.NewReader(b)).Decode(raw); err != nil {
+		return nil
+	}
+
+	var urls []string
+	for _, ss := range raw.Section(remoteSection).Subsections {
+		urls = append(urls, ss.Options.GetAll(urlKey)...)
+	}
+
+	return urls
+}
+
+// markImpliedFetch marks as external the default fetch refspec given by
+// Validate to the external remotes, since it's implied by the file they are
+// read from and doesn't belong to the config being written.
+func (c *Config) markImpliedFetch() {
+	for _, r := range c.Remotes {
+		if r.raw == nil || !c.external[r.raw] {
+			continue
+		}
+
+		def := RefSpec(fmt.Sprintf(DefaultFetchRefSpec, r.Name))
+		if len(r.Fetch) != 1 || r.Fetch[0] != def {
+			continue
+		}
+
+		for _, o := range r.raw.Options {
+			if o.IsKey(fetchKey) {
+				c.external[o] = true
+			}
+		}
+	}
+}
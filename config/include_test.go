@@ -0,0 +1,162 @@
+package config
+
+import (
+	"os"
+
+	. "gopkg.in/check.v1"
+)
+
+type IncludeSuite struct{}
+
+var _ = Suite(&IncludeSuite{})
+
+func readFiles(files map[string]string) func(string) ([]byte, error) {
+	return func(path string) ([]byte, error) {
+		content, ok := files[path]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+
+		return []byte(content), nil
+	}
+}
+
+func (s *IncludeSuite) TestIncludePath(c *C) {
+	input := []byte(`[core]
+	bare = true
+[include]
+	path = shared.config
+	path = missing.config
+[core]
+	bare = false
+`)
+
+	cfg := NewConfig()
+	err := cfg.UnmarshalIncludes(input, &IncludeOptions{
+		Path: "/repo/.git/config",
+		ReadFile: readFiles(map[string]string{
+			"/repo/.git/shared.config": `[core]
+	bare = true
+	worktree = /foo
+[remote "origin"]
+	url = https://github.com/git-fixtures/basic
+`,
+		}),
+	})
+	c.Assert(err, IsNil)
+
+	// the options after the include override the included ones
+	c.Assert(cfg.Core.IsBare, Equals, false)
+	c.Assert(cfg.Core.Worktree, Equals, "/foo")
+	c.Assert(cfg.Remotes, HasLen, 1)
+	c.Assert(cfg.Remotes["origin"].URLs, DeepEquals, []string{"https://github.com/git-fixtures/basic"})
+}
+
+func (s *IncludeSuite) TestIncludeNested(c *C) {
+	input := []byte(`[include]
+	path = /etc/a.config
+`)
+
+	cfg := NewConfig()
+	err := cfg.UnmarshalIncludes(input, &IncludeOptions{
+		ReadFile: readFiles(map[string]string{
+			"/etc/a.config": "[include]\n\tpath = b.config\n",
+			"/etc/b.config": "[core]\n\tworktree = /foo\n",
+		}),
+	})
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Core.Worktree, Equals, "/foo")
+}
+
+func (s *IncludeSuite) TestIncludeDepth(c *C) {
+	input := []byte(`[include]
+	path = /etc/loop.config
+`)
+
+	cfg := NewConfig()
+	err := cfg.UnmarshalIncludes(input, &IncludeOptions{
+		ReadFile: readFiles(map[string]string{
+			"/etc/loop.config": "[include]\n\tpath = loop.config\n",
+		}),
+	})
+	c.Assert(err, Equals, ErrIncludeDepth)
+}
+
+func (s *IncludeSuite) TestIncludeIf(c *C) {
+	input := []byte(`[remote "origin"]
+	url = https://github.com/src-d/go-git
+[includeIf "gitdir:/work/"]
+	path = /etc/work.config
+[includeIf "gitdir:/home/"]
+	path = /etc/home.config
+[includeIf "gitdir/i:PROJECT/.git"]
+	path = /etc/project.config
+[includeIf "onbranch:feature/"]
+	path = /etc/feature.config
+[includeIf "onbranch:master"]
+	path = /etc/master.config
+[includeIf "hasconfig:remote.*.url:https://github.com/src-d/**"]
+	path = /etc/src-d.config
+[includeIf "hasconfig:remote.*.url:https://gitlab.com/**"]
+	path = /etc/gitlab.config
+`)
+
+	files := map[string]string{}
+	for _, name := range []string{"work", "home", "project", "feature", "master", "src-d", "gitlab"} {
+		files["/etc/"+name+".config"] = "[url \"" + name + "\"]\n\tinsteadOf = " + name + "\n"
+	}
+
+	cfg := NewConfig()
+	err := cfg.UnmarshalIncludes(input, &IncludeOptions{
+		GitDir:   "/work/foo/project/.git",
+		Branch:   "feature/foo",
+		ReadFile: readFiles(files),
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(cfg.URLs, HasLen, 4)
+	c.Assert(cfg.URLs["work"], NotNil)
+	c.Assert(cfg.URLs["project"], NotNil)
+	c.Assert(cfg.URLs["feature"], NotNil)
+	c.Assert(cfg.URLs["src-d"], NotNil)
+}
+
+func (s *IncludeSuite) TestMarshalWithoutIncluded(c *C) {
+	input := []byte(`[core]
+	bare = false
+[include]
+	path = /etc/shared.config
+`)
+
+	cfg := NewConfig()
+	err := cfg.UnmarshalIncludes(input, &IncludeOptions{
+		ReadFile: readFiles(map[string]string{
+			"/etc/shared.config": `[core]
+	worktree = /foo
+[remote "origin"]
+	url = https://github.com/git-fixtures/basic
+[remote "upstream"]
+	url = https://github.com/src-d/go-git
+`,
+		}),
+	})
+	c.Assert(err, IsNil)
+
+	cfg.Remotes["origin"].URLs = []string{"https://github.com/git-fixtures/tags"}
+	cfg.Remotes["fork"] = &RemoteConfig{
+		Name: "fork",
+		URLs: []string{"https://github.com/foo/go-git"},
+	}
+
+	b, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, `[core]
+	bare = false
+[include]
+	path = /etc/shared.config
+[remote "origin"]
+	url = https://github.com/git-fixtures/tags
+[remote "fork"]
+	url = https://github.com/foo/go-git
+`)
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"strconv"
+)
+
+const (
+	maintenanceSection = "maintenance"
+	enabledKey         = "enabled"
+	batchSizeKey       = "batchSize"
+)
+
+// MaintenanceTask is the config of a task of the repository maintenance, set
+// at the maintenance.<task> options.
+type MaintenanceTask struct {
+	// Enabled makes the task run when the maintenance is run without tasks.
+	Enabled bool
+	// Auto is the threshold of the task when the maintenance is run as
+	// needed, its meaning depends on the task. A value of 0 turns the task
+	// off, and a negative one makes it run always.
+	Auto int
+	// BatchSize is the maximum number of objects the task processes at
+	// once, for the tasks doing their work in batches. A value of 0 means
+	// no limit.
+	BatchSize int
+}
+
+// MaintenanceTask returns the config of the maintenance task with the given
+// name, def with the options set at maintenance.<name>.enabled,
+// maintenance.<name>.auto and maintenance.<name>.batchSize.
+func (c *Config) MaintenanceTask(name string, def MaintenanceTask) (MaintenanceTask, error) {
+	t := def
+	section := c.Raw.Section(maintenanceSection)
+	if !section.HasSubsection(name) {
+		return t, nil
+	}
+
+	s := section.Subsection(name)
+	if enabled := s.Options.Get(enabledKey); enabled != "" {
+		v, err := strconv.ParseBool(enabled)
+		if err != nil {
+			return t, err
+		}
+		t.Enabled = v
+	}
+
+	if auto := s.Options.Get(autoKey); auto != "" {
+		v, err := strconv.Atoi(auto)
+		if err != nil {
+			return t, err
+		}
+		t.Auto = v
+	}
+
+	if size := s.Options.Get(batchSizeKey); size != "" {
+		v, err := strconv.Atoi(size)
+		if err != nil {
+			return t, err
+		}
+		t.BatchSize = v
+	}
+
+	return t, nil
+}
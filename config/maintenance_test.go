@@ -0,0 +1,61 @@
+package config
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type MaintenanceSuite struct{}
+
+var _ = Suite(&MaintenanceSuite{})
+
+func (s *MaintenanceSuite) TestMaintenanceTask(c *C) {
+	cfg := NewConfig()
+	c.Assert(cfg.Unmarshal([]byte(`[maintenance "gc"]
+	enabled = false
+[maintenance "loose-objects"]
+	auto = 10
+	batchSize = 5
+`)), IsNil)
+
+	def := MaintenanceTask{Enabled: true, Auto: 100}
+
+	t, err := cfg.MaintenanceTask("gc", def)
+	c.Assert(err, IsNil)
+	c.Assert(t, Equals, MaintenanceTask{Enabled: false, Auto: 100})
+
+	t, err = cfg.MaintenanceTask("loose-objects", def)
+	c.Assert(err, IsNil)
+	c.Assert(t, Equals, MaintenanceTask{Enabled: true, Auto: 10, BatchSize: 5})
+
+	t, err = cfg.MaintenanceTask("commit-graph", def)
+	c.Assert(err, IsNil)
+	c.Assert(t, Equals, def)
+	c.Assert(cfg.Raw.Section(maintenanceSection).HasSubsection("commit-graph"), Equals, false)
+}
+
+func (s *MaintenanceSuite) TestMaintenanceTaskInvalid(c *C) {
+	cfg := NewConfig()
+	c.Assert(cfg.Unmarshal([]byte("[maintenance \"gc\"]\n\tauto = foo\n")), IsNil)
+
+	_, err := cfg.MaintenanceTask("gc", MaintenanceTask{})
+	c.Assert(err, NotNil)
+}
+
+func (s *MaintenanceSuite) TestGC(c *C) {
+	cfg := NewConfig()
+	c.Assert(cfg.GC.Auto, Equals, DefaultGCAuto)
+	c.Assert(cfg.GC.AutoPackLimit, Equals, DefaultGCAutoPackLimit)
+	c.Assert(cfg.GC.AutoDetach, Equals, true)
+
+	c.Assert(cfg.Unmarshal([]byte("[gc]\n\tauto = 0\n\tautoPackLimit = 10\n\tautoDetach = false\n")), IsNil)
+	c.Assert(cfg.GC.Auto, Equals, 0)
+	c.Assert(cfg.GC.AutoPackLimit, Equals, 10)
+	c.Assert(cfg.GC.AutoDetach, Equals, false)
+
+	cfg.GC.Auto = DefaultGCAuto
+	_, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Raw.Section(gcSection).Option(autoKey), Equals, "")
+	c.Assert(cfg.Raw.Section(gcSection).Option(autoPackLimitKey), Equals, "10")
+	c.Assert(cfg.Raw.Section(gcSection).Option(autoDetachKey), Equals, "false")
+}
@@ -37,8 +37,10 @@ func NewModules() *Modules {
 }
 
 const (
-	pathKey   = "path"
-	branchKey = "branch"
+	pathKey    = "path"
+	branchKey  = "branch"
+	shallowKey = "shallow"
+	updateKey  = "update"
 )
 
 // Unmarshal parses a git-config file and stores it.
@@ -86,6 +88,12 @@ type Submodule struct {
 	// Branch is a remote branch name for tracking updates in the upstream
 	// submodule. Optional value.
 	Branch string
+	// Shallow recommends the submodule to be cloned shallowly, fetching
+	// only the tip of its history.
+	Shallow bool
+	// Update is how the submodule is updated: "checkout", the default,
+	// "rebase", "merge" or "none".
+	Update string
 
 	// raw representation of the subsection, filled by marshal or unmarshal are
 	// called.
@@ -116,6 +124,8 @@ func (m *Submodule) unmarshal(s *format.Subsection) {
 	m.Path = m.raw.Option(pathKey)
 	m.URL = m.raw.Option(urlKey)
 	m.Branch = m.raw.Option(branchKey)
+	m.Shallow = m.raw.Option(shallowKey) == "true"
+	m.Update = m.raw.Option(updateKey)
 }
 
 func (m *Submodule) marshal() *format.Subsection {
@@ -135,5 +145,15 @@ func (m *Submodule) marshal() *format.Subsection {
 		m.raw.SetOption(branchKey, m.Branch)
 	}
 
+	if m.Shallow {
+		m.raw.SetOption(shallowKey, "true")
+	} else {
+		m.raw.RemoveOption(shallowKey)
+	}
+
+	if m.Update != "" {
+		m.raw.SetOption(updateKey, m.Update)
+	}
+
 	return m.raw
 }
@@ -94,3 +94,21 @@ func (s *ModulesSuite) TestUnmarshallMarshall(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(string(output), DeepEquals, string(input))
 }
+
+func (s *ModulesSuite) TestUnmarshallShallowUpdate(c *C) {
+	cfg := NewModules()
+	err := cfg.Unmarshal([]byte(`[submodule "qux"]
+	path = qux
+	url = baz
+	shallow = true
+	update = rebase
+`))
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Submodules["qux"].Shallow, Equals, true)
+	c.Assert(cfg.Submodules["qux"].Update, Equals, "rebase")
+
+	cfg.Submodules["qux"].Shallow = false
+	output, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(output), Equals, "[submodule \"qux\"]\n\tpath = qux\n\turl = baz\n\tupdate = rebase\n")
+}
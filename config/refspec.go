@@ -11,11 +11,19 @@ const (
 	refSpecWildcard  = "*"
 	refSpecForce     = "+"
 	refSpecSeparator = ":"
+	refSpecNegative  = "^"
+
+	// refSpecReviewPrefix is the prefix of the references of the code review
+	// servers, e.g. refs/for/master, whose names may end with a % followed
+	// by options, e.g. "refs/for/master%topic=foo,r=alice@example.com".
+	refSpecReviewPrefix  = "refs/for/"
+	refSpecReviewOptions = "%"
 )
 
 var (
 	ErrRefSpecMalformedSeparator = errors.New("malformed refspec, separators are wrong")
 	ErrRefSpecMalformedWildcard  = errors.New("malformed refspec, missmatched number of wildcards")
+	ErrRefSpecMalformedNegative  = errors.New("malformed refspec, negative refspecs only have a source")
 )
 
 // RefSpec is a mapping from local branches to remote references
@@ -25,23 +33,45 @@ var (
 // reference even if it isn’t a fast-forward.
 // eg.: "+refs/heads/*:refs/remotes/origin/*"
 //
+// A negative refspec is a ^ followed by a <src> pattern, the references it
+// matches are excluded from the other refspecs, e.g. "^refs/heads/wip/*".
+//
+// The destination of a push to a code review server, as Gerrit, may end with
+// review options following a %, e.g. "HEAD:refs/for/master%topic=foo", they
+// are sent along with the reference name and never parsed as a wildcard or
+// a separator.
+//
 // https://git-scm.com/book/es/v2/Git-Internals-The-Refspec
 type RefSpec string
 
 // Validate validates the RefSpec
 func (s RefSpec) Validate() error {
 	spec := string(s)
-	if strings.Count(spec, refSpecSeparator) != 1 {
-		return ErrRefSpecMalformedSeparator
+	if s.IsNegative() {
+		if len(spec) == 1 || strings.Contains(spec, refSpecSeparator) ||
+			strings.HasPrefix(spec[1:], refSpecForce) {
+			return ErrRefSpecMalformedNegative
+		}
+
+		if strings.Count(spec, refSpecWildcard) > 1 {
+			return ErrRefSpecMalformedWildcard
+		}
+
+		return nil
 	}
 
 	sep := strings.Index(spec, refSpecSeparator)
-	if sep == len(spec)-1 {
+	if sep == -1 || sep == len(spec)-1 {
+		return ErrRefSpecMalformedSeparator
+	}
+
+	dst := trimReviewOptions(spec[sep+1:])
+	if strings.Contains(dst, refSpecSeparator) {
 		return ErrRefSpecMalformedSeparator
 	}
 
 	ws := strings.Count(spec[0:sep], refSpecWildcard)
-	wd := strings.Count(spec[sep+1:], refSpecWildcard)
+	wd := strings.Count(dst, refSpecWildcard)
 	if ws == wd && ws < 2 && wd < 2 {
 		return nil
 	}
@@ -59,9 +89,18 @@ func (s RefSpec) IsDelete() bool {
 	return s[0] == refSpecSeparator[0]
 }
 
+// IsNegative returns true if the refspec excludes the references matching
+// its source.
+func (s RefSpec) IsNegative() bool {
+	return s[0] == refSpecNegative[0]
+}
+
 // Src return the src side.
 func (s RefSpec) Src() string {
 	spec := string(s)
+	if s.IsNegative() {
+		return spec[1:]
+	}
 
 	var start int
 	if s.IsForceUpdate() {
@@ -74,8 +113,23 @@ func (s RefSpec) Src() string {
 	return spec[start:end]
 }
 
-// Match match the given plumbing.ReferenceName against the source.
+// Match match the given plumbing.ReferenceName against the source. A negative
+// refspec maps no references, so it never matches, see Excludes.
 func (s RefSpec) Match(n plumbing.ReferenceName) bool {
+	if s.IsNegative() {
+		return false
+	}
+
+	return s.matchSrc(n)
+}
+
+// Excludes returns true if the refspec is negative and the given
+// plumbing.ReferenceName matches its source.
+func (s RefSpec) Excludes(n plumbing.ReferenceName) bool {
+	return s.IsNegative() && s.matchSrc(n)
+}
+
+func (s RefSpec) matchSrc(n plumbing.ReferenceName) bool {
 	if !s.IsWildcard() {
 		return s.matchExact(n)
 	}
@@ -83,9 +137,29 @@ func (s RefSpec) Match(n plumbing.ReferenceName) bool {
 	return s.matchGlob(n)
 }
 
-// IsWildcard returns true if the RefSpec contains a wildcard.
+// IsWildcard returns true if the RefSpec contains a wildcard, outside of the
+// review options of its destination.
 func (s RefSpec) IsWildcard() bool {
-	return strings.Contains(string(s), refSpecWildcard)
+	spec := string(s)
+	if sep := strings.Index(spec, refSpecSeparator); sep != -1 {
+		spec = spec[:sep+1] + trimReviewOptions(spec[sep+1:])
+	}
+
+	return strings.Contains(spec, refSpecWildcard)
+}
+
+// trimReviewOptions returns the given destination without its review
+// options, if it is a reference of a code review server.
+func trimReviewOptions(dst string) string {
+	if !strings.HasPrefix(dst, refSpecReviewPrefix) {
+		return dst
+	}
+
+	if i := strings.Index(dst, refSpecReviewOptions); i != -1 {
+		return dst[:i]
+	}
+
+	return dst
 }
 
 func (s RefSpec) matchExact(n plumbing.ReferenceName) bool {
@@ -99,8 +173,8 @@ func (s RefSpec) matchGlob(n plumbing.ReferenceName) bool {
 
 	var prefix, suffix string
 	prefix = src[0:wildcard]
-	if len(src) < wildcard {
-		suffix = src[wildcard+1 : len(suffix)]
+	if len(src) > wildcard+1 {
+		suffix = src[wildcard+1:]
 	}
 
 	return len(name) > len(prefix)+len(suffix) &&
@@ -127,12 +201,31 @@ func (s RefSpec) Dst(n plumbing.ReferenceName) plumbing.ReferenceName {
 	return plumbing.ReferenceName(dst[0:wd] + match + dst[wd+1:])
 }
 
+// Reverse returns the RefSpec mapping the destination back to the source,
+// e.g. "refs/remotes/origin/*:refs/heads/*" for the default fetch RefSpec.
+func (s RefSpec) Reverse() RefSpec {
+	spec := string(s)
+	sep := strings.Index(spec, refSpecSeparator)
+
+	var force string
+	if s.IsForceUpdate() {
+		force = refSpecForce
+	}
+
+	return RefSpec(force + spec[sep+1:] + refSpecSeparator + s.Src())
+}
+
 func (s RefSpec) String() string {
 	return string(s)
 }
 
-// MatchAny returns true if any of the RefSpec match with the given ReferenceName.
+// MatchAny returns true if any of the RefSpec match with the given
+// ReferenceName, and none of the negative ones excludes it.
 func MatchAny(l []RefSpec, n plumbing.ReferenceName) bool {
+	if ExcludedByAny(l, n) {
+		return false
+	}
+
 	for _, r := range l {
 		if r.Match(n) {
 			return true
@@ -141,3 +234,57 @@ func MatchAny(l []RefSpec, n plumbing.ReferenceName) bool {
 
 	return false
 }
+
+// ExcludedByAny returns true if any of the negative RefSpec excludes the given
+// ReferenceName.
+func ExcludedByAny(l []RefSpec, n plumbing.ReferenceName) bool {
+	for _, r := range l {
+		if r.Excludes(n) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RefMapping is a reference mapped by a RefSpec from its source to its
+// destination.
+type RefMapping struct {
+	// Src is the name of the reference at the source side.
+	Src plumbing.ReferenceName
+	// Dst is the name of the reference updated at the destination side.
+	Dst plumbing.ReferenceName
+	// Force is true if Dst is updated even if it isn't a fast-forward.
+	Force bool
+	// RefSpec is the refspec mapping the reference.
+	RefSpec RefSpec
+}
+
+// ExpandRefSpecs returns the mappings the RefSpecs make for the given names of
+// the references at the source side, such as the references advertised by a
+// remote when fetching, or the local references when pushing. Each name is
+// mapped by every RefSpec matching it, in order, unless a negative RefSpec
+// excludes it. The delete RefSpecs map no references.
+func ExpandRefSpecs(l []RefSpec, names []plumbing.ReferenceName) []RefMapping {
+	var mappings []RefMapping
+	for _, n := range names {
+		if ExcludedByAny(l, n) {
+			continue
+		}
+
+		for _, r := range l {
+			if r.IsDelete() || !r.Match(n) {
+				continue
+			}
+
+			mappings = append(mappings, RefMapping{
+				Src:     n,
+				Dst:     r.Dst(n),
+				Force:   r.IsForceUpdate(),
+				RefSpec: r,
+			})
+		}
+	}
+
+	return mappings
+}
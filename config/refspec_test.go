@@ -116,6 +116,39 @@ func (s *RefSpecSuite) TestRefSpecDstBlob(c *C) {
 		"refs/remotes/origin/foo",
 	)
 }
+
+func (s *RefSpecSuite) TestRefSpecReviewOptions(c *C) {
+	spec := RefSpec("HEAD:refs/for/master%topic=fix*,m=Fix:bug,r=alice@example.com")
+	c.Assert(spec.Validate(), IsNil)
+	c.Assert(spec.IsWildcard(), Equals, false)
+	c.Assert(spec.Src(), Equals, "HEAD")
+	c.Assert(
+		spec.Dst(plumbing.ReferenceName("HEAD")).String(), Equals,
+		"refs/for/master%topic=fix*,m=Fix:bug,r=alice@example.com",
+	)
+
+	spec = RefSpec("refs/heads/*:refs/for/*%wip")
+	c.Assert(spec.Validate(), IsNil)
+	c.Assert(spec.IsWildcard(), Equals, true)
+	c.Assert(
+		spec.Dst(plumbing.ReferenceName("refs/heads/foo")).String(), Equals,
+		"refs/for/foo%wip",
+	)
+
+	spec = RefSpec("refs/heads/master:refs/heads/master%topic=foo:bar")
+	c.Assert(spec.Validate(), Equals, ErrRefSpecMalformedSeparator)
+}
+
+func (s *RefSpecSuite) TestRefSpecReverse(c *C) {
+	spec := RefSpec("+refs/heads/*:refs/remotes/origin/*").Reverse()
+	c.Assert(spec, Equals, RefSpec("+refs/remotes/origin/*:refs/heads/*"))
+	c.Assert(spec.Match("refs/remotes/origin/foo"), Equals, true)
+	c.Assert(spec.Dst("refs/remotes/origin/foo").String(), Equals, "refs/heads/foo")
+
+	spec = RefSpec("refs/heads/master:refs/remotes/origin/master").Reverse()
+	c.Assert(spec, Equals, RefSpec("refs/remotes/origin/master:refs/heads/master"))
+}
+
 func (s *RefSpecSuite) TestMatchAny(c *C) {
 	specs := []RefSpec{
 		"refs/heads/bar:refs/remotes/origin/foo",
@@ -126,3 +159,70 @@ func (s *RefSpecSuite) TestMatchAny(c *C) {
 	c.Assert(MatchAny(specs, plumbing.ReferenceName("refs/heads/bar")), Equals, true)
 	c.Assert(MatchAny(specs, plumbing.ReferenceName("refs/heads/master")), Equals, false)
 }
+
+func (s *RefSpecSuite) TestRefSpecNegative(c *C) {
+	spec := RefSpec("^refs/heads/wip/*")
+	c.Assert(spec.Validate(), IsNil)
+	c.Assert(spec.IsNegative(), Equals, true)
+	c.Assert(spec.Src(), Equals, "refs/heads/wip/*")
+	c.Assert(spec.Match("refs/heads/wip/foo"), Equals, false)
+	c.Assert(spec.Excludes("refs/heads/wip/foo"), Equals, true)
+	c.Assert(spec.Excludes("refs/heads/master"), Equals, false)
+
+	spec = RefSpec("^refs/heads/master")
+	c.Assert(spec.Validate(), IsNil)
+	c.Assert(spec.Excludes("refs/heads/master"), Equals, true)
+
+	c.Assert(RefSpec("+refs/heads/*:refs/remotes/origin/*").Excludes("refs/heads/master"), Equals, false)
+
+	for _, spec := range []RefSpec{"^", "^refs/heads/*:refs/remotes/origin/*", "^+refs/heads/*"} {
+		c.Assert(spec.Validate(), Equals, ErrRefSpecMalformedNegative, Commentf("%s", spec))
+	}
+
+	c.Assert(RefSpec("^refs/*/*").Validate(), Equals, ErrRefSpecMalformedWildcard)
+}
+
+func (s *RefSpecSuite) TestRefSpecMatchGlobSuffix(c *C) {
+	spec := RefSpec("^refs/heads/*-wip")
+	c.Assert(spec.Excludes("refs/heads/foo-wip"), Equals, true)
+	c.Assert(spec.Excludes("refs/heads/foo"), Equals, false)
+}
+
+func (s *RefSpecSuite) TestMatchAnyNegative(c *C) {
+	specs := []RefSpec{
+		"+refs/heads/*:refs/remotes/origin/*",
+		"^refs/heads/wip/*",
+	}
+
+	c.Assert(MatchAny(specs, "refs/heads/master"), Equals, true)
+	c.Assert(MatchAny(specs, "refs/heads/wip/foo"), Equals, false)
+	c.Assert(ExcludedByAny(specs, "refs/heads/wip/foo"), Equals, true)
+}
+
+func (s *RefSpecSuite) TestExpandRefSpecs(c *C) {
+	specs := []RefSpec{
+		"+refs/heads/*:refs/remotes/origin/*",
+		"refs/tags/v1.0:refs/tags/v1.0",
+		"^refs/heads/wip/*",
+		":refs/heads/old",
+	}
+
+	mappings := ExpandRefSpecs(specs, []plumbing.ReferenceName{
+		"HEAD",
+		"refs/heads/master",
+		"refs/heads/wip/foo",
+		"refs/tags/v1.0",
+		"refs/tags/v2.0",
+	})
+
+	c.Assert(mappings, DeepEquals, []RefMapping{{
+		Src:     "refs/heads/master",
+		Dst:     "refs/remotes/origin/master",
+		Force:   true,
+		RefSpec: specs[0],
+	}, {
+		Src:     "refs/tags/v1.0",
+		Dst:     "refs/tags/v1.0",
+		RefSpec: specs[1],
+	}})
+}
@@ -0,0 +1,374 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+
+	format "gopkg.in/src-d/go-git.v4/plumbing/format/config"
+)
+
+// Scope is the scope of a config file, the wider scopes have less
+// precedence.
+type Scope int
+
+const (
+	// LocalScope is the config of a repository, .git/config.
+	LocalScope Scope = iota
+	// GlobalScope is the config of the user, ~/.gitconfig and
+	// $XDG_CONFIG_HOME/git/config.
+	GlobalScope
+	// SystemScope is the config of the system, /etc/gitconfig.
+	SystemScope
+	// WorktreeScope is the config of the worktree, config.worktree at the
+	// git directory, read when extensions.worktreeConfig is enabled. It
+	// takes precedence over the LocalScope.
+	WorktreeScope Scope = -1
+)
+
+// String returns the name of the scope, as shown by
+// `git config --show-scope`.
+func (s Scope) String() string {
+	switch s {
+	case LocalScope:
+		return "local"
+	case GlobalScope:
+		return "global"
+	case SystemScope:
+		return "system"
+	case WorktreeScope:
+		return "worktree"
+	}
+
+	return "unknown"
+}
+
+var (
+	// ErrLocalScope is returned when the config of the local or worktree
+	// scopes is requested outside of a repository.
+	ErrLocalScope = errors.New("the local config belongs to a repository")
+
+	errNoConfigFile = errors.New("no config file for the scope")
+)
+
+// Origin is the file an option of a config is read from, as reported by
+// `git config --show-origin`.
+type Origin struct {
+	Scope Scope
+	// Path of the file, empty if the option isn't read from a file.
+	Path string
+}
+
+// Origin returns the origin of an option of Raw.
+func (c *Config) Origin(o *format.Option) Origin {
+	if origin, ok := c.origins[o]; ok {
+		return origin
+	}
+
+	return Origin{Scope: c.scope}
+}
+
+// Paths returns the paths of the config files of the given scope, global or
+// system, from the least to the most significant one. The files may not
+// exist. As in git, the GIT_CONFIG_GLOBAL and GIT_CONFIG_SYSTEM environment
+// variables replace them, and GIT_CONFIG_NOSYSTEM disables the system ones.
+func Paths(scope Scope) ([]string, error) {
+	switch scope {
+	case GlobalScope:
+		if path := os.Getenv("GIT_CONFIG_GLOBAL"); path != "" {
+			return []string{path}, nil
+		}
+
+		u, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+
+		xdg := os.Getenv("XDG_CONFIG_HOME")
+		if xdg == "" {
+			xdg = filepath.Join(u.HomeDir, ".config")
+		}
+
+		return []string{
+			filepath.Join(xdg, "git", "config"),
+			filepath.Join(u.HomeDir, ".gitconfig"),
+		}, nil
+	case SystemScope:
+		if isTrue(os.Getenv("GIT_CONFIG_NOSYSTEM")) {
+			return nil, nil
+		}
+
+		if path := os.Getenv("GIT_CONFIG_SYSTEM"); path != "" {
+			return []string{path}, nil
+		}
+
+		if runtime.GOOS == "windows" {
+			return []string{
+				filepath.Join(os.Getenv("PROGRAMDATA"), "Git", "config"),
+				filepath.Join(os.Getenv("PROGRAMFILES"), "Git", "etc", "gitconfig"),
+			}, nil
+		}
+
+		return []string{"/etc/gitconfig"}, nil
+	}
+
+	return nil, ErrLocalScope
+}
+
+// writePath returns the path of the file the config of the given scope is
+// stored at: the most significant one, unless only the ~/.gitconfig of the
+// global scope is missing, as git does.
+func writePath(scope Scope) (string, error) {
+	paths, err := Paths(scope)
+	if err != nil {
+		return "", err
+	}
+
+	if len(paths) == 0 {
+		return "", errNoConfigFile
+	}
+
+	path := paths[len(paths)-1]
+	if scope == GlobalScope && len(paths) == 2 {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if _, err := os.Stat(paths[0]); err == nil {
+				path = paths[0]
+			}
+		}
+	}
+
+	return path, nil
+}
+
+// LoadConfig reads the config of the given scope, global or system, merging
+// all its files and the ones they include. The files missing are ignored.
+func LoadConfig(scope Scope) (*Config, error) {
+	paths, err := Paths(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	write, err := writePath(scope)
+	if err != nil && err != errNoConfigFile {
+		return nil, err
+	}
+
+	// the file the config is stored at is merged the last, so only its
+	// options are marshalled.
+	var cfgs []*Config
+	var last *Config
+	for _, path := range paths {
+		cfg, err := readConfigFile(path, scope)
+		if err != nil {
+			return nil, err
+		}
+
+		if path == write {
+			last = cfg
+			continue
+		}
+
+		cfgs = append(cfgs, cfg)
+	}
+
+	if last == nil {
+		last = NewConfig()
+		last.scope = scope
+	}
+
+	return Merge(append(cfgs, last)...)
+}
+
+func readConfigFile(path string, scope Scope) (*Config, error) {
+	cfg := NewConfig()
+	cfg.scope = scope
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.UnmarshalIncludes(b, &IncludeOptions{Path: path, Scope: scope}); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// SaveConfig stores the config at the file of the given scope, global or
+// system, ~/.gitconfig and /etc/gitconfig by default. The options read from
+// the other files of the scope are not written, unless they are changed.
+func SaveConfig(scope Scope, cfg *Config) error {
+	path, err := writePath(scope)
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	b, err := cfg.Marshal()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// Merge returns the configs merged, from the least to the most significant
+// one, so the options of the last ones take precedence, keeping the origin
+// of every option. The returned config marshals only the options of the
+// last config, unless the others are changed, so it can be stored in its
+// place, preserving the layout of its file.
+func Merge(cfgs ...*Config) (*Config, error) {
+	for _, cfg := range cfgs {
+		// the options not marshalled yet, as with the memory storage, are
+		// set at Raw.
+		if _, err := cfg.Marshal(); err != nil {
+			return nil, err
+		}
+	}
+
+	return merge(len(cfgs)-1, cfgs...)
+}
+
+// MergeWorktree returns the config of a repository, local, merged with the
+// config of its worktree, wt, whose options take precedence. The returned
+// config marshals the options of wt if stored is WorktreeScope, and the
+// ones of local otherwise. Both configs are expected to be read from their
+// files.
+func MergeWorktree(local, wt *Config, stored Scope) (*Config, error) {
+	// wt isn't marshalled, since that would set the options with a default
+	// value, such as core.bare, overriding the ones of local.
+	if _, err := local.Marshal(); err != nil {
+		return nil, err
+	}
+
+	if stored == WorktreeScope {
+		return merge(1, local, wt)
+	}
+
+	return merge(0, local, wt)
+}
+
+// merge merges the configs as Merge does, the returned config marshalling
+// only the options of the one at stored.
+func merge(stored int, cfgs ...*Config) (*Config, error) {
+	result := NewConfig()
+	result.Raw = format.New()
+	result.external = make(map[interface{}]bool)
+	result.origins = make(map[*format.Option]Origin)
+
+	owned := make(map[interface{}]bool)
+	for i, cfg := range cfgs {
+		own := i == stored
+		for _, s := range cfg.Raw.Sections {
+			section := result.Raw.Section(s.Name)
+			result.markExternal(section, owned, own && !cfg.external[s])
+			section.Options = result.mergeOptions(cfg, section.Options, s.Options, own)
+			for _, ss := range s.Subsections {
+				subsection := section.Subsection(ss.Name)
+				result.markExternal(subsection, owned, own && !cfg.external[ss])
+				subsection.Options = result.mergeOptions(cfg, subsection.Options, ss.Options, own)
+			}
+		}
+	}
+
+	if stored >= 0 {
+		result.scope = cfgs[stored].scope
+		result.layout = cfgs[stored].layout
+	}
+
+	if err := result.unmarshal(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// markExternal marks a section or subsection of the merged config as
+// external, unless it's owned by the config it's stored in place of.
+func (c *Config) markExternal(s interface{}, owned map[interface{}]bool, own bool) {
+	if own {
+		owned[s] = true
+		delete(c.external, s)
+		return
+	}
+
+	if !owned[s] {
+		c.external[s] = true
+	}
+}
+
+func (c *Config) mergeOptions(cfg *Config, dst, src format.Options, own bool) format.Options {
+	for _, o := range src {
+		// the options of the stored config are kept, since they are the
+		// ones of its layout
+		n := o
+		if !own {
+			n = &format.Option{Key: o.Key, Value: o.Value}
+		}
+
+		c.origins[n] = cfg.Origin(o)
+		if !own || cfg.external[o] {
+			c.external[n] = true
+		}
+
+		dst = append(dst, n)
+	}
+
+	return dst
+}
+
+// withoutExternal returns a copy of raw without the external options, nor
+// the subsections left empty.
+func withoutExternal(raw *format.Config, external map[interface{}]bool) *format.Config {
+	result := format.New()
+	for _, s := range raw.Sections {
+		section := &format.Section{Name: s.Name, Options: withoutExternalOptions(s.Options, external)}
+		for _, ss := range s.Subsections {
+			opts := withoutExternalOptions(ss.Options, external)
+			if len(opts) == 0 && (external[ss] || len(ss.Options) > 0) {
+				continue
+			}
+
+			section.Subsections = append(section.Subsections, &format.Subsection{Name: ss.Name, Options: opts})
+		}
+
+		result.Sections = append(result.Sections, section)
+	}
+
+	return result
+}
+
+func withoutExternalOptions(opts format.Options, external map[interface{}]bool) format.Options {
+	var result format.Options
+	for _, o := range opts {
+		if !external[o] {
+			result = append(result, o)
+		}
+	}
+
+	return result
+}
+
+func isTrue(value string) bool {
+	switch value {
+	case "true", "yes", "on", "1":
+		return true
+	}
+
+	return false
+}
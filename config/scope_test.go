@@ -0,0 +1,122 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+)
+
+type ScopeSuite struct {
+	env map[string]string
+}
+
+var _ = Suite(&ScopeSuite{})
+
+func (s *ScopeSuite) SetUpTest(c *C) {
+	s.env = make(map[string]string)
+	for _, name := range []string{"GIT_CONFIG_GLOBAL", "GIT_CONFIG_SYSTEM", "GIT_CONFIG_NOSYSTEM"} {
+		s.env[name] = os.Getenv(name)
+	}
+}
+
+func (s *ScopeSuite) TearDownTest(c *C) {
+	for name, value := range s.env {
+		os.Setenv(name, value)
+	}
+}
+
+func (s *ScopeSuite) TestPaths(c *C) {
+	os.Setenv("GIT_CONFIG_GLOBAL", "/tmp/global.config")
+	os.Setenv("GIT_CONFIG_SYSTEM", "/tmp/system.config")
+	os.Setenv("GIT_CONFIG_NOSYSTEM", "")
+
+	paths, err := Paths(GlobalScope)
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"/tmp/global.config"})
+
+	paths, err = Paths(SystemScope)
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"/tmp/system.config"})
+
+	os.Setenv("GIT_CONFIG_NOSYSTEM", "true")
+	paths, err = Paths(SystemScope)
+	c.Assert(err, IsNil)
+	c.Assert(paths, HasLen, 0)
+
+	_, err = Paths(LocalScope)
+	c.Assert(err, Equals, ErrLocalScope)
+}
+
+func (s *ScopeSuite) TestLoadConfig(c *C) {
+	dir := c.MkDir()
+	path := filepath.Join(dir, "gitconfig")
+	err := ioutil.WriteFile(path, []byte("[core]\n\tworktree = /foo\n"), 0644)
+	c.Assert(err, IsNil)
+
+	os.Setenv("GIT_CONFIG_SYSTEM", path)
+	os.Setenv("GIT_CONFIG_NOSYSTEM", "")
+
+	cfg, err := LoadConfig(SystemScope)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Core.Worktree, Equals, "/foo")
+
+	o := cfg.Raw.Section("core").Options[0]
+	c.Assert(cfg.Origin(o), Equals, Origin{Scope: SystemScope, Path: path})
+
+	cfg.Core.ExcludesFile = "/bar"
+	err = SaveConfig(SystemScope, cfg)
+	c.Assert(err, IsNil)
+
+	b, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "[core]\n\tworktree = /foo\n\tbare = false\n\texcludesFile = /bar\n")
+}
+
+func (s *ScopeSuite) TestLoadConfigMissing(c *C) {
+	os.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(c.MkDir(), "missing"))
+
+	cfg, err := LoadConfig(GlobalScope)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Remotes, HasLen, 0)
+}
+
+func (s *ScopeSuite) TestMerge(c *C) {
+	system := NewConfig()
+	err := system.UnmarshalIncludes([]byte(`[core]
+	worktree = /system
+[remote "mirror"]
+	url = https://example.com/mirror
+`), &IncludeOptions{Path: "/etc/gitconfig", Scope: SystemScope})
+	c.Assert(err, IsNil)
+
+	local := NewConfig()
+	err = local.Unmarshal([]byte(`[core]
+	worktree = /local
+[remote "origin"]
+	url = https://github.com/git-fixtures/basic
+`))
+	c.Assert(err, IsNil)
+
+	cfg, err := Merge(system, local)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Core.Worktree, Equals, "/local")
+	c.Assert(cfg.Remotes, HasLen, 2)
+
+	opts := cfg.Raw.Section("core").Options
+	c.Assert(opts, HasLen, 4)
+	c.Assert(opts[0].Value, Equals, "/system")
+	c.Assert(cfg.Origin(opts[0]), Equals, Origin{Scope: SystemScope, Path: "/etc/gitconfig"})
+	c.Assert(opts[2].Value, Equals, "/local")
+	c.Assert(cfg.Origin(opts[2]), Equals, Origin{Scope: LocalScope})
+
+	b, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, `[core]
+	worktree = /local
+	bare = false
+[remote "origin"]
+	url = https://github.com/git-fixtures/basic
+`)
+}
@@ -0,0 +1,224 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	format "gopkg.in/src-d/go-git.v4/plumbing/format/config"
+)
+
+const (
+	transferSection             = "transfer"
+	uploadPackSection           = "uploadpack"
+	uploadArchiveSection        = "uploadarchive"
+	repackSection               = "repack"
+	sendPackSection             = "sendpack"
+	receiveSection              = "receive"
+	hideRefsKey                 = "hideRefs"
+	allowTipSHA1InWantKey       = "allowTipSHA1InWant"
+	allowReachableSHA1InWantKey = "allowReachableSHA1InWant"
+	allowUnreachableKey         = "allowUnreachable"
+	useDeltaBaseOffsetKey       = "useDeltaBaseOffset"
+	sidebandKey                 = "sideband"
+	agentKey                    = "agent"
+	fsckObjectsKey              = "fsckObjects"
+	fsckSubsection              = "fsck"
+)
+
+// UploadPack is the config of the upload-pack server, set at the transfer
+// and uploadpack sections.
+type UploadPack struct {
+	// HideRefs are the patterns of the references omitted from the
+	// advertisement, see IsHiddenRef.
+	HideRefs []string
+	// AllowTipSHA1InWant allows the clients to fetch the tips of the hidden
+	// references by their hash.
+	AllowTipSHA1InWant bool
+	// AllowReachableSHA1InWant allows the clients to fetch any object
+	// reachable from a reference by its hash.
+	AllowReachableSHA1InWant bool
+}
+
+// UploadPack returns the config of the upload-pack server, its HideRefs are
+// the values of transfer.hideRefs followed by the ones of uploadpack.hideRefs.
+func (c *Config) UploadPack() (UploadPack, error) {
+	u := UploadPack{
+		HideRefs: c.hideRefs(uploadPackSection),
+	}
+
+	for _, o := range []struct {
+		key string
+		v   *bool
+	}{
+		{allowTipSHA1InWantKey, &u.AllowTipSHA1InWant},
+		{allowReachableSHA1InWantKey, &u.AllowReachableSHA1InWant},
+	} {
+		value := rawOptions(c.Raw, uploadPackSection).Get(o.key)
+		if value == "" {
+			continue
+		}
+
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return u, err
+		}
+
+		*o.v = v
+	}
+
+	return u, nil
+}
+
+// UploadArchiveAllowUnreachable returns the value of
+// uploadarchive.allowUnreachable, if true the upload-archive server archives
+// any tree-ish requested by its hash, not only the ones of the references.
+func (c *Config) UploadArchiveAllowUnreachable() (bool, error) {
+	return rawBool(c.Raw, uploadArchiveSection, allowUnreachableKey, false)
+}
+
+// UseDeltaBaseOffset returns the value of repack.useDeltaBaseOffset, true
+// by default. If false, the clients don't request the ofs-delta capability,
+// getting and sending the deltas with their base hash instead.
+func (c *Config) UseDeltaBaseOffset() (bool, error) {
+	return rawBool(c.Raw, repackSection, useDeltaBaseOffsetKey, true)
+}
+
+// SendPackSideband returns the value of sendpack.sideband, true by default.
+// If false, the pushes don't request the side-band capabilities.
+func (c *Config) SendPackSideband() (bool, error) {
+	return rawBool(c.Raw, sendPackSection, sidebandKey, true)
+}
+
+// Agent returns the agent the clients identify with to the given remote,
+// its Agent or, if empty or the remote is nil, the value of transfer.agent.
+// It is empty if none of them is set.
+func (c *Config) Agent(r *RemoteConfig) string {
+	if r != nil && r.Agent != "" {
+		return r.Agent
+	}
+
+	return rawOptions(c.Raw, transferSection).Get(agentKey)
+}
+
+// FetchFsckObjects returns the value of fetch.fsckObjects or, if not set,
+// of transfer.fsckObjects, false by default. If true, the objects fetched
+// are checked to be connected and valid before updating the references.
+func (c *Config) FetchFsckObjects() (bool, error) {
+	return c.fsckObjects(fetchSection)
+}
+
+// ReceiveFsckObjects returns the value of receive.fsckObjects or, if not
+// set, of transfer.fsckObjects, false by default. If true, the receive-pack
+// server checks the objects pushed before updating the references.
+func (c *Config) ReceiveFsckObjects() (bool, error) {
+	return c.fsckObjects(receiveSection)
+}
+
+func (c *Config) fsckObjects(section string) (bool, error) {
+	def, err := rawBool(c.Raw, transferSection, fsckObjectsKey, false)
+	if err != nil {
+		return false, err
+	}
+
+	return rawBool(c.Raw, section, fsckObjectsKey, def)
+}
+
+// FetchFsckOptions returns the options set at fetch.fsck, keyed by their
+// name in lower case, as the config keys are case insensitive, the last value
+// being kept. They are the severities overriding the default ones of the
+// problems found in the fetched objects when FetchFsckObjects is true, e.g.
+// missingEmail = ignore to accept the commits of an old history without
+// email, parsed by fsck.ParseSeverities.
+func (c *Config) FetchFsckOptions() map[string]string {
+	return c.fsckOptions(fetchSection)
+}
+
+// ReceiveFsckOptions returns the options set at receive.fsck, as
+// FetchFsckOptions does, overriding the severity of the problems found in the
+// pushed objects when ReceiveFsckObjects is true.
+func (c *Config) ReceiveFsckOptions() map[string]string {
+	return c.fsckOptions(receiveSection)
+}
+
+func (c *Config) fsckOptions(section string) map[string]string {
+	opts := make(map[string]string)
+	for _, s := range c.Raw.Sections {
+		if !s.IsName(section) {
+			continue
+		}
+
+		for _, ss := range s.Subsections {
+			if !ss.IsName(fsckSubsection) {
+				continue
+			}
+
+			for _, o := range ss.Options {
+				opts[strings.ToLower(o.Key)] = o.Value
+			}
+		}
+	}
+
+	return opts
+}
+
+// ReceivePackHideRefs returns the patterns of the references omitted from
+// the advertisement of the receive-pack server, the values of
+// transfer.hideRefs followed by the ones of receive.hideRefs.
+func (c *Config) ReceivePackHideRefs() []string {
+	return c.hideRefs(receiveSection)
+}
+
+func (c *Config) hideRefs(section string) []string {
+	var refs []string
+	refs = append(refs, rawOptions(c.Raw, transferSection).GetAll(hideRefsKey)...)
+	return append(refs, rawOptions(c.Raw, section).GetAll(hideRefsKey)...)
+}
+
+// rawOptions returns the options of the given section, without adding the
+// section if missing.
+func rawOptions(raw *format.Config, section string) format.Options {
+	var opts format.Options
+	for _, s := range raw.Sections {
+		if s.IsName(section) {
+			opts = append(opts, s.Options...)
+		}
+	}
+
+	return opts
+}
+
+// rawBool returns the boolean value of the given option, def if not set.
+func rawBool(raw *format.Config, section, key string, def bool) (bool, error) {
+	value := rawOptions(raw, section).Get(key)
+	if value == "" {
+		return def, nil
+	}
+
+	return strconv.ParseBool(value)
+}
+
+// IsHiddenRef returns true if the reference is hidden by the given hideRefs
+// patterns. A pattern hides the reference named as it or under it, e.g.
+// refs/pull hides refs/pull/1/head, unless it starts with ! which reveals
+// the references hidden by the previous patterns. A leading ^, to match the
+// full name of the references outside of a namespace, is ignored.
+func IsHiddenRef(hideRefs []string, n plumbing.ReferenceName) bool {
+	name := n.String()
+
+	hidden := false
+	for _, p := range hideRefs {
+		reveal := strings.HasPrefix(p, "!")
+		p = strings.TrimPrefix(p, "!")
+		p = strings.TrimSuffix(strings.TrimPrefix(p, "^"), "/")
+		if p == "" {
+			continue
+		}
+
+		if name == p || strings.HasPrefix(name, p+"/") {
+			hidden = !reveal
+		}
+	}
+
+	return hidden
+}
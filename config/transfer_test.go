@@ -0,0 +1,179 @@
+package config
+
+import (
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	. "gopkg.in/check.v1"
+)
+
+type TransferSuite struct{}
+
+var _ = Suite(&TransferSuite{})
+
+func (s *TransferSuite) TestUploadPack(c *C) {
+	cfg := NewConfig()
+	c.Assert(cfg.Unmarshal([]byte(`[transfer]
+	hideRefs = refs/pull
+[uploadpack]
+	hideRefs = refs/changes
+	allowTipSHA1InWant = true
+[receive]
+	hideRefs = refs/meta
+`)), IsNil)
+
+	u, err := cfg.UploadPack()
+	c.Assert(err, IsNil)
+	c.Assert(u, DeepEquals, UploadPack{
+		HideRefs:           []string{"refs/pull", "refs/changes"},
+		AllowTipSHA1InWant: true,
+	})
+
+	c.Assert(cfg.ReceivePackHideRefs(), DeepEquals, []string{"refs/pull", "refs/meta"})
+}
+
+func (s *TransferSuite) TestUploadPackInvalid(c *C) {
+	cfg := NewConfig()
+	c.Assert(cfg.Unmarshal([]byte("[uploadpack]\n\tallowReachableSHA1InWant = foo\n")), IsNil)
+
+	_, err := cfg.UploadPack()
+	c.Assert(err, NotNil)
+}
+
+func (s *TransferSuite) TestUploadPackKeepsSections(c *C) {
+	cfg := NewConfig()
+	_, err := cfg.UploadPack()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Raw.Sections, HasLen, 0)
+}
+
+func (s *TransferSuite) TestUploadArchiveAllowUnreachable(c *C) {
+	cfg := NewConfig()
+	allow, err := cfg.UploadArchiveAllowUnreachable()
+	c.Assert(err, IsNil)
+	c.Assert(allow, Equals, false)
+
+	c.Assert(cfg.Unmarshal([]byte("[uploadarchive]\n\tallowUnreachable = true\n")), IsNil)
+	allow, err = cfg.UploadArchiveAllowUnreachable()
+	c.Assert(err, IsNil)
+	c.Assert(allow, Equals, true)
+}
+
+func (s *TransferSuite) TestCapabilitiesConfig(c *C) {
+	cfg := NewConfig()
+	ofsDelta, err := cfg.UseDeltaBaseOffset()
+	c.Assert(err, IsNil)
+	c.Assert(ofsDelta, Equals, true)
+
+	sideband, err := cfg.SendPackSideband()
+	c.Assert(err, IsNil)
+	c.Assert(sideband, Equals, true)
+
+	c.Assert(cfg.Unmarshal([]byte(`[repack]
+	useDeltaBaseOffset = false
+[sendpack]
+	sideband = false
+`)), IsNil)
+
+	ofsDelta, err = cfg.UseDeltaBaseOffset()
+	c.Assert(err, IsNil)
+	c.Assert(ofsDelta, Equals, false)
+
+	sideband, err = cfg.SendPackSideband()
+	c.Assert(err, IsNil)
+	c.Assert(sideband, Equals, false)
+}
+
+func (s *TransferSuite) TestAgent(c *C) {
+	cfg := NewConfig()
+	c.Assert(cfg.Agent(nil), Equals, "")
+
+	c.Assert(cfg.Unmarshal([]byte(`[transfer]
+	agent = foo/1.0
+[remote "origin"]
+	url = https://github.com/foo/bar.git
+	agent = bar/2.0
+[remote "alt"]
+	url = https://github.com/foo/qux.git
+`)), IsNil)
+
+	c.Assert(cfg.Remotes["origin"].Agent, Equals, "bar/2.0")
+	c.Assert(cfg.Agent(cfg.Remotes["origin"]), Equals, "bar/2.0")
+	c.Assert(cfg.Agent(cfg.Remotes["alt"]), Equals, "foo/1.0")
+	c.Assert(cfg.Agent(nil), Equals, "foo/1.0")
+
+	b, err := cfg.Marshal()
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(b), "\tagent = bar/2.0\n"), Equals, true)
+}
+
+func (s *TransferSuite) TestFsckObjects(c *C) {
+	cfg := NewConfig()
+	fetch, err := cfg.FetchFsckObjects()
+	c.Assert(err, IsNil)
+	c.Assert(fetch, Equals, false)
+
+	c.Assert(cfg.Unmarshal([]byte(`[transfer]
+	fsckObjects = true
+[fetch]
+	fsckobjects = false
+`)), IsNil)
+
+	fetch, err = cfg.FetchFsckObjects()
+	c.Assert(err, IsNil)
+	c.Assert(fetch, Equals, false)
+
+	receive, err := cfg.ReceiveFsckObjects()
+	c.Assert(err, IsNil)
+	c.Assert(receive, Equals, true)
+
+	c.Assert(cfg.Unmarshal([]byte(`[receive]
+	fsckObjects = foo
+`)), IsNil)
+
+	_, err = cfg.ReceiveFsckObjects()
+	c.Assert(err, NotNil)
+}
+
+func (s *TransferSuite) TestFsckOptions(c *C) {
+	cfg := NewConfig()
+	c.Assert(cfg.Unmarshal([]byte(`[fetch "fsck"]
+	missingEmail = ignore
+	zeroPaddedFilemode = error
+	missingemail = warn
+	skipList = .git/fsck-skip
+[receive "fsck"]
+	badDate = ignore
+`)), IsNil)
+
+	c.Assert(cfg.FetchFsckOptions(), DeepEquals, map[string]string{
+		"missingemail":       "warn",
+		"zeropaddedfilemode": "error",
+		"skiplist":           ".git/fsck-skip",
+	})
+
+	c.Assert(cfg.ReceiveFsckOptions(), DeepEquals, map[string]string{"baddate": "ignore"})
+	c.Assert(NewConfig().FetchFsckOptions(), HasLen, 0)
+}
+
+func (s *TransferSuite) TestIsHiddenRef(c *C) {
+	hideRefs := []string{"refs/pull/", "refs/changes", "!refs/changes/01", "^refs/meta"}
+
+	for name, hidden := range map[string]bool{
+		"refs/pull/1/head":      true,
+		"refs/pulls":            false,
+		"refs/changes":          true,
+		"refs/changes/02/2/1":   true,
+		"refs/changes/01/1/1":   false,
+		"refs/meta/config":      true,
+		"refs/heads/master":     false,
+		"refs/heads/refs/pull":  false,
+		"refs/tags/refs/change": false,
+	} {
+		c.Assert(IsHiddenRef(hideRefs, plumbing.ReferenceName(name)), Equals, hidden,
+			Commentf("%s", name))
+	}
+
+	c.Assert(IsHiddenRef(nil, plumbing.Master), Equals, false)
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"errors"
+	"strings"
+
+	format "gopkg.in/src-d/go-git.v4/plumbing/format/config"
+)
+
+var (
+	errURLEmptyName = errors.New("url config: empty name")
+)
+
+// URL defines URL rewrite rules, as set by the `url.<base>.insteadOf` and
+// `url.<base>.pushInsteadOf` options.
+type URL struct {
+	// Name is the base URL, the prefix replacing any matching rule.
+	Name string
+	// InsteadOf are the URL prefixes rewritten to Name.
+	InsteadOf []string
+	// PushInsteadOf are the URL prefixes rewritten to Name, only when
+	// pushing.
+	PushInsteadOf []string
+
+	raw *format.Subsection
+}
+
+// Validate validates fields of url
+func (u *URL) Validate() error {
+	if u.Name == "" {
+		return errURLEmptyName
+	}
+
+	return nil
+}
+
+func (u *URL) marshal() *format.Subsection {
+	if u.raw == nil {
+		u.raw = &format.Subsection{}
+	}
+
+	u.raw.Name = u.Name
+
+	if len(u.InsteadOf) == 0 {
+		u.raw.RemoveOption(insteadOfKey)
+	} else {
+		u.raw.SetOption(insteadOfKey, u.InsteadOf...)
+	}
+
+	if len(u.PushInsteadOf) == 0 {
+		u.raw.RemoveOption(pushInsteadOfKey)
+	} else {
+		u.raw.SetOption(pushInsteadOfKey, u.PushInsteadOf...)
+	}
+
+	return u.raw
+}
+
+func (u *URL) unmarshal(s *format.Subsection) error {
+	u.raw = s
+
+	u.Name = u.raw.Name
+	u.InsteadOf = append([]string(nil), u.raw.Options.GetAll(insteadOfKey)...)
+	u.PushInsteadOf = append([]string(nil), u.raw.Options.GetAll(pushInsteadOfKey)...)
+
+	return u.Validate()
+}
+
+// RewriteURL returns the given url rewritten by the insteadOf rules of the
+// config. As git does, the longest matching prefix wins; the url is returned
+// unchanged if none matches.
+func (c *Config) RewriteURL(url string) string {
+	return c.rewriteURL(url, func(u *URL) []string { return u.InsteadOf })
+}
+
+// RewritePushURL returns the given url rewritten for pushing: the
+// pushInsteadOf rules are applied first, falling back to the insteadOf ones
+// if none matches. It should not be used on the explicit push URLs of a
+// remote, which as in git are only rewritten by RewriteURL.
+func (c *Config) RewritePushURL(url string) string {
+	rewritten := c.rewriteURL(url, func(u *URL) []string { return u.PushInsteadOf })
+	if rewritten != url {
+		return rewritten
+	}
+
+	return c.RewriteURL(url)
+}
+
+func (c *Config) rewriteURL(url string, rules func(*URL) []string) string {
+	var base, prefix string
+	for _, u := range c.URLs {
+		for _, p := range rules(u) {
+			if len(p) > len(prefix) && strings.HasPrefix(url, p) {
+				base, prefix = u.Name, p
+			}
+		}
+	}
+
+	if prefix == "" {
+		return url
+	}
+
+	return base + url[len(prefix):]
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type URLSuite struct{}
+
+var _ = Suite(&URLSuite{})
+
+func (s *URLSuite) TestValidateName(c *C) {
+	goodURL := URL{
+		Name:      "ssh://git@mirror.example.com/",
+		InsteadOf: []string{"https://github.com/"},
+	}
+	badURL := URL{
+		InsteadOf: []string{"https://github.com/"},
+	}
+	c.Assert(goodURL.Validate(), IsNil)
+	c.Assert(badURL.Validate(), NotNil)
+}
+
+func (s *URLSuite) TestRewriteURL(c *C) {
+	cfg := NewConfig()
+	cfg.URLs["ssh://git@mirror.example.com/"] = &URL{
+		Name:      "ssh://git@mirror.example.com/",
+		InsteadOf: []string{"https://github.com/"},
+	}
+	cfg.URLs["ssh://git@internal.example.com/"] = &URL{
+		Name:      "ssh://git@internal.example.com/",
+		InsteadOf: []string{"https://github.com/src-d/"},
+	}
+
+	c.Assert(cfg.RewriteURL("https://github.com/foo/bar.git"), Equals, "ssh://git@mirror.example.com/foo/bar.git")
+	c.Assert(cfg.RewriteURL("https://github.com/src-d/go-git.git"), Equals, "ssh://git@internal.example.com/go-git.git")
+	c.Assert(cfg.RewriteURL("https://gitlab.com/foo/bar.git"), Equals, "https://gitlab.com/foo/bar.git")
+}
+
+func (s *URLSuite) TestRewritePushURL(c *C) {
+	cfg := NewConfig()
+	cfg.URLs["ssh://git@github.com/"] = &URL{
+		Name:          "ssh://git@github.com/",
+		PushInsteadOf: []string{"https://github.com/"},
+	}
+	cfg.URLs["ssh://git@mirror.example.com/"] = &URL{
+		Name:      "ssh://git@mirror.example.com/",
+		InsteadOf: []string{"https://github.com/", "https://gitlab.com/"},
+	}
+
+	c.Assert(cfg.RewriteURL("https://github.com/foo/bar.git"), Equals, "ssh://git@mirror.example.com/foo/bar.git")
+	c.Assert(cfg.RewritePushURL("https://github.com/foo/bar.git"), Equals, "ssh://git@github.com/foo/bar.git")
+	c.Assert(cfg.RewritePushURL("https://gitlab.com/foo/bar.git"), Equals, "ssh://git@mirror.example.com/foo/bar.git")
+}
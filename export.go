@@ -0,0 +1,141 @@
+package git
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+
+	"gopkg.in/src-d/go-billy.v4"
+)
+
+// exportDirMode is the mode of the directories created by Export.
+const exportDirMode = 0755
+
+// Export writes the files of the tree of the given commit, tag or tree to a
+// filesystem, as `git archive | tar -x` does, without touching the index nor
+// the worktree of the repository. The files already existing at the
+// filesystem are overwritten, and the submodules are written as empty
+// directories. The symbolic links are written as plain files, with the link
+// target as content, if the filesystem doesn't support them.
+func (r *Repository) Export(o *ExportOptions) error {
+	return r.ExportContext(context.Background(), o)
+}
+
+// ExportContext writes the files of a tree to a filesystem, as Export does.
+// The provided Context must be non-nil, if it is canceled the export stops
+// and its error is returned, leaving the files written until then.
+func (r *Repository) ExportContext(ctx context.Context, o *ExportOptions) error {
+	if err := o.Validate(r); err != nil {
+		return err
+	}
+
+	t, err := r.exportTree(o.Hash)
+	if err != nil {
+		return err
+	}
+
+	caps := exportCapabilities(o.Filesystem)
+	w := object.NewTreeWalker(t, true, nil)
+	defer w.Close()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		name, e, err := w.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if !exportedPath(name, o.Paths) {
+			continue
+		}
+
+		switch e.Mode {
+		case filemode.Dir, filemode.Submodule:
+			err = o.Filesystem.MkdirAll(name, exportDirMode)
+		default:
+			err = r.exportFile(o.Filesystem, name, e, caps)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// exportTree returns the tree of the given commit, tag or tree.
+func (r *Repository) exportTree(h plumbing.Hash) (*object.Tree, error) {
+	obj, err := r.Object(plumbing.AnyObject, h)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch o := obj.(type) {
+		case *object.Tag:
+			if obj, err = o.Object(); err != nil {
+				return nil, err
+			}
+		case *object.Commit:
+			return o.Tree()
+		case *object.Tree:
+			return o, nil
+		default:
+			return nil, plumbing.ErrInvalidType
+		}
+	}
+}
+
+// exportFile writes the blob of the given entry to the filesystem.
+func (r *Repository) exportFile(fs billy.Filesystem, name string, e object.TreeEntry,
+	caps func() (FilesystemCapabilities, error)) error {
+
+	blob, err := object.GetBlob(r.Storer, e.Hash)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(fs, object.NewFile(name, e.Mode, blob), caps)
+}
+
+// exportCapabilities returns the capabilities of the filesystem, as declared
+// by the filesystem if it implements CapableFilesystem. Otherwise the
+// symbolic links are tried, and written as plain files if not supported.
+func exportCapabilities(fs billy.Filesystem) func() (FilesystemCapabilities, error) {
+	return func() (FilesystemCapabilities, error) {
+		if fs, ok := fs.(CapableFilesystem); ok {
+			return fs.WorktreeCapabilities(), nil
+		}
+
+		return FilesystemCapabilities{Symlinks: true}, nil
+	}
+}
+
+// exportedPath returns true if the given path is one of the paths, is inside
+// one of them or is a parent directory of one of them. All the paths are
+// exported if none is given.
+func exportedPath(name string, paths []string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+
+	for _, p := range paths {
+		p = strings.Trim(p, "/")
+		if p == "" || name == p ||
+			strings.HasPrefix(name, p+"/") || strings.HasPrefix(p, name+"/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,109 @@
+package git
+
+import (
+	"context"
+	"io/ioutil"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+)
+
+type ExportSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&ExportSuite{})
+
+func (s *ExportSuite) TestExport(c *C) {
+	fs := memfs.New()
+	err := s.Repository.Export(&ExportOptions{Filesystem: fs})
+	c.Assert(err, IsNil)
+
+	for _, name := range []string{
+		".gitignore", "CHANGELOG", "LICENSE", "binary.jpg",
+		"go/example.go", "json/long.json", "json/short.json",
+		"php/crappy.php", "vendor/foo.go",
+	} {
+		_, err := fs.Stat(name)
+		c.Assert(err, IsNil, Commentf("%s", name))
+	}
+
+	f, err := fs.Open("CHANGELOG")
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "Initial changelog\n")
+}
+
+func (s *ExportSuite) TestExportPaths(c *C) {
+	fs := memfs.New()
+	err := s.Repository.Export(&ExportOptions{
+		Filesystem: fs,
+		Paths:      []string{"json/", "LICENSE"},
+	})
+	c.Assert(err, IsNil)
+
+	files, err := fs.ReadDir("")
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 2)
+
+	files, err = fs.ReadDir("json")
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 2)
+
+	_, err = fs.Stat("go/example.go")
+	c.Assert(err, NotNil)
+}
+
+func (s *ExportSuite) TestExportHash(c *C) {
+	fs := memfs.New()
+	err := s.Repository.Export(&ExportOptions{
+		Filesystem: fs,
+		Hash:       plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d"),
+	})
+	c.Assert(err, IsNil)
+
+	_, err = fs.Stat(".gitignore")
+	c.Assert(err, IsNil)
+
+	_, err = fs.Stat("vendor/foo.go")
+	c.Assert(err, NotNil)
+}
+
+func (s *ExportSuite) TestExportKeepsWorktree(c *C) {
+	r := s.NewRepositoryWithEmptyWorktree(fixtures.Basic().One())
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	before, err := r.Storer.Index()
+	c.Assert(err, IsNil)
+
+	err = r.Export(&ExportOptions{Filesystem: memfs.New()})
+	c.Assert(err, IsNil)
+
+	files, err := w.Filesystem.ReadDir("")
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 0)
+
+	after, err := r.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(after.Entries, HasLen, len(before.Entries))
+}
+
+func (s *ExportSuite) TestExportContextCanceled(c *C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := s.Repository.ExportContext(ctx, &ExportOptions{Filesystem: memfs.New()})
+	c.Assert(err, Equals, context.Canceled)
+}
+
+func (s *ExportSuite) TestExportMissingTarget(c *C) {
+	err := s.Repository.Export(&ExportOptions{})
+	c.Assert(err, Equals, ErrMissingExportTarget)
+}
@@ -0,0 +1,247 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
+)
+
+var (
+	// ErrFSMonitorNotSupported is returned by NewHookFSMonitor when no
+	// fsmonitor hook is configured at core.fsmonitor, or when the worktree
+	// is not at the OS filesystem.
+	ErrFSMonitorNotSupported = errors.New("fsmonitor not supported by the repository")
+)
+
+// FSMonitor reports the files changed at the worktree since a previous
+// query, as the file system monitors used by git through core.fsmonitor.
+type FSMonitor interface {
+	// Changes returns the paths changed since the state identified by
+	// token, an empty token means no previous query.
+	Changes(token string) (*FSMonitorChanges, error)
+}
+
+// FSMonitorChanges are the changes reported by a FSMonitor.
+type FSMonitorChanges struct {
+	// Token identifies the state of the worktree when the changes were
+	// computed, it is passed to the next query.
+	Token string
+	// Paths are the changed files and directories, relative to the root of
+	// the worktree. A changed directory invalidates all its content.
+	Paths []string
+	// All means that any file may have changed, for example when the
+	// monitor is not able to compute the changes since the given token.
+	All bool
+}
+
+// HookFSMonitor is a FSMonitor running a program with the protocol of the
+// fsmonitor hooks of git, such as the fsmonitor-watchman sample hook, which
+// is queried first with version 2 of the protocol and with version 1 if
+// version 2 fails.
+type HookFSMonitor struct {
+	// Path is the program executed.
+	Path string
+	// Dir is the working directory of the program, the root of the
+	// worktree.
+	Dir string
+}
+
+// NewHookFSMonitor returns a HookFSMonitor running the program configured at
+// core.fsmonitor, a relative path is relative to the root of the worktree.
+// The built-in file system monitor daemon of git, configured as
+// core.fsmonitor=true, is not supported.
+func NewHookFSMonitor(r *Repository) (*HookFSMonitor, error) {
+	cfg, err := r.Storer.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	program := cfg.Core.FSMonitor
+	switch strings.ToLower(program) {
+	case "", "true", "false", "yes", "no", "on", "off", "1", "0":
+		return nil, ErrFSMonitorNotSupported
+	}
+
+	if r.wt == nil || !filepath.IsAbs(r.wt.Root()) {
+		return nil, ErrFSMonitorNotSupported
+	}
+
+	m := &HookFSMonitor{Path: program, Dir: r.wt.Root()}
+	if !filepath.IsAbs(program) {
+		m.Path = filepath.Join(m.Dir, program)
+	}
+
+	return m, nil
+}
+
+// Changes runs the hook, returning the changes it reports.
+func (m *HookFSMonitor) Changes(token string) (*FSMonitorChanges, error) {
+	out, err := m.run("2", token)
+	if err == nil {
+		return parseFSMonitorV2(out), nil
+	}
+
+	// version 1 receives a time in nanoseconds, the token is the time
+	// before running the hook
+	now := strconv.FormatInt(time.Now().UnixNano(), 10)
+	if _, err := strconv.ParseUint(token, 10, 64); err != nil {
+		token = "0"
+	}
+
+	out, err = m.run("1", token)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := parseFSMonitorV1(out)
+	ch.Token = now
+	ch.All = ch.All || token == "0"
+	return ch, nil
+}
+
+func (m *HookFSMonitor) run(version, token string) ([]byte, error) {
+	stdout := bytes.NewBuffer(nil)
+	stderr := bytes.NewBuffer(nil)
+
+	cmd := exec.Command(m.Path, version, token)
+	cmd.Dir = m.Dir
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("fsmonitor %s failed: %s: %s", m.Path, err, stderr.Bytes())
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// parseFSMonitorV1 parses the NUL separated paths of version 1 of the hook
+// protocol.
+func parseFSMonitorV1(out []byte) *FSMonitorChanges {
+	ch := &FSMonitorChanges{}
+	for _, p := range bytes.Split(out, []byte{0}) {
+		switch name := string(p); name {
+		case "":
+		case "/":
+			ch.All = true
+		default:
+			ch.Paths = append(ch.Paths, strings.TrimSuffix(name, "/"))
+		}
+	}
+
+	return ch
+}
+
+// parseFSMonitorV2 parses the output of version 2 of the hook protocol, the
+// new token followed by the paths, all of them NUL terminated.
+func parseFSMonitorV2(out []byte) *FSMonitorChanges {
+	i := bytes.IndexByte(out, 0)
+	if i == -1 {
+		return &FSMonitorChanges{Token: string(out), All: true}
+	}
+
+	ch := parseFSMonitorV1(out[i+1:])
+	ch.Token = string(out[:i])
+	return ch
+}
+
+// fsMonitor applies the changes reported by the FSMonitor of the repository to
+// the 'File System Monitor cache' extension of an index.
+type fsMonitor struct {
+	idx     *index.Index
+	entries map[string]*index.Entry
+	changes *FSMonitorChanges
+}
+
+// newFSMonitor queries the FSMonitor of the repository, clearing the
+// FSMonitorValid flag of the entries reported as changed. It returns nil if
+// the repository has no FSMonitor.
+func (w *Worktree) newFSMonitor(idx *index.Index) (*fsMonitor, error) {
+	if w.r.FSMonitor == nil {
+		return nil, nil
+	}
+
+	var token string
+	if idx.FSMonitor != nil {
+		token = idx.FSMonitor.Token
+	}
+
+	ch, err := w.r.FSMonitor.Changes(token)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &fsMonitor{
+		idx:     idx,
+		entries: make(map[string]*index.Entry, len(idx.Entries)),
+		changes: ch,
+	}
+
+	for _, e := range idx.Entries {
+		m.entries[e.Name] = e
+	}
+
+	if token == "" || ch.All {
+		for _, e := range idx.Entries {
+			e.FSMonitorValid = false
+		}
+
+		return m, nil
+	}
+
+	changed := make(map[string]bool, len(ch.Paths))
+	for _, p := range ch.Paths {
+		changed[p] = true
+	}
+
+	for _, e := range idx.Entries {
+		if !e.FSMonitorValid {
+			continue
+		}
+
+		for name := e.Name; name != "."; name = path.Dir(name) {
+			if changed[name] {
+				e.FSMonitorValid = false
+				break
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// hash returns the hash, as computed by the filesystem noder, of the files
+// not changed since they were cached at the index.
+func (m *fsMonitor) hash(name string) []byte {
+	e, ok := m.entries[name]
+	if !ok || !e.FSMonitorValid || e.IntentToAdd || e.Mode == filemode.Submodule {
+		return nil
+	}
+
+	return append(e.Hash[:], e.Mode.Bytes()...)
+}
+
+// update flags the entries without changes at the worktree as valid and saves
+// the token of the monitor at the index.
+func (m *fsMonitor) update(changes merkletrie.Changes) {
+	changed := make(map[string]bool, len(changes))
+	for _, ch := range changes {
+		changed[nameFromAction(&ch)] = true
+	}
+
+	for _, e := range m.idx.Entries {
+		e.FSMonitorValid = !changed[e.Name] && !e.IntentToAdd
+	}
+
+	m.idx.FSMonitor = &index.FSMonitor{Token: m.changes.Token}
+}
@@ -0,0 +1,224 @@
+package git
+
+import (
+	stdioutil "io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type FSMonitorSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&FSMonitorSuite{})
+
+type fsMonitorStub struct {
+	tokens  []string
+	changes FSMonitorChanges
+	queries int
+}
+
+func (m *fsMonitorStub) Changes(token string) (*FSMonitorChanges, error) {
+	m.tokens = append(m.tokens, token)
+	m.queries++
+
+	ch := m.changes
+	ch.Token = strconv.Itoa(m.queries)
+	m.changes = FSMonitorChanges{}
+	return &ch, nil
+}
+
+func (s *FSMonitorSuite) newWorktree(c *C) (*Worktree, *fsMonitorStub) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	m := &fsMonitorStub{}
+	r.FSMonitor = m
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	for _, name := range []string{"foo", "qux/bar"} {
+		err := util.WriteFile(w.Filesystem, name, []byte(name), 0644)
+		c.Assert(err, IsNil)
+
+		_, err = w.Add(name)
+		c.Assert(err, IsNil)
+	}
+
+	return w, m
+}
+
+func (s *FSMonitorSuite) TestStatusSavesToken(c *C) {
+	w, m := s.newWorktree(c)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, false)
+
+	idx, err := w.r.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.FSMonitor, NotNil)
+	c.Assert(idx.FSMonitor.Token, Equals, strconv.Itoa(m.queries))
+	for _, e := range idx.Entries {
+		c.Assert(e.FSMonitorValid, Equals, true)
+	}
+
+	token := idx.FSMonitor.Token
+	_, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(m.tokens[len(m.tokens)-1], Equals, token)
+}
+
+func (s *FSMonitorSuite) TestStatusSkipsUnchangedFiles(c *C) {
+	w, m := s.newWorktree(c)
+
+	_, err := w.Status()
+	c.Assert(err, IsNil)
+
+	// the monitor is trusted, a change not reported is not noticed
+	err = util.WriteFile(w.Filesystem, "foo", []byte("bar"), 0644)
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Unmodified)
+
+	m.changes = FSMonitorChanges{Paths: []string{"foo"}}
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Modified)
+
+	idx, err := w.r.Storer.Index()
+	c.Assert(err, IsNil)
+	e, err := idx.Entry("foo")
+	c.Assert(err, IsNil)
+	c.Assert(e.FSMonitorValid, Equals, false)
+
+	// the entry stays invalid while modified
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Modified)
+}
+
+func (s *FSMonitorSuite) TestStatusChangedDirectory(c *C) {
+	w, m := s.newWorktree(c)
+
+	_, err := w.Status()
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "qux/bar", []byte("foo"), 0644)
+	c.Assert(err, IsNil)
+
+	m.changes = FSMonitorChanges{Paths: []string{"qux"}}
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("qux/bar").Worktree, Equals, Modified)
+}
+
+func (s *FSMonitorSuite) TestStatusAllChanged(c *C) {
+	w, m := s.newWorktree(c)
+
+	_, err := w.Status()
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "foo", []byte("bar"), 0644)
+	c.Assert(err, IsNil)
+
+	m.changes = FSMonitorChanges{All: true}
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Modified)
+}
+
+func (s *FSMonitorSuite) TestNewHookFSMonitorNotSupported(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	_, err = NewHookFSMonitor(r)
+	c.Assert(err, Equals, ErrFSMonitorNotSupported)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Core.FSMonitor = "true"
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	_, err = NewHookFSMonitor(r)
+	c.Assert(err, Equals, ErrFSMonitorNotSupported)
+}
+
+func (s *FSMonitorSuite) TestHookFSMonitor(c *C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("hook scripts are not supported on windows")
+	}
+
+	dir := c.MkDir()
+	r, err := PlainInit(dir, false)
+	c.Assert(err, IsNil)
+
+	hooks := filepath.Join(dir, ".git", "hooks")
+	s.writeScript(c, hooks, "query", `echo "$1 $2" >> `+filepath.Join(dir, "queries")+`
+printf 'next\0foo\0qux/\0'
+`)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Core.FSMonitor = ".git/hooks/query"
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	m, err := NewHookFSMonitor(r)
+	c.Assert(err, IsNil)
+	c.Assert(m.Path, Equals, filepath.Join(hooks, "query"))
+	c.Assert(m.Dir, Equals, dir)
+
+	ch, err := m.Changes("last")
+	c.Assert(err, IsNil)
+	c.Assert(ch, DeepEquals, &FSMonitorChanges{
+		Token: "next",
+		Paths: []string{"foo", "qux"},
+	})
+
+	queries, err := stdioutil.ReadFile(filepath.Join(dir, "queries"))
+	c.Assert(err, IsNil)
+	c.Assert(string(queries), Equals, "2 last\n")
+}
+
+func (s *FSMonitorSuite) TestHookFSMonitorVersion1(c *C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("hook scripts are not supported on windows")
+	}
+
+	dir := c.MkDir()
+	s.writeScript(c, dir, "query", `[ "$1" = 1 ] || exit 1
+printf 'foo\0'
+`)
+
+	m := &HookFSMonitor{Path: filepath.Join(dir, "query"), Dir: dir}
+	ch, err := m.Changes("")
+	c.Assert(err, IsNil)
+	c.Assert(ch.All, Equals, true)
+
+	_, err = strconv.ParseInt(ch.Token, 10, 64)
+	c.Assert(err, IsNil)
+
+	ch, err = m.Changes(ch.Token)
+	c.Assert(err, IsNil)
+	c.Assert(ch.All, Equals, false)
+	c.Assert(ch.Paths, DeepEquals, []string{"foo"})
+}
+
+func (s *FSMonitorSuite) writeScript(c *C, dir, name, script string) {
+	err := os.MkdirAll(dir, 0755)
+	c.Assert(err, IsNil)
+
+	err = stdioutil.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755)
+	c.Assert(err, IsNil)
+}
@@ -0,0 +1,437 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	stdioutil "io/ioutil"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/storage"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+// Names of the hooks invoked by go-git.
+const (
+	PreCommitHook    = "pre-commit"
+	CommitMsgHook    = "commit-msg"
+	PrePushHook      = "pre-push"
+	PostCheckoutHook = "post-checkout"
+	PostMergeHook    = "post-merge"
+	PreAutoGCHook    = "pre-auto-gc"
+)
+
+const (
+	hooksDirName      = "hooks"
+	commitEditMsgFile = "COMMIT_EDITMSG"
+)
+
+var (
+	ErrHooksNotSupported = errors.New("hooks not supported by the repository storage")
+)
+
+// HookError is returned when a hook exits with a non-zero status.
+type HookError struct {
+	// Name of the failed hook.
+	Name string
+	// Output is the combined stdout and stderr of the hook, if any.
+	Output []byte
+	// Err is the error returned by the hook execution.
+	Err error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("%s hook failed: %s", e.Name, e.Err)
+}
+
+// Hooks contains Go callbacks invoked at the same points as the equivalent
+// git hooks, independently of the HookRunner. Nil callbacks are ignored.
+type Hooks struct {
+	// PreCommit is called before the commit is created, a non-nil error
+	// aborts the commit.
+	PreCommit func(w *Worktree, msg string, opts *CommitOptions) error
+	// PostCommit is called once the commit is created and HEAD updated, the
+	// returned error is returned by Commit but doesn't revert the commit.
+	PostCommit func(w *Worktree, commit plumbing.Hash) error
+	// PrePush is called with the reference updates to be sent to the remote,
+	// before the packfile is generated, a non-nil error aborts the push.
+	PrePush func(r *Remote, updates []*RefUpdate) error
+	// PostCheckout is called once the checkout is completed, the returned
+	// error is returned by Checkout.
+	PostCheckout func(w *Worktree, old, new plumbing.Hash) error
+	// PreAutoGC is called before the automatic gc, see Repository.AutoGC, a
+	// non-nil error vetoes it.
+	PreAutoGC func(r *Repository) error
+}
+
+// RefUpdate describes the update of a remote reference during a push.
+type RefUpdate struct {
+	// Local is the name of the local reference being pushed, empty when the
+	// remote reference is deleted.
+	Local plumbing.ReferenceName
+	// Remote is the name of the remote reference being updated.
+	Remote plumbing.ReferenceName
+	// Old is the current hash of the remote reference, zero if it doesn't
+	// exist.
+	Old plumbing.Hash
+	// New is the hash the remote reference is updated to, zero if deleted.
+	New plumbing.Hash
+}
+
+// HookRunner runs the hook called name with the given arguments and standard
+// input, the arguments and input follow the ones described at githooks(5).
+// A missing hook is not an error.
+type HookRunner interface {
+	RunHook(name string, args []string, stdin io.Reader) error
+}
+
+// HookFunc is a Go implementation of a hook, it receives the same arguments
+// and standard input as the hook program would.
+type HookFunc func(args []string, stdin io.Reader) error
+
+// ScriptHookRunner is a HookRunner executing the programs found at a hooks
+// directory, as git does.
+type ScriptHookRunner struct {
+	// Path is the directory where the hooks are looked up.
+	Path string
+	// Dir is the working directory of the executed hooks.
+	Dir string
+	// Env contains additional environment variables for the hooks, in the
+	// form "key=value".
+	Env []string
+	// Output is where the combined stdout and stderr of the hooks is
+	// written, if nil the output is only reported on failure.
+	Output io.Writer
+	// Funcs replaces the program of a hook with a Go callback, the key is the
+	// hook name. A nil HookFunc disables the hook.
+	Funcs map[string]HookFunc
+}
+
+// NewScriptHookRunner returns a ScriptHookRunner for the given repository, the
+// hooks are looked up at the directory configured in core.hooksPath or at the
+// `hooks` directory of the git directory. As in git, core.hooksPath is also
+// read from the config of the user and of the system for the repositories at
+// the OS filesystem, and a relative path is relative to the worktree.
+func NewScriptHookRunner(r *Repository) (*ScriptHookRunner, error) {
+	scope := config.LocalScope
+	if isOSRepository(r) {
+		scope = config.SystemScope
+	}
+
+	cfg, err := r.ConfigScoped(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &ScriptHookRunner{}
+
+	dot, isFSBased := storerFilesystem(r)
+	if isFSBased {
+		h.Dir = dot.Root()
+		h.Path = filepath.Join(dot.Root(), hooksDirName)
+		h.Env = []string{"GIT_DIR=" + dot.Root()}
+	}
+
+	if r.wt != nil {
+		h.Dir = r.wt.Root()
+	}
+
+	if path := cfg.Core.HooksPath; path != "" {
+		if strings.HasPrefix(path, "~/") {
+			usr, err := user.Current()
+			if err != nil {
+				return nil, err
+			}
+
+			path = filepath.Join(usr.HomeDir, path[2:])
+		}
+
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(h.Dir, path)
+		}
+
+		h.Path = path
+	}
+
+	if h.Path == "" || !filepath.IsAbs(h.Path) {
+		return nil, ErrHooksNotSupported
+	}
+
+	return h, nil
+}
+
+// RunHook runs the hook with the given name, if it exists and is executable.
+func (h *ScriptHookRunner) RunHook(name string, args []string, stdin io.Reader) error {
+	if fn, ok := h.Funcs[name]; ok {
+		if fn == nil {
+			return nil
+		}
+
+		return fn(args, stdin)
+	}
+
+	path := filepath.Join(h.Path, name)
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if fi.IsDir() || (runtime.GOOS != "windows" && fi.Mode()&0111 == 0) {
+		return nil
+	}
+
+	buf := bytes.NewBuffer(nil)
+	var out io.Writer = buf
+	if h.Output != nil {
+		out = io.MultiWriter(buf, h.Output)
+	}
+
+	cmd := exec.Command(path, args...)
+	cmd.Dir = h.Dir
+	cmd.Env = append(os.Environ(), h.Env...)
+	cmd.Stdin = stdin
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	if err := cmd.Run(); err != nil {
+		return &HookError{Name: name, Output: buf.Bytes(), Err: err}
+	}
+
+	return nil
+}
+
+func storerFilesystem(r *Repository) (billy.Filesystem, bool) {
+	return storageFilesystem(r.Storer)
+}
+
+func storageFilesystem(s storage.Storer) (billy.Filesystem, bool) {
+	type fsBased interface {
+		Filesystem() billy.Filesystem
+	}
+
+	fs, isFSBased := s.(fsBased)
+	if !isFSBased {
+		return nil, false
+	}
+
+	return fs.Filesystem(), true
+}
+
+func runHook(h HookRunner, name string, args []string, stdin io.Reader) error {
+	if h == nil {
+		return nil
+	}
+
+	return h.RunHook(name, args, stdin)
+}
+
+// runPreCommitHooks runs the PreCommit callback and, unless NoVerify is set,
+// the pre-commit and commit-msg hooks, returning the commit message as left
+// by the commit-msg hook.
+func (w *Worktree) runPreCommitHooks(msg string, opts *CommitOptions) (string, error) {
+	if fn := w.r.Hooks.PreCommit; fn != nil {
+		if err := fn(w, msg, opts); err != nil {
+			return msg, err
+		}
+	}
+
+	if opts.NoVerify {
+		return msg, nil
+	}
+
+	if err := runHook(w.r.HookRunner, PreCommitHook, nil, nil); err != nil {
+		return msg, err
+	}
+
+	return w.runCommitMsgHook(msg)
+}
+
+// runCommitMsgHook runs the commit-msg hook, the message is written to
+// COMMIT_EDITMSG and read back, since the hook is allowed to edit it.
+func (w *Worktree) runCommitMsgHook(msg string) (string, error) {
+	if w.r.HookRunner == nil {
+		return msg, nil
+	}
+
+	dot, isFSBased := storerFilesystem(w.r)
+	if !isFSBased {
+		return msg, nil
+	}
+
+	if err := util.WriteFile(dot, commitEditMsgFile, []byte(msg), 0644); err != nil {
+		return msg, err
+	}
+
+	path := filepath.Join(dot.Root(), commitEditMsgFile)
+	if err := w.r.HookRunner.RunHook(CommitMsgHook, []string{path}, nil); err != nil {
+		return msg, err
+	}
+
+	content, err := readFile(dot, commitEditMsgFile)
+	if err != nil {
+		return msg, err
+	}
+
+	return string(content), nil
+}
+
+func (w *Worktree) runPostCommitHook(commit plumbing.Hash) error {
+	if fn := w.r.Hooks.PostCommit; fn != nil {
+		return fn(w, commit)
+	}
+
+	return nil
+}
+
+func (w *Worktree) runPostCheckoutHook(old, new plumbing.Hash) error {
+	if fn := w.r.Hooks.PostCheckout; fn != nil {
+		if err := fn(w, old, new); err != nil {
+			return err
+		}
+	}
+
+	return runHook(w.r.HookRunner, PostCheckoutHook,
+		[]string{old.String(), new.String(), "1"}, nil,
+	)
+}
+
+func (w *Worktree) runPostMergeHook() error {
+	return runHook(w.r.HookRunner, PostMergeHook, []string{"0"}, nil)
+}
+
+// runPrePushHook runs the PrePush callback and, unless NoVerify is set, the
+// pre-push hook for the push to url, the ref updates are sent to the standard
+// input of the hook in the form:
+// <local ref> SP <local sha1> SP <remote ref> SP <remote sha1> LF
+func (r *Remote) runPrePushHook(
+	url string,
+	o *PushOptions,
+	localRefs []*plumbing.Reference,
+	req *packp.ReferenceUpdateRequest,
+) error {
+	hooks := r.hooks
+	if o.NoVerify {
+		hooks = nil
+	}
+
+	if hooks == nil && (r.callbacks == nil || r.callbacks.PrePush == nil) {
+		return nil
+	}
+
+	updates := refUpdatesFromCommands(o, localRefs, req.Commands)
+	if r.callbacks != nil && r.callbacks.PrePush != nil {
+		if err := r.callbacks.PrePush(r, updates); err != nil {
+			return err
+		}
+	}
+
+	if hooks == nil {
+		return nil
+	}
+
+	stdin := bytes.NewBuffer(nil)
+	for _, u := range updates {
+		local := u.Local.String()
+		if u.New.IsZero() {
+			local = "(delete)"
+		}
+
+		fmt.Fprintf(stdin, "%s %s %s %s\n", local, u.New, u.Remote, u.Old)
+	}
+
+	return hooks.RunHook(PrePushHook, []string{r.c.Name, url}, stdin)
+}
+
+// runPushPrePush runs the PrePush callback of the PushOptions, replacing the
+// commands of req by the updates it returns.
+func runPushPrePush(
+	o *PushOptions,
+	localRefs []*plumbing.Reference,
+	req *packp.ReferenceUpdateRequest,
+) error {
+	updates, err := o.PrePush(refUpdatesFromCommands(o, localRefs, req.Commands))
+	if err != nil {
+		return err
+	}
+
+	commands := make([]*packp.Command, 0, len(updates))
+	for _, u := range updates {
+		cmd := &packp.Command{Name: u.Remote, Old: u.Old, New: u.New}
+		if cmd.Name == "" || cmd.Action() == packp.Invalid {
+			return packp.ErrMalformedCommand
+		}
+
+		commands = append(commands, cmd)
+	}
+
+	req.Commands = commands
+	return nil
+}
+
+func refUpdatesFromCommands(
+	o *PushOptions,
+	localRefs []*plumbing.Reference,
+	commands []*packp.Command,
+) []*RefUpdate {
+	updates := make([]*RefUpdate, len(commands))
+	for i, cmd := range commands {
+		u := &RefUpdate{Remote: cmd.Name, Old: cmd.Old, New: cmd.New}
+		if cmd.Action() != packp.Delete {
+			u.Local = localReferenceForCommand(o, localRefs, cmd)
+		}
+
+		updates[i] = u
+	}
+
+	return updates
+}
+
+func localReferenceForCommand(
+	o *PushOptions,
+	localRefs []*plumbing.Reference,
+	cmd *packp.Command,
+) plumbing.ReferenceName {
+	for _, rs := range o.RefSpecs {
+		if rs.IsDelete() {
+			continue
+		}
+
+		for _, ref := range localRefs {
+			if ref.Type() != plumbing.HashReference || ref.Hash() != cmd.New {
+				continue
+			}
+
+			if rs.Match(ref.Name()) && rs.Dst(ref.Name()) == cmd.Name {
+				return ref.Name()
+			}
+		}
+	}
+
+	return cmd.Name
+}
+
+func readFile(fs billy.Filesystem, name string) (b []byte, err error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	return stdioutil.ReadAll(f)
+}
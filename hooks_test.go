@@ -0,0 +1,460 @@
+package git
+
+import (
+	"io"
+	stdioutil "io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type HooksSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&HooksSuite{})
+
+func (s *HooksSuite) SetUpTest(c *C) {
+	if runtime.GOOS == "windows" {
+		c.Skip("hook scripts are not supported on windows")
+	}
+}
+
+func (s *HooksSuite) newRepository(c *C) (*Repository, string) {
+	dir := c.MkDir()
+	r, err := PlainInit(dir, false)
+	c.Assert(err, IsNil)
+
+	r.HookRunner, err = NewScriptHookRunner(r)
+	c.Assert(err, IsNil)
+
+	return r, dir
+}
+
+func (s *HooksSuite) writeHook(c *C, dir, name, script string) {
+	err := os.MkdirAll(dir, 0755)
+	c.Assert(err, IsNil)
+
+	err = stdioutil.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755)
+	c.Assert(err, IsNil)
+}
+
+func commitTestFile(c *C, w *Worktree, opts *CommitOptions) (plumbing.Hash, error) {
+	err := util.WriteFile(w.Filesystem, "foo", []byte("foo"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	if opts == nil {
+		opts = &CommitOptions{}
+	}
+
+	opts.Author = defaultSignature()
+	return w.Commit("foo\n", opts)
+}
+
+func (s *HooksSuite) TestNewScriptHookRunner(c *C) {
+	r, dir := s.newRepository(c)
+
+	h := r.HookRunner.(*ScriptHookRunner)
+	c.Assert(h.Path, Equals, filepath.Join(dir, GitDirName, hooksDirName))
+	c.Assert(h.Dir, Equals, dir)
+}
+
+func (s *HooksSuite) TestNewScriptHookRunnerHooksPath(c *C) {
+	r, dir := s.newRepository(c)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Core.HooksPath = ".githooks"
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	h, err := NewScriptHookRunner(r)
+	c.Assert(err, IsNil)
+	c.Assert(h.Path, Equals, filepath.Join(dir, ".githooks"))
+}
+
+func (s *HooksSuite) TestNewScriptHookRunnerGlobalHooksPath(c *C) {
+	hooks := c.MkDir()
+	global := filepath.Join(c.MkDir(), "gitconfig")
+	err := stdioutil.WriteFile(global, []byte("[core]\n\thooksPath = "+hooks+"\n"), 0644)
+	c.Assert(err, IsNil)
+
+	defer os.Setenv("GIT_CONFIG_GLOBAL", os.Getenv("GIT_CONFIG_GLOBAL"))
+	defer os.Setenv("GIT_CONFIG_NOSYSTEM", os.Getenv("GIT_CONFIG_NOSYSTEM"))
+	os.Setenv("GIT_CONFIG_GLOBAL", global)
+	os.Setenv("GIT_CONFIG_NOSYSTEM", "true")
+
+	_, dir := s.newRepository(c)
+	r, err := PlainOpen(dir)
+	c.Assert(err, IsNil)
+
+	h, err := NewScriptHookRunner(r)
+	c.Assert(err, IsNil)
+	c.Assert(h.Path, Equals, hooks)
+}
+
+func (s *HooksSuite) TestNewScriptHookRunnerNotSupported(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	_, err = NewScriptHookRunner(r)
+	c.Assert(err, Equals, ErrHooksNotSupported)
+}
+
+func (s *HooksSuite) TestPreCommitHookFails(c *C) {
+	r, dir := s.newRepository(c)
+	s.writeHook(c, filepath.Join(dir, GitDirName, hooksDirName), PreCommitHook, "echo rejected; exit 1\n")
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	_, err = commitTestFile(c, w, nil)
+	c.Assert(err, NotNil)
+
+	herr, ok := err.(*HookError)
+	c.Assert(ok, Equals, true)
+	c.Assert(herr.Name, Equals, PreCommitHook)
+	c.Assert(string(herr.Output), Equals, "rejected\n")
+
+	_, err = r.Head()
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *HooksSuite) TestPreCommitHookNoVerify(c *C) {
+	r, dir := s.newRepository(c)
+	s.writeHook(c, filepath.Join(dir, GitDirName, hooksDirName), PreCommitHook, "exit 1\n")
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	_, err = commitTestFile(c, w, &CommitOptions{NoVerify: true})
+	c.Assert(err, IsNil)
+}
+
+func (s *HooksSuite) TestCommitMsgHook(c *C) {
+	r, dir := s.newRepository(c)
+	s.writeHook(c, filepath.Join(dir, GitDirName, hooksDirName), CommitMsgHook, "echo bar >> \"$1\"\n")
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	hash, err := commitTestFile(c, w, nil)
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(hash)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "foo\nbar\n")
+}
+
+func (s *HooksSuite) TestHookFuncs(c *C) {
+	r, dir := s.newRepository(c)
+	s.writeHook(c, filepath.Join(dir, GitDirName, hooksDirName), PreCommitHook, "exit 1\n")
+
+	var called bool
+	h := r.HookRunner.(*ScriptHookRunner)
+	h.Funcs = map[string]HookFunc{
+		PreCommitHook: func(args []string, stdin io.Reader) error {
+			called = true
+			return nil
+		},
+		CommitMsgHook: nil,
+	}
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	_, err = commitTestFile(c, w, nil)
+	c.Assert(err, IsNil)
+	c.Assert(called, Equals, true)
+}
+
+func (s *HooksSuite) TestPostCheckoutHook(c *C) {
+	r, dir := s.newRepository(c)
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	first, err := commitTestFile(c, w, nil)
+	c.Assert(err, IsNil)
+
+	var args []string
+	r.HookRunner.(*ScriptHookRunner).Funcs = map[string]HookFunc{
+		PostCheckoutHook: func(a []string, stdin io.Reader) error {
+			args = a
+			return nil
+		},
+	}
+
+	err = w.Checkout(&CheckoutOptions{
+		Branch: plumbing.ReferenceName("refs/heads/foo"),
+		Create: true,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(args, DeepEquals, []string{first.String(), first.String(), "1"})
+
+	s.writeHook(c, filepath.Join(dir, GitDirName, hooksDirName), PostCheckoutHook, "exit 1\n")
+	r.HookRunner.(*ScriptHookRunner).Funcs = nil
+
+	err = w.Checkout(&CheckoutOptions{Branch: plumbing.Master})
+	c.Assert(err, FitsTypeOf, &HookError{})
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, plumbing.Master)
+}
+
+func (s *HooksSuite) TestPrePushHook(c *C) {
+	url := c.MkDir()
+	_, err := PlainInit(url, true)
+	c.Assert(err, IsNil)
+
+	r, _ := s.newRepository(c)
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	hash, err := commitTestFile(c, w, nil)
+	c.Assert(err, IsNil)
+
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{url},
+	})
+	c.Assert(err, IsNil)
+
+	var args []string
+	var input []byte
+	r.HookRunner.(*ScriptHookRunner).Funcs = map[string]HookFunc{
+		PrePushHook: func(a []string, stdin io.Reader) error {
+			args = a
+			input, err = stdioutil.ReadAll(stdin)
+			c.Assert(err, IsNil)
+			return io.ErrUnexpectedEOF
+		},
+	}
+
+	err = r.Push(&PushOptions{})
+	c.Assert(err, Equals, io.ErrUnexpectedEOF)
+	c.Assert(args, DeepEquals, []string{DefaultRemoteName, url})
+	c.Assert(string(input), Equals,
+		"refs/heads/master "+hash.String()+" refs/heads/master "+plumbing.ZeroHash.String()+"\n",
+	)
+
+	err = r.Push(&PushOptions{NoVerify: true})
+	c.Assert(err, IsNil)
+}
+
+type CallbackHooksSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&CallbackHooksSuite{})
+
+func (s *CallbackHooksSuite) TestPreCommit(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	var msg string
+	r.Hooks.PreCommit = func(w *Worktree, m string, opts *CommitOptions) error {
+		msg = m
+		return ErrWorktreeNotClean
+	}
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	_, err = commitTestFile(c, w, nil)
+	c.Assert(err, Equals, ErrWorktreeNotClean)
+	c.Assert(msg, Equals, "foo\n")
+
+	_, err = r.Head()
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	// NoVerify only bypasses the hook scripts
+	msg = ""
+	_, err = commitTestFile(c, w, &CommitOptions{NoVerify: true})
+	c.Assert(err, Equals, ErrWorktreeNotClean)
+	c.Assert(msg, Equals, "foo\n")
+
+	r.Hooks.PreCommit = nil
+	_, err = commitTestFile(c, w, &CommitOptions{NoVerify: true})
+	c.Assert(err, IsNil)
+}
+
+func (s *CallbackHooksSuite) TestPostCommit(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	var commit plumbing.Hash
+	r.Hooks.PostCommit = func(w *Worktree, h plumbing.Hash) error {
+		commit = h
+		return nil
+	}
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	hash, err := commitTestFile(c, w, nil)
+	c.Assert(err, IsNil)
+	c.Assert(commit, Equals, hash)
+}
+
+func (s *CallbackHooksSuite) TestPostCheckout(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	hash, err := commitTestFile(c, w, nil)
+	c.Assert(err, IsNil)
+
+	var old, new plumbing.Hash
+	r.Hooks.PostCheckout = func(w *Worktree, o, n plumbing.Hash) error {
+		old, new = o, n
+		return nil
+	}
+
+	err = w.Checkout(&CheckoutOptions{Hash: hash})
+	c.Assert(err, IsNil)
+	c.Assert(old, Equals, hash)
+	c.Assert(new, Equals, hash)
+}
+
+func (s *CallbackHooksSuite) TestPrePush(c *C) {
+	url := c.MkDir()
+	_, err := PlainInit(url, true)
+	c.Assert(err, IsNil)
+
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	hash, err := commitTestFile(c, w, nil)
+	c.Assert(err, IsNil)
+
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{url},
+	})
+	c.Assert(err, IsNil)
+
+	var updates []*RefUpdate
+	r.Hooks.PrePush = func(remote *Remote, u []*RefUpdate) error {
+		updates = u
+		return ErrForceNeeded
+	}
+
+	err = r.Push(&PushOptions{})
+	c.Assert(err, Equals, ErrForceNeeded)
+	c.Assert(updates, DeepEquals, []*RefUpdate{{
+		Local:  plumbing.Master,
+		Remote: plumbing.Master,
+		Old:    plumbing.ZeroHash,
+		New:    hash,
+	}})
+
+	// NoVerify only bypasses the pre-push hook script
+	updates = nil
+	err = r.Push(&PushOptions{NoVerify: true})
+	c.Assert(err, Equals, ErrForceNeeded)
+	c.Assert(updates, HasLen, 1)
+}
+
+func (s *CallbackHooksSuite) TestPushOptionsPrePush(c *C) {
+	url := c.MkDir()
+	server, err := PlainInit(url, true)
+	c.Assert(err, IsNil)
+
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	hash, err := commitTestFile(c, w, nil)
+	c.Assert(err, IsNil)
+
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{url},
+	})
+	c.Assert(err, IsNil)
+
+	var updates []*RefUpdate
+	err = r.Push(&PushOptions{PrePush: func(u []*RefUpdate) ([]*RefUpdate, error) {
+		updates = u
+		return nil, ErrForceNeeded
+	}})
+	c.Assert(err, Equals, ErrForceNeeded)
+	c.Assert(updates, DeepEquals, []*RefUpdate{{
+		Local:  plumbing.Master,
+		Remote: plumbing.Master,
+		Old:    plumbing.ZeroHash,
+		New:    hash,
+	}})
+
+	err = r.Push(&PushOptions{PrePush: func(u []*RefUpdate) ([]*RefUpdate, error) {
+		return nil, nil
+	}})
+	c.Assert(err, Equals, NoErrAlreadyUpToDate)
+
+	var hooked []*RefUpdate
+	r.Hooks.PrePush = func(remote *Remote, u []*RefUpdate) error {
+		hooked = u
+		return nil
+	}
+
+	err = r.Push(&PushOptions{PrePush: func(u []*RefUpdate) ([]*RefUpdate, error) {
+		u[0].Remote = "refs/heads/other"
+		return u, nil
+	}})
+	c.Assert(err, IsNil)
+	c.Assert(hooked, HasLen, 1)
+	c.Assert(hooked[0].Remote, Equals, plumbing.ReferenceName("refs/heads/other"))
+
+	ref, err := server.Reference("refs/heads/other", false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, hash)
+
+	_, err = server.Reference(plumbing.Master, false)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *CallbackHooksSuite) TestPushOptionsPrePushMalformed(c *C) {
+	url := c.MkDir()
+	_, err := PlainInit(url, true)
+	c.Assert(err, IsNil)
+
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	_, err = commitTestFile(c, w, nil)
+	c.Assert(err, IsNil)
+
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{url},
+	})
+	c.Assert(err, IsNil)
+
+	err = r.Push(&PushOptions{PrePush: func(u []*RefUpdate) ([]*RefUpdate, error) {
+		u[0].New = plumbing.ZeroHash
+		return u, nil
+	}})
+	c.Assert(err, Equals, packp.ErrMalformedCommand)
+}
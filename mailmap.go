@@ -0,0 +1,162 @@
+package git
+
+import (
+	"bytes"
+	stdioutil "io/ioutil"
+	"os"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/mailmap"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+const (
+	mailmapFile        = ".mailmap"
+	defaultMailmapBlob = "HEAD:" + mailmapFile
+)
+
+// Mailmap returns the mailmap of the repository, as git does: the .mailmap
+// file at the root of the worktree, the blob at mailmap.blob, HEAD:.mailmap
+// by default in the bare repositories, and the file at mailmap.file, every
+// one overriding the mappings of the previous ones. The missing files are
+// skipped.
+func (r *Repository) Mailmap() (*mailmap.Mailmap, error) {
+	m := mailmap.New()
+	if r.wt != nil {
+		if err := readMailmap(m, r.readConfigPath, mailmapFile); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg, err := r.userConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	blob := cfg.Mailmap.Blob
+	if blob == "" && r.wt == nil {
+		blob = defaultMailmapBlob
+	}
+
+	if blob != "" {
+		if err := readMailmap(m, r.readRevisionBlob, blob); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Mailmap.File != "" {
+		if err := readMailmap(m, r.readConfigPath, cfg.Mailmap.File); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+func readMailmap(m *mailmap.Mailmap, read func(string) ([]byte, error), name string) error {
+	content, err := read(name)
+	if os.IsNotExist(err) || err == plumbing.ErrObjectNotFound || err == plumbing.ErrReferenceNotFound ||
+		err == object.ErrFileNotFound || err == object.ErrDirectoryNotFound {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return m.Read(bytes.NewReader(content))
+}
+
+// readRevisionBlob reads the blob given as <revision>:<path>, or by its hash.
+func (r *Repository) readRevisionBlob(name string) ([]byte, error) {
+	i := strings.LastIndexByte(name, ':')
+	if i == -1 {
+		blob, err := r.BlobObject(plumbing.NewHash(name))
+		if err != nil {
+			return nil, err
+		}
+
+		return readBlob(blob)
+	}
+
+	h, err := r.ResolveRevision(plumbing.Revision(name[:i]))
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := r.CommitObject(*h)
+	if err != nil {
+		return nil, err
+	}
+
+	return readCommitFile(commit, name[i+1:])
+}
+
+func readCommitFile(c *object.Commit, name string) ([]byte, error) {
+	f, err := c.File(name)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := f.Contents()
+	return []byte(content), err
+}
+
+func readBlob(b *object.Blob) (content []byte, err error) {
+	rd, err := b.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(rd, &err)
+	return stdioutil.ReadAll(rd)
+}
+
+// commitMailmap returns the mailmap of the .mailmap file of the commit, empty
+// if the commit has none.
+func commitMailmap(c *object.Commit) (*mailmap.Mailmap, error) {
+	m := mailmap.New()
+	err := readMailmap(m, func(name string) ([]byte, error) {
+		return readCommitFile(c, name)
+	}, mailmapFile)
+
+	return m, err
+}
+
+// mailmapSignature returns the signature with its canonical name and email.
+func mailmapSignature(m *mailmap.Mailmap, s object.Signature) object.Signature {
+	s.Name, s.Email = m.Map(s.Name, s.Email)
+	return s
+}
+
+// mailmapCommit returns a copy of the commit with the canonical identities of
+// its author and committer.
+func mailmapCommit(m *mailmap.Mailmap, c *object.Commit) *object.Commit {
+	mapped := *c
+	mapped.Author = mailmapSignature(m, c.Author)
+	mapped.Committer = mailmapSignature(m, c.Committer)
+	return &mapped
+}
+
+// mailmapCommitIter maps the identities of the commits, as mailmapCommit does.
+type mailmapCommitIter struct {
+	m *mailmap.Mailmap
+	object.CommitIter
+}
+
+func (iter *mailmapCommitIter) Next() (*object.Commit, error) {
+	c, err := iter.CommitIter.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	return mailmapCommit(iter.m, c), nil
+}
+
+func (iter *mailmapCommitIter) ForEach(cb func(*object.Commit) error) error {
+	return iter.CommitIter.ForEach(func(c *object.Commit) error {
+		return cb(mailmapCommit(iter.m, c))
+	})
+}
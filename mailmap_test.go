@@ -0,0 +1,137 @@
+package git
+
+import (
+	"context"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type MailmapSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&MailmapSuite{})
+
+// repository creates a repository with a commit of foo by foo <foo@foo.foo>,
+// mapped to Foo Bar <foo@bar.bar> by its .mailmap.
+func (s *MailmapSuite) repository(c *C) (*Repository, *Worktree, plumbing.Hash) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	c.Assert(util.WriteFile(fs, "foo", []byte("foo\n"), 0644), IsNil)
+	c.Assert(util.WriteFile(fs, ".mailmap", []byte("Foo Bar <foo@bar.bar> <foo@foo.foo>\n"), 0644), IsNil)
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+	_, err = w.Add(".mailmap")
+	c.Assert(err, IsNil)
+
+	h, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+	return r, w, h
+}
+
+func (s *MailmapSuite) TestMailmap(c *C) {
+	r, w, _ := s.repository(c)
+
+	m, err := r.Mailmap()
+	c.Assert(err, IsNil)
+	name, email := m.Map("foo", "foo@foo.foo")
+	c.Assert(name, Equals, "Foo Bar")
+	c.Assert(email, Equals, "foo@bar.bar")
+
+	c.Assert(util.WriteFile(w.Filesystem, "mailmap", []byte("Qux <foo@foo.foo>\n"), 0644), IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Mailmap.File = "mailmap"
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	m, err = r.Mailmap()
+	c.Assert(err, IsNil)
+	name, email = m.Map("foo", "foo@foo.foo")
+	c.Assert(name, Equals, "Qux")
+	c.Assert(email, Equals, "foo@bar.bar")
+}
+
+func (s *MailmapSuite) TestMailmapBareRepository(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	m, err := r.Mailmap()
+	c.Assert(err, IsNil)
+	c.Assert(m.Len(), Equals, 0)
+
+	_, err = r.CommitChanges("foo\n", &CommitChangesOptions{
+		Author: defaultSignature(),
+		Changes: []FileChange{{
+			Path:    ".mailmap",
+			Content: strings.NewReader("Foo Bar <foo@foo.foo>\n"),
+		}},
+	})
+	c.Assert(err, IsNil)
+
+	m, err = r.Mailmap()
+	c.Assert(err, IsNil)
+	name, _ := m.Map("foo", "foo@foo.foo")
+	c.Assert(name, Equals, "Foo Bar")
+}
+
+func (s *MailmapSuite) TestLog(c *C) {
+	r, _, h := s.repository(c)
+
+	iter, err := r.Log(&LogOptions{UseMailmap: true})
+	c.Assert(err, IsNil)
+
+	commit, err := iter.Next()
+	c.Assert(err, IsNil)
+	c.Assert(commit.Hash, Equals, h)
+	c.Assert(commit.Author.Name, Equals, "Foo Bar")
+	c.Assert(commit.Author.Email, Equals, "foo@bar.bar")
+	c.Assert(commit.Committer.Email, Equals, "foo@bar.bar")
+
+	iter, err = r.Log(&LogOptions{})
+	c.Assert(err, IsNil)
+
+	commit, err = iter.Next()
+	c.Assert(err, IsNil)
+	c.Assert(commit.Author.Email, Equals, "foo@foo.foo")
+}
+
+func (s *MailmapSuite) TestBlame(c *C) {
+	r, _, h := s.repository(c)
+
+	commit, err := r.CommitObject(h)
+	c.Assert(err, IsNil)
+
+	var hunks []*BlameHunk
+	result, err := r.Blame(commit, "foo", &BlameOptions{
+		UseMailmap: true,
+		Incremental: func(h *BlameHunk) error {
+			hunks = append(hunks, h)
+			return nil
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(result.Lines, HasLen, 1)
+	c.Assert(result.Lines[0].Author, Equals, "foo@bar.bar")
+	c.Assert(hunks, HasLen, 1)
+	c.Assert(hunks[0].Commit.Author.Name, Equals, "Foo Bar")
+
+	result, err = BlameWithOptions(context.Background(), commit, "foo", &BlameOptions{UseMailmap: true})
+	c.Assert(err, IsNil)
+	c.Assert(result.Lines[0].Author, Equals, "foo@bar.bar")
+
+	result, err = Blame(commit, "foo")
+	c.Assert(err, IsNil)
+	c.Assert(result.Lines[0].Author, Equals, "foo@foo.foo")
+}
@@ -0,0 +1,727 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/commitgraph"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/revlist"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage"
+)
+
+// MaintenanceTask is a task of the maintenance of a repository, as the ones
+// of git maintenance.
+type MaintenanceTask string
+
+const (
+	// PrefetchTask fetches every remote into refs/prefetch/, without
+	// updating the remote-tracking branches, so the next fetch has less
+	// objects to download. It has no auto threshold, it runs only when
+	// maintenance.prefetch.auto is negative.
+	PrefetchTask MaintenanceTask = "prefetch"
+	// LooseObjectsTask packs the loose objects, up to
+	// maintenance.loose-objects.batchSize of them, 50000 by default,
+	// deleting them and updating the multi-pack-index. It's needed with at
+	// least maintenance.loose-objects.auto loose objects, 100 by default.
+	LooseObjectsTask MaintenanceTask = "loose-objects"
+	// IncrementalRepackTask packs the objects of every pack into a single
+	// one, keeping even the unreachable ones, and updates the
+	// multi-pack-index. It's needed with at least
+	// maintenance.incremental-repack.auto packs, 10 by default.
+	IncrementalRepackTask MaintenanceTask = "incremental-repack"
+	// GCTask repacks the reachable objects and prunes the unreachable loose
+	// objects older than two weeks. It's needed with more loose objects
+	// than gc.auto or more packs than gc.autoPackLimit.
+	GCTask MaintenanceTask = "gc"
+	// CommitGraphTask writes the commit-graph file with every commit
+	// reachable from the references. It's needed with at least
+	// maintenance.commit-graph.auto commits not at the commit-graph, 100 by
+	// default.
+	CommitGraphTask MaintenanceTask = "commit-graph"
+	// PackRefsTask packs the loose references. It's needed with at least
+	// maintenance.pack-refs.auto loose references, 100 by default.
+	PackRefsTask MaintenanceTask = "pack-refs"
+)
+
+// gcPruneExpire is the age of the unreachable loose objects pruned by the gc
+// task, the default gc.pruneExpire of git.
+const gcPruneExpire = 14 * 24 * time.Hour
+
+// looseObjectsBatchSize is the default maximum number of loose objects packed
+// by the loose-objects task at once.
+const looseObjectsBatchSize = 50000
+
+var (
+	// ErrUnknownMaintenanceTask is returned by Maintenance with a task not
+	// known.
+	ErrUnknownMaintenanceTask = errors.New("unknown maintenance task")
+	// ErrCommitGraphNotSupported is returned by the commit-graph task when
+	// the storer can't store a commit-graph.
+	ErrCommitGraphNotSupported = errors.New("commit-graph not supported")
+)
+
+// commitGraphStorer is implemented by the storers able to read and write the
+// commit-graph of the repository, as a single file or as a chain of layers.
+type commitGraphStorer interface {
+	CommitGraph() (*commitgraph.CommitGraph, error)
+	SetCommitGraph(*commitgraph.CommitGraph) error
+	SetCommitGraphChain(*commitgraph.CommitGraph) error
+}
+
+// multiPackIndexStorer is implemented by the storers able to write a
+// multi-pack-index of their packs.
+type multiPackIndexStorer interface {
+	WriteMultiPackIndex() error
+}
+
+type maintenanceTask struct {
+	// config is the default config of the task.
+	config config.MaintenanceTask
+	// needed returns true if the task is needed given its auto threshold.
+	needed func(r *Repository, threshold int) (bool, error)
+	run    func(r *Repository, ctx context.Context, o *MaintenanceOptions) error
+}
+
+// maintenanceOrder is the order of the tasks run when no tasks are given.
+var maintenanceOrder = []MaintenanceTask{
+	PrefetchTask,
+	LooseObjectsTask,
+	IncrementalRepackTask,
+	GCTask,
+	CommitGraphTask,
+	PackRefsTask,
+}
+
+var maintenanceTasks = map[MaintenanceTask]*maintenanceTask{
+	PrefetchTask: {
+		run: (*Repository).prefetch,
+	},
+	LooseObjectsTask: {
+		config: config.MaintenanceTask{Auto: 100, BatchSize: looseObjectsBatchSize},
+		needed: (*Repository).needsLooseObjectsTask,
+		run:    (*Repository).packLooseObjects,
+	},
+	IncrementalRepackTask: {
+		config: config.MaintenanceTask{Auto: 10},
+		needed: (*Repository).needsIncrementalRepackTask,
+		run:    (*Repository).incrementalRepack,
+	},
+	GCTask: {
+		config: config.MaintenanceTask{Enabled: true, Auto: 1},
+		needed: (*Repository).needsGCTask,
+		run:    (*Repository).gc,
+	},
+	CommitGraphTask: {
+		config: config.MaintenanceTask{Auto: 100},
+		needed: (*Repository).needsCommitGraphTask,
+		run:    (*Repository).writeCommitGraph,
+	},
+	PackRefsTask: {
+		config: config.MaintenanceTask{Auto: 100},
+		needed: (*Repository).needsPackRefsTask,
+		run:    (*Repository).packRefs,
+	},
+}
+
+// Maintenance runs the given maintenance tasks, in order, as git maintenance
+// run does. Without tasks, the ones enabled by maintenance.<task>.enabled
+// are run, only gc by default. With MaintenanceOptions.Auto, each task is
+// run only if the repository needs it.
+func (r *Repository) Maintenance(tasks []MaintenanceTask, o *MaintenanceOptions) error {
+	return r.MaintenanceContext(context.Background(), tasks, o)
+}
+
+// MaintenanceContext runs the given maintenance tasks, as Maintenance does.
+//
+// The provided Context must be non-nil. If the context expires before the
+// tasks are done, an error is returned.
+func (r *Repository) MaintenanceContext(ctx context.Context, tasks []MaintenanceTask, o *MaintenanceOptions) error {
+	if o == nil {
+		o = &MaintenanceOptions{}
+	}
+
+	for _, t := range tasks {
+		if _, ok := maintenanceTasks[t]; !ok {
+			return fmt.Errorf("%s: %s", ErrUnknownMaintenanceTask, t)
+		}
+	}
+
+	cfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+
+	configs := make(map[MaintenanceTask]config.MaintenanceTask, len(maintenanceTasks))
+	for _, t := range maintenanceOrder {
+		configs[t], err = cfg.MaintenanceTask(string(t), maintenanceTasks[t].config)
+		if err != nil {
+			return err
+		}
+
+		if tasks == nil && configs[t].Enabled {
+			tasks = append(tasks, t)
+		}
+	}
+
+	for _, t := range tasks {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		if o.Auto {
+			needed, err := r.needsMaintenanceTask(t, configs[t].Auto)
+			if err != nil {
+				return err
+			}
+
+			if !needed {
+				continue
+			}
+		}
+
+		if err := maintenanceTasks[t].run(r, ctx, o); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Repository) needsMaintenanceTask(t MaintenanceTask, threshold int) (bool, error) {
+	task := maintenanceTasks[t]
+	switch {
+	case threshold == 0:
+		return false, nil
+	case threshold < 0:
+		return true, nil
+	case task.needed == nil:
+		return false, nil
+	}
+
+	return task.needed(r, threshold)
+}
+
+// prefetch fetches every remote with its fetch refspecs, their destinations
+// moved under refs/prefetch/.
+func (r *Repository) prefetch(ctx context.Context, o *MaintenanceOptions) error {
+	remotes, err := r.Remotes()
+	if err != nil {
+		return err
+	}
+
+	for _, remote := range remotes {
+		c := remote.Config()
+		var specs []config.RefSpec
+		var positive bool
+		for _, rs := range c.Fetch {
+			if spec, ok := prefetchRefSpec(rs); ok {
+				specs = append(specs, spec)
+				positive = positive || !spec.IsNegative()
+			}
+		}
+
+		if !positive {
+			continue
+		}
+
+		err := remote.FetchContext(ctx, &FetchOptions{
+			RemoteName: c.Name,
+			RefSpecs:   specs,
+			Auth:       o.Auth,
+			Progress:   o.Progress,
+			Tags:       NoTags,
+		})
+		if err != nil && err != NoErrAlreadyUpToDate {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// prefetchRefSpec returns the refspec forcing the update of the destination
+// of rs moved under refs/prefetch/, the negative refspecs are kept as they
+// are. It returns false for the refspecs without a destination.
+func prefetchRefSpec(rs config.RefSpec) (config.RefSpec, bool) {
+	if rs.IsNegative() {
+		return rs, true
+	}
+
+	spec := string(rs)
+	sep := strings.Index(spec, ":")
+	if sep < 0 {
+		return "", false
+	}
+
+	dst := spec[sep+1:]
+	if !strings.HasPrefix(dst, "refs/") {
+		return "", false
+	}
+
+	dst = "refs/prefetch/" + strings.TrimPrefix(dst, "refs/")
+	return config.RefSpec("+" + rs.Src() + ":" + dst), true
+}
+
+func (r *Repository) needsLooseObjectsTask(threshold int) (bool, error) {
+	los, ok := r.Storer.(storer.LooseObjectStorer)
+	if !ok {
+		return false, nil
+	}
+
+	n, err := countLooseObjects(los, threshold)
+	return n >= threshold, err
+}
+
+// countLooseObjects returns the number of loose objects, up to limit.
+func countLooseObjects(los storer.LooseObjectStorer, limit int) (int, error) {
+	var n int
+	err := los.ForEachObjectHash(func(plumbing.Hash) error {
+		n++
+		if n >= limit {
+			return storer.ErrStop
+		}
+
+		return nil
+	})
+	if err == storer.ErrStop {
+		err = nil
+	}
+
+	return n, err
+}
+
+// packLooseObjects packs the loose objects, up to the batch size of the task,
+// into a new pack, deleting them and writing the multi-pack-index.
+func (r *Repository) packLooseObjects(_ context.Context, _ *MaintenanceOptions) error {
+	los, ok := r.Storer.(storer.LooseObjectStorer)
+	if !ok {
+		return ErrLooseObjectsNotSupported
+	}
+
+	cfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+
+	t, err := cfg.MaintenanceTask(string(LooseObjectsTask), config.MaintenanceTask{
+		BatchSize: looseObjectsBatchSize,
+	})
+	if err != nil {
+		return err
+	}
+
+	var hashes []plumbing.Hash
+	err = los.ForEachObjectHash(func(h plumbing.Hash) error {
+		hashes = append(hashes, h)
+		if t.BatchSize > 0 && len(hashes) >= t.BatchSize {
+			return storer.ErrStop
+		}
+
+		return nil
+	})
+	if err == storer.ErrStop {
+		err = nil
+	}
+
+	if err != nil || len(hashes) == 0 {
+		return err
+	}
+
+	if _, err := r.writeObjectPack(hashes, false); err != nil {
+		return err
+	}
+
+	for _, h := range hashes {
+		if err := los.DeleteLooseObject(h); err != nil {
+			return err
+		}
+	}
+
+	return r.writeMultiPackIndex()
+}
+
+// writeMultiPackIndex writes the multi-pack-index of the packs, if the storer
+// supports it.
+func (r *Repository) writeMultiPackIndex() error {
+	mis, ok := r.Storer.(multiPackIndexStorer)
+	if !ok {
+		return nil
+	}
+
+	return mis.WriteMultiPackIndex()
+}
+
+func (r *Repository) needsIncrementalRepackTask(threshold int) (bool, error) {
+	pos, ok := r.Storer.(storer.PackedObjectStorer)
+	if !ok {
+		return false, nil
+	}
+
+	packs, err := pos.ObjectPacks()
+	return len(packs) >= threshold, err
+}
+
+// incrementalRepack packs the objects of every pack into a new pack, deleting
+// the old ones and writing the multi-pack-index.
+func (r *Repository) incrementalRepack(_ context.Context, _ *MaintenanceOptions) error {
+	pos, ok := r.Storer.(storer.PackedObjectStorer)
+	if !ok {
+		return ErrPackedObjectsNotSupported
+	}
+
+	pol, ok := r.Storer.(storer.PackedObjectLister)
+	if !ok {
+		return ErrPackedObjectsNotSupported
+	}
+
+	packs, err := pos.ObjectPacks()
+	if err != nil || len(packs) < 2 {
+		return err
+	}
+
+	seen := make(map[plumbing.Hash]struct{})
+	var hashes []plumbing.Hash
+	for _, pack := range packs {
+		objs, err := pol.ObjectPackHashes(pack)
+		if err != nil {
+			return err
+		}
+
+		for _, h := range objs {
+			if _, ok := seen[h]; !ok {
+				seen[h] = struct{}{}
+				hashes = append(hashes, h)
+			}
+		}
+	}
+
+	nh, err := r.writeObjectPack(hashes, false)
+	if err != nil {
+		return err
+	}
+
+	for _, pack := range packs {
+		if pack == nh {
+			continue
+		}
+
+		if err := pos.DeleteOldObjectPackAndIndex(pack, time.Time{}); err != nil {
+			return err
+		}
+	}
+
+	return r.writeMultiPackIndex()
+}
+
+// needsGCTask returns true if the repository has more loose objects than
+// gc.auto or more packs than gc.autoPackLimit, the threshold is ignored.
+func (r *Repository) needsGCTask(_ int) (bool, error) {
+	cfg, err := r.Config()
+	if err != nil {
+		return false, err
+	}
+
+	if cfg.GC.Auto > 0 {
+		if los, ok := r.Storer.(storer.LooseObjectStorer); ok {
+			n, err := countLooseObjects(los, cfg.GC.Auto+1)
+			if err != nil {
+				return false, err
+			}
+
+			if n > cfg.GC.Auto {
+				return true, nil
+			}
+		}
+	}
+
+	if cfg.GC.AutoPackLimit > 0 {
+		if pos, ok := r.Storer.(storer.PackedObjectStorer); ok {
+			packs, err := pos.ObjectPacks()
+			if err != nil {
+				return false, err
+			}
+
+			return len(packs) > cfg.GC.AutoPackLimit, nil
+		}
+	}
+
+	return false, nil
+}
+
+// gc repacks the reachable objects and prunes the unreachable loose objects
+// older than gcPruneExpire.
+func (r *Repository) gc(_ context.Context, _ *MaintenanceOptions) error {
+	if err := r.RepackObjects(&RepackConfig{}); err != nil {
+		return err
+	}
+
+	return r.Prune(PruneOptions{
+		Expire:  gcPruneExpire,
+		Handler: r.DeleteObject,
+	})
+}
+
+func (r *Repository) needsCommitGraphTask(threshold int) (bool, error) {
+	cgs, ok := r.Storer.(commitGraphStorer)
+	if !ok {
+		return false, nil
+	}
+
+	if shallow, err := r.Storer.Shallow(); err != nil || len(shallow) > 0 {
+		return false, err
+	}
+
+	g, err := cgs.CommitGraph()
+	if err != nil {
+		return false, err
+	}
+
+	commits, err := reachableCommits(r.Storer)
+	if err != nil {
+		return false, err
+	}
+
+	var n int
+	for _, c := range commits {
+		if g == nil {
+			n++
+		} else if _, ok := g.Commit(c.Hash); !ok {
+			n++
+		}
+	}
+
+	return n >= threshold, nil
+}
+
+// writeCommitGraph writes the commit-graph with every commit reachable from
+// the references, with their generation data. Shallow repositories have no
+// commit-graph, since the parents of their commits may be missing.
+func (r *Repository) writeCommitGraph(_ context.Context, _ *MaintenanceOptions) error {
+	cgs, ok := r.Storer.(commitGraphStorer)
+	if !ok {
+		return ErrCommitGraphNotSupported
+	}
+
+	if shallow, err := r.Storer.Shallow(); err != nil || len(shallow) > 0 {
+		return err
+	}
+
+	commits, err := reachableCommits(r.Storer)
+	if err != nil {
+		return err
+	}
+
+	g := commitgraph.New()
+	g.HasGenerationData = true
+
+	data := make(map[plumbing.Hash]*commitgraph.CommitData, len(commits))
+	for _, c := range commits {
+		d := newCommitGraphData(c, data, nil)
+		data[c.Hash] = d
+		g.Add(d)
+	}
+
+	return cgs.SetCommitGraph(g)
+}
+
+// writeCommitGraphLayer adds the commits reachable from the references and
+// not at the commit-graph yet to a new layer of the commit-graph chain, as git
+// commit-graph write --split --reachable does. The top layers with less than
+// twice the commits of the new layer are merged into it, so the chain stays
+// short. Nothing is written if the storer can't store a commit-graph chain.
+func writeCommitGraphLayer(s storage.Storer) error {
+	cgs, ok := s.(commitGraphStorer)
+	if !ok {
+		return nil
+	}
+
+	if shallow, err := s.Shallow(); err != nil || len(shallow) > 0 {
+		return err
+	}
+
+	base, err := cgs.CommitGraph()
+	if err != nil {
+		return err
+	}
+
+	commits, err := reachableCommits(s)
+	if err != nil {
+		return err
+	}
+
+	g := commitgraph.New()
+	g.Base = base
+	g.HasGenerationData = true
+	for l := base; l != nil; l = l.Base {
+		g.HasGenerationData = g.HasGenerationData && l.HasGenerationData
+	}
+
+	data := make(map[plumbing.Hash]*commitgraph.CommitData)
+	for _, c := range commits {
+		if base != nil {
+			if _, ok := base.Commit(c.Hash); ok {
+				continue
+			}
+		}
+
+		d := newCommitGraphData(c, data, base)
+		data[c.Hash] = d
+		g.Add(d)
+	}
+
+	if len(g.Commits) == 0 {
+		return nil
+	}
+
+	for g.Base != nil && len(g.Base.Commits) < 2*len(g.Commits) {
+		for _, d := range g.Base.Commits {
+			g.Add(d)
+		}
+
+		g.Base = g.Base.Base
+	}
+
+	return cgs.SetCommitGraphChain(g)
+}
+
+// newCommitGraphData returns the commit-graph data of the commit, whose
+// parents must be at data or at the base commit-graph, if not nil.
+func newCommitGraphData(
+	c *object.Commit, data map[plumbing.Hash]*commitgraph.CommitData, base *commitgraph.CommitGraph,
+) *commitgraph.CommitData {
+	d := &commitgraph.CommitData{
+		Hash:         c.Hash,
+		TreeHash:     c.TreeHash,
+		ParentHashes: c.ParentHashes,
+		Generation:   1,
+		When:         c.Committer.When,
+	}
+
+	if when := c.Committer.When.Unix(); when > 0 {
+		d.CorrectedDate = uint64(when)
+	}
+
+	for _, p := range c.ParentHashes {
+		pd, ok := data[p]
+		if !ok {
+			pd, _ = base.Commit(p)
+		}
+
+		if pd.Generation >= d.Generation {
+			d.Generation = pd.Generation + 1
+		}
+
+		if pd.CorrectedDate >= d.CorrectedDate {
+			d.CorrectedDate = pd.CorrectedDate + 1
+		}
+	}
+
+	return d
+}
+
+// reachableCommits returns the commits reachable from the references, every
+// commit after its parents.
+func reachableCommits(s storage.Storer) ([]*object.Commit, error) {
+	iter, err := s.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	var tips []plumbing.Hash
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference {
+			tips = append(tips, ref.Hash())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	w := revlist.NewObjectWalker(s)
+	w.Filters = []revlist.ObjectFilter{
+		revlist.TypeFilter(plumbing.CommitObject, plumbing.TagObject),
+	}
+
+	var commits []*object.Commit
+	err = w.Walk(func(h plumbing.Hash, t plumbing.ObjectType) error {
+		if t != plumbing.CommitObject {
+			return nil
+		}
+
+		c, err := object.GetCommit(s, h)
+		if err != nil {
+			return err
+		}
+
+		commits = append(commits, c)
+		return nil
+	}, tips...)
+	if err != nil {
+		return nil, err
+	}
+
+	return parentsFirst(commits), nil
+}
+
+// parentsFirst returns the given commits sorted with every commit after its
+// parents, the parents not given are ignored.
+func parentsFirst(commits []*object.Commit) []*object.Commit {
+	type entry struct {
+		commit *object.Commit
+		// done is true once the parents of the commit are pushed.
+		done bool
+	}
+
+	byHash := make(map[plumbing.Hash]*object.Commit, len(commits))
+	for _, c := range commits {
+		byHash[c.Hash] = c
+	}
+
+	sorted := make([]*object.Commit, 0, len(commits))
+	added := make(map[plumbing.Hash]bool, len(commits))
+	for _, c := range commits {
+		stack := []entry{{commit: c}}
+		for len(stack) > 0 {
+			e := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if e.done {
+				sorted = append(sorted, e.commit)
+				continue
+			}
+
+			if added[e.commit.Hash] {
+				continue
+			}
+
+			added[e.commit.Hash] = true
+			stack = append(stack, entry{commit: e.commit, done: true})
+			for _, p := range e.commit.ParentHashes {
+				if pc, ok := byHash[p]; ok && !added[p] {
+					stack = append(stack, entry{commit: pc})
+				}
+			}
+		}
+	}
+
+	return sorted
+}
+
+func (r *Repository) needsPackRefsTask(threshold int) (bool, error) {
+	n, err := r.Storer.CountLooseRefs()
+	return n >= threshold, err
+}
+
+func (r *Repository) packRefs(_ context.Context, _ *MaintenanceOptions) error {
+	return r.Storer.PackRefs()
+}
@@ -0,0 +1,412 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/util"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+)
+
+type MaintenanceSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&MaintenanceSuite{})
+
+func (s *MaintenanceSuite) openUnpacked(c *C) (*Repository, *filesystem.Storage) {
+	fs := fixtures.ByTag("unpacked").One().DotGit()
+	sto, err := filesystem.NewStorage(fs)
+	c.Assert(err, IsNil)
+
+	r, err := Open(sto, fs)
+	c.Assert(err, IsNil)
+	return r, sto
+}
+
+func (s *MaintenanceSuite) looseObjects(c *C, sto *filesystem.Storage) []plumbing.Hash {
+	var hashes []plumbing.Hash
+	err := sto.ForEachObjectHash(func(h plumbing.Hash) error {
+		hashes = append(hashes, h)
+		return nil
+	})
+	c.Assert(err, IsNil)
+	return hashes
+}
+
+func (s *MaintenanceSuite) countLoose(c *C, sto *filesystem.Storage) int {
+	return len(s.looseObjects(c, sto))
+}
+
+func (s *MaintenanceSuite) TestLooseObjects(c *C) {
+	r, sto := s.openUnpacked(c)
+	c.Assert(s.countLoose(c, sto) > 0, Equals, true)
+
+	packs, err := sto.ObjectPacks()
+	c.Assert(err, IsNil)
+
+	err = r.Maintenance([]MaintenanceTask{LooseObjectsTask}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(s.countLoose(c, sto), Equals, 0)
+
+	after, err := sto.ObjectPacks()
+	c.Assert(err, IsNil)
+	c.Assert(after, HasLen, len(packs)+1)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+
+	_, err = r.CommitObject(head.Hash())
+	c.Assert(err, IsNil)
+}
+
+func (s *MaintenanceSuite) TestLooseObjectsAuto(c *C) {
+	r, sto := s.openUnpacked(c)
+	loose := s.countLoose(c, sto)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("maintenance").Subsection("loose-objects").SetOption("auto", "100000")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	err = r.Maintenance([]MaintenanceTask{LooseObjectsTask}, &MaintenanceOptions{Auto: true})
+	c.Assert(err, IsNil)
+	c.Assert(s.countLoose(c, sto), Equals, loose)
+
+	cfg.Raw.Section("maintenance").Subsection("loose-objects").SetOption("auto", "1")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	err = r.Maintenance([]MaintenanceTask{LooseObjectsTask}, &MaintenanceOptions{Auto: true})
+	c.Assert(err, IsNil)
+	c.Assert(s.countLoose(c, sto), Equals, 0)
+}
+
+func (s *MaintenanceSuite) TestLooseObjectsBatchSize(c *C) {
+	r, sto := s.openUnpacked(c)
+	loose := s.countLoose(c, sto)
+
+	before, err := sto.ObjectPacks()
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("maintenance").Subsection("loose-objects").SetOption("batchSize", "3")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	err = r.Maintenance([]MaintenanceTask{LooseObjectsTask}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(s.countLoose(c, sto), Equals, loose-3)
+
+	packs, err := sto.ObjectPacks()
+	c.Assert(err, IsNil)
+	c.Assert(packs, HasLen, len(before)+1)
+
+	old := make(map[plumbing.Hash]bool, len(before))
+	for _, h := range before {
+		old[h] = true
+	}
+
+	var pack plumbing.Hash
+	for _, h := range packs {
+		if !old[h] {
+			pack = h
+		}
+	}
+
+	hashes, err := sto.ObjectPackHashes(pack)
+	c.Assert(err, IsNil)
+	c.Assert(hashes, HasLen, 3)
+
+	m, err := sto.MultiPackIndex()
+	c.Assert(err, IsNil)
+	c.Assert(m, NotNil)
+	c.Assert(m.PackNames, HasLen, len(packs))
+
+	for _, h := range hashes {
+		_, ok := m.FindEntry(h)
+		c.Assert(ok, Equals, true)
+	}
+
+	for _, e := range m.Entries {
+		c.Assert(sto.HasEncodedObject(e.Hash), IsNil)
+	}
+}
+
+func (s *MaintenanceSuite) TestIncrementalRepack(c *C) {
+	r, sto := s.openUnpacked(c)
+
+	loose := s.looseObjects(c, sto)
+	_, err := r.writeObjectPack(loose[:len(loose)/2], false)
+	c.Assert(err, IsNil)
+	_, err = r.writeObjectPack(loose[len(loose)/2:], false)
+	c.Assert(err, IsNil)
+
+	packs, err := sto.ObjectPacks()
+	c.Assert(err, IsNil)
+	c.Assert(len(packs) > 1, Equals, true)
+
+	var objects []plumbing.Hash
+	for _, pack := range packs {
+		hashes, err := sto.ObjectPackHashes(pack)
+		c.Assert(err, IsNil)
+		objects = append(objects, hashes...)
+	}
+
+	err = r.Maintenance([]MaintenanceTask{IncrementalRepackTask}, nil)
+	c.Assert(err, IsNil)
+
+	packs, err = sto.ObjectPacks()
+	c.Assert(err, IsNil)
+	c.Assert(packs, HasLen, 1)
+
+	for _, h := range objects {
+		c.Assert(sto.HasEncodedObject(h), IsNil)
+	}
+
+	m, err := sto.MultiPackIndex()
+	c.Assert(err, IsNil)
+	c.Assert(m.PackNames, DeepEquals, []string{"pack-" + packs[0].String() + ".idx"})
+}
+
+func (s *MaintenanceSuite) TestCommitGraph(c *C) {
+	r, sto := s.openUnpacked(c)
+
+	err := r.Maintenance([]MaintenanceTask{CommitGraphTask}, nil)
+	c.Assert(err, IsNil)
+
+	g, err := sto.CommitGraph()
+	c.Assert(err, IsNil)
+	c.Assert(g, NotNil)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+
+	iter, err := r.Log(&LogOptions{From: head.Hash()})
+	c.Assert(err, IsNil)
+
+	err = iter.ForEach(func(commit *object.Commit) error {
+		d, ok := g.Commit(commit.Hash)
+		c.Assert(ok, Equals, true)
+		c.Assert(d.TreeHash, Equals, commit.TreeHash)
+		c.Assert(d.ParentHashes, DeepEquals, commit.ParentHashes)
+
+		for _, p := range commit.ParentHashes {
+			pd, ok := g.Commit(p)
+			c.Assert(ok, Equals, true)
+			c.Assert(d.Generation > pd.Generation, Equals, true)
+			c.Assert(d.CorrectedDate > pd.CorrectedDate, Equals, true)
+		}
+
+		return nil
+	})
+	c.Assert(err, IsNil)
+
+	needed, err := r.needsCommitGraphTask(1)
+	c.Assert(err, IsNil)
+	c.Assert(needed, Equals, false)
+}
+
+func (s *MaintenanceSuite) TestFetchWriteCommitGraph(c *C) {
+	server, err := PlainInit(c.MkDir(), false)
+	c.Assert(err, IsNil)
+
+	sw, err := server.Worktree()
+	c.Assert(err, IsNil)
+
+	var n int
+	commit := func(count int) {
+		for i := 0; i < count; i++ {
+			n++
+			name := fmt.Sprintf("foo%d", n)
+			c.Assert(util.WriteFile(sw.Filesystem, name, []byte(name), 0644), IsNil)
+			_, err := sw.Add(name)
+			c.Assert(err, IsNil)
+			_, err = sw.Commit(name+"\n", &CommitOptions{Author: defaultSignature()})
+			c.Assert(err, IsNil)
+		}
+	}
+
+	commit(3)
+	r, err := PlainClone(c.MkDir(), true, &CloneOptions{URL: sw.Filesystem.Root()})
+	c.Assert(err, IsNil)
+	c.Assert(r.Maintenance([]MaintenanceTask{CommitGraphTask}, nil), IsNil)
+
+	sto := r.Storer.(*filesystem.Storage)
+	dot, _ := storerFilesystem(r)
+
+	commit(1)
+	c.Assert(r.Fetch(&FetchOptions{WriteCommitGraph: true}), IsNil)
+
+	_, err = dot.Stat("objects/info/commit-graph")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	g, err := sto.CommitGraph()
+	c.Assert(err, IsNil)
+	c.Assert(g.Commits, HasLen, 1)
+	c.Assert(g.Len(), Equals, 4)
+	c.Assert(g.Base.Base, IsNil)
+
+	head, err := server.Head()
+	c.Assert(err, IsNil)
+	generation, ok := g.Generation(head.Hash())
+	c.Assert(ok, Equals, true)
+	c.Assert(generation > 0, Equals, true)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Fetch.WriteCommitGraph = true
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	commit(2)
+	c.Assert(r.Fetch(&FetchOptions{}), IsNil)
+
+	g, err = sto.CommitGraph()
+	c.Assert(err, IsNil)
+	c.Assert(g.Commits, HasLen, 6)
+	c.Assert(g.Base, IsNil)
+
+	files, err := dot.ReadDir("objects/info/commit-graphs")
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 2)
+
+	c.Assert(r.Fetch(&FetchOptions{}), Equals, NoErrAlreadyUpToDate)
+}
+
+func (s *MaintenanceSuite) TestPackRefs(c *C) {
+	r, sto := s.openUnpacked(c)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	err = sto.SetReference(plumbing.NewHashReference("refs/heads/foo", head.Hash()))
+	c.Assert(err, IsNil)
+
+	n, err := sto.CountLooseRefs()
+	c.Assert(err, IsNil)
+	c.Assert(n > 0, Equals, true)
+
+	err = r.Maintenance([]MaintenanceTask{PackRefsTask}, nil)
+	c.Assert(err, IsNil)
+
+	n, err = sto.CountLooseRefs()
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 0)
+}
+
+func (s *MaintenanceSuite) TestPrefetch(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{s.GetBasicLocalRepositoryURL()},
+	})
+	c.Assert(err, IsNil)
+
+	err = r.Maintenance([]MaintenanceTask{PrefetchTask}, nil)
+	c.Assert(err, IsNil)
+
+	ref, err := r.Reference("refs/prefetch/remotes/origin/master", false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash().String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+	_, err = r.Reference("refs/remotes/origin/master", false)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	tags, err := r.Tags()
+	c.Assert(err, IsNil)
+	c.Assert(tags.ForEach(func(*plumbing.Reference) error {
+		c.Fatal("no tags expected")
+		return nil
+	}), IsNil)
+}
+
+func (s *MaintenanceSuite) TestPrefetchRefSpec(c *C) {
+	for _, t := range []struct {
+		spec     config.RefSpec
+		expected config.RefSpec
+		ok       bool
+	}{
+		{"+refs/heads/*:refs/remotes/origin/*", "+refs/heads/*:refs/prefetch/remotes/origin/*", true},
+		{"refs/heads/master:refs/heads/master", "+refs/heads/master:refs/prefetch/heads/master", true},
+		{"^refs/heads/wip/*", "^refs/heads/wip/*", true},
+		{"refs/heads/master", "", false},
+	} {
+		spec, ok := prefetchRefSpec(t.spec)
+		c.Assert(ok, Equals, t.ok, Commentf("%s", t.spec))
+		c.Assert(spec, Equals, t.expected, Commentf("%s", t.spec))
+	}
+}
+
+func (s *MaintenanceSuite) TestEnabledTasks(c *C) {
+	r, sto := s.openUnpacked(c)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("maintenance").Subsection("gc").SetOption("enabled", "false")
+	cfg.Raw.Section("maintenance").Subsection("pack-refs").SetOption("enabled", "true")
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	loose := s.countLoose(c, sto)
+	c.Assert(r.Maintenance(nil, nil), IsNil)
+	c.Assert(s.countLoose(c, sto), Equals, loose)
+
+	n, err := sto.CountLooseRefs()
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 0)
+}
+
+func (s *MaintenanceSuite) TestGCAuto(c *C) {
+	r, sto := s.openUnpacked(c)
+
+	needed, err := r.needsGCTask(1)
+	c.Assert(err, IsNil)
+	c.Assert(needed, Equals, false)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.GC.Auto = 1
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	err = r.Maintenance(nil, &MaintenanceOptions{Auto: true})
+	c.Assert(err, IsNil)
+
+	packs, err := sto.ObjectPacks()
+	c.Assert(err, IsNil)
+	c.Assert(packs, HasLen, 1)
+}
+
+func (s *MaintenanceSuite) TestUnknownTask(c *C) {
+	r, _ := s.openUnpacked(c)
+
+	err := r.Maintenance([]MaintenanceTask{"foo"}, nil)
+	c.Assert(err, ErrorMatches, ".*unknown maintenance task.*")
+}
+
+func (s *MaintenanceSuite) TestMaintenanceContextCanceled(c *C) {
+	r, _ := s.openUnpacked(c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := r.MaintenanceContext(ctx, []MaintenanceTask{GCTask}, nil)
+	c.Assert(err, Equals, context.Canceled)
+}
+
+func (s *MaintenanceSuite) TestUnsupportedStorer(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	err = r.Maintenance([]MaintenanceTask{CommitGraphTask}, nil)
+	c.Assert(err, Equals, ErrCommitGraphNotSupported)
+
+	err = r.Maintenance(nil, &MaintenanceOptions{Auto: true})
+	c.Assert(err, IsNil)
+}
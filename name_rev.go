@@ -0,0 +1,411 @@
+package git
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+const (
+	// nameRevMergeWeight is the distance added when following a parent
+	// other than the first one, as git does.
+	nameRevMergeWeight = 65535
+	// nameRevCutoffSlop is the time, in seconds, that a commit can be older
+	// than the commit being named and still be walked.
+	nameRevCutoffSlop = 86400
+)
+
+// NameRev returns a name of the object with the given hash relative to the
+// references, as `git name-rev --name-only` does, such as "master~2" or
+// "tags/v2.1.0~3^2". The names based on tags are preferred, then the ones
+// closer to the reference, following the first parents, and then the ones
+// based on older references. An object other than a commit is only named if
+// a reference points directly to it. ErrNameRevNotFound is returned if the
+// object can't be named.
+func (r *Repository) NameRev(h plumbing.Hash, o *NameRevOptions) (string, error) {
+	if o == nil {
+		o = &NameRevOptions{}
+	}
+
+	if err := o.Validate(); err != nil {
+		return "", err
+	}
+
+	obj, err := r.Storer.EncodedObject(plumbing.AnyObject, h)
+	if err != nil {
+		return "", err
+	}
+
+	tips, err := r.nameRevTips(o)
+	if err != nil {
+		return "", err
+	}
+
+	if obj.Type() != plumbing.CommitObject {
+		for _, t := range tips {
+			if t.hash == h {
+				return t.name, nil
+			}
+		}
+
+		return "", ErrNameRevNotFound
+	}
+
+	c, err := object.DecodeCommit(r.Storer, obj)
+	if err != nil {
+		return "", err
+	}
+
+	n := &revNamer{
+		s:       r.Storer,
+		cutoff:  c.Committer.When.Unix() - nameRevCutoffSlop,
+		names:   make(map[plumbing.Hash]*revName),
+		commits: make(map[plumbing.Hash]*object.Commit),
+	}
+
+	for _, t := range tips {
+		if t.commit == nil {
+			continue
+		}
+
+		if err := n.name(t); err != nil {
+			return "", err
+		}
+	}
+
+	name, ok := n.names[h]
+	if !ok {
+		return "", ErrNameRevNotFound
+	}
+
+	return name.String(), nil
+}
+
+// revNameTip is a reference used to name commits.
+type revNameTip struct {
+	name       string
+	hash       plumbing.Hash
+	commit     *object.Commit
+	taggerDate int64
+	fromTag    bool
+	deref      bool
+}
+
+// nameRevTips returns the references used to name the commits, the ones
+// based on tags first, and then the ones based on older references.
+func (r *Repository) nameRevTips(o *NameRevOptions) ([]*revNameTip, error) {
+	iter, err := r.Storer.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []*plumbing.Reference
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Name() != plumbing.HEAD {
+			refs = append(refs, ref)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Name() < refs[j].Name() })
+
+	var tips []*revNameTip
+	for _, ref := range refs {
+		name, ok := nameRevTipName(ref.Name(), o)
+		if !ok {
+			continue
+		}
+
+		if ref.Type() == plumbing.SymbolicReference {
+			if ref, err = storer.ResolveReference(r.Storer, ref.Name()); err != nil {
+				return nil, err
+			}
+		}
+
+		t, err := r.nameRevTip(name, ref)
+		if err != nil {
+			return nil, err
+		}
+
+		tips = append(tips, t)
+	}
+
+	sort.SliceStable(tips, func(i, j int) bool {
+		if tips[i].fromTag != tips[j].fromTag {
+			return tips[i].fromTag
+		}
+
+		return tips[i].taggerDate < tips[j].taggerDate
+	})
+
+	return tips, nil
+}
+
+func (r *Repository) nameRevTip(name string, ref *plumbing.Reference) (*revNameTip, error) {
+	t := &revNameTip{name: name, hash: ref.Hash()}
+
+	obj, err := r.Storer.EncodedObject(plumbing.AnyObject, ref.Hash())
+	if err == plumbing.ErrObjectNotFound {
+		return t, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var date *int64
+	for obj.Type() == plumbing.TagObject {
+		tag, err := object.DecodeTag(r.Storer, obj)
+		if err != nil {
+			return nil, err
+		}
+
+		if date == nil {
+			when := tag.Tagger.When.Unix()
+			date = &when
+		}
+
+		if obj, err = r.Storer.EncodedObject(plumbing.AnyObject, tag.Target); err != nil {
+			return nil, err
+		}
+
+		t.deref = true
+	}
+
+	if obj.Type() != plumbing.CommitObject {
+		return t, nil
+	}
+
+	if t.commit, err = object.DecodeCommit(r.Storer, obj); err != nil {
+		return nil, err
+	}
+
+	t.fromTag = ref.Name().IsTag()
+	t.taggerDate = t.commit.Committer.When.Unix()
+	if date != nil {
+		t.taggerDate = *date
+	}
+
+	return t, nil
+}
+
+// nameRevTipName returns the name used for the commits named after the
+// reference, and whether the reference is used at all.
+func nameRevTipName(ref plumbing.ReferenceName, o *NameRevOptions) (string, bool) {
+	if o.Tags && !ref.IsTag() {
+		return "", false
+	}
+
+	for _, p := range o.Exclude {
+		if refPatternMatch(p, ref) >= 0 {
+			return "", false
+		}
+	}
+
+	abbrev := o.Tags
+	if len(o.Refs) != 0 {
+		matched := false
+		for _, p := range o.Refs {
+			switch m := refPatternMatch(p, ref); {
+			case m == 0:
+				matched = true
+			case m > 0:
+				matched, abbrev = true, true
+			}
+		}
+
+		if !matched {
+			return "", false
+		}
+	}
+
+	if abbrev {
+		return ref.Short(), true
+	}
+
+	name := string(ref)
+	if strings.HasPrefix(name, "refs/heads/") {
+		return strings.TrimPrefix(name, "refs/heads/"), true
+	}
+
+	return strings.TrimPrefix(name, "refs/"), true
+}
+
+// refPatternMatch matches the pattern against the name of the reference and
+// its trailing components, returning the position of the first match, or -1.
+// A "*" in the pattern also matches slashes, as the patterns of name-rev and
+// ls-remote do.
+func refPatternMatch(pattern string, ref plumbing.ReferenceName) int {
+	pattern = strings.Replace(pattern, "/", "\x00", -1)
+
+	name := string(ref)
+	for i := 0; i <= len(name); {
+		sub := strings.Replace(name[i:], "/", "\x00", -1)
+		if ok, _ := path.Match(pattern, sub); ok {
+			return i
+		}
+
+		next := strings.IndexByte(name[i:], '/')
+		if next == -1 {
+			break
+		}
+
+		i += next + 1
+	}
+
+	return -1
+}
+
+// revName is the name of a commit relative to a tip.
+type revName struct {
+	tip        string
+	taggerDate int64
+	generation int
+	distance   int
+	fromTag    bool
+}
+
+func (n *revName) String() string {
+	if n.generation == 0 {
+		return n.tip
+	}
+
+	return fmt.Sprintf("%s~%d", strings.TrimSuffix(n.tip, "^0"), n.generation)
+}
+
+// parent returns the tip of the name of the given parent of the commit, not
+// the first one.
+func (n *revName) parent(number int) string {
+	tip := strings.TrimSuffix(n.tip, "^0")
+	if n.generation > 0 {
+		return fmt.Sprintf("%s~%d^%d", tip, n.generation, number)
+	}
+
+	return fmt.Sprintf("%s^%d", tip, number)
+}
+
+// better returns whether a name with the given values is better than n.
+func (n *revName) better(taggerDate int64, distance int, fromTag bool) bool {
+	if fromTag && n.fromTag {
+		return n.taggerDate > taggerDate ||
+			(n.taggerDate == taggerDate && n.distance > distance)
+	}
+
+	if n.fromTag != fromTag {
+		return fromTag
+	}
+
+	if n.distance != distance {
+		return n.distance > distance
+	}
+
+	return n.taggerDate > taggerDate
+}
+
+// revNamer names the commits walking from the tips to their ancestors, as
+// git does.
+type revNamer struct {
+	s       storer.EncodedObjectStorer
+	cutoff  int64
+	names   map[plumbing.Hash]*revName
+	commits map[plumbing.Hash]*object.Commit
+}
+
+func (n *revNamer) name(t *revNameTip) error {
+	tip := t.name
+	if t.deref {
+		tip += "^0"
+	}
+
+	if n.update(t.commit.Hash, tip, t, 0, 0) == nil {
+		return nil
+	}
+
+	n.commits[t.commit.Hash] = t.commit
+	stack := []*object.Commit{t.commit}
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		name := n.names[c.Hash]
+
+		var parents []*object.Commit
+		for i, h := range c.ParentHashes {
+			p, err := n.commit(h)
+			if err == plumbing.ErrObjectNotFound {
+				continue
+			}
+
+			if err != nil {
+				return err
+			}
+
+			if p.Committer.When.Unix() < n.cutoff {
+				continue
+			}
+
+			tip, generation, distance := name.tip, name.generation+1, name.distance+1
+			if i > 0 {
+				tip, generation = name.parent(i+1), 0
+				distance = name.distance + nameRevMergeWeight
+			}
+
+			if n.update(h, tip, t, generation, distance) != nil {
+				parents = append(parents, p)
+			}
+		}
+
+		// the first parent is walked first
+		for i := len(parents) - 1; i >= 0; i-- {
+			stack = append(stack, parents[i])
+		}
+	}
+
+	return nil
+}
+
+// update names the commit with the given values if there is no better name
+// for it, returning the name, or nil if it wasn't updated.
+func (n *revNamer) update(h plumbing.Hash, tip string, t *revNameTip, generation, distance int) *revName {
+	name, ok := n.names[h]
+	if ok && !name.better(t.taggerDate, distance, t.fromTag) {
+		return nil
+	}
+
+	if !ok {
+		name = &revName{}
+		n.names[h] = name
+	}
+
+	*name = revName{
+		tip:        tip,
+		taggerDate: t.taggerDate,
+		generation: generation,
+		distance:   distance,
+		fromTag:    t.fromTag,
+	}
+
+	return name
+}
+
+func (n *revNamer) commit(h plumbing.Hash) (*object.Commit, error) {
+	if c, ok := n.commits[h]; ok {
+		return c, nil
+	}
+
+	c, err := object.GetCommit(n.s, h)
+	if err != nil {
+		return nil, err
+	}
+
+	n.commits[h] = c
+	return c, nil
+}
@@ -0,0 +1,162 @@
+package git
+
+import (
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+type NameRevSuite struct {
+	BaseSuite
+	r       *Repository
+	commits map[string]plumbing.Hash
+	when    time.Time
+}
+
+var _ = Suite(&NameRevSuite{})
+
+// SetUpTest creates the history:
+//
+//	1 - 2 - 3 - 4 - m - 6 - 7 (master)
+//	         \     /
+//	          f1 - f2 (feature)
+//
+// with a lightweight tag v0.1 at 2, and an annotated tag v1.0 at 6.
+func (s *NameRevSuite) SetUpTest(c *C) {
+	var err error
+	s.r, err = Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	s.commits = make(map[string]plumbing.Hash)
+	s.when = time.Unix(1500000000, 0)
+
+	s.commit(c, "1")
+	s.commit(c, "2", "1")
+	s.commit(c, "3", "2")
+	s.commit(c, "f1", "3")
+	s.commit(c, "f2", "f1")
+	s.commit(c, "4", "3")
+	s.commit(c, "m", "4", "f2")
+	s.commit(c, "6", "m")
+	s.commit(c, "7", "6")
+
+	refs := map[plumbing.ReferenceName]string{
+		"refs/heads/master":  "7",
+		"refs/heads/feature": "f2",
+		"refs/tags/v0.1":     "2",
+	}
+
+	for name, commit := range refs {
+		ref := plumbing.NewHashReference(name, s.commits[commit])
+		c.Assert(s.r.Storer.SetReference(ref), IsNil)
+	}
+
+	s.when = s.when.Add(time.Minute)
+	_, err = s.r.CreateTag("v1.0", s.commits["6"], &CreateTagOptions{
+		Tagger:  &object.Signature{Name: "foo", Email: "foo@foo.foo", When: s.when},
+		Message: "v1.0",
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *NameRevSuite) commit(c *C, msg string, parents ...string) {
+	s.when = s.when.Add(time.Minute)
+	sig := object.Signature{Name: "foo", Email: "foo@foo.foo", When: s.when}
+
+	commit := &object.Commit{
+		Author:    sig,
+		Committer: sig,
+		Message:   msg,
+		TreeHash:  plumbing.NewHash("4b825dc642cb6eb9a060e54bf8d69288fbee4904"),
+	}
+
+	for _, p := range parents {
+		commit.ParentHashes = append(commit.ParentHashes, s.commits[p])
+	}
+
+	obj := s.r.Storer.NewEncodedObject()
+	c.Assert(commit.Encode(obj), IsNil)
+
+	h, err := s.r.Storer.SetEncodedObject(obj)
+	c.Assert(err, IsNil)
+	s.commits[msg] = h
+}
+
+func (s *NameRevSuite) assertNames(c *C, o *NameRevOptions, expected map[string]string) {
+	for commit, name := range expected {
+		got, err := s.r.NameRev(s.commits[commit], o)
+		if name == "" {
+			c.Assert(err, Equals, ErrNameRevNotFound, Commentf("commit %s", commit))
+			continue
+		}
+
+		c.Assert(err, IsNil, Commentf("commit %s", commit))
+		c.Assert(got, Equals, name, Commentf("commit %s", commit))
+	}
+}
+
+func (s *NameRevSuite) TestNameRev(c *C) {
+	s.assertNames(c, nil, map[string]string{
+		"1":  "tags/v0.1~1",
+		"2":  "tags/v0.1",
+		"3":  "tags/v1.0~3",
+		"4":  "tags/v1.0~2",
+		"f1": "tags/v1.0~1^2~1",
+		"f2": "tags/v1.0~1^2",
+		"m":  "tags/v1.0~1",
+		"6":  "tags/v1.0^0",
+		"7":  "master",
+	})
+}
+
+func (s *NameRevSuite) TestNameRevTags(c *C) {
+	s.assertNames(c, &NameRevOptions{Tags: true}, map[string]string{
+		"1":  "v0.1~1",
+		"f1": "v1.0~1^2~1",
+		"6":  "v1.0^0",
+		"7":  "",
+	})
+}
+
+func (s *NameRevSuite) TestNameRevRefs(c *C) {
+	s.assertNames(c, &NameRevOptions{Refs: []string{"refs/heads/*"}}, map[string]string{
+		"1":  "feature~4",
+		"3":  "feature~2",
+		"4":  "master~3",
+		"f1": "feature~1",
+		"f2": "feature",
+		"m":  "master~2",
+		"6":  "master~1",
+	})
+
+	s.assertNames(c, &NameRevOptions{Refs: []string{"v1*"}}, map[string]string{
+		"1": "v1.0~5",
+		"6": "v1.0^0",
+		"7": "",
+	})
+}
+
+func (s *NameRevSuite) TestNameRevExclude(c *C) {
+	s.assertNames(c, &NameRevOptions{Exclude: []string{"tags/v1*", "feature"}}, map[string]string{
+		"1":  "tags/v0.1~1",
+		"3":  "master~4",
+		"f2": "master~2^2",
+		"6":  "master~1",
+	})
+}
+
+func (s *NameRevSuite) TestNameRevTagObject(c *C) {
+	ref, err := s.r.Reference("refs/tags/v1.0", false)
+	c.Assert(err, IsNil)
+
+	name, err := s.r.NameRev(ref.Hash(), nil)
+	c.Assert(err, IsNil)
+	c.Assert(name, Equals, "tags/v1.0")
+
+	_, err = s.r.NameRev(plumbing.NewHash("4b825dc642cb6eb9a060e54bf8d69288fbee4904"), nil)
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+}
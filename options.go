@@ -2,13 +2,24 @@ package git
 
 import (
 	"errors"
+	"io"
+	"path"
 	"regexp"
+	"strings"
+	"time"
 
 	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/archive"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/capability"
 	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/sideband"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/osfs"
 )
 
 // SubmoduleRescursivity defines how depth will affect any submodule recursive
@@ -27,6 +38,15 @@ const (
 
 var (
 	ErrMissingURL = errors.New("URL field is required")
+	// ErrMissingRebaseUpstream is returned rebasing without an upstream
+	// commit.
+	ErrMissingRebaseUpstream = errors.New("rebase upstream is required")
+	// ErrInvalidDefaultBranch is returned when the default branch of an
+	// init isn't a valid branch name.
+	ErrInvalidDefaultBranch = errors.New("invalid default branch name")
+	// ErrUnsupportedObjectFormat is returned when a repository is initialized
+	// with an object format other than sha1.
+	ErrUnsupportedObjectFormat = errors.New("unsupported object format")
 )
 
 // CloneOptions describes how a clone should be performed.
@@ -35,6 +55,9 @@ type CloneOptions struct {
 	URL string
 	// Auth credentials, if required, to use with the remote repository.
 	Auth transport.AuthMethod
+	// HTTPOptions, if not nil, override the http.<url>.* config of the
+	// requests sent to an HTTP remote.
+	HTTPOptions *transport.HTTPOptions
 	// Name of the remote to be added, by default `origin`.
 	RemoteName string
 	// Remote branch to clone.
@@ -49,6 +72,14 @@ type CloneOptions struct {
 	// within, using their default settings. This option is ignored if the
 	// cloned repository does not have a worktree.
 	RecurseSubmodules SubmoduleRescursivity
+	// ShallowSubmodules clones the submodules, with RecurseSubmodules, limited
+	// to Depth commits, or to 1 if Depth is not set, as `git clone
+	// --shallow-submodules` does.
+	ShallowSubmodules bool
+	// SubmoduleDepth limits the cloning of the submodules, with
+	// RecurseSubmodules, to the given number of commits, independently of
+	// Depth.
+	SubmoduleDepth int
 	// Progress is where the human readable information sent by the server is
 	// stored, if nil nothing is stored and the capability (if supported)
 	// no-progress, is sent to the server to avoid send this information.
@@ -56,6 +87,28 @@ type CloneOptions struct {
 	// Tags describe how the tags will be fetched from the remote repository,
 	// by default is AllTags.
 	Tags TagMode
+	// Workers is the number of files written concurrently by the checkout of
+	// HEAD, see CheckoutOptions.Workers.
+	Workers int
+	// CheckoutProgress, if not nil, receives the progress of the checkout of
+	// HEAD, while Progress only covers the transfer of the objects.
+	CheckoutProgress CheckoutProgress
+	// Tracer, if not nil, observes the steps of the clone and is set as the
+	// Tracer of the repository.
+	Tracer Tracer
+	// Mirror clones all the references of the remote as they are into a bare
+	// repository, and sets the remote up as a mirror, so the fetches
+	// overwrite all the local references and the pushes all the remote
+	// ones, as `git clone --mirror` does. SingleBranch is ignored.
+	Mirror bool
+	// WriteCommitGraph writes the commit-graph of the cloned commits, as
+	// fetch.writeCommitGraph does, see FetchOptions.WriteCommitGraph.
+	WriteCommitGraph bool
+	// DeltaBaseCacheSize limits the memory used by the cache of delta bases
+	// while indexing the cloned packfile, see FetchOptions.DeltaBaseCacheSize.
+	DeltaBaseCacheSize cache.FileSize
+	// FsckObjects checks the cloned objects, see FetchOptions.FsckObjects.
+	FsckObjects bool
 }
 
 // Validate validates the fields and sets the default values.
@@ -76,6 +129,13 @@ func (o *CloneOptions) Validate() error {
 		o.Tags = AllTags
 	}
 
+	if o.SubmoduleDepth == 0 && o.ShallowSubmodules {
+		o.SubmoduleDepth = o.Depth
+		if o.SubmoduleDepth == 0 {
+			o.SubmoduleDepth = 1
+		}
+	}
+
 	return nil
 }
 
@@ -91,6 +151,9 @@ type PullOptions struct {
 	Depth int
 	// Auth credentials, if required, to use with the remote repository.
 	Auth transport.AuthMethod
+	// HTTPOptions, if not nil, override the http.<url>.* config of the
+	// requests sent to an HTTP remote.
+	HTTPOptions *transport.HTTPOptions
 	// RecurseSubmodules controls if new commits of all populated submodules
 	// should be fetched too.
 	RecurseSubmodules SubmoduleRescursivity
@@ -101,6 +164,21 @@ type PullOptions struct {
 	// Force allows the pull to update a local branch even when the remote
 	// branch does not descend from it.
 	Force bool
+	// VerifySignatures, if not nil, requires the pulled commit to be signed
+	// with a signature trusted by the given Verifier.
+	VerifySignatures object.Verifier
+	// Rebase controls if the current branch is rebased onto the pulled
+	// commit when it can't be fast-forwarded, instead of failing with
+	// ErrNonFastForwardUpdate. If empty, branch.<name>.rebase or pull.rebase
+	// is used.
+	Rebase PullRebaseMode
+	// Committer is the committer's signature of the commits rebased. If nil,
+	// the committer of every commit is kept, with the current time.
+	Committer *object.Signature
+	// Autostash stashes the local changes of the tracked files before
+	// updating the worktree, reapplying them once the pull is completed, as
+	// rebase.autoStash and merge.autoStash do for the rebases and the merges.
+	Autostash bool
 }
 
 // Validate validates the fields and sets the default values.
@@ -113,6 +191,74 @@ func (o *PullOptions) Validate() error {
 		o.ReferenceName = plumbing.HEAD
 	}
 
+	switch o.Rebase {
+	case "", PullRebaseFalse, PullRebaseTrue, PullRebaseMerges:
+	default:
+		return ErrInvalidPullRebaseMode
+	}
+
+	return nil
+}
+
+var (
+	// ErrInvalidPullRebaseMode is returned pulling with an unknown rebase
+	// mode, given by the options or the config.
+	ErrInvalidPullRebaseMode = errors.New("invalid pull rebase mode")
+)
+
+// PullRebaseMode defines if a pull rebases the current branch onto the pulled
+// commit, as the values of pull.rebase.
+type PullRebaseMode string
+
+const (
+	// PullRebaseFalse merges the pulled commit into the current branch. Only
+	// fast-forwards are supported.
+	PullRebaseFalse PullRebaseMode = "false"
+	// PullRebaseTrue rebases the commits of the current branch onto the
+	// pulled commit, dropping the local merge commits.
+	PullRebaseTrue PullRebaseMode = "true"
+	// PullRebaseMerges rebases the commits of the current branch onto the
+	// pulled commit, recreating the local merge commits.
+	PullRebaseMerges PullRebaseMode = "merges"
+)
+
+// parsePullRebaseMode parses the value of pull.rebase or branch.<name>.rebase.
+// The interactive mode is rebased non-interactively.
+func parsePullRebaseMode(v string) (PullRebaseMode, error) {
+	switch strings.ToLower(v) {
+	case "", "false", "no", "off", "0":
+		return PullRebaseFalse, nil
+	case "true", "yes", "on", "1", "interactive", "i":
+		return PullRebaseTrue, nil
+	case "merges", "m", "preserve", "p":
+		return PullRebaseMerges, nil
+	default:
+		return "", ErrInvalidPullRebaseMode
+	}
+}
+
+// RebaseOptions describes how a rebase should be performed.
+type RebaseOptions struct {
+	// Upstream is the commit the current branch is rebased onto. The
+	// commits of the branch not reachable from it are replayed on top of it.
+	Upstream plumbing.Hash
+	// Merges recreates the merge commits of the branch, instead of dropping
+	// them, as `git rebase --rebase-merges` does.
+	Merges bool
+	// Committer is the committer's signature of the commits rebased. If nil,
+	// the committer of every commit is kept, with the current time.
+	Committer *object.Signature
+	// Autostash stashes the local changes of the tracked files before the
+	// rebase, reapplying them once completed, as rebase.autoStash does.
+	Autostash bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *RebaseOptions) Validate() error {
+	if o.Upstream.IsZero() {
+		return ErrMissingRebaseUpstream
+	}
+
 	return nil
 }
 
@@ -121,8 +267,9 @@ type TagMode int
 const (
 	InvalidTagMode TagMode = iota
 	// TagFollowing any tag that points into the histories being fetched is also
-	// fetched. TagFollowing requires a server with `include-tag` capability
-	// in order to fetch the annotated tags objects.
+	// fetched. The annotated tags objects are sent along with the packfile by
+	// the servers with `include-tag` capability, and requested afterwards
+	// from the rest.
 	TagFollowing
 	// AllTags fetch all tags from the remote (i.e., fetch remote tags
 	// refs/tags/* into local tags with the same name)
@@ -141,6 +288,9 @@ type FetchOptions struct {
 	Depth int
 	// Auth credentials, if required, to use with the remote repository.
 	Auth transport.AuthMethod
+	// HTTPOptions, if not nil, override the http.<url>.* config of the
+	// requests sent to an HTTP remote.
+	HTTPOptions *transport.HTTPOptions
 	// Progress is where the human readable information sent by the server is
 	// stored, if nil nothing is stored and the capability (if supported)
 	// no-progress, is sent to the server to avoid send this information.
@@ -151,6 +301,59 @@ type FetchOptions struct {
 	// Force allows the fetch to update a local branch even when the remote
 	// branch does not descend from it.
 	Force bool
+	// Prune deletes the references matching the destination of the
+	// RefSpecs, such as the remote-tracking branches, whose sources no
+	// longer exist at the remote. If false, remote.<name>.prune or
+	// fetch.prune are followed.
+	Prune bool
+	// PruneTags deletes the local tags no longer existing at the remote. If
+	// false, remote.<name>.pruneTags or fetch.pruneTags are followed.
+	PruneTags bool
+	// Pruned, if not nil, is called with every reference deleted by Prune
+	// or PruneTags.
+	Pruned func(*plumbing.Reference)
+	// DryRun negotiates with the remote the references to fetch, without
+	// fetching any object nor updating or pruning any reference. The updates
+	// are reported to Updated and Pruned. With TagFollowing, only the tags
+	// pointing to objects already present are reported, since the history
+	// to be fetched isn't known without the packfile.
+	DryRun bool
+	// NoWriteFetchHead disables recording the fetched references at
+	// FETCH_HEAD, as `git fetch --no-write-fetch-head` does. Nothing is
+	// recorded with DryRun either.
+	NoWriteFetchHead bool
+	// WriteCommitGraph adds the fetched commits to a new layer of the
+	// commit-graph, as fetch.writeCommitGraph does, which is followed if not
+	// set. The commit-graph isn't written for shallow repositories.
+	WriteCommitGraph bool
+	// Updated, if not nil, is called with every local reference processed by
+	// the fetch, including the up-to-date and rejected ones, with the
+	// outcome of its update, or the one it would have with DryRun.
+	Updated func(*RefResult)
+	// DeltaBaseCacheSize limits the memory used by the cache of delta bases
+	// while indexing the fetched packfile, when the storage streams it
+	// straight to disk, as the filesystem storage does. If zero,
+	// cache.DefaultMaxSize is used.
+	DeltaBaseCacheSize cache.FileSize
+	// DisabledCapabilities are the optional capabilities never requested,
+	// even if advertised by the server, to work around the servers
+	// mishandling them, e.g. side-band-64k, which falls back to side-band,
+	// no-progress, ofs-delta, thin-pack or agent. ofs-delta is also
+	// disabled by repack.useDeltaBaseOffset.
+	DisabledCapabilities []capability.Capability
+	// Refetch fetches all the objects as a fresh clone would, instead of
+	// negotiating with the remote the ones already present, as `git fetch
+	// --refetch` does, e.g. to recover the objects lost by a corruption.
+	// All the received objects are indexed again, and with
+	// Repository.AutoGC the gc is run afterwards to consolidate the packs,
+	// whatever gc.auto and gc.autoPackLimit are.
+	Refetch bool
+	// FsckObjects checks that all the objects reachable from the fetched
+	// references are present and valid before updating them, failing with a
+	// fsck.MissingObjectError or fsck.Problem otherwise. If false,
+	// fetch.fsckObjects and transfer.fsckObjects are followed. The severity
+	// of the problems found is set by fetch.fsck.<msg-id>.
+	FsckObjects bool
 }
 
 // Validate validates the fields and sets the default values.
@@ -172,6 +375,79 @@ func (o *FetchOptions) Validate() error {
 	return nil
 }
 
+// ErrMissingBranchNames is returned when no branches are given to add to a
+// remote.
+var ErrMissingBranchNames = errors.New("branch names are required")
+
+// AddRemoteBranchesOptions describes how branches should be added to the ones
+// fetched from a remote, e.g. after a clone with SingleBranch.
+type AddRemoteBranchesOptions struct {
+	// Name of the remote, by default DefaultRemoteName.
+	RemoteName string
+	// Branches are the names, or glob patterns such as "feature/*", of the
+	// branches added.
+	Branches []string
+	// NoFetch only adds the branches to the config of the remote, without
+	// fetching them.
+	NoFetch bool
+	// Depth limit fetching to the specified number of commits from the tip of
+	// each new branch.
+	Depth int
+	// Auth credentials, if required, to use with the remote repository.
+	Auth transport.AuthMethod
+	// Progress is where the human readable information sent by the server is
+	// stored, if nil nothing is stored.
+	Progress sideband.Progress
+}
+
+// Validate validates the fields and sets the default values.
+func (o *AddRemoteBranchesOptions) Validate() error {
+	if o.RemoteName == "" {
+		o.RemoteName = DefaultRemoteName
+	}
+
+	if len(o.Branches) == 0 {
+		return ErrMissingBranchNames
+	}
+
+	return nil
+}
+
+// MaintenanceOptions describes how the maintenance of a repository should be
+// performed.
+type MaintenanceOptions struct {
+	// Auto runs only the tasks the repository needs, as told by their
+	// maintenance.<task>.auto thresholds.
+	Auto bool
+	// Auth credentials, if required, used by the prefetch task with the
+	// remote repositories.
+	Auth transport.AuthMethod
+	// Progress is where the human readable information sent by the servers
+	// to the prefetch task is stored, if nil nothing is stored.
+	Progress sideband.Progress
+}
+
+// RemotePruneOptions describes how the prune of a remote should be performed.
+type RemotePruneOptions struct {
+	// Auth credentials, if required, to use with the remote repository.
+	Auth transport.AuthMethod
+	// HTTPOptions, if not nil, override the http.<url>.* config of the
+	// requests sent to an HTTP remote.
+	HTTPOptions *transport.HTTPOptions
+	// DryRun reports the references to delete to Pruned, without deleting
+	// them.
+	DryRun bool
+	// Pruned, if not nil, is called with every reference deleted.
+	Pruned func(*plumbing.Reference)
+}
+
+var (
+	ErrMirrorRefSpecs = errors.New("Mirror and RefSpecs are mutually exclusive")
+	// ErrInvalidPushOption is returned when a push option contains a new
+	// line or a NUL character.
+	ErrInvalidPushOption = errors.New("push options must not contain new line or NUL characters")
+)
+
 // PushOptions describes how a push should be performed.
 type PushOptions struct {
 	// RemoteName is the name of the remote to be pushed to.
@@ -181,9 +457,57 @@ type PushOptions struct {
 	RefSpecs []config.RefSpec
 	// Auth credentials, if required, to use with the remote repository.
 	Auth transport.AuthMethod
+	// HTTPOptions, if not nil, override the http.<url>.* config of the
+	// requests sent to an HTTP remote.
+	HTTPOptions *transport.HTTPOptions
 	// Progress is where the human readable information sent by the server is
 	// stored, if nil nothing is stored.
 	Progress sideband.Progress
+	// NoVerify bypasses the pre-push hook, the Hooks.PrePush callback of the
+	// repository is still called.
+	NoVerify bool
+	// Tags pushes all the local tags missing on the remote, along with the
+	// references matched by RefSpecs, as `git push --tags` does.
+	Tags bool
+	// FollowTags pushes the annotated tags missing on the remote that point
+	// into the history of the commits being pushed, as `git push
+	// --follow-tags` does.
+	FollowTags bool
+	// Mirror makes the references of the remote mirror all the local ones:
+	// they are force updated, and the ones missing locally are deleted, as
+	// `git push --mirror` does. It can't be combined with RefSpecs, and is
+	// the default for the remotes configured as mirror.
+	Mirror bool
+	// DryRun negotiates with the remote the references to update, without
+	// sending any object nor updating any reference, local or remote. The
+	// pre-push hook is not run. The updates are reported to Updated and
+	// PackSize.
+	DryRun bool
+	// Updated, if not nil, is called with every reference of the remote
+	// processed by the push, including the up-to-date and rejected ones,
+	// with the outcome of its update, or the one it would have with DryRun.
+	Updated func(*RefResult)
+	// PackSize, if not nil, is called with DryRun with the number of objects
+	// that would be sent and their total size, an upper bound of the size of
+	// the packfile.
+	PackSize func(objects int, size int64)
+	// DisabledCapabilities are the optional capabilities never requested,
+	// even if advertised by the server, to work around the servers
+	// mishandling them, e.g. side-band-64k, which falls back to side-band,
+	// or ofs-delta. They are also disabled by the config, ofs-delta by
+	// repack.useDeltaBaseOffset and the side-bands by sendpack.sideband.
+	DisabledCapabilities []capability.Capability
+	// Options are the push options sent to the server, as `git push
+	// --push-option` does, e.g. "topic=foo" for the code review servers
+	// receiving the pushes to refs/for/<branch>. The server must support
+	// the push-options capability.
+	Options []string
+	// PrePush, if not nil, is called with the reference updates computed
+	// for the push, before the packfile is generated, even with NoVerify or
+	// DryRun. It returns the updates to send, which may be fewer or differ
+	// from the given ones, a non-nil error aborts the push. The Hooks.PrePush
+	// callback and the pre-push hook receive the returned updates.
+	PrePush func(updates []*RefUpdate) ([]*RefUpdate, error)
 }
 
 // Validate validates the fields and sets the default values.
@@ -192,6 +516,14 @@ func (o *PushOptions) Validate() error {
 		o.RemoteName = DefaultRemoteName
 	}
 
+	if o.Mirror {
+		if len(o.RefSpecs) != 0 {
+			return ErrMirrorRefSpecs
+		}
+
+		o.RefSpecs = []config.RefSpec{config.MirrorRefSpec}
+	}
+
 	if len(o.RefSpecs) == 0 {
 		o.RefSpecs = []config.RefSpec{
 			config.RefSpec(config.DefaultPushRefSpec),
@@ -204,9 +536,41 @@ func (o *PushOptions) Validate() error {
 		}
 	}
 
+	for _, opt := range o.Options {
+		if strings.ContainsAny(opt, "\n\x00") {
+			return ErrInvalidPushOption
+		}
+	}
+
 	return nil
 }
 
+var (
+	// ErrInvalidSubmoduleUpdateMode is returned updating a submodule with an
+	// unknown update mode.
+	ErrInvalidSubmoduleUpdateMode = errors.New("invalid submodule update mode")
+)
+
+// SubmoduleUpdateMode defines how a submodule is updated to the commit
+// expected by the superproject.
+type SubmoduleUpdateMode string
+
+const (
+	// CheckoutSubmoduleUpdate checks out the commit at the submodule, in
+	// detached HEAD. This is the default mode.
+	CheckoutSubmoduleUpdate SubmoduleUpdateMode = "checkout"
+	// RebaseSubmoduleUpdate rebases the current branch of the submodule onto
+	// the commit. Only fast-forwards are supported, ErrNonFastForwardUpdate
+	// is returned otherwise.
+	RebaseSubmoduleUpdate SubmoduleUpdateMode = "rebase"
+	// MergeSubmoduleUpdate merges the commit into the current branch of the
+	// submodule. Only fast-forwards are supported, ErrNonFastForwardUpdate
+	// is returned otherwise.
+	MergeSubmoduleUpdate SubmoduleUpdateMode = "merge"
+	// NoneSubmoduleUpdate doesn't update the submodule.
+	NoneSubmoduleUpdate SubmoduleUpdateMode = "none"
+)
+
 // SubmoduleUpdateOptions describes how a submodule update should be performed.
 type SubmoduleUpdateOptions struct {
 	// Init, if true initializes the submodules recorded in the index.
@@ -220,11 +584,143 @@ type SubmoduleUpdateOptions struct {
 	RecurseSubmodules SubmoduleRescursivity
 	// Auth credentials, if required, to use with the remote repository.
 	Auth transport.AuthMethod
+	// Remote updates the submodules to the tip of their remote-tracking
+	// branch instead of the commit recorded at the superproject, as git
+	// submodule update --remote does. The branch is submodule.<name>.branch,
+	// "." meaning the current branch of the superproject, or the HEAD of the
+	// remote if empty.
+	Remote bool
+	// Mode is how the submodules are updated, overriding
+	// submodule.<name>.update. The submodules cloned by the update are
+	// always checked out.
+	Mode SubmoduleUpdateMode
+	// Depth limits the fetching of the submodules to the given number of
+	// commits from the tip of every remote branch.
+	Depth int
+	// NoRecommendShallow ignores submodule.<name>.shallow, cloning the
+	// submodules recommended as shallow with their whole history.
+	NoRecommendShallow bool
+	// Jobs is the number of submodules updated in parallel, overriding
+	// submodule.fetchJobs.
+	Jobs int
+}
+
+// Validate validates the fields and sets the default values.
+func (o *SubmoduleUpdateOptions) Validate() error {
+	switch o.Mode {
+	case "", CheckoutSubmoduleUpdate, RebaseSubmoduleUpdate,
+		MergeSubmoduleUpdate, NoneSubmoduleUpdate:
+	default:
+		return ErrInvalidSubmoduleUpdateMode
+	}
+
+	return nil
+}
+
+// SubmoduleDeinitOptions describes how a submodule deinit should be performed.
+type SubmoduleDeinitOptions struct {
+	// Force removes the worktree of the submodules even if it contains local
+	// modifications.
+	Force bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *SubmoduleDeinitOptions) Validate() error {
+	return nil
+}
+
+var (
+	// ErrMissingSubtreePrefix is returned when the prefix of a subtree is
+	// empty.
+	ErrMissingSubtreePrefix = errors.New("subtree prefix is required")
+	// ErrMissingSubtreeCommit is returned when the commit added or merged
+	// as a subtree is zero.
+	ErrMissingSubtreeCommit = errors.New("subtree commit is required")
+)
+
+// SubtreeSplitOptions describes how a subtree split should be performed.
+type SubtreeSplitOptions struct {
+	// Prefix is the slash separated path of the subdirectory split.
+	Prefix string
+	// Commit is the commit whose history is split, by default HEAD.
+	Commit plumbing.Hash
+	// Branch, if not empty, is the branch created, or fast-forwarded, to
+	// point to the split.
+	Branch plumbing.ReferenceName
+}
+
+// Validate validates the fields and sets the default values.
+func (o *SubtreeSplitOptions) Validate(r *Repository) error {
+	if o.Prefix = cleanSubtreePrefix(o.Prefix); o.Prefix == "" {
+		return ErrMissingSubtreePrefix
+	}
+
+	if o.Commit.IsZero() {
+		head, err := r.Head()
+		if err != nil {
+			return err
+		}
+
+		o.Commit = head.Hash()
+	}
+
+	return nil
+}
+
+// SubtreeOptions describes how a subtree add or merge should be performed.
+type SubtreeOptions struct {
+	// Prefix is the slash separated path of the subtree.
+	Prefix string
+	// Commit is the commit added or merged, usually fetched from the
+	// repository of the subtree.
+	Commit plumbing.Hash
+	// Squash adds or merges a single commit with the tree of Commit, instead
+	// of the whole history of Commit.
+	Squash bool
+	// Message is the message of the merge commit, by default the one of git
+	// subtree, with the trailers recognized by subtree split and merge.
+	Message string
+	// Author is the author's signature of the commits.
+	Author *object.Signature
+	// Committer is the committer's signature of the commits. If Committer is
+	// nil the Author signature is used.
+	Committer *object.Signature
+}
+
+// Validate validates the fields and sets the default values.
+func (o *SubtreeOptions) Validate(r *Repository) error {
+	if o.Prefix = cleanSubtreePrefix(o.Prefix); o.Prefix == "" {
+		return ErrMissingSubtreePrefix
+	}
+
+	if o.Commit.IsZero() {
+		return ErrMissingSubtreeCommit
+	}
+
+	if o.Author == nil {
+		return ErrMissingAuthor
+	}
+
+	if o.Committer == nil {
+		o.Committer = o.Author
+	}
+
+	return nil
+}
+
+func cleanSubtreePrefix(prefix string) string {
+	prefix = path.Clean("/" + strings.Replace(prefix, "\\", "/", -1))
+	return strings.TrimPrefix(prefix, "/")
 }
 
 var (
 	ErrBranchHashExclusive  = errors.New("Branch and Hash are mutually exclusive")
 	ErrCreateRequiresBranch = errors.New("Branch is mandatory when Create is used")
+	ErrStartRequiresCreate  = errors.New("Start can only be used with Create")
+	ErrStartHashExclusive   = errors.New("Start and Hash are mutually exclusive")
+	// ErrDetachCreateExclusive is returned checking out with both Detach and
+	// Create.
+	ErrDetachCreateExclusive = errors.New("Detach and Create are mutually exclusive")
 )
 
 // CheckoutOptions describes how a checkout 31operation should be performed.
@@ -236,9 +732,29 @@ type CheckoutOptions struct {
 	Branch plumbing.ReferenceName
 	// Create a new branch named Branch and start it at Hash.
 	Create bool
+	// Start is the reference the branch created by Create starts at, instead
+	// of Hash. If it is a remote-tracking branch, or a local branch, the new
+	// branch tracks it according to branch.autoSetupMerge, as
+	// `git checkout -b <branch> <start>` does.
+	Start plumbing.ReferenceName
 	// Force, if true when switching branches, proceed even if the index or the
 	// working tree differs from HEAD. This is used to throw away local changes
 	Force bool
+	// Detach checks out the commit of Branch with HEAD in detached mode,
+	// instead of pointing to the branch, as `git checkout --detach` does.
+	Detach bool
+	// Workers is the number of files read from the storage and written to
+	// the worktree concurrently, by default the files are written one by
+	// one. A value greater than 1 requires the worktree filesystem and the
+	// storage to be safe for concurrent use, as the OS filesystem is.
+	Workers int
+	// Progress, if not nil, receives the progress of the update of the
+	// worktree.
+	Progress CheckoutProgress
+	// Autostash stashes the local changes of the tracked files before
+	// switching, reapplying them once the checkout is completed. It is
+	// ignored with Force.
+	Autostash bool
 }
 
 // Validate validates the fields and sets the default values.
@@ -251,6 +767,18 @@ func (o *CheckoutOptions) Validate() error {
 		return ErrCreateRequiresBranch
 	}
 
+	if o.Start != "" && !o.Create {
+		return ErrStartRequiresCreate
+	}
+
+	if o.Start != "" && !o.Hash.IsZero() {
+		return ErrStartHashExclusive
+	}
+
+	if o.Detach && o.Create {
+		return ErrDetachCreateExclusive
+	}
+
 	if o.Branch == "" {
 		o.Branch = plumbing.Master
 	}
@@ -281,6 +809,17 @@ const (
 	// resets the head to <commit>, just like all modes do). This leaves all
 	// your changed files "Changes to be committed", as git status would put it.
 	SoftReset
+	// KeepReset resets the index and updates the files in the working tree
+	// that are different between Commit and HEAD, the local changes of the
+	// other files are kept, as unstaged changes.
+	//
+	// If a file that is different between Commit and HEAD has local changes,
+	// staged or not, reset is aborted.
+	KeepReset
+)
+
+var (
+	ErrResetPathsMode = errors.New("paths can only be reset with MixedReset")
 )
 
 // ResetOptions describes how a reset operation should be performed.
@@ -291,10 +830,25 @@ type ResetOptions struct {
 	// the index (resetting it to the tree of Commit) and the working tree
 	// depending on Mode. If empty MixedReset is used.
 	Mode ResetMode
+	// Paths, if not empty, limits the reset to the index entries matching
+	// the given files or directories, which are set to the ones at Commit,
+	// as `git reset <commit> -- <paths>`. HEAD and the worktree are not
+	// changed. Only MixedReset can be used with Paths.
+	Paths []string
+	// Workers is the number of files written concurrently when the working
+	// tree is updated, see CheckoutOptions.Workers.
+	Workers int
+	// Progress, if not nil, receives the progress of the update of the
+	// working tree.
+	Progress CheckoutProgress
 }
 
 // Validate validates the fields and sets the default values.
 func (o *ResetOptions) Validate(r *Repository) error {
+	if len(o.Paths) != 0 && o.Mode != MixedReset {
+		return ErrResetPathsMode
+	}
+
 	if o.Commit == plumbing.ZeroHash {
 		ref, err := r.Head()
 		if err != nil {
@@ -307,6 +861,47 @@ func (o *ResetOptions) Validate(r *Repository) error {
 	return nil
 }
 
+var (
+	ErrNoRestorePaths = errors.New("you must specify path(s) to restore")
+)
+
+// RestoreOptions describes how a restore operation should be performed.
+type RestoreOptions struct {
+	// Source is the commit the paths are restored from. If empty, the paths
+	// are restored from the index when only the worktree is restored, and
+	// from HEAD otherwise.
+	Source plumbing.Hash
+	// Staged restores the paths at the index.
+	Staged bool
+	// Worktree restores the paths at the worktree. If neither Staged nor
+	// Worktree are set, only the worktree is restored.
+	Worktree bool
+	// Paths to be restored, a directory restores all the files within it.
+	Paths []string
+}
+
+// Validate validates the fields and sets the default values.
+func (o *RestoreOptions) Validate(r *Repository) error {
+	if len(o.Paths) == 0 {
+		return ErrNoRestorePaths
+	}
+
+	if !o.Staged && !o.Worktree {
+		o.Worktree = true
+	}
+
+	if o.Source.IsZero() && o.Staged {
+		ref, err := r.Head()
+		if err != nil {
+			return err
+		}
+
+		o.Source = ref.Hash()
+	}
+
+	return nil
+}
+
 type LogOrder int8
 
 const (
@@ -328,12 +923,137 @@ type LogOptions struct {
 	// set Order=LogOrderCommitterTime for ordering by committer time (more compatible with `git log`)
 	// set Order=LogOrderBSF for Breadth-first search
 	Order LogOrder
+
+	// UseMailmap replaces the names and emails of the authors and committers
+	// of the commits by their canonical ones, given by Repository.Mailmap.
+	UseMailmap bool
+}
+
+// CommitChangesOptions describes how a commit of a set of changes, made
+// without a worktree, should be performed.
+type CommitChangesOptions struct {
+	// Author is the author's signature of the commit.
+	Author *object.Signature
+	// Committer is the committer's signature of the commit. If Committer is
+	// nil the Author signature is used.
+	Committer *object.Signature
+	// Branch is the reference updated to point to the new commit, by
+	// default the branch HEAD points to, or HEAD itself if it is detached.
+	Branch plumbing.ReferenceName
+	// Parent is the commit the changes are applied to, by default the commit
+	// Branch points to. The new commit has no parents if Parent is zero and
+	// Branch does not exist. The update of Branch fails if it no longer
+	// points to Parent.
+	Parent plumbing.Hash
+	// Changes are the changes applied to the tree of Parent, in order.
+	Changes []FileChange
+	// AllowEmpty allows a commit with the same tree as its parent, by
+	// default ErrEmptyCommit is returned.
+	AllowEmpty bool
+	// Signer, if not nil, signs the commit.
+	Signer Signer
+}
+
+// FileChange is a change of a file committed by Repository.CommitChanges.
+type FileChange struct {
+	// Path is the slash separated path of the file.
+	Path string
+	// Content is the new content of the file.
+	Content io.Reader
+	// Mode is the mode of the file, filemode.Regular by default.
+	Mode filemode.FileMode
+	// Delete removes the file, or the directory, at Path.
+	Delete bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *CommitChangesOptions) Validate(r *Repository) error {
+	if o.Author == nil {
+		return ErrMissingAuthor
+	}
+
+	if o.Committer == nil {
+		o.Committer = o.Author
+	}
+
+	for i, c := range o.Changes {
+		if c.Path == "" {
+			return ErrMissingChangePath
+		}
+
+		if !c.Delete && c.Content == nil {
+			return ErrMissingChangeContent
+		}
+
+		if c.Mode == filemode.Empty {
+			o.Changes[i].Mode = filemode.Regular
+		}
+	}
+
+	if o.Branch == "" {
+		head, err := r.Storer.Reference(plumbing.HEAD)
+		if err != nil {
+			return err
+		}
+
+		o.Branch = plumbing.HEAD
+		if head.Type() == plumbing.SymbolicReference {
+			o.Branch = head.Target()
+		}
+	}
+
+	return nil
 }
 
 var (
-	ErrMissingAuthor = errors.New("author field is required")
+	ErrMissingAuthor    = errors.New("author field is required")
+	ErrMissingCommitter = errors.New("committer field is required")
+	ErrParentsAndAmend  = errors.New("Parents and Amend are mutually exclusive")
+	// ErrMissingChangePath is returned by CommitChanges when a change has
+	// no path.
+	ErrMissingChangePath = errors.New("change path is required")
+	// ErrMissingChangeContent is returned by CommitChanges when a change
+	// adding a file has no content.
+	ErrMissingChangeContent = errors.New("change content is required")
+	// ErrEmptyCommit is returned by CommitChanges when the changes leave the
+	// tree of the parent unchanged.
+	ErrEmptyCommit = errors.New("the changes leave the tree unchanged")
+	// ErrInvalidCleanupMode is returned committing with an unknown cleanup
+	// mode, given by the options or commit.cleanup.
+	ErrInvalidCleanupMode = errors.New("invalid commit message cleanup mode")
 )
 
+// CleanupMode defines how the commit messages are cleaned up, as the values
+// of commit.cleanup.
+type CleanupMode string
+
+const (
+	// CleanupVerbatim keeps the message as it is.
+	CleanupVerbatim CleanupMode = "verbatim"
+	// CleanupWhitespace removes the leading and trailing blank lines, the
+	// trailing whitespace of every line and collapses the consecutive blank
+	// lines.
+	CleanupWhitespace CleanupMode = "whitespace"
+	// CleanupStrip cleans up the whitespace as CleanupWhitespace, and also
+	// removes the comment lines.
+	CleanupStrip CleanupMode = "strip"
+	// CleanupScissors cleans up the whitespace as CleanupWhitespace, and
+	// also removes everything from the scissors line on, a comment line
+	// with ">8" between two rows of 24 hyphens.
+	CleanupScissors CleanupMode = "scissors"
+	// CleanupDefault is CleanupWhitespace, since the message isn't edited.
+	CleanupDefault CleanupMode = "default"
+)
+
+func (m CleanupMode) validate() error {
+	switch m {
+	case CleanupVerbatim, CleanupWhitespace, CleanupStrip, CleanupScissors, CleanupDefault:
+		return nil
+	}
+
+	return ErrInvalidCleanupMode
+}
+
 // CommitOptions describes how a commit operation should be performed.
 type CommitOptions struct {
 	// All automatically stage files that have been modified and deleted, but
@@ -345,12 +1065,44 @@ type CommitOptions struct {
 	// nil the Author signature is used.
 	Committer *object.Signature
 	// Parents are the parents commits for the new commit, by default when
-	// len(Parents) is zero, the hash of HEAD reference is used.
+	// len(Parents) is zero, the hash of HEAD reference is used, followed by
+	// the commits at MERGE_HEAD if a merge is in progress.
 	Parents []plumbing.Hash
+	// NoVerify bypasses the pre-commit and commit-msg hooks, the
+	// Hooks.PreCommit callback of the repository is still called.
+	NoVerify bool
+	// Signer, if not nil, signs the commit, NewOpenPGPSigner can be used to
+	// sign with an OpenPGP key.
+	Signer Signer
+	// Amend replaces the HEAD commit with the new commit, which takes the
+	// parents of the replaced one. If Author is nil the author of the
+	// replaced commit is kept, and an empty message keeps its message. The
+	// amend is recorded at the reflogs of HEAD and the checked-out branch.
+	Amend bool
+	// Cleanup is how the message is cleaned up once the hooks have run. If
+	// empty, commit.cleanup is used, keeping the message verbatim if it isn't
+	// set either. The comment lines start with core.commentChar.
+	Cleanup CleanupMode
+	// Trailers are added to the trailer block of the message, skipping the
+	// ones already there, as `git commit --trailer` does.
+	Trailers []object.Trailer
+	// Signoff adds a Signed-off-by trailer with the committer to the
+	// message, as `git commit --signoff` does.
+	Signoff bool
 }
 
 // Validate validates the fields and sets the default values.
 func (o *CommitOptions) Validate(r *Repository) error {
+	if o.Cleanup != "" {
+		if err := o.Cleanup.validate(); err != nil {
+			return err
+		}
+	}
+
+	if o.Amend {
+		return o.validateAmend(r)
+	}
+
 	if o.Author == nil {
 		return ErrMissingAuthor
 	}
@@ -368,20 +1120,202 @@ func (o *CommitOptions) Validate(r *Repository) error {
 		if head != nil {
 			o.Parents = []plumbing.Hash{head.Hash()}
 		}
+
+		merging, err := r.MergeHead()
+		if err != nil {
+			return err
+		}
+
+		o.Parents = append(o.Parents, merging...)
+	}
+
+	return nil
+}
+
+func (o *CommitOptions) validateAmend(r *Repository) error {
+	if len(o.Parents) != 0 {
+		return ErrParentsAndAmend
+	}
+
+	head, err := r.Head()
+	if err != nil {
+		return err
+	}
+
+	commit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return err
 	}
 
+	if o.Author == nil {
+		if o.Committer == nil {
+			return ErrMissingCommitter
+		}
+
+		o.Author = &commit.Author
+	}
+
+	if o.Committer == nil {
+		o.Committer = o.Author
+	}
+
+	o.Parents = commit.ParentHashes
 	return nil
 }
 
+var (
+	ErrMissingTagger  = errors.New("tagger field is required")
+	ErrMissingMessage = errors.New("message field is required")
+)
+
+// CreateTagOptions describes how a tag object should be created.
+type CreateTagOptions struct {
+	// Tagger defines the signature of the tag creator.
+	Tagger *object.Signature
+	// Message defines the annotation of the tag. It is canonicalized during
+	// validation into the format expected by git - no leading whitespace and
+	// ending in a newline.
+	Message string
+	// Signer, if not nil, signs the tag, NewOpenPGPSigner can be used to
+	// sign with an OpenPGP key.
+	Signer Signer
+}
+
+// Validate validates the fields and sets the default values.
+func (o *CreateTagOptions) Validate() error {
+	if o.Tagger == nil {
+		return ErrMissingTagger
+	}
+
+	if o.Message == "" {
+		return ErrMissingMessage
+	}
+
+	o.Message = strings.TrimSpace(o.Message) + "\n"
+	return nil
+}
+
+// ReplaceTagOptions describes how a tag is replaced by Repository.ReplaceTag.
+type ReplaceTagOptions struct {
+	// Tagger defines the signature of the tag creator, recorded at the
+	// reflog of the tag.
+	Tagger *object.Signature
+	// Message, if not empty, is the annotation of the tag, which is then an
+	// annotated tag, otherwise a lightweight tag is created.
+	Message string
+	// Signer, if not nil, signs the annotated tag.
+	Signer Signer
+}
+
+// Validate validates the fields and sets the default values.
+func (o *ReplaceTagOptions) Validate() error {
+	if o.Tagger == nil {
+		return ErrMissingTagger
+	}
+
+	return nil
+}
+
+// ListTagsOptions describes how Repository.ListTags lists the tags.
+type ListTagsOptions struct {
+	// Sort is the order of the tags, by name if not set.
+	Sort TagSort
+	// Reverse reverses the order of the tags.
+	Reverse bool
+	// Kind lists only the annotated or only the lightweight tags.
+	Kind TagKind
+}
+
 // ListOptions describes how a remote list should be performed.
 type ListOptions struct {
 	// Auth credentials, if required, to use with the remote repository.
 	Auth transport.AuthMethod
+	// HTTPOptions, if not nil, override the http.<url>.* config of the
+	// requests sent to an HTTP remote.
+	HTTPOptions *transport.HTTPOptions
+	// Patterns, if any, are shell patterns only the references matching
+	// any of them are listed. They are matched as `git ls-remote` does,
+	// against the full names of the references and against their trailing
+	// components, e.g. "master" or "tags/v1.*".
+	Patterns []string
+	// Heads lists only the references at refs/heads, they are listed along
+	// with the references at refs/tags if Tags is also set.
+	Heads bool
+	// Tags lists only the references at refs/tags, as Heads does.
+	Tags bool
+	// RefPrefixes, if any, lists only the references starting with any of
+	// them, e.g. HEAD or refs/heads/. Unlike Patterns, they are sent to the
+	// servers speaking the protocol v2, as Heads and Tags are, so the ones
+	// with lots of references don't send all of them.
+	RefPrefixes []string
+	// Peeled lists, after each annotated tag advertised with its peeled
+	// value, a reference named as the tag with the "^{}" suffix pointing to
+	// the object the tag points to, as `git ls-remote` does.
+	Peeled bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *ListOptions) Validate() error {
+	for _, p := range o.Patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ArchiveOptions describes how a remote archive should be performed.
+type ArchiveOptions struct {
+	// Auth credentials, if required, to use with the remote repository.
+	Auth transport.AuthMethod
+	// Revision is the tree-ish archived by the server, e.g. a branch or a
+	// tag name, HEAD by default.
+	Revision string
+	// Format of the archive, archive.Tar by default.
+	Format archive.Format
+	// Prefix is prepended to the paths of the archived files, e.g. foo/
+	// archives them under the foo directory.
+	Prefix string
+	// Paths, if not empty, restricts the archive to the given files and
+	// directories.
+	Paths []string
+	// Progress is where the human readable information sent by the server
+	// is stored, if nil nothing is stored.
+	Progress sideband.Progress
+}
+
+// Validate validates the fields and sets the default values.
+func (o *ArchiveOptions) Validate() error {
+	if o.Revision == "" {
+		o.Revision = plumbing.HEAD.String()
+	}
+
+	switch o.Format {
+	case archive.Tar, archive.Zip, "":
+	default:
+		return archive.ErrUnsupportedFormat
+	}
+
+	return nil
 }
 
 // CleanOptions describes how a clean should be performed.
 type CleanOptions struct {
+	// Dir removes the untracked directories too, and the untracked files
+	// within subdirectories. Without it, only the untracked files at the
+	// root of the worktree, or within the directories matched by Paths, are
+	// removed.
 	Dir bool
+	// Ignored removes the ignored files too, as `git clean -x`.
+	Ignored bool
+	// OnlyIgnored removes only the ignored files, as `git clean -X`.
+	OnlyIgnored bool
+	// DryRun doesn't remove anything, just returns what would be removed.
+	DryRun bool
+	// Paths limits the clean to the given files or directories, if empty
+	// the whole worktree is cleaned.
+	Paths []string
 }
 
 // GrepOptions describes how a grep should be performed.
@@ -421,6 +1355,60 @@ func (o *GrepOptions) Validate(w *Worktree) error {
 	return nil
 }
 
+// InitOptions describes how an init should be performed.
+type InitOptions struct {
+	// DefaultBranch is the branch HEAD points to, as "refs/heads/main" or
+	// just "main". If empty, the init.defaultBranch option of the config of
+	// the user and of the system is used for the repositories at the OS
+	// filesystem, and master otherwise.
+	DefaultBranch plumbing.ReferenceName
+	// ObjectFormat is the hash algorithm of the objects, only "sha1", the
+	// default, is supported.
+	ObjectFormat string
+	// TemplateDir is the directory whose files, such as hooks, info/exclude
+	// and description, are copied into the git directory, except the ones
+	// starting with a dot. If empty, GIT_TEMPLATE_DIR or the init.templateDir
+	// option of the config of the user and of the system is used for the
+	// repositories at the OS filesystem. It's ignored by the storages not
+	// based on a filesystem.
+	TemplateDir string
+}
+
+// Validate validates the fields and sets the default values.
+func (o *InitOptions) Validate() error {
+	if o.ObjectFormat != "" && o.ObjectFormat != "sha1" {
+		return ErrUnsupportedObjectFormat
+	}
+
+	if o.DefaultBranch == "" {
+		return nil
+	}
+
+	if !strings.HasPrefix(o.DefaultBranch.String(), "refs/") {
+		o.DefaultBranch = plumbing.ReferenceName("refs/heads/" + o.DefaultBranch.String())
+	}
+
+	if !o.DefaultBranch.IsBranch() || o.DefaultBranch.Short() == "" ||
+		strings.ContainsAny(o.DefaultBranch.String(), " ~^:?*[\\") ||
+		strings.Contains(o.DefaultBranch.String(), "..") ||
+		strings.HasSuffix(o.DefaultBranch.String(), "/") {
+		return ErrInvalidDefaultBranch
+	}
+
+	return nil
+}
+
+// PlainInitOptions describes how a plain init should be performed.
+type PlainInitOptions struct {
+	InitOptions
+	// Bare creates a repository without worktree, with the git directory at
+	// the given path.
+	Bare bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *PlainInitOptions) Validate() error { return o.InitOptions.Validate() }
+
 // PlainOpenOptions describes how opening a plain repository should be
 // performed.
 type PlainOpenOptions struct {
@@ -431,3 +1419,158 @@ type PlainOpenOptions struct {
 
 // Validate validates the fields and sets the default values.
 func (o *PlainOpenOptions) Validate() error { return nil }
+
+// ErrMissingExportTarget is returned by Repository.Export when neither a
+// Filesystem nor a Directory are given.
+var ErrMissingExportTarget = errors.New("export filesystem or directory is required")
+
+// ExportOptions describes how Repository.Export materializes a tree.
+type ExportOptions struct {
+	// Hash is the commit, tag or tree exported, by default HEAD.
+	Hash plumbing.Hash
+	// Filesystem is where the files are written.
+	Filesystem billy.Filesystem
+	// Directory is the path of the directory where the files are written,
+	// created if needed, when Filesystem is nil.
+	Directory string
+	// Paths, if not empty, limits the export to the given slash separated
+	// paths of files and directories of the tree.
+	Paths []string
+}
+
+// Validate validates the fields and sets the default values.
+func (o *ExportOptions) Validate(r *Repository) error {
+	if o.Filesystem == nil {
+		if o.Directory == "" {
+			return ErrMissingExportTarget
+		}
+
+		o.Filesystem = osfs.New(o.Directory)
+	}
+
+	if o.Hash.IsZero() {
+		head, err := r.Head()
+		if err != nil {
+			return err
+		}
+
+		o.Hash = head.Hash()
+	}
+
+	return nil
+}
+
+// CatFileOptions describes how the objects are written by
+// Repository.CatFileBatch.
+type CatFileOptions struct {
+	// Contents writes the content of every object after its type and size,
+	// as `git cat-file --batch` does, otherwise only the type and the size are
+	// written, as `git cat-file --batch-check` does.
+	Contents bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *CatFileOptions) Validate() error { return nil }
+
+const (
+	// DefaultBlameMoveScore is the default BlameOptions.MoveScore, as git's.
+	DefaultBlameMoveScore = 20
+	// DefaultBlameCopyScore is the default BlameOptions.CopyScore, as git's.
+	DefaultBlameCopyScore = 40
+)
+
+// BlameOptions describes how a blame should be performed.
+type BlameOptions struct {
+	// Since stops the blame at the commits older than the given time, the
+	// lines not changed since are attributed to the boundary commits, as
+	// git blame --since does.
+	Since time.Time
+	// Incremental, if not nil, is called with every hunk of lines as soon as
+	// it's attributed, in no particular order, as git blame --incremental
+	// does. A non-nil error stops the blame.
+	Incremental func(*BlameHunk) error
+	// DetectMoves detects the blocks of lines moved or copied within the
+	// file, attributing them to the commits originally introducing them
+	// instead of the commits moving them, as git blame -M does.
+	DetectMoves bool
+	// MoveScore is the minimum number of alphanumeric characters a block of
+	// lines must have to be detected as moved, DefaultBlameMoveScore if 0.
+	MoveScore int
+	// DetectCopies detects, in addition to DetectMoves, the blocks of lines
+	// moved or copied from other files modified by the same commit, as git
+	// blame -C does.
+	DetectCopies bool
+	// CopyScore is the minimum number of alphanumeric characters a block of
+	// lines must have to be detected as copied from another file,
+	// DefaultBlameCopyScore if 0.
+	CopyScore int
+	// IgnoreRevs are the commits whose changes are ignored, as git blame
+	// --ignore-rev does, such as the commits reformatting the code. The
+	// lines changed by them are attributed as the lines they replace at the
+	// first parent, the lines only added by them are still attributed to
+	// them.
+	IgnoreRevs []plumbing.Hash
+	// UseMailmap reports the canonical emails of the authors at the lines,
+	// and the canonical identities at the commits of the hunks, given by
+	// the .mailmap of the blamed commit, or by Repository.Mailmap when
+	// blaming with Repository.Blame.
+	UseMailmap bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *BlameOptions) Validate() error {
+	if o.MoveScore == 0 {
+		o.MoveScore = DefaultBlameMoveScore
+	}
+
+	if o.CopyScore == 0 {
+		o.CopyScore = DefaultBlameCopyScore
+	}
+
+	return nil
+}
+
+// ShortlogOptions describes how Repository.Shortlog summarizes the commits.
+type ShortlogOptions struct {
+	// Committer groups the commits by committer instead of by author.
+	Committer bool
+	// Email groups the commits by name and email instead of only by name.
+	Email bool
+	// Summary only counts the commits, without their subjects.
+	Summary bool
+	// Numbered sorts the authors by their number of commits, descending,
+	// instead of by name.
+	Numbered bool
+	// NoMerges skips the merge commits.
+	NoMerges bool
+	// UseMailmap groups the commits by the canonical identities of their
+	// authors, given by Repository.Mailmap.
+	UseMailmap bool
+}
+
+// NameRevOptions describes how Repository.NameRev names the commits.
+type NameRevOptions struct {
+	// Tags only uses the tags to name the commits, and names them without
+	// the "tags/" prefix.
+	Tags bool
+	// Refs only uses the references matching any of the given shell
+	// patterns, the patterns are matched against the full name of the
+	// references and against any of their trailing components, as
+	// `git name-rev --refs` does. The names of the references matched by
+	// their trailing components are shortened.
+	Refs []string
+	// Exclude doesn't use the references matching any of the given shell
+	// patterns, matched as the Refs patterns are.
+	Exclude []string
+}
+
+// Validate validates the fields and sets the default values.
+func (o *NameRevOptions) Validate() error {
+	for _, p := range append(append([]string{}, o.Refs...), o.Exclude...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
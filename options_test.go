@@ -33,3 +33,21 @@ func (s *OptionsSuite) TestCommitOptionsCommitter(c *C) {
 
 	c.Assert(o.Committer, Equals, o.Author)
 }
+
+func (s *OptionsSuite) TestCloneOptionsShallowSubmodules(c *C) {
+	o := CloneOptions{URL: "foo", ShallowSubmodules: true}
+	c.Assert(o.Validate(), IsNil)
+	c.Assert(o.SubmoduleDepth, Equals, 1)
+
+	o = CloneOptions{URL: "foo", Depth: 5, ShallowSubmodules: true}
+	c.Assert(o.Validate(), IsNil)
+	c.Assert(o.SubmoduleDepth, Equals, 5)
+
+	o = CloneOptions{URL: "foo", Depth: 5, SubmoduleDepth: 2}
+	c.Assert(o.Validate(), IsNil)
+	c.Assert(o.SubmoduleDepth, Equals, 2)
+
+	o = CloneOptions{URL: "foo", Depth: 5}
+	c.Assert(o.Validate(), IsNil)
+	c.Assert(o.SubmoduleDepth, Equals, 0)
+}
@@ -24,3 +24,17 @@ type Object interface {
 	// Clear clears every object from the cache.
 	Clear()
 }
+
+// Offset is an interface to a cache of the objects of a packfile, keyed by
+// their offset in it, such as the objects resolved along a delta chain.
+type Offset interface {
+	// Put puts the object at the given offset into the cache. Whether this
+	// object will actually be put into the cache or not is implementation
+	// specific.
+	Put(offset int64, o plumbing.EncodedObject)
+	// Get gets the object at the given offset from the cache. The second
+	// return value is true if the object was returned, and false otherwise.
+	Get(offset int64) (plumbing.EncodedObject, bool)
+	// Clear clears every object from the cache.
+	Clear()
+}
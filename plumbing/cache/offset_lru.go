@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// DefaultOffsetMaxSize is the default maximum size of an OffsetLRU.
+const DefaultOffsetMaxSize FileSize = 32 * MiByte
+
+// OffsetLRU implements a cache of the objects of a packfile, keyed by their
+// offset, with an LRU eviction policy and a maximum size (measured in object
+// size).
+type OffsetLRU struct {
+	MaxSize FileSize
+
+	actualSize FileSize
+	ll         *list.List
+	cache      map[int64]*list.Element
+	mut        sync.Mutex
+}
+
+type offsetEntry struct {
+	offset int64
+	obj    plumbing.EncodedObject
+}
+
+// NewOffsetLRU creates a new OffsetLRU with the given maximum size. The
+// maximum size will never be exceeded.
+func NewOffsetLRU(maxSize FileSize) *OffsetLRU {
+	return &OffsetLRU{MaxSize: maxSize}
+}
+
+// NewOffsetLRUDefault creates a new OffsetLRU with the default cache size.
+func NewOffsetLRUDefault() *OffsetLRU {
+	return &OffsetLRU{MaxSize: DefaultOffsetMaxSize}
+}
+
+// Put puts the object at the given offset into the cache. If an object is
+// already cached at the offset, it will be replaced and marked as used.
+// Otherwise, it will be inserted, evicting the least recently used objects
+// to make room for it.
+func (c *OffsetLRU) Put(offset int64, obj plumbing.EncodedObject) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if c.cache == nil {
+		c.actualSize = 0
+		c.cache = make(map[int64]*list.Element, 1000)
+		c.ll = list.New()
+	}
+
+	objSize := FileSize(obj.Size())
+	if ee, ok := c.cache[offset]; ok {
+		c.ll.MoveToFront(ee)
+		e := ee.Value.(*offsetEntry)
+		c.actualSize += objSize - FileSize(e.obj.Size())
+		e.obj = obj
+		c.evict()
+		return
+	}
+
+	if objSize > c.MaxSize {
+		return
+	}
+
+	ee := c.ll.PushFront(&offsetEntry{offset: offset, obj: obj})
+	c.cache[offset] = ee
+	c.actualSize += objSize
+	c.evict()
+}
+
+// evict removes the least recently used objects until the cache fits its
+// maximum size.
+func (c *OffsetLRU) evict() {
+	for c.actualSize > c.MaxSize {
+		last := c.ll.Back()
+		e := last.Value.(*offsetEntry)
+
+		c.ll.Remove(last)
+		delete(c.cache, e.offset)
+		c.actualSize -= FileSize(e.obj.Size())
+	}
+}
+
+// Get returns the object at the given offset. It marks the object as used.
+// If no object is cached at the offset, (nil, false) will be returned.
+func (c *OffsetLRU) Get(offset int64) (plumbing.EncodedObject, bool) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	ee, ok := c.cache[offset]
+	if !ok {
+		return nil, false
+	}
+
+	c.ll.MoveToFront(ee)
+	return ee.Value.(*offsetEntry).obj, true
+}
+
+// Clear the content of this cache.
+func (c *OffsetLRU) Clear() {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.ll = nil
+	c.cache = nil
+	c.actualSize = 0
+}
@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"sync"
+
+	. "gopkg.in/check.v1"
+)
+
+type OffsetSuite struct{}
+
+var _ = Suite(&OffsetSuite{})
+
+func (s *OffsetSuite) TestPutGet(c *C) {
+	o := NewOffsetLRU(4 * Byte)
+	a := newObject("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", 1*Byte)
+	b := newObject("bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", 3*Byte)
+
+	o.Put(12, a)
+	o.Put(42, b)
+
+	obj, ok := o.Get(12)
+	c.Assert(ok, Equals, true)
+	c.Assert(obj, Equals, a)
+
+	obj, ok = o.Get(42)
+	c.Assert(ok, Equals, true)
+	c.Assert(obj, Equals, b)
+
+	_, ok = o.Get(13)
+	c.Assert(ok, Equals, false)
+}
+
+func (s *OffsetSuite) TestPutBigObject(c *C) {
+	o := NewOffsetLRU(2 * Byte)
+	o.Put(12, newObject("bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", 3*Byte))
+
+	obj, ok := o.Get(12)
+	c.Assert(ok, Equals, false)
+	c.Assert(obj, IsNil)
+}
+
+func (s *OffsetSuite) TestEvict(c *C) {
+	o := NewOffsetLRU(2 * Byte)
+	o.Put(1, newObject("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", 1*Byte))
+	o.Put(2, newObject("cccccccccccccccccccccccccccccccccccccccc", 1*Byte))
+
+	_, ok := o.Get(1)
+	c.Assert(ok, Equals, true)
+
+	o.Put(3, newObject("dddddddddddddddddddddddddddddddddddddddd", 1*Byte))
+
+	_, ok = o.Get(1)
+	c.Assert(ok, Equals, true)
+	_, ok = o.Get(2)
+	c.Assert(ok, Equals, false)
+	_, ok = o.Get(3)
+	c.Assert(ok, Equals, true)
+}
+
+func (s *OffsetSuite) TestReplace(c *C) {
+	o := NewOffsetLRU(3 * Byte)
+	o.Put(1, newObject("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", 1*Byte))
+	o.Put(2, newObject("cccccccccccccccccccccccccccccccccccccccc", 1*Byte))
+
+	e := newObject("eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee", 2*Byte)
+	o.Put(2, e)
+
+	obj, ok := o.Get(2)
+	c.Assert(ok, Equals, true)
+	c.Assert(obj, Equals, e)
+	_, ok = o.Get(1)
+	c.Assert(ok, Equals, true)
+	c.Assert(o.actualSize, Equals, 3*Byte)
+}
+
+func (s *OffsetSuite) TestClear(c *C) {
+	o := NewOffsetLRUDefault()
+	c.Assert(o.MaxSize, Equals, DefaultOffsetMaxSize)
+
+	o.Put(1, newObject("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", 1*Byte))
+	o.Clear()
+
+	_, ok := o.Get(1)
+	c.Assert(ok, Equals, false)
+}
+
+func (s *OffsetSuite) TestConcurrentAccess(c *C) {
+	o := NewOffsetLRU(2 * KiByte)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 1000; i++ {
+		wg.Add(3)
+		go func(i int) {
+			o.Put(int64(i), newObject("aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", FileSize(i)))
+			wg.Done()
+		}(i)
+
+		go func(i int) {
+			if i%30 == 0 {
+				o.Clear()
+			}
+			wg.Done()
+		}(i)
+
+		go func(i int) {
+			o.Get(int64(i))
+			wg.Done()
+		}(i)
+	}
+
+	wg.Wait()
+}
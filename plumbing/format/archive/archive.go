@@ -0,0 +1,312 @@
+// Package archive implements the tar and zip archives of the trees, as
+// produced by git-archive.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+// Format is the format of an archive.
+type Format string
+
+const (
+	// Tar is the tar format, the default one.
+	Tar Format = "tar"
+	// Zip is the zip format, with the files deflated.
+	Zip Format = "zip"
+)
+
+// ErrUnsupportedFormat is returned by Write if the format is not Tar nor Zip.
+var ErrUnsupportedFormat = errors.New("unsupported archive format")
+
+const (
+	// fileMode and execMode are the modes of the archived files, taking the
+	// default tar.umask of git, 0002.
+	fileMode = 0664
+	execMode = 0775
+)
+
+// Options describes how an archive is written.
+type Options struct {
+	// Format of the archive, Tar by default.
+	Format Format
+	// Prefix is prepended to the paths of the archived files, e.g. foo/
+	// archives them under the foo directory.
+	Prefix string
+	// Paths, if not empty, restricts the archive to the given files and
+	// directories.
+	Paths []string
+	// ModTime is the modification time of the archived files, usually the
+	// committer time of the archived commit, the current time if zero.
+	ModTime time.Time
+	// Commit, if not zero, is recorded as the comment of the archive, as
+	// git-archive does, so git get-tar-commit-id can read it back.
+	Commit plumbing.Hash
+}
+
+// Write writes the tree read from s as an archive to w.
+func Write(w io.Writer, s storer.EncodedObjectStorer, t *object.Tree, o *Options) error {
+	if o.ModTime.IsZero() {
+		o.ModTime = time.Now()
+	}
+
+	var aw archiveWriter
+	switch o.Format {
+	case Tar, "":
+		aw = newTarWriter(w, o)
+	case Zip:
+		aw = newZipWriter(w, o)
+	default:
+		return ErrUnsupportedFormat
+	}
+
+	if err := writeTree(aw, s, t, o); err != nil {
+		return err
+	}
+
+	return aw.Close()
+}
+
+func writeTree(aw archiveWriter, s storer.EncodedObjectStorer, t *object.Tree, o *Options) error {
+	if o.Prefix != "" && strings.HasSuffix(o.Prefix, "/") {
+		if err := aw.WriteDir(o.Prefix); err != nil {
+			return err
+		}
+	}
+
+	walker := object.NewTreeWalker(t, true, nil)
+	defer walker.Close()
+
+	for {
+		name, e, err := walker.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if !archivedPath(name, o.Paths) {
+			continue
+		}
+
+		path := o.Prefix + name
+		switch e.Mode {
+		case filemode.Dir, filemode.Submodule:
+			err = aw.WriteDir(path + "/")
+		default:
+			err = writeBlob(aw, s, path, e)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func writeBlob(aw archiveWriter, s storer.EncodedObjectStorer, path string, e object.TreeEntry) error {
+	blob, err := object.GetBlob(s, e.Hash)
+	if err != nil {
+		return err
+	}
+
+	r, err := blob.Reader()
+	if err != nil {
+		return err
+	}
+
+	defer r.Close()
+
+	if e.Mode == filemode.Symlink {
+		target, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		return aw.WriteSymlink(path, string(target))
+	}
+
+	mode := os.FileMode(fileMode)
+	if e.Mode == filemode.Executable {
+		mode = execMode
+	}
+
+	return aw.WriteFile(path, mode, blob.Size, r)
+}
+
+// archivedPath returns true if the given path is one of the paths, is inside
+// one of them or is a parent directory of one of them. All the paths are
+// archived if none is given.
+func archivedPath(name string, paths []string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+
+	for _, p := range paths {
+		p = strings.Trim(p, "/")
+		if p == "" || name == p ||
+			strings.HasPrefix(name, p+"/") || strings.HasPrefix(p, name+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+type archiveWriter interface {
+	WriteDir(name string) error
+	WriteFile(name string, mode os.FileMode, size int64, r io.Reader) error
+	WriteSymlink(name, target string) error
+	Close() error
+}
+
+type tarWriter struct {
+	w       *tar.Writer
+	o       *Options
+	started bool
+}
+
+func newTarWriter(w io.Writer, o *Options) *tarWriter {
+	return &tarWriter{w: tar.NewWriter(w), o: o}
+}
+
+// start writes the global header with the commit before the first entry.
+func (w *tarWriter) start() error {
+	if w.started || w.o.Commit.IsZero() {
+		w.started = true
+		return nil
+	}
+
+	w.started = true
+	return w.w.WriteHeader(&tar.Header{
+		Typeflag:   tar.TypeXGlobalHeader,
+		Name:       "pax_global_header",
+		PAXRecords: map[string]string{"comment": w.o.Commit.String()},
+	})
+}
+
+func (w *tarWriter) header(name string, mode int64) *tar.Header {
+	return &tar.Header{
+		Name:    name,
+		Mode:    mode,
+		ModTime: w.o.ModTime,
+		Uname:   "root",
+		Gname:   "root",
+	}
+}
+
+func (w *tarWriter) WriteDir(name string) error {
+	if err := w.start(); err != nil {
+		return err
+	}
+
+	h := w.header(name, execMode)
+	h.Typeflag = tar.TypeDir
+	return w.w.WriteHeader(h)
+}
+
+func (w *tarWriter) WriteFile(name string, mode os.FileMode, size int64, r io.Reader) error {
+	if err := w.start(); err != nil {
+		return err
+	}
+
+	h := w.header(name, int64(mode))
+	h.Typeflag = tar.TypeReg
+	h.Size = size
+	if err := w.w.WriteHeader(h); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(w.w, r)
+	return err
+}
+
+func (w *tarWriter) WriteSymlink(name, target string) error {
+	if err := w.start(); err != nil {
+		return err
+	}
+
+	h := w.header(name, 0777)
+	h.Typeflag = tar.TypeSymlink
+	h.Linkname = target
+	return w.w.WriteHeader(h)
+}
+
+func (w *tarWriter) Close() error {
+	if err := w.start(); err != nil {
+		return err
+	}
+
+	return w.w.Close()
+}
+
+type zipWriter struct {
+	w *zip.Writer
+	o *Options
+}
+
+func newZipWriter(w io.Writer, o *Options) *zipWriter {
+	zw := &zipWriter{w: zip.NewWriter(w), o: o}
+	if !o.Commit.IsZero() {
+		zw.w.SetComment(o.Commit.String())
+	}
+
+	return zw
+}
+
+func (w *zipWriter) header(name string, mode os.FileMode) *zip.FileHeader {
+	h := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: w.o.ModTime,
+	}
+
+	h.SetMode(mode)
+	return h
+}
+
+func (w *zipWriter) WriteDir(name string) error {
+	h := w.header(name, os.ModeDir|execMode)
+	h.Method = zip.Store
+	_, err := w.w.CreateHeader(h)
+	return err
+}
+
+func (w *zipWriter) WriteFile(name string, mode os.FileMode, size int64, r io.Reader) error {
+	f, err := w.w.CreateHeader(w.header(name, mode))
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, r)
+	return err
+}
+
+func (w *zipWriter) WriteSymlink(name, target string) error {
+	h := w.header(name, os.ModeSymlink|0777)
+	h.Method = zip.Store
+	f, err := w.w.CreateHeader(h)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(f, target)
+	return err
+}
+
+func (w *zipWriter) Close() error {
+	return w.w.Close()
+}
@@ -0,0 +1,125 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type ArchiveSuite struct {
+	fixtures.Suite
+	Storer *filesystem.Storage
+	Tree   *object.Tree
+}
+
+var _ = Suite(&ArchiveSuite{})
+
+func (s *ArchiveSuite) SetUpSuite(c *C) {
+	s.Suite.SetUpSuite(c)
+
+	var err error
+	s.Storer, err = filesystem.NewStorage(fixtures.Basic().One().DotGit())
+	c.Assert(err, IsNil)
+
+	s.Tree, err = object.GetTree(s.Storer, plumbing.NewHash("a8d315b2b1c615d43042c3a62402b8a54288cf5c"))
+	c.Assert(err, IsNil)
+}
+
+func (s *ArchiveSuite) readTar(c *C, b *bytes.Buffer) map[string]*tar.Header {
+	headers := make(map[string]*tar.Header)
+	r := tar.NewReader(b)
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			return headers
+		}
+
+		c.Assert(err, IsNil)
+		headers[h.Name] = h
+	}
+}
+
+func (s *ArchiveSuite) TestWriteTar(c *C) {
+	commit := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	modTime := time.Unix(1428500000, 0)
+
+	buf := bytes.NewBuffer(nil)
+	err := Write(buf, s.Storer, s.Tree, &Options{ModTime: modTime, Commit: commit})
+	c.Assert(err, IsNil)
+
+	headers := s.readTar(c, buf)
+	c.Assert(headers, HasLen, 14)
+	c.Assert(headers["pax_global_header"].PAXRecords["comment"], Equals, commit.String())
+
+	f, err := s.Tree.File("go/example.go")
+	c.Assert(err, IsNil)
+
+	h := headers["go/example.go"]
+	c.Assert(h, NotNil)
+	c.Assert(h.Size, Equals, f.Size)
+	c.Assert(h.Mode, Equals, int64(0664))
+	c.Assert(h.ModTime.Equal(modTime), Equals, true)
+
+	c.Assert(headers["go/"].Typeflag, Equals, byte(tar.TypeDir))
+}
+
+func (s *ArchiveSuite) TestWriteTarPrefixAndPaths(c *C) {
+	buf := bytes.NewBuffer(nil)
+	err := Write(buf, s.Storer, s.Tree, &Options{
+		Prefix: "basic/",
+		Paths:  []string{"json/", "LICENSE"},
+	})
+	c.Assert(err, IsNil)
+
+	headers := s.readTar(c, buf)
+	c.Assert(headers, HasLen, 5)
+	c.Assert(headers["basic/"], NotNil)
+	c.Assert(headers["basic/LICENSE"], NotNil)
+	c.Assert(headers["basic/json/"], NotNil)
+	c.Assert(headers["basic/json/long.json"], NotNil)
+	c.Assert(headers["basic/json/short.json"], NotNil)
+}
+
+func (s *ArchiveSuite) TestWriteZip(c *C) {
+	commit := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+	buf := bytes.NewBuffer(nil)
+	err := Write(buf, s.Storer, s.Tree, &Options{Format: Zip, Commit: commit})
+	c.Assert(err, IsNil)
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	c.Assert(err, IsNil)
+	c.Assert(r.Comment, Equals, commit.String())
+	c.Assert(r.File, HasLen, 13)
+
+	expected, err := s.Tree.File("vendor/foo.go")
+	c.Assert(err, IsNil)
+
+	for _, f := range r.File {
+		if f.Name != "vendor/foo.go" {
+			continue
+		}
+
+		c.Assert(f.UncompressedSize64, Equals, uint64(expected.Size))
+		return
+	}
+
+	c.Fatal("vendor/foo.go not archived")
+}
+
+func (s *ArchiveSuite) TestWriteUnsupportedFormat(c *C) {
+	err := Write(bytes.NewBuffer(nil), s.Storer, s.Tree, &Options{Format: "rar"})
+	c.Assert(err, Equals, ErrUnsupportedFormat)
+}
@@ -0,0 +1,197 @@
+package commitgraph
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+const (
+	// VersionSupported is the only commit-graph version supported.
+	VersionSupported = 1
+
+	sha1Version = 1
+
+	parentNone        = 0x70000000
+	parentOctopusUsed = 0x80000000
+	parentOctopusMask = 0x7fffffff
+	parentLast        = 0x80000000
+
+	generationOverflow = 0x80000000
+	maxGeneration      = 0x3fffffff
+	maxCommitTime      = 1<<34 - 1
+
+	chunkEntrySize  = 12
+	fanoutSize      = 256 * 4
+	commitDataExtra = 16
+)
+
+var (
+	signature = []byte{'C', 'G', 'P', 'H'}
+
+	oidFanoutChunk              = [4]byte{'O', 'I', 'D', 'F'}
+	oidLookupChunk              = [4]byte{'O', 'I', 'D', 'L'}
+	commitDataChunk             = [4]byte{'C', 'D', 'A', 'T'}
+	generationDataChunk         = [4]byte{'G', 'D', 'A', '2'}
+	generationDataOverflowChunk = [4]byte{'G', 'D', 'O', '2'}
+	extraEdgeListChunk          = [4]byte{'E', 'D', 'G', 'E'}
+	baseGraphsListChunk         = [4]byte{'B', 'A', 'S', 'E'}
+)
+
+// CommitGraph is the in memory representation of a commit-graph file, or of
+// a layer of a commit-graph chain.
+type CommitGraph struct {
+	// Commits are the commits of the layer.
+	Commits []*CommitData
+	// Base is the previous layer of the chain, nil for the first layer or
+	// for a commit-graph file.
+	Base *CommitGraph
+	// HasGenerationData is true if the layer carries the corrected commit
+	// dates of its commits.
+	HasGenerationData bool
+	// Checksum is the trailing checksum of the layer, set by Decode and
+	// Encode.
+	Checksum plumbing.Hash
+
+	sorted bool
+}
+
+// CommitData is the in memory representation of a commit at a commit-graph.
+type CommitData struct {
+	Hash     plumbing.Hash
+	TreeHash plumbing.Hash
+	// ParentHashes are the parents of the commit, they must be at the
+	// commit-graph or at its base layers.
+	ParentHashes []plumbing.Hash
+	// Generation is the topological level of the commit.
+	Generation uint32
+	// CorrectedDate is the corrected commit date of the commit, in seconds
+	// since EPOCH, zero if the layer has no generation data.
+	CorrectedDate uint64
+	// When is the committer time, with seconds precision.
+	When time.Time
+}
+
+// New returns an empty CommitGraph.
+func New() *CommitGraph {
+	return &CommitGraph{sorted: true}
+}
+
+// Add adds a commit to the layer.
+func (g *CommitGraph) Add(c *CommitData) {
+	g.Commits = append(g.Commits, c)
+	g.sorted = false
+}
+
+// Len returns the number of commits at the layer and its base layers.
+func (g *CommitGraph) Len() int {
+	n := len(g.Commits)
+	if g.Base != nil {
+		n += g.Base.Len()
+	}
+
+	return n
+}
+
+// Commit returns the commit with the given hash, looking for it at the
+// layer and its base layers.
+func (g *CommitGraph) Commit(h plumbing.Hash) (*CommitData, bool) {
+	p, ok := g.position(h)
+	if !ok {
+		return nil, false
+	}
+
+	return g.at(p), true
+}
+
+// Generation returns the generation number of the commit with the given
+// hash: its corrected commit date if every layer carries them, or its
+// topological level otherwise.
+func (g *CommitGraph) Generation(h plumbing.Hash) (uint64, bool) {
+	c, ok := g.Commit(h)
+	if !ok {
+		return 0, false
+	}
+
+	if g.hasGenerationData() {
+		return c.CorrectedDate, true
+	}
+
+	return uint64(c.Generation), true
+}
+
+func (g *CommitGraph) hasGenerationData() bool {
+	for l := g; l != nil; l = l.Base {
+		if !l.HasGenerationData {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (g *CommitGraph) sort() {
+	if g.sorted {
+		return
+	}
+
+	sort.Slice(g.Commits, func(i, j int) bool {
+		return bytes.Compare(g.Commits[i].Hash[:], g.Commits[j].Hash[:]) < 0
+	})
+
+	g.sorted = true
+}
+
+// position returns the position of the commit at the chain, the positions of
+// the base layers go first.
+func (g *CommitGraph) position(h plumbing.Hash) (int, bool) {
+	g.sort()
+
+	i := sort.Search(len(g.Commits), func(i int) bool {
+		return bytes.Compare(g.Commits[i].Hash[:], h[:]) >= 0
+	})
+
+	base := 0
+	if g.Base != nil {
+		base = g.Base.Len()
+	}
+
+	if i < len(g.Commits) && g.Commits[i].Hash == h {
+		return base + i, true
+	}
+
+	if g.Base != nil {
+		return g.Base.position(h)
+	}
+
+	return 0, false
+}
+
+// at returns the commit at the given position of the chain.
+func (g *CommitGraph) at(p int) *CommitData {
+	if g.Base != nil {
+		base := g.Base.Len()
+		if p < base {
+			return g.Base.at(p)
+		}
+
+		p -= base
+	}
+
+	return g.Commits[p]
+}
+
+func (g *CommitGraph) calculateFanout() [256]uint32 {
+	fanout := [256]uint32{}
+	for _, c := range g.Commits {
+		fanout[c.Hash[0]]++
+	}
+
+	for i := 1; i < 256; i++ {
+		fanout[i] += fanout[i-1]
+	}
+
+	return fanout
+}
@@ -0,0 +1,157 @@
+package commitgraph_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	. "gopkg.in/src-d/go-git.v4/plumbing/format/commitgraph"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type CommitGraphSuite struct{}
+
+var _ = Suite(&CommitGraphSuite{})
+
+var emptyTree = plumbing.NewHash("4b825dc642cb6eb9a060e54bf8d69288fbee4904")
+
+// skewedGraph returns the commit-graph written by git for a history with a
+// commit older than its parent by more than 2^31 seconds.
+func skewedGraph() *CommitGraph {
+	a := plumbing.NewHash("289f376f226198fad43f884d6e6bda458fbe8528")
+	b := plumbing.NewHash("48cd64650b5bb64d4848769aebe795d246a44c28")
+	c := plumbing.NewHash("ac49bb55f2abf7e0be2b01786c109ef63d12bfbe")
+
+	g := New()
+	g.HasGenerationData = true
+	g.Add(&CommitData{
+		Hash: c, TreeHash: emptyTree, ParentHashes: []plumbing.Hash{b},
+		Generation: 3, CorrectedDate: 4000000002, When: time.Unix(100000001, 0),
+	})
+	g.Add(&CommitData{
+		Hash: a, TreeHash: emptyTree,
+		Generation: 1, CorrectedDate: 4000000000, When: time.Unix(4000000000, 0),
+	})
+	g.Add(&CommitData{
+		Hash: b, TreeHash: emptyTree, ParentHashes: []plumbing.Hash{a},
+		Generation: 2, CorrectedDate: 4000000001, When: time.Unix(100000000, 0),
+	})
+
+	return g
+}
+
+func (s *CommitGraphSuite) TestEncode(c *C) {
+	g := skewedGraph()
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(NewEncoder(buf).Encode(g), IsNil)
+	c.Assert(g.Checksum.String(), Equals, "25aef94ded755264a5f27a5ed528986990a4fca3")
+	c.Assert(g.Commits[0].Hash.String(), Equals, "289f376f226198fad43f884d6e6bda458fbe8528")
+}
+
+func (s *CommitGraphSuite) TestDecode(c *C) {
+	buf := bytes.NewBuffer(nil)
+	c.Assert(NewEncoder(buf).Encode(skewedGraph()), IsNil)
+
+	g := New()
+	c.Assert(NewDecoder(buf).Decode(g), IsNil)
+	c.Assert(g.Checksum.String(), Equals, "25aef94ded755264a5f27a5ed528986990a4fca3")
+	c.Assert(g.HasGenerationData, Equals, true)
+	c.Assert(g.Len(), Equals, 3)
+
+	commit, ok := g.Commit(plumbing.NewHash("ac49bb55f2abf7e0be2b01786c109ef63d12bfbe"))
+	c.Assert(ok, Equals, true)
+	c.Assert(commit.TreeHash, Equals, emptyTree)
+	c.Assert(commit.ParentHashes, DeepEquals, []plumbing.Hash{
+		plumbing.NewHash("48cd64650b5bb64d4848769aebe795d246a44c28"),
+	})
+	c.Assert(commit.Generation, Equals, uint32(3))
+	c.Assert(commit.CorrectedDate, Equals, uint64(4000000002))
+	c.Assert(commit.When.Unix(), Equals, int64(100000001))
+
+	generation, ok := g.Generation(commit.Hash)
+	c.Assert(ok, Equals, true)
+	c.Assert(generation, Equals, uint64(4000000002))
+
+	_, ok = g.Commit(plumbing.ZeroHash)
+	c.Assert(ok, Equals, false)
+}
+
+func (s *CommitGraphSuite) TestDecodeMalformed(c *C) {
+	buf := bytes.NewBuffer(nil)
+	c.Assert(NewEncoder(buf).Encode(skewedGraph()), IsNil)
+
+	content := buf.Bytes()
+	content[len(content)-1]++
+	err := NewDecoder(bytes.NewReader(content)).Decode(New())
+	c.Assert(err, Equals, ErrMalformedCommitGraph)
+
+	content[4] = 2
+	err = NewDecoder(bytes.NewReader(content)).Decode(New())
+	c.Assert(err, Equals, ErrUnsupportedVersion)
+}
+
+func (s *CommitGraphSuite) TestOctopusChain(c *C) {
+	hash := func(b byte) plumbing.Hash { return plumbing.Hash{b} }
+	commit := func(h byte, generation uint32, parents ...byte) *CommitData {
+		d := &CommitData{
+			Hash: hash(h), TreeHash: emptyTree, Generation: generation,
+			When: time.Unix(int64(h), 0),
+		}
+
+		for _, p := range parents {
+			d.ParentHashes = append(d.ParentHashes, hash(p))
+		}
+
+		return d
+	}
+
+	base := New()
+	base.Add(commit(1, 1))
+	base.Add(commit(3, 2, 1))
+	base.Add(commit(2, 2, 1))
+	c.Assert(NewEncoder(bytes.NewBuffer(nil)).Encode(base), IsNil)
+
+	layer := New()
+	layer.Base = base
+	layer.Add(commit(5, 4, 4))
+	layer.Add(commit(4, 3, 3, 2, 1))
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(NewEncoder(buf).Encode(layer), IsNil)
+
+	decoded := New()
+	err := NewDecoder(bytes.NewReader(buf.Bytes())).Decode(decoded)
+	c.Assert(err, Equals, ErrBaseMismatch)
+
+	decoded.Base = base
+	c.Assert(NewDecoder(buf).Decode(decoded), IsNil)
+	c.Assert(decoded.HasGenerationData, Equals, false)
+	c.Assert(decoded.Len(), Equals, 5)
+
+	octopus, ok := decoded.Commit(hash(4))
+	c.Assert(ok, Equals, true)
+	c.Assert(octopus.ParentHashes, DeepEquals, []plumbing.Hash{hash(3), hash(2), hash(1)})
+
+	generation, ok := decoded.Generation(hash(5))
+	c.Assert(ok, Equals, true)
+	c.Assert(generation, Equals, uint64(4))
+
+	_, ok = decoded.Commit(hash(2))
+	c.Assert(ok, Equals, true)
+}
+
+func (s *CommitGraphSuite) TestEncodeParentNotFound(c *C) {
+	g := New()
+	g.Add(&CommitData{
+		Hash:         plumbing.NewHash("ac49bb55f2abf7e0be2b01786c109ef63d12bfbe"),
+		ParentHashes: []plumbing.Hash{plumbing.NewHash("48cd64650b5bb64d4848769aebe795d246a44c28")},
+	})
+
+	err := NewEncoder(bytes.NewBuffer(nil)).Encode(g)
+	c.Assert(err, Equals, ErrParentNotFound)
+}
@@ -0,0 +1,241 @@
+package commitgraph
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+var (
+	// ErrUnsupportedVersion is returned by Decode when the commit-graph
+	// version or its hash version is not supported.
+	ErrUnsupportedVersion = errors.New("unsupported commit-graph version")
+	// ErrMalformedCommitGraph is returned by Decode when the commit-graph
+	// file is corrupted.
+	ErrMalformedCommitGraph = errors.New("malformed commit-graph file")
+	// ErrBaseMismatch is returned by Decode when the base layers listed by
+	// the commit-graph file are not the base layers of the CommitGraph.
+	ErrBaseMismatch = errors.New("commit-graph base layers mismatch")
+)
+
+// Decoder reads and decodes commit-graph files from an input stream.
+type Decoder struct {
+	r io.Reader
+}
+
+// NewDecoder builds a new commit-graph decoder, that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r}
+}
+
+// decodedChunks are the chunks of a commit-graph file, by ID.
+type decodedChunks map[[4]byte][]byte
+
+// Decode reads from the stream and decodes the content into the CommitGraph.
+// The Base of g must be set to the previous layer when decoding a layer of a
+// commit-graph chain.
+func (d *Decoder) Decode(g *CommitGraph) error {
+	content, err := ioutil.ReadAll(d.r)
+	if err != nil {
+		return err
+	}
+
+	if len(content) < 8+chunkEntrySize+sha1.Size ||
+		!bytes.Equal(content[:4], signature) {
+		return ErrMalformedCommitGraph
+	}
+
+	if content[4] != VersionSupported || content[5] != sha1Version {
+		return ErrUnsupportedVersion
+	}
+
+	body := content[:len(content)-sha1.Size]
+	sum := sha1.Sum(body)
+	if !bytes.Equal(sum[:], content[len(body):]) {
+		return ErrMalformedCommitGraph
+	}
+
+	chunks, err := readChunks(body, int(content[6]))
+	if err != nil {
+		return err
+	}
+
+	if err := checkBase(g, chunks[baseGraphsListChunk], int(content[7])); err != nil {
+		return err
+	}
+
+	if err := readCommits(g, chunks); err != nil {
+		return err
+	}
+
+	copy(g.Checksum[:], sum[:])
+	return nil
+}
+
+func readChunks(body []byte, count int) (decodedChunks, error) {
+	table := body[8:]
+	if len(table) < (count+1)*chunkEntrySize {
+		return nil, ErrMalformedCommitGraph
+	}
+
+	chunks := make(decodedChunks)
+	for i := 0; i < count; i++ {
+		entry := table[i*chunkEntrySize:]
+		next := table[(i+1)*chunkEntrySize:]
+
+		var id [4]byte
+		copy(id[:], entry)
+		start := binary.BigEndian.Uint64(entry[4:])
+		end := binary.BigEndian.Uint64(next[4:])
+		if start > end || end > uint64(len(body)) {
+			return nil, ErrMalformedCommitGraph
+		}
+
+		chunks[id] = body[start:end]
+	}
+
+	return chunks, nil
+}
+
+func checkBase(g *CommitGraph, list []byte, count int) error {
+	if len(list) != count*sha1.Size {
+		return ErrMalformedCommitGraph
+	}
+
+	// the base graphs are listed from the first layer of the chain
+	l := g.Base
+	for i := count - 1; i >= 0; i-- {
+		if l == nil || !bytes.Equal(l.Checksum[:], list[i*sha1.Size:(i+1)*sha1.Size]) {
+			return ErrBaseMismatch
+		}
+
+		l = l.Base
+	}
+
+	if l != nil {
+		return ErrBaseMismatch
+	}
+
+	return nil
+}
+
+func readCommits(g *CommitGraph, chunks decodedChunks) error {
+	fanout, lookup, data := chunks[oidFanoutChunk], chunks[oidLookupChunk], chunks[commitDataChunk]
+	if len(fanout) != fanoutSize {
+		return ErrMalformedCommitGraph
+	}
+
+	n := int(binary.BigEndian.Uint32(fanout[fanoutSize-4:]))
+	if len(lookup) != n*sha1.Size || len(data) != n*(sha1.Size+commitDataExtra) {
+		return ErrMalformedCommitGraph
+	}
+
+	generations := chunks[generationDataChunk]
+	g.HasGenerationData = generations != nil
+	if g.HasGenerationData && len(generations) != n*4 {
+		return ErrMalformedCommitGraph
+	}
+
+	base := 0
+	if g.Base != nil {
+		base = g.Base.Len()
+	}
+
+	g.Commits = make([]*CommitData, n)
+	for i := range g.Commits {
+		c := &CommitData{}
+		copy(c.Hash[:], lookup[i*sha1.Size:])
+		if i > 0 && bytes.Compare(g.Commits[i-1].Hash[:], c.Hash[:]) >= 0 {
+			return ErrMalformedCommitGraph
+		}
+
+		entry := data[i*(sha1.Size+commitDataExtra):]
+		copy(c.TreeHash[:], entry)
+		levelAndTime := binary.BigEndian.Uint64(entry[sha1.Size+8:])
+		c.Generation = uint32(levelAndTime >> 34)
+		c.When = time.Unix(int64(levelAndTime&maxCommitTime), 0)
+
+		if g.HasGenerationData {
+			offset, err := readGenerationOffset(chunks, generations[i*4:])
+			if err != nil {
+				return err
+			}
+
+			c.CorrectedDate = uint64(c.When.Unix()) + offset
+		}
+
+		g.Commits[i] = c
+	}
+
+	g.sorted = true
+
+	// the parents are resolved once every commit of the layer is known
+	for i, c := range g.Commits {
+		entry := data[i*(sha1.Size+commitDataExtra)+sha1.Size:]
+		parents, err := readParents(
+			chunks[extraEdgeListChunk],
+			binary.BigEndian.Uint32(entry),
+			binary.BigEndian.Uint32(entry[4:]),
+		)
+
+		if err != nil {
+			return err
+		}
+
+		for _, p := range parents {
+			if int(p) >= base+n {
+				return ErrMalformedCommitGraph
+			}
+
+			c.ParentHashes = append(c.ParentHashes, g.at(int(p)).Hash)
+		}
+	}
+
+	return nil
+}
+
+func readGenerationOffset(chunks decodedChunks, entry []byte) (uint64, error) {
+	offset := binary.BigEndian.Uint32(entry)
+	if offset&generationOverflow == 0 {
+		return uint64(offset), nil
+	}
+
+	overflow := chunks[generationDataOverflowChunk]
+	i := int(offset &^ generationOverflow)
+	if len(overflow) < (i+1)*8 {
+		return 0, ErrMalformedCommitGraph
+	}
+
+	return binary.BigEndian.Uint64(overflow[i*8:]), nil
+}
+
+func readParents(edges []byte, first, second uint32) ([]uint32, error) {
+	if first == parentNone {
+		return nil, nil
+	}
+
+	parents := []uint32{first}
+	if second == parentNone {
+		return parents, nil
+	}
+
+	if second&parentOctopusUsed == 0 {
+		return append(parents, second), nil
+	}
+
+	for i := int(second & parentOctopusMask); ; i++ {
+		if len(edges) < (i+1)*4 {
+			return nil, ErrMalformedCommitGraph
+		}
+
+		p := binary.BigEndian.Uint32(edges[i*4:])
+		parents = append(parents, p&parentOctopusMask)
+		if p&parentLast != 0 {
+			return parents, nil
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Package commitgraph implements encoding and decoding of commit-graph files.
+//
+//	== Git commit-graph format
+//
+//	The commit-graph file stores the commit graph structure along with some
+//	extra metadata to speed up graph walks. It is stored at
+//	objects/info/commit-graph, or split in layers listed by
+//	objects/info/commit-graphs/commit-graph-chain, each layer stored at
+//	objects/info/commit-graphs/graph-{hash}.graph.
+//
+//	- The header consists of:
+//
+//	  4-byte signature: The signature is: {'C', 'G', 'P', 'H'}
+//
+//	  1-byte version number: Currently, the only valid version is 1.
+//
+//	  1-byte Hash Version: 1 for SHA-1.
+//
+//	  1-byte number (C) of "chunks"
+//
+//	  1-byte number (B) of base commit-graphs
+//
+//	- The chunk lookup table has (C + 1) entries of 12 bytes, a 4-byte chunk
+//	  ID and an 8-byte offset into the file, the last one with ID 0 marking
+//	  the end of the last chunk.
+//
+//	- The chunks are:
+//
+//	  OID Fanout (ID: {'O', 'I', 'D', 'F'}) (256 * 4 bytes)
+//	    The ith entry, F[i], stores the number of OIDs with first
+//	    byte at most i. Thus F[255] stores the total number of commits (N).
+//
+//	  OID Lookup (ID: {'O', 'I', 'D', 'L'}) (N * H bytes)
+//	    The OIDs for all commits in the graph, sorted in ascending order.
+//
+//	  Commit Data (ID: {'C', 'D', 'A', 'T' }) (N * (H + 16) bytes)
+//	    * The first H bytes are for the OID of the root tree.
+//	    * The next 8 bytes are for the positions of the first two parents
+//	      of the ith commit. Stores value 0x70000000 if no parent in that
+//	      position. If there are more than two parents, the second value
+//	      has its most-significant bit on and the other bits store an array
+//	      position into the Extra Edge List chunk.
+//	    * The next 8 bytes store the topological level (generation number
+//	      v1) of the commit and the commit time in seconds since EPOCH. The
+//	      generation number uses the higher 30 bits of the first 4 bytes,
+//	      while the commit time uses the 32 bits of the second 4 bytes,
+//	      along with the lowest 2 bits of the lowest byte, storing the
+//	      33rd and 34th bit of the commit time.
+//
+//	  Generation Data (ID: {'G', 'D', 'A', '2' }) (N * 4 bytes) [Optional]
+//	    * This list of 4-byte values store corrected commit date offsets for
+//	      the commits, arranged in the same order as commit data chunk.
+//	    * If the corrected commit date offset cannot be stored within 31
+//	      bits, the value has its most-significant bit on and the other bits
+//	      store the position of corrected commit date into the Generation
+//	      Data Overflow chunk.
+//
+//	  Generation Data Overflow (ID: {'G', 'D', 'O', '2' }) [Optional]
+//	    * This list of 8-byte values stores the corrected commit date offsets
+//	      for commits with corrected commit date offsets that cannot be
+//	      stored within 31 bits.
+//
+//	  Extra Edge List (ID: {'E', 'D', 'G', 'E'}) [Optional]
+//	    This list of 4-byte values store the second through nth parents for
+//	    all octopus merges. The second parent value in the commit data
+//	    stores an array position within this list along with the most-
+//	    significant bit on. Starting at that array position, iterate through
+//	    this list of commit positions for the parents until reaching a value
+//	    with the most-significant bit on. The other bits correspond to the
+//	    position of the last parent.
+//
+//	  Base Graphs List (ID: {'B', 'A', 'S', 'E'}) [Optional]
+//	    This list of H-byte hashes describe a set of B commit-graph files
+//	    that form a commit-graph chain. The graph position for the ith
+//	    commit in this file's OID Lookup chunk is equal to i plus the
+//	    number of commits in all base graphs.
+//
+//	- The trailer is the H-byte checksum of all of the above.
+//
+//	The corrected commit date of a commit is the maximum of its commit time
+//	and the corrected commit dates of its parents plus one (generation
+//	number v2), the topological level is one for the root commits and the
+//	maximum of the levels of its parents plus one otherwise. Both grow along
+//	the history, so a commit is never reachable from a commit with a lower
+//	generation number.
+//
+// Source:
+// https://github.com/git/git/blob/master/Documentation/technical/commit-graph-format.txt
+package commitgraph
@@ -0,0 +1,186 @@
+package commitgraph
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+)
+
+var (
+	// ErrParentNotFound is returned by Encode when a parent of a commit is
+	// not at the commit-graph nor at its base layers.
+	ErrParentNotFound = errors.New("commit-graph parent not found")
+)
+
+// Encoder writes CommitGraph structs to an output stream.
+type Encoder struct {
+	io.Writer
+	hash hash.Hash
+}
+
+// NewEncoder returns a new stream encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	h := sha1.New()
+	mw := io.MultiWriter(w, h)
+	return &Encoder{mw, h}
+}
+
+type encodedChunk struct {
+	id      [4]byte
+	content []byte
+}
+
+// Encode encodes a CommitGraph to the encoder writer, only the commits of the
+// layer are written, listing its base layers.
+func (e *Encoder) Encode(g *CommitGraph) error {
+	g.sort()
+
+	chunks, err := encodeChunks(g)
+	if err != nil {
+		return err
+	}
+
+	var bases []*CommitGraph
+	for l := g.Base; l != nil; l = l.Base {
+		bases = append(bases, l)
+	}
+
+	if len(bases) > 0 {
+		list := make([]byte, 0, len(bases)*sha1.Size)
+		for i := len(bases) - 1; i >= 0; i-- {
+			list = append(list, bases[i].Checksum[:]...)
+		}
+
+		chunks = append(chunks, encodedChunk{baseGraphsListChunk, list})
+	}
+
+	header := append([]byte{}, signature...)
+	header = append(header, VersionSupported, sha1Version, byte(len(chunks)), byte(len(bases)))
+
+	offset := uint64(len(header) + (len(chunks)+1)*chunkEntrySize)
+	for _, c := range chunks {
+		header = append(header, c.id[:]...)
+		header = appendUint64(header, offset)
+		offset += uint64(len(c.content))
+	}
+
+	header = append(header, 0, 0, 0, 0)
+	header = appendUint64(header, offset)
+
+	if _, err := e.Write(header); err != nil {
+		return err
+	}
+
+	for _, c := range chunks {
+		if _, err := e.Write(c.content); err != nil {
+			return err
+		}
+	}
+
+	copy(g.Checksum[:], e.hash.Sum(nil))
+	_, err = e.Write(g.Checksum[:])
+	return err
+}
+
+func encodeChunks(g *CommitGraph) ([]encodedChunk, error) {
+	fanout := make([]byte, 0, fanoutSize)
+	for _, n := range g.calculateFanout() {
+		fanout = appendUint32(fanout, n)
+	}
+
+	n := len(g.Commits)
+	lookup := make([]byte, 0, n*sha1.Size)
+	data := make([]byte, 0, n*(sha1.Size+commitDataExtra))
+	var generations, overflow, edges []byte
+
+	for _, c := range g.Commits {
+		lookup = append(lookup, c.Hash[:]...)
+		data = append(data, c.TreeHash[:]...)
+
+		parents := make([]uint32, len(c.ParentHashes))
+		for i, h := range c.ParentHashes {
+			p, ok := g.position(h)
+			if !ok {
+				return nil, ErrParentNotFound
+			}
+
+			parents[i] = uint32(p)
+		}
+
+		switch len(parents) {
+		case 0:
+			data = appendUint32(data, parentNone)
+			data = appendUint32(data, parentNone)
+		case 1:
+			data = appendUint32(data, parents[0])
+			data = appendUint32(data, parentNone)
+		case 2:
+			data = appendUint32(data, parents[0])
+			data = appendUint32(data, parents[1])
+		default:
+			data = appendUint32(data, parents[0])
+			data = appendUint32(data, parentOctopusUsed|uint32(len(edges)/4))
+			for i, p := range parents[1:] {
+				if i == len(parents)-2 {
+					p |= parentLast
+				}
+
+				edges = appendUint32(edges, p)
+			}
+		}
+
+		level := uint64(c.Generation)
+		if level > maxGeneration {
+			level = maxGeneration
+		}
+
+		when := uint64(c.When.Unix()) & maxCommitTime
+		data = appendUint64(data, level<<34|when)
+
+		if !g.HasGenerationData {
+			continue
+		}
+
+		offset := c.CorrectedDate - uint64(c.When.Unix())
+		if offset < generationOverflow {
+			generations = appendUint32(generations, uint32(offset))
+			continue
+		}
+
+		generations = appendUint32(generations, generationOverflow|uint32(len(overflow)/8))
+		overflow = appendUint64(overflow, offset)
+	}
+
+	chunks := []encodedChunk{
+		{oidFanoutChunk, fanout},
+		{oidLookupChunk, lookup},
+		{commitDataChunk, data},
+	}
+
+	if g.HasGenerationData {
+		chunks = append(chunks, encodedChunk{generationDataChunk, generations})
+		if overflow != nil {
+			chunks = append(chunks, encodedChunk{generationDataOverflowChunk, overflow})
+		}
+	}
+
+	if edges != nil {
+		chunks = append(chunks, encodedChunk{extraEdgeListChunk, edges})
+	}
+
+	return chunks, nil
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
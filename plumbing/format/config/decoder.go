@@ -1,7 +1,9 @@
 package config
 
 import (
+	"bytes"
 	"io"
+	"io/ioutil"
 
 	"github.com/src-d/gcfg"
 )
@@ -9,16 +11,34 @@ import (
 // A Decoder reads and decodes config files from an input stream.
 type Decoder struct {
 	io.Reader
+	// OptionAdded, if not nil, is called for every option decoded, once it is
+	// added to the config. An error aborts the decoding.
+	OptionAdded func(section, subsection string, o *Option) error
+	// Layout, if not nil, records the layout of the decoded text, so the
+	// config can be written back preserving it with Encoder.EncodeLayout.
+	Layout *Layout
 }
 
 // NewDecoder returns a new decoder that reads from r.
 func NewDecoder(r io.Reader) *Decoder {
-	return &Decoder{r}
+	return &Decoder{Reader: r}
 }
 
 // Decode reads the whole config from its input and stores it in the
 // value pointed to by config.
 func (d *Decoder) Decode(config *Config) error {
+	var r io.Reader = d
+	var text []byte
+	var decoded []*Option
+	if d.Layout != nil {
+		var err error
+		if text, err = ioutil.ReadAll(d.Reader); err != nil {
+			return err
+		}
+
+		r = bytes.NewReader(text)
+	}
+
 	cb := func(s string, ss string, k string, v string, bv bool) error {
 		if ss == "" && k == "" {
 			config.Section(s)
@@ -31,7 +51,30 @@ func (d *Decoder) Decode(config *Config) error {
 		}
 
 		config.AddOption(s, ss, k, v)
-		return nil
+		if d.OptionAdded == nil && d.Layout == nil {
+			return nil
+		}
+
+		opts := config.Section(s).Options
+		if ss != "" {
+			opts = config.Section(s).Subsection(ss).Options
+		}
+
+		decoded = append(decoded, opts[len(opts)-1])
+		if d.OptionAdded == nil {
+			return nil
+		}
+
+		return d.OptionAdded(s, ss, opts[len(opts)-1])
+	}
+
+	if err := gcfg.ReadWithCallback(r, cb); err != nil {
+		return err
+	}
+
+	if d.Layout != nil {
+		d.Layout.build(string(text), decoded)
 	}
-	return gcfg.ReadWithCallback(d, cb)
+
+	return nil
 }
@@ -58,12 +58,7 @@ func (e *Encoder) encodeSubsection(sectionName string, s *Subsection) error {
 
 func (e *Encoder) encodeOptions(opts Options) error {
 	for _, o := range opts {
-		pattern := "\t%s = %s\n"
-		if strings.Contains(o.Value, "\\") {
-			pattern = "\t%s = %q\n"
-		}
-
-		if err := e.printf(pattern, o.Key, o.Value); err != nil {
+		if _, err := io.WriteString(e.w, optionLine("\t", o)); err != nil {
 			return err
 		}
 	}
@@ -71,6 +66,16 @@ func (e *Encoder) encodeOptions(opts Options) error {
 	return nil
 }
 
+// optionLine formats an option as a line of a config file.
+func optionLine(indent string, o *Option) string {
+	pattern := "%s%s = %s\n"
+	if strings.ContainsAny(o.Value, "\\\n") {
+		pattern = "%s%s = %q\n"
+	}
+
+	return fmt.Sprintf(pattern, indent, o.Key, o.Value)
+}
+
 func (e *Encoder) printf(msg string, args ...interface{}) error {
 	_, err := fmt.Fprintf(e.w, msg, args...)
 	return err
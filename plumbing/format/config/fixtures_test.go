@@ -101,4 +101,15 @@ var fixtures = []*Fixture{
 			AddOption("sect1", "", "opt1", "value1").
 			AddOption("sect1", "", "opt1", "value2"),
 	},
+	{
+		Raw: `
+			[sect1]
+			opt1 = "line1\nline2\n"
+			`,
+		Text: `[sect1]
+	opt1 = "line1\nline2\n"
+`,
+		Config: New().
+			AddOption("sect1", "", "opt1", "line1\nline2\n"),
+	},
 }
@@ -0,0 +1,313 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Layout is the text of a decoded config file: its comments, blank lines,
+// formatting and the position of its sections and options. It allows
+// Encoder.EncodeLayout to write a config changing only the lines of the
+// options changed, instead of rewriting it canonically.
+type Layout struct {
+	lines   []string
+	headers []layoutHeader
+	options []layoutOption
+}
+
+type layoutHeader struct {
+	section, subsection string
+	line                int
+}
+
+type layoutOption struct {
+	section, subsection string
+	key                 string
+	// first and last are the lines of the option, which spans several if
+	// it's continued with a trailing backslash.
+	first, last int
+	option      *Option
+}
+
+// build records the layout of text, whose decoded options are opts, in the
+// order they are found. If the lines of text don't match the options, as
+// with the syntax not understood, the layout is left empty, so the config
+// is encoded canonically.
+func (l *Layout) build(text string, opts []*Option) {
+	*l = Layout{}
+
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	var headers []layoutHeader
+	var options []layoutOption
+	var section, subsection string
+	for i := 0; i < len(lines); i++ {
+		content, _ := splitComment(lines[i])
+		content = strings.TrimSpace(content)
+		if content == "" {
+			continue
+		}
+
+		if content[0] == '[' {
+			var ok bool
+			section, subsection, ok = parseHeader(content)
+			if !ok {
+				return
+			}
+
+			headers = append(headers, layoutHeader{section, subsection, i})
+			continue
+		}
+
+		first := i
+		for i < len(lines)-1 && isContinued(lines[i]) {
+			i++
+		}
+
+		if len(options) == len(opts) || !opts[len(options)].IsKey(optionKey(content)) {
+			return
+		}
+
+		options = append(options, layoutOption{
+			section:    section,
+			subsection: subsection,
+			key:        opts[len(options)].Key,
+			first:      first,
+			last:       i,
+			option:     opts[len(options)],
+		})
+	}
+
+	if len(options) != len(opts) {
+		return
+	}
+
+	l.lines, l.headers, l.options = lines, headers, options
+}
+
+// splitComment splits a line into its content and its comment, if any.
+func splitComment(line string) (content, comment string) {
+	var quoted, escaped bool
+	for i, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case !quoted && (r == '#' || r == ';'):
+			return line[:i], line[i:]
+		}
+	}
+
+	return line, ""
+}
+
+// isContinued returns true if the value of the option at line continues at
+// the next one, since it ends with a backslash.
+func isContinued(line string) bool {
+	content, comment := splitComment(strings.TrimRight(line, "\r\n"))
+	if comment != "" {
+		return false
+	}
+
+	n := len(content) - len(strings.TrimRight(content, "\\"))
+	return n%2 == 1
+}
+
+// parseHeader parses a section header, as [section] or [section "sub"], and
+// the deprecated [section.sub].
+func parseHeader(content string) (section, subsection string, ok bool) {
+	if !strings.HasSuffix(content, "]") {
+		return "", "", false
+	}
+
+	content = strings.TrimSpace(content[1 : len(content)-1])
+	if i := strings.IndexAny(content, " \t"); i != -1 {
+		quoted := strings.TrimSpace(content[i:])
+		if len(quoted) < 2 || quoted[0] != '"' || quoted[len(quoted)-1] != '"' {
+			return "", "", false
+		}
+
+		sub := strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(quoted[1 : len(quoted)-1])
+		return content[:i], sub, true
+	}
+
+	if i := strings.Index(content, "."); i != -1 {
+		return content[:i], strings.ToLower(content[i+1:]), true
+	}
+
+	return content, "", true
+}
+
+func optionKey(content string) string {
+	if i := strings.IndexAny(content, " \t="); i != -1 {
+		return content[:i]
+	}
+
+	return content
+}
+
+// layoutKey identifies a section or subsection of a Layout.
+type layoutKey struct {
+	section, subsection string
+}
+
+func newLayoutKey(section, subsection string) layoutKey {
+	return layoutKey{strings.ToLower(section), subsection}
+}
+
+// slotKey identifies the options with the same key of a section.
+type slotKey struct {
+	layoutKey
+	key string
+}
+
+func newSlotKey(k layoutKey, key string) slotKey {
+	return slotKey{k, strings.ToLower(key)}
+}
+
+// EncodeLayout writes the config as Encode does, keeping the comments,
+// blank lines, order and formatting of l, the layout of the file cfg was
+// decoded from. The lines of the options removed are deleted, the changed
+// options are written in place of the old ones and the new ones after the
+// last option of their section, or at the end of the file. If l is nil or
+// empty, the config is encoded canonically.
+func (e *Encoder) EncodeLayout(cfg *Config, l *Layout) error {
+	if l == nil || len(l.lines) == 0 {
+		return e.Encode(cfg)
+	}
+
+	present := make(map[*Option]layoutKey)
+	sections := make(map[layoutKey]bool)
+	for _, s := range cfg.Sections {
+		sections[newLayoutKey(s.Name, "")] = true
+		for _, o := range s.Options {
+			present[o] = newLayoutKey(s.Name, "")
+		}
+
+		for _, ss := range s.Subsections {
+			sections[newLayoutKey(s.Name, ss.Name)] = true
+			for _, o := range ss.Options {
+				present[o] = newLayoutKey(s.Name, ss.Name)
+			}
+		}
+	}
+
+	deleted := make([]bool, len(l.lines))
+	replaced := make(map[int]string)
+	kept := make(map[*Option]bool)
+	// anchors are the last line of every section in the layout, where its
+	// new options are inserted, and slots the lines of the options deleted,
+	// where the new options with the same key are written.
+	anchors := make(map[layoutKey]int)
+	slots := make(map[slotKey][]layoutOption)
+	for _, h := range l.headers {
+		k := newLayoutKey(h.section, h.subsection)
+		if !sections[k] {
+			deleted[h.line] = true
+			continue
+		}
+
+		anchors[k] = h.line
+	}
+
+	for _, lo := range l.options {
+		k := newLayoutKey(lo.section, lo.subsection)
+		if at, ok := present[lo.option]; ok && at == k && !kept[lo.option] {
+			kept[lo.option] = true
+			if lo.last > anchors[k] {
+				anchors[k] = lo.last
+			}
+
+			continue
+		}
+
+		for i := lo.first; i <= lo.last; i++ {
+			deleted[i] = true
+		}
+
+		if sections[k] {
+			slot := newSlotKey(k, lo.key)
+			slots[slot] = append(slots[slot], lo)
+		}
+	}
+
+	inserted := make(map[int][]string)
+	var appended []string
+	add := func(k layoutKey, header string, opts Options) {
+		for _, o := range opts {
+			if kept[o] {
+				continue
+			}
+
+			slot := newSlotKey(k, o.Key)
+			if s := slots[slot]; len(s) > 0 {
+				line := l.lines[s[0].first]
+				indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+				replaced[s[0].first] = optionLine(indent, o)
+				slots[slot] = s[1:]
+				continue
+			}
+
+			if at, ok := anchors[k]; ok {
+				inserted[at] = append(inserted[at], optionLine("\t", o))
+				continue
+			}
+
+			if header != "" {
+				appended = append(appended, header)
+				header = ""
+			}
+
+			appended = append(appended, optionLine("\t", o))
+		}
+
+		if _, ok := anchors[k]; !ok && header != "" && k.subsection != "" {
+			appended = append(appended, header)
+		}
+	}
+
+	for _, s := range cfg.Sections {
+		add(newLayoutKey(s.Name, ""), fmt.Sprintf("[%s]\n", s.Name), s.Options)
+		for _, ss := range s.Subsections {
+			add(newLayoutKey(s.Name, ss.Name), fmt.Sprintf("[%s \"%s\"]\n", s.Name, ss.Name), ss.Options)
+		}
+	}
+
+	var out []string
+	for i, line := range l.lines {
+		if text, ok := replaced[i]; ok {
+			out = append(out, text)
+		} else if !deleted[i] {
+			out = append(out, line)
+		}
+
+		if len(inserted[i]) > 0 {
+			out = terminate(out)
+			out = append(out, inserted[i]...)
+		}
+	}
+
+	if len(appended) > 0 {
+		out = terminate(out)
+		out = append(out, appended...)
+	}
+
+	_, err := io.WriteString(e.w, strings.Join(out, ""))
+	return err
+}
+
+// terminate adds a line break to the last line, if missing.
+func terminate(lines []string) []string {
+	if n := len(lines); n > 0 && !strings.HasSuffix(lines[n-1], "\n") {
+		lines[n-1] += "\n"
+	}
+
+	return lines
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"bytes"
+
+	. "gopkg.in/check.v1"
+)
+
+type LayoutSuite struct{}
+
+var _ = Suite(&LayoutSuite{})
+
+const layoutText = `# comment
+[core]
+	bare = false # inline comment
+
+[remote "origin"]
+    url = foo
+	fetch = +refs/heads/*:refs/remotes/origin/*
+`
+
+func (s *LayoutSuite) decode(c *C, text string) (*Config, *Layout) {
+	l := &Layout{}
+	d := NewDecoder(bytes.NewBufferString(text))
+	d.Layout = l
+
+	cfg := New()
+	c.Assert(d.Decode(cfg), IsNil)
+	return cfg, l
+}
+
+func (s *LayoutSuite) encode(c *C, cfg *Config, l *Layout) string {
+	buf := bytes.NewBuffer(nil)
+	c.Assert(NewEncoder(buf).EncodeLayout(cfg, l), IsNil)
+	return buf.String()
+}
+
+func (s *LayoutSuite) TestEncodeLayoutUnchanged(c *C) {
+	for idx, fixture := range fixtures {
+		cfg, l := s.decode(c, fixture.Raw)
+		c.Assert(s.encode(c, cfg, l), Equals, fixture.Raw, Commentf("bad result for fixture: %d", idx))
+	}
+}
+
+func (s *LayoutSuite) TestEncodeLayoutNil(c *C) {
+	cfg, _ := s.decode(c, layoutText)
+	c.Assert(s.encode(c, cfg, nil), Equals, `[core]
+	bare = false
+[remote "origin"]
+	url = foo
+	fetch = +refs/heads/*:refs/remotes/origin/*
+`)
+}
+
+func (s *LayoutSuite) TestEncodeLayoutChangedOption(c *C) {
+	cfg, l := s.decode(c, layoutText)
+	cfg.Section("remote").Subsection("origin").SetOption("url", "bar")
+
+	c.Assert(s.encode(c, cfg, l), Equals, `# comment
+[core]
+	bare = false # inline comment
+
+[remote "origin"]
+    url = bar
+	fetch = +refs/heads/*:refs/remotes/origin/*
+`)
+}
+
+func (s *LayoutSuite) TestEncodeLayoutAddedOption(c *C) {
+	cfg, l := s.decode(c, layoutText)
+	cfg.Section("core").AddOption("filemode", "true")
+
+	c.Assert(s.encode(c, cfg, l), Equals, `# comment
+[core]
+	bare = false # inline comment
+	filemode = true
+
+[remote "origin"]
+    url = foo
+	fetch = +refs/heads/*:refs/remotes/origin/*
+`)
+}
+
+func (s *LayoutSuite) TestEncodeLayoutRemovedOption(c *C) {
+	cfg, l := s.decode(c, layoutText)
+	cfg.Section("remote").Subsection("origin").RemoveOption("fetch")
+
+	c.Assert(s.encode(c, cfg, l), Equals, `# comment
+[core]
+	bare = false # inline comment
+
+[remote "origin"]
+    url = foo
+`)
+}
+
+func (s *LayoutSuite) TestEncodeLayoutRemovedSubsection(c *C) {
+	cfg, l := s.decode(c, layoutText)
+	cfg.RemoveSubsection("remote", "origin")
+
+	c.Assert(s.encode(c, cfg, l), Equals, `# comment
+[core]
+	bare = false # inline comment
+
+`)
+}
+
+func (s *LayoutSuite) TestEncodeLayoutAddedSection(c *C) {
+	cfg, l := s.decode(c, "[core]\n\tbare = false")
+	cfg.AddOption("user", "", "name", "foo")
+	cfg.AddOption("branch", "master", "remote", "origin")
+
+	c.Assert(s.encode(c, cfg, l), Equals, `[core]
+	bare = false
+[user]
+	name = foo
+[branch "master"]
+	remote = origin
+`)
+}
+
+func (s *LayoutSuite) TestEncodeLayoutMultiline(c *C) {
+	cfg, l := s.decode(c, "[alias]\n\tst = status \\\n\t  --short\n\tco = checkout\n")
+	cfg.Section("alias").SetOption("co", "commit")
+
+	c.Assert(s.encode(c, cfg, l), Equals, "[alias]\n\tst = status \\\n\t  --short\n\tco = commit\n")
+}
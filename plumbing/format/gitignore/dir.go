@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"os/user"
+	"path/filepath"
 	"strings"
 
 	"gopkg.in/src-d/go-billy.v4"
@@ -21,6 +22,8 @@ const (
 	gitignoreFile = ".gitignore"
 	gitconfigFile = ".gitconfig"
 	systemFile    = "/etc/gitconfig"
+	xdgConfigHome = "XDG_CONFIG_HOME"
+	homePrefix    = "~/"
 )
 
 // readIgnoreFile reads a specific git ignore file.
@@ -72,12 +75,33 @@ func ReadPatterns(fs billy.Filesystem, path []string) (ps []Pattern, err error)
 }
 
 func loadPatterns(fs billy.Filesystem, path string) (ps []Pattern, err error) {
+	efo, err := readExcludesFileOption(fs, path)
+	if err != nil || efo == "" {
+		return nil, err
+	}
+
+	efo, err = expandHome(efo)
+	if err != nil {
+		return
+	}
+
+	ps, err = readIgnoreFile(fs, nil, efo)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	return
+}
+
+// readExcludesFileOption returns the value of core.excludesfile at the given
+// config file, empty if the file or the option doesn't exist.
+func readExcludesFileOption(fs billy.Filesystem, path string) (efo string, err error) {
 	f, err := fs.Open(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, nil
+			return "", nil
 		}
-		return nil, err
+		return "", err
 	}
 
 	defer gioutil.CheckClose(f, &err)
@@ -94,18 +118,67 @@ func loadPatterns(fs billy.Filesystem, path string) (ps []Pattern, err error) {
 		return
 	}
 
-	s := raw.Section(coreSection)
-	efo := s.Options.Get(excludesfile)
-	if efo == "" {
-		return nil, nil
+	return raw.Section(coreSection).Options.Get(excludesfile), nil
+}
+
+// expandHome replaces a leading ~/ at path with the home directory of the
+// current user.
+func expandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, homePrefix) {
+		return path, nil
 	}
 
-	ps, err = readIgnoreFile(fs, nil, efo)
+	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(usr.HomeDir, path[len(homePrefix):]), nil
+}
+
+// ExcludesFile returns the path of the gitignore file applied to every
+// repository of the user, the one declared at the core.excludesfile property
+// of the user's ~/.gitconfig or, if missing, of the system's /etc/gitconfig.
+// If the property is not declared at any of them, the default
+// $XDG_CONFIG_HOME/git/ignore is returned, or ~/.config/git/ignore if
+// XDG_CONFIG_HOME is not set. The file may not exist.
+//
+// The function assumes fs is rooted at the root filesystem.
+func ExcludesFile(fs billy.Filesystem) (string, error) {
+	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+
+	for _, path := range []string{fs.Join(usr.HomeDir, gitconfigFile), systemFile} {
+		efo, err := readExcludesFileOption(fs, path)
+		if err != nil {
+			return "", err
+		}
+
+		if efo != "" {
+			return expandHome(efo)
+		}
+	}
+
+	if xdg := os.Getenv(xdgConfigHome); xdg != "" {
+		return fs.Join(xdg, "git", "ignore"), nil
+	}
+
+	return fs.Join(usr.HomeDir, ".config", "git", "ignore"), nil
+}
+
+// ReadExcludesFile reads the gitignore patterns of the given file, which
+// apply to the whole worktree, as the ones of .git/info/exclude or of the
+// file at core.excludesfile. If the file does not exist the function will
+// return nil.
+func ReadExcludesFile(fs billy.Filesystem, path string) ([]Pattern, error) {
+	ps, err := readIgnoreFile(fs, nil, path)
 	if os.IsNotExist(err) {
 		return nil, nil
 	}
 
-	return
+	return ps, err
 }
 
 // LoadGlobalPatterns loads gitignore patterns from from the gitignore file
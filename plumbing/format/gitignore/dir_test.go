@@ -211,3 +211,65 @@ func (s *MatcherSuite) TestDir_LoadSystemPatterns(c *C) {
 	c.Assert(m.Match([]string{"go-git.v4.iml"}, true), Equals, true)
 	c.Assert(m.Match([]string{".idea"}, true), Equals, true)
 }
+
+func (s *MatcherSuite) TestDir_LoadGlobalPatternsHomePrefix(c *C) {
+	usr, err := user.Current()
+	c.Assert(err, IsNil)
+
+	f, err := s.MIFS.Create(s.MIFS.Join(usr.HomeDir, gitconfigFile))
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("[core]\n\texcludesfile = ~/.gitignore_home\n"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	f, err = s.MIFS.Create(s.MIFS.Join(usr.HomeDir, ".gitignore_home"))
+	c.Assert(err, IsNil)
+	_, err = f.Write([]byte("*.iml\n"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Close(), IsNil)
+
+	ps, err := LoadGlobalPatterns(s.MIFS)
+	c.Assert(err, IsNil)
+	c.Assert(ps, HasLen, 1)
+	c.Assert(NewMatcher(ps).Match([]string{"go-git.v4.iml"}, false), Equals, true)
+}
+
+func (s *MatcherSuite) TestDir_ExcludesFile(c *C) {
+	usr, err := user.Current()
+	c.Assert(err, IsNil)
+
+	path, err := ExcludesFile(s.RFS)
+	c.Assert(err, IsNil)
+	c.Assert(path, Equals, s.RFS.Join(usr.HomeDir, ".gitignore_global"))
+
+	path, err = ExcludesFile(s.SFS)
+	c.Assert(err, IsNil)
+	c.Assert(path, Equals, "/etc/gitignore_global")
+}
+
+func (s *MatcherSuite) TestDir_ExcludesFileDefault(c *C) {
+	usr, err := user.Current()
+	c.Assert(err, IsNil)
+
+	defer os.Setenv(xdgConfigHome, os.Getenv(xdgConfigHome))
+
+	os.Setenv(xdgConfigHome, "/xdg")
+	path, err := ExcludesFile(s.MEFS)
+	c.Assert(err, IsNil)
+	c.Assert(path, Equals, s.MEFS.Join("/xdg", "git", "ignore"))
+
+	os.Setenv(xdgConfigHome, "")
+	path, err = ExcludesFile(s.MEFS)
+	c.Assert(err, IsNil)
+	c.Assert(path, Equals, s.MEFS.Join(usr.HomeDir, ".config", "git", "ignore"))
+}
+
+func (s *MatcherSuite) TestDir_ReadExcludesFile(c *C) {
+	ps, err := ReadExcludesFile(s.SFS, "/etc/gitignore_global")
+	c.Assert(err, IsNil)
+	c.Assert(ps, HasLen, 2)
+
+	ps, err = ReadExcludesFile(s.SFS, "/etc/missing")
+	c.Assert(err, IsNil)
+	c.Assert(ps, HasLen, 0)
+}
@@ -8,6 +8,7 @@ import (
 	"io"
 	"io/ioutil"
 	"strconv"
+	"sync"
 	"time"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
@@ -24,11 +25,21 @@ var (
 	// ErrInvalidChecksum is returned by Decode if the SHA1 hash missmatch with
 	// the read content
 	ErrInvalidChecksum = errors.New("invalid checksum")
-
-	errUnknownExtension = errors.New("unknown extension")
+	// ErrUnsupportedExtension is returned by Decode when the index contains
+	// a required extension not supported, such as the split index one.
+	ErrUnsupportedExtension = errors.New("unsupported required extension")
+	// ErrMalformedUntrackedCache is returned by Decode when the 'Untracked
+	// cache' extension is malformed
+	ErrMalformedUntrackedCache = errors.New("malformed untracked cache extension")
+	// ErrUnsupportedFSMonitorVersion is returned by Decode when the version
+	// of the 'File system monitor cache' extension is not supported
+	ErrUnsupportedFSMonitorVersion = errors.New("unsupported fsmonitor extension version")
 )
 
 const (
+	indexHeaderLength = 12
+	hashSize          = 20
+	eoieLength        = 8 + 4 + hashSize
 	entryHeaderLength = 62
 	entryExtended     = 0x4000
 	entryValid        = 0x8000
@@ -46,32 +57,89 @@ type Decoder struct {
 
 // NewDecoder returns a new decoder that reads from r.
 func NewDecoder(r io.Reader) *Decoder {
-	h := sha1.New()
 	return &Decoder{
-		r:    io.TeeReader(r, h),
-		hash: h,
+		r:    r,
+		hash: sha1.New(),
 	}
 }
 
 // Decode reads the whole index object from its input and stores it in the
-// value pointed to by idx.
+// value pointed to by idx. If the index contains the 'End of index entry' and
+// 'Index entry offset table' extensions, the entries are decoded in parallel.
 func (d *Decoder) Decode(idx *Index) error {
-	var err error
-	idx.Version, err = validateHeader(d.r)
+	data, err := ioutil.ReadAll(d.r)
+	if err != nil {
+		return err
+	}
+
+	r := bytes.NewReader(data)
+	idx.Version, err = validateHeader(r)
 	if err != nil {
 		return err
 	}
 
-	entryCount, err := binary.ReadUint32(d.r)
+	entryCount, err := binary.ReadUint32(r)
 	if err != nil {
 		return err
 	}
 
-	if err := d.readEntries(idx, int(entryCount)); err != nil {
+	if len(data) < indexHeaderLength+hashSize {
+		return io.ErrUnexpectedEOF
+	}
+
+	content := data[:len(data)-hashSize]
+	if _, err := d.hash.Write(content); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(d.hash.Sum(nil), data[len(content):]) {
+		return ErrInvalidChecksum
+	}
+
+	if err := d.readEntriesAndExtensions(idx, content, int(entryCount)); err != nil {
+		return err
+	}
+
+	if m := idx.FSMonitor; m != nil {
+		for _, e := range idx.Entries {
+			e.FSMonitorValid = true
+		}
+
+		for _, pos := range m.dirty {
+			if pos < len(idx.Entries) {
+				idx.Entries[pos].FSMonitorValid = false
+			}
+		}
+
+		m.dirty = nil
+	}
+
+	return nil
+}
+
+func (d *Decoder) readEntriesAndExtensions(idx *Index, content []byte, count int) error {
+	if offset, ok := endOfIndexEntries(content); ok {
+		blocks, err := d.readExtensions(idx, content[offset:])
+		if err != nil {
+			return err
+		}
+
+		if validOffsetTable(blocks, count, offset) {
+			return d.readEntriesParallel(idx, content, blocks, offset)
+		}
+
+		d.r = bytes.NewReader(content[indexHeaderLength:offset])
+		return d.readEntries(idx, count)
+	}
+
+	d.r = bytes.NewReader(content[indexHeaderLength:])
+	if err := d.readEntries(idx, count); err != nil {
 		return err
 	}
 
-	return d.readExtensions(idx)
+	offset := len(content) - d.r.(*bytes.Reader).Len()
+	_, err := d.readExtensions(idx, content[offset:])
+	return err
 }
 
 func (d *Decoder) readEntries(idx *Index, count int) error {
@@ -122,6 +190,7 @@ func (d *Decoder) readEntry(idx *Index) (*Entry, error) {
 	}
 
 	e.Stage = Stage(flags>>12) & 0x3
+	e.AssumeValid = flags&entryValid != 0
 
 	if flags&entryExtended != 0 {
 		extended, err := binary.ReadUint16(d.r)
@@ -204,94 +273,422 @@ func (d *Decoder) padEntry(idx *Index, e *Entry, read int) error {
 	return err
 }
 
-func (d *Decoder) readExtensions(idx *Index) error {
-	// TODO: support 'Split index' and 'Untracked cache' extensions, take in
-	// count that they are not supported by jgit or libgit
+// readEntriesParallel decodes every block of entries of the offset table at
+// its own goroutine, the entries end at the given offset.
+func (d *Decoder) readEntriesParallel(idx *Index, content []byte, blocks []offsetTableEntry, end int) error {
+	entries := make([][]*Entry, len(blocks))
+	errs := make([]error, len(blocks))
+
+	var wg sync.WaitGroup
+	for i, b := range blocks {
+		next := end
+		if i+1 < len(blocks) {
+			next = int(blocks[i+1].Offset)
+		}
 
-	var expected []byte
-	var err error
+		wg.Add(1)
+		go func(i int, b offsetTableEntry, data []byte) {
+			defer wg.Done()
 
-	var header [4]byte
-	for {
-		expected = d.hash.Sum(nil)
+			block := &Index{Version: idx.Version}
+			bd := &Decoder{r: bytes.NewReader(data)}
+			errs[i] = bd.readEntries(block, int(b.Count))
+			entries[i] = block.Entries
+		}(i, b, content[b.Offset:next])
+	}
 
-		var n int
-		if n, err = io.ReadFull(d.r, header[:]); err != nil {
-			if n == 0 {
-				err = io.EOF
-			}
+	wg.Wait()
 
-			break
+	for i := range blocks {
+		if errs[i] != nil {
+			return errs[i]
 		}
 
-		err = d.readExtension(idx, header[:])
+		idx.Entries = append(idx.Entries, entries[i]...)
+	}
+
+	return nil
+}
+
+// readExtensions decodes the extensions at data, returning the blocks of the
+// offset table, if any.
+func (d *Decoder) readExtensions(idx *Index, data []byte) ([]offsetTableEntry, error) {
+	var blocks []offsetTableEntry
+	r := bytes.NewReader(data)
+	for r.Len() > 0 {
+		var header [4]byte
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return nil, err
+		}
+
+		size, err := binary.ReadUint32(r)
 		if err != nil {
-			break
+			return nil, err
 		}
+
+		if int64(size) > int64(r.Len()) {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case bytes.Equal(header[:], treeExtSignature):
+			idx.Cache = &Tree{}
+			d := &treeExtensionDecoder{bytes.NewReader(payload)}
+			if err := d.Decode(idx.Cache); err != nil {
+				return nil, err
+			}
+		case bytes.Equal(header[:], resolveUndoExtSignature):
+			idx.ResolveUndo = &ResolveUndo{}
+			d := &resolveUndoDecoder{bytes.NewReader(payload)}
+			if err := d.Decode(idx.ResolveUndo); err != nil {
+				return nil, err
+			}
+		case bytes.Equal(header[:], splitIndexExtSignature):
+			idx.SplitIndex = &SplitIndex{}
+			if err := decodeSplitIndex(idx.SplitIndex, payload); err != nil {
+				return nil, err
+			}
+		case bytes.Equal(header[:], untrackedCacheExtSignature):
+			idx.UntrackedCache = &UntrackedCache{}
+			if err := decodeUntrackedCache(idx.UntrackedCache, payload); err != nil {
+				return nil, err
+			}
+		case bytes.Equal(header[:], fsMonitorExtSignature):
+			idx.FSMonitor = &FSMonitor{}
+			if err := decodeFSMonitor(idx.FSMonitor, payload); err != nil {
+				return nil, err
+			}
+		case bytes.Equal(header[:], offsetTableExtSignature):
+			blocks = decodeOffsetTable(payload)
+		case bytes.Equal(header[:], endOfIndexEntryExtSignature):
+		case header[0] >= 'A' && header[0] <= 'Z':
+			idx.Extensions = append(idx.Extensions, &Extension{
+				Signature: header,
+				Data:      payload,
+			})
+		default:
+			return nil, ErrUnsupportedExtension
+		}
+	}
+
+	return blocks, nil
+}
+
+func decodeSplitIndex(s *SplitIndex, data []byte) error {
+	r := bytes.NewReader(data)
+	if err := binary.Read(r, &s.SharedIndex); err != nil {
+		return err
+	}
+
+	if r.Len() == 0 {
+		return nil
 	}
 
-	if err != errUnknownExtension {
+	var err error
+	if s.Delete, err = decodeEWAH(r); err != nil {
 		return err
 	}
 
-	return d.readChecksum(expected, header)
+	s.Replace, err = decodeEWAH(r)
+	return err
 }
 
-func (d *Decoder) readExtension(idx *Index, header []byte) error {
-	switch {
-	case bytes.Equal(header, treeExtSignature):
-		r, err := d.getExtensionReader()
-		if err != nil {
+func decodeUntrackedCache(u *UntrackedCache, data []byte) error {
+	r := bytes.NewReader(data)
+	size, err := binary.ReadVariableWidthInt(r)
+	if err != nil {
+		return err
+	}
+
+	if size < 0 || size > int64(r.Len()) {
+		return ErrMalformedUntrackedCache
+	}
+
+	environments := make([]byte, size)
+	if _, err := io.ReadFull(r, environments); err != nil {
+		return err
+	}
+
+	for _, env := range bytes.Split(environments, []byte{'\x00'}) {
+		if len(env) != 0 {
+			u.Environments = append(u.Environments, string(env))
+		}
+	}
+
+	if err := readUntrackedCacheStat(r, &u.InfoExcludeStat); err != nil {
+		return err
+	}
+
+	if err := readUntrackedCacheStat(r, &u.ExcludesFileStat); err != nil {
+		return err
+	}
+
+	if err := binary.Read(r, &u.DirFlags, &u.InfoExcludeHash, &u.ExcludesFileHash); err != nil {
+		return err
+	}
+
+	name, err := binary.ReadUntil(r, '\x00')
+	if err != nil {
+		return err
+	}
+
+	u.ExcludePerDir = string(name)
+
+	count, err := binary.ReadVariableWidthInt(r)
+	if err != nil || count == 0 {
+		return err
+	}
+
+	var dirs []*UntrackedCacheDirectory
+	if u.Root, err = readUntrackedCacheDirectory(r, &dirs, int(count)); err != nil {
+		return err
+	}
+
+	if int64(len(dirs)) != count {
+		return ErrMalformedUntrackedCache
+	}
+
+	var valid, checkOnly, hashValid []int
+	for _, bits := range []*[]int{&valid, &checkOnly, &hashValid} {
+		if *bits, err = decodeEWAH(r); err != nil {
+			return err
+		}
+
+		for _, i := range *bits {
+			if i >= len(dirs) {
+				return ErrMalformedUntrackedCache
+			}
+		}
+	}
+
+	for _, i := range checkOnly {
+		dirs[i].CheckOnly = true
+	}
+
+	for _, i := range valid {
+		dirs[i].Valid = true
+		if err := readUntrackedCacheStat(r, &dirs[i].Stat); err != nil {
 			return err
 		}
+	}
 
-		idx.Cache = &Tree{}
-		d := &treeExtensionDecoder{r}
-		if err := d.Decode(idx.Cache); err != nil {
+	for _, i := range hashValid {
+		if err := binary.Read(r, &dirs[i].ExcludeHash); err != nil {
 			return err
 		}
-	case bytes.Equal(header, resolveUndoExtSignature):
-		r, err := d.getExtensionReader()
+	}
+
+	return nil
+}
+
+// decodeFSMonitor decodes the extension, the positions of the entries not
+// valid are kept until the entries are read.
+func decodeFSMonitor(m *FSMonitor, data []byte) error {
+	r := bytes.NewReader(data)
+	version, err := binary.ReadUint32(r)
+	if err != nil {
+		return err
+	}
+
+	switch version {
+	case 1:
+		since, err := binary.ReadUint64(r)
 		if err != nil {
 			return err
 		}
 
-		idx.ResolveUndo = &ResolveUndo{}
-		d := &resolveUndoDecoder{r}
-		if err := d.Decode(idx.ResolveUndo); err != nil {
+		m.Token = strconv.FormatUint(since, 10)
+	case 2:
+		token, err := binary.ReadUntil(r, '\x00')
+		if err != nil {
 			return err
 		}
+
+		m.Token = string(token)
 	default:
-		return errUnknownExtension
+		return ErrUnsupportedFSMonitorVersion
 	}
 
-	return nil
+	if _, err := binary.ReadUint32(r); err != nil {
+		return err
+	}
+
+	m.dirty, err = decodeEWAH(r)
+	return err
 }
 
-func (d *Decoder) getExtensionReader() (io.Reader, error) {
-	len, err := binary.ReadUint32(d.r)
+// readUntrackedCacheDirectory reads a directory block and its sub-directories,
+// appending them to dirs in depth-first order, as they are referenced by the
+// bitmaps of the extension.
+func readUntrackedCacheDirectory(r *bytes.Reader, dirs *[]*UntrackedCacheDirectory, max int) (*UntrackedCacheDirectory, error) {
+	if len(*dirs) >= max {
+		return nil, ErrMalformedUntrackedCache
+	}
+
+	untracked, err := binary.ReadVariableWidthInt(r)
 	if err != nil {
 		return nil, err
 	}
 
-	return &io.LimitedReader{R: d.r, N: int64(len)}, nil
-}
+	subdirs, err := binary.ReadVariableWidthInt(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if untracked < 0 || subdirs < 0 || untracked+subdirs > int64(r.Len()) {
+		return nil, ErrMalformedUntrackedCache
+	}
 
-func (d *Decoder) readChecksum(expected []byte, alreadyRead [4]byte) error {
-	var h plumbing.Hash
-	copy(h[:4], alreadyRead[:])
+	name, err := binary.ReadUntil(r, '\x00')
+	if err != nil {
+		return nil, err
+	}
 
-	if err := binary.Read(d.r, h[4:]); err != nil {
+	d := &UntrackedCacheDirectory{Name: string(name)}
+	*dirs = append(*dirs, d)
+
+	for i := int64(0); i < untracked; i++ {
+		name, err := binary.ReadUntil(r, '\x00')
+		if err != nil {
+			return nil, err
+		}
+
+		d.Untracked = append(d.Untracked, string(name))
+	}
+
+	for i := int64(0); i < subdirs; i++ {
+		sub, err := readUntrackedCacheDirectory(r, dirs, max)
+		if err != nil {
+			return nil, err
+		}
+
+		d.Directories = append(d.Directories, sub)
+	}
+
+	return d, nil
+}
+
+func readUntrackedCacheStat(r io.Reader, s *UntrackedCacheStat) error {
+	var sec, nsec, msec, mnsec uint32
+	if err := binary.Read(r, &sec, &nsec, &msec, &mnsec,
+		&s.Dev, &s.Inode, &s.UID, &s.GID, &s.Size,
+	); err != nil {
 		return err
 	}
 
-	if !bytes.Equal(h[:], expected) {
-		return ErrInvalidChecksum
+	if sec != 0 || nsec != 0 {
+		s.CreatedAt = time.Unix(int64(sec), int64(nsec))
+	}
+
+	if msec != 0 || mnsec != 0 {
+		s.ModifiedAt = time.Unix(int64(msec), int64(mnsec))
 	}
 
 	return nil
 }
 
+// offsetTableEntry is a block of entries of the 'Index entry offset table'
+// extension, Offset is the position of the first entry of the block from the
+// beginning of the index.
+type offsetTableEntry struct {
+	Offset, Count uint32
+}
+
+func decodeOffsetTable(data []byte) []offsetTableEntry {
+	r := bytes.NewReader(data)
+	version, err := binary.ReadUint32(r)
+	if err != nil || version != 1 {
+		return nil
+	}
+
+	var blocks []offsetTableEntry
+	for r.Len() >= 8 {
+		var b offsetTableEntry
+		if err := binary.Read(r, &b.Offset, &b.Count); err != nil {
+			return nil
+		}
+
+		blocks = append(blocks, b)
+	}
+
+	return blocks
+}
+
+// validOffsetTable returns true if the blocks cover all the entries, which
+// end at the given offset.
+func validOffsetTable(blocks []offsetTableEntry, count, end int) bool {
+	if len(blocks) == 0 || blocks[0].Offset != indexHeaderLength {
+		return false
+	}
+
+	var total int
+	for i, b := range blocks {
+		if int(b.Offset) >= end || (i > 0 && b.Offset <= blocks[i-1].Offset) {
+			return false
+		}
+
+		total += int(b.Count)
+	}
+
+	return total == count
+}
+
+// endOfIndexEntries returns the offset where the entries end and the
+// extensions begin, read from the 'End of index entry' extension, if the
+// content has a valid one.
+func endOfIndexEntries(content []byte) (int, bool) {
+	if len(content) < indexHeaderLength+eoieLength {
+		return 0, false
+	}
+
+	start := len(content) - eoieLength
+	r := bytes.NewReader(content[start:])
+
+	var header [4]byte
+	var size, offset uint32
+	var hash plumbing.Hash
+	if err := binary.Read(r, &header, &size, &offset, &hash); err != nil {
+		return 0, false
+	}
+
+	if !bytes.Equal(header[:], endOfIndexEntryExtSignature) ||
+		size != eoieLength-8 ||
+		int(offset) < indexHeaderLength || int(offset) > start {
+		return 0, false
+	}
+
+	// the hash covers the signature and size of every extension before
+	// this one
+	h := sha1.New()
+	for pos := int(offset); pos != start; {
+		if pos+8 > start {
+			return 0, false
+		}
+
+		h.Write(content[pos : pos+8])
+		size, err := binary.ReadUint32(bytes.NewReader(content[pos+4 : pos+8]))
+		if err != nil {
+			return 0, false
+		}
+
+		pos += 8 + int(size)
+		if pos > start {
+			return 0, false
+		}
+	}
+
+	if !bytes.Equal(h.Sum(nil), hash[:]) {
+		return 0, false
+	}
+
+	return int(offset), true
+}
+
 func validateHeader(r io.Reader) (version uint32, err error) {
 	var s = make([]byte, 4)
 	if _, err := io.ReadFull(r, s); err != nil {
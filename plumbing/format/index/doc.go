@@ -297,5 +297,28 @@
 //        in the previous ewah bitmap.
 //
 //      - One NUL.
+//
+//    == File System Monitor cache
+//
+//      The file system monitor cache tracks files for which the
+//      core.fsmonitor hook has told us about changes. The signature for this
+//      extension is { 'F', 'S', 'M', 'N' }.
+//
+//      The extension starts with
+//
+//      - 32-bit version number: the current supported versions are 1 and 2.
+//
+//      - (Version 1) 64-bit time: the extension data reflects all changes
+//        through the given time which is stored as the nanoseconds elapsed
+//        since midnight, January 1, 1970.
+//
+//      - (Version 2) A null terminated string: an opaque token defined by the
+//        file system monitor application. The extension data reflects all
+//        changes relative to that token.
+//
+//      - 32-bit bitmap size: the size of the CE_FSMONITOR_VALID bitmap.
+//
+//      - An ewah bitmap, the n-th bit indicates whether the n-th index entry
+//        is not CE_FSMONITOR_VALID.
 // Source https://www.kernel.org/pub/software/scm/git/docs/technical/index-format.txt
 package index
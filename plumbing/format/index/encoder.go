@@ -13,73 +13,135 @@ import (
 )
 
 var (
-	// EncodeVersionSupported is the range of supported index versions
-	EncodeVersionSupported uint32 = 2
+	// EncodeVersionSupported is the maximum supported version of the index
+	EncodeVersionSupported uint32 = 4
+	// EncodeVersionMin is the minimum supported version of the index
+	EncodeVersionMin uint32 = 2
 
 	// ErrInvalidTimestamp is returned by Encode if a Index with a Entry with
 	// negative timestamp values
 	ErrInvalidTimestamp = errors.New("negative timestamps are not allowed")
+
+	// offsetTableBlockSize is the number of entries of every block of the
+	// 'Index entry offset table' extension, written along the 'End of index
+	// entry' extension for the indexes with more entries than a block.
+	offsetTableBlockSize = 10000
 )
 
 // An Encoder writes an Index to an output stream.
 type Encoder struct {
 	w    io.Writer
 	hash hash.Hash
+	// offset is the number of bytes written
+	offset *counter
 }
 
 // NewEncoder returns a new encoder that writes to w.
 func NewEncoder(w io.Writer) *Encoder {
 	h := sha1.New()
-	mw := io.MultiWriter(w, h)
-	return &Encoder{mw, h}
+	c := &counter{}
+	mw := io.MultiWriter(w, h, c)
+	return &Encoder{mw, h, c}
 }
 
-// Encode writes the Index to the stream of the encoder.
+// Encode writes the Index to the stream of the encoder. The 'Cached tree' and
+// 'Resolve undo' extensions are not written. A split index is written on top
+// of the same shared index it was read from.
 func (e *Encoder) Encode(idx *Index) error {
-	// TODO: support versions v3 and v4
-	// TODO: support extensions
-	if idx.Version != EncodeVersionSupported {
+	// TODO: support 'Cached tree' and 'Resolve undo' extensions
+	if idx.Version < EncodeVersionMin || idx.Version > EncodeVersionSupported {
 		return ErrUnsupportedVersion
 	}
 
-	if err := e.encodeHeader(idx); err != nil {
+	sort.Sort(byName(idx.Entries))
+
+	entries := idx.Entries
+	var link []byte
+	if s := idx.SplitIndex; s != nil {
+		var err error
+		if entries, link, err = encodeSplitIndex(s, entries); err != nil {
+			return err
+		}
+	}
+
+	if err := e.encodeHeader(idx, entries); err != nil {
+		return err
+	}
+
+	blocks, err := e.encodeEntries(idx, entries)
+	if err != nil {
 		return err
 	}
 
-	if err := e.encodeEntries(idx); err != nil {
+	if err := e.encodeExtensions(idx, entries, blocks, link); err != nil {
 		return err
 	}
 
 	return e.encodeFooter()
 }
 
-func (e *Encoder) encodeHeader(idx *Index) error {
+func (e *Encoder) encodeHeader(idx *Index, entries []*Entry) error {
 	return binary.Write(e.w,
 		indexSignature,
 		idx.Version,
-		uint32(len(idx.Entries)),
+		uint32(len(entries)),
 	)
 }
 
-func (e *Encoder) encodeEntries(idx *Index) error {
-	sort.Sort(byName(idx.Entries))
+// encodeSplitIndex returns the entries to be written at a split index and the
+// content of its extension.
+func encodeSplitIndex(s *SplitIndex, entries []*Entry) ([]*Entry, []byte, error) {
+	entries, deleted, replaced := s.split(entries)
 
-	for _, entry := range idx.Entries {
-		if err := e.encodeEntry(entry); err != nil {
-			return err
+	buf := bytes.NewBuffer(nil)
+	if err := binary.Write(buf, s.SharedIndex); err != nil {
+		return nil, nil, err
+	}
+
+	if err := encodeEWAH(buf, deleted); err != nil {
+		return nil, nil, err
+	}
+
+	if err := encodeEWAH(buf, replaced); err != nil {
+		return nil, nil, err
+	}
+
+	return entries, buf.Bytes(), nil
+}
+
+// encodeEntries writes the entries, returning the blocks of the offset table
+// if the index is big enough to use one.
+func (e *Encoder) encodeEntries(idx *Index, entries []*Entry) ([]offsetTableEntry, error) {
+	useBlocks := len(entries) > offsetTableBlockSize
+
+	var blocks []offsetTableEntry
+	var previous string
+	for i, entry := range entries {
+		first := i == 0
+		if useBlocks && i%offsetTableBlockSize == 0 {
+			first = true
+			blocks = append(blocks, offsetTableEntry{
+				Offset: uint32(e.offset.n),
+				Count:  uint32(min(offsetTableBlockSize, len(entries)-i)),
+			})
 		}
 
-		wrote := entryHeaderLength + len(entry.Name)
-		if err := e.padEntry(wrote); err != nil {
-			return err
+		if err := e.encodeEntry(idx, entry, previous, first); err != nil {
+			return nil, err
 		}
+
+		previous = entry.Name
 	}
 
-	return nil
+	return blocks, nil
 }
 
-func (e *Encoder) encodeEntry(entry *Entry) error {
-	if entry.IntentToAdd || entry.SkipWorktree {
+// encodeEntry writes the entry, previous is the name of the previous entry
+// used by the prefix compression of the version 4, unless the entry is the
+// first of a block.
+func (e *Encoder) encodeEntry(idx *Index, entry *Entry, previous string, first bool) error {
+	extended := isExtended(entry)
+	if extended && idx.Version < 3 {
 		return ErrUnsupportedVersion
 	}
 
@@ -100,6 +162,14 @@ func (e *Encoder) encodeEntry(entry *Entry) error {
 		flags |= nameMask
 	}
 
+	if entry.AssumeValid {
+		flags |= entryValid
+	}
+
+	if extended {
+		flags |= entryExtended
+	}
+
 	flow := []interface{}{
 		sec, nsec,
 		msec, mnsec,
@@ -113,11 +183,305 @@ func (e *Encoder) encodeEntry(entry *Entry) error {
 		flags,
 	}
 
+	wrote := entryHeaderLength
+	if extended {
+		var extendedFlags uint16
+		if entry.IntentToAdd {
+			extendedFlags |= intentToAddMask
+		}
+
+		if entry.SkipWorktree {
+			extendedFlags |= skipWorkTreeMask
+		}
+
+		flow = append(flow, extendedFlags)
+		wrote += 2
+	}
+
 	if err := binary.Write(e.w, flow...); err != nil {
 		return err
 	}
 
-	return binary.Write(e.w, []byte(entry.Name))
+	if idx.Version == 4 {
+		return e.encodeEntryNameV4(entry.Name, previous, first)
+	}
+
+	if err := binary.Write(e.w, []byte(entry.Name)); err != nil {
+		return err
+	}
+
+	return e.padEntry(wrote + len(entry.Name))
+}
+
+// encodeEntryNameV4 writes the name prefix-compressed, as the number of bytes
+// to remove from the end of the previous name followed by the NUL-terminated
+// suffix to append. The first entry of a block shares nothing with the
+// previous one, so the blocks can be decoded independently.
+func (e *Encoder) encodeEntryNameV4(name, previous string, first bool) error {
+	var common int
+	if !first {
+		for common < len(name) && common < len(previous) && name[common] == previous[common] {
+			common++
+		}
+	}
+
+	if err := binary.WriteVariableWidthInt(e.w, int64(len(previous)-common)); err != nil {
+		return err
+	}
+
+	return binary.Write(e.w, []byte(name[common:]), byte('\x00'))
+}
+
+// isExtended returns true if the entry requires the extended flags, only
+// available since version 3.
+func isExtended(e *Entry) bool {
+	return e.IntentToAdd || e.SkipWorktree
+}
+
+func (e *Encoder) encodeExtensions(idx *Index, entries []*Entry, blocks []offsetTableEntry, link []byte) error {
+	start := e.offset.n
+	headers := sha1.New()
+
+	write := func(signature []byte, data []byte) error {
+		header := bytes.NewBuffer(nil)
+		if err := binary.Write(header, signature, uint32(len(data))); err != nil {
+			return err
+		}
+
+		headers.Write(header.Bytes())
+		return binary.Write(e.w, header.Bytes(), data)
+	}
+
+	if len(blocks) != 0 {
+		table := bytes.NewBuffer(nil)
+		if err := binary.WriteUint32(table, 1); err != nil {
+			return err
+		}
+
+		for _, b := range blocks {
+			if err := binary.Write(table, b.Offset, b.Count); err != nil {
+				return err
+			}
+		}
+
+		if err := write(offsetTableExtSignature, table.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	if link != nil {
+		if err := write(splitIndexExtSignature, link); err != nil {
+			return err
+		}
+	}
+
+	if idx.UntrackedCache != nil {
+		data, err := e.encodeUntrackedCache(idx.UntrackedCache)
+		if err != nil {
+			return err
+		}
+
+		if err := write(untrackedCacheExtSignature, data); err != nil {
+			return err
+		}
+	}
+
+	if idx.FSMonitor != nil {
+		data, err := encodeFSMonitor(idx.FSMonitor, entries)
+		if err != nil {
+			return err
+		}
+
+		if err := write(fsMonitorExtSignature, data); err != nil {
+			return err
+		}
+	}
+
+	for _, ext := range idx.Extensions {
+		if err := write(ext.Signature[:], ext.Data); err != nil {
+			return err
+		}
+	}
+
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	return binary.Write(e.w,
+		endOfIndexEntryExtSignature,
+		uint32(eoieLength-8),
+		uint32(start),
+		headers.Sum(nil),
+	)
+}
+
+// encodeFSMonitor returns the content of the 'File system monitor cache'
+// extension, always written as version 2.
+func encodeFSMonitor(m *FSMonitor, entries []*Entry) ([]byte, error) {
+	var dirty []int
+	for i, e := range entries {
+		if !e.FSMonitorValid {
+			dirty = append(dirty, i)
+		}
+	}
+
+	bitmap := bytes.NewBuffer(nil)
+	if err := encodeEWAH(bitmap, dirty); err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err := binary.Write(buf,
+		uint32(2),
+		[]byte(m.Token), byte('\x00'),
+		uint32(bitmap.Len()),
+		bitmap.Bytes(),
+	)
+
+	return buf.Bytes(), err
+}
+
+// encodeUntrackedCache returns the content of the 'Untracked cache' extension.
+func (e *Encoder) encodeUntrackedCache(u *UntrackedCache) ([]byte, error) {
+	environments := bytes.NewBuffer(nil)
+	for _, env := range u.Environments {
+		environments.WriteString(env)
+		environments.WriteByte('\x00')
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := binary.WriteVariableWidthInt(buf, int64(environments.Len())); err != nil {
+		return nil, err
+	}
+
+	buf.Write(environments.Bytes())
+	if err := e.encodeUntrackedCacheStat(buf, &u.InfoExcludeStat); err != nil {
+		return nil, err
+	}
+
+	if err := e.encodeUntrackedCacheStat(buf, &u.ExcludesFileStat); err != nil {
+		return nil, err
+	}
+
+	if err := binary.Write(buf,
+		u.DirFlags,
+		u.InfoExcludeHash,
+		u.ExcludesFileHash,
+		[]byte(u.ExcludePerDir), byte('\x00'),
+	); err != nil {
+		return nil, err
+	}
+
+	if u.Root == nil {
+		err := binary.WriteVariableWidthInt(buf, 0)
+		return buf.Bytes(), err
+	}
+
+	var dirs []*UntrackedCacheDirectory
+	blocks := bytes.NewBuffer(nil)
+	if err := encodeUntrackedCacheDirectory(blocks, u.Root, &dirs); err != nil {
+		return nil, err
+	}
+
+	if err := binary.WriteVariableWidthInt(buf, int64(len(dirs))); err != nil {
+		return nil, err
+	}
+
+	buf.Write(blocks.Bytes())
+
+	var valid, checkOnly, hashValid []int
+	for i, d := range dirs {
+		if d.Valid {
+			valid = append(valid, i)
+		}
+
+		if d.CheckOnly {
+			checkOnly = append(checkOnly, i)
+		}
+
+		if !d.ExcludeHash.IsZero() {
+			hashValid = append(hashValid, i)
+		}
+	}
+
+	for _, bits := range [][]int{valid, checkOnly, hashValid} {
+		if err := encodeEWAH(buf, bits); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, i := range valid {
+		if err := e.encodeUntrackedCacheStat(buf, &dirs[i].Stat); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, i := range hashValid {
+		if err := binary.Write(buf, dirs[i].ExcludeHash); err != nil {
+			return nil, err
+		}
+	}
+
+	// a NUL byte is added as safeguard, as git does
+	buf.WriteByte('\x00')
+	return buf.Bytes(), nil
+}
+
+// encodeUntrackedCacheDirectory writes the block of the directory and its
+// sub-directories, appending them to dirs in depth-first order.
+func encodeUntrackedCacheDirectory(w io.Writer, d *UntrackedCacheDirectory, dirs *[]*UntrackedCacheDirectory) error {
+	*dirs = append(*dirs, d)
+
+	untracked := d.Untracked
+	if !d.Valid {
+		untracked = nil
+	}
+
+	if err := binary.WriteVariableWidthInt(w, int64(len(untracked))); err != nil {
+		return err
+	}
+
+	if err := binary.WriteVariableWidthInt(w, int64(len(d.Directories))); err != nil {
+		return err
+	}
+
+	if err := binary.Write(w, []byte(d.Name), byte('\x00')); err != nil {
+		return err
+	}
+
+	for _, name := range untracked {
+		if err := binary.Write(w, []byte(name), byte('\x00')); err != nil {
+			return err
+		}
+	}
+
+	for _, sub := range d.Directories {
+		if err := encodeUntrackedCacheDirectory(w, sub, dirs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (e *Encoder) encodeUntrackedCacheStat(w io.Writer, s *UntrackedCacheStat) error {
+	sec, nsec, err := e.timeToUint32(&s.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	msec, mnsec, err := e.timeToUint32(&s.ModifiedAt)
+	if err != nil {
+		return err
+	}
+
+	return binary.Write(w,
+		sec, nsec,
+		msec, mnsec,
+		s.Dev, s.Inode,
+		s.UID, s.GID,
+		s.Size,
+	)
 }
 
 func (e *Encoder) timeToUint32(t *time.Time) (uint32, uint32, error) {
@@ -148,3 +512,20 @@ type byName []*Entry
 func (l byName) Len() int           { return len(l) }
 func (l byName) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
 func (l byName) Less(i, j int) bool { return l[i].Name < l[j].Name }
+
+type counter struct {
+	n int
+}
+
+func (c *counter) Write(p []byte) (int, error) {
+	c.n += len(p)
+	return len(p), nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
@@ -56,7 +56,7 @@ func (s *IndexSuite) TestEncode(c *C) {
 }
 
 func (s *IndexSuite) TestEncodeUnsuportedVersion(c *C) {
-	idx := &Index{Version: 3}
+	idx := &Index{Version: 5}
 
 	buf := bytes.NewBuffer(nil)
 	e := NewEncoder(buf)
@@ -87,3 +87,236 @@ func (s *IndexSuite) TestEncodeWithSkipWorktreeUnsuportedVersion(c *C) {
 	err := e.Encode(idx)
 	c.Assert(err, Equals, ErrUnsupportedVersion)
 }
+
+func (s *IndexSuite) TestEncodeV3(c *C) {
+	idx := &Index{
+		Version: 3,
+		Entries: []*Entry{{
+			Hash:         plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3"),
+			Name:         "bar",
+			SkipWorktree: true,
+		}, {
+			Name:        "baz",
+			AssumeValid: true,
+		}, {
+			Name:        "foo",
+			IntentToAdd: true,
+		}, {
+			Name: "qux",
+		}},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	e := NewEncoder(buf)
+	err := e.Encode(idx)
+	c.Assert(err, IsNil)
+
+	output := &Index{}
+	d := NewDecoder(buf)
+	err = d.Decode(output)
+	c.Assert(err, IsNil)
+
+	c.Assert(cmp.Equal(idx, output), Equals, true)
+}
+
+func (s *IndexSuite) TestEncodeAssumeValidV2(c *C) {
+	idx := &Index{
+		Version: 2,
+		Entries: []*Entry{{Name: "foo", AssumeValid: true}},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	e := NewEncoder(buf)
+	err := e.Encode(idx)
+	c.Assert(err, IsNil)
+
+	output := &Index{}
+	d := NewDecoder(buf)
+	err = d.Decode(output)
+	c.Assert(err, IsNil)
+
+	c.Assert(output.Entries[0].AssumeValid, Equals, true)
+}
+
+func (s *IndexSuite) newIndex(version uint32, names ...string) *Index {
+	idx := &Index{Version: version}
+	for _, name := range names {
+		idx.Entries = append(idx.Entries, &Entry{
+			Hash:       plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3"),
+			Name:       name,
+			ModifiedAt: time.Unix(1569346556, 42),
+			Size:       uint32(len(name)),
+		})
+	}
+
+	return idx
+}
+
+func (s *IndexSuite) encodeDecode(c *C, idx *Index) (*Index, []byte) {
+	buf := bytes.NewBuffer(nil)
+	err := NewEncoder(buf).Encode(idx)
+	c.Assert(err, IsNil)
+
+	data := buf.Bytes()
+
+	output := &Index{}
+	err = NewDecoder(bytes.NewReader(data)).Decode(output)
+	c.Assert(err, IsNil)
+	return output, data
+}
+
+func (s *IndexSuite) TestEncodeV4(c *C) {
+	idx := s.newIndex(4, "bar", "foo/bar", "foo/bar/baz", "foo/qux", "qux")
+	idx.Entries[1].SkipWorktree = true
+
+	output, _ := s.encodeDecode(c, idx)
+	c.Assert(cmp.Equal(idx, output), Equals, true)
+}
+
+func (s *IndexSuite) TestEncodeOffsetTable(c *C) {
+	defer func(size int) { offsetTableBlockSize = size }(offsetTableBlockSize)
+	offsetTableBlockSize = 3
+
+	for _, version := range []uint32{2, 4} {
+		idx := s.newIndex(version,
+			"a", "b/a", "b/b", "b/c", "c/a", "c/b", "c/c/a", "d",
+		)
+
+		output, data := s.encodeDecode(c, idx)
+		c.Assert(cmp.Equal(idx, output), Equals, true)
+
+		offset, ok := endOfIndexEntries(data[:len(data)-hashSize])
+		c.Assert(ok, Equals, true)
+		c.Assert(string(data[offset:offset+4]), Equals, "IEOT")
+
+		blocks := decodeOffsetTable(data[offset+8 : offset+8+4+3*8])
+		c.Assert(blocks, HasLen, 3)
+		c.Assert(blocks[0].Offset, Equals, uint32(indexHeaderLength))
+		c.Assert(blocks[2].Count, Equals, uint32(2))
+		c.Assert(validOffsetTable(blocks, len(idx.Entries), offset), Equals, true)
+	}
+}
+
+func (s *IndexSuite) TestEncodeExtensions(c *C) {
+	idx := s.newIndex(2, "foo")
+	idx.Extensions = []*Extension{{
+		Signature: [4]byte{'U', 'N', 'K', 'N'},
+		Data:      []byte("foo"),
+	}}
+
+	output, _ := s.encodeDecode(c, idx)
+	c.Assert(cmp.Equal(idx, output), Equals, true)
+}
+
+func (s *IndexSuite) TestEncodeUntrackedCache(c *C) {
+	stat := UntrackedCacheStat{
+		CreatedAt:  time.Unix(1473350251, 12059307),
+		ModifiedAt: time.Unix(1480626693, 498593596),
+		Dev:        4242,
+		Inode:      424242,
+		UID:        84,
+		GID:        8484,
+		Size:       4096,
+	}
+
+	idx := s.newIndex(2, "a/foo")
+	idx.UntrackedCache = &UntrackedCache{
+		Environments:  []string{"Location /foo, system Linux"},
+		DirFlags:      6,
+		ExcludePerDir: ".gitignore",
+		Root: &UntrackedCacheDirectory{
+			Untracked:   []string{".gitignore", "c/"},
+			Valid:       true,
+			Stat:        stat,
+			ExcludeHash: plumbing.NewHash("cfce1ade9509051648024d9a26997f898ae6821f"),
+			Directories: []*UntrackedCacheDirectory{{
+				Name:      "a",
+				Untracked: []string{"bar"},
+				Valid:     true,
+				Stat:      stat,
+			}, {
+				Name:      "c",
+				Untracked: []string{"qux"},
+				Valid:     true,
+				CheckOnly: true,
+				Stat:      stat,
+			}, {
+				Name: "d",
+			}},
+		},
+	}
+
+	output, _ := s.encodeDecode(c, idx)
+	c.Assert(cmp.Equal(idx, output), Equals, true)
+
+	idx.UntrackedCache.Root = nil
+	output, _ = s.encodeDecode(c, idx)
+	c.Assert(cmp.Equal(idx, output), Equals, true)
+}
+
+func (s *IndexSuite) TestDecodeUnsupportedExtension(c *C) {
+	idx := s.newIndex(2, "foo")
+	idx.Extensions = []*Extension{{
+		Signature: [4]byte{'s', 'd', 'i', 'r'},
+		Data:      []byte("foo"),
+	}}
+
+	buf := bytes.NewBuffer(nil)
+	err := NewEncoder(buf).Encode(idx)
+	c.Assert(err, IsNil)
+
+	err = NewDecoder(buf).Decode(&Index{})
+	c.Assert(err, Equals, ErrUnsupportedExtension)
+}
+
+func (s *IndexSuite) TestEncodeSplitIndex(c *C) {
+	shared := s.newIndex(2, "a", "b", "c", "d")
+
+	idx := s.newIndex(2, "b", "e")
+	idx.Entries[0].Size = 42
+	idx.SplitIndex = &SplitIndex{
+		SharedIndex: plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3"),
+		Delete:      []int{2},
+		Replace:     []int{1},
+	}
+
+	err := idx.ApplySharedIndex(shared)
+	c.Assert(err, IsNil)
+	c.Assert(idx.Entries, HasLen, 4)
+	c.Assert(idx.Entries[1].Name, Equals, "b")
+	c.Assert(idx.Entries[1].Size, Equals, uint32(42))
+	c.Assert(idx.Entries[3].Name, Equals, "e")
+
+	_, err = idx.Remove("d")
+	c.Assert(err, IsNil)
+	idx.Add("f")
+
+	buf := bytes.NewBuffer(nil)
+	err = NewEncoder(buf).Encode(idx)
+	c.Assert(err, IsNil)
+
+	output := &Index{}
+	err = NewDecoder(buf).Decode(output)
+	c.Assert(err, IsNil)
+	c.Assert(output.Entries, HasLen, 3)
+	c.Assert(output.SplitIndex.SharedIndex, Equals, idx.SplitIndex.SharedIndex)
+	c.Assert(output.SplitIndex.Delete, DeepEquals, []int{2, 3})
+	c.Assert(output.SplitIndex.Replace, DeepEquals, []int{1})
+
+	err = output.ApplySharedIndex(shared)
+	c.Assert(err, IsNil)
+
+	var names []string
+	for _, e := range output.Entries {
+		names = append(names, e.Name)
+	}
+
+	c.Assert(names, DeepEquals, []string{"a", "b", "e", "f"})
+	c.Assert(output.Entries[1].Size, Equals, uint32(42))
+}
+
+func (s *IndexSuite) TestApplySharedIndexInvalid(c *C) {
+	idx := &Index{SplitIndex: &SplitIndex{Replace: []int{0}}}
+	err := idx.ApplySharedIndex(s.newIndex(2, "a"))
+	c.Assert(err, Equals, ErrInvalidSplitIndex)
+}
@@ -0,0 +1,134 @@
+package index
+
+import (
+	"io"
+
+	"gopkg.in/src-d/go-git.v4/utils/binary"
+)
+
+// The EWAH compressed bitmaps, as serialized by git, are composed by:
+//
+//   - 32-bit size of the bitmap in bits
+//   - 32-bit number of the 64-bit words
+//   - the 64-bit words
+//   - 32-bit position of the last running length word
+//
+// Every running length word contains at its lowest bit the running bit, at
+// the next 32 bits the number of words full of the running bit, and at the
+// highest 31 bits the number of literal words following it.
+const (
+	ewahRunningBits = 32
+	ewahLiteralBits = 31
+
+	ewahMaxRunningLength = 1<<ewahRunningBits - 1
+	ewahMaxLiteralWords  = 1<<ewahLiteralBits - 1
+)
+
+// decodeEWAH reads an EWAH bitmap, returning the position of the set bits.
+func decodeEWAH(r io.Reader) ([]int, error) {
+	size, err := binary.ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := binary.ReadUint32(r)
+	if err != nil {
+		return nil, err
+	}
+
+	words := make([]uint64, count)
+	for i := range words {
+		if words[i], err = binary.ReadUint64(r); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := binary.ReadUint32(r); err != nil {
+		return nil, err
+	}
+
+	var bits []int
+	var pos int
+	for i := 0; i < len(words) && pos < int(size); {
+		rlw := words[i]
+		i++
+
+		running := int(rlw >> 1 & ewahMaxRunningLength)
+		literals := int(rlw >> (1 + ewahRunningBits))
+
+		if rlw&1 != 0 {
+			for b := 0; b < running*64 && pos+b < int(size); b++ {
+				bits = append(bits, pos+b)
+			}
+		}
+
+		pos += running * 64
+		for j := 0; j < literals && i < len(words); j++ {
+			w := words[i]
+			i++
+
+			for b := 0; b < 64; b++ {
+				if w>>uint(b)&1 != 0 && pos+b < int(size) {
+					bits = append(bits, pos+b)
+				}
+			}
+
+			pos += 64
+		}
+	}
+
+	return bits, nil
+}
+
+// encodeEWAH writes an EWAH bitmap with the given positions set, which must be
+// sorted. Only runs of unset bits are compressed.
+func encodeEWAH(w io.Writer, bits []int) error {
+	var size int
+	if len(bits) != 0 {
+		size = bits[len(bits)-1] + 1
+	}
+
+	raw := make([]uint64, (size+63)/64)
+	for _, b := range bits {
+		raw[b/64] |= 1 << uint(b%64)
+	}
+
+	words := []uint64{0}
+	rlw := 0
+	var running, literals uint64
+	flush := func() {
+		words[rlw] = running<<1 | literals<<(1+ewahRunningBits)
+	}
+
+	for _, word := range raw {
+		if word == 0 && literals == 0 && running < ewahMaxRunningLength {
+			running++
+			continue
+		}
+
+		if word == 0 || literals == ewahMaxLiteralWords {
+			flush()
+			words = append(words, 0)
+			rlw = len(words) - 1
+			running, literals = 0, 0
+
+			if word == 0 {
+				running++
+				continue
+			}
+		}
+
+		literals++
+		words = append(words, word)
+	}
+
+	flush()
+
+	flow := []interface{}{uint32(size), uint32(len(words))}
+	for _, word := range words {
+		flow = append(flow, word)
+	}
+
+	flow = append(flow, uint32(rlw))
+	return binary.Write(w, flow...)
+}
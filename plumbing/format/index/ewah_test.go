@@ -0,0 +1,48 @@
+package index
+
+import (
+	"bytes"
+
+	. "gopkg.in/check.v1"
+)
+
+type EWAHSuite struct{}
+
+var _ = Suite(&EWAHSuite{})
+
+func (s *EWAHSuite) TestEncodeDecode(c *C) {
+	for _, bits := range [][]int{
+		nil,
+		{0},
+		{63, 64},
+		{1, 5, 200, 201, 202, 1000, 5000, 5001},
+	} {
+		buf := bytes.NewBuffer(nil)
+		err := encodeEWAH(buf, bits)
+		c.Assert(err, IsNil)
+
+		decoded, err := decodeEWAH(buf)
+		c.Assert(err, IsNil)
+		c.Assert(decoded, DeepEquals, bits)
+		c.Assert(buf.Len(), Equals, 0)
+	}
+}
+
+func (s *EWAHSuite) TestDecodeRunningBits(c *C) {
+	// 130 bits, a run of two words of set bits followed by a literal word
+	// with the bit 1 set.
+	buf := bytes.NewBuffer([]byte{
+		0, 0, 0, 130,
+		0, 0, 0, 2,
+		0, 0, 0, 2, 0, 0, 0, 5,
+		0, 0, 0, 0, 0, 0, 0, 2,
+		0, 0, 0, 0,
+	})
+
+	bits, err := decodeEWAH(buf)
+	c.Assert(err, IsNil)
+	c.Assert(bits, HasLen, 129)
+	c.Assert(bits[0], Equals, 0)
+	c.Assert(bits[127], Equals, 127)
+	c.Assert(bits[128], Equals, 129)
+}
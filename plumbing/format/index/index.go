@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
@@ -17,10 +18,18 @@ var (
 	ErrUnsupportedVersion = errors.New("unsupported version")
 	// ErrEntryNotFound is returned by Index.Entry, if an entry is not found.
 	ErrEntryNotFound = errors.New("entry not found")
+	// ErrInvalidSplitIndex is returned by ApplySharedIndex if the split index
+	// doesn't match the shared index.
+	ErrInvalidSplitIndex = errors.New("split index doesn't match the shared index")
 
-	indexSignature          = []byte{'D', 'I', 'R', 'C'}
-	treeExtSignature        = []byte{'T', 'R', 'E', 'E'}
-	resolveUndoExtSignature = []byte{'R', 'E', 'U', 'C'}
+	indexSignature              = []byte{'D', 'I', 'R', 'C'}
+	treeExtSignature            = []byte{'T', 'R', 'E', 'E'}
+	resolveUndoExtSignature     = []byte{'R', 'E', 'U', 'C'}
+	offsetTableExtSignature     = []byte{'I', 'E', 'O', 'T'}
+	endOfIndexEntryExtSignature = []byte{'E', 'O', 'I', 'E'}
+	splitIndexExtSignature      = []byte{'l', 'i', 'n', 'k'}
+	untrackedCacheExtSignature  = []byte{'U', 'N', 'T', 'R'}
+	fsMonitorExtSignature       = []byte{'F', 'S', 'M', 'N'}
 )
 
 // Stage during merge
@@ -50,6 +59,144 @@ type Index struct {
 	Cache *Tree
 	// ResolveUndo represents the 'Resolve undo' extension
 	ResolveUndo *ResolveUndo
+	// SplitIndex represents the 'Split index' extension
+	SplitIndex *SplitIndex
+	// UntrackedCache represents the 'Untracked cache' extension
+	UntrackedCache *UntrackedCache
+	// FSMonitor represents the 'File system monitor cache' extension
+	FSMonitor *FSMonitor
+	// Extensions contains the optional extensions not supported by go-git,
+	// which are written back by the Encoder as they were read
+	Extensions []*Extension
+}
+
+// Extension is an index extension, stored as is.
+type Extension struct {
+	// Signature identifies the extension, the optional extensions start with
+	// an uppercase letter
+	Signature [4]byte
+	// Data is the content of the extension, without its header
+	Data []byte
+}
+
+// SplitIndex represents the 'Split index' extension, the majority of the
+// entries are stored at a shared index file and the index only records the
+// changes made on top of them. Until ApplySharedIndex is called, the entries
+// of the index are the replaced entries, followed by the added ones.
+type SplitIndex struct {
+	// SharedIndex is the hash of the shared index file, stored at
+	// $GIT_DIR/sharedindex.<hash>. If zero there is no shared index.
+	SharedIndex plumbing.Hash
+	// Delete contains the positions of the entries of the shared index
+	// removed by the index.
+	Delete []int
+	// Replace contains the positions of the entries of the shared index
+	// replaced by the entries of the index.
+	Replace []int
+
+	shared []*Entry
+}
+
+// ApplySharedIndex merges the entries of the shared index with the changes
+// recorded at the index. Once applied, the Encoder writes the index split
+// again from the same shared index.
+func (i *Index) ApplySharedIndex(shared *Index) error {
+	s := i.SplitIndex
+	if s == nil || s.shared != nil {
+		return nil
+	}
+
+	if len(s.Replace) > len(i.Entries) {
+		return ErrInvalidSplitIndex
+	}
+
+	deleted := make(map[int]bool, len(s.Delete))
+	for _, pos := range s.Delete {
+		deleted[pos] = true
+	}
+
+	replaced := make(map[int]bool, len(s.Replace))
+	for _, pos := range s.Replace {
+		replaced[pos] = true
+	}
+
+	replacements := i.Entries[:len(s.Replace)]
+	entries := make([]*Entry, 0, len(shared.Entries)+len(i.Entries))
+	for pos, e := range shared.Entries {
+		switch {
+		case deleted[pos]:
+		case replaced[pos]:
+			r := replacements[0]
+			replacements = replacements[1:]
+			if r.Name == "" {
+				r.Name = e.Name
+			}
+
+			entries = append(entries, r)
+		default:
+			c := *e
+			entries = append(entries, &c)
+		}
+	}
+
+	if len(replacements) != 0 {
+		return ErrInvalidSplitIndex
+	}
+
+	i.Entries = append(entries, i.Entries[len(s.Replace):]...)
+	sort.Stable(byName(i.Entries))
+
+	s.Delete, s.Replace = nil, nil
+	s.shared = shared.Entries
+	return nil
+}
+
+// split returns the entries to be written at the index, the replaced entries
+// followed by the added ones, and the positions of the entries of the shared
+// index deleted and replaced by them.
+func (s *SplitIndex) split(entries []*Entry) ([]*Entry, []int, []int) {
+	if s.shared == nil {
+		return entries, s.Delete, s.Replace
+	}
+
+	type key struct {
+		name  string
+		stage Stage
+	}
+
+	current := make(map[key]*Entry, len(entries))
+	for _, e := range entries {
+		current[key{e.Name, e.Stage}] = e
+	}
+
+	var written []*Entry
+	var deleted, replaced []int
+	used := make(map[*Entry]bool, len(entries))
+	for pos, base := range s.shared {
+		e, ok := current[key{base.Name, base.Stage}]
+		if !ok {
+			deleted = append(deleted, pos)
+			continue
+		}
+
+		used[e] = true
+		if *e != *base {
+			// the replaced entries are written without name, as git expects
+			r := *e
+			r.Name = ""
+
+			replaced = append(replaced, pos)
+			written = append(written, &r)
+		}
+	}
+
+	for _, e := range entries {
+		if !used[e] {
+			written = append(written, e)
+		}
+	}
+
+	return written, deleted, replaced
 }
 
 // Add creates a new Entry and returns it. The caller should first check that
@@ -145,6 +292,14 @@ type Entry struct {
 	// IntentToAdd record only the fact that the path will be added later
 	// https://git-scm.com/docs/git-add ("git add -N")
 	IntentToAdd bool
+	// AssumeValid marks the path as unchanged, the file at the worktree is
+	// not compared with the entry
+	// https://git-scm.com/docs/git-update-index ("--assume-unchanged")
+	AssumeValid bool
+	// FSMonitorValid marks the path as not changed at the worktree since the
+	// token of the 'File system monitor cache' extension
+	// https://git-scm.com/docs/git-update-index ("--fsmonitor-valid")
+	FSMonitorValid bool
 }
 
 func (e Entry) String() string {
@@ -193,3 +348,78 @@ type ResolveUndoEntry struct {
 	Path   string
 	Stages map[Stage]plumbing.Hash
 }
+
+// FSMonitor represents the 'File system monitor cache' extension, it records
+// the point in time since which the entries marked as FSMonitorValid are
+// known to be unchanged at the worktree, as reported by a file system
+// monitor.
+type FSMonitor struct {
+	// Token is the opaque token defined by the file system monitor, for the
+	// version 1 of the extension it is the time as nanoseconds since the
+	// epoch
+	Token string
+
+	dirty []int
+}
+
+// UntrackedCache represents the 'Untracked cache' extension, it saves the
+// untracked files of every directory, along with the data required to check
+// that the directory didn't change since they were collected.
+type UntrackedCache struct {
+	// Environments describe where the cache can be used, as
+	// "Location <worktree>, system <os>"
+	Environments []string
+	// InfoExcludeStat is the stat data of $GIT_DIR/info/exclude
+	InfoExcludeStat UntrackedCacheStat
+	// ExcludesFileStat is the stat data of the file at core.excludesfile
+	ExcludesFileStat UntrackedCacheStat
+	// DirFlags are the flags used to collect the untracked files
+	DirFlags uint32
+	// InfoExcludeHash is the hash of $GIT_DIR/info/exclude, zero if the file
+	// does not exist
+	InfoExcludeHash plumbing.Hash
+	// ExcludesFileHash is the hash of the file at core.excludesfile, zero if
+	// the file does not exist
+	ExcludesFileHash plumbing.Hash
+	// ExcludePerDir is the name of the per-directory exclude file, usually
+	// .gitignore
+	ExcludePerDir string
+	// Root is the cache of the root directory of the worktree, nil if the
+	// cache is empty
+	Root *UntrackedCacheDirectory
+}
+
+// UntrackedCacheDirectory contains the untracked files of a directory.
+type UntrackedCacheDirectory struct {
+	// Name of the directory, relative to its parent directory
+	Name string
+	// Untracked are the names of the untracked files, the untracked
+	// directories end with a slash
+	Untracked []string
+	// Directories are the cached sub-directories
+	Directories []*UntrackedCacheDirectory
+	// Valid is true if Untracked and Stat are up to date
+	Valid bool
+	// CheckOnly is true if the directory was only checked for the existence
+	// of untracked files
+	CheckOnly bool
+	// Stat is the stat data of the directory, when the untracked files were
+	// collected
+	Stat UntrackedCacheStat
+	// ExcludeHash is the hash of the per-directory exclude file, zero if the
+	// file does not exist
+	ExcludeHash plumbing.Hash
+}
+
+// UntrackedCacheStat is the stat data of a file or directory, as stored by
+// the 'Untracked cache' extension.
+type UntrackedCacheStat struct {
+	// CreatedAt and ModifiedAt are the change and modification times
+	CreatedAt, ModifiedAt time.Time
+	// Dev and Inode of the path
+	Dev, Inode uint32
+	// UID and GID, userid and group id of the owner
+	UID, GID uint32
+	// Size of the path
+	Size uint32
+}
@@ -0,0 +1,144 @@
+// Package mailmap implements the mailmap format, mapping the names and emails
+// of the authors and committers of the commits to their canonical ones, as
+// described at gitmailmap(5).
+//
+// Every line maps an identity, matched by its email, or by its name and
+// email, to the proper name, the proper email or both:
+//
+//	Proper Name <commit@email.xx>
+//	<proper@email.xx> <commit@email.xx>
+//	Proper Name <proper@email.xx> <commit@email.xx>
+//	Proper Name <proper@email.xx> Commit Name <commit@email.xx>
+//
+// The names and emails are matched case-insensitively, and the lines starting
+// with # are comments.
+package mailmap
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Mailmap maps the identities of the commits to their canonical ones.
+type Mailmap struct {
+	entries map[string]*entry
+}
+
+// entry are the mappings of an email, the default one and the ones by name.
+type entry struct {
+	identity
+	names map[string]identity
+}
+
+type identity struct {
+	name, email string
+}
+
+// New returns an empty Mailmap.
+func New() *Mailmap {
+	return &Mailmap{entries: make(map[string]*entry)}
+}
+
+// Parse parses a mailmap file.
+func Parse(r io.Reader) (*Mailmap, error) {
+	m := New()
+	return m, m.Read(r)
+}
+
+// Read reads the mappings of a mailmap file into m, overriding the existing
+// mappings of the same identities.
+func (m *Mailmap) Read(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		properName, properEmail, rest, ok := parseIdentity(line)
+		if !ok {
+			continue
+		}
+
+		commitName, commitEmail, _, _ := parseIdentity(rest)
+		m.Add(properName, properEmail, commitName, commitEmail)
+	}
+
+	return s.Err()
+}
+
+// Add maps the commit identity to the proper one. An empty commitName maps
+// every name of commitEmail, and an empty commitEmail maps properEmail, only
+// replacing its name. The empty proper name or email aren't replaced.
+func (m *Mailmap) Add(properName, properEmail, commitName, commitEmail string) {
+	if commitEmail == "" {
+		commitEmail, properEmail = properEmail, ""
+	}
+
+	key := strings.ToLower(commitEmail)
+	e, ok := m.entries[key]
+	if !ok {
+		e = &entry{names: make(map[string]identity)}
+		m.entries[key] = e
+	}
+
+	if commitName != "" {
+		e.names[strings.ToLower(commitName)] = identity{properName, properEmail}
+		return
+	}
+
+	if properName != "" {
+		e.name = properName
+	}
+
+	if properEmail != "" {
+		e.email = properEmail
+	}
+}
+
+// Map returns the canonical name and email of the given identity, the same
+// ones if it isn't mapped.
+func (m *Mailmap) Map(name, email string) (string, string) {
+	e, ok := m.entries[strings.ToLower(email)]
+	if !ok {
+		return name, email
+	}
+
+	id := e.identity
+	if named, ok := e.names[strings.ToLower(name)]; ok {
+		id = named
+	}
+
+	if id.name != "" {
+		name = id.name
+	}
+
+	if id.email != "" {
+		email = id.email
+	}
+
+	return name, email
+}
+
+// Len returns the number of emails mapped.
+func (m *Mailmap) Len() int {
+	return len(m.entries)
+}
+
+// parseIdentity parses the next "Name <email>" of the line, the name being
+// optional, returning the rest of the line after it.
+func parseIdentity(line string) (name, email, rest string, ok bool) {
+	left := strings.IndexByte(line, '<')
+	if left == -1 {
+		return "", "", "", false
+	}
+
+	right := strings.IndexByte(line[left+1:], '>')
+	if right == -1 {
+		return "", "", "", false
+	}
+
+	right += left + 1
+	return strings.TrimSpace(line[:left]), line[left+1 : right], line[right+1:], true
+}
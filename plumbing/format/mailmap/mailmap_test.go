@@ -0,0 +1,57 @@
+package mailmap
+
+import (
+	"strings"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type MailmapSuite struct{}
+
+var _ = Suite(&MailmapSuite{})
+
+const fixture = `# comment
+Joe Developer <joe@example.com>
+<jane@example.com> <jane@laptop.(none)>
+Jane Doe <jane@example.com> <jane@desktop.(none)>
+Other Author <other@author.xx> nick1 <bugs@company.xx>
+Other Author <other@author.xx> Nick2 <BUGS@Company.xx> # trailing comment
+Santa Claus <santa.claus@northpole.xx> <me@company.xx>
+invalid line
+`
+
+func (s *MailmapSuite) TestMap(c *C) {
+	m, err := Parse(strings.NewReader(fixture))
+	c.Assert(err, IsNil)
+	c.Assert(m.Len(), Equals, 5)
+
+	for _, t := range []struct {
+		name, email, expectedName, expectedEmail string
+	}{
+		{"joe", "Joe@example.com", "Joe Developer", "Joe@example.com"},
+		{"Jane", "jane@laptop.(none)", "Jane", "jane@example.com"},
+		{"Jane", "jane@desktop.(none)", "Jane Doe", "jane@example.com"},
+		{"nick1", "bugs@company.xx", "Other Author", "other@author.xx"},
+		{"NICK2", "bugs@company.xx", "Other Author", "other@author.xx"},
+		{"nick3", "bugs@company.xx", "nick3", "bugs@company.xx"},
+		{"Santa", "me@company.xx", "Santa Claus", "santa.claus@northpole.xx"},
+		{"foo", "foo@foo.foo", "foo", "foo@foo.foo"},
+	} {
+		name, email := m.Map(t.name, t.email)
+		c.Assert(name, Equals, t.expectedName, Commentf("%s <%s>", t.name, t.email))
+		c.Assert(email, Equals, t.expectedEmail, Commentf("%s <%s>", t.name, t.email))
+	}
+}
+
+func (s *MailmapSuite) TestReadOverrides(c *C) {
+	m, err := Parse(strings.NewReader("Joe <joe@example.com>\n"))
+	c.Assert(err, IsNil)
+
+	c.Assert(m.Read(strings.NewReader("Joe Developer <joe@example.com>\n")), IsNil)
+
+	name, _ := m.Map("joe", "joe@example.com")
+	c.Assert(name, Equals, "Joe Developer")
+}
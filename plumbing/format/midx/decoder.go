@@ -0,0 +1,161 @@
+package midx
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+var (
+	// ErrUnsupportedVersion is returned by Decode when the multi-pack-index
+	// version or its object id version is not supported.
+	ErrUnsupportedVersion = errors.New("unsupported multi-pack-index version")
+	// ErrMalformedMultiPackIndex is returned by Decode when the
+	// multi-pack-index file is corrupted.
+	ErrMalformedMultiPackIndex = errors.New("malformed multi-pack-index file")
+)
+
+// Decoder reads and decodes multi-pack-index files from an input stream.
+type Decoder struct {
+	r io.Reader
+}
+
+// NewDecoder builds a new multi-pack-index decoder, that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r}
+}
+
+// decodedChunks are the chunks of a multi-pack-index file, by ID.
+type decodedChunks map[[4]byte][]byte
+
+// Decode reads from the stream and decodes the content into the
+// MultiPackIndex.
+func (d *Decoder) Decode(m *MultiPackIndex) error {
+	content, err := ioutil.ReadAll(d.r)
+	if err != nil {
+		return err
+	}
+
+	if len(content) < headerSize+chunkEntrySize+sha1.Size ||
+		!bytes.Equal(content[:4], signature) {
+		return ErrMalformedMultiPackIndex
+	}
+
+	if content[4] != VersionSupported || content[5] != sha1Version || content[7] != 0 {
+		return ErrUnsupportedVersion
+	}
+
+	body := content[:len(content)-sha1.Size]
+	sum := sha1.Sum(body)
+	if !bytes.Equal(sum[:], content[len(body):]) {
+		return ErrMalformedMultiPackIndex
+	}
+
+	chunks, err := readChunks(body, int(content[6]))
+	if err != nil {
+		return err
+	}
+
+	packs := int(binary.BigEndian.Uint32(content[8:]))
+	if err := readPackNames(m, chunks[packNamesChunk], packs); err != nil {
+		return err
+	}
+
+	if err := readEntries(m, chunks); err != nil {
+		return err
+	}
+
+	copy(m.Checksum[:], sum[:])
+	return nil
+}
+
+func readChunks(body []byte, count int) (decodedChunks, error) {
+	table := body[headerSize:]
+	if len(table) < (count+1)*chunkEntrySize {
+		return nil, ErrMalformedMultiPackIndex
+	}
+
+	chunks := make(decodedChunks)
+	for i := 0; i < count; i++ {
+		entry := table[i*chunkEntrySize:]
+		next := table[(i+1)*chunkEntrySize:]
+
+		var id [4]byte
+		copy(id[:], entry)
+		start := binary.BigEndian.Uint64(entry[4:])
+		end := binary.BigEndian.Uint64(next[4:])
+		if start > end || end > uint64(len(body)) {
+			return nil, ErrMalformedMultiPackIndex
+		}
+
+		chunks[id] = body[start:end]
+	}
+
+	return chunks, nil
+}
+
+func readPackNames(m *MultiPackIndex, names []byte, count int) error {
+	m.PackNames = make([]string, 0, count)
+	for len(m.PackNames) < count {
+		i := bytes.IndexByte(names, 0)
+		if i <= 0 {
+			return ErrMalformedMultiPackIndex
+		}
+
+		name := string(names[:i])
+		if n := len(m.PackNames); n > 0 && m.PackNames[n-1] >= name {
+			return ErrMalformedMultiPackIndex
+		}
+
+		m.PackNames = append(m.PackNames, name)
+		names = names[i+1:]
+	}
+
+	return nil
+}
+
+func readEntries(m *MultiPackIndex, chunks decodedChunks) error {
+	fanout, lookup, offsets := chunks[oidFanoutChunk], chunks[oidLookupChunk], chunks[objectOffsetChunk]
+	if len(fanout) != fanoutSize {
+		return ErrMalformedMultiPackIndex
+	}
+
+	n := int(binary.BigEndian.Uint32(fanout[fanoutSize-4:]))
+	if len(lookup) != n*sha1.Size || len(offsets) != n*offsetSize {
+		return ErrMalformedMultiPackIndex
+	}
+
+	large := chunks[largeOffsetChunk]
+	m.Entries = make([]*Entry, n)
+	for i := range m.Entries {
+		e := &Entry{}
+		copy(e.Hash[:], lookup[i*sha1.Size:])
+		if i > 0 && bytes.Compare(m.Entries[i-1].Hash[:], e.Hash[:]) >= 0 {
+			return ErrMalformedMultiPackIndex
+		}
+
+		e.Pack = binary.BigEndian.Uint32(offsets[i*offsetSize:])
+		if int(e.Pack) >= len(m.PackNames) {
+			return ErrMalformedMultiPackIndex
+		}
+
+		offset := binary.BigEndian.Uint32(offsets[i*offsetSize+4:])
+		e.Offset = uint64(offset)
+		if large != nil && offset&largeOffset != 0 {
+			pos := int(offset&largeOffsetMask) * 8
+			if pos+8 > len(large) {
+				return ErrMalformedMultiPackIndex
+			}
+
+			e.Offset = binary.BigEndian.Uint64(large[pos:])
+		}
+
+		m.Entries[i] = e
+	}
+
+	m.sorted = true
+	return nil
+}
@@ -0,0 +1,65 @@
+// Package midx implements encoding and decoding of multi-pack-index files.
+//
+//	== Git multi-pack-index format
+//
+//	The multi-pack-index file indexes the objects of several packfiles,
+//	so an object is looked up once instead of once per packfile. It is
+//	stored at objects/pack/multi-pack-index.
+//
+//	- The header consists of:
+//
+//	  4-byte signature: The signature is: {'M', 'I', 'D', 'X'}
+//
+//	  1-byte version number: Currently, the only valid version is 1.
+//
+//	  1-byte Object Id Version: 1 for SHA-1.
+//
+//	  1-byte number (C) of "chunks"
+//
+//	  1-byte number (I) of base multi-pack-index files: Currently, it is
+//	  always 0.
+//
+//	  4-byte number (P) of pack files
+//
+//	- The chunk lookup table has (C + 1) entries of 12 bytes, a 4-byte chunk
+//	  ID and an 8-byte offset into the file, the last one with ID 0 marking
+//	  the end of the last chunk.
+//
+//	- The chunks are:
+//
+//	  Packfile Names (ID: {'P', 'N', 'A', 'M'})
+//	    Stores the packfile names, the names of their index files, as
+//	    concatenated, null-terminated strings, in lexicographic order. The
+//	    chunk is padded with up to 3 null bytes, so its size is a multiple
+//	    of 4 bytes.
+//
+//	  OID Fanout (ID: {'O', 'I', 'D', 'F'}) (256 * 4 bytes)
+//	    The ith entry, F[i], stores the number of OIDs with first
+//	    byte at most i. Thus F[255] stores the total number of objects (N).
+//
+//	  OID Lookup (ID: {'O', 'I', 'D', 'L'}) (N * H bytes)
+//	    The OIDs for all objects in the MIDX are stored in lexicographic
+//	    order in this chunk.
+//
+//	  Object Offsets (ID: {'O', 'O', 'F', 'F'}) (N * 8 bytes)
+//	    Stores two 4-byte values for every object.
+//	    1: The pack-int-id for the pack storing this object, its position
+//	       at the Packfile Names chunk.
+//	    2: The offset within the pack.
+//	        If all offsets are less than 2^32, then the large offset chunk
+//	        will not exist and offsets are stored as in IDX v1.
+//	        If there is at least one offset value larger than 2^32-1, then
+//	        the large offset chunk must exist, and offsets larger than
+//	        2^31-1 must be stored in it instead. If the large offset chunk
+//	        exists and the 31st bit is on, then removing that bit reveals
+//	        the row in the large offsets containing the 8-byte offset of
+//	        this object.
+//
+//	  Object Large Offsets (ID: {'L', 'O', 'F', 'F'}) [Optional]
+//	    8-byte offsets into large packfiles.
+//
+//	- The trailer is the H-byte checksum of all of the above.
+//
+// Source:
+// https://github.com/git/git/blob/master/Documentation/gitformat-pack.txt
+package midx
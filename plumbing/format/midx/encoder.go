@@ -0,0 +1,132 @@
+package midx
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"hash"
+	"io"
+)
+
+// Encoder writes MultiPackIndex structs to an output stream.
+type Encoder struct {
+	io.Writer
+	hash hash.Hash
+}
+
+// NewEncoder returns a new stream encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	h := sha1.New()
+	mw := io.MultiWriter(w, h)
+	return &Encoder{mw, h}
+}
+
+type encodedChunk struct {
+	id      [4]byte
+	content []byte
+}
+
+// Encode encodes a MultiPackIndex to the encoder writer.
+func (e *Encoder) Encode(m *MultiPackIndex) error {
+	m.sort()
+	chunks := encodeChunks(m)
+
+	header := append([]byte{}, signature...)
+	header = append(header, VersionSupported, sha1Version, byte(len(chunks)), 0)
+	header = appendUint32(header, uint32(len(m.PackNames)))
+
+	offset := uint64(len(header) + (len(chunks)+1)*chunkEntrySize)
+	for _, c := range chunks {
+		header = append(header, c.id[:]...)
+		header = appendUint64(header, offset)
+		offset += uint64(len(c.content))
+	}
+
+	header = append(header, 0, 0, 0, 0)
+	header = appendUint64(header, offset)
+
+	if _, err := e.Write(header); err != nil {
+		return err
+	}
+
+	for _, c := range chunks {
+		if _, err := e.Write(c.content); err != nil {
+			return err
+		}
+	}
+
+	copy(m.Checksum[:], e.hash.Sum(nil))
+	_, err := e.Write(m.Checksum[:])
+	return err
+}
+
+func encodeChunks(m *MultiPackIndex) []encodedChunk {
+	var names []byte
+	for _, name := range m.PackNames {
+		names = append(names, name...)
+		names = append(names, 0)
+	}
+
+	for len(names)%4 != 0 {
+		names = append(names, 0)
+	}
+
+	var counts [256]uint32
+	for _, e := range m.Entries {
+		counts[e.Hash[0]]++
+	}
+
+	fanout := make([]byte, 0, fanoutSize)
+	var total uint32
+	for _, n := range counts {
+		total += n
+		fanout = appendUint32(fanout, total)
+	}
+
+	needsLarge := false
+	for _, e := range m.Entries {
+		if e.Offset > 0xffffffff {
+			needsLarge = true
+			break
+		}
+	}
+
+	lookup := make([]byte, 0, len(m.Entries)*sha1.Size)
+	offsets := make([]byte, 0, len(m.Entries)*offsetSize)
+	var large []byte
+	for _, e := range m.Entries {
+		lookup = append(lookup, e.Hash[:]...)
+		offsets = appendUint32(offsets, e.Pack)
+		if needsLarge && e.Offset > largeOffsetMask {
+			offsets = appendUint32(offsets, largeOffset|uint32(len(large)/8))
+			large = appendUint64(large, e.Offset)
+			continue
+		}
+
+		offsets = appendUint32(offsets, uint32(e.Offset))
+	}
+
+	chunks := []encodedChunk{
+		{packNamesChunk, names},
+		{oidFanoutChunk, fanout},
+		{oidLookupChunk, lookup},
+		{objectOffsetChunk, offsets},
+	}
+
+	if large != nil {
+		chunks = append(chunks, encodedChunk{largeOffsetChunk, large})
+	}
+
+	return chunks
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
@@ -0,0 +1,121 @@
+package midx
+
+import (
+	"bytes"
+	"sort"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+const (
+	// VersionSupported is the only multi-pack-index version supported.
+	VersionSupported = 1
+
+	sha1Version = 1
+
+	headerSize     = 12
+	chunkEntrySize = 12
+	fanoutSize     = 256 * 4
+	offsetSize     = 8
+
+	largeOffset     = 0x80000000
+	largeOffsetMask = 0x7fffffff
+)
+
+var (
+	signature = []byte{'M', 'I', 'D', 'X'}
+
+	packNamesChunk    = [4]byte{'P', 'N', 'A', 'M'}
+	oidFanoutChunk    = [4]byte{'O', 'I', 'D', 'F'}
+	oidLookupChunk    = [4]byte{'O', 'I', 'D', 'L'}
+	objectOffsetChunk = [4]byte{'O', 'O', 'F', 'F'}
+	largeOffsetChunk  = [4]byte{'L', 'O', 'F', 'F'}
+)
+
+// MultiPackIndex is the in memory representation of a multi-pack-index file.
+type MultiPackIndex struct {
+	// PackNames are the names of the index files of the packs, e.g.
+	// pack-<hash>.idx, sorted.
+	PackNames []string
+	// Entries are the objects of the packs, sorted by hash by Encode and
+	// Decode.
+	Entries []*Entry
+	// Checksum is the trailing checksum of the file, set by Decode and
+	// Encode.
+	Checksum plumbing.Hash
+
+	sorted bool
+}
+
+// Entry is an object at a multi-pack-index.
+type Entry struct {
+	Hash plumbing.Hash
+	// Pack is the position at PackNames of the pack storing the object.
+	Pack uint32
+	// Offset is the offset of the object within the pack.
+	Offset uint64
+}
+
+// New returns a MultiPackIndex of the packs with the given index file names,
+// without objects.
+func New(packNames ...string) *MultiPackIndex {
+	names := append([]string(nil), packNames...)
+	sort.Strings(names)
+	return &MultiPackIndex{PackNames: names, sorted: true}
+}
+
+// Pack returns the position at PackNames of the pack with the given index file
+// name, false if it isn't indexed.
+func (m *MultiPackIndex) Pack(name string) (uint32, bool) {
+	i := sort.SearchStrings(m.PackNames, name)
+	if i < len(m.PackNames) && m.PackNames[i] == name {
+		return uint32(i), true
+	}
+
+	return 0, false
+}
+
+// Add adds an object stored by the pack at the given position of PackNames.
+// If the object is added more than once, the first entry is kept.
+func (m *MultiPackIndex) Add(h plumbing.Hash, pack uint32, offset uint64) {
+	m.Entries = append(m.Entries, &Entry{Hash: h, Pack: pack, Offset: offset})
+	m.sorted = false
+}
+
+// FindEntry returns the entry of the object with the given hash, false if it
+// isn't indexed.
+func (m *MultiPackIndex) FindEntry(h plumbing.Hash) (*Entry, bool) {
+	m.sort()
+
+	i := sort.Search(len(m.Entries), func(i int) bool {
+		return bytes.Compare(m.Entries[i].Hash[:], h[:]) >= 0
+	})
+
+	if i < len(m.Entries) && m.Entries[i].Hash == h {
+		return m.Entries[i], true
+	}
+
+	return nil, false
+}
+
+// sort sorts the entries by hash, removing the duplicated ones.
+func (m *MultiPackIndex) sort() {
+	if m.sorted {
+		return
+	}
+
+	sort.SliceStable(m.Entries, func(i, j int) bool {
+		return bytes.Compare(m.Entries[i].Hash[:], m.Entries[j].Hash[:]) < 0
+	})
+
+	entries := m.Entries[:0]
+	for i, e := range m.Entries {
+		if i > 0 && e.Hash == entries[len(entries)-1].Hash {
+			continue
+		}
+
+		entries = append(entries, e)
+	}
+
+	m.Entries, m.sorted = entries, true
+}
@@ -0,0 +1,82 @@
+package midx_test
+
+import (
+	"bytes"
+	"testing"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	. "gopkg.in/src-d/go-git.v4/plumbing/format/midx"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type MultiPackIndexSuite struct{}
+
+var _ = Suite(&MultiPackIndexSuite{})
+
+var (
+	foo = plumbing.NewHash("8d5c1e4bd3b6f3e1c1b0a1c9e5e0c5a1b2c3d4e5")
+	bar = plumbing.NewHash("1d5c1e4bd3b6f3e1c1b0a1c9e5e0c5a1b2c3d4e5")
+)
+
+func multiPackIndex() *MultiPackIndex {
+	m := New("pack-b.idx", "pack-a.idx")
+	m.Add(foo, 1, 12)
+	m.Add(bar, 0, 1<<33)
+	m.Add(foo, 0, 99)
+
+	return m
+}
+
+func (s *MultiPackIndexSuite) TestEncode(c *C) {
+	m := multiPackIndex()
+
+	buf := bytes.NewBuffer(nil)
+	c.Assert(NewEncoder(buf).Encode(m), IsNil)
+	c.Assert(buf.Len(), Equals, 1216)
+	c.Assert(m.Checksum.String(), Equals, "253aaadc20c7b4e7c831f661f8d332b301e23085")
+}
+
+func (s *MultiPackIndexSuite) TestDecode(c *C) {
+	buf := bytes.NewBuffer(nil)
+	c.Assert(NewEncoder(buf).Encode(multiPackIndex()), IsNil)
+
+	m := &MultiPackIndex{}
+	c.Assert(NewDecoder(bytes.NewReader(buf.Bytes())).Decode(m), IsNil)
+	c.Assert(m.PackNames, DeepEquals, []string{"pack-a.idx", "pack-b.idx"})
+	c.Assert(m.Checksum.String(), Equals, "253aaadc20c7b4e7c831f661f8d332b301e23085")
+	c.Assert(m.Entries, DeepEquals, []*Entry{
+		{Hash: bar, Pack: 0, Offset: 1 << 33},
+		{Hash: foo, Pack: 1, Offset: 12},
+	})
+}
+
+func (s *MultiPackIndexSuite) TestFindEntry(c *C) {
+	m := multiPackIndex()
+
+	e, ok := m.FindEntry(foo)
+	c.Assert(ok, Equals, true)
+	c.Assert(e.Offset, Equals, uint64(12))
+
+	_, ok = m.FindEntry(plumbing.ZeroHash)
+	c.Assert(ok, Equals, false)
+
+	i, ok := m.Pack("pack-b.idx")
+	c.Assert(ok, Equals, true)
+	c.Assert(i, Equals, uint32(1))
+}
+
+func (s *MultiPackIndexSuite) TestDecodeMalformed(c *C) {
+	buf := bytes.NewBuffer(nil)
+	c.Assert(NewEncoder(buf).Encode(multiPackIndex()), IsNil)
+
+	raw := buf.Bytes()
+	raw[4] = 2
+	err := NewDecoder(bytes.NewReader(raw)).Decode(&MultiPackIndex{})
+	c.Assert(err, Equals, ErrUnsupportedVersion)
+
+	err = NewDecoder(bytes.NewReader([]byte("MIDX"))).Decode(&MultiPackIndex{})
+	c.Assert(err, Equals, ErrMalformedMultiPackIndex)
+}
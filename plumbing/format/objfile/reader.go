@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io"
 	"strconv"
+	"sync"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
@@ -16,27 +17,56 @@ var (
 	ErrNegativeSize = errors.New("objfile: negative object size")
 )
 
+// zlibReaderPool holds the zlib readers of the closed Readers, since their
+// initialization dominates the cost of reading small objects.
+var zlibReaderPool sync.Pool
+
+type zlibReader interface {
+	io.ReadCloser
+	zlib.Resetter
+}
+
 // Reader reads and decodes compressed objfile data from a provided io.Reader.
 // Reader implements io.ReadCloser. Close should be called when finished with
 // the Reader. Close will not close the underlying io.Reader.
 type Reader struct {
 	multi  io.Reader
-	zlib   io.ReadCloser
+	zlib   zlibReader
 	hasher plumbing.Hasher
 }
 
 // NewReader returns a new Reader reading from r.
 func NewReader(r io.Reader) (*Reader, error) {
-	zlib, err := zlib.NewReader(r)
+	zr, err := newZlibReader(r)
 	if err != nil {
 		return nil, packfile.ErrZLib.AddDetails(err.Error())
 	}
 
 	return &Reader{
-		zlib: zlib,
+		zlib: zr,
 	}, nil
 }
 
+// newZlibReader returns a zlib reader of r, taken from the pool if possible.
+func newZlibReader(r io.Reader) (zlibReader, error) {
+	zr, ok := zlibReaderPool.Get().(zlibReader)
+	if !ok {
+		nzr, err := zlib.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return nzr.(zlibReader), nil
+	}
+
+	if err := zr.Reset(r, nil); err != nil {
+		zlibReaderPool.Put(zr)
+		return nil, err
+	}
+
+	return zr, nil
+}
+
 // Header reads the type and the size of object, and prepares the reader for read
 func (r *Reader) Header() (t plumbing.ObjectType, size int64, err error) {
 	var raw []byte
@@ -99,6 +129,10 @@ func (r *Reader) prepareForRead(t plumbing.ObjectType, size int64) {
 // If Read encounters the end of the data stream it will return err == io.EOF,
 // either in the current call if n > 0 or in a subsequent call.
 func (r *Reader) Read(p []byte) (n int, err error) {
+	if r.zlib == nil {
+		return 0, ErrClosed
+	}
+
 	return r.multi.Read(p)
 }
 
@@ -110,5 +144,12 @@ func (r *Reader) Hash() plumbing.Hash {
 // Close releases any resources consumed by the Reader. Calling Close does not
 // close the wrapped io.Reader originally passed to NewReader.
 func (r *Reader) Close() error {
-	return r.zlib.Close()
+	if r.zlib == nil {
+		return nil
+	}
+
+	err := r.zlib.Close()
+	zlibReaderPool.Put(r.zlib)
+	r.zlib, r.multi = nil, nil
+	return err
 }
@@ -44,6 +44,20 @@ func testReader(c *C, source io.Reader, hash plumbing.Hash, t plumbing.ObjectTyp
 
 }
 
+func (s *SuiteReader) TestReadClosed(c *C) {
+	data, _ := base64.StdEncoding.DecodeString(objfileFixtures[0].data)
+	r, err := NewReader(bytes.NewReader(data))
+	c.Assert(err, IsNil)
+
+	_, _, err = r.Header()
+	c.Assert(err, IsNil)
+	c.Assert(r.Close(), IsNil)
+	c.Assert(r.Close(), IsNil)
+
+	_, err = r.Read(make([]byte, 1))
+	c.Assert(err, Equals, ErrClosed)
+}
+
 func (s *SuiteReader) TestReadEmptyObjfile(c *C) {
 	source := bytes.NewReader([]byte{})
 	_, err := NewReader(source)
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"io"
 	"strconv"
+	"sync"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
 )
@@ -13,12 +14,20 @@ var (
 	ErrOverflow = errors.New("objfile: declared data length exceeded (overflow)")
 )
 
+// zlibWriterPool holds the zlib writers of the closed Writers, since their
+// initialization dominates the cost of writing small objects.
+var zlibWriterPool = sync.Pool{
+	New: func() interface{} {
+		return zlib.NewWriter(nil)
+	},
+}
+
 // Writer writes and encodes data in compressed objfile format to a provided
 // io.Writer. Close should be called when finished with the Writer. Close will
 // not close the underlying io.Writer.
 type Writer struct {
 	raw    io.Writer
-	zlib   io.WriteCloser
+	zlib   *zlib.Writer
 	hasher plumbing.Hasher
 	multi  io.Writer
 
@@ -31,9 +40,12 @@ type Writer struct {
 // The returned Writer implements io.WriteCloser. Close should be called when
 // finished with the Writer. Close will not close the underlying io.Writer.
 func NewWriter(w io.Writer) *Writer {
+	zw := zlibWriterPool.Get().(*zlib.Writer)
+	zw.Reset(w)
+
 	return &Writer{
 		raw:  w,
-		zlib: zlib.NewWriter(w),
+		zlib: zw,
 	}
 }
 
@@ -41,6 +53,10 @@ func NewWriter(w io.Writer) *Writer {
 // contents. If an invalid t is provided, plumbing.ErrInvalidType is returned. If a
 // negative size is provided, ErrNegativeSize is returned.
 func (w *Writer) WriteHeader(t plumbing.ObjectType, size int64) error {
+	if w.closed {
+		return ErrClosed
+	}
+
 	if !t.Valid() {
 		return plumbing.ErrInvalidType
 	}
@@ -100,10 +116,16 @@ func (w *Writer) Hash() plumbing.Hash {
 // Calling Close does not close the wrapped io.Writer originally passed to
 // NewWriter.
 func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+
 	if err := w.zlib.Close(); err != nil {
 		return err
 	}
 
+	zlibWriterPool.Put(w.zlib)
+	w.zlib, w.multi = nil, nil
 	w.closed = true
 	return nil
 }
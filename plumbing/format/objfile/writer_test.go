@@ -78,3 +78,15 @@ func (s *SuiteWriter) TestNewWriterInvalidSize(c *C) {
 	err = w.WriteHeader(plumbing.BlobObject, -1651860)
 	c.Assert(err, Equals, ErrNegativeSize)
 }
+
+func (s *SuiteWriter) TestWriteClosed(c *C) {
+	buf := bytes.NewBuffer(nil)
+	w := NewWriter(buf)
+
+	c.Assert(w.WriteHeader(plumbing.BlobObject, 4), IsNil)
+	c.Assert(w.Close(), IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	_, err := w.Write([]byte("1234"))
+	c.Assert(err, Equals, ErrClosed)
+}
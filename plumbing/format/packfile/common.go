@@ -2,6 +2,7 @@ package packfile
 
 import (
 	"bytes"
+	"compress/zlib"
 	"io"
 	"sync"
 
@@ -56,3 +57,52 @@ var bufPool = sync.Pool{
 		return bytes.NewBuffer(nil)
 	},
 }
+
+// zlibReaderPool and zlibWriterPool hold the zlib readers and writers of the
+// objects, since their initialization dominates the cost of reading and
+// writing small objects.
+var (
+	zlibReaderPool sync.Pool
+	zlibWriterPool = sync.Pool{
+		New: func() interface{} {
+			return zlib.NewWriter(nil)
+		},
+	}
+)
+
+// getZlibReader returns a zlib reader of r from the pool, it should be put
+// back with putZlibReader once closed.
+func getZlibReader(r io.Reader) (readerResetter, error) {
+	zr, ok := zlibReaderPool.Get().(readerResetter)
+	if !ok {
+		nzr, err := zlib.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return nzr.(readerResetter), nil
+	}
+
+	if err := zr.Reset(r, nil); err != nil {
+		zlibReaderPool.Put(zr)
+		return nil, err
+	}
+
+	return zr, nil
+}
+
+func putZlibReader(zr readerResetter) {
+	zlibReaderPool.Put(zr)
+}
+
+// getZlibWriter returns a zlib writer to w from the pool, it should be put
+// back with putZlibWriter once closed.
+func getZlibWriter(w io.Writer) *zlib.Writer {
+	zw := zlibWriterPool.Get().(*zlib.Writer)
+	zw.Reset(w)
+	return zw
+}
+
+func putZlibWriter(zw *zlib.Writer) {
+	zlibWriterPool.Put(zw)
+}
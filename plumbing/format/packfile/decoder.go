@@ -52,7 +52,8 @@ var (
 // is destroyed. The Offsets and CRCs are calculated whether an
 // ObjectStorer was provided or not.
 type Decoder struct {
-	deltaBaseCache cache.Object
+	deltaBaseCache  cache.Object
+	deltaChainCache cache.Offset
 
 	s  *Scanner
 	o  storer.EncodedObjectStorer
@@ -318,6 +319,10 @@ func (d *Decoder) decodeByHeader(h *ObjectHeader) (plumbing.EncodedObject, error
 		d.idx.Add(obj.Hash(), uint64(h.Offset), crc)
 	}
 
+	if h.Type == plumbing.OFSDeltaObject || h.Type == plumbing.REFDeltaObject {
+		d.chainPut(h.Offset, obj)
+	}
+
 	return obj, nil
 }
 
@@ -340,6 +345,10 @@ func (d *Decoder) DecodeObjectAt(offset int64) (plumbing.EncodedObject, error) {
 		return nil, ErrNonSeekable
 	}
 
+	if obj, ok := d.chainGet(offset); ok {
+		return obj, nil
+	}
+
 	beforeJump, err := d.s.SeekFromStart(offset)
 	if err != nil {
 		return nil, err
@@ -396,10 +405,11 @@ func (d *Decoder) fillOFSDeltaObjectContent(obj plumbing.EncodedObject, offset i
 		return 0, err
 	}
 
-	e, ok := d.idx.LookupOffset(uint64(offset))
-	var base plumbing.EncodedObject
-	if ok {
-		base, ok = d.cacheGet(e.Hash)
+	base, ok := d.chainGet(offset)
+	if !ok {
+		if e, found := d.idx.LookupOffset(uint64(offset)); found {
+			base, ok = d.cacheGet(e.Hash)
+		}
 	}
 
 	if !ok {
@@ -409,6 +419,7 @@ func (d *Decoder) fillOFSDeltaObjectContent(obj plumbing.EncodedObject, offset i
 		}
 
 		d.cachePut(base)
+		d.chainPut(offset, base)
 	}
 
 	obj.SetType(base.Type())
@@ -434,6 +445,22 @@ func (d *Decoder) cachePut(obj plumbing.EncodedObject) {
 	d.deltaBaseCache.Put(obj)
 }
 
+func (d *Decoder) chainGet(offset int64) (plumbing.EncodedObject, bool) {
+	if d.deltaChainCache == nil {
+		return nil, false
+	}
+
+	return d.deltaChainCache.Get(offset)
+}
+
+func (d *Decoder) chainPut(offset int64, obj plumbing.EncodedObject) {
+	if d.deltaChainCache == nil {
+		return
+	}
+
+	d.deltaChainCache.Put(offset, obj)
+}
+
 func (d *Decoder) recallByOffset(o int64) (plumbing.EncodedObject, error) {
 	if d.s.IsSeekable {
 		return d.DecodeObjectAt(o)
@@ -480,6 +507,15 @@ func (d *Decoder) SetIndex(idx *Index) {
 	d.idx = idx
 }
 
+// SetDeltaChainCache sets a cache of the objects resolved along the delta
+// chains, keyed by their offset in the packfile, so decoding the deltas of
+// neighboring objects doesn't inflate their common bases again. The cache is
+// only valid for the packfile of the Decoder, it may be shared by the several
+// Decoders of the same packfile. Nothing is cached by default.
+func (d *Decoder) SetDeltaChainCache(c cache.Offset) {
+	d.deltaChainCache = c
+}
+
 // Index returns the index for the packfile. If index was set with SetIndex,
 // Index will return it. Otherwise, it will return an index that is built while
 // decoding. If neither SetIndex was called with a full index or Decode called
@@ -318,6 +318,34 @@ func (s *ReaderSuite) TestDecodeObjectAt(c *C) {
 	c.Assert(obj.Hash().String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
 }
 
+func (s *ReaderSuite) TestDecodeObjectAtWithDeltaChainCache(c *C) {
+	f := fixtures.Basic().One()
+	chain := cache.NewOffsetLRUDefault()
+
+	decode := func() plumbing.EncodedObject {
+		d, err := packfile.NewDecoder(packfile.NewScanner(f.Packfile()), nil)
+		c.Assert(err, IsNil)
+		d.SetDeltaChainCache(chain)
+
+		if f.Is("ref-delta") {
+			d.SetIndex(getIndexFromIdxFile(f.Idx()))
+		}
+
+		obj, err := d.DecodeObjectAt(186)
+		c.Assert(err, IsNil)
+		c.Assert(obj.Hash().String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+		return obj
+	}
+
+	obj := decode()
+
+	cached, ok := chain.Get(186)
+	c.Assert(ok, Equals, true)
+	c.Assert(cached, Equals, obj)
+
+	c.Assert(decode(), Equals, obj)
+}
+
 func (s *ReaderSuite) TestDecodeObjectAtForType(c *C) {
 	f := fixtures.Basic().One()
 	scanner := packfile.NewScanner(f.Packfile())
@@ -139,8 +139,9 @@ func (dw *deltaSelector) fixAndBreakChains(objectsToPack []*ObjectToPack) error
 		m[otp.Hash()] = otp
 	}
 
+	chain := make(map[plumbing.Hash]bool)
 	for _, otp := range objectsToPack {
-		if err := dw.fixAndBreakChainsOne(m, otp); err != nil {
+		if err := dw.fixAndBreakChainsOne(m, otp, chain); err != nil {
 			return err
 		}
 	}
@@ -148,7 +149,10 @@ func (dw *deltaSelector) fixAndBreakChains(objectsToPack []*ObjectToPack) error
 	return nil
 }
 
-func (dw *deltaSelector) fixAndBreakChainsOne(objectsToPack map[plumbing.Hash]*ObjectToPack, otp *ObjectToPack) error {
+// fixAndBreakChainsOne sets the base of the delta otp, undeltifying it if
+// the base isn't packed or the chain is a cycle, that is if the base is one of
+// the deltas in chain, whose bases are being fixed.
+func (dw *deltaSelector) fixAndBreakChainsOne(objectsToPack map[plumbing.Hash]*ObjectToPack, otp *ObjectToPack, chain map[plumbing.Hash]bool) error {
 	if !otp.Object.Type().IsDelta() {
 		return nil
 	}
@@ -174,7 +178,16 @@ func (dw *deltaSelector) fixAndBreakChainsOne(objectsToPack map[plumbing.Hash]*O
 		return dw.undeltify(otp)
 	}
 
-	if err := dw.fixAndBreakChainsOne(objectsToPack, base); err != nil {
+	// the same objects may be read from different packfiles, deltified
+	// against each other
+	if chain[base.Hash()] {
+		return dw.undeltify(otp)
+	}
+
+	chain[otp.Hash()] = true
+	err := dw.fixAndBreakChainsOne(objectsToPack, base, chain)
+	delete(chain, otp.Hash())
+	if err != nil {
 		return err
 	}
 
@@ -245,8 +258,9 @@ func (dw *deltaSelector) walk(
 			continue
 		}
 
-		// We only want to create deltas from specific types.
-		if !applyDelta[target.Type()] {
+		// We only want to create deltas from specific types, and the big
+		// objects are stored without delta compression.
+		if !applyDelta[target.Type()] || plumbing.IsBigObject(target.Object) {
 			continue
 		}
 
@@ -259,6 +273,10 @@ func (dw *deltaSelector) walk(
 				break
 			}
 
+			if plumbing.IsBigObject(base.Original) {
+				continue
+			}
+
 			if err := dw.tryToDeltify(indexMap, base, target); err != nil {
 				return err
 			}
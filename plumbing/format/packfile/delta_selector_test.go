@@ -234,3 +234,55 @@ func (s *DeltaSelectorSuite) TestMaxDepth(c *C) {
 	dsl := s.ds.deltaSizeLimit(0, 0, int(maxDepth), true)
 	c.Assert(dsl, Equals, int64(0))
 }
+
+// refDelta is a delta object with the given base, without content.
+type refDelta struct {
+	plumbing.EncodedObject
+	base, actual plumbing.Hash
+}
+
+func newRefDelta(base, actual plumbing.Hash) *refDelta {
+	return &refDelta{newObject(plumbing.REFDeltaObject, nil), base, actual}
+}
+
+func (d *refDelta) BaseHash() plumbing.Hash   { return d.base }
+func (d *refDelta) ActualHash() plumbing.Hash { return d.actual }
+func (d *refDelta) ActualSize() int64         { return 0 }
+
+func (s *DeltaSelectorSuite) TestFixAndBreakChainsCycle(c *C) {
+	base, target := s.hashes["base"], s.hashes["target"]
+	otp := []*ObjectToPack{
+		{Object: newRefDelta(target, base)},
+		{Object: newRefDelta(base, target)},
+	}
+
+	c.Assert(s.ds.fixAndBreakChains(otp), IsNil)
+
+	// the chain is broken at the delta whose base was already in it
+	c.Assert(otp[0].IsDelta(), Equals, true)
+	c.Assert(otp[0].Base, Equals, otp[1])
+	c.Assert(otp[1].IsDelta(), Equals, false)
+	c.Assert(otp[1].Object, Equals, s.store.Objects[target])
+}
+
+type bigTestObject struct {
+	plumbing.EncodedObject
+}
+
+func (bigTestObject) IsBig() bool { return true }
+
+func (s *DeltaSelectorSuite) TestObjectsToPackBigObjects(c *C) {
+	hashes := []plumbing.Hash{s.hashes["base"], s.hashes["target"]}
+	for _, big := range []string{"base", "target"} {
+		h := s.hashes[big]
+		s.store.Objects[h] = bigTestObject{s.store.Objects[h]}
+
+		otp, err := s.ds.ObjectsToPack(hashes, 10)
+		c.Assert(err, IsNil)
+		c.Assert(otp, HasLen, 2)
+		c.Assert(otp[0].IsDelta(), Equals, false)
+		c.Assert(otp[1].IsDelta(), Equals, false)
+
+		s.store.Objects[h] = s.store.Objects[h].(bigTestObject).EncodedObject
+	}
+}
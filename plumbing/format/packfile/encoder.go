@@ -31,11 +31,9 @@ func NewEncoder(w io.Writer, s storer.EncodedObjectStorer, useRefDeltas bool) *E
 	}
 	mw := io.MultiWriter(w, h)
 	ow := newOffsetWriter(mw)
-	zw := zlib.NewWriter(mw)
 	return &Encoder{
 		selector:     newDeltaSelector(s),
 		w:            ow,
-		zw:           zw,
 		hasher:       h,
 		useRefDeltas: useRefDeltas,
 	}
@@ -58,6 +56,12 @@ func (e *Encoder) Encode(
 }
 
 func (e *Encoder) encode(objects []*ObjectToPack) (plumbing.Hash, error) {
+	e.zw = getZlibWriter(e.w)
+	defer func() {
+		putZlibWriter(e.zw)
+		e.zw = nil
+	}()
+
 	if err := e.head(len(objects)); err != nil {
 		return plumbing.ZeroHash, err
 	}
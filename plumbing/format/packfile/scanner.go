@@ -40,7 +40,6 @@ type ObjectHeader struct {
 
 type Scanner struct {
 	r   reader
-	zr  readerResetter
 	crc hash.Hash32
 
 	// pendingObject is used to detect if an object has been read, or still
@@ -278,23 +277,15 @@ func (s *Scanner) NextObject(w io.Writer) (written int64, crc32 uint32, err erro
 // ReadRegularObject reads and write a non-deltified object
 // from it zlib stream in an object entry in the packfile.
 func (s *Scanner) copyObject(w io.Writer) (n int64, err error) {
-	if s.zr == nil {
-		var zr io.ReadCloser
-		zr, err = zlib.NewReader(s.r)
-		if err != nil {
-			return 0, fmt.Errorf("zlib initialization error: %s", err)
-		}
-
-		s.zr = zr.(readerResetter)
-	} else {
-		if err = s.zr.Reset(s.r, nil); err != nil {
-			return 0, fmt.Errorf("zlib reset error: %s", err)
-		}
+	zr, err := getZlibReader(s.r)
+	if err != nil {
+		return 0, fmt.Errorf("zlib initialization error: %s", err)
 	}
 
-	defer ioutil.CheckClose(s.zr, &err)
+	defer putZlibReader(zr)
+	defer ioutil.CheckClose(zr, &err)
 	buf := byteSlicePool.Get().([]byte)
-	n, err = io.CopyBuffer(w, s.zr, buf)
+	n, err = io.CopyBuffer(w, zr, buf)
 	byteSlicePool.Put(buf)
 	return
 }
@@ -3,15 +3,20 @@
 package pktline
 
 import (
-	"bytes"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"strconv"
 )
 
-// An Encoder writes pkt-lines to an output stream.
+// An Encoder writes pkt-lines to an output stream. Every pkt-line is built in
+// a buffer reused between lines and written with a single call to the
+// output stream.
 type Encoder struct {
-	w io.Writer
+	w    io.Writer
+	buf  []byte
+	line LineWriter
 }
 
 const (
@@ -29,6 +34,9 @@ var (
 	Flush = []byte{}
 	// FlushString is the payload to use with the EncodeString method to encode a flush-pkt.
 	FlushString = ""
+	// DelimPkt are the contents of a delim-pkt pkt-line, separating the
+	// sections of a protocol v2 message.
+	DelimPkt = []byte{'0', '0', '0', '1'}
 	// ErrPayloadTooLong is returned by the Encode methods when any of the
 	// provided payloads is bigger than MaxPayloadSize.
 	ErrPayloadTooLong = errors.New("payload is too long")
@@ -47,6 +55,12 @@ func (e *Encoder) Flush() error {
 	return err
 }
 
+// Delim encodes a delim-pkt to the output stream.
+func (e *Encoder) Delim() error {
+	_, err := e.w.Write(DelimPkt)
+	return err
+}
+
 // Encode encodes a pkt-line with the payload specified and write it to
 // the output stream.  If several payloads are specified, each of them
 // will get streamed in their own pkt-lines.
@@ -65,30 +79,36 @@ func (e *Encoder) encodeLine(p []byte) error {
 		return ErrPayloadTooLong
 	}
 
-	if bytes.Equal(p, Flush) {
+	e.buf = append(e.buf[:0], 0, 0, 0, 0)
+	e.buf = append(e.buf, p...)
+	return e.writeLine()
+}
+
+// writeLine writes the pkt-line at the buffer, its payload after the room
+// left for its pkt-len, as a flush-pkt if the payload is empty.
+func (e *Encoder) writeLine() error {
+	n := len(e.buf)
+	if n == lenSize {
 		return e.Flush()
 	}
 
-	n := len(p) + 4
-	if _, err := e.w.Write(asciiHex16(n)); err != nil {
-		return err
+	if n-lenSize > MaxPayloadSize {
+		return ErrPayloadTooLong
 	}
-	_, err := e.w.Write(p)
+
+	putASCIIHex16(e.buf, n)
+	_, err := e.w.Write(e.buf)
 	return err
 }
 
-// Returns the hexadecimal ascii representation of the 16 less
-// significant bits of n.  The length of the returned slice will always
-// be 4.  Example: if n is 1234 (0x4d2), the return value will be
-// []byte{'0', '4', 'd', '2'}.
-func asciiHex16(n int) []byte {
-	var ret [4]byte
-	ret[0] = byteToASCIIHex(byte(n & 0xf000 >> 12))
-	ret[1] = byteToASCIIHex(byte(n & 0x0f00 >> 8))
-	ret[2] = byteToASCIIHex(byte(n & 0x00f0 >> 4))
-	ret[3] = byteToASCIIHex(byte(n & 0x000f))
-
-	return ret[:]
+// Writes the hexadecimal ascii representation of the 16 less significant
+// bits of n to the first 4 bytes of b.  Example: if n is 1234 (0x4d2), they
+// are set to '0', '4', 'd', '2'.
+func putASCIIHex16(b []byte, n int) {
+	b[0] = byteToASCIIHex(byte(n & 0xf000 >> 12))
+	b[1] = byteToASCIIHex(byte(n & 0x0f00 >> 8))
+	b[2] = byteToASCIIHex(byte(n & 0x00f0 >> 4))
+	b[3] = byteToASCIIHex(byte(n & 0x000f))
 }
 
 // turns a byte into its hexadecimal ascii representation.  Example:
@@ -104,7 +124,13 @@ func byteToASCIIHex(n byte) byte {
 // EncodeString works similarly as Encode but payloads are specified as strings.
 func (e *Encoder) EncodeString(payloads ...string) error {
 	for _, p := range payloads {
-		if err := e.Encode([]byte(p)); err != nil {
+		if len(p) > MaxPayloadSize {
+			return ErrPayloadTooLong
+		}
+
+		e.buf = append(e.buf[:0], 0, 0, 0, 0)
+		e.buf = append(e.buf, p...)
+		if err := e.writeLine(); err != nil {
 			return err
 		}
 	}
@@ -114,9 +140,75 @@ func (e *Encoder) EncodeString(payloads ...string) error {
 
 // Encodef encodes a single pkt-line with the payload formatted as
 // the format specifier. The rest of the arguments will be used in
-// the format string.
+// the format string. The payload is formatted straight into the buffer of
+// the encoder, use Line to build it without the cost of formatting.
 func (e *Encoder) Encodef(format string, a ...interface{}) error {
-	return e.EncodeString(
-		fmt.Sprintf(format, a...),
-	)
+	l := e.Line()
+	if _, err := fmt.Fprintf(l, format, a...); err != nil {
+		return err
+	}
+
+	return l.End()
+}
+
+// Line starts a new pkt-line, returning the LineWriter building its payload
+// at the buffer of the encoder. The line is written by LineWriter.End.
+func (e *Encoder) Line() *LineWriter {
+	e.buf = append(e.buf[:0], 0, 0, 0, 0)
+	e.line.e = e
+	return &e.line
+}
+
+// A LineWriter builds the payload of a pkt-line in the buffer of an Encoder,
+// so lines made of several parts are encoded without allocations. It's only
+// valid until the line is ended or another line is started.
+type LineWriter struct {
+	e *Encoder
+}
+
+// AppendString appends s to the payload.
+func (l *LineWriter) AppendString(s string) *LineWriter {
+	l.e.buf = append(l.e.buf, s...)
+	return l
+}
+
+// AppendBytes appends p to the payload.
+func (l *LineWriter) AppendBytes(p []byte) *LineWriter {
+	l.e.buf = append(l.e.buf, p...)
+	return l
+}
+
+// AppendByte appends c to the payload.
+func (l *LineWriter) AppendByte(c byte) *LineWriter {
+	l.e.buf = append(l.e.buf, c)
+	return l
+}
+
+// AppendHex appends the hexadecimal representation of p to the payload, as
+// the one of an object hash.
+func (l *LineWriter) AppendHex(p []byte) *LineWriter {
+	n := len(l.e.buf)
+	l.e.buf = append(l.e.buf, make([]byte, hex.EncodedLen(len(p)))...)
+	hex.Encode(l.e.buf[n:], p)
+	return l
+}
+
+// AppendInt appends the decimal representation of n to the payload.
+func (l *LineWriter) AppendInt(n int64) *LineWriter {
+	l.e.buf = strconv.AppendInt(l.e.buf, n, 10)
+	return l
+}
+
+// Write appends p to the payload, so the LineWriter can be used as an
+// io.Writer. It never fails.
+func (l *LineWriter) Write(p []byte) (int, error) {
+	l.e.buf = append(l.e.buf, p...)
+	return len(p), nil
+}
+
+// End writes the pkt-line to the output stream of the encoder, as a
+// flush-pkt if its payload is empty. ErrPayloadTooLong is returned if it is
+// bigger than MaxPayloadSize.
+func (l *LineWriter) End() error {
+	return l.e.writeLine()
 }
@@ -2,6 +2,7 @@ package pktline_test
 
 import (
 	"bytes"
+	"io/ioutil"
 	"os"
 	"strings"
 	"testing"
@@ -28,6 +29,17 @@ func (s *SuiteEncoder) TestFlush(c *C) {
 	c.Assert(obtained, DeepEquals, pktline.FlushPkt)
 }
 
+func (s *SuiteEncoder) TestDelim(c *C) {
+	var buf bytes.Buffer
+	e := pktline.NewEncoder(&buf)
+
+	err := e.Delim()
+	c.Assert(err, IsNil)
+
+	obtained := buf.Bytes()
+	c.Assert(obtained, DeepEquals, []byte("0001"))
+}
+
 func (s *SuiteEncoder) TestEncode(c *C) {
 	for i, test := range [...]struct {
 		input    [][]byte
@@ -212,6 +224,63 @@ func (s *SuiteEncoder) TestEncodef(c *C) {
 	c.Assert(buf.Bytes(), DeepEquals, expected)
 }
 
+func (s *SuiteEncoder) TestLine(c *C) {
+	var buf bytes.Buffer
+	e := pktline.NewEncoder(&buf)
+
+	err := e.Line().AppendString("want ").AppendHex([]byte{0xab, 0x01}).AppendByte('\n').End()
+	c.Assert(err, IsNil)
+	err = e.Line().AppendBytes([]byte("deepen ")).AppendInt(42).End()
+	c.Assert(err, IsNil)
+	c.Assert(e.Line().End(), IsNil)
+
+	c.Assert(buf.String(), Equals, "000ewant ab01\n000ddeepen 420000")
+}
+
+func (s *SuiteEncoder) TestLineTooLong(c *C) {
+	var buf bytes.Buffer
+	e := pktline.NewEncoder(&buf)
+
+	err := e.Line().AppendString(strings.Repeat("a", pktline.MaxPayloadSize+1)).End()
+	c.Assert(err, Equals, pktline.ErrPayloadTooLong)
+	c.Assert(buf.Len(), Equals, 0)
+}
+
+func (s *SuiteEncoder) TestLineAllocs(c *C) {
+	e := pktline.NewEncoder(ioutil.Discard)
+	hash := make([]byte, 20)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = e.Line().AppendString("have ").AppendHex(hash).AppendByte('\n').End()
+		_ = e.EncodeString("done\n")
+	})
+	c.Assert(allocs, Equals, 0.0)
+}
+
+func BenchmarkEncodef(b *testing.B) {
+	e := pktline.NewEncoder(ioutil.Discard)
+	hash := "6ecf0ef2c2dffb796033e5a02219af86ec6584e5"
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := e.Encodef("have %s\n", hash); err != nil {
+			b.Fatalf("unexpected error: %s", err)
+		}
+	}
+}
+
+func BenchmarkLine(b *testing.B) {
+	e := pktline.NewEncoder(ioutil.Discard)
+	hash := make([]byte, 20)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := e.Line().AppendString("have ").AppendHex(hash).AppendByte('\n').End(); err != nil {
+			b.Fatalf("unexpected error: %s", err)
+		}
+	}
+}
+
 func ExampleEncoder() {
 	// Create an encoder that writes pktlines to stdout.
 	e := pktline.NewEncoder(os.Stdout)
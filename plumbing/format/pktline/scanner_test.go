@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"testing"
 
 	"gopkg.in/src-d/go-git.v4/plumbing/format/pktline"
 
@@ -218,6 +219,31 @@ func sectionsExample(c *C, nSections, nLines int) io.Reader {
 	return &buf
 }
 
+func BenchmarkScanner(b *testing.B) {
+	var buf bytes.Buffer
+	e := pktline.NewEncoder(&buf)
+	for i := 0; i < 1000; i++ {
+		if err := e.EncodeString("have 6ecf0ef2c2dffb796033e5a02219af86ec6584e5\n"); err != nil {
+			b.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	input := buf.Bytes()
+	r := bytes.NewReader(input)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.Reset(input)
+		sc := pktline.NewScanner(r)
+		for sc.Scan() {
+		}
+
+		if err := sc.Err(); err != nil {
+			b.Fatalf("unexpected error: %s", err)
+		}
+	}
+}
+
 func ExampleScanner() {
 	// A reader is needed as input.
 	input := strings.NewReader("000ahello\n" +
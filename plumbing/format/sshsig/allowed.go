@@ -0,0 +1,265 @@
+package sshsig
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	ErrSignerNotAllowed = errors.New("sshsig: signer not allowed")
+)
+
+// AllowedSigner is an entry of an allowed signers file, see the
+// ALLOWED SIGNERS section of ssh-keygen(1).
+type AllowedSigner struct {
+	// Principals are the patterns of the principals allowed to sign with
+	// the key, a pattern prefixed by `!` negates the match.
+	Principals []string
+	// CertAuthority is true when Key is a certificate authority, trusted to
+	// sign the certificates of the allowed principals.
+	CertAuthority bool
+	// Namespaces are the patterns of the namespaces the key is accepted
+	// for, all namespaces are accepted when empty.
+	Namespaces []string
+	// ValidAfter and ValidBefore define the time window the key is accepted
+	// for, a zero value means no limit.
+	ValidAfter  time.Time
+	ValidBefore time.Time
+	// Key is the public key of the signer or the certificate authority.
+	Key ssh.PublicKey
+}
+
+// AllowedSigners is the content of an allowed signers file, as configured at
+// gpg.ssh.allowedSignersFile.
+type AllowedSigners []*AllowedSigner
+
+// ParseAllowedSigners parses an allowed signers file, blank lines and lines
+// starting with `#` are ignored.
+func ParseAllowedSigners(r io.Reader) (AllowedSigners, error) {
+	var signers AllowedSigners
+
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		signer, err := parseAllowedSigner(line)
+		if err != nil {
+			return nil, fmt.Errorf("sshsig: allowed signers line %d: %s", n, err)
+		}
+
+		signers = append(signers, signer)
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return signers, nil
+}
+
+func parseAllowedSigner(line string) (*AllowedSigner, error) {
+	principals, rest := splitField(line)
+	if principals == "" || rest == "" {
+		return nil, errors.New("missing public key")
+	}
+
+	key, _, options, _, err := ssh.ParseAuthorizedKey([]byte(rest))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &AllowedSigner{
+		Principals: strings.Split(unquote(principals), ","),
+		Key:        key,
+	}
+
+	for _, opt := range options {
+		name, value := opt, ""
+		if i := strings.IndexByte(opt, '='); i >= 0 {
+			name, value = opt[:i], unquote(opt[i+1:])
+		}
+
+		switch strings.ToLower(name) {
+		case "cert-authority":
+			s.CertAuthority = true
+		case "namespaces":
+			s.Namespaces = strings.Split(value, ",")
+		case "valid-after":
+			s.ValidAfter, err = parseTime(value)
+		case "valid-before":
+			s.ValidBefore, err = parseTime(value)
+		default:
+			err = fmt.Errorf("unknown option %q", name)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// splitField splits the first field of line, honoring double quotes.
+func splitField(line string) (field, rest string) {
+	var quoted bool
+	for i, c := range line {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case !quoted && (c == ' ' || c == '\t'):
+			return line[:i], strings.TrimSpace(line[i:])
+		}
+	}
+
+	return line, ""
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+
+	return s
+}
+
+// parseTime parses a time in the format YYYYMMDD[HHMM[SS]][Z], in local time
+// unless the Z suffix is present.
+func parseTime(value string) (time.Time, error) {
+	loc := time.Local
+	if strings.HasSuffix(value, "Z") || strings.HasSuffix(value, "z") {
+		loc = time.UTC
+		value = value[:len(value)-1]
+	}
+
+	var layout string
+	switch len(value) {
+	case 8:
+		layout = "20060102"
+	case 12:
+		layout = "200601021504"
+	case 14:
+		layout = "20060102150405"
+	default:
+		return time.Time{}, fmt.Errorf("invalid time %q", value)
+	}
+
+	return time.ParseInLocation(layout, value, loc)
+}
+
+// Find returns the entry allowing key to sign in the given namespace at
+// the given time, key can be a certificate issued by a certificate authority
+// entry. ErrSignerNotAllowed is returned if no entry allows the key.
+func (s AllowedSigners) Find(key ssh.PublicKey, namespace string, when time.Time) (*AllowedSigner, error) {
+	for _, signer := range s {
+		if signer.Allows(key, namespace, when) {
+			return signer, nil
+		}
+	}
+
+	return nil, ErrSignerNotAllowed
+}
+
+// Allows returns true if the entry allows key to sign in the given namespace
+// at the given time.
+func (s *AllowedSigner) Allows(key ssh.PublicKey, namespace string, when time.Time) bool {
+	if len(s.Namespaces) != 0 && !matchList(namespace, s.Namespaces) {
+		return false
+	}
+
+	if !s.ValidAfter.IsZero() && when.Before(s.ValidAfter) {
+		return false
+	}
+
+	if !s.ValidBefore.IsZero() && !when.Before(s.ValidBefore) {
+		return false
+	}
+
+	if !s.CertAuthority {
+		return bytes.Equal(key.Marshal(), s.Key.Marshal())
+	}
+
+	cert, ok := key.(*ssh.Certificate)
+	if !ok || cert.CertType != ssh.UserCert {
+		return false
+	}
+
+	if !bytes.Equal(cert.SignatureKey.Marshal(), s.Key.Marshal()) {
+		return false
+	}
+
+	checker := &ssh.CertChecker{Clock: func() time.Time { return when }}
+	for _, p := range cert.ValidPrincipals {
+		if s.HasPrincipal(p) && checker.CheckCert(p, cert) == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasPrincipal returns true if the principal matches the principals of the
+// entry.
+func (s *AllowedSigner) HasPrincipal(principal string) bool {
+	return matchList(principal, s.Principals)
+}
+
+// matchList matches s against a list of patterns, as OpenSSH does: a match
+// of a negated pattern always fails.
+func matchList(s string, patterns []string) bool {
+	var found bool
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if strings.HasPrefix(p, "!") {
+			if match(s, p[1:]) {
+				return false
+			}
+
+			continue
+		}
+
+		if match(s, p) {
+			found = true
+		}
+	}
+
+	return found
+}
+
+// match is a wildcard match supporting `*` and `?`.
+func match(s, pattern string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if match(s[i:], pattern[1:]) {
+					return true
+				}
+			}
+
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		}
+
+		s, pattern = s[1:], pattern[1:]
+	}
+
+	return len(s) == 0
+}
@@ -0,0 +1,104 @@
+package sshsig
+
+import (
+	"crypto/rand"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	. "gopkg.in/check.v1"
+)
+
+type AllowedSignersSuite struct {
+	key ssh.PublicKey
+}
+
+var _ = Suite(&AllowedSignersSuite{})
+
+func (s *AllowedSignersSuite) SetUpSuite(c *C) {
+	var err error
+	s.key, _, _, _, err = ssh.ParseAuthorizedKey([]byte(fixturePublicKey))
+	c.Assert(err, IsNil)
+}
+
+func (s *AllowedSignersSuite) TestParse(c *C) {
+	signers, err := ParseAllowedSigners(strings.NewReader(`
+# comment
+"foo@example.com,bar@example.com" ` + fixturePublicKey + ` comment
+*@example.com namespaces="git,file",valid-after="20200101",valid-before="20300101120000Z" ` + fixturePublicKey + `
+`))
+	c.Assert(err, IsNil)
+	c.Assert(signers, HasLen, 2)
+
+	c.Assert(signers[0].Principals, DeepEquals, []string{"foo@example.com", "bar@example.com"})
+	c.Assert(signers[0].Namespaces, HasLen, 0)
+	c.Assert(signers[0].Key.Marshal(), DeepEquals, s.key.Marshal())
+
+	c.Assert(signers[1].Principals, DeepEquals, []string{"*@example.com"})
+	c.Assert(signers[1].Namespaces, DeepEquals, []string{"git", "file"})
+	c.Assert(signers[1].ValidAfter.Equal(time.Date(2020, 1, 1, 0, 0, 0, 0, time.Local)), Equals, true)
+	c.Assert(signers[1].ValidBefore.Equal(time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)), Equals, true)
+}
+
+func (s *AllowedSignersSuite) TestParseInvalid(c *C) {
+	_, err := ParseAllowedSigners(strings.NewReader("foo@example.com\n"))
+	c.Assert(err, ErrorMatches, ".*line 1: missing public key")
+
+	_, err = ParseAllowedSigners(strings.NewReader("foo@example.com foo " + fixturePublicKey))
+	c.Assert(err, ErrorMatches, `.*line 1: unknown option "foo"`)
+}
+
+func (s *AllowedSignersSuite) TestFind(c *C) {
+	signers, err := ParseAllowedSigners(strings.NewReader(
+		`*@example.com,!bar@example.com namespaces="file",valid-before="20300101" ` + fixturePublicKey + "\n" +
+			`foo@example.com valid-after="20200101" ` + fixturePublicKey + "\n",
+	))
+	c.Assert(err, IsNil)
+
+	when := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	signer, err := signers.Find(s.key, "file", when)
+	c.Assert(err, IsNil)
+	c.Assert(signer, Equals, signers[0])
+	c.Assert(signer.HasPrincipal("foo@example.com"), Equals, true)
+	c.Assert(signer.HasPrincipal("bar@example.com"), Equals, false)
+
+	signer, err = signers.Find(s.key, Namespace, when)
+	c.Assert(err, IsNil)
+	c.Assert(signer, Equals, signers[1])
+
+	_, err = signers.Find(s.key, Namespace, time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+	c.Assert(err, Equals, ErrSignerNotAllowed)
+
+	_, err = AllowedSigners{}.Find(s.key, Namespace, when)
+	c.Assert(err, Equals, ErrSignerNotAllowed)
+}
+
+func (s *AllowedSignersSuite) TestFindCertificate(c *C) {
+	ca, err := ssh.ParsePrivateKey([]byte(fixturePrivateKey))
+	c.Assert(err, IsNil)
+
+	signers, err := ParseAllowedSigners(strings.NewReader(
+		"*@example.com cert-authority " + fixturePublicKey + "\n",
+	))
+	c.Assert(err, IsNil)
+	c.Assert(signers[0].CertAuthority, Equals, true)
+
+	cert := &ssh.Certificate{
+		Key:             s.key,
+		CertType:        ssh.UserCert,
+		ValidPrincipals: []string{"foo@example.com"},
+		ValidAfter:      uint64(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Unix()),
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	c.Assert(cert.SignCert(rand.Reader, ca), IsNil)
+
+	_, err = signers.Find(s.key, Namespace, time.Now())
+	c.Assert(err, Equals, ErrSignerNotAllowed)
+
+	signer, err := signers.Find(cert, Namespace, time.Now())
+	c.Assert(err, IsNil)
+	c.Assert(signer, Equals, signers[0])
+
+	_, err = signers.Find(cert, Namespace, time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+	c.Assert(err, Equals, ErrSignerNotAllowed)
+}
@@ -0,0 +1,8 @@
+// Package sshsig implements the SSH signature format, as created by
+// `ssh-keygen -Y sign` and used by git to sign commits and tags when
+// gpg.format is set to ssh, along with the allowed signers file format used
+// by git to verify them.
+//
+// The signature format is described at:
+// https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig
+package sshsig
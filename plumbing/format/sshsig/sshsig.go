@@ -0,0 +1,220 @@
+package sshsig
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// Namespace is the namespace used by git for commit and tag signatures.
+	Namespace = "git"
+
+	// HashSHA256 and HashSHA512 are the supported hash algorithms.
+	HashSHA256 = "sha256"
+	HashSHA512 = "sha512"
+
+	beginMarker = "-----BEGIN SSH SIGNATURE-----"
+	endMarker   = "-----END SSH SIGNATURE-----"
+	lineLength  = 70
+	magic       = "SSHSIG"
+	version     = 1
+)
+
+var (
+	ErrInvalidSignature    = errors.New("sshsig: invalid signature")
+	ErrUnsupportedVersion  = errors.New("sshsig: unsupported signature version")
+	ErrUnsupportedHash     = errors.New("sshsig: unsupported hash algorithm")
+	ErrUnsupportedKey      = errors.New("sshsig: unsupported signature algorithm")
+	ErrNamespaceMismatch   = errors.New("sshsig: namespace mismatch")
+	ErrMissingNamespace    = errors.New("sshsig: namespace is required")
+	ErrMissingArmorMarkers = errors.New("sshsig: missing armor markers")
+)
+
+// Signature is a SSH signature of a message.
+type Signature struct {
+	// PublicKey is the key of the signer, it can be a certificate.
+	PublicKey ssh.PublicKey
+	// Namespace is the domain of the signature, preventing a signature from
+	// being used with a different purpose, git uses Namespace.
+	Namespace string
+	// HashAlgorithm is the algorithm used to hash the message.
+	HashAlgorithm string
+	// Signature is the signature of the message hash.
+	Signature *ssh.Signature
+}
+
+type wireSignature struct {
+	Magic         [6]byte
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+type signedData struct {
+	Magic         [6]byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          []byte
+}
+
+// Sign returns the signature of message in the given namespace, created by
+// signer. RSA keys are signed with the rsa-sha2-512 algorithm, as ssh-keygen
+// does.
+func Sign(signer ssh.Signer, message io.Reader, namespace string) (*Signature, error) {
+	if namespace == "" {
+		return nil, ErrMissingNamespace
+	}
+
+	sig := &Signature{
+		PublicKey:     signer.PublicKey(),
+		Namespace:     namespace,
+		HashAlgorithm: HashSHA512,
+	}
+
+	data, err := sig.signedData(message)
+	if err != nil {
+		return nil, err
+	}
+
+	as, ok := signer.(ssh.AlgorithmSigner)
+	if ok && sig.PublicKey.Type() == ssh.KeyAlgoRSA {
+		sig.Signature, err = as.SignWithAlgorithm(rand.Reader, data, ssh.SigAlgoRSASHA2512)
+	} else {
+		sig.Signature, err = signer.Sign(rand.Reader, data)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sig, nil
+}
+
+// Verify verifies the signature of message in the given namespace. The key is
+// not checked to be trusted, AllowedSigners can be used for it.
+func (s *Signature) Verify(message io.Reader, namespace string) error {
+	if s.Namespace != namespace {
+		return ErrNamespaceMismatch
+	}
+
+	if s.Signature == nil || s.Signature.Format == ssh.SigAlgoRSA {
+		return ErrUnsupportedKey
+	}
+
+	data, err := s.signedData(message)
+	if err != nil {
+		return err
+	}
+
+	return s.PublicKey.Verify(data, s.Signature)
+}
+
+func (s *Signature) signedData(message io.Reader) ([]byte, error) {
+	var h hash.Hash
+	switch s.HashAlgorithm {
+	case HashSHA256:
+		h = sha256.New()
+	case HashSHA512:
+		h = sha512.New()
+	default:
+		return nil, ErrUnsupportedHash
+	}
+
+	if _, err := io.Copy(h, message); err != nil {
+		return nil, err
+	}
+
+	d := signedData{
+		Namespace:     s.Namespace,
+		HashAlgorithm: s.HashAlgorithm,
+		Hash:          h.Sum(nil),
+	}
+
+	copy(d.Magic[:], magic)
+	return ssh.Marshal(d), nil
+}
+
+// Marshal returns the armored representation of the signature.
+func (s *Signature) Marshal() []byte {
+	w := wireSignature{
+		Version:       version,
+		PublicKey:     s.PublicKey.Marshal(),
+		Namespace:     s.Namespace,
+		HashAlgorithm: s.HashAlgorithm,
+		Signature:     ssh.Marshal(s.Signature),
+	}
+
+	copy(w.Magic[:], magic)
+	encoded := base64.StdEncoding.EncodeToString(ssh.Marshal(w))
+
+	buf := bytes.NewBuffer(nil)
+	buf.WriteString(beginMarker + "\n")
+	for len(encoded) > lineLength {
+		buf.WriteString(encoded[:lineLength] + "\n")
+		encoded = encoded[lineLength:]
+	}
+
+	buf.WriteString(encoded + "\n")
+	buf.WriteString(endMarker + "\n")
+
+	return buf.Bytes()
+}
+
+// Unmarshal parses an armored signature.
+func Unmarshal(armored []byte) (*Signature, error) {
+	content := strings.TrimSpace(string(armored))
+	if !strings.HasPrefix(content, beginMarker) || !strings.HasSuffix(content, endMarker) {
+		return nil, ErrMissingArmorMarkers
+	}
+
+	content = content[len(beginMarker) : len(content)-len(endMarker)]
+	content = strings.Join(strings.Fields(content), "")
+
+	raw, err := base64.StdEncoding.DecodeString(content)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	var w wireSignature
+	if err := ssh.Unmarshal(raw, &w); err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	if string(w.Magic[:]) != magic {
+		return nil, ErrInvalidSignature
+	}
+
+	if w.Version != version {
+		return nil, ErrUnsupportedVersion
+	}
+
+	key, err := ssh.ParsePublicKey(w.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := &ssh.Signature{}
+	if err := ssh.Unmarshal(w.Signature, sig); err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	return &Signature{
+		PublicKey:     key,
+		Namespace:     w.Namespace,
+		HashAlgorithm: w.HashAlgorithm,
+		Signature:     sig,
+	}, nil
+}
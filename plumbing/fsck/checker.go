@@ -0,0 +1,131 @@
+package fsck
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/revlist"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+// Checker checks that the objects reachable from a set of tips are present
+// in a storage and valid, as git does with the objects received when
+// transfer.fsckObjects is set.
+type Checker struct {
+	// Shallow are the shallow commits of the storage, whose parents are not
+	// required to be present.
+	Shallow []plumbing.Hash
+	// Severities, if any, override the default severity of the problems.
+	Severities Severities
+	// Warn, if not nil, is called with the problems found of a severity
+	// lower than error, which don't make the objects invalid.
+	Warn func(p *Problem)
+
+	s storer.EncodedObjectStorer
+}
+
+// NewChecker returns a new Checker of the objects of the given storage.
+func NewChecker(s storer.EncodedObjectStorer) *Checker {
+	return &Checker{s: s}
+}
+
+// CheckObject returns the first problem of the object of error severity, or
+// higher, after applying the Severities, nil if it is valid.
+func (c *Checker) CheckObject(o plumbing.EncodedObject) error {
+	problems, err := Check(o)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range problems {
+		p.Severity = c.Severities.Of(p.ID)
+		if p.Severity >= SeverityError {
+			return p
+		}
+
+		if p.Severity != SeverityIgnore && c.Warn != nil {
+			c.Warn(p)
+		}
+	}
+
+	return nil
+}
+
+// Check checks the objects reachable from the tips, but not through the
+// known ones, which are assumed to be connected, e.g. the tips of the
+// references before a fetch. It returns a MissingObjectError if an object
+// is missing, or the Problem making an object invalid.
+func (c *Checker) Check(tips, known []plumbing.Hash) error {
+	visited := revlist.HashSet{}
+	for _, h := range known {
+		visited.Add(h)
+	}
+
+	if err := c.addShallowParents(visited); err != nil {
+		return err
+	}
+
+	var invalid error
+	w := revlist.NewObjectWalker(&checkedStorer{c.s})
+	w.Visited = visited
+	w.Filters = []revlist.ObjectFilter{func(o plumbing.EncodedObject) bool {
+		if invalid == nil {
+			invalid = c.CheckObject(o)
+		}
+
+		return invalid == nil
+	}}
+
+	err := w.Walk(func(plumbing.Hash, plumbing.ObjectType) error {
+		if invalid != nil {
+			return storer.ErrStop
+		}
+
+		return nil
+	}, tips...)
+	if err != nil {
+		return err
+	}
+
+	return invalid
+}
+
+// addShallowParents marks as visited the parents of the shallow commits,
+// which are missing from the storage.
+func (c *Checker) addShallowParents(visited revlist.HashSet) error {
+	for _, h := range c.Shallow {
+		o, err := c.s.EncodedObject(plumbing.CommitObject, h)
+		if err == plumbing.ErrObjectNotFound {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		commit, err := object.DecodeCommit(c.s, o)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range commit.ParentHashes {
+			visited.Add(p)
+		}
+	}
+
+	return nil
+}
+
+// checkedStorer returns a MissingObjectError for the missing objects, to
+// know which one is missing when the walk fails.
+type checkedStorer struct {
+	storer.EncodedObjectStorer
+}
+
+func (s *checkedStorer) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	o, err := s.EncodedObjectStorer.EncodedObject(t, h)
+	if err == plumbing.ErrObjectNotFound {
+		return nil, &MissingObjectError{Hash: h}
+	}
+
+	return o, err
+}
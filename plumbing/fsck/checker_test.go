@@ -0,0 +1,145 @@
+package fsck_test
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/fsck"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+type CheckerSuite struct {
+	Storer *memory.Storage
+	Tree   plumbing.Hash
+	Commit plumbing.Hash
+}
+
+var _ = Suite(&CheckerSuite{})
+
+var missing = plumbing.NewHash("0000000000000000000000000000000000000001")
+
+func (s *CheckerSuite) SetUpTest(c *C) {
+	s.Storer = memory.NewStorage()
+
+	blob := s.store(c, plumbing.BlobObject, "foo")
+	s.Tree = s.store(c, plumbing.TreeObject, treeEntry("100644", "foo", blob))
+	s.Commit = s.store(c, plumbing.CommitObject, commitContent(s.Tree))
+	s.Commit = s.store(c, plumbing.CommitObject, commitContent(s.Tree, s.Commit))
+}
+
+func (s *CheckerSuite) store(c *C, t plumbing.ObjectType, content string) plumbing.Hash {
+	h, err := s.Storer.SetEncodedObject(newObject(t, content))
+	c.Assert(err, IsNil)
+	return h
+}
+
+func commitContent(tree plumbing.Hash, parents ...plumbing.Hash) string {
+	content := "tree " + tree.String() + "\n"
+	for _, p := range parents {
+		content += "parent " + p.String() + "\n"
+	}
+
+	return content + "author " + ident + "\ncommitter " + ident + "\n\nfoo\n"
+}
+
+func (s *CheckerSuite) TestCheck(c *C) {
+	tag := s.store(c, plumbing.TagObject, "object "+s.Commit.String()+
+		"\ntype commit\ntag v1.0\ntagger "+ident+"\n\nfoo\n")
+
+	c.Assert(fsck.NewChecker(s.Storer).Check([]plumbing.Hash{tag, s.Commit}, nil), IsNil)
+}
+
+func (s *CheckerSuite) TestCheckMissing(c *C) {
+	commit := s.store(c, plumbing.CommitObject, commitContent(s.Tree, missing))
+	err := fsck.NewChecker(s.Storer).Check([]plumbing.Hash{commit}, nil)
+	c.Assert(err, DeepEquals, &fsck.MissingObjectError{Hash: missing})
+
+	tree := s.store(c, plumbing.TreeObject, treeEntry("100644", "bar", missing))
+	commit = s.store(c, plumbing.CommitObject, commitContent(tree, s.Commit))
+	err = fsck.NewChecker(s.Storer).Check([]plumbing.Hash{commit}, nil)
+	c.Assert(err, DeepEquals, &fsck.MissingObjectError{Hash: missing})
+	c.Assert(err.Error(), Equals, "missing object "+missing.String())
+}
+
+func (s *CheckerSuite) TestCheckKnown(c *C) {
+	tree := s.store(c, plumbing.TreeObject, treeEntry("100644", "bar", missing))
+	known := s.store(c, plumbing.CommitObject, commitContent(tree))
+	commit := s.store(c, plumbing.CommitObject, commitContent(s.Tree, known))
+
+	checker := fsck.NewChecker(s.Storer)
+	c.Assert(checker.Check([]plumbing.Hash{commit}, []plumbing.Hash{known}), IsNil)
+	c.Assert(checker.Check([]plumbing.Hash{commit}, nil), NotNil)
+}
+
+func (s *CheckerSuite) TestCheckShallow(c *C) {
+	shallow := s.store(c, plumbing.CommitObject, commitContent(s.Tree, missing))
+	commit := s.store(c, plumbing.CommitObject, commitContent(s.Tree, shallow))
+
+	checker := fsck.NewChecker(s.Storer)
+	checker.Shallow = []plumbing.Hash{shallow}
+	c.Assert(checker.Check([]plumbing.Hash{commit}, nil), IsNil)
+}
+
+func (s *CheckerSuite) TestCheckInvalid(c *C) {
+	invalid := s.store(c, plumbing.CommitObject, "tree "+s.Tree.String()+"\n\nfoo\n")
+	commit := s.store(c, plumbing.CommitObject, commitContent(s.Tree, invalid))
+
+	err := fsck.NewChecker(s.Storer).Check([]plumbing.Hash{commit}, nil)
+	p, ok := err.(*fsck.Problem)
+	c.Assert(ok, Equals, true)
+	c.Assert(p.Hash, Equals, invalid)
+	c.Assert(p.ID, Equals, fsck.MissingAuthor)
+	c.Assert(p.Severity, Equals, fsck.SeverityError)
+}
+
+func (s *CheckerSuite) TestCheckWarn(c *C) {
+	blob := s.store(c, plumbing.BlobObject, "bar")
+	tree := s.store(c, plumbing.TreeObject, treeEntry("0100644", "bar", blob))
+	commit := s.store(c, plumbing.CommitObject, commitContent(tree, s.Commit))
+
+	var warnings []*fsck.Problem
+	checker := fsck.NewChecker(s.Storer)
+	checker.Warn = func(p *fsck.Problem) {
+		warnings = append(warnings, p)
+	}
+
+	c.Assert(checker.Check([]plumbing.Hash{commit}, nil), IsNil)
+	c.Assert(warnings, HasLen, 1)
+	c.Assert(warnings[0].Hash, Equals, tree)
+	c.Assert(warnings[0].ID, Equals, fsck.ZeroPaddedFilemode)
+}
+
+func (s *CheckerSuite) TestCheckSeverities(c *C) {
+	invalid := s.store(c, plumbing.CommitObject, "tree "+s.Tree.String()+
+		"\nauthor John Doe 1257894000 +0100\ncommitter "+ident+"\n\nfoo\n")
+	blob := s.store(c, plumbing.BlobObject, "bar")
+	tree := s.store(c, plumbing.TreeObject, treeEntry("0100644", "bar", blob))
+	commit := s.store(c, plumbing.CommitObject, commitContent(tree, invalid))
+
+	var warnings []*fsck.Problem
+	checker := fsck.NewChecker(s.Storer)
+	checker.Warn = func(p *fsck.Problem) {
+		warnings = append(warnings, p)
+	}
+
+	err := checker.Check([]plumbing.Hash{commit}, nil)
+	p, ok := err.(*fsck.Problem)
+	c.Assert(ok, Equals, true)
+	c.Assert(p.ID, Equals, fsck.MissingEmail)
+
+	checker.Severities = fsck.Severities{fsck.MissingEmail: fsck.SeverityWarn}
+	warnings = nil
+	c.Assert(checker.Check([]plumbing.Hash{commit}, nil), IsNil)
+	c.Assert(warnings, HasLen, 2)
+
+	checker.Severities = fsck.Severities{
+		fsck.MissingEmail:       fsck.SeverityIgnore,
+		fsck.ZeroPaddedFilemode: fsck.SeverityError,
+	}
+
+	err = checker.Check([]plumbing.Hash{commit}, nil)
+	p, ok = err.(*fsck.Problem)
+	c.Assert(ok, Equals, true)
+	c.Assert(p.ID, Equals, fsck.ZeroPaddedFilemode)
+	c.Assert(p.Severity, Equals, fsck.SeverityError)
+}
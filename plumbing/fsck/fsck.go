@@ -0,0 +1,249 @@
+// Package fsck checks the connectivity and the validity of the objects, as
+// git fsck does, and is used to check the objects received by a fetch or a
+// push before updating the references.
+package fsck
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+var (
+	// ErrUnknownMessageID is returned by ParseMessageID when the name is not
+	// of a known problem.
+	ErrUnknownMessageID = errors.New("unknown fsck message id")
+	// ErrInvalidSeverity is returned by ParseSeverity when the value is not
+	// error, warn or ignore.
+	ErrInvalidSeverity = errors.New("invalid fsck message type")
+	// ErrDemoteFatal is returned when lowering the severity of a fatal
+	// problem, which always makes the object invalid.
+	ErrDemoteFatal = errors.New("cannot demote fatal fsck message")
+)
+
+// MessageID identifies a kind of problem found in an object, with the name
+// git uses for it, e.g. in the fsck.<msg-id> config.
+type MessageID string
+
+const (
+	NulInHeader             MessageID = "nulInHeader"
+	UnterminatedHeader      MessageID = "unterminatedHeader"
+	BadDate                 MessageID = "badDate"
+	BadDateOverflow         MessageID = "badDateOverflow"
+	BadEmail                MessageID = "badEmail"
+	BadName                 MessageID = "badName"
+	BadObjectSha1           MessageID = "badObjectSha1"
+	BadParentSha1           MessageID = "badParentSha1"
+	BadTimezone             MessageID = "badTimezone"
+	BadTree                 MessageID = "badTree"
+	BadTreeSha1             MessageID = "badTreeSha1"
+	BadType                 MessageID = "badType"
+	DuplicateEntries        MessageID = "duplicateEntries"
+	MissingAuthor           MessageID = "missingAuthor"
+	MissingCommitter        MessageID = "missingCommitter"
+	MissingEmail            MessageID = "missingEmail"
+	MissingNameBeforeEmail  MessageID = "missingNameBeforeEmail"
+	MissingObject           MessageID = "missingObject"
+	MissingSpaceBeforeDate  MessageID = "missingSpaceBeforeDate"
+	MissingSpaceBeforeEmail MessageID = "missingSpaceBeforeEmail"
+	MissingTagEntry         MessageID = "missingTagEntry"
+	MissingTree             MessageID = "missingTree"
+	MissingTypeEntry        MessageID = "missingTypeEntry"
+	MultipleAuthors         MessageID = "multipleAuthors"
+	TreeNotSorted           MessageID = "treeNotSorted"
+	ZeroPaddedDate          MessageID = "zeroPaddedDate"
+	BadFilemode             MessageID = "badFilemode"
+	EmptyName               MessageID = "emptyName"
+	FullPathname            MessageID = "fullPathname"
+	HasDot                  MessageID = "hasDot"
+	HasDotdot               MessageID = "hasDotdot"
+	HasDotgit               MessageID = "hasDotgit"
+	NullSha1                MessageID = "nullSha1"
+	ZeroPaddedFilemode      MessageID = "zeroPaddedFilemode"
+	BadTagName              MessageID = "badTagName"
+	MissingTaggerEntry      MessageID = "missingTaggerEntry"
+)
+
+// Severity is how a problem is handled, the problems of error severity, or
+// higher, make the checked objects invalid.
+type Severity int
+
+const (
+	// SeverityIgnore problems are not reported.
+	SeverityIgnore Severity = iota
+	// SeverityInfo problems are reported, as the warnings.
+	SeverityInfo
+	// SeverityWarn problems are reported, without making the object invalid.
+	SeverityWarn
+	// SeverityError problems make the object invalid.
+	SeverityError
+	// SeverityFatal problems make the object invalid, of any configured
+	// severity.
+	SeverityFatal
+)
+
+var severityNames = map[Severity]string{
+	SeverityIgnore: "ignore",
+	SeverityInfo:   "info",
+	SeverityWarn:   "warn",
+	SeverityError:  "error",
+	SeverityFatal:  "fatal",
+}
+
+func (s Severity) String() string {
+	return severityNames[s]
+}
+
+var defaultSeverities = map[MessageID]Severity{
+	NulInHeader:             SeverityFatal,
+	UnterminatedHeader:      SeverityFatal,
+	BadDate:                 SeverityError,
+	BadDateOverflow:         SeverityError,
+	BadEmail:                SeverityError,
+	BadName:                 SeverityError,
+	BadObjectSha1:           SeverityError,
+	BadParentSha1:           SeverityError,
+	BadTimezone:             SeverityError,
+	BadTree:                 SeverityError,
+	BadTreeSha1:             SeverityError,
+	BadType:                 SeverityError,
+	DuplicateEntries:        SeverityError,
+	MissingAuthor:           SeverityError,
+	MissingCommitter:        SeverityError,
+	MissingEmail:            SeverityError,
+	MissingNameBeforeEmail:  SeverityError,
+	MissingObject:           SeverityError,
+	MissingSpaceBeforeDate:  SeverityError,
+	MissingSpaceBeforeEmail: SeverityError,
+	MissingTagEntry:         SeverityError,
+	MissingTree:             SeverityError,
+	MissingTypeEntry:        SeverityError,
+	MultipleAuthors:         SeverityError,
+	TreeNotSorted:           SeverityError,
+	ZeroPaddedDate:          SeverityError,
+	BadFilemode:             SeverityWarn,
+	EmptyName:               SeverityWarn,
+	FullPathname:            SeverityWarn,
+	HasDot:                  SeverityWarn,
+	HasDotdot:               SeverityWarn,
+	HasDotgit:               SeverityWarn,
+	NullSha1:                SeverityWarn,
+	ZeroPaddedFilemode:      SeverityWarn,
+	BadTagName:              SeverityInfo,
+	MissingTaggerEntry:      SeverityInfo,
+}
+
+// DefaultSeverity returns the severity of the problem, as git sets it.
+func (id MessageID) DefaultSeverity() Severity {
+	return defaultSeverities[id]
+}
+
+// ParseMessageID returns the MessageID of the given name, compared ignoring
+// the case as the config keys are.
+func ParseMessageID(name string) (MessageID, error) {
+	for id := range defaultSeverities {
+		if strings.EqualFold(string(id), name) {
+			return id, nil
+		}
+	}
+
+	return "", ErrUnknownMessageID
+}
+
+// ParseSeverity parses the severity of a fsck.<msg-id> config, one of
+// error, warn or ignore.
+func ParseSeverity(value string) (Severity, error) {
+	switch strings.ToLower(value) {
+	case "error":
+		return SeverityError, nil
+	case "warn":
+		return SeverityWarn, nil
+	case "ignore":
+		return SeverityIgnore, nil
+	default:
+		return SeverityIgnore, ErrInvalidSeverity
+	}
+}
+
+// Severities overrides the default severity of the problems, e.g. to accept
+// the objects of an old history with a known problem.
+type Severities map[MessageID]Severity
+
+// skipListKey is the fsck.skipList config, the file of the objects not
+// checked, which isn't the severity of a problem.
+const skipListKey = "skiplist"
+
+// ParseSeverities parses the severities of the given fsck.<msg-id> configs,
+// keyed by the name of the message, as returned by
+// config.Config.FetchFsckOptions. The skipList key is ignored.
+func ParseSeverities(opts map[string]string) (Severities, error) {
+	s := make(Severities)
+	for key, value := range opts {
+		if strings.EqualFold(key, skipListKey) {
+			continue
+		}
+
+		id, err := ParseMessageID(key)
+		if err != nil {
+			return nil, err
+		}
+
+		sev, err := ParseSeverity(value)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.Set(id, sev); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// Set sets the severity of the problem, fatal problems can't be demoted.
+func (s Severities) Set(id MessageID, sev Severity) error {
+	if id.DefaultSeverity() == SeverityFatal && sev < SeverityError {
+		return ErrDemoteFatal
+	}
+
+	s[id] = sev
+	return nil
+}
+
+// Of returns the severity of the problem, the default one if not set.
+func (s Severities) Of(id MessageID) Severity {
+	if sev, ok := s[id]; ok {
+		return sev
+	}
+
+	return id.DefaultSeverity()
+}
+
+// Problem is a problem found in an object.
+type Problem struct {
+	// Hash is the hash of the object.
+	Hash plumbing.Hash
+	// ID is the kind of the problem.
+	ID MessageID
+	// Severity is the severity the problem was reported with.
+	Severity Severity
+	// Message describes the problem.
+	Message string
+}
+
+func (p *Problem) Error() string {
+	return fmt.Sprintf("object %s: %s: %s", p.Hash, p.ID, p.Message)
+}
+
+// MissingObjectError is returned by Checker.Check when an object reachable
+// from the checked tips is not in the storage.
+type MissingObjectError struct {
+	Hash plumbing.Hash
+}
+
+func (e *MissingObjectError) Error() string {
+	return fmt.Sprintf("missing object %s", e.Hash)
+}
@@ -0,0 +1,378 @@
+package fsck
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+)
+
+// hashSize is the size of the binary hashes of the tree entries.
+const hashSize = len(plumbing.ZeroHash)
+
+// Check returns the problems found in the object, with their default
+// severity. The blobs have no problems.
+func Check(o plumbing.EncodedObject) ([]*Problem, error) {
+	if o.Type() == plumbing.BlobObject {
+		return nil, nil
+	}
+
+	r, err := o.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+
+	if err := r.Close(); err != nil {
+		return nil, err
+	}
+
+	c := &objectChecker{hash: o.Hash()}
+	switch o.Type() {
+	case plumbing.CommitObject:
+		c.checkCommit(data)
+	case plumbing.TreeObject:
+		c.checkTree(data)
+	case plumbing.TagObject:
+		c.checkTag(data)
+	}
+
+	return c.problems, nil
+}
+
+type objectChecker struct {
+	hash     plumbing.Hash
+	problems []*Problem
+}
+
+func (c *objectChecker) report(id MessageID, format string, args ...interface{}) {
+	c.problems = append(c.problems, &Problem{
+		Hash:     c.hash,
+		ID:       id,
+		Severity: id.DefaultSeverity(),
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// checkHeader reports the headers of a commit or tag not terminated by an
+// empty line, or the end of the object, and the ones with NUL bytes.
+func (c *objectChecker) checkHeader(data []byte) bool {
+	for i, b := range data {
+		switch {
+		case b == 0:
+			c.report(NulInHeader, "unterminated header: NUL at offset %d", i)
+			return false
+		case b != '\n':
+			continue
+		case i+1 == len(data), data[i+1] == '\n':
+			return true
+		}
+	}
+
+	c.report(UnterminatedHeader, "unterminated header")
+	return false
+}
+
+func (c *objectChecker) checkCommit(data []byte) {
+	if !c.checkHeader(data) {
+		return
+	}
+
+	b := data
+	if !bytes.HasPrefix(b, []byte("tree ")) {
+		c.report(MissingTree, "invalid format - expected 'tree' line")
+		return
+	}
+
+	var ok bool
+	if b, ok = hashLine(b[len("tree "):]); !ok {
+		c.report(BadTreeSha1, "invalid 'tree' line format - bad sha1")
+	}
+
+	for bytes.HasPrefix(b, []byte("parent ")) {
+		if b, ok = hashLine(b[len("parent "):]); !ok {
+			c.report(BadParentSha1, "invalid 'parent' line format - bad sha1")
+		}
+	}
+
+	authors := 0
+	for bytes.HasPrefix(b, []byte("author ")) {
+		var line []byte
+		line, b = nextLine(b[len("author "):])
+		c.checkIdent(line)
+		authors++
+	}
+
+	switch authors {
+	case 0:
+		c.report(MissingAuthor, "invalid format - expected 'author' line")
+		return
+	case 1:
+	default:
+		c.report(MultipleAuthors, "invalid format - multiple 'author' lines")
+	}
+
+	if !bytes.HasPrefix(b, []byte("committer ")) {
+		c.report(MissingCommitter, "invalid format - expected 'committer' line")
+		return
+	}
+
+	line, _ := nextLine(b[len("committer "):])
+	c.checkIdent(line)
+}
+
+func (c *objectChecker) checkTag(data []byte) {
+	if !c.checkHeader(data) {
+		return
+	}
+
+	b := data
+	if !bytes.HasPrefix(b, []byte("object ")) {
+		c.report(MissingObject, "invalid format - expected 'object' line")
+		return
+	}
+
+	var ok bool
+	if b, ok = hashLine(b[len("object "):]); !ok {
+		c.report(BadObjectSha1, "invalid 'object' line format - bad sha1")
+	}
+
+	if !bytes.HasPrefix(b, []byte("type ")) {
+		c.report(MissingTypeEntry, "invalid format - expected 'type' line")
+		return
+	}
+
+	var line []byte
+	line, b = nextLine(b[len("type "):])
+	if t, err := plumbing.ParseObjectType(string(line)); err != nil || t.IsDelta() {
+		c.report(BadType, "invalid 'type' value")
+	}
+
+	if !bytes.HasPrefix(b, []byte("tag ")) {
+		c.report(MissingTagEntry, "invalid format - expected 'tag' line")
+		return
+	}
+
+	line, b = nextLine(b[len("tag "):])
+	if !isValidTagName(string(line)) {
+		c.report(BadTagName, "invalid 'tag' name: %s", line)
+	}
+
+	if !bytes.HasPrefix(b, []byte("tagger ")) {
+		c.report(MissingTaggerEntry, "invalid format - expected 'tagger' line")
+		return
+	}
+
+	line, _ = nextLine(b[len("tagger "):])
+	c.checkIdent(line)
+}
+
+// checkIdent checks an author, committer or tagger line, as
+// "Name <email> 1234567890 +0000", reporting the first problem found.
+func (c *objectChecker) checkIdent(line []byte) {
+	if len(line) > 0 && line[0] == '<' {
+		c.report(MissingNameBeforeEmail, "invalid author/committer line - missing name before email")
+		return
+	}
+
+	i := bytes.IndexAny(line, "<>")
+	switch {
+	case i < 0:
+		c.report(MissingEmail, "invalid author/committer line - missing email")
+		return
+	case line[i] == '>':
+		c.report(BadName, "invalid author/committer line - bad name")
+		return
+	case line[i-1] != ' ':
+		c.report(MissingSpaceBeforeEmail, "invalid author/committer line - missing space before email")
+		return
+	}
+
+	p := line[i+1:]
+	if i = bytes.IndexAny(p, "<>"); i < 0 || p[i] != '>' {
+		c.report(BadEmail, "invalid author/committer line - bad email")
+		return
+	}
+
+	p = p[i+1:]
+	if len(p) == 0 || p[0] != ' ' {
+		c.report(MissingSpaceBeforeDate, "invalid author/committer line - missing space before date")
+		return
+	}
+
+	p = p[1:]
+	if len(p) > 1 && p[0] == '0' && p[1] != ' ' {
+		c.report(ZeroPaddedDate, "invalid author/committer line - zero-padded date")
+		return
+	}
+
+	n := 0
+	for n < len(p) && isDigit(p[n]) {
+		n++
+	}
+
+	if n == 0 || n == len(p) || p[n] != ' ' {
+		c.report(BadDate, "invalid author/committer line - bad date")
+		return
+	}
+
+	if _, err := strconv.ParseUint(string(p[:n]), 10, 64); err != nil {
+		c.report(BadDateOverflow, "invalid author/committer line - date causes integer overflow")
+		return
+	}
+
+	tz := p[n+1:]
+	if len(tz) != 5 || (tz[0] != '+' && tz[0] != '-') ||
+		!isDigit(tz[1]) || !isDigit(tz[2]) || !isDigit(tz[3]) || !isDigit(tz[4]) {
+		c.report(BadTimezone, "invalid author/committer line - bad time zone")
+	}
+}
+
+// treeChecks are the problems reported once per tree, no matter how many
+// of its entries have them.
+var treeChecks = []struct {
+	id      MessageID
+	message string
+}{
+	{NullSha1, "contains entries pointing to null sha1"},
+	{FullPathname, "contains full pathnames"},
+	{EmptyName, "contains empty pathname"},
+	{HasDot, "contains '.'"},
+	{HasDotdot, "contains '..'"},
+	{HasDotgit, "contains '.git'"},
+	{ZeroPaddedFilemode, "contains zero-padded file modes"},
+	{BadFilemode, "contains bad file modes"},
+	{DuplicateEntries, "contains duplicate file entries"},
+	{TreeNotSorted, "not properly sorted"},
+}
+
+func (c *objectChecker) checkTree(data []byte) {
+	found := make(map[MessageID]bool)
+
+	var prevName string
+	var prevMode filemode.FileMode
+	for first := true; len(data) > 0; first = false {
+		sp := bytes.IndexByte(data, ' ')
+		nul := bytes.IndexByte(data, 0)
+		if sp <= 0 || nul < sp || len(data) < nul+1+hashSize {
+			c.report(BadTree, "cannot be parsed as a tree")
+			return
+		}
+
+		modeStr := string(data[:sp])
+		m, err := strconv.ParseUint(modeStr, 8, 32)
+		if err != nil {
+			c.report(BadTree, "cannot be parsed as a tree")
+			return
+		}
+
+		mode := filemode.FileMode(m)
+		name := string(data[sp+1 : nul])
+
+		var h plumbing.Hash
+		copy(h[:], data[nul+1:nul+1+hashSize])
+		data = data[nul+1+hashSize:]
+
+		found[NullSha1] = found[NullSha1] || h.IsZero()
+		found[FullPathname] = found[FullPathname] || strings.Contains(name, "/")
+		found[EmptyName] = found[EmptyName] || name == ""
+		found[HasDot] = found[HasDot] || name == "."
+		found[HasDotdot] = found[HasDotdot] || name == ".."
+		found[HasDotgit] = found[HasDotgit] || strings.EqualFold(name, ".git")
+		found[ZeroPaddedFilemode] = found[ZeroPaddedFilemode] || modeStr[0] == '0'
+
+		switch mode {
+		case filemode.Regular, filemode.Executable, filemode.Deprecated,
+			filemode.Symlink, filemode.Dir, filemode.Submodule:
+		default:
+			found[BadFilemode] = true
+		}
+
+		if !first {
+			switch {
+			case name == prevName:
+				found[DuplicateEntries] = true
+			case treeEntryName(name, mode) < treeEntryName(prevName, prevMode):
+				found[TreeNotSorted] = true
+			}
+		}
+
+		prevName, prevMode = name, mode
+	}
+
+	for _, t := range treeChecks {
+		if found[t.id] {
+			c.report(t.id, "%s", t.message)
+		}
+	}
+}
+
+// treeEntryName returns the name the tree entries are sorted by, with a
+// trailing slash for the directories.
+func treeEntryName(name string, mode filemode.FileMode) string {
+	if mode == filemode.Dir {
+		return name + "/"
+	}
+
+	return name
+}
+
+// hashLine returns the rest of b after a line with just a hash, and if it
+// was found.
+func hashLine(b []byte) ([]byte, bool) {
+	line, rest := nextLine(b)
+	if len(line) != hashSize*2 {
+		return rest, false
+	}
+
+	_, err := hex.DecodeString(string(line))
+	return rest, err == nil
+}
+
+// nextLine splits b after its first line, returning the line without the
+// line feed.
+func nextLine(b []byte) (line, rest []byte) {
+	i := bytes.IndexByte(b, '\n')
+	if i < 0 {
+		return b, nil
+	}
+
+	return b[:i], b[i+1:]
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+// isValidTagName returns true if refs/tags/<name> is a valid reference
+// name, see git check-ref-format.
+func isValidTagName(name string) bool {
+	if name == "" || strings.HasSuffix(name, "/") || strings.HasSuffix(name, ".") ||
+		strings.Contains(name, "..") || strings.Contains(name, "@{") {
+		return false
+	}
+
+	for _, c := range name {
+		if c < ' ' || c == 0x7f || strings.ContainsRune(" ~^:?*[\\", c) {
+			return false
+		}
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || strings.HasPrefix(part, ".") || strings.HasSuffix(part, ".lock") {
+			return false
+		}
+	}
+
+	return true
+}
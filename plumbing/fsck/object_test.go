@@ -0,0 +1,198 @@
+package fsck_test
+
+import (
+	"testing"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/fsck"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type FsckSuite struct{}
+
+var _ = Suite(&FsckSuite{})
+
+const (
+	treeHash = "a8d315b2b1c615d43042c3a62402b8a54288cf5c"
+	ident    = "John Doe <john@example.com> 1257894000 +0100"
+)
+
+func newObject(t plumbing.ObjectType, content string) plumbing.EncodedObject {
+	o := &plumbing.MemoryObject{}
+	o.SetType(t)
+	_, _ = o.Write([]byte(content))
+	return o
+}
+
+func treeEntry(mode, name string, h plumbing.Hash) string {
+	return mode + " " + name + "\x00" + string(h[:])
+}
+
+func problemIDs(c *C, o plumbing.EncodedObject) []fsck.MessageID {
+	problems, err := fsck.Check(o)
+	c.Assert(err, IsNil)
+
+	var ids []fsck.MessageID
+	for _, p := range problems {
+		c.Assert(p.Hash, Equals, o.Hash())
+		c.Assert(p.Severity, Equals, p.ID.DefaultSeverity())
+		ids = append(ids, p.ID)
+	}
+
+	return ids
+}
+
+func (s *FsckSuite) TestCheckCommit(c *C) {
+	for content, ids := range map[string][]fsck.MessageID{
+		"tree " + treeHash + "\nauthor " + ident + "\ncommitter " + ident + "\n\nfoo\n":                nil,
+		"tree " + treeHash + "\nauthor " + ident + "\ncommitter " + ident + "\n":                       nil,
+		"parent " + treeHash + "\n\nfoo\n":                                                             {fsck.MissingTree},
+		"tree foo\nauthor " + ident + "\ncommitter " + ident + "\n":                                    {fsck.BadTreeSha1},
+		"tree " + treeHash + "\nparent foo\nauthor " + ident + "\ncommitter " + ident + "\n":           {fsck.BadParentSha1},
+		"tree " + treeHash + "\ncommitter " + ident + "\n":                                             {fsck.MissingAuthor},
+		"tree " + treeHash + "\nauthor " + ident + "\nauthor " + ident + "\ncommitter " + ident + "\n": {fsck.MultipleAuthors},
+		"tree " + treeHash + "\nauthor " + ident + "\n\nfoo\n":                                         {fsck.MissingCommitter},
+		"tree " + treeHash + "\nauthor " + ident + "\ncommitter " + ident:                              {fsck.UnterminatedHeader},
+		"tree " + treeHash + "\nauthor " + ident + "\x00\ncommitter " + ident + "\n":                   {fsck.NulInHeader},
+	} {
+		c.Assert(problemIDs(c, newObject(plumbing.CommitObject, content)), DeepEquals, ids,
+			Commentf("commit: %q", content))
+	}
+}
+
+func (s *FsckSuite) TestCheckIdent(c *C) {
+	for line, id := range map[string]fsck.MessageID{
+		"<john@example.com> 1257894000 +0100":                    fsck.MissingNameBeforeEmail,
+		"John Doe 1257894000 +0100":                              fsck.MissingEmail,
+		"John> Doe <john@example.com> 1257894000 +0100":          fsck.BadName,
+		"John Doe<john@example.com> 1257894000 +0100":            fsck.MissingSpaceBeforeEmail,
+		"John Doe <john<example.com> 1257894000 +0100":           fsck.BadEmail,
+		"John Doe <john@example.com>1257894000 +0100":            fsck.MissingSpaceBeforeDate,
+		"John Doe <john@example.com> 01257894000 +0100":          fsck.ZeroPaddedDate,
+		"John Doe <john@example.com> foo +0100":                  fsck.BadDate,
+		"John Doe <john@example.com> 1257894000":                 fsck.BadDate,
+		"John Doe <john@example.com> 99999999999999999999 +0100": fsck.BadDateOverflow,
+		"John Doe <john@example.com> 1257894000 0100":            fsck.BadTimezone,
+		"John Doe <john@example.com> 1257894000 +01":             fsck.BadTimezone,
+	} {
+		content := "tree " + treeHash + "\nauthor " + ident + "\ncommitter " + line + "\n"
+		c.Assert(problemIDs(c, newObject(plumbing.CommitObject, content)), DeepEquals,
+			[]fsck.MessageID{id}, Commentf("ident: %q", line))
+	}
+
+	content := "tree " + treeHash + "\nauthor " + ident + "\ncommitter John Doe <john@example.com> 0 +0000\n"
+	c.Assert(problemIDs(c, newObject(plumbing.CommitObject, content)), HasLen, 0)
+}
+
+func (s *FsckSuite) TestCheckTree(c *C) {
+	h := plumbing.NewHash(treeHash)
+	for content, ids := range map[string][]fsck.MessageID{
+		"": nil,
+		treeEntry("100644", "a", h) + treeEntry("40000", "a.b", h) + treeEntry("40000", "a0", h) +
+			treeEntry("120000", "b", h) + treeEntry("160000", "c", h) + treeEntry("100755", "d", h): nil,
+		treeEntry("40000", "a", h) + treeEntry("100644", "a.b", h): {fsck.TreeNotSorted},
+		treeEntry("100644", "b", h) + treeEntry("100644", "a", h):  {fsck.TreeNotSorted},
+		treeEntry("100644", "a", h) + treeEntry("40000", "a", h):   {fsck.DuplicateEntries},
+		treeEntry("100644", "a", plumbing.ZeroHash):                {fsck.NullSha1},
+		treeEntry("100644", "a/b", h):                              {fsck.FullPathname},
+		treeEntry("100644", "", h):                                 {fsck.EmptyName},
+		treeEntry("40000", ".", h):                                 {fsck.HasDot},
+		treeEntry("40000", "..", h):                                {fsck.HasDotdot},
+		treeEntry("40000", ".GIT", h):                              {fsck.HasDotgit},
+		treeEntry("040000", "a", h):                                {fsck.ZeroPaddedFilemode},
+		treeEntry("100600", "a", h) + treeEntry("100600", "b", h):  {fsck.BadFilemode},
+		treeEntry("100644", "a", h)[:10]:                           {fsck.BadTree},
+		treeEntry("foo", "a", h):                                   {fsck.BadTree},
+	} {
+		c.Assert(problemIDs(c, newObject(plumbing.TreeObject, content)), DeepEquals, ids,
+			Commentf("tree: %q", content))
+	}
+}
+
+func (s *FsckSuite) TestCheckTag(c *C) {
+	for content, ids := range map[string][]fsck.MessageID{
+		"object " + treeHash + "\ntype tree\ntag v1.0\ntagger " + ident + "\n\nfoo\n": nil,
+		"type tree\ntag v1.0\ntagger " + ident + "\n":                                 {fsck.MissingObject},
+		"object foo\ntype tree\ntag v1.0\ntagger " + ident + "\n":                     {fsck.BadObjectSha1},
+		"object " + treeHash + "\ntag v1.0\ntagger " + ident + "\n":                   {fsck.MissingTypeEntry},
+		"object " + treeHash + "\ntype foo\ntag v1.0\ntagger " + ident + "\n":         {fsck.BadType},
+		"object " + treeHash + "\ntype tree\ntagger " + ident + "\n":                  {fsck.MissingTagEntry},
+		"object " + treeHash + "\ntype tree\ntag v1..0\ntagger " + ident + "\n":       {fsck.BadTagName},
+		"object " + treeHash + "\ntype tree\ntag v1.0\n\nfoo\n":                       {fsck.MissingTaggerEntry},
+		"object " + treeHash + "\ntype tree\ntag v1.0\ntagger John\n":                 {fsck.MissingEmail},
+	} {
+		c.Assert(problemIDs(c, newObject(plumbing.TagObject, content)), DeepEquals, ids,
+			Commentf("tag: %q", content))
+	}
+}
+
+func (s *FsckSuite) TestCheckBlob(c *C) {
+	c.Assert(problemIDs(c, newObject(plumbing.BlobObject, "tree foo\x00")), HasLen, 0)
+}
+
+func (s *FsckSuite) TestSeverities(c *C) {
+	id, err := fsck.ParseMessageID("missingemail")
+	c.Assert(err, IsNil)
+	c.Assert(id, Equals, fsck.MissingEmail)
+
+	_, err = fsck.ParseMessageID("foo")
+	c.Assert(err, Equals, fsck.ErrUnknownMessageID)
+
+	sev, err := fsck.ParseSeverity("Ignore")
+	c.Assert(err, IsNil)
+	c.Assert(sev, Equals, fsck.SeverityIgnore)
+
+	_, err = fsck.ParseSeverity("fatal")
+	c.Assert(err, Equals, fsck.ErrInvalidSeverity)
+
+	severities := fsck.Severities{}
+	c.Assert(severities.Set(fsck.MissingEmail, fsck.SeverityIgnore), IsNil)
+	c.Assert(severities.Set(fsck.ZeroPaddedFilemode, fsck.SeverityError), IsNil)
+	c.Assert(severities.Set(fsck.NulInHeader, fsck.SeverityWarn), Equals, fsck.ErrDemoteFatal)
+	c.Assert(severities.Set(fsck.NulInHeader, fsck.SeverityError), IsNil)
+
+	c.Assert(severities.Of(fsck.MissingEmail), Equals, fsck.SeverityIgnore)
+	c.Assert(severities.Of(fsck.ZeroPaddedFilemode), Equals, fsck.SeverityError)
+	c.Assert(severities.Of(fsck.BadDate), Equals, fsck.SeverityError)
+	c.Assert(fsck.Severities(nil).Of(fsck.HasDot), Equals, fsck.SeverityWarn)
+}
+
+func (s *FsckSuite) TestParseSeverities(c *C) {
+	severities, err := fsck.ParseSeverities(map[string]string{
+		"missingemail":       "warn",
+		"zeroPaddedFilemode": "error",
+		"skipList":           ".git/fsck-skip",
+	})
+	c.Assert(err, IsNil)
+	c.Assert(severities, DeepEquals, fsck.Severities{
+		fsck.MissingEmail:       fsck.SeverityWarn,
+		fsck.ZeroPaddedFilemode: fsck.SeverityError,
+	})
+
+	for _, t := range []struct {
+		key, value string
+		err        error
+	}{
+		{"foo", "ignore", fsck.ErrUnknownMessageID},
+		{"badDate", "foo", fsck.ErrInvalidSeverity},
+		{"nulInHeader", "ignore", fsck.ErrDemoteFatal},
+	} {
+		_, err := fsck.ParseSeverities(map[string]string{t.key: t.value})
+		c.Assert(err, Equals, t.err, Commentf("%s = %s", t.key, t.value))
+	}
+}
+
+func (s *FsckSuite) TestProblemError(c *C) {
+	p := &fsck.Problem{
+		Hash:     plumbing.NewHash(treeHash),
+		ID:       fsck.MissingEmail,
+		Severity: fsck.SeverityError,
+		Message:  "invalid author/committer line - missing email",
+	}
+
+	c.Assert(p.Error(), Equals, "object "+treeHash+": missingEmail: invalid author/committer line - missing email")
+	c.Assert(p.Severity.String(), Equals, "error")
+}
@@ -34,6 +34,23 @@ type DeltaObject interface {
 	ActualSize() int64
 }
 
+// BigObject is an EncodedObject over core.bigFileThreshold, whose content is
+// streamed from the storage on every read instead of being held in memory.
+// They are stored without delta compression and reported as binary by the
+// diffs.
+type BigObject interface {
+	EncodedObject
+	// IsBig returns true if the object is over core.bigFileThreshold.
+	IsBig() bool
+}
+
+// IsBigObject returns true if the given object is a BigObject over
+// core.bigFileThreshold.
+func IsBigObject(o EncodedObject) bool {
+	b, ok := o.(BigObject)
+	return ok && b.IsBig()
+}
+
 // ObjectType internal object type
 // Integer values from 0 to 7 map to those exposed by git.
 // AnyObject is used to represent any from 0 to 7.
@@ -11,13 +11,16 @@ import (
 	"golang.org/x/crypto/openpgp"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/sshsig"
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 	"gopkg.in/src-d/go-git.v4/utils/ioutil"
 )
 
 const (
-	beginpgp string = "-----BEGIN PGP SIGNATURE-----"
-	endpgp   string = "-----END PGP SIGNATURE-----"
+	beginpgp  string = "-----BEGIN PGP SIGNATURE-----"
+	beginx509 string = "-----BEGIN SIGNED MESSAGE-----"
+	beginssh  string = "-----BEGIN SSH SIGNATURE-----"
+	headerpgp string = "gpgsig"
 )
 
 // Hash represents the hash of an object
@@ -174,23 +177,13 @@ func (c *Commit) Decode(o plumbing.EncodedObject) (err error) {
 		}
 
 		if pgpsig {
-			// Check if it's the end of a PGP signature.
-			if bytes.Contains(line, []byte(endpgp)) {
-				c.PGPSignature += endpgp + "\n"
-				pgpsig = false
-			} else {
-				// Trim the left padding.
-				line = bytes.TrimLeft(line, " ")
-				c.PGPSignature += string(line)
+			// The signature continues while the lines are left padded.
+			if len(line) > 0 && line[0] == ' ' {
+				c.PGPSignature += string(line[1:])
+				continue
 			}
-			continue
-		}
 
-		// Check if it's the beginning of a PGP signature.
-		if bytes.Contains(line, []byte(beginpgp)) {
-			c.PGPSignature += beginpgp + "\n"
-			pgpsig = true
-			continue
+			pgpsig = false
 		}
 
 		if !message {
@@ -210,6 +203,9 @@ func (c *Commit) Decode(o plumbing.EncodedObject) (err error) {
 				c.Author.Decode(split[1])
 			case "committer":
 				c.Committer.Decode(split[1])
+			case headerpgp:
+				c.PGPSignature += string(split[1]) + "\n"
+				pgpsig = true
 			}
 		} else {
 			c.Message += string(line)
@@ -226,6 +222,12 @@ func (b *Commit) Encode(o plumbing.EncodedObject) error {
 	return b.encode(o, true)
 }
 
+// EncodeWithoutSignature export a Commit into a plumbing.EncodedObject without
+// the signature, this is the payload signed by a commit signature.
+func (b *Commit) EncodeWithoutSignature(o plumbing.EncodedObject) error {
+	return b.encode(o, false)
+}
+
 func (b *Commit) encode(o plumbing.EncodedObject, includeSig bool) (err error) {
 	o.SetType(plumbing.CommitObject)
 	w, err := o.Writer()
@@ -262,17 +264,16 @@ func (b *Commit) encode(o plumbing.EncodedObject, includeSig bool) (err error) {
 	}
 
 	if b.PGPSignature != "" && includeSig {
-		if _, err = fmt.Fprint(w, "pgpsig"); err != nil {
+		if _, err = fmt.Fprint(w, "\n"+headerpgp+" "); err != nil {
 			return err
 		}
 
-		// Split all the signature lines and write with a left padding and
-		// newline at the end.
-		lines := strings.Split(b.PGPSignature, "\n")
-		for _, line := range lines {
-			if _, err = fmt.Fprintf(w, " %s\n", line); err != nil {
-				return err
-			}
+		// Split all the signature lines and write them with a left padding,
+		// the newline after the last line is written along with the message.
+		signature := strings.TrimSuffix(b.PGPSignature, "\n")
+		lines := strings.Split(signature, "\n")
+		if _, err = fmt.Fprint(w, strings.Join(lines, "\n ")); err != nil {
+			return err
 		}
 	}
 
@@ -342,6 +343,36 @@ func (c *Commit) Verify(armoredKeyRing string) (*openpgp.Entity, error) {
 	return openpgp.CheckArmoredDetachedSignature(keyring, er, signature)
 }
 
+// VerifySSH performs the verification of the SSH signature of the commit with
+// the provided allowed signers, the signing key must be allowed at the time
+// of the commit. The allowed signers entry of the key is returned on success.
+func (c *Commit) VerifySSH(allowed sshsig.AllowedSigners) (*sshsig.AllowedSigner, error) {
+	encoded := &plumbing.MemoryObject{}
+	if err := c.encode(encoded, false); err != nil {
+		return nil, err
+	}
+
+	r, err := encoded.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	_, entry, err := verifySSH(r, c.PGPSignature, allowed, c.Committer.When)
+	return entry, err
+}
+
+// VerifySignature verifies the signature of the commit with the given Verifier,
+// which defines the trusted keys, ErrUnsignedObject is returned if the commit is
+// not signed.
+func (c *Commit) VerifySignature(v Verifier) (*VerificationResult, error) {
+	encoded := &plumbing.MemoryObject{}
+	if err := c.encode(encoded, false); err != nil {
+		return nil, err
+	}
+
+	return verifySignature(v, encoded, c.PGPSignature, c.Committer.When)
+}
+
 func indent(t string) string {
 	var output []string
 	for _, line := range strings.Split(t, "\n") {
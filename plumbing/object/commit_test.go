@@ -3,6 +3,7 @@ package object
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
 	"strings"
 	"time"
 
@@ -272,6 +273,36 @@ RUysgqjcpT8+iQM1PblGfHR4XAhuOqN5Fx06PSaFZhqvWFezJ28/CLyX5q+oIVk=
 	c.Assert(decoded.PGPSignature, Equals, pgpsignature)
 }
 
+func (s *SuiteCommit) TestPGPSignatureHeader(c *C) {
+	ts := time.Unix(1494201823, 0).UTC()
+	commit := &Commit{
+		Author:       Signature{Name: "foo", Email: "foo@foo.foo", When: ts},
+		Committer:    Signature{Name: "foo", Email: "foo@foo.foo", When: ts},
+		TreeHash:     plumbing.NewHash("b8e471f58bcbca63b07bda20e428190409c2db47"),
+		Message:      "foo\n",
+		PGPSignature: "-----BEGIN PGP SIGNATURE-----\n\nfoo\n-----END PGP SIGNATURE-----\n",
+	}
+
+	encoded := &plumbing.MemoryObject{}
+	c.Assert(commit.Encode(encoded), IsNil)
+
+	r, err := encoded.Reader()
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "tree b8e471f58bcbca63b07bda20e428190409c2db47\n"+
+		"author foo <foo@foo.foo> 1494201823 +0000\n"+
+		"committer foo <foo@foo.foo> 1494201823 +0000\n"+
+		"gpgsig -----BEGIN PGP SIGNATURE-----\n \n foo\n -----END PGP SIGNATURE-----\n"+
+		"\nfoo\n",
+	)
+
+	decoded := &Commit{}
+	c.Assert(decoded.Decode(encoded), IsNil)
+	c.Assert(decoded.PGPSignature, Equals, commit.PGPSignature)
+	c.Assert(decoded.Message, Equals, commit.Message)
+}
+
 func (s *SuiteCommit) TestStat(c *C) {
 	aCommit := s.commit(c, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
 	fileStats, err := aCommit.Stats()
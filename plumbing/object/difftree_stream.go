@@ -0,0 +1,266 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+// RawChange is a change of a blob or a submodule between two trees, yielded
+// by DiffTreeStream. The mode and the hash of the missing side of an
+// addition or a deletion are zero.
+type RawChange struct {
+	FromMode filemode.FileMode
+	ToMode   filemode.FileMode
+	From     plumbing.Hash
+	To       plumbing.Hash
+	// Status is 'A' for an addition, 'D' for a deletion, 'M' for a
+	// modification and 'T' for a change of the type of the file.
+	Status byte
+	Path   string
+}
+
+// DiffTreeStream compares two trees, as `git diff-tree -r` does, calling fn
+// for every change in the order of the paths, without building the list of
+// changes. Any of the trees can be nil, meaning an empty tree. The subtrees
+// with the same hash on both sides are not read. If ErrStop is returned by
+// fn the comparison is stopped but no error is returned.
+func DiffTreeStream(a, b *Tree, fn func(RawChange) error) error {
+	d := &treeStreamDiffer{fn: fn}
+
+	var from, to []TreeEntry
+	if a != nil {
+		d.s, from = a.s, a.Entries
+	}
+
+	if b != nil {
+		d.s, to = b.s, b.Entries
+	}
+
+	err := d.diff(from, to, "", 0)
+	if err == storer.ErrStop {
+		return nil
+	}
+
+	return err
+}
+
+type treeStreamDiffer struct {
+	s  storer.EncodedObjectStorer
+	fn func(RawChange) error
+}
+
+func (d *treeStreamDiffer) diff(from, to []TreeEntry, dir string, depth int) error {
+	if depth > maxTreeDepth {
+		return ErrMaxTreeDepth
+	}
+
+	for len(from) > 0 || len(to) > 0 {
+		var cmp int
+		switch {
+		case len(from) == 0:
+			cmp = 1
+		case len(to) == 0:
+			cmp = -1
+		default:
+			cmp = compareTreeEntries(&from[0], &to[0])
+		}
+
+		var err error
+		switch {
+		case cmp < 0:
+			err = d.entry(&from[0], nil, dir, depth)
+			from = from[1:]
+		case cmp > 0:
+			err = d.entry(nil, &to[0], dir, depth)
+			to = to[1:]
+		default:
+			err = d.entry(&from[0], &to[0], dir, depth)
+			from, to = from[1:], to[1:]
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// entry compares two entries with the same name, and the same kind, a file
+// or a directory. One of them is nil if the entry is only on one side.
+func (d *treeStreamDiffer) entry(a, b *TreeEntry, dir string, depth int) error {
+	if a != nil && b != nil && a.Hash == b.Hash && a.Mode == b.Mode {
+		return nil
+	}
+
+	var name string
+	var isDir bool
+	if a != nil {
+		name, isDir = a.Name, a.Mode == filemode.Dir
+	} else {
+		name, isDir = b.Name, b.Mode == filemode.Dir
+	}
+
+	p := path.Join(dir, name)
+	if isDir {
+		from, err := d.entries(a)
+		if err != nil {
+			return err
+		}
+
+		to, err := d.entries(b)
+		if err != nil {
+			return err
+		}
+
+		return d.diff(from, to, p, depth+1)
+	}
+
+	c := RawChange{Path: p}
+	if a != nil {
+		c.FromMode, c.From = a.Mode, a.Hash
+	}
+
+	if b != nil {
+		c.ToMode, c.To = b.Mode, b.Hash
+	}
+
+	switch {
+	case a == nil:
+		c.Status = 'A'
+	case b == nil:
+		c.Status = 'D'
+	case a.Mode&fileTypeMask != b.Mode&fileTypeMask:
+		c.Status = 'T'
+	default:
+		c.Status = 'M'
+	}
+
+	return d.fn(c)
+}
+
+// fileTypeMask is the mask of the type bits of a file mode.
+const fileTypeMask = 0170000
+
+func (d *treeStreamDiffer) entries(e *TreeEntry) ([]TreeEntry, error) {
+	if e == nil {
+		return nil, nil
+	}
+
+	t, err := GetTree(d.s, e.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.Entries, nil
+}
+
+// compareTreeEntries compares two entries in the order of git, the names of
+// the directories are compared as if they had a trailing slash.
+func compareTreeEntries(a, b *TreeEntry) int {
+	n := len(a.Name)
+	if len(b.Name) < n {
+		n = len(b.Name)
+	}
+
+	if cmp := strings.Compare(a.Name[:n], b.Name[:n]); cmp != 0 {
+		return cmp
+	}
+
+	ca, cb := treeEntryByte(a, n), treeEntryByte(b, n)
+	switch {
+	case ca < cb:
+		return -1
+	case ca > cb:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// treeEntryByte returns the byte of the name of the entry at i, a slash past
+// the name of a directory, or 0 past the name of a file.
+func treeEntryByte(e *TreeEntry, i int) byte {
+	if i < len(e.Name) {
+		return e.Name[i]
+	}
+
+	if e.Mode == filemode.Dir {
+		return '/'
+	}
+
+	return 0
+}
+
+// RawDiffEncoder writes the changes of DiffTreeStream with the raw format of
+// `git diff-tree -r --raw`.
+type RawDiffEncoder struct {
+	w io.Writer
+}
+
+// NewRawDiffEncoder returns a new RawDiffEncoder writing to w.
+func NewRawDiffEncoder(w io.Writer) *RawDiffEncoder {
+	return &RawDiffEncoder{w}
+}
+
+// Encode writes a change.
+func (e *RawDiffEncoder) Encode(c RawChange) error {
+	_, err := fmt.Fprintf(e.w, ":%06o %06o %s %s %c\t%s\n",
+		uint32(c.FromMode), uint32(c.ToMode), c.From, c.To, c.Status, quoteRawPath(c.Path))
+	return err
+}
+
+// quoteRawPath quotes a path with special characters as git does.
+func quoteRawPath(p string) string {
+	needsQuote := false
+	for i := 0; i < len(p); i++ {
+		if c := p[i]; c < 0x20 || c == '"' || c == '\\' || c >= 0x7f {
+			needsQuote = true
+			break
+		}
+	}
+
+	if !needsQuote {
+		return p
+	}
+
+	buf := bytes.NewBuffer(nil)
+	buf.WriteByte('"')
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; c {
+		case '\a':
+			buf.WriteString(`\a`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\v':
+			buf.WriteString(`\v`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		default:
+			if c < 0x20 || c >= 0x7f {
+				fmt.Fprintf(buf, `\%03o`, c)
+			} else {
+				buf.WriteByte(c)
+			}
+		}
+	}
+
+	buf.WriteByte('"')
+	return buf.String()
+}
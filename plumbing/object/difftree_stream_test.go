@@ -0,0 +1,131 @@
+package object
+
+import (
+	"bytes"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+type DiffTreeStreamSuite struct {
+	storage *memory.Storage
+}
+
+var _ = Suite(&DiffTreeStreamSuite{})
+
+func (s *DiffTreeStreamSuite) SetUpTest(c *C) {
+	s.storage = memory.NewStorage()
+}
+
+type streamFile struct {
+	mode    filemode.FileMode
+	content string
+}
+
+func (s *DiffTreeStreamSuite) tree(c *C, files map[string]streamFile) *Tree {
+	b := NewTreeBuilder(s.storage, nil)
+	for path, f := range files {
+		h, err := storer.StoreObject(s.storage, plumbing.BlobObject, -1, strings.NewReader(f.content))
+		c.Assert(err, IsNil)
+		c.Assert(b.Add(path, f.mode, h), IsNil)
+	}
+
+	h, err := b.Build()
+	c.Assert(err, IsNil)
+
+	t, err := GetTree(s.storage, h)
+	c.Assert(err, IsNil)
+	return t
+}
+
+func (s *DiffTreeStreamSuite) trees(c *C) (*Tree, *Tree) {
+	from := s.tree(c, map[string]streamFile{
+		"a/b":   {filemode.Regular, "b\n"},
+		"a.txt": {filemode.Regular, "x\n"},
+		"a-b":   {filemode.Regular, "y\n"},
+		"c/d/e": {filemode.Regular, "e\n"},
+		"f":     {filemode.Regular, "f\n"},
+		"x/y":   {filemode.Regular, "y\n"},
+	})
+	c.Assert(from.Hash.String(), Equals, "030185522a924d5b7833186f3b22a3632ecaee3d")
+
+	to := s.tree(c, map[string]streamFile{
+		"a":       {filemode.Regular, "a\n"},
+		"a.txt":   {filemode.Executable, "x\n"},
+		"c/d/e":   {filemode.Regular, "e2\n"},
+		"c/new":   {filemode.Regular, "n\n"},
+		"f":       {filemode.Symlink, "target"},
+		"q\"uote": {filemode.Regular, "q\n"},
+		"x/y":     {filemode.Regular, "y\n"},
+	})
+	c.Assert(to.Hash.String(), Equals, "4d9b67f840c5b722edfce23c67951f0c4518709a")
+
+	return from, to
+}
+
+func (s *DiffTreeStreamSuite) TestRawDiffEncoder(c *C) {
+	from, to := s.trees(c)
+
+	buf := bytes.NewBuffer(nil)
+	e := NewRawDiffEncoder(buf)
+	c.Assert(DiffTreeStream(from, to, e.Encode), IsNil)
+
+	c.Assert(buf.String(), Equals, ""+
+		":000000 100644 0000000000000000000000000000000000000000 78981922613b2afb6025042ff6bd878ac1994e85 A\ta\n"+
+		":100644 000000 975fbec8256d3e8a3797e7a3611380f27c49f4ac 0000000000000000000000000000000000000000 D\ta-b\n"+
+		":100644 100755 587be6b4c3f93f93c489c0111bba5596147a26cb 587be6b4c3f93f93c489c0111bba5596147a26cb M\ta.txt\n"+
+		":100644 000000 61780798228d17af2d34fce4cfbdf35556832472 0000000000000000000000000000000000000000 D\ta/b\n"+
+		":100644 100644 d905d9da82c97264ab6f4920e20242e088850ce9 3811af3ca744c2fb44077a8025c23b4d4166a449 M\tc/d/e\n"+
+		":000000 100644 0000000000000000000000000000000000000000 8ba3a16384aacc37d01564b28401755ce8053f51 A\tc/new\n"+
+		":100644 120000 6a69f92020f5df77af6e8813ff1232493383b708 1de565933b05f74c75ff9a6520af5f9f8a5a2f1d T\tf\n"+
+		":000000 100644 0000000000000000000000000000000000000000 bca70f35318f31dd1d1d1d2d2e64c19b880899ff A\t\"q\\\"uote\"\n",
+	)
+}
+
+func (s *DiffTreeStreamSuite) TestDiffTreeStreamNil(c *C) {
+	from, _ := s.trees(c)
+
+	var added, deleted []string
+	err := DiffTreeStream(nil, from, func(ch RawChange) error {
+		c.Assert(ch.Status, Equals, byte('A'))
+		c.Assert(ch.From.IsZero(), Equals, true)
+		added = append(added, ch.Path)
+		return nil
+	})
+	c.Assert(err, IsNil)
+
+	err = DiffTreeStream(from, nil, func(ch RawChange) error {
+		c.Assert(ch.Status, Equals, byte('D'))
+		c.Assert(ch.To.IsZero(), Equals, true)
+		deleted = append(deleted, ch.Path)
+		return nil
+	})
+	c.Assert(err, IsNil)
+
+	expected := []string{"a-b", "a.txt", "a/b", "c/d/e", "f", "x/y"}
+	c.Assert(added, DeepEquals, expected)
+	c.Assert(deleted, DeepEquals, expected)
+
+	err = DiffTreeStream(from, from, func(ch RawChange) error {
+		c.Fatalf("unexpected change %v", ch)
+		return nil
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *DiffTreeStreamSuite) TestDiffTreeStreamStop(c *C) {
+	from, to := s.trees(c)
+
+	var n int
+	err := DiffTreeStream(from, to, func(RawChange) error {
+		n++
+		return storer.ErrStop
+	})
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 1)
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"strings"
 
+	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 	"gopkg.in/src-d/go-git.v4/utils/binary"
@@ -43,8 +44,13 @@ func (f *File) Contents() (content string, err error) {
 	return buf.String(), nil
 }
 
-// IsBinary returns if the file is binary or not
+// IsBinary returns if the file is binary or not. The files over
+// core.bigFileThreshold are always binary.
 func (f *File) IsBinary() (bin bool, err error) {
+	if plumbing.IsBigObject(f.obj) {
+		return true, nil
+	}
+
 	reader, err := f.Reader()
 	if err != nil {
 		return false, err
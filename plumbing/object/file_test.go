@@ -122,6 +122,37 @@ func (s *FileSuite) TestContents(c *C) {
 	}
 }
 
+func (s *FileSuite) TestIsBinaryBigFile(c *C) {
+	sto, err := filesystem.NewStorage(fixtures.Basic().One().DotGit())
+	c.Assert(err, IsNil)
+	sto.SetBigFileThreshold(16)
+
+	commit, err := GetCommit(sto, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	c.Assert(err, IsNil)
+
+	files, err := commit.Files()
+	c.Assert(err, IsNil)
+
+	var big int
+	err = files.ForEach(func(f *File) error {
+		if !plumbing.IsBigObject(f.obj) {
+			return nil
+		}
+
+		big++
+		bin, err := f.IsBinary()
+		c.Assert(err, IsNil)
+		c.Assert(bin, Equals, true)
+
+		content, err := f.Contents()
+		c.Assert(err, IsNil)
+		c.Assert(int64(len(content)), Equals, f.Size)
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(big > 0, Equals, true)
+}
+
 var linesTests = []struct {
 	repo   string   // the repo name as in localRepos
 	commit string   // the commit to search for the file
@@ -0,0 +1,302 @@
+package object
+
+import (
+	"math"
+
+	"github.com/emirpasic/gods/trees/binaryheap"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/commitgraph"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+// MergeBase mimics the behavior of `git merge-base actual others...`,
+// returning the best common ancestors of the actual commit and a hypothetical
+// merge of the others: the common ancestors that are not ancestors of other
+// common ancestors. It returns no commits if the histories are unrelated.
+func (c *Commit) MergeBase(others ...*Commit) ([]*Commit, error) {
+	p := newPainter(c.s)
+	if err := p.paint(c, others...); err != nil {
+		return nil, err
+	}
+
+	return removeRedundant(p.bases)
+}
+
+// MergeBaseOctopus mimics the behavior of `git merge-base --octopus`,
+// returning the best common ancestors of all the commits, as needed by an
+// octopus merge of them.
+func MergeBaseOctopus(commits ...*Commit) ([]*Commit, error) {
+	if len(commits) == 0 {
+		return nil, nil
+	}
+
+	result := commits[:1]
+	for _, c := range commits[1:] {
+		var next []*Commit
+		for _, r := range result {
+			bases, err := r.MergeBase(c)
+			if err != nil {
+				return nil, err
+			}
+
+			next = appendUnique(next, bases...)
+		}
+
+		result = next
+	}
+
+	return result, nil
+}
+
+// Independents mimics the behavior of `git merge-base --independent`,
+// returning the commits not reachable from any of the other ones, in the
+// given order and without duplicates.
+func Independents(commits []*Commit) ([]*Commit, error) {
+	return removeRedundant(appendUnique(nil, commits...))
+}
+
+func appendUnique(commits []*Commit, others ...*Commit) []*Commit {
+	for _, o := range others {
+		found := false
+		for _, c := range commits {
+			if c.Hash == o.Hash {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			commits = append(commits, o)
+		}
+	}
+
+	return commits
+}
+
+// IsAncestor returns true if the actual commit is an ancestor of the other
+// one, or the same commit, as `git merge-base --is-ancestor actual other`.
+// The walk does not go below the generation number of the actual commit if
+// the storer has a commit-graph.
+func (c *Commit) IsAncestor(other *Commit) (bool, error) {
+	if c.Hash == other.Hash {
+		return true, nil
+	}
+
+	p := newPainter(c.s)
+	generation := p.generation(c)
+	if generation > p.generation(other) {
+		// a commit is never reachable from a commit of lower generation
+		return false, nil
+	}
+
+	if generation != infiniteGeneration {
+		p.minGeneration = generation
+	}
+
+	if err := p.paint(c, other); err != nil {
+		return false, err
+	}
+
+	return p.flags[c.Hash]&reachableFromRight != 0, nil
+}
+
+// AheadBehind returns the number of commits reachable from the actual commit
+// and not from the other one, and the number of commits reachable from the
+// other commit and not from the actual one, as
+// `git rev-list --left-right --count actual...other`. The walk stops once
+// every pending commit is reachable from both commits.
+func (c *Commit) AheadBehind(other *Commit) (ahead, behind int, err error) {
+	p := newPainter(c.s)
+	if err := p.paint(c, other); err != nil {
+		return 0, 0, err
+	}
+
+	for _, f := range p.flags {
+		switch f &^ stale {
+		case reachableFromLeft:
+			ahead++
+		case reachableFromRight:
+			behind++
+		}
+	}
+
+	return ahead, behind, nil
+}
+
+const (
+	reachableFromLeft uint8 = 1 << iota
+	reachableFromRight
+	stale
+)
+
+// infiniteGeneration is the generation number of the commits not found at
+// the commit-graph, as every commit of the graph has its ancestors at it.
+const infiniteGeneration = math.MaxUint64
+
+// commitGraphStorer is implemented by the storers able to return the
+// commit-graph of the repository.
+type commitGraphStorer interface {
+	CommitGraph() (*commitgraph.CommitGraph, error)
+}
+
+// painter walks the history of a commit, the left one, and some others, the
+// right ones, flagging every commit with the sides reaching it, as
+// paint_down_to_common of git. The commits are walked in generation number
+// order if the storer has a commit-graph, falling back to committer time
+// order for the commits not found at it.
+type painter struct {
+	s     storer.EncodedObjectStorer
+	graph *commitgraph.CommitGraph
+	flags map[plumbing.Hash]uint8
+	bases []*Commit
+	heap  *binaryheap.Heap
+	// pending is the number of commits at the heap not flagged as stale
+	pending int
+	// minGeneration stops the walk at the commits of lower generation
+	minGeneration uint64
+}
+
+func newPainter(s storer.EncodedObjectStorer) *painter {
+	p := &painter{
+		s:     s,
+		flags: make(map[plumbing.Hash]uint8),
+		heap: binaryheap.NewWith(func(a, b interface{}) int {
+			pa, pb := a.(*paintedCommit), b.(*paintedCommit)
+			if pa.generation != pb.generation {
+				if pa.generation < pb.generation {
+					return 1
+				}
+				return -1
+			}
+
+			if pa.c.Committer.When.Before(pb.c.Committer.When) {
+				return 1
+			}
+			return -1
+		}),
+	}
+
+	if cgs, ok := s.(commitGraphStorer); ok {
+		// a broken commit-graph is ignored, as git does, the walk is
+		// still right without it
+		p.graph, _ = cgs.CommitGraph()
+	}
+
+	return p
+}
+
+func (p *painter) generation(c *Commit) uint64 {
+	if p.graph == nil {
+		return infiniteGeneration
+	}
+
+	generation, ok := p.graph.Generation(c.Hash)
+	if !ok {
+		return infiniteGeneration
+	}
+
+	return generation
+}
+
+// paintedCommit is a commit pushed to the heap with the flags it had, an
+// entry is outdated if the flags of the commit changed after it was pushed.
+type paintedCommit struct {
+	c          *Commit
+	flags      uint8
+	generation uint64
+}
+
+func (p *painter) push(c *Commit, f uint8) {
+	old, seen := p.flags[c.Hash]
+	if seen && old|f == old {
+		return
+	}
+
+	f |= old
+	p.flags[c.Hash] = f
+	p.heap.Push(&paintedCommit{c, f, p.generation(c)})
+	if f&stale == 0 {
+		p.pending++
+	}
+}
+
+func (p *painter) paint(left *Commit, right ...*Commit) error {
+	p.push(left, reachableFromLeft)
+	for _, r := range right {
+		p.push(r, reachableFromRight)
+	}
+
+	for p.pending > 0 {
+		v, _ := p.heap.Pop()
+		e := v.(*paintedCommit)
+		if e.flags&stale == 0 {
+			p.pending--
+		}
+
+		if e.generation < p.minGeneration {
+			break
+		}
+
+		f := p.flags[e.c.Hash]
+		if f != e.flags {
+			// pushed again with new flags
+			continue
+		}
+
+		both := reachableFromLeft | reachableFromRight
+		if f&both == both && f&stale == 0 {
+			p.bases = append(p.bases, e.c)
+			f |= stale
+			p.flags[e.c.Hash] = f
+		}
+
+		for _, h := range e.c.ParentHashes {
+			parent, err := GetCommit(p.s, h)
+			if err == plumbing.ErrObjectNotFound {
+				// the parents of the shallow commits are missing
+				continue
+			}
+
+			if err != nil {
+				return err
+			}
+
+			p.push(parent, f)
+		}
+	}
+
+	return nil
+}
+
+// removeRedundant removes the commits reachable from the other ones.
+func removeRedundant(commits []*Commit) ([]*Commit, error) {
+	if len(commits) < 2 {
+		return commits, nil
+	}
+
+	var result []*Commit
+	for i, c := range commits {
+		redundant := false
+		for j, other := range commits {
+			if i == j {
+				continue
+			}
+
+			var err error
+			if redundant, err = c.IsAncestor(other); err != nil {
+				return nil, err
+			}
+
+			if redundant {
+				break
+			}
+		}
+
+		if !redundant {
+			result = append(result, c)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,366 @@
+package object
+
+import (
+	"sort"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/commitgraph"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+type MergeBaseSuite struct {
+	storage *memory.Storage
+	commits map[string]*Commit
+}
+
+var _ = Suite(&MergeBaseSuite{})
+
+func (s *MergeBaseSuite) SetUpTest(c *C) {
+	s.storage = memory.NewStorage()
+	s.commits = make(map[string]*Commit)
+}
+
+// commit stores a commit with the given parents, committed at the given
+// minute.
+func (s *MergeBaseSuite) commit(c *C, name string, minute int, parents ...string) *Commit {
+	sig := Signature{
+		Name:  "foo",
+		Email: "foo@foo.foo",
+		When:  time.Date(2018, 1, 1, 0, minute, 0, 0, time.UTC),
+	}
+
+	commit := &Commit{
+		Author:    sig,
+		Committer: sig,
+		Message:   name,
+		TreeHash:  plumbing.NewHash("4b825dc642cb6eb9a060e54bf8d69288fbee4904"),
+	}
+
+	for _, p := range parents {
+		commit.ParentHashes = append(commit.ParentHashes, s.commits[p].Hash)
+	}
+
+	obj := s.storage.NewEncodedObject()
+	c.Assert(commit.Encode(obj), IsNil)
+
+	h, err := s.storage.SetEncodedObject(obj)
+	c.Assert(err, IsNil)
+
+	commit, err = GetCommit(s.storage, h)
+	c.Assert(err, IsNil)
+
+	s.commits[name] = commit
+	return commit
+}
+
+func (s *MergeBaseSuite) names(commits []*Commit) []string {
+	var names []string
+	for _, c := range commits {
+		names = append(names, c.Message)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func (s *MergeBaseSuite) TestMergeBase(c *C) {
+	s.commit(c, "A", 0)
+	s.commit(c, "B", 1, "A")
+	s.commit(c, "C", 2, "B")
+	s.commit(c, "D", 3, "B")
+	left := s.commit(c, "E", 4, "C")
+	right := s.commit(c, "F", 5, "D")
+
+	bases, err := left.MergeBase(right)
+	c.Assert(err, IsNil)
+	c.Assert(s.names(bases), DeepEquals, []string{"B"})
+
+	bases, err = left.MergeBase(left)
+	c.Assert(err, IsNil)
+	c.Assert(s.names(bases), DeepEquals, []string{"E"})
+}
+
+func (s *MergeBaseSuite) TestMergeBaseCrissCross(c *C) {
+	s.commit(c, "A", 0)
+	s.commit(c, "B", 1, "A")
+	s.commit(c, "C", 2, "A")
+	left := s.commit(c, "D", 3, "B", "C")
+	right := s.commit(c, "E", 4, "C", "B")
+
+	bases, err := left.MergeBase(right)
+	c.Assert(err, IsNil)
+	c.Assert(s.names(bases), DeepEquals, []string{"B", "C"})
+}
+
+func (s *MergeBaseSuite) TestMergeBaseUnrelated(c *C) {
+	left := s.commit(c, "A", 0)
+	right := s.commit(c, "B", 1)
+
+	bases, err := left.MergeBase(right)
+	c.Assert(err, IsNil)
+	c.Assert(bases, HasLen, 0)
+}
+
+func (s *MergeBaseSuite) TestMergeBaseClockSkew(c *C) {
+	s.commit(c, "A", 10)
+	s.commit(c, "B", 11, "A")
+	// C is older than its parent
+	s.commit(c, "C", 0, "B")
+	left := s.commit(c, "D", 12, "C")
+	right := s.commit(c, "E", 13, "B")
+
+	bases, err := left.MergeBase(right)
+	c.Assert(err, IsNil)
+	c.Assert(s.names(bases), DeepEquals, []string{"B"})
+
+	ahead, behind, err := left.AheadBehind(right)
+	c.Assert(err, IsNil)
+	c.Assert(ahead, Equals, 2)
+	c.Assert(behind, Equals, 1)
+}
+
+func (s *MergeBaseSuite) TestIsAncestor(c *C) {
+	a := s.commit(c, "A", 0)
+	b := s.commit(c, "B", 1, "A")
+	d := s.commit(c, "C", 2, "A")
+
+	is, err := a.IsAncestor(b)
+	c.Assert(err, IsNil)
+	c.Assert(is, Equals, true)
+
+	is, err = b.IsAncestor(a)
+	c.Assert(err, IsNil)
+	c.Assert(is, Equals, false)
+
+	is, err = b.IsAncestor(d)
+	c.Assert(err, IsNil)
+	c.Assert(is, Equals, false)
+
+	is, err = b.IsAncestor(b)
+	c.Assert(err, IsNil)
+	c.Assert(is, Equals, true)
+}
+
+func (s *MergeBaseSuite) TestAheadBehind(c *C) {
+	s.commit(c, "A", 0)
+	s.commit(c, "B", 1, "A")
+	s.commit(c, "C", 2, "B")
+	s.commit(c, "side", 3, "A")
+	left := s.commit(c, "D", 4, "C", "side")
+	s.commit(c, "E", 5, "B")
+	right := s.commit(c, "F", 6, "E")
+
+	ahead, behind, err := left.AheadBehind(right)
+	c.Assert(err, IsNil)
+	c.Assert(ahead, Equals, 3)
+	c.Assert(behind, Equals, 2)
+
+	ahead, behind, err = right.AheadBehind(left)
+	c.Assert(err, IsNil)
+	c.Assert(ahead, Equals, 2)
+	c.Assert(behind, Equals, 3)
+}
+
+func (s *MergeBaseSuite) TestAheadBehindShallow(c *C) {
+	// the parent of the shallow commits is not at the storage
+	s.commits["B"] = &Commit{Hash: plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")}
+	left := s.commit(c, "C", 2, "B")
+	right := s.commit(c, "D", 3, "B")
+
+	ahead, behind, err := left.AheadBehind(right)
+	c.Assert(err, IsNil)
+	c.Assert(ahead, Equals, 1)
+	c.Assert(behind, Equals, 1)
+}
+
+func (s *MergeBaseSuite) TestMergeBaseMany(c *C) {
+	s.commit(c, "A", 0)
+	s.commit(c, "B", 1, "A")
+	s.commit(c, "C", 2, "A")
+	left := s.commit(c, "D", 3, "B", "C")
+	b := s.commit(c, "E", 4, "B")
+	d := s.commit(c, "F", 5, "C")
+
+	// the merge base of D and a merge of E and F
+	bases, err := left.MergeBase(b, d)
+	c.Assert(err, IsNil)
+	c.Assert(s.names(bases), DeepEquals, []string{"B", "C"})
+}
+
+func (s *MergeBaseSuite) TestMergeBaseOctopus(c *C) {
+	s.commit(c, "A", 0)
+	s.commit(c, "B", 1, "A")
+	e := s.commit(c, "C", 2, "B")
+	f := s.commit(c, "D", 3, "B")
+	g := s.commit(c, "E", 4, "A")
+
+	bases, err := MergeBaseOctopus(e, f)
+	c.Assert(err, IsNil)
+	c.Assert(s.names(bases), DeepEquals, []string{"B"})
+
+	bases, err = MergeBaseOctopus(e, f, g)
+	c.Assert(err, IsNil)
+	c.Assert(s.names(bases), DeepEquals, []string{"A"})
+
+	bases, err = MergeBaseOctopus(e)
+	c.Assert(err, IsNil)
+	c.Assert(s.names(bases), DeepEquals, []string{"C"})
+}
+
+func (s *MergeBaseSuite) TestIndependents(c *C) {
+	a := s.commit(c, "A", 0)
+	b := s.commit(c, "B", 1, "A")
+	d := s.commit(c, "C", 2, "B")
+	e := s.commit(c, "D", 3, "A")
+
+	commits, err := Independents([]*Commit{b, d, a, e, d})
+	c.Assert(err, IsNil)
+	c.Assert(commits, HasLen, 2)
+	c.Assert(commits[0].Message, Equals, "C")
+	c.Assert(commits[1].Message, Equals, "D")
+
+	commits, err = Independents(nil)
+	c.Assert(err, IsNil)
+	c.Assert(commits, HasLen, 0)
+}
+
+// graphStorage is a storage with a commit-graph, recording the objects read.
+type graphStorage struct {
+	*memory.Storage
+	graph *commitgraph.CommitGraph
+	read  map[plumbing.Hash]bool
+}
+
+func (s *graphStorage) CommitGraph() (*commitgraph.CommitGraph, error) {
+	return s.graph, nil
+}
+
+func (s *graphStorage) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	s.read[h] = true
+	return s.Storage.EncodedObject(t, h)
+}
+
+// withGraph returns a storage with a commit-graph of the commits stored so
+// far, and the commits read from it.
+func (s *MergeBaseSuite) withGraph(c *C) (*graphStorage, map[string]*Commit) {
+	g := commitgraph.New()
+	g.HasGenerationData = true
+
+	var add func(commit *Commit) *commitgraph.CommitData
+	add = func(commit *Commit) *commitgraph.CommitData {
+		if d, ok := g.Commit(commit.Hash); ok {
+			return d
+		}
+
+		d := &commitgraph.CommitData{
+			Hash:          commit.Hash,
+			TreeHash:      commit.TreeHash,
+			ParentHashes:  commit.ParentHashes,
+			Generation:    1,
+			CorrectedDate: uint64(commit.Committer.When.Unix()),
+			When:          commit.Committer.When,
+		}
+
+		for _, h := range commit.ParentHashes {
+			parent, err := GetCommit(s.storage, h)
+			c.Assert(err, IsNil)
+
+			p := add(parent)
+			if p.Generation >= d.Generation {
+				d.Generation = p.Generation + 1
+			}
+
+			if p.CorrectedDate >= d.CorrectedDate {
+				d.CorrectedDate = p.CorrectedDate + 1
+			}
+		}
+
+		g.Add(d)
+		return d
+	}
+
+	for _, commit := range s.commits {
+		add(commit)
+	}
+
+	storage := &graphStorage{s.storage, g, make(map[plumbing.Hash]bool)}
+	commits := make(map[string]*Commit)
+	for name, commit := range s.commits {
+		var err error
+		commits[name], err = GetCommit(storage, commit.Hash)
+		c.Assert(err, IsNil)
+	}
+
+	storage.read = make(map[plumbing.Hash]bool)
+	return storage, commits
+}
+
+func (s *MergeBaseSuite) TestIsAncestorGeneration(c *C) {
+	s.commit(c, "A", 0)
+	s.commit(c, "B", 1, "A")
+	s.commit(c, "C", 2, "B")
+	s.commit(c, "D", 3, "C")
+	// E is older than its parent
+	s.commit(c, "E", 0, "D")
+	s.commit(c, "F", 5, "E")
+	s.commit(c, "side", 6, "C")
+
+	storage, commits := s.withGraph(c)
+
+	is, err := commits["side"].IsAncestor(commits["F"])
+	c.Assert(err, IsNil)
+	c.Assert(is, Equals, false)
+	// the walk stops at the generation of side
+	c.Assert(storage.read[commits["B"].Hash], Equals, false)
+
+	is, err = commits["F"].IsAncestor(commits["C"])
+	c.Assert(err, IsNil)
+	c.Assert(is, Equals, false)
+	c.Assert(storage.read, HasLen, 0)
+
+	is, err = commits["C"].IsAncestor(commits["F"])
+	c.Assert(err, IsNil)
+	c.Assert(is, Equals, true)
+
+	// a commit not found at the commit-graph
+	s.commit(c, "G", 7, "F", "side")
+	tip, err := GetCommit(storage, s.commits["G"].Hash)
+	c.Assert(err, IsNil)
+
+	is, err = commits["E"].IsAncestor(tip)
+	c.Assert(err, IsNil)
+	c.Assert(is, Equals, true)
+
+	is, err = tip.IsAncestor(commits["F"])
+	c.Assert(err, IsNil)
+	c.Assert(is, Equals, false)
+}
+
+func (s *MergeBaseSuite) TestMergeBaseGeneration(c *C) {
+	s.commit(c, "A", 10)
+	s.commit(c, "B", 11, "A")
+	// C and D are older than their parents
+	s.commit(c, "C", 0, "B")
+	s.commit(c, "D", 1, "C")
+	s.commit(c, "E", 12, "D")
+	s.commit(c, "F", 13, "B")
+	s.commit(c, "G", 14, "D", "F")
+
+	_, commits := s.withGraph(c)
+
+	bases, err := commits["E"].MergeBase(commits["G"])
+	c.Assert(err, IsNil)
+	c.Assert(s.names(bases), DeepEquals, []string{"D"})
+
+	ahead, behind, err := commits["E"].AheadBehind(commits["F"])
+	c.Assert(err, IsNil)
+	c.Assert(ahead, Equals, 3)
+	c.Assert(behind, Equals, 1)
+
+	independents, err := Independents([]*Commit{commits["C"], commits["E"], commits["F"]})
+	c.Assert(err, IsNil)
+	c.Assert(s.names(independents), DeepEquals, []string{"E", "F"})
+}
@@ -11,6 +11,7 @@ import (
 	"golang.org/x/crypto/openpgp"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/sshsig"
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 	"gopkg.in/src-d/go-git.v4/utils/ioutil"
 )
@@ -131,32 +132,10 @@ func (t *Tag) Decode(o plumbing.EncodedObject) (err error) {
 		return err
 	}
 
-	var pgpsig bool
-	// Check if data contains PGP signature.
-	if bytes.Contains(data, []byte(beginpgp)) {
-		// Split the lines at newline.
-		messageAndSig := bytes.Split(data, []byte("\n"))
-
-		for _, l := range messageAndSig {
-			if pgpsig {
-				if bytes.Contains(l, []byte(endpgp)) {
-					t.PGPSignature += endpgp + "\n"
-					pgpsig = false
-				} else {
-					t.PGPSignature += string(l) + "\n"
-				}
-				continue
-			}
-
-			// Check if it's the beginning of a PGP signature.
-			if bytes.Contains(l, []byte(beginpgp)) {
-				t.PGPSignature += beginpgp + "\n"
-				pgpsig = true
-				continue
-			}
-
-			t.Message += string(l) + "\n"
-		}
+	// The signature, if any, is appended to the message.
+	if i := signatureStart(data); i >= 0 {
+		t.Message = string(data[:i])
+		t.PGPSignature = string(data[i:])
 	} else {
 		t.Message = string(data)
 	}
@@ -169,6 +148,12 @@ func (t *Tag) Encode(o plumbing.EncodedObject) error {
 	return t.encode(o, true)
 }
 
+// EncodeWithoutSignature export a Tag into a plumbing.EncodedObject without
+// the signature, this is the payload signed by a tag signature.
+func (t *Tag) EncodeWithoutSignature(o plumbing.EncodedObject) error {
+	return t.encode(o, false)
+}
+
 func (t *Tag) encode(o plumbing.EncodedObject, includeSig bool) (err error) {
 	o.SetType(plumbing.TagObject)
 	w, err := o.Writer()
@@ -196,10 +181,12 @@ func (t *Tag) encode(o plumbing.EncodedObject, includeSig bool) (err error) {
 	}
 
 	if t.PGPSignature != "" && includeSig {
-		// Split all the signature lines and write with a newline at the end.
-		lines := strings.Split(t.PGPSignature, "\n")
-		for _, line := range lines {
-			if _, err = fmt.Fprintf(w, "%s\n", line); err != nil {
+		if _, err = fmt.Fprint(w, t.PGPSignature); err != nil {
+			return err
+		}
+
+		if !strings.HasSuffix(t.PGPSignature, "\n") {
+			if _, err = fmt.Fprint(w, "\n"); err != nil {
 				return err
 			}
 		}
@@ -208,6 +195,19 @@ func (t *Tag) encode(o plumbing.EncodedObject, includeSig bool) (err error) {
 	return err
 }
 
+// signatureStart returns the position at data where the signature starts, or
+// -1 if data is not signed.
+func signatureStart(data []byte) int {
+	for _, marker := range [][]byte{[]byte(beginpgp), []byte(beginx509), []byte(beginssh)} {
+		i := bytes.LastIndex(data, marker)
+		if i == 0 || (i > 0 && data[i-1] == '\n') {
+			return i
+		}
+	}
+
+	return -1
+}
+
 // Commit returns the commit pointed to by the tag. If the tag points to a
 // different type of object ErrUnsupportedObject will be returned.
 func (t *Tag) Commit() (*Commit, error) {
@@ -299,6 +299,36 @@ func (t *Tag) Verify(armoredKeyRing string) (*openpgp.Entity, error) {
 	return openpgp.CheckArmoredDetachedSignature(keyring, er, signature)
 }
 
+// VerifySSH performs the verification of the SSH signature of the tag with the
+// provided allowed signers, the signing key must be allowed at the time of the
+// tag. The allowed signers entry of the key is returned on success.
+func (t *Tag) VerifySSH(allowed sshsig.AllowedSigners) (*sshsig.AllowedSigner, error) {
+	encoded := &plumbing.MemoryObject{}
+	if err := t.encode(encoded, false); err != nil {
+		return nil, err
+	}
+
+	r, err := encoded.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	_, entry, err := verifySSH(r, t.PGPSignature, allowed, t.Tagger.When)
+	return entry, err
+}
+
+// VerifySignature verifies the signature of the tag with the given Verifier,
+// which defines the trusted keys, ErrUnsignedObject is returned if the tag is
+// not signed.
+func (t *Tag) VerifySignature(v Verifier) (*VerificationResult, error) {
+	encoded := &plumbing.MemoryObject{}
+	if err := t.encode(encoded, false); err != nil {
+		return nil, err
+	}
+
+	return verifySignature(v, encoded, t.PGPSignature, t.Tagger.When)
+}
+
 // TagIter provides an iterator for a set of tags.
 type TagIter struct {
 	storer.EncodedObjectIter
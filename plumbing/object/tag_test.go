@@ -312,6 +312,29 @@ RUysgqjcpT8+iQM1PblGfHR4XAhuOqN5Fx06PSaFZhqvWFezJ28/CLyX5q+oIVk=
 	c.Assert(decoded.PGPSignature, Equals, pgpsignature)
 }
 
+func (s *TagSuite) TestSignatureRoundTrip(c *C) {
+	tag := &Tag{
+		Name:         "foo",
+		Tagger:       Signature{Name: "foo", Email: "foo@foo.foo", When: time.Unix(1494201823, 0).UTC()},
+		Message:      "foo\n",
+		TargetType:   plumbing.CommitObject,
+		Target:       plumbing.NewHash("b8e471f58bcbca63b07bda20e428190409c2db47"),
+		PGPSignature: "-----BEGIN SIGNED MESSAGE-----\nfoo\n-----END SIGNED MESSAGE-----\n",
+	}
+
+	encoded := &plumbing.MemoryObject{}
+	c.Assert(tag.Encode(encoded), IsNil)
+
+	decoded := &Tag{}
+	c.Assert(decoded.Decode(encoded), IsNil)
+	c.Assert(decoded.Message, Equals, tag.Message)
+	c.Assert(decoded.PGPSignature, Equals, tag.PGPSignature)
+
+	reencoded := &plumbing.MemoryObject{}
+	c.Assert(decoded.Encode(reencoded), IsNil)
+	c.Assert(reencoded.Hash(), Equals, encoded.Hash())
+}
+
 func (s *TagSuite) TestVerify(c *C) {
 	ts := time.Unix(1511524851, 0)
 	loc, _ := time.LoadLocation("Asia/Kolkata")
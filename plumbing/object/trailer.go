@@ -0,0 +1,317 @@
+package object
+
+import (
+	"strings"
+)
+
+// gitGeneratedPrefixes are the prefixes of the lines added by git to the
+// trailer block, which is recognized with them even if most of its lines
+// aren't trailers.
+var gitGeneratedPrefixes = []string{
+	"Signed-off-by: ",
+	"(cherry picked from commit ",
+}
+
+// Trailer is a "Key: Value" line of the trailer block closing a commit
+// message, as the Signed-off-by and Co-authored-by ones.
+type Trailer struct {
+	Key   string
+	Value string
+}
+
+// String returns the trailer as a line of the trailer block, without the
+// newline.
+func (t Trailer) String() string {
+	return t.Key + ": " + t.Value
+}
+
+// Trailers returns the trailers of the commit message.
+func (c *Commit) Trailers() []Trailer {
+	return ParseTrailers(c.Message)
+}
+
+// ParseTrailers returns the trailers of the trailer block of the message, as
+// git interpret-trailers --parse does. The trailer block is the last
+// paragraph of the message, but the first one, made of trailers and their
+// continuation lines, or having at least a quarter of trailers if any of
+// them is generated by git, as Signed-off-by. The comments, a patch after a
+// "---" line and the trailing blank lines are ignored. The values folded over
+// several lines are unfolded.
+func ParseTrailers(msg string) []Trailer {
+	m := newTrailerMessage(msg)
+
+	var trailers []Trailer
+	for _, line := range m.block() {
+		if isCommentLine(line) {
+			continue
+		}
+
+		if isContinuationLine(line) {
+			if len(trailers) != 0 {
+				t := &trailers[len(trailers)-1]
+				t.Value = strings.TrimSpace(t.Value + " " + strings.TrimSpace(line))
+			}
+
+			continue
+		}
+
+		t, ok := parseTrailer(line)
+		if ok {
+			trailers = append(trailers, t)
+		}
+	}
+
+	return trailers
+}
+
+// AddTrailers adds the trailers to the end of the trailer block of the
+// message, creating it if the message doesn't have one. The trailers already
+// at the block, with the same key and value, are skipped. The keys are
+// matched case-insensitively.
+func AddTrailers(msg string, trailers ...Trailer) string {
+	m := newTrailerMessage(msg)
+	existing := ParseTrailers(msg)
+	for _, t := range trailers {
+		if !containsTrailer(existing, t) {
+			existing = append(existing, t)
+			m.add(t)
+		}
+	}
+
+	return m.String()
+}
+
+// SetTrailer sets the trailer at the trailer block of the message, replacing
+// the first trailer with the same key, and removing the rest of them, or
+// adding it if the key isn't at the block.
+func SetTrailer(msg string, t Trailer) string {
+	m := newTrailerMessage(msg)
+	if !m.replace(t.Key, &t) {
+		m.add(t)
+	}
+
+	return m.String()
+}
+
+// RemoveTrailers removes the trailers with the key from the trailer block of
+// the message, removing the block if it becomes empty.
+func RemoveTrailers(msg, key string) string {
+	m := newTrailerMessage(msg)
+	m.replace(key, nil)
+	return m.String()
+}
+
+// trailerMessage is a message split in lines, with the boundaries of its
+// trailer block, if any.
+type trailerMessage struct {
+	lines []string
+	// start and end are the lines of the trailer block, start is -1 if the
+	// message has no block, end being the line where it would be added.
+	start, end int
+}
+
+func newTrailerMessage(msg string) *trailerMessage {
+	m := &trailerMessage{start: -1}
+	if msg != "" {
+		m.lines = strings.SplitAfter(msg, "\n")
+		if m.lines[len(m.lines)-1] == "" {
+			m.lines = m.lines[:len(m.lines)-1]
+		}
+	}
+
+	end := len(m.lines)
+	for i, line := range m.lines {
+		if strings.HasPrefix(line, "---") && (len(line) == 3 || isSpace(line[3])) {
+			end = i
+			break
+		}
+	}
+
+	for end > 0 && (isBlankLine(m.lines[end-1]) || isCommentLine(m.lines[end-1])) {
+		end--
+	}
+
+	m.end = end
+
+	// the first paragraph, the title, is never the trailer block
+	title := 0
+	for title < end && isBlankLine(m.lines[title]) {
+		title++
+	}
+
+	for title < end && !isBlankLine(m.lines[title]) {
+		title++
+	}
+
+	var trailerLines, otherLines int
+	var recognized bool
+	for i := end - 1; i >= title; i-- {
+		line := m.lines[i]
+		switch {
+		case isBlankLine(line):
+			if trailerLines != 0 && (otherLines == 0 || recognized && trailerLines*3 >= otherLines) {
+				m.start = i + 1
+			}
+
+			return m
+		case isCommentLine(line), isContinuationLine(line):
+			continue
+		}
+
+		if hasGitGeneratedPrefix(line) {
+			recognized = true
+			trailerLines++
+			continue
+		}
+
+		if _, ok := parseTrailer(line); ok {
+			trailerLines++
+		} else {
+			otherLines++
+		}
+	}
+
+	return m
+}
+
+// block returns the lines of the trailer block.
+func (m *trailerMessage) block() []string {
+	if m.start == -1 {
+		return nil
+	}
+
+	return m.lines[m.start:m.end]
+}
+
+// add adds the trailer at the end of the trailer block, creating it if needed.
+func (m *trailerMessage) add(t Trailer) {
+	var lines []string
+	if m.start == -1 {
+		if m.end > 0 {
+			lines = append(lines, "\n")
+		}
+
+		m.start = m.end + len(lines)
+	}
+
+	lines = append(lines, t.String()+"\n")
+	if m.end > 0 && !strings.HasSuffix(m.lines[m.end-1], "\n") {
+		m.lines[m.end-1] += "\n"
+	}
+
+	m.splice(m.end, m.end, lines)
+}
+
+// replace replaces the first trailer with the key by t, removing the rest of
+// them, or all of them if t is nil. It returns false if there is none.
+func (m *trailerMessage) replace(key string, t *Trailer) bool {
+	if m.start == -1 {
+		return false
+	}
+
+	var lines []string
+	var found, skip bool
+	for _, line := range m.block() {
+		if skip && isContinuationLine(line) {
+			continue
+		}
+
+		skip = false
+		current, ok := parseTrailer(line)
+		if !ok || isContinuationLine(line) || !strings.EqualFold(current.Key, key) {
+			lines = append(lines, line)
+			continue
+		}
+
+		skip = true
+		if !found && t != nil {
+			lines = append(lines, t.String()+"\n")
+		}
+
+		found = true
+	}
+
+	if !found {
+		return false
+	}
+
+	start := m.start
+	if len(lines) == 0 {
+		// the blank line separating the block is removed along with it
+		start--
+		m.start = -1
+	}
+
+	m.splice(start, m.end, lines)
+	return true
+}
+
+// splice replaces the lines from i to j with the given ones, updating the end
+// of the trailer block.
+func (m *trailerMessage) splice(i, j int, lines []string) {
+	rest := append(lines, m.lines[j:]...)
+	m.lines = append(m.lines[:i], rest...)
+	m.end = i + len(lines)
+}
+
+func (m *trailerMessage) String() string {
+	return strings.Join(m.lines, "")
+}
+
+// parseTrailer parses a "Key: Value" line, the key made of alphanumeric
+// characters and hyphens.
+func parseTrailer(line string) (Trailer, bool) {
+	i := strings.IndexByte(line, ':')
+	if i <= 0 {
+		return Trailer{}, false
+	}
+
+	key := strings.TrimRight(line[:i], " \t")
+	if key == "" {
+		return Trailer{}, false
+	}
+
+	for _, r := range key {
+		if !(r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return Trailer{}, false
+		}
+	}
+
+	return Trailer{Key: key, Value: strings.TrimSpace(line[i+1:])}, true
+}
+
+func containsTrailer(trailers []Trailer, t Trailer) bool {
+	for _, current := range trailers {
+		if strings.EqualFold(current.Key, t.Key) && current.Value == t.Value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hasGitGeneratedPrefix(line string) bool {
+	for _, p := range gitGeneratedPrefixes {
+		if strings.HasPrefix(line, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isBlankLine(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func isCommentLine(line string) bool {
+	return strings.HasPrefix(line, "#")
+}
+
+func isContinuationLine(line string) bool {
+	return line != "" && isSpace(line[0]) && !isBlankLine(line)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
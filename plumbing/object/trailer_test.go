@@ -0,0 +1,80 @@
+package object
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type TrailerSuite struct{}
+
+var _ = Suite(&TrailerSuite{})
+
+func (s *TrailerSuite) TestParseTrailers(c *C) {
+	for _, t := range []struct {
+		msg      string
+		trailers []Trailer
+	}{
+		{"foo\n", nil},
+		{"Signed-off-by: foo\n", nil},
+		{"foo\n\nbar\n", nil},
+		{"foo\n\nbar\nFixes: #1\n", nil},
+		{"foo\n\nFixes: #1\nCo-authored-by: bar <bar@example.com>\n", []Trailer{
+			{"Fixes", "#1"},
+			{"Co-authored-by", "bar <bar@example.com>"},
+		}},
+		{"foo\n\nbar\n\nFixes : #1\n  and #2\n\n# comment\n\n", []Trailer{
+			{"Fixes", "#1 and #2"},
+		}},
+		{"foo\n\nbar\n\nsome text\nSigned-off-by: bar <bar@example.com>\n", []Trailer{
+			{"Signed-off-by", "bar <bar@example.com>"},
+		}},
+		{"foo\n\nsome\ntext\nmore\nSigned-off-by: bar\n", []Trailer{{"Signed-off-by", "bar"}}},
+		{"foo\n\nsome\nmore\ntext\nlines\nSigned-off-by: bar\n", nil},
+		{"foo\n\nFixes: #1\n---\n a | 1 +\n", []Trailer{{"Fixes", "#1"}}},
+	} {
+		c.Assert(ParseTrailers(t.msg), DeepEquals, t.trailers, Commentf("%q", t.msg))
+	}
+}
+
+func (s *TrailerSuite) TestAddTrailers(c *C) {
+	signoff := Trailer{"Signed-off-by", "foo <foo@example.com>"}
+	for _, t := range []struct {
+		msg, expected string
+	}{
+		{"", "Signed-off-by: foo <foo@example.com>\n"},
+		{"foo", "foo\n\nSigned-off-by: foo <foo@example.com>\n"},
+		{"foo\n\nbar\n", "foo\n\nbar\n\nSigned-off-by: foo <foo@example.com>\n"},
+		{
+			"foo\n\nFixes: #1\n\n# comment\n",
+			"foo\n\nFixes: #1\nSigned-off-by: foo <foo@example.com>\n\n# comment\n",
+		},
+		{
+			"foo\n\nSigned-off-by: foo <foo@example.com>\n",
+			"foo\n\nSigned-off-by: foo <foo@example.com>\n",
+		},
+	} {
+		c.Assert(AddTrailers(t.msg, signoff), Equals, t.expected, Commentf("%q", t.msg))
+	}
+
+	msg := AddTrailers("foo\n", Trailer{"Fixes", "#1"}, Trailer{"Fixes", "#2"}, Trailer{"fixes", "#1"})
+	c.Assert(msg, Equals, "foo\n\nFixes: #1\nFixes: #2\n")
+}
+
+func (s *TrailerSuite) TestSetTrailer(c *C) {
+	msg := "foo\n\nFixes: #1\nAcked-by: bar\nfixes: #2\n  continued\n"
+
+	c.Assert(SetTrailer(msg, Trailer{"Fixes", "#3"}), Equals, "foo\n\nFixes: #3\nAcked-by: bar\n")
+	c.Assert(SetTrailer(msg, Trailer{"Reviewed-by", "qux"}), Equals, msg+"Reviewed-by: qux\n")
+}
+
+func (s *TrailerSuite) TestRemoveTrailers(c *C) {
+	msg := "foo\n\nFixes: #1\nAcked-by: bar\n"
+
+	c.Assert(RemoveTrailers(msg, "acked-by"), Equals, "foo\n\nFixes: #1\n")
+	c.Assert(RemoveTrailers(RemoveTrailers(msg, "Fixes"), "Acked-by"), Equals, "foo\n")
+	c.Assert(RemoveTrailers("foo\n", "Fixes"), Equals, "foo\n")
+}
+
+func (s *TrailerSuite) TestCommitTrailers(c *C) {
+	commit := &Commit{Message: "foo\n\nCo-authored-by: bar <bar@example.com>\n"}
+	c.Assert(commit.Trailers(), DeepEquals, []Trailer{{"Co-authored-by", "bar <bar@example.com>"}})
+}
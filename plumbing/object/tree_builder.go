@@ -0,0 +1,247 @@
+package object
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+var (
+	// ErrInvalidTreePath is returned by the TreeBuilder when a path is empty
+	// or has an empty, ".", ".." or ".git" component.
+	ErrInvalidTreePath = errors.New("invalid tree entry path")
+)
+
+// TreeBuilder builds tree objects, and the hierarchy of trees of nested
+// paths, storing them at an object storer, as `git mktree` does. It starts
+// from an empty tree or from an existing one, whose subtrees are only read
+// when an entry below them is changed.
+type TreeBuilder struct {
+	s    storer.EncodedObjectStorer
+	root *treeBuilderNode
+}
+
+// treeBuilderNode is a tree being built, the entries of a stored tree are
+// read once they are needed.
+type treeBuilderNode struct {
+	hash    plumbing.Hash
+	entries map[string]*treeBuilderEntry
+	dirty   bool
+}
+
+type treeBuilderEntry struct {
+	mode filemode.FileMode
+	hash plumbing.Hash
+	// tree is the node of a directory, once it is read or changed
+	tree *treeBuilderNode
+}
+
+// NewTreeBuilder returns a TreeBuilder storing the trees at s, starting from
+// the given tree, or from an empty tree if base is nil.
+func NewTreeBuilder(s storer.EncodedObjectStorer, base *Tree) *TreeBuilder {
+	root := &treeBuilderNode{
+		entries: make(map[string]*treeBuilderEntry),
+		dirty:   true,
+	}
+
+	if base != nil {
+		root.hash = base.Hash
+		root.entries = treeBuilderEntries(base)
+		root.dirty = false
+	}
+
+	return &TreeBuilder{s: s, root: root}
+}
+
+func treeBuilderEntries(t *Tree) map[string]*treeBuilderEntry {
+	entries := make(map[string]*treeBuilderEntry, len(t.Entries))
+	for _, e := range t.Entries {
+		entries[e.Name] = &treeBuilderEntry{mode: e.Mode, hash: e.Hash}
+	}
+
+	return entries
+}
+
+// Add adds an entry at the given path, creating the directories leading to
+// it. Any entry at the path, or at one of its directories if it is not a
+// directory, is replaced. A directory entry is added with the hash of an
+// existing tree.
+func (b *TreeBuilder) Add(path string, mode filemode.FileMode, h plumbing.Hash) error {
+	parts, err := splitTreePath(path)
+	if err != nil {
+		return err
+	}
+
+	node := b.root
+	for _, name := range parts[:len(parts)-1] {
+		if node, err = b.child(node, name, true); err != nil {
+			return err
+		}
+	}
+
+	node.entries[parts[len(parts)-1]] = &treeBuilderEntry{mode: mode, hash: h}
+	node.dirty = true
+	return nil
+}
+
+// AddEntries adds the given entries, keyed by path, as Add does. The names of
+// the entries are taken from their paths.
+func (b *TreeBuilder) AddEntries(entries map[string]TreeEntry) error {
+	for path, e := range entries {
+		if err := b.Add(path, e.Mode, e.Hash); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Remove removes the entry at the given path, a directory is removed with
+// all its entries. The directories left empty are removed when the tree is
+// built. It returns ErrEntryNotFound if there is no entry at the path.
+func (b *TreeBuilder) Remove(path string) error {
+	parts, err := splitTreePath(path)
+	if err != nil {
+		return err
+	}
+
+	node := b.root
+	for _, name := range parts[:len(parts)-1] {
+		if node, err = b.child(node, name, false); err != nil {
+			return err
+		}
+	}
+
+	if err := b.load(node); err != nil {
+		return err
+	}
+
+	name := parts[len(parts)-1]
+	if _, ok := node.entries[name]; !ok {
+		return ErrEntryNotFound
+	}
+
+	delete(node.entries, name)
+	return nil
+}
+
+// child returns the node of the directory with the given name, marking the
+// node as changed, the directory is created if create is true.
+func (b *TreeBuilder) child(node *treeBuilderNode, name string, create bool) (*treeBuilderNode, error) {
+	if err := b.load(node); err != nil {
+		return nil, err
+	}
+
+	e, ok := node.entries[name]
+	if !ok || e.mode != filemode.Dir {
+		if !create {
+			return nil, ErrEntryNotFound
+		}
+
+		e = &treeBuilderEntry{mode: filemode.Dir, tree: &treeBuilderNode{
+			entries: make(map[string]*treeBuilderEntry),
+		}}
+
+		node.entries[name] = e
+	}
+
+	if e.tree == nil {
+		e.tree = &treeBuilderNode{hash: e.hash}
+	}
+
+	return e.tree, b.load(e.tree)
+}
+
+// load reads the entries of the node if needed, marking it as changed.
+func (b *TreeBuilder) load(node *treeBuilderNode) error {
+	node.dirty = true
+	if node.entries != nil {
+		return nil
+	}
+
+	t, err := GetTree(b.s, node.hash)
+	if err != nil {
+		return err
+	}
+
+	node.entries = treeBuilderEntries(t)
+	return nil
+}
+
+// Build stores the trees changed since the TreeBuilder was created, or since
+// the last call, and returns the hash of the root tree.
+func (b *TreeBuilder) Build() (plumbing.Hash, error) {
+	return b.build(b.root)
+}
+
+func (b *TreeBuilder) build(node *treeBuilderNode) (plumbing.Hash, error) {
+	if !node.dirty {
+		return node.hash, nil
+	}
+
+	t := &Tree{}
+	for name, e := range node.entries {
+		if e.tree != nil {
+			h, err := b.build(e.tree)
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+
+			if len(e.tree.entries) == 0 {
+				delete(node.entries, name)
+				continue
+			}
+
+			e.hash = h
+		}
+
+		t.Entries = append(t.Entries, TreeEntry{Name: name, Mode: e.mode, Hash: e.hash})
+	}
+
+	sortTreeEntries(t.Entries)
+
+	o := b.s.NewEncodedObject()
+	if err := t.Encode(o); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	h, err := b.s.SetEncodedObject(o)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	node.hash, node.dirty = h, false
+	return h, nil
+}
+
+func splitTreePath(path string) ([]string, error) {
+	parts := strings.Split(path, "/")
+	for _, p := range parts {
+		if p == "" || p == "." || p == ".." || p == ".git" ||
+			strings.IndexByte(p, 0) != -1 {
+			return nil, ErrInvalidTreePath
+		}
+	}
+
+	return parts, nil
+}
+
+// sortTreeEntries sorts the entries in the order of git, the names of the
+// directories are compared as if they had a trailing slash.
+func sortTreeEntries(entries []TreeEntry) {
+	key := func(e TreeEntry) string {
+		if e.Mode == filemode.Dir {
+			return e.Name + "/"
+		}
+
+		return e.Name
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return key(entries[i]) < key(entries[j])
+	})
+}
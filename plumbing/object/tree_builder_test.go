@@ -0,0 +1,149 @@
+package object
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+type TreeBuilderSuite struct {
+	storage *memory.Storage
+}
+
+var _ = Suite(&TreeBuilderSuite{})
+
+func (s *TreeBuilderSuite) SetUpTest(c *C) {
+	s.storage = memory.NewStorage()
+}
+
+func (s *TreeBuilderSuite) blob(c *C, content string) plumbing.Hash {
+	obj := s.storage.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+
+	w, err := obj.Writer()
+	c.Assert(err, IsNil)
+	_, err = w.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	h, err := s.storage.SetEncodedObject(obj)
+	c.Assert(err, IsNil)
+	return h
+}
+
+func (s *TreeBuilderSuite) build(c *C) *Tree {
+	b := NewTreeBuilder(s.storage, nil)
+	err := b.AddEntries(map[string]TreeEntry{
+		"a/b":   {Mode: filemode.Regular, Hash: s.blob(c, "b")},
+		"a.txt": {Mode: filemode.Regular, Hash: s.blob(c, "x")},
+		"a-b":   {Mode: filemode.Regular, Hash: s.blob(c, "y")},
+		"c/d/e": {Mode: filemode.Regular, Hash: s.blob(c, "e")},
+	})
+	c.Assert(err, IsNil)
+
+	h, err := b.Build()
+	c.Assert(err, IsNil)
+
+	tree, err := GetTree(s.storage, h)
+	c.Assert(err, IsNil)
+	return tree
+}
+
+func (s *TreeBuilderSuite) TestBuild(c *C) {
+	tree := s.build(c)
+	c.Assert(tree.Hash.String(), Equals, "4a2ee7dfa9e66c6727613e9f24dc968ff455065e")
+
+	var names []string
+	for _, e := range tree.Entries {
+		names = append(names, e.Name)
+	}
+
+	c.Assert(names, DeepEquals, []string{"a-b", "a.txt", "a", "c"})
+
+	e, err := tree.FindEntry("c/d/e")
+	c.Assert(err, IsNil)
+	c.Assert(e.Mode, Equals, filemode.Regular)
+}
+
+func (s *TreeBuilderSuite) TestBuildFromTree(c *C) {
+	base := s.build(c)
+
+	b := NewTreeBuilder(s.storage, base)
+	h, err := b.Build()
+	c.Assert(err, IsNil)
+	c.Assert(h, Equals, base.Hash)
+
+	c.Assert(b.Remove("c/d/e"), IsNil)
+	c.Assert(b.Add("a2", filemode.Regular, s.blob(c, "f")), IsNil)
+
+	h, err = b.Build()
+	c.Assert(err, IsNil)
+	c.Assert(h.String(), Equals, "177cfaa5f55315ff0ec2947e0fcb70bd2603dcdd")
+}
+
+func (s *TreeBuilderSuite) TestAddReplaces(c *C) {
+	b := NewTreeBuilder(s.storage, s.build(c))
+
+	// a file replacing a directory, and a directory replacing a file
+	c.Assert(b.Add("a", filemode.Executable, s.blob(c, "a")), IsNil)
+	c.Assert(b.Add("a.txt/foo", filemode.Regular, s.blob(c, "foo")), IsNil)
+
+	h, err := b.Build()
+	c.Assert(err, IsNil)
+
+	tree, err := GetTree(s.storage, h)
+	c.Assert(err, IsNil)
+
+	e, err := tree.FindEntry("a")
+	c.Assert(err, IsNil)
+	c.Assert(e.Mode, Equals, filemode.Executable)
+
+	e, err = tree.FindEntry("a.txt/foo")
+	c.Assert(err, IsNil)
+	c.Assert(e.Hash, Equals, s.blob(c, "foo"))
+}
+
+func (s *TreeBuilderSuite) TestAddTree(c *C) {
+	base := s.build(c)
+	sub, err := base.Tree("c")
+	c.Assert(err, IsNil)
+
+	b := NewTreeBuilder(s.storage, nil)
+	c.Assert(b.Add("x/y", filemode.Dir, sub.Hash), IsNil)
+	c.Assert(b.Add("x/y/z", filemode.Regular, s.blob(c, "z")), IsNil)
+
+	h, err := b.Build()
+	c.Assert(err, IsNil)
+
+	tree, err := GetTree(s.storage, h)
+	c.Assert(err, IsNil)
+
+	_, err = tree.FindEntry("x/y/d/e")
+	c.Assert(err, IsNil)
+	_, err = tree.FindEntry("x/y/z")
+	c.Assert(err, IsNil)
+}
+
+func (s *TreeBuilderSuite) TestRemoveNotFound(c *C) {
+	b := NewTreeBuilder(s.storage, s.build(c))
+	c.Assert(b.Remove("foo"), Equals, ErrEntryNotFound)
+	c.Assert(b.Remove("a.txt/foo"), Equals, ErrEntryNotFound)
+	c.Assert(b.Remove("a/b"), IsNil)
+
+	h, err := b.Build()
+	c.Assert(err, IsNil)
+
+	tree, err := GetTree(s.storage, h)
+	c.Assert(err, IsNil)
+	c.Assert(tree.Entries, HasLen, 3)
+}
+
+func (s *TreeBuilderSuite) TestInvalidPath(c *C) {
+	b := NewTreeBuilder(s.storage, nil)
+	for _, path := range []string{"", "/foo", "foo/", "foo//bar", "./foo", "foo/../bar", ".git/config"} {
+		err := b.Add(path, filemode.Regular, plumbing.ZeroHash)
+		c.Assert(err, Equals, ErrInvalidTreePath, Commentf("path %q", path))
+	}
+}
@@ -0,0 +1,139 @@
+package object
+
+import (
+	"path"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+// TreeListEntry is an entry listed by Tree.ListEntries, with its full path
+// and the type and size of its object.
+type TreeListEntry struct {
+	Path string
+	Mode filemode.FileMode
+	Type plumbing.ObjectType
+	Hash plumbing.Hash
+	// Size is the size of a blob, it is -1 for submodules.
+	Size int64
+}
+
+// ListEntries calls fn for the blobs and submodules below the tree whose path
+// match one of the given pathspecs, or for all of them if no pathspec is
+// given, in the order of `git ls-tree -r -l --full-tree -- <pathspec>...`.
+// A pathspec matches the path equal to it and the paths below it. Only the
+// subtrees that may contain matching paths are read. If ErrStop is returned
+// by fn the listing is stopped but no error is returned.
+func (t *Tree) ListEntries(pathspecs []string, fn func(*TreeListEntry) error) (err error) {
+	specs := make([]string, 0, len(pathspecs))
+	for _, p := range pathspecs {
+		p = strings.Trim(path.Clean(p), "/")
+		if p == "." || p == "" {
+			specs = nil
+			break
+		}
+
+		specs = append(specs, p)
+	}
+
+	b, err := storer.NewObjectBatch(t.s)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(b, &err)
+
+	l := &treeLister{specs: specs, b: b, fn: fn}
+	err = l.list(t, "", 0)
+	if err == storer.ErrStop {
+		return nil
+	}
+
+	return err
+}
+
+type treeLister struct {
+	specs []string
+	b     storer.ObjectBatch
+	fn    func(*TreeListEntry) error
+}
+
+func (l *treeLister) list(t *Tree, dir string, depth int) error {
+	if depth > maxTreeDepth {
+		return ErrMaxTreeDepth
+	}
+
+	for _, e := range t.Entries {
+		p := path.Join(dir, e.Name)
+		if e.Mode == filemode.Dir {
+			if !l.inDir(p) {
+				continue
+			}
+
+			sub, err := GetTree(t.s, e.Hash)
+			if err != nil {
+				return err
+			}
+
+			if err := l.list(sub, p, depth+1); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if !l.match(p) {
+			continue
+		}
+
+		le := &TreeListEntry{Path: p, Mode: e.Mode, Hash: e.Hash, Type: plumbing.CommitObject, Size: -1}
+		if e.Mode != filemode.Submodule {
+			info, err := l.b.ObjectInfo(e.Hash)
+			if err != nil {
+				return err
+			}
+
+			le.Type, le.Size = info.Type, info.Size
+		}
+
+		if err := l.fn(le); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// match returns whether the path matches one of the pathspecs.
+func (l *treeLister) match(p string) bool {
+	if len(l.specs) == 0 {
+		return true
+	}
+
+	for _, s := range l.specs {
+		if p == s || strings.HasPrefix(p, s+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// inDir returns whether the directory may contain paths matching one of the
+// pathspecs.
+func (l *treeLister) inDir(dir string) bool {
+	if l.match(dir) {
+		return true
+	}
+
+	for _, s := range l.specs {
+		if strings.HasPrefix(s, dir+"/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,94 @@
+package object
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *TreeBuilderSuite) listEntries(c *C, t *Tree, pathspecs ...string) []string {
+	var paths []string
+	err := t.ListEntries(pathspecs, func(e *TreeListEntry) error {
+		paths = append(paths, e.Path)
+		return nil
+	})
+
+	c.Assert(err, IsNil)
+	return paths
+}
+
+func (s *TreeBuilderSuite) TestListEntries(c *C) {
+	tree := s.build(c)
+
+	var entries []*TreeListEntry
+	err := tree.ListEntries(nil, func(e *TreeListEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 4)
+	c.Assert(*entries[3], DeepEquals, TreeListEntry{
+		Path: "c/d/e",
+		Mode: filemode.Regular,
+		Type: plumbing.BlobObject,
+		Hash: s.blob(c, "e"),
+		Size: 1,
+	})
+
+	c.Assert(s.listEntries(c, tree), DeepEquals, []string{"a-b", "a.txt", "a/b", "c/d/e"})
+	c.Assert(s.listEntries(c, tree, "."), DeepEquals, []string{"a-b", "a.txt", "a/b", "c/d/e"})
+	c.Assert(s.listEntries(c, tree, "a"), DeepEquals, []string{"a/b"})
+	c.Assert(s.listEntries(c, tree, "c/d/", "a.txt"), DeepEquals, []string{"a.txt", "c/d/e"})
+	c.Assert(s.listEntries(c, tree, "c/d/e/f", "c/d/x"), HasLen, 0)
+}
+
+func (s *TreeBuilderSuite) TestListEntriesSubmodule(c *C) {
+	b := NewTreeBuilder(s.storage, s.build(c))
+	sub := plumbing.NewHash("0000000000000000000000000000000000000001")
+	c.Assert(b.Add("c/sub", filemode.Submodule, sub), IsNil)
+
+	h, err := b.Build()
+	c.Assert(err, IsNil)
+	tree, err := GetTree(s.storage, h)
+	c.Assert(err, IsNil)
+
+	var entries []*TreeListEntry
+	err = tree.ListEntries([]string{"c/sub"}, func(e *TreeListEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	c.Assert(*entries[0], DeepEquals, TreeListEntry{
+		Path: "c/sub", Mode: filemode.Submodule, Type: plumbing.CommitObject, Hash: sub, Size: -1,
+	})
+}
+
+func (s *TreeBuilderSuite) TestListEntriesReadsMatchingTrees(c *C) {
+	tree := s.build(c)
+	a, err := tree.Tree("a")
+	c.Assert(err, IsNil)
+	d, err := tree.Tree("c/d")
+	c.Assert(err, IsNil)
+
+	storage := &graphStorage{s.storage, nil, make(map[plumbing.Hash]bool)}
+	tree, err = GetTree(storage, tree.Hash)
+	c.Assert(err, IsNil)
+
+	c.Assert(s.listEntries(c, tree, "c/d/e"), DeepEquals, []string{"c/d/e"})
+	c.Assert(storage.read[d.Hash], Equals, true)
+	c.Assert(storage.read[a.Hash], Equals, false)
+}
+
+func (s *TreeBuilderSuite) TestListEntriesStop(c *C) {
+	var paths []string
+	err := s.build(c).ListEntries(nil, func(e *TreeListEntry) error {
+		paths = append(paths, e.Path)
+		return storer.ErrStop
+	})
+
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"a-b"})
+}
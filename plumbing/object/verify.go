@@ -0,0 +1,294 @@
+package object
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	stdioutil "io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/sshsig"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	ErrUnsignedObject       = errors.New("object is not signed")
+	ErrUnsupportedSignature = errors.New("unsupported signature type")
+	ErrKeyNotValid          = errors.New("signing key not valid at the signature time")
+)
+
+// SignatureType is the format of the signature of a commit or a tag.
+type SignatureType int8
+
+const (
+	UnknownSignature SignatureType = iota
+	OpenPGPSignature
+	X509Signature
+	SSHSignature
+)
+
+// DetectSignatureType returns the type of the given armored signature.
+func DetectSignatureType(signature string) SignatureType {
+	signature = strings.TrimSpace(signature)
+	switch {
+	case strings.HasPrefix(signature, beginpgp):
+		return OpenPGPSignature
+	case strings.HasPrefix(signature, beginx509):
+		return X509Signature
+	case strings.HasPrefix(signature, beginssh):
+		return SSHSignature
+	default:
+		return UnknownSignature
+	}
+}
+
+// VerificationResult describes a valid signature of a commit or tag.
+type VerificationResult struct {
+	// Type is the type of the signature.
+	Type SignatureType
+	// KeyID identifies the signing key, the long key ID for OpenPGP keys
+	// and the SHA256 fingerprint for SSH keys.
+	KeyID string
+	// Signer is the identity of the signer, the primary identity of OpenPGP
+	// keys and the principals of the allowed signers entry for SSH keys.
+	Signer string
+	// ValidAfter and ValidBefore define the time window the key is valid
+	// to sign for, a zero value means no limit.
+	ValidAfter  time.Time
+	ValidBefore time.Time
+}
+
+// Verifier verifies the signature of a commit or tag against a set of trusted
+// keys. The payload is the object encoded without the signature and when is
+// the time of the object, at which the key must be valid if the signature
+// doesn't contain its creation time.
+type Verifier interface {
+	Verify(payload io.Reader, signature string, when time.Time) (*VerificationResult, error)
+}
+
+type openPGPVerifier struct {
+	keyring openpgp.EntityList
+}
+
+// NewOpenPGPVerifier returns a Verifier of OpenPGP signatures, trusting the
+// keys of the given keyring.
+func NewOpenPGPVerifier(keyring openpgp.EntityList) Verifier {
+	return &openPGPVerifier{keyring: keyring}
+}
+
+// Verify implements the Verifier interface.
+func (v *openPGPVerifier) Verify(payload io.Reader, signature string, when time.Time) (*VerificationResult, error) {
+	if DetectSignatureType(signature) != OpenPGPSignature {
+		return nil, ErrUnsupportedSignature
+	}
+
+	entity, err := openpgp.CheckArmoredDetachedSignature(v.keyring, payload, strings.NewReader(signature))
+	if err != nil {
+		return nil, err
+	}
+
+	issuer, signed, err := signatureIssuer(signature)
+	if err != nil {
+		return nil, err
+	}
+
+	key, lifetime := entity.PrimaryKey, primaryKeyLifetime(entity)
+	for _, sub := range entity.Subkeys {
+		if sub.PublicKey.KeyId == issuer {
+			key, lifetime = sub.PublicKey, sub.Sig.KeyLifetimeSecs
+		}
+	}
+
+	// Times are stored with a precision of seconds.
+	created := key.CreationTime.Truncate(time.Second)
+
+	r := &VerificationResult{
+		Type:       OpenPGPSignature,
+		KeyID:      key.KeyIdString(),
+		Signer:     primaryIdentityName(entity),
+		ValidAfter: created,
+	}
+
+	if lifetime != nil && *lifetime != 0 {
+		r.ValidBefore = r.ValidAfter.Add(time.Duration(*lifetime) * time.Second)
+	}
+
+	// OpenPGP signatures carry their creation time, which is used instead of
+	// the time of the object, as gpg does.
+	if !r.isValidAt(signed) {
+		return nil, ErrKeyNotValid
+	}
+
+	return r, nil
+}
+
+// signatureIssuer returns the key ID of the issuer of the signature and its
+// creation time.
+func signatureIssuer(signature string) (uint64, time.Time, error) {
+	block, err := armor.Decode(strings.NewReader(signature))
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	p, err := packet.Read(block.Body)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	switch sig := p.(type) {
+	case *packet.Signature:
+		if sig.IssuerKeyId != nil {
+			return *sig.IssuerKeyId, sig.CreationTime, nil
+		}
+	case *packet.SignatureV3:
+		return sig.IssuerKeyId, sig.CreationTime, nil
+	}
+
+	return 0, time.Time{}, ErrUnsupportedSignature
+}
+
+// primaryIdentityName returns the name of the primary identity, or the first
+// one in lexical order if none is flagged as primary.
+func primaryIdentityName(e *openpgp.Entity) string {
+	var names []string
+	for name, id := range e.Identities {
+		if id.SelfSignature != nil && id.SelfSignature.IsPrimaryId != nil && *id.SelfSignature.IsPrimaryId {
+			return name
+		}
+
+		names = append(names, name)
+	}
+
+	if len(names) == 0 {
+		return ""
+	}
+
+	sort.Strings(names)
+	return names[0]
+}
+
+func primaryKeyLifetime(e *openpgp.Entity) *uint32 {
+	id, ok := e.Identities[primaryIdentityName(e)]
+	if !ok || id.SelfSignature == nil {
+		return nil
+	}
+
+	return id.SelfSignature.KeyLifetimeSecs
+}
+
+type sshVerifier struct {
+	allowed sshsig.AllowedSigners
+}
+
+// NewSSHVerifier returns a Verifier of SSH signatures, trusting the keys of
+// the given allowed signers.
+func NewSSHVerifier(allowed sshsig.AllowedSigners) Verifier {
+	return &sshVerifier{allowed: allowed}
+}
+
+// Verify implements the Verifier interface.
+func (v *sshVerifier) Verify(payload io.Reader, signature string, when time.Time) (*VerificationResult, error) {
+	if DetectSignatureType(signature) != SSHSignature {
+		return nil, ErrUnsupportedSignature
+	}
+
+	sig, entry, err := verifySSH(payload, signature, v.allowed, when)
+	if err != nil {
+		return nil, err
+	}
+
+	key := sig.PublicKey
+	if cert, ok := key.(*ssh.Certificate); ok {
+		key = cert.Key
+	}
+
+	return &VerificationResult{
+		Type:        SSHSignature,
+		KeyID:       ssh.FingerprintSHA256(key),
+		Signer:      strings.Join(entry.Principals, ","),
+		ValidAfter:  entry.ValidAfter,
+		ValidBefore: entry.ValidBefore,
+	}, nil
+}
+
+// verifySSH verifies the armored SSH signature of the given payload, the key
+// must be allowed to sign git objects at the given time.
+func verifySSH(
+	payload io.Reader,
+	armored string,
+	allowed sshsig.AllowedSigners,
+	when time.Time,
+) (*sshsig.Signature, *sshsig.AllowedSigner, error) {
+	sig, err := sshsig.Unmarshal([]byte(armored))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := sig.Verify(payload, sshsig.Namespace); err != nil {
+		return nil, nil, err
+	}
+
+	entry, err := allowed.Find(sig.PublicKey, sshsig.Namespace, when)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sig, entry, nil
+}
+
+type multiVerifier []Verifier
+
+// NewMultiVerifier returns a Verifier delegating to the first of the given
+// verifiers supporting the type of the signature, allowing to trust at the
+// same time an OpenPGP keyring and a set of SSH allowed signers.
+func NewMultiVerifier(verifiers ...Verifier) Verifier {
+	return multiVerifier(verifiers)
+}
+
+// Verify implements the Verifier interface.
+func (m multiVerifier) Verify(payload io.Reader, signature string, when time.Time) (*VerificationResult, error) {
+	content, err := stdioutil.ReadAll(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range m {
+		r, err := v.Verify(bytes.NewReader(content), signature, when)
+		if err == ErrUnsupportedSignature {
+			continue
+		}
+
+		return r, err
+	}
+
+	return nil, ErrUnsupportedSignature
+}
+
+func (r *VerificationResult) isValidAt(when time.Time) bool {
+	if !r.ValidAfter.IsZero() && when.Before(r.ValidAfter) {
+		return false
+	}
+
+	return r.ValidBefore.IsZero() || when.Before(r.ValidBefore)
+}
+
+// verifySignature verifies the signature of the object encoded at payload.
+func verifySignature(v Verifier, payload plumbing.EncodedObject, signature string, when time.Time) (*VerificationResult, error) {
+	if signature == "" {
+		return nil, ErrUnsignedObject
+	}
+
+	r, err := payload.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	return v.Verify(r, signature, when)
+}
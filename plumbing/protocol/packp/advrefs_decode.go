@@ -60,7 +60,7 @@ func (d *advRefsDecoder) error(format string, a ...interface{}) {
 		fmt.Sprintf(format, a...),
 	)
 
-	d.err = NewErrUnexpectedData(msg, d.line)
+	d.err = &ErrUnexpectedData{Msg: msg, Data: d.line, Line: d.nLine}
 }
 
 // Reads a new pkt-line from the scanner, makes its payload available as
@@ -134,12 +134,14 @@ func encodeRefs(e *advRefsEncoder) encoderStateFn {
 		}
 
 		hash := e.data.References[r]
-		if e.err = e.pe.Encodef("%s %s\n", hash.String(), r); e.err != nil {
+		l := e.pe.Line().AppendHex(hash[:]).AppendByte(' ').AppendString(r)
+		if e.err = l.AppendByte('\n').End(); e.err != nil {
 			return nil
 		}
 
 		if hash, ok := e.data.Peeled[r]; ok {
-			if e.err = e.pe.Encodef("%s %s^{}\n", hash.String(), r); e.err != nil {
+			l := e.pe.Line().AppendHex(hash[:]).AppendByte(' ').AppendString(r)
+			if e.err = l.AppendString("^{}\n").End(); e.err != nil {
 				return nil
 			}
 		}
@@ -152,7 +154,7 @@ func encodeRefs(e *advRefsEncoder) encoderStateFn {
 func encodeShallow(e *advRefsEncoder) encoderStateFn {
 	sorted := sortShallows(e.data.Shallows)
 	for _, hash := range sorted {
-		if e.err = e.pe.Encodef("shallow %s\n", hash); e.err != nil {
+		if e.err = e.pe.Line().AppendString("shallow ").AppendString(hash).AppendByte('\n').End(); e.err != nil {
 			return nil
 		}
 	}
@@ -230,8 +230,15 @@ const (
 	PushCert Capability = "push-cert"
 	// SymRef symbolic reference support for better negotiation.
 	SymRef Capability = "symref"
+	// Filter if the upload-pack server advertises this capability,
+	// fetch-pack may send "filter" commands to request a partial clone or
+	// partial fetch, and the server omits the objects excluded by the given
+	// filter-spec from the packfile, e.g. "blob:none".
+	Filter Capability = "filter"
 )
 
+// DefaultAgent is the value of the agent capability sent by go-git, unless
+// replaced by the config of the repository.
 const DefaultAgent = "go-git/4.x"
 
 var known = map[Capability]bool{
@@ -241,6 +248,7 @@ var known = map[Capability]bool{
 	NoProgress: true, IncludeTag: true, ReportStatus: true, DeleteRefs: true,
 	Quiet: true, Atomic: true, PushOptions: true, AllowTipSHA1InWant: true,
 	AllowReachableSHA1InWant: true, PushCert: true, SymRef: true,
+	Filter: true,
 }
 
 var requiresArgument = map[Capability]bool{
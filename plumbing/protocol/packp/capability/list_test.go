@@ -114,6 +114,16 @@ func (s *SuiteCapabilities) TestSet(c *check.C) {
 	c.Assert(cap.Get(SymRef), check.DeepEquals, []string{"bar"})
 }
 
+func (s *SuiteCapabilities) TestSetKeepsOrder(c *check.C) {
+	cap := NewList()
+	cap.Add(Agent, "foo")
+	cap.Add(ThinPack)
+	err := cap.Set(Agent, "bar")
+	c.Assert(err, check.IsNil)
+
+	c.Assert(cap.String(), check.Equals, "agent=bar thin-pack")
+}
+
 func (s *SuiteCapabilities) TestSetEmpty(c *check.C) {
 	cap := NewList()
 	err := cap.Set(Agent, "bar")
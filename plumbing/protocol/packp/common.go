@@ -33,6 +33,7 @@ var (
 	deepenCommits   = []byte("deepen ")
 	deepenSince     = []byte("deepen-since ")
 	deepenReference = []byte("deepen-not ")
+	filter          = []byte("filter ")
 
 	// shallow-update
 	unshallow = []byte("unshallow ")
@@ -43,6 +44,18 @@ var (
 
 	// updreq
 	shallowNoSp = []byte("shallow")
+
+	// upload-archive
+	argument  = []byte("argument ")
+	nack      = []byte("NACK ")
+	errPrefix = []byte("ERR ")
+
+	// protocol v2
+	version2     = []byte("version 2")
+	lsRefs       = []byte("command=ls-refs\n")
+	refPrefix    = []byte("ref-prefix ")
+	symrefTarget = []byte("symref-target:")
+	peeledAttr   = []byte("peeled:")
 )
 
 func isFlush(payload []byte) bool {
@@ -53,6 +66,9 @@ func isFlush(payload []byte) bool {
 type ErrUnexpectedData struct {
 	Msg  string
 	Data []byte
+	// Line is the number of the pkt-line holding Data, starting at 1, or 0
+	// if unknown.
+	Line int
 }
 
 // NewErrUnexpectedData returns a new ErrUnexpectedData containing the data and
@@ -68,3 +84,20 @@ func (err *ErrUnexpectedData) Error() string {
 
 	return fmt.Sprintf("%s (%s)", err.Msg, err.Data)
 }
+
+// ErrEncoding represents an error writing a part of a message.
+type ErrEncoding struct {
+	// What is the part of the message being written.
+	What string
+	// Err is the error of the writer.
+	Err error
+}
+
+func (err *ErrEncoding) Error() string {
+	return fmt.Sprintf("encoding %s: %s", err.What, err.Err)
+}
+
+// Unwrap returns the error of the writer.
+func (err *ErrEncoding) Unwrap() error {
+	return err.Err
+}
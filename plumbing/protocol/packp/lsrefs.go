@@ -0,0 +1,224 @@
+package packp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/pktline"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/capability"
+)
+
+// LsRefsCapability is the protocol v2 capability of the servers supporting
+// the ls-refs command.
+const LsRefsCapability = "ls-refs"
+
+// CapabilityAdvertisement values represent the capabilities advertised by
+// a protocol v2 server, right after its "version 2" pkt-line.
+type CapabilityAdvertisement struct {
+	// Capabilities are the values of the capabilities by name, empty for
+	// the capabilities without a value, e.g. "fetch": "shallow filter".
+	Capabilities map[string]string
+}
+
+// NewCapabilityAdvertisement returns a pointer to a new
+// CapabilityAdvertisement value, ready to be used.
+func NewCapabilityAdvertisement() *CapabilityAdvertisement {
+	return &CapabilityAdvertisement{Capabilities: make(map[string]string)}
+}
+
+// Supports returns true if the capability is advertised.
+func (a *CapabilityAdvertisement) Supports(name string) bool {
+	_, ok := a.Capabilities[name]
+	return ok
+}
+
+// Decode reads the "version 2" pkt-line and the capabilities, until a
+// flush-pkt. It does not read more input than that.
+func (a *CapabilityAdvertisement) Decode(r io.Reader) error {
+	s := pktline.NewScanner(r)
+	if !s.Scan() {
+		if err := s.Err(); err != nil {
+			return err
+		}
+
+		return ErrEmptyInput
+	}
+
+	if b := bytes.TrimSuffix(s.Bytes(), eol); !bytes.Equal(b, version2) {
+		return &ErrUnexpectedData{Msg: "expecting version 2", Data: b, Line: 1}
+	}
+
+	for s.Scan() {
+		b := bytes.TrimSuffix(s.Bytes(), eol)
+		if isFlush(b) {
+			return nil
+		}
+
+		name, value := string(b), ""
+		if i := bytes.IndexByte(b, '='); i != -1 {
+			name, value = string(b[:i]), string(b[i+1:])
+		}
+
+		a.Capabilities[name] = value
+	}
+
+	if err := s.Err(); err != nil {
+		return err
+	}
+
+	return io.ErrUnexpectedEOF
+}
+
+// LsRefsRequest values represent the arguments of the ls-refs command of
+// the protocol v2, listing the references of the server.
+type LsRefsRequest struct {
+	// Prefixes, if any, restrict the listing to the references starting
+	// with any of them, e.g. refs/heads/ or HEAD, saving the servers with
+	// lots of references from sending all of them.
+	Prefixes []string
+	// Symrefs asks for the targets of the symbolic references.
+	Symrefs bool
+	// Peel asks for the objects the annotated tags point to.
+	Peel bool
+}
+
+// NewLsRefsRequest returns a pointer to a new LsRefsRequest value, asking
+// for the symbolic references and the peeled tags.
+func NewLsRefsRequest() *LsRefsRequest {
+	return &LsRefsRequest{Symrefs: true, Peel: true}
+}
+
+// Encode writes the ls-refs command with its arguments.
+func (r *LsRefsRequest) Encode(w io.Writer) error {
+	e := pktline.NewEncoder(w)
+	if err := e.Encode(lsRefs); err != nil {
+		return err
+	}
+
+	if err := e.Delim(); err != nil {
+		return err
+	}
+
+	if r.Symrefs {
+		if err := e.EncodeString("symrefs\n"); err != nil {
+			return err
+		}
+	}
+
+	if r.Peel {
+		if err := e.EncodeString("peel\n"); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range r.Prefixes {
+		if err := e.Line().AppendBytes(refPrefix).AppendString(p).AppendByte('\n').End(); err != nil {
+			return &ErrEncoding{What: fmt.Sprintf("ref-prefix %q", p), Err: err}
+		}
+	}
+
+	return e.Flush()
+}
+
+// Match returns true if the reference name starts with any of the prefixes
+// of the request, or if it has none.
+func (r *LsRefsRequest) Match(name string) bool {
+	if len(r.Prefixes) == 0 {
+		return true
+	}
+
+	for _, p := range r.Prefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FilterAdvRefs removes from the advertised references the ones not
+// matching the request, as a protocol v2 server does. This is meant to be
+// used with the servers not supporting the ls-refs command.
+func (r *LsRefsRequest) FilterAdvRefs(a *AdvRefs) {
+	if len(r.Prefixes) == 0 {
+		return
+	}
+
+	for name := range a.References {
+		if !r.Match(name) {
+			delete(a.References, name)
+		}
+	}
+
+	for name := range a.Peeled {
+		if !r.Match(name) {
+			delete(a.Peeled, name)
+		}
+	}
+
+	if a.Head != nil && !r.Match(head) {
+		a.Head = nil
+	}
+
+	symrefs := a.Capabilities.Get(capability.SymRef)
+	a.Capabilities.Delete(capability.SymRef)
+	for _, symref := range symrefs {
+		if r.Match(strings.SplitN(symref, ":", 2)[0]) {
+			_ = a.Capabilities.Add(capability.SymRef, symref)
+		}
+	}
+}
+
+// DecodeLsRefs reads the references listed by the ls-refs command, until a
+// flush-pkt, into the advertised references: the hash references, HEAD, the
+// targets of the symbolic references as symref capabilities and the peeled
+// tags.
+func (a *AdvRefs) DecodeLsRefs(r io.Reader) error {
+	s := pktline.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		b := bytes.TrimSuffix(s.Bytes(), eol)
+		if isFlush(b) {
+			return nil
+		}
+
+		fields := bytes.Split(b, sp)
+		if len(fields) < 2 || len(fields[0]) != hashSize {
+			return &ErrUnexpectedData{Msg: "malformed ls-refs line", Data: b, Line: line}
+		}
+
+		hash := plumbing.NewHash(string(fields[0]))
+		name := string(fields[1])
+
+		var target string
+		for _, attr := range fields[2:] {
+			switch {
+			case bytes.HasPrefix(attr, symrefTarget):
+				target = string(attr[len(symrefTarget):])
+			case bytes.HasPrefix(attr, peeledAttr):
+				a.Peeled[name] = plumbing.NewHash(string(attr[len(peeledAttr):]))
+			}
+		}
+
+		if target != "" {
+			if err := a.Capabilities.Add(capability.SymRef, name+":"+target); err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case name == head:
+			a.Head = &hash
+		case target == "":
+			a.References[name] = hash
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return err
+	}
+
+	return io.ErrUnexpectedEOF
+}
@@ -0,0 +1,144 @@
+package packp
+
+import (
+	"bytes"
+	"io"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/pktline"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/capability"
+
+	. "gopkg.in/check.v1"
+)
+
+type LsRefsSuite struct{}
+
+var _ = Suite(&LsRefsSuite{})
+
+func (s *LsRefsSuite) TestCapabilityAdvertisementDecode(c *C) {
+	r := toPktLines(c, []string{
+		"version 2\n",
+		"agent=git/2.39.5\n",
+		"ls-refs=unborn\n",
+		"fetch=shallow wait-for-done\n",
+		"server-option\n",
+		pktline.FlushString,
+	})
+
+	a := NewCapabilityAdvertisement()
+	c.Assert(a.Decode(r), IsNil)
+	c.Assert(a.Capabilities, DeepEquals, map[string]string{
+		"agent":         "git/2.39.5",
+		"ls-refs":       "unborn",
+		"fetch":         "shallow wait-for-done",
+		"server-option": "",
+	})
+
+	c.Assert(a.Supports(LsRefsCapability), Equals, true)
+	c.Assert(a.Supports("object-info"), Equals, false)
+}
+
+func (s *LsRefsSuite) TestCapabilityAdvertisementDecodeErrors(c *C) {
+	a := NewCapabilityAdvertisement()
+	c.Assert(a.Decode(bytes.NewBuffer(nil)), Equals, ErrEmptyInput)
+
+	a = NewCapabilityAdvertisement()
+	err := a.Decode(toPktLines(c, []string{"version 1\n", pktline.FlushString}))
+	c.Assert(err, ErrorMatches, ".*expecting version 2.*")
+
+	a = NewCapabilityAdvertisement()
+	err = a.Decode(toPktLines(c, []string{"version 2\n", "ls-refs\n"}))
+	c.Assert(err, Equals, io.ErrUnexpectedEOF)
+}
+
+func (s *LsRefsSuite) TestRequestEncode(c *C) {
+	req := NewLsRefsRequest()
+	req.Prefixes = []string{"HEAD", "refs/heads/"}
+
+	var buf bytes.Buffer
+	c.Assert(req.Encode(&buf), IsNil)
+
+	expected := bytes.NewBuffer(nil)
+	e := pktline.NewEncoder(expected)
+	c.Assert(e.EncodeString("command=ls-refs\n"), IsNil)
+	c.Assert(e.Delim(), IsNil)
+	c.Assert(e.EncodeString(
+		"symrefs\n",
+		"peel\n",
+		"ref-prefix HEAD\n",
+		"ref-prefix refs/heads/\n",
+		pktline.FlushString,
+	), IsNil)
+
+	c.Assert(buf.String(), Equals, expected.String())
+}
+
+func (s *LsRefsSuite) TestRequestMatch(c *C) {
+	req := &LsRefsRequest{}
+	c.Assert(req.Match("refs/pull/1/head"), Equals, true)
+
+	req.Prefixes = []string{"HEAD", "refs/heads/"}
+	c.Assert(req.Match("HEAD"), Equals, true)
+	c.Assert(req.Match("refs/heads/master"), Equals, true)
+	c.Assert(req.Match("refs/tags/v1.0.0"), Equals, false)
+}
+
+func (s *LsRefsSuite) TestFilterAdvRefs(c *C) {
+	head := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	tag := plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d")
+
+	a := NewAdvRefs()
+	a.Head = &head
+	a.References["refs/heads/master"] = head
+	a.References["refs/tags/v1.0.0"] = tag
+	a.Peeled["refs/tags/v1.0.0"] = head
+	c.Assert(a.Capabilities.Add(capability.SymRef, "HEAD:refs/heads/master"), IsNil)
+	c.Assert(a.Capabilities.Add(capability.OFSDelta), IsNil)
+
+	req := &LsRefsRequest{Prefixes: []string{"refs/tags/"}}
+	req.FilterAdvRefs(a)
+
+	c.Assert(a.Head, IsNil)
+	c.Assert(a.References, DeepEquals, map[string]plumbing.Hash{"refs/tags/v1.0.0": tag})
+	c.Assert(a.Peeled, DeepEquals, map[string]plumbing.Hash{"refs/tags/v1.0.0": head})
+	c.Assert(a.Capabilities.Supports(capability.SymRef), Equals, false)
+	c.Assert(a.Capabilities.Supports(capability.OFSDelta), Equals, true)
+}
+
+func (s *LsRefsSuite) TestDecodeLsRefs(c *C) {
+	head := "6ecf0ef2c2dffb796033e5a02219af86ec6584e5"
+	tag := "b029517f6300c2da0f4b651b8642506cd6aaf45d"
+
+	r := toPktLines(c, []string{
+		head + " HEAD symref-target:refs/heads/master\n",
+		head + " refs/heads/master\n",
+		head + " refs/remotes/origin/HEAD symref-target:refs/remotes/origin/master\n",
+		tag + " refs/tags/v1.0.0 peeled:" + head + "\n",
+		pktline.FlushString,
+	})
+
+	a := NewAdvRefs()
+	c.Assert(a.DecodeLsRefs(r), IsNil)
+	c.Assert(a.Head.String(), Equals, head)
+	c.Assert(a.References, DeepEquals, map[string]plumbing.Hash{
+		"refs/heads/master": plumbing.NewHash(head),
+		"refs/tags/v1.0.0":  plumbing.NewHash(tag),
+	})
+	c.Assert(a.Peeled, DeepEquals, map[string]plumbing.Hash{
+		"refs/tags/v1.0.0": plumbing.NewHash(head),
+	})
+	c.Assert(a.Capabilities.Get(capability.SymRef), DeepEquals, []string{
+		"HEAD:refs/heads/master",
+		"refs/remotes/origin/HEAD:refs/remotes/origin/master",
+	})
+}
+
+func (s *LsRefsSuite) TestDecodeLsRefsMalformed(c *C) {
+	a := NewAdvRefs()
+	err := a.DecodeLsRefs(toPktLines(c, []string{"foo\n", pktline.FlushString}))
+	c.Assert(err, ErrorMatches, ".*malformed ls-refs line.*")
+
+	a = NewAdvRefs()
+	err = a.DecodeLsRefs(toPktLines(c, []string{}))
+	c.Assert(err, Equals, io.ErrUnexpectedEOF)
+}
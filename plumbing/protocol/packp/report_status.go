@@ -29,7 +29,7 @@ func NewReportStatus() *ReportStatus {
 // Error returns the first error if any.
 func (s *ReportStatus) Error() error {
 	if s.UnpackStatus != ok {
-		return fmt.Errorf("unpack error: %s", s.UnpackStatus)
+		return &ErrUnpackStatus{Status: s.UnpackStatus}
 	}
 
 	for _, s := range s.CommandStatuses {
@@ -151,8 +151,30 @@ func (s *CommandStatus) Error() error {
 		return nil
 	}
 
-	return fmt.Errorf("command error on %s: %s",
-		s.ReferenceName.String(), s.Status)
+	return &ErrCommandStatus{ReferenceName: s.ReferenceName, Status: s.Status}
+}
+
+// ErrUnpackStatus is the error of a report status whose packfile failed to be
+// unpacked by the server.
+type ErrUnpackStatus struct {
+	// Status is the unpack status sent by the server.
+	Status string
+}
+
+func (err *ErrUnpackStatus) Error() string {
+	return fmt.Sprintf("unpack error: %s", err.Status)
+}
+
+// ErrCommandStatus is the error of a reference the server failed to update,
+// such as a rejected non-fast-forward update.
+type ErrCommandStatus struct {
+	ReferenceName plumbing.ReferenceName
+	// Status is the reason sent by the server.
+	Status string
+}
+
+func (err *ErrCommandStatus) Error() string {
+	return fmt.Sprintf("command error on %s: %s", err.ReferenceName, err.Status)
 }
 
 func (s *CommandStatus) encode(w io.Writer) error {
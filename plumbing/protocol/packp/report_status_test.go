@@ -32,6 +32,12 @@ func (s *ReportStatusSuite) TestError(c *C) {
 	c.Assert(rs.Error(), ErrorMatches, "command error on ref: OK")
 	cs.Status = ""
 	c.Assert(rs.Error(), ErrorMatches, "command error on ref: ")
+
+	cs.Status = "non-fast-forward"
+	csErr, ok := rs.Error().(*ErrCommandStatus)
+	c.Assert(ok, Equals, true)
+	c.Assert(csErr.ReferenceName, Equals, plumbing.ReferenceName("ref"))
+	c.Assert(csErr.Status, Equals, "non-fast-forward")
 }
 
 func (s *ReportStatusSuite) testEncodeDecodeOk(c *C, rs *ReportStatus, lines ...string) {
@@ -77,13 +77,13 @@ func (r *ShallowUpdate) Encode(w io.Writer) error {
 	e := pktline.NewEncoder(w)
 
 	for _, h := range r.Shallows {
-		if err := e.Encodef("%s%s\n", shallow, h.String()); err != nil {
+		if err := e.Line().AppendBytes(shallow).AppendHex(h[:]).AppendByte('\n').End(); err != nil {
 			return err
 		}
 	}
 
 	for _, h := range r.Unshallows {
-		if err := e.Encodef("%s%s\n", unshallow, h.String()); err != nil {
+		if err := e.Line().AppendBytes(unshallow).AppendHex(h[:]).AppendByte('\n').End(); err != nil {
 			return err
 		}
 	}
@@ -123,5 +123,6 @@ func (r *ServerResponse) Encode(w io.Writer) error {
 		return e.Encodef("%s\n", nak)
 	}
 
-	return e.Encodef("%s %s\n", ack, r.ACKs[0].String())
+	h := r.ACKs[0]
+	return e.Line().AppendBytes(ack).AppendByte(' ').AppendHex(h[:]).AppendByte('\n').End()
 }
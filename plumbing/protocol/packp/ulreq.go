@@ -17,6 +17,7 @@ type UploadRequest struct {
 	Wants        []plumbing.Hash
 	Shallows     []plumbing.Hash
 	Depth        Depth
+	Filter       Filter
 }
 
 // Depth values stores the desired depth of the requested packfile: see
@@ -55,6 +56,26 @@ func (d DepthReference) IsZero() bool {
 	return string(d) == ""
 }
 
+// Filter values stores the filter-spec of a partial clone or fetch, asking
+// the server to omit some objects from the packfile: see FilterBlobNone,
+// FilterBlobLimit and FilterTreeDepth. The empty value means no filter.
+type Filter string
+
+// FilterBlobNone requests the packfile to omit all the blobs.
+const FilterBlobNone Filter = "blob:none"
+
+// FilterBlobLimit requests the packfile to omit the blobs of size at least
+// limit bytes.
+func FilterBlobLimit(limit int64) Filter {
+	return Filter(fmt.Sprintf("blob:limit=%d", limit))
+}
+
+// FilterTreeDepth requests the packfile to omit the blobs and trees whose
+// depth from the root tree is at least depth, a depth of 0 omits all of them.
+func FilterTreeDepth(depth int) Filter {
+	return Filter(fmt.Sprintf("tree:depth=%d", depth))
+}
+
 // NewUploadRequest returns a pointer to a new UploadRequest value, ready to be
 // used. It has no capabilities, wants or shallows and an infinite depth. Please
 // note that to encode an upload-request it has to have at least one wanted hash.
@@ -107,6 +128,7 @@ func NewUploadRequestFromCapabilities(adv *capability.List) *UploadRequest {
 //   - is a non-zero DepthCommits is given capability.Shallow MUST be present
 //   - is a DepthSince is given capability.Shallow MUST be present
 //   - is a DepthReference is given capability.DeepenNot MUST be present
+//   - is a Filter is given capability.Filter MUST be present
 //   - MUST contain only maximum of one of capability.Sideband and capability.Sideband64k
 //   - MUST contain only maximum of one of capability.MultiACK and capability.MultiACKDetailed
 func (r *UploadRequest) Validate() error {
@@ -149,6 +171,10 @@ func (r *UploadRequest) validateRequiredCapabilities() error {
 		}
 	}
 
+	if r.Filter != "" && !r.Capabilities.Supports(capability.Filter) {
+		return fmt.Errorf(msg, capability.Filter)
+	}
+
 	return nil
 }
 
@@ -50,7 +50,7 @@ func (d *ulReqDecoder) error(format string, a ...interface{}) {
 		fmt.Sprintf(format, a...),
 	)
 
-	d.err = NewErrUnexpectedData(msg, d.line)
+	d.err = &ErrUnexpectedData{Msg: msg, Data: d.line, Line: d.nLine}
 }
 
 // Reads a new pkt-line from the scanner, makes its payload available as
@@ -136,6 +136,10 @@ func (d *ulReqDecoder) decodeOtherWants() stateFn {
 		return d.decodeDeepen
 	}
 
+	if bytes.HasPrefix(d.line, filter) {
+		return d.decodeFilter
+	}
+
 	if len(d.line) == 0 {
 		return nil
 	}
@@ -161,6 +165,10 @@ func (d *ulReqDecoder) decodeShallow() stateFn {
 		return d.decodeDeepen
 	}
 
+	if bytes.HasPrefix(d.line, filter) {
+		return d.decodeFilter
+	}
+
 	if len(d.line) == 0 {
 		return nil
 	}
@@ -219,7 +227,7 @@ func (d *ulReqDecoder) decodeDeepenCommits() stateFn {
 	}
 	d.data.Depth = DepthCommits(n)
 
-	return d.decodeFlush
+	return d.decodeFilterOrFlush
 }
 
 func (d *ulReqDecoder) decodeDeepenSince() stateFn {
@@ -233,7 +241,7 @@ func (d *ulReqDecoder) decodeDeepenSince() stateFn {
 	t := time.Unix(secs, 0).UTC()
 	d.data.Depth = DepthSince(t)
 
-	return d.decodeFlush
+	return d.decodeFilterOrFlush
 }
 
 func (d *ulReqDecoder) decodeDeepenReference() stateFn {
@@ -241,9 +249,38 @@ func (d *ulReqDecoder) decodeDeepenReference() stateFn {
 
 	d.data.Depth = DepthReference(string(d.line))
 
+	return d.decodeFilterOrFlush
+}
+
+// Expected format: filter <filter-spec>
+func (d *ulReqDecoder) decodeFilter() stateFn {
+	d.line = bytes.TrimPrefix(d.line, filter)
+	if len(d.line) == 0 {
+		d.error("empty filter specification")
+		return nil
+	}
+
+	d.data.Filter = Filter(d.line)
+
 	return d.decodeFlush
 }
 
+func (d *ulReqDecoder) decodeFilterOrFlush() stateFn {
+	if ok := d.nextLine(); !ok {
+		return nil
+	}
+
+	if bytes.HasPrefix(d.line, filter) {
+		return d.decodeFilter
+	}
+
+	if len(d.line) != 0 {
+		d.err = fmt.Errorf("unexpected payload while expecting a flush-pkt: %q", d.line)
+	}
+
+	return nil
+}
+
 func (d *ulReqDecoder) decodeFlush() stateFn {
 	if ok := d.nextLine(); !ok {
 		return nil
@@ -477,6 +477,40 @@ func (s *UlReqDecodeSuite) TestDeepenReference(c *C) {
 	c.Assert(string(reference), Equals, expected)
 }
 
+func (s *UlReqDecodeSuite) TestFilter(c *C) {
+	payloads := []string{
+		"want 3333333333333333333333333333333333333333 ofs-delta filter",
+		"filter blob:none",
+		pktline.FlushString,
+	}
+	ur := s.testDecodeOK(c, payloads)
+	c.Assert(ur.Filter, Equals, FilterBlobNone)
+	c.Assert(ur.Depth, Equals, DepthCommits(0))
+}
+
+func (s *UlReqDecodeSuite) TestFilterAfterDeepen(c *C) {
+	payloads := []string{
+		"want 3333333333333333333333333333333333333333 ofs-delta filter",
+		"shallow aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		"deepen 2",
+		"filter tree:depth=1",
+		pktline.FlushString,
+	}
+	ur := s.testDecodeOK(c, payloads)
+	c.Assert(ur.Filter, Equals, FilterTreeDepth(1))
+	c.Assert(ur.Depth, Equals, DepthCommits(2))
+}
+
+func (s *UlReqDecodeSuite) TestFilterEmpty(c *C) {
+	payloads := []string{
+		"want 3333333333333333333333333333333333333333 ofs-delta filter",
+		"filter ",
+		pktline.FlushString,
+	}
+	r := toPktLines(c, payloads)
+	s.testDecoderErrorMatches(c, r, ".*empty filter.*")
+}
+
 func (s *UlReqDecodeSuite) TestAll(c *C) {
 	payloads := []string{
 		"want 3333333333333333333333333333333333333333 ofs-delta multi_ack",
@@ -2,6 +2,7 @@ package packp
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"time"
@@ -14,12 +15,20 @@ import (
 //
 // All the payloads will end with a newline character.  Wants and
 // shallows are sorted alphabetically.  A depth of 0 means no depth
-// request is sent.
+// request is sent, as an empty filter means no filter request is sent.
 func (u *UploadRequest) Encode(w io.Writer) error {
 	e := newUlReqEncoder(w)
 	return e.Encode(u)
 }
 
+var (
+	// ErrEmptyWants is returned by Encode if the request has no wants.
+	ErrEmptyWants = errors.New("empty wants provided")
+	// ErrUnsupportedDepth is returned by Encode if the depth of the request
+	// is not one of the supported Depth types.
+	ErrUnsupportedDepth = errors.New("unsupported depth type")
+)
+
 type ulReqEncoder struct {
 	pe   *pktline.Encoder // where to write the encoded data
 	data *UploadRequest   // the data to encode
@@ -36,7 +45,7 @@ func (e *ulReqEncoder) Encode(v *UploadRequest) error {
 	e.data = v
 
 	if len(v.Wants) == 0 {
-		return fmt.Errorf("empty wants provided")
+		return ErrEmptyWants
 	}
 
 	plumbing.HashesSort(e.data.Wants)
@@ -48,19 +57,13 @@ func (e *ulReqEncoder) Encode(v *UploadRequest) error {
 }
 
 func (e *ulReqEncoder) encodeFirstWant() stateFn {
-	var err error
-	if e.data.Capabilities.IsEmpty() {
-		err = e.pe.Encodef("want %s\n", e.data.Wants[0])
-	} else {
-		err = e.pe.Encodef(
-			"want %s %s\n",
-			e.data.Wants[0],
-			e.data.Capabilities.String(),
-		)
+	l := e.pe.Line().AppendString("want ").AppendHex(e.data.Wants[0][:])
+	if !e.data.Capabilities.IsEmpty() {
+		l.AppendByte(' ').AppendString(e.data.Capabilities.String())
 	}
 
-	if err != nil {
-		e.err = fmt.Errorf("encoding first want line: %s", err)
+	if err := l.AppendByte('\n').End(); err != nil {
+		e.err = &ErrEncoding{What: "first want line", Err: err}
 		return nil
 	}
 
@@ -74,8 +77,8 @@ func (e *ulReqEncoder) encodeAditionalWants() stateFn {
 			continue
 		}
 
-		if err := e.pe.Encodef("want %s\n", w); err != nil {
-			e.err = fmt.Errorf("encoding want %q: %s", w, err)
+		if err := e.pe.Line().AppendString("want ").AppendHex(w[:]).AppendByte('\n').End(); err != nil {
+			e.err = &ErrEncoding{What: fmt.Sprintf("want %q", w), Err: err}
 			return nil
 		}
 
@@ -94,8 +97,8 @@ func (e *ulReqEncoder) encodeShallows() stateFn {
 			continue
 		}
 
-		if err := e.pe.Encodef("shallow %s\n", s); err != nil {
-			e.err = fmt.Errorf("encoding shallow %q: %s", s, err)
+		if err := e.pe.Line().AppendString("shallow ").AppendHex(s[:]).AppendByte('\n').End(); err != nil {
+			e.err = &ErrEncoding{What: fmt.Sprintf("shallow %q", s), Err: err}
 			return nil
 		}
 
@@ -109,35 +112,48 @@ func (e *ulReqEncoder) encodeDepth() stateFn {
 	switch depth := e.data.Depth.(type) {
 	case DepthCommits:
 		if depth != 0 {
-			commits := int(depth)
-			if err := e.pe.Encodef("deepen %d\n", commits); err != nil {
-				e.err = fmt.Errorf("encoding depth %d: %s", depth, err)
+			l := e.pe.Line().AppendString("deepen ").AppendInt(int64(depth))
+			if err := l.AppendByte('\n').End(); err != nil {
+				e.err = &ErrEncoding{What: fmt.Sprintf("depth %d", depth), Err: err}
 				return nil
 			}
 		}
 	case DepthSince:
 		when := time.Time(depth).UTC()
-		if err := e.pe.Encodef("deepen-since %d\n", when.Unix()); err != nil {
-			e.err = fmt.Errorf("encoding depth %s: %s", when, err)
+		l := e.pe.Line().AppendString("deepen-since ").AppendInt(when.Unix())
+		if err := l.AppendByte('\n').End(); err != nil {
+			e.err = &ErrEncoding{What: fmt.Sprintf("depth %s", when), Err: err}
 			return nil
 		}
 	case DepthReference:
 		reference := string(depth)
 		if err := e.pe.Encodef("deepen-not %s\n", reference); err != nil {
-			e.err = fmt.Errorf("encoding depth %s: %s", reference, err)
+			e.err = &ErrEncoding{What: fmt.Sprintf("depth %s", reference), Err: err}
 			return nil
 		}
 	default:
-		e.err = fmt.Errorf("unsupported depth type")
+		e.err = ErrUnsupportedDepth
 		return nil
 	}
 
+	return e.encodeFilter
+}
+
+func (e *ulReqEncoder) encodeFilter() stateFn {
+	if f := e.data.Filter; f != "" {
+		l := e.pe.Line().AppendString("filter ").AppendString(string(f))
+		if err := l.AppendByte('\n').End(); err != nil {
+			e.err = &ErrEncoding{What: fmt.Sprintf("filter %s", f), Err: err}
+			return nil
+		}
+	}
+
 	return e.encodeFlush
 }
 
 func (e *ulReqEncoder) encodeFlush() stateFn {
 	if err := e.pe.Flush(); err != nil {
-		e.err = fmt.Errorf("encoding flush-pkt: %s", err)
+		e.err = &ErrEncoding{What: "flush-pkt", Err: err}
 		return nil
 	}
 
@@ -2,6 +2,9 @@ package packp
 
 import (
 	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
 	"time"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
@@ -45,6 +48,23 @@ func (s *UlReqEncodeSuite) TestZeroValue(c *C) {
 	testUlReqEncodeError(c, ur, expectedErrorRegEx)
 }
 
+func (s *UlReqEncodeSuite) TestEncodingError(c *C) {
+	ur := NewUploadRequest()
+	ur.Wants = append(ur.Wants, plumbing.NewHash("1111111111111111111111111111111111111111"))
+
+	err := ur.Encode(&failingWriter{})
+	c.Assert(err, ErrorMatches, "encoding first want line: .*")
+
+	encErr, ok := err.(*ErrEncoding)
+	c.Assert(ok, Equals, true)
+	c.Assert(encErr.What, Equals, "first want line")
+	c.Assert(encErr.Err, Equals, io.ErrClosedPipe)
+}
+
+type failingWriter struct{}
+
+func (*failingWriter) Write([]byte) (int, error) { return 0, io.ErrClosedPipe }
+
 func (s *UlReqEncodeSuite) TestOneWant(c *C) {
 	ur := NewUploadRequest()
 	ur.Wants = append(ur.Wants, plumbing.NewHash("1111111111111111111111111111111111111111"))
@@ -268,6 +288,22 @@ func (s *UlReqEncodeSuite) TestDepthReference(c *C) {
 	testUlReqEncode(c, ur, expected)
 }
 
+func (s *UlReqEncodeSuite) TestFilter(c *C) {
+	ur := NewUploadRequest()
+	ur.Wants = append(ur.Wants, plumbing.NewHash("1111111111111111111111111111111111111111"))
+	ur.Depth = DepthCommits(1)
+	ur.Filter = FilterBlobLimit(1024)
+
+	expected := []string{
+		"want 1111111111111111111111111111111111111111\n",
+		"deepen 1\n",
+		"filter blob:limit=1024\n",
+		pktline.FlushString,
+	}
+
+	testUlReqEncode(c, ur, expected)
+}
+
 func (s *UlReqEncodeSuite) TestAll(c *C) {
 	ur := NewUploadRequest()
 	ur.Wants = append(ur.Wants,
@@ -308,3 +344,19 @@ func (s *UlReqEncodeSuite) TestAll(c *C) {
 
 	testUlReqEncode(c, ur, expected)
 }
+
+func BenchmarkUploadRequestEncode(b *testing.B) {
+	ur := NewUploadRequest()
+	for i := 0; i < 256; i++ {
+		var h plumbing.Hash
+		h[0], h[1] = byte(i), byte(i>>8)
+		ur.Wants = append(ur.Wants, h)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := ur.Encode(ioutil.Discard); err != nil {
+			b.Fatalf("unexpected error: %s", err)
+		}
+	}
+}
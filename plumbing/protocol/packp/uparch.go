@@ -0,0 +1,141 @@
+package packp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/format/pktline"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/sideband"
+)
+
+// UploadArchiveRequest values represent the information transmitted on a
+// git-upload-archive request: the git-archive arguments, e.g. --format=tar,
+// --prefix=foo/, the tree-ish and the paths, in the same order.
+type UploadArchiveRequest struct {
+	Arguments []string
+	// Progress receives sideband progress messages from the server
+	Progress sideband.Progress
+}
+
+// NewUploadArchiveRequest returns a pointer to a new UploadArchiveRequest
+// value, without arguments.
+func NewUploadArchiveRequest() *UploadArchiveRequest {
+	return &UploadArchiveRequest{}
+}
+
+// Encode writes the arguments of the request, one argument pkt-line each,
+// followed by a flush-pkt.
+func (r *UploadArchiveRequest) Encode(w io.Writer) error {
+	e := pktline.NewEncoder(w)
+	for _, arg := range r.Arguments {
+		if err := e.Line().AppendBytes(argument).AppendString(arg).AppendByte('\n').End(); err != nil {
+			return &ErrEncoding{What: fmt.Sprintf("argument %q", arg), Err: err}
+		}
+	}
+
+	return e.Flush()
+}
+
+// Decode reads the arguments of the request until a flush-pkt. It does not
+// read more input than that.
+func (r *UploadArchiveRequest) Decode(reader io.Reader) error {
+	s := pktline.NewScanner(reader)
+	for line := 1; s.Scan(); line++ {
+		b := s.Bytes()
+		if isFlush(b) {
+			return nil
+		}
+
+		if !bytes.HasPrefix(b, argument) {
+			return &ErrUnexpectedData{Msg: "expecting an argument", Data: b, Line: line}
+		}
+
+		arg := bytes.TrimSuffix(bytes.TrimPrefix(b, argument), eol)
+		r.Arguments = append(r.Arguments, string(arg))
+	}
+
+	if err := s.Err(); err != nil {
+		return err
+	}
+
+	return io.ErrUnexpectedEOF
+}
+
+// UploadArchiveStatus is the status sent by the git-upload-archive server
+// before the archive. An empty Error means the archive follows, multiplexed
+// in a sideband.Sideband64k stream ending with a flush-pkt.
+type UploadArchiveStatus struct {
+	Error string
+}
+
+// Err returns the error of the status, nil if the archive follows.
+func (s *UploadArchiveStatus) Err() error {
+	if s.Error == "" {
+		return nil
+	}
+
+	return fmt.Errorf("remote error: %s", s.Error)
+}
+
+// Encode writes an ACK pkt-line, or a NACK one with the Error, followed by
+// a flush-pkt.
+func (s *UploadArchiveStatus) Encode(w io.Writer) error {
+	e := pktline.NewEncoder(w)
+
+	var err error
+	if s.Error == "" {
+		err = e.Encodef("%s\n", ack)
+	} else {
+		err = e.Encodef("%s%s\n", nack, s.Error)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return e.Flush()
+}
+
+// Decode reads the status sent by the server, an ERR pkt-line is decoded as
+// a NACK one. It returns ErrEmptyInput if there is no input at all, as the
+// servers do when the repository is not found, and it does not read more
+// input than the status.
+func (s *UploadArchiveStatus) Decode(r io.Reader) error {
+	scan := pktline.NewScanner(r)
+	if !scan.Scan() {
+		if err := scan.Err(); err != nil {
+			return err
+		}
+
+		return ErrEmptyInput
+	}
+
+	b := bytes.TrimSuffix(scan.Bytes(), eol)
+	switch {
+	case bytes.Equal(b, ack):
+		s.Error = ""
+	case bytes.HasPrefix(b, nack):
+		s.Error = string(b[len(nack):])
+		return nil
+	case bytes.HasPrefix(b, errPrefix):
+		s.Error = string(b[len(errPrefix):])
+		return nil
+	default:
+		return &ErrUnexpectedData{Msg: "expecting ACK or NACK", Data: b, Line: 1}
+	}
+
+	if !scan.Scan() {
+		if err := scan.Err(); err != nil {
+			return err
+		}
+
+		return io.ErrUnexpectedEOF
+	}
+
+	if !isFlush(scan.Bytes()) {
+		return &ErrUnexpectedData{Msg: "expecting a flush-pkt", Data: scan.Bytes(), Line: 2}
+	}
+
+	return nil
+}
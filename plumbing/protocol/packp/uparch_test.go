@@ -0,0 +1,80 @@
+package packp
+
+import (
+	"bytes"
+	"io"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/format/pktline"
+
+	. "gopkg.in/check.v1"
+)
+
+type UploadArchiveSuite struct{}
+
+var _ = Suite(&UploadArchiveSuite{})
+
+func (s *UploadArchiveSuite) TestRequestEncode(c *C) {
+	req := NewUploadArchiveRequest()
+	req.Arguments = []string{"--format=tar", "master", "--", "vendor"}
+
+	var buf bytes.Buffer
+	c.Assert(req.Encode(&buf), IsNil)
+	c.Assert(buf.Bytes(), DeepEquals, pktlines(c,
+		"argument --format=tar\n",
+		"argument master\n",
+		"argument --\n",
+		"argument vendor\n",
+		pktline.FlushString,
+	))
+}
+
+func (s *UploadArchiveSuite) TestRequestDecode(c *C) {
+	r := toPktLines(c, []string{
+		"argument --prefix=foo/\n",
+		"argument HEAD\n",
+		pktline.FlushString,
+		"trailing",
+	})
+
+	req := NewUploadArchiveRequest()
+	c.Assert(req.Decode(r), IsNil)
+	c.Assert(req.Arguments, DeepEquals, []string{"--prefix=foo/", "HEAD"})
+}
+
+func (s *UploadArchiveSuite) TestRequestDecodeErrors(c *C) {
+	req := NewUploadArchiveRequest()
+	err := req.Decode(toPktLines(c, []string{"foo\n", pktline.FlushString}))
+	c.Assert(err, ErrorMatches, "(?s).*expecting an argument.*")
+
+	req = NewUploadArchiveRequest()
+	err = req.Decode(toPktLines(c, []string{"argument HEAD\n"}))
+	c.Assert(err, Equals, io.ErrUnexpectedEOF)
+}
+
+func (s *UploadArchiveSuite) TestStatusEncodeDecode(c *C) {
+	for _, st := range []*UploadArchiveStatus{
+		{},
+		{Error: "unknown revision"},
+	} {
+		var buf bytes.Buffer
+		c.Assert(st.Encode(&buf), IsNil)
+
+		decoded := &UploadArchiveStatus{}
+		c.Assert(decoded.Decode(&buf), IsNil)
+		c.Assert(decoded, DeepEquals, st)
+	}
+}
+
+func (s *UploadArchiveSuite) TestStatusErr(c *C) {
+	c.Assert((&UploadArchiveStatus{}).Err(), IsNil)
+	c.Assert((&UploadArchiveStatus{Error: "foo"}).Err(), ErrorMatches, "remote error: foo")
+}
+
+func (s *UploadArchiveSuite) TestStatusDecodeError(c *C) {
+	st := &UploadArchiveStatus{}
+	c.Assert(st.Decode(toPktLines(c, []string{"ERR access denied\n"})), IsNil)
+	c.Assert(st.Error, Equals, "access denied")
+
+	c.Assert(st.Decode(bytes.NewBuffer(nil)), Equals, ErrEmptyInput)
+	c.Assert(st.Decode(toPktLines(c, []string{"foo\n"})), ErrorMatches, ".*expecting ACK or NACK.*")
+}
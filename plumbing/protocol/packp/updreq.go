@@ -20,6 +20,9 @@ type ReferenceUpdateRequest struct {
 	Capabilities *capability.List
 	Commands     []*Command
 	Shallow      *plumbing.Hash
+	// Options are the push options sent after the commands, as `git push
+	// --push-option` does, if the request has the push-options capability.
+	Options []string
 	// Packfile contains an optional packfile reader.
 	Packfile io.ReadCloser
 
@@ -7,6 +7,7 @@ import (
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/pktline"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/capability"
 
 	. "gopkg.in/check.v1"
 )
@@ -167,6 +168,44 @@ func (s *UpdReqDecodeSuite) TestOneUpdateCommand(c *C) {
 	s.testDecodeOkExpected(c, expected, payloads)
 }
 
+func (s *UpdReqDecodeSuite) TestPushOptions(c *C) {
+	hash1 := plumbing.NewHash("1ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	hash2 := plumbing.NewHash("2ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	name := plumbing.ReferenceName("refs/for/master")
+
+	expected := NewReferenceUpdateRequest()
+	expected.Commands = []*Command{
+		{Name: name, Old: hash1, New: hash2},
+	}
+	expected.Capabilities.Set(capability.PushOptions)
+	expected.Options = []string{"topic=foo", "wip"}
+	expected.Packfile = ioutil.NopCloser(bytes.NewReader([]byte{}))
+
+	payloads := []string{
+		"1ecf0ef2c2dffb796033e5a02219af86ec6584e5 2ecf0ef2c2dffb796033e5a02219af86ec6584e5 refs/for/master\x00push-options",
+		pktline.FlushString,
+		"topic=foo",
+		"wip\n",
+		pktline.FlushString,
+	}
+
+	s.testDecodeOkExpected(c, expected, payloads)
+}
+
+func (s *UpdReqDecodeSuite) TestPushOptionsMissingFlush(c *C) {
+	payloads := []string{
+		"1ecf0ef2c2dffb796033e5a02219af86ec6584e5 2ecf0ef2c2dffb796033e5a02219af86ec6584e5 refs/for/master\x00push-options",
+		pktline.FlushString,
+		"topic=foo",
+	}
+
+	var buf bytes.Buffer
+	e := pktline.NewEncoder(&buf)
+	c.Assert(e.EncodeString(payloads...), IsNil)
+
+	s.testDecoderErrorMatches(c, &buf, ".*end of the push options")
+}
+
 func (s *UpdReqDecodeSuite) TestMultipleCommands(c *C) {
 	hash1 := plumbing.NewHash("1ecf0ef2c2dffb796033e5a02219af86ec6584e5")
 	hash2 := plumbing.NewHash("2ecf0ef2c2dffb796033e5a02219af86ec6584e5")
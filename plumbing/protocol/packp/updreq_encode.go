@@ -1,7 +1,6 @@
 package packp
 
 import (
-	"fmt"
 	"io"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
@@ -29,6 +28,12 @@ func (r *ReferenceUpdateRequest) Encode(w io.Writer) error {
 		return err
 	}
 
+	if r.Capabilities.Supports(capability.PushOptions) {
+		if err := r.encodeOptions(e, r.Options); err != nil {
+			return err
+		}
+	}
+
 	if r.Packfile != nil {
 		if _, err := io.Copy(w, r.Packfile); err != nil {
 			return err
@@ -47,20 +52,31 @@ func (r *ReferenceUpdateRequest) encodeShallow(e *pktline.Encoder,
 		return nil
 	}
 
-	objId := []byte(h.String())
-	return e.Encodef("%s%s", shallow, objId)
+	return e.Line().AppendBytes(shallow).AppendHex(h[:]).End()
 }
 
 func (r *ReferenceUpdateRequest) encodeCommands(e *pktline.Encoder,
 	cmds []*Command, cap *capability.List) error {
 
-	if err := e.Encodef("%s\x00%s",
-		formatCommand(cmds[0]), cap.String()); err != nil {
+	l := appendCommand(e.Line(), cmds[0])
+	if err := l.AppendByte(0).AppendString(cap.String()).End(); err != nil {
 		return err
 	}
 
 	for _, cmd := range cmds[1:] {
-		if err := e.Encodef(formatCommand(cmd)); err != nil {
+		if err := appendCommand(e.Line(), cmd).End(); err != nil {
+			return err
+		}
+	}
+
+	return e.Flush()
+}
+
+func (r *ReferenceUpdateRequest) encodeOptions(e *pktline.Encoder,
+	opts []string) error {
+
+	for _, opt := range opts {
+		if err := e.EncodeString(opt); err != nil {
 			return err
 		}
 	}
@@ -68,8 +84,9 @@ func (r *ReferenceUpdateRequest) encodeCommands(e *pktline.Encoder,
 	return e.Flush()
 }
 
-func formatCommand(cmd *Command) string {
-	o := cmd.Old.String()
-	n := cmd.New.String()
-	return fmt.Sprintf("%s %s %s", o, n, cmd.Name)
+// appendCommand appends the command, "<old> SP <new> SP <name>", to the
+// pkt-line.
+func appendCommand(l *pktline.LineWriter, cmd *Command) *pktline.LineWriter {
+	l.AppendHex(cmd.Old[:]).AppendByte(' ').AppendHex(cmd.New[:]).AppendByte(' ')
+	return l.AppendString(cmd.Name.String())
 }
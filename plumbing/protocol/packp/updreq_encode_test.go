@@ -5,6 +5,7 @@ import (
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/pktline"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/capability"
 
 	. "gopkg.in/check.v1"
 	"io/ioutil"
@@ -52,6 +53,29 @@ func (s *UpdReqEncodeSuite) TestOneUpdateCommand(c *C) {
 	s.testEncode(c, r, expected)
 }
 
+func (s *UpdReqEncodeSuite) TestPushOptions(c *C) {
+	hash1 := plumbing.NewHash("1ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	hash2 := plumbing.NewHash("2ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	name := plumbing.ReferenceName("refs/for/master")
+
+	r := NewReferenceUpdateRequest()
+	r.Commands = []*Command{
+		{Name: name, Old: hash1, New: hash2},
+	}
+	r.Capabilities.Set(capability.PushOptions)
+	r.Options = []string{"topic=foo", "wip"}
+
+	expected := pktlines(c,
+		"1ecf0ef2c2dffb796033e5a02219af86ec6584e5 2ecf0ef2c2dffb796033e5a02219af86ec6584e5 refs/for/master\x00push-options",
+		pktline.FlushString,
+		"topic=foo",
+		"wip",
+		pktline.FlushString,
+	)
+
+	s.testEncode(c, r, expected)
+}
+
 func (s *UpdReqEncodeSuite) TestMultipleCommands(c *C) {
 	hash1 := plumbing.NewHash("1ecf0ef2c2dffb796033e5a02219af86ec6584e5")
 	hash2 := plumbing.NewHash("2ecf0ef2c2dffb796033e5a02219af86ec6584e5")
@@ -81,7 +81,7 @@ func (u *UploadHaves) Encode(w io.Writer, flush bool) error {
 			continue
 		}
 
-		if err := e.Encodef("have %s\n", have); err != nil {
+		if err := e.Line().AppendString("have ").AppendHex(have[:]).AppendByte('\n').End(); err != nil {
 			return fmt.Errorf("sending haves for %q: %s", have, err)
 		}
 
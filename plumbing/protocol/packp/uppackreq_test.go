@@ -2,6 +2,8 @@ package packp
 
 import (
 	"bytes"
+	"io/ioutil"
+	"testing"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/capability"
@@ -67,3 +69,19 @@ func (s *UploadHavesSuite) TestEncode(c *C) {
 		"0000",
 	)
 }
+
+func BenchmarkUploadHavesEncode(b *testing.B) {
+	uh := &UploadHaves{}
+	for i := 0; i < 256; i++ {
+		var h plumbing.Hash
+		h[0], h[1] = byte(i), byte(i>>8)
+		uh.Haves = append(uh.Haves, h)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := uh.Encode(ioutil.Discard, true); err != nil {
+			b.Fatalf("unexpected error: %s", err)
+		}
+	}
+}
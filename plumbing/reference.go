@@ -96,6 +96,15 @@ func (r ReferenceName) Short() string {
 const (
 	HEAD   ReferenceName = "HEAD"
 	Master ReferenceName = "refs/heads/master"
+	// FetchHead is the pseudo-reference recording the references fetched by
+	// the last fetch.
+	FetchHead ReferenceName = "FETCH_HEAD"
+	// OrigHead is the pseudo-reference recording the commit HEAD pointed to
+	// before the last reset, rebase or merge.
+	OrigHead ReferenceName = "ORIG_HEAD"
+	// MergeHead is the pseudo-reference recording the commits being merged
+	// into HEAD, until the merge is committed.
+	MergeHead ReferenceName = "MERGE_HEAD"
 )
 
 // Reference is a representation of git reference
@@ -233,37 +233,19 @@ func (s *RevListSuite) TestRevListObjectsNewBranch(c *C) {
 // |/
 // * b029517 Initial commit
 func (s *RevListSuite) TestReachableObjectsNoRevisit(c *C) {
-	obj, err := s.Storer.EncodedObject(plumbing.CommitObject, plumbing.NewHash("af2d6a6954d532f8ffb47615169c8fdf9d383a1a"))
-	c.Assert(err, IsNil)
-
-	do, err := object.DecodeObject(s.Storer, obj)
-	c.Assert(err, IsNil)
-
-	commit, ok := do.(*object.Commit)
-	c.Assert(ok, Equals, true)
+	w := NewObjectWalker(s.Storer)
+	w.Visited = HashSet{
+		plumbing.NewHash("35e85108805c84807bc66a02d91535e1e24b38b9"): struct{}{},
+	}
 
 	var visited []plumbing.Hash
-	err = reachableObjects(
-		commit,
-		map[plumbing.Hash]bool{
-			plumbing.NewHash("35e85108805c84807bc66a02d91535e1e24b38b9"): true,
-		},
-		map[plumbing.Hash]bool{
-			plumbing.NewHash("35e85108805c84807bc66a02d91535e1e24b38b9"): true,
-		},
-		nil,
-		func(h plumbing.Hash) {
-			obj, err := s.Storer.EncodedObject(plumbing.AnyObject, h)
-			c.Assert(err, IsNil)
-
-			do, err := object.DecodeObject(s.Storer, obj)
-			c.Assert(err, IsNil)
-
-			if _, ok := do.(*object.Commit); ok {
-				visited = append(visited, h)
-			}
-		},
-	)
+	err := w.Walk(func(h plumbing.Hash, t plumbing.ObjectType) error {
+		if t == plumbing.CommitObject {
+			visited = append(visited, h)
+		}
+
+		return nil
+	}, plumbing.NewHash("af2d6a6954d532f8ffb47615169c8fdf9d383a1a"))
 	c.Assert(err, IsNil)
 
 	c.Assert(visited, DeepEquals, []plumbing.Hash{
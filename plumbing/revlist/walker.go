@@ -0,0 +1,327 @@
+package revlist
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+// VisitedSet is the set of objects already walked by an ObjectWalker, which
+// are never walked again. A set shared by several walkers, or filled before
+// the walk, skips the objects known to be walked somewhere else.
+type VisitedSet interface {
+	// Has returns true if the object was walked.
+	Has(h plumbing.Hash) bool
+	// Add marks the object as walked.
+	Add(h plumbing.Hash)
+}
+
+// HashSet is a VisitedSet backed by a map, with no value to keep it small
+// when walking big repositories.
+type HashSet map[plumbing.Hash]struct{}
+
+// Has returns true if the hash is in the set.
+func (s HashSet) Has(h plumbing.Hash) bool {
+	_, ok := s[h]
+	return ok
+}
+
+// Add adds the hash to the set.
+func (s HashSet) Add(h plumbing.Hash) {
+	s[h] = struct{}{}
+}
+
+// ObjectFilter is called with every object reached by an ObjectWalker, if
+// it returns false the object isn't visited, nor the objects reached only
+// through it, e.g. the parents of a commit or the entries of a tree.
+type ObjectFilter func(o plumbing.EncodedObject) bool
+
+// BlobLimitFilter returns an ObjectFilter omitting the blobs of the given
+// size or bigger, as the blob:limit=<n> filter-spec does, zero omits all of
+// them as blob:none.
+func BlobLimitFilter(limit int64) ObjectFilter {
+	return func(o plumbing.EncodedObject) bool {
+		return o.Type() != plumbing.BlobObject || o.Size() < limit
+	}
+}
+
+// TypeFilter returns an ObjectFilter visiting only the objects of the given
+// types, e.g. the commits and the tags to walk the history without reading
+// the trees.
+func TypeFilter(types ...plumbing.ObjectType) ObjectFilter {
+	return func(o plumbing.EncodedObject) bool {
+		for _, t := range types {
+			if o.Type() == t {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// ObjectWalkFunc is called with every object visited by an ObjectWalker,
+// returning storer.ErrStop stops the walk without error.
+type ObjectWalkFunc func(h plumbing.Hash, t plumbing.ObjectType) error
+
+// ObjectWalker walks the objects reachable from a set of tips, commits,
+// trees, blobs and tags, as git rev-list --objects does. The tips may be of
+// any type, the tags are peeled and the submodule entries of the trees
+// skipped, since their commits belong to another repository.
+//
+// The objects reachable from the ones given to Hide are uninteresting, they
+// are never visited, and the uninteresting commits whose children are
+// visited are the boundary of the walk.
+type ObjectWalker struct {
+	// Filters, if any, decide which of the reached objects are visited, all
+	// of them must accept an object for it to be visited. Without filters,
+	// the blobs are visited without being read from the storage.
+	Filters []ObjectFilter
+	// Visited is the set of the objects walked, including the ones rejected
+	// by the Filters, if nil a new HashSet is used. The objects already in
+	// it are skipped.
+	Visited VisitedSet
+	// AllowMissing skips the objects missing from the storage, instead of
+	// failing, as the parents of the shallow commits.
+	AllowMissing bool
+	// MaxTreeDepth, if not negative, omits the trees and blobs whose depth
+	// from the root tree of the commits, or the object pointed by a tag, is
+	// MaxTreeDepth or more, as the tree:depth=<n> filter-spec does. An
+	// object reached at several depths is visited if the lowest one is
+	// under the limit. The tips are never omitted. NewObjectWalker sets it
+	// to -1.
+	MaxTreeDepth int
+
+	s        storer.EncodedObjectStorer
+	hidden   HashSet
+	boundary map[plumbing.Hash]bool
+	// depths is the lowest depth the trees and blobs were reached at, with
+	// MaxTreeDepth.
+	depths map[plumbing.Hash]int
+}
+
+// NewObjectWalker returns a new ObjectWalker of the objects of the given
+// storage.
+func NewObjectWalker(s storer.EncodedObjectStorer) *ObjectWalker {
+	return &ObjectWalker{
+		MaxTreeDepth: -1,
+		s:            s,
+		hidden:       HashSet{},
+		boundary:     make(map[plumbing.Hash]bool),
+	}
+}
+
+// Hide marks as uninteresting the given objects and the ones reachable from
+// them, as the ^<rev> arguments of git rev-list. The missing objects are
+// ignored, so the objects the other side of a negotiation claims to have
+// can be hidden without checking them.
+func (w *ObjectWalker) Hide(hashes ...plumbing.Hash) error {
+	hw := &ObjectWalker{
+		Visited:      w.hidden,
+		AllowMissing: true,
+		MaxTreeDepth: -1,
+		s:            w.s,
+		hidden:       HashSet{},
+		boundary:     make(map[plumbing.Hash]bool),
+	}
+
+	return hw.Walk(func(plumbing.Hash, plumbing.ObjectType) error {
+		return nil
+	}, hashes...)
+}
+
+// IsHidden returns true if the object is uninteresting, see Hide.
+func (w *ObjectWalker) IsHidden(h plumbing.Hash) bool {
+	return w.hidden.Has(h)
+}
+
+// Boundary returns the uninteresting commits that are parents of the
+// commits visited so far, as git rev-list --boundary shows.
+func (w *ObjectWalker) Boundary() []plumbing.Hash {
+	result := make([]plumbing.Hash, 0, len(w.boundary))
+	for h := range w.boundary {
+		result = append(result, h)
+	}
+
+	return result
+}
+
+// walkItem is an object pending to be walked, with its type if known from
+// the object pointing to it.
+type walkItem struct {
+	hash   plumbing.Hash
+	typ    plumbing.ObjectType
+	parent bool
+	// depth is the depth of a tree or blob from the root tree, -1 for the
+	// tips, commits and tags.
+	depth int
+}
+
+// Walk calls fn with every object reachable from the given tips that is not
+// hidden, nor visited before, and is accepted by the Filters. The objects
+// are walked depth-first, the commits before their trees and their first
+// parents before the rest.
+func (w *ObjectWalker) Walk(fn ObjectWalkFunc, tips ...plumbing.Hash) error {
+	if w.Visited == nil {
+		w.Visited = HashSet{}
+	}
+
+	if w.MaxTreeDepth >= 0 && w.depths == nil {
+		w.depths = make(map[plumbing.Hash]int)
+	}
+
+	pending := make([]walkItem, 0, len(tips))
+	for i := len(tips) - 1; i >= 0; i-- {
+		pending = append(pending, walkItem{hash: tips[i], typ: plumbing.AnyObject, depth: -1})
+	}
+
+	for len(pending) > 0 {
+		item := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if w.hidden.Has(item.hash) {
+			if item.parent {
+				w.boundary[item.hash] = true
+			}
+
+			continue
+		}
+
+		// revisit is true for the trees reached again at a lower depth,
+		// whose entries may be under MaxTreeDepth now.
+		var revisit bool
+		if w.MaxTreeDepth >= 0 && item.depth >= 0 {
+			d, seen := w.depths[item.hash]
+			if seen && d <= item.depth || !seen && w.Visited.Has(item.hash) {
+				continue
+			}
+
+			w.depths[item.hash] = item.depth
+			if item.depth >= w.MaxTreeDepth {
+				continue
+			}
+
+			revisit = seen && w.Visited.Has(item.hash)
+			if revisit && item.typ != plumbing.TreeObject {
+				continue
+			}
+		} else if w.Visited.Has(item.hash) {
+			continue
+		}
+
+		w.Visited.Add(item.hash)
+
+		var o plumbing.EncodedObject
+		if item.typ != plumbing.BlobObject || len(w.Filters) != 0 {
+			var err error
+			o, err = w.s.EncodedObject(item.typ, item.hash)
+			if err == plumbing.ErrObjectNotFound && w.AllowMissing {
+				continue
+			}
+
+			if err != nil {
+				return err
+			}
+
+			if !w.accept(o) {
+				continue
+			}
+
+			item.typ = o.Type()
+		}
+
+		if !revisit {
+			if err := fn(item.hash, item.typ); err != nil {
+				if err == storer.ErrStop {
+					return nil
+				}
+
+				return err
+			}
+		}
+
+		if o == nil {
+			continue
+		}
+
+		next, err := w.children(o, item.depth)
+		if err != nil {
+			return err
+		}
+
+		pending = append(pending, next...)
+	}
+
+	return nil
+}
+
+func (w *ObjectWalker) accept(o plumbing.EncodedObject) bool {
+	for _, f := range w.Filters {
+		if !f(o) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// children returns the objects pointed by o, reached at the given depth, in
+// the reverse order they are walked.
+func (w *ObjectWalker) children(o plumbing.EncodedObject, depth int) ([]walkItem, error) {
+	switch o.Type() {
+	case plumbing.CommitObject:
+		c, err := object.DecodeCommit(w.s, o)
+		if err != nil {
+			return nil, err
+		}
+
+		items := []walkItem{{hash: c.TreeHash, typ: plumbing.TreeObject}}
+		for i := len(c.ParentHashes) - 1; i >= 0; i-- {
+			items = append(items, walkItem{
+				hash: c.ParentHashes[i], typ: plumbing.CommitObject, parent: true, depth: -1,
+			})
+		}
+
+		return items, nil
+	case plumbing.TreeObject:
+		t, err := object.DecodeTree(w.s, o)
+		if err != nil {
+			return nil, err
+		}
+
+		// the tips are at the root
+		if depth < 0 {
+			depth = 0
+		}
+
+		items := make([]walkItem, 0, len(t.Entries))
+		for i := len(t.Entries) - 1; i >= 0; i-- {
+			e := t.Entries[i]
+			switch e.Mode {
+			case filemode.Submodule:
+				continue
+			case filemode.Dir:
+				items = append(items, walkItem{hash: e.Hash, typ: plumbing.TreeObject, depth: depth + 1})
+			default:
+				items = append(items, walkItem{hash: e.Hash, typ: plumbing.BlobObject, depth: depth + 1})
+			}
+		}
+
+		return items, nil
+	case plumbing.TagObject:
+		t, err := object.DecodeTag(w.s, o)
+		if err != nil {
+			return nil, err
+		}
+
+		item := walkItem{hash: t.Target, typ: t.TargetType}
+		if t.TargetType == plumbing.CommitObject || t.TargetType == plumbing.TagObject {
+			item.depth = -1
+		}
+
+		return []walkItem{item}, nil
+	default:
+		return nil, nil
+	}
+}
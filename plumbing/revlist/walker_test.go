@@ -0,0 +1,226 @@
+package revlist
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *RevListSuite) TestObjectWalkerHideBoundary(c *C) {
+	w := NewObjectWalker(s.Storer)
+	c.Assert(w.Hide(plumbing.NewHash(someCommit)), IsNil)
+	c.Assert(w.IsHidden(plumbing.NewHash(initialCommit)), Equals, true)
+
+	var commits []plumbing.Hash
+	err := w.Walk(func(h plumbing.Hash, t plumbing.ObjectType) error {
+		c.Assert(w.IsHidden(h), Equals, false)
+		if t == plumbing.CommitObject {
+			commits = append(commits, h)
+		}
+
+		return nil
+	}, plumbing.NewHash(someCommitBranch))
+	c.Assert(err, IsNil)
+
+	c.Assert(commits, DeepEquals, []plumbing.Hash{plumbing.NewHash(someCommitBranch)})
+	c.Assert(w.Boundary(), DeepEquals, []plumbing.Hash{plumbing.NewHash(someCommit)})
+}
+
+func (s *RevListSuite) TestObjectWalkerFilters(c *C) {
+	w := NewObjectWalker(s.Storer)
+	w.Filters = []ObjectFilter{BlobLimitFilter(0)}
+
+	types := make(map[plumbing.ObjectType]int)
+	err := w.Walk(func(h plumbing.Hash, t plumbing.ObjectType) error {
+		types[t]++
+		return nil
+	}, plumbing.NewHash(someCommitOtherBranch))
+	c.Assert(err, IsNil)
+	c.Assert(types[plumbing.BlobObject], Equals, 0)
+	c.Assert(types[plumbing.TreeObject] > 0, Equals, true)
+	c.Assert(types[plumbing.CommitObject], Equals, 8)
+
+	w = NewObjectWalker(s.Storer)
+	w.Filters = []ObjectFilter{func(o plumbing.EncodedObject) bool {
+		return o.Type() == plumbing.CommitObject && o.Hash() != plumbing.NewHash(someCommit)
+	}}
+
+	var commits []plumbing.Hash
+	err = w.Walk(func(h plumbing.Hash, t plumbing.ObjectType) error {
+		commits = append(commits, h)
+		return nil
+	}, plumbing.NewHash(someCommitOtherBranch))
+	c.Assert(err, IsNil)
+	c.Assert(commits, DeepEquals, []plumbing.Hash{plumbing.NewHash(someCommitOtherBranch)})
+}
+
+func (s *RevListSuite) TestObjectWalkerVisited(c *C) {
+	visited := HashSet{}
+
+	w := NewObjectWalker(s.Storer)
+	w.Visited = visited
+	c.Assert(w.Walk(func(plumbing.Hash, plumbing.ObjectType) error {
+		return nil
+	}, plumbing.NewHash(someCommit)), IsNil)
+	c.Assert(visited.Has(plumbing.NewHash(initialCommit)), Equals, true)
+
+	expected, err := Objects(s.Storer,
+		[]plumbing.Hash{plumbing.NewHash(someCommitBranch)},
+		[]plumbing.Hash{plumbing.NewHash(someCommit)},
+	)
+	c.Assert(err, IsNil)
+
+	var objs []plumbing.Hash
+	w = NewObjectWalker(s.Storer)
+	w.Visited = visited
+	c.Assert(w.Walk(func(h plumbing.Hash, _ plumbing.ObjectType) error {
+		objs = append(objs, h)
+		return nil
+	}, plumbing.NewHash(someCommitBranch)), IsNil)
+	c.Assert(objs, HasLen, len(expected))
+}
+
+func (s *RevListSuite) TestObjectWalkerStop(c *C) {
+	var n int
+	err := NewObjectWalker(s.Storer).Walk(func(plumbing.Hash, plumbing.ObjectType) error {
+		n++
+		return storer.ErrStop
+	}, plumbing.NewHash(someCommit))
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 1)
+}
+
+func (s *RevListSuite) TestObjectWalkerMissing(c *C) {
+	missing := plumbing.NewHash("0000000000000000000000000000000000000001")
+
+	err := NewObjectWalker(s.Storer).Walk(func(plumbing.Hash, plumbing.ObjectType) error {
+		return nil
+	}, missing)
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+
+	w := NewObjectWalker(s.Storer)
+	w.AllowMissing = true
+	var n int
+	err = w.Walk(func(plumbing.Hash, plumbing.ObjectType) error {
+		n++
+		return nil
+	}, missing, plumbing.NewHash(initialCommit))
+	c.Assert(err, IsNil)
+	c.Assert(n > 0, Equals, true)
+}
+
+func (s *RevListSuite) TestObjectWalkerTypeFilter(c *C) {
+	w := NewObjectWalker(s.Storer)
+	w.Filters = []ObjectFilter{TypeFilter(plumbing.CommitObject)}
+
+	var n int
+	err := w.Walk(func(h plumbing.Hash, t plumbing.ObjectType) error {
+		c.Assert(t, Equals, plumbing.CommitObject)
+		n++
+		return nil
+	}, plumbing.NewHash(someCommitOtherBranch))
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 8)
+}
+
+func (s *RevListSuite) TestObjectWalkerMaxTreeDepth(c *C) {
+	for depth, expected := range map[int][]plumbing.ObjectType{
+		0: {plumbing.CommitObject},
+		1: {plumbing.CommitObject, plumbing.TreeObject},
+		2: {plumbing.CommitObject, plumbing.TreeObject, plumbing.BlobObject},
+	} {
+		w := NewObjectWalker(s.Storer)
+		w.MaxTreeDepth = depth
+
+		types := make(map[plumbing.ObjectType]int)
+		err := w.Walk(func(h plumbing.Hash, t plumbing.ObjectType) error {
+			types[t]++
+			return nil
+		}, plumbing.NewHash(someCommit))
+		c.Assert(err, IsNil)
+		c.Assert(types, HasLen, len(expected), Commentf("depth %d", depth))
+		for _, t := range expected {
+			c.Assert(types[t] > 0, Equals, true, Commentf("depth %d", depth))
+		}
+	}
+}
+
+func (s *RevListSuite) TestObjectWalkerMaxTreeDepthLowest(c *C) {
+	sto := memory.NewStorage()
+	blob := storeObject(c, sto, plumbing.BlobObject, []byte("foo"))
+	shared := storeTree(c, sto, object.TreeEntry{Name: "b", Mode: filemode.Regular, Hash: blob})
+	dir := storeTree(c, sto, object.TreeEntry{Name: "x", Mode: filemode.Dir, Hash: shared})
+
+	// the parent reaches the shared tree deeper than its child, and it's
+	// walked first
+	parent := storeCommit(c, sto, storeTree(c, sto,
+		object.TreeEntry{Name: "a", Mode: filemode.Dir, Hash: dir},
+	))
+	child := storeCommit(c, sto, storeTree(c, sto,
+		object.TreeEntry{Name: "y", Mode: filemode.Dir, Hash: shared},
+	), parent)
+
+	w := NewObjectWalker(sto)
+	w.MaxTreeDepth = 3
+
+	var objs []plumbing.Hash
+	err := w.Walk(func(h plumbing.Hash, t plumbing.ObjectType) error {
+		objs = append(objs, h)
+		return nil
+	}, child)
+	c.Assert(err, IsNil)
+
+	seen := HashSet{}
+	for _, h := range objs {
+		c.Assert(seen.Has(h), Equals, false)
+		seen.Add(h)
+	}
+
+	c.Assert(seen.Has(shared), Equals, true)
+	c.Assert(seen.Has(blob), Equals, true)
+	c.Assert(objs, HasLen, 7)
+}
+
+func storeObject(c *C, s storer.EncodedObjectStorer, t plumbing.ObjectType, content []byte) plumbing.Hash {
+	o := s.NewEncodedObject()
+	o.SetType(t)
+	w, err := o.Writer()
+	c.Assert(err, IsNil)
+	_, err = w.Write(content)
+	c.Assert(err, IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	h, err := s.SetEncodedObject(o)
+	c.Assert(err, IsNil)
+	return h
+}
+
+func storeTree(c *C, s storer.EncodedObjectStorer, entries ...object.TreeEntry) plumbing.Hash {
+	o := s.NewEncodedObject()
+	c.Assert((&object.Tree{Entries: entries}).Encode(o), IsNil)
+
+	h, err := s.SetEncodedObject(o)
+	c.Assert(err, IsNil)
+	return h
+}
+
+func storeCommit(c *C, s storer.EncodedObjectStorer, tree plumbing.Hash, parents ...plumbing.Hash) plumbing.Hash {
+	sig := object.Signature{Name: "foo", Email: "foo@foo.foo"}
+	o := s.NewEncodedObject()
+	commit := &object.Commit{
+		Author:       sig,
+		Committer:    sig,
+		Message:      "foo\n",
+		TreeHash:     tree,
+		ParentHashes: parents,
+	}
+	c.Assert(commit.Encode(o), IsNil)
+
+	h, err := s.SetEncodedObject(o)
+	c.Assert(err, IsNil)
+	return h
+}
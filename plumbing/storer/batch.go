@@ -0,0 +1,67 @@
+package storer
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// ObjectInfo is the type and the size of an object.
+type ObjectInfo struct {
+	Hash plumbing.Hash
+	Type plumbing.ObjectType
+	Size int64
+}
+
+// ObjectBatch reads many objects one after another, as `git cat-file --batch`
+// does, reusing the resources needed to read them, such as the open
+// packfiles. An ObjectBatch is not safe for concurrent use and must be closed
+// when it is no longer needed.
+type ObjectBatch interface {
+	// ObjectInfo returns the type and the size of the object with the given
+	// hash, avoiding to read its content when possible. It returns
+	// plumbing.ErrObjectNotFound if the object does not exist.
+	ObjectInfo(plumbing.Hash) (*ObjectInfo, error)
+	// EncodedObject returns the object with the given hash and type, as
+	// EncodedObjectStorer.EncodedObject does.
+	EncodedObject(plumbing.ObjectType, plumbing.Hash) (plumbing.EncodedObject, error)
+	// Close releases the resources of the batch.
+	Close() error
+}
+
+// BatchObjectStorer is an optional interface for EncodedObjectStorer, it
+// allows to read many objects with a lower overhead per object.
+type BatchObjectStorer interface {
+	// ObjectBatch returns a new ObjectBatch reading from the storer.
+	ObjectBatch() (ObjectBatch, error)
+}
+
+// NewObjectBatch returns an ObjectBatch reading from the given storer, the
+// batch of the storer is used if it implements BatchObjectStorer, otherwise
+// every object is read with EncodedObject.
+func NewObjectBatch(s EncodedObjectStorer) (ObjectBatch, error) {
+	if bs, ok := s.(BatchObjectStorer); ok {
+		return bs.ObjectBatch()
+	}
+
+	return &objectBatch{s}, nil
+}
+
+type objectBatch struct {
+	s EncodedObjectStorer
+}
+
+func (b *objectBatch) ObjectInfo(h plumbing.Hash) (*ObjectInfo, error) {
+	obj, err := b.s.EncodedObject(plumbing.AnyObject, h)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ObjectInfo{Hash: h, Type: obj.Type(), Size: obj.Size()}, nil
+}
+
+func (b *objectBatch) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	return b.s.EncodedObject(t, h)
+}
+
+func (b *objectBatch) Close() error {
+	return nil
+}
@@ -0,0 +1,25 @@
+package storer
+
+import (
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+func (s *ObjectSuite) TestNewObjectBatch(c *C) {
+	b, err := NewObjectBatch(&MockObjectStorage{s.Objects})
+	c.Assert(err, IsNil)
+
+	for _, o := range s.Objects {
+		info, err := b.ObjectInfo(o.Hash())
+		c.Assert(err, IsNil)
+		c.Assert(*info, Equals, ObjectInfo{Hash: o.Hash(), Type: o.Type(), Size: o.Size()})
+
+		obj, err := b.EncodedObject(plumbing.AnyObject, o.Hash())
+		c.Assert(err, IsNil)
+		c.Assert(obj, Equals, o)
+	}
+
+	_, err = b.ObjectInfo(plumbing.ZeroHash)
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+	c.Assert(b.Close(), IsNil)
+}
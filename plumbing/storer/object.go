@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
 )
 
 var (
@@ -85,6 +86,14 @@ type PackedObjectStorer interface {
 	DeleteOldObjectPackAndIndex(plumbing.Hash, time.Time) error
 }
 
+// PackedObjectLister is an optional interface for listing the objects in a
+// packfile.
+type PackedObjectLister interface {
+	// ObjectPackHashes returns the hashes of the objects in the object pack
+	// with the given hash.
+	ObjectPackHashes(plumbing.Hash) ([]plumbing.Hash, error)
+}
+
 // PackfileWriter is a optional method for ObjectStorer, it enable direct write
 // of packfile to the storage
 type PackfileWriter interface {
@@ -95,6 +104,23 @@ type PackfileWriter interface {
 	PackfileWriter() (io.WriteCloser, error)
 }
 
+// StreamPackfileWriter is an optional method for ObjectStorer, it enables
+// writing a packfile straight to the storage, indexing it once written
+// instead of while being written, so its memory use is bounded.
+type StreamPackfileWriter interface {
+	// StreamPackfileWriter returns a writer for writing a packfile to the
+	// storage, caching up to the given size of delta bases while indexing.
+	StreamPackfileWriter(cache.FileSize) (io.WriteCloser, error)
+}
+
+// PackfileOpener is an optional interface for ObjectStorer, it enables
+// reading the packfiles of the storage as they are, e.g. to copy them to
+// another storage without decoding their objects.
+type PackfileOpener interface {
+	// ObjectPackfile returns a reader of the object pack with the given hash.
+	ObjectPackfile(plumbing.Hash) (io.ReadCloser, error)
+}
+
 // EncodedObjectIter is a generic closable interface for iterating over objects.
 type EncodedObjectIter interface {
 	Next() (plumbing.EncodedObject, error)
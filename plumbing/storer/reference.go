@@ -13,6 +13,10 @@ const MaxResolveRecursion = 1024
 // is exceeded
 var ErrMaxResolveRecursion = errors.New("max. recursion level reached")
 
+// ErrReferenceHasChanged is returned by CheckAndSetReference if the stored
+// reference is not the expected one.
+var ErrReferenceHasChanged = errors.New("reference has changed concurrently")
+
 // ReferenceStorer is a generic storage of references.
 type ReferenceStorer interface {
 	SetReference(*plumbing.Reference) error
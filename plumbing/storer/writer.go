@@ -0,0 +1,169 @@
+package storer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+var (
+	// ErrObjectSizeMismatch is returned when the content of an object is
+	// not of the size given for it.
+	ErrObjectSizeMismatch = errors.New("object content size mismatch")
+)
+
+// ObjectWriter writes the content of an object, computing its hash as the
+// content is written. The object is stored when the writer is closed.
+type ObjectWriter interface {
+	io.WriteCloser
+	// Hash returns the hash of the object, once the writer is closed.
+	Hash() plumbing.Hash
+}
+
+// ObjectWriterStorer is an optional interface for EncodedObjectStorer, it
+// allows to store objects as their content is written, without holding it
+// in memory.
+type ObjectWriterStorer interface {
+	// ObjectWriter returns an ObjectWriter storing an object of the given
+	// type and size, the size is negative if it is unknown.
+	ObjectWriter(t plumbing.ObjectType, size int64) (ObjectWriter, error)
+}
+
+// NewObjectWriter returns an ObjectWriter storing an object of the given type
+// and size at s, the size is negative if it is unknown. If s doesn't
+// implement ObjectWriterStorer, the content is written to an object created
+// with NewEncodedObject, and set with SetEncodedObject on Close.
+func NewObjectWriter(s EncodedObjectStorer, t plumbing.ObjectType, size int64) (ObjectWriter, error) {
+	if ws, ok := s.(ObjectWriterStorer); ok {
+		return ws.ObjectWriter(t, size)
+	}
+
+	obj := s.NewEncodedObject()
+	obj.SetType(t)
+	if size >= 0 {
+		obj.SetSize(size)
+	}
+
+	w, err := obj.Writer()
+	if err != nil {
+		return nil, err
+	}
+
+	return &objectWriter{s: s, obj: obj, w: w, size: size}, nil
+}
+
+type objectWriter struct {
+	s    EncodedObjectStorer
+	obj  plumbing.EncodedObject
+	w    io.WriteCloser
+	size int64
+	n    int64
+	h    plumbing.Hash
+}
+
+func (w *objectWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+func (w *objectWriter) Close() error {
+	if err := w.w.Close(); err != nil {
+		return err
+	}
+
+	if w.size >= 0 && w.n != w.size {
+		return ErrObjectSizeMismatch
+	}
+
+	h, err := w.s.SetEncodedObject(w.obj)
+	if err != nil {
+		return err
+	}
+
+	w.h = h
+	return nil
+}
+
+func (w *objectWriter) Hash() plumbing.Hash {
+	return w.h
+}
+
+// HashObject returns the hash of an object of the given type and size with
+// the content read from r, as `git hash-object` does, the size is negative if
+// it is unknown. The content is hashed as it is read; when the size is
+// unknown, it is taken seeking to the end of r if it is an io.Seeker, or the
+// content is read into memory otherwise. ErrObjectSizeMismatch is returned if
+// the content is not of the given size.
+func HashObject(t plumbing.ObjectType, size int64, r io.Reader) (plumbing.Hash, error) {
+	if size < 0 {
+		var err error
+		if size, r, err = readerSize(r); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	h := plumbing.NewHasher(t, size)
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if n != size {
+		return plumbing.ZeroHash, ErrObjectSizeMismatch
+	}
+
+	return h.Sum(), nil
+}
+
+func readerSize(r io.Reader) (int64, io.Reader, error) {
+	if s, ok := r.(io.Seeker); ok {
+		cur, err := s.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return -1, nil, err
+		}
+
+		end, err := s.Seek(0, io.SeekEnd)
+		if err != nil {
+			return -1, nil, err
+		}
+
+		if _, err := s.Seek(cur, io.SeekStart); err != nil {
+			return -1, nil, err
+		}
+
+		return end - cur, r, nil
+	}
+
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	return int64(len(content)), bytes.NewReader(content), nil
+}
+
+// StoreObject stores at s an object of the given type and size with the
+// content read from r, as `git hash-object -w` does, and returns its hash.
+// The size is negative if it is unknown. The content is written as it is
+// read with the ObjectWriter returned by NewObjectWriter.
+func StoreObject(s EncodedObjectStorer, t plumbing.ObjectType, size int64, r io.Reader) (plumbing.Hash, error) {
+	w, err := NewObjectWriter(s, t, size)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return plumbing.ZeroHash, err
+	}
+
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return w.Hash(), nil
+}
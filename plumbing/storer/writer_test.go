@@ -0,0 +1,37 @@
+package storer
+
+import (
+	"bytes"
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+func (s *ObjectSuite) TestHashObject(c *C) {
+	expected := plumbing.NewHash("19102815663d23f8b75a47e7a01965dcdc96468c")
+
+	h, err := HashObject(plumbing.BlobObject, 3, bytes.NewBufferString("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(h, Equals, expected)
+
+	// the size taken seeking, and reading the content into memory
+	r := strings.NewReader("barfoo")
+	_, err = r.Seek(3, 0)
+	c.Assert(err, IsNil)
+
+	h, err = HashObject(plumbing.BlobObject, -1, r)
+	c.Assert(err, IsNil)
+	c.Assert(h, Equals, expected)
+
+	h, err = HashObject(plumbing.BlobObject, -1, bytes.NewBufferString("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(h, Equals, expected)
+}
+
+func (s *ObjectSuite) TestHashObjectSizeMismatch(c *C) {
+	_, err := HashObject(plumbing.BlobObject, 4, bytes.NewBufferString("foo"))
+	c.Assert(err, Equals, ErrObjectSizeMismatch)
+	_, err = HashObject(plumbing.BlobObject, 2, bytes.NewBufferString("foo"))
+	c.Assert(err, Equals, ErrObjectSizeMismatch)
+}
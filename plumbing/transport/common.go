@@ -15,13 +15,17 @@ package transport
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
@@ -38,9 +42,35 @@ var (
 	ErrAlreadyConnected       = errors.New("session already established")
 )
 
+// AuthenticationRequiredError is returned when the server asks for
+// credentials, along with the realm they are asked for, if any. Err is always
+// ErrAuthenticationRequired, for the callers comparing errors with ==.
+type AuthenticationRequiredError struct {
+	// Err is ErrAuthenticationRequired.
+	Err error
+	// Realm is the protection space of the credentials, as announced by the
+	// server.
+	Realm string
+}
+
+// NewAuthenticationRequiredError returns an AuthenticationRequiredError for
+// the given realm.
+func NewAuthenticationRequiredError(realm string) *AuthenticationRequiredError {
+	return &AuthenticationRequiredError{Err: ErrAuthenticationRequired, Realm: realm}
+}
+
+func (e *AuthenticationRequiredError) Error() string {
+	if e.Realm == "" {
+		return e.Err.Error()
+	}
+
+	return fmt.Sprintf("%s: realm %q", e.Err, e.Realm)
+}
+
 const (
-	UploadPackServiceName  = "git-upload-pack"
-	ReceivePackServiceName = "git-receive-pack"
+	UploadPackServiceName    = "git-upload-pack"
+	ReceivePackServiceName   = "git-receive-pack"
+	UploadArchiveServiceName = "git-upload-archive"
 )
 
 // Transport can initiate git-upload-pack and git-receive-pack processes.
@@ -52,6 +82,23 @@ type Transport interface {
 	NewReceivePackSession(*Endpoint, AuthMethod) (ReceivePackSession, error)
 }
 
+// UploadArchiveTransport is implemented by the Transports supporting the
+// git-upload-archive service, producing the archives at the server.
+type UploadArchiveTransport interface {
+	// NewUploadArchiveSession starts a git-upload-archive session for an
+	// endpoint.
+	NewUploadArchiveSession(*Endpoint, AuthMethod) (UploadArchiveSession, error)
+}
+
+// LsRefsTransport is implemented by the Transports able to list only some
+// of the references of a server, with the ls-refs command of the protocol
+// v2.
+type LsRefsTransport interface {
+	// NewLsRefsSession starts a git-upload-pack session for an endpoint,
+	// asking the server for the protocol v2.
+	NewLsRefsSession(*Endpoint, AuthMethod) (LsRefsSession, error)
+}
+
 type Session interface {
 	// AdvertisedReferences retrieves the advertised references for a
 	// repository.
@@ -82,6 +129,25 @@ type UploadPackSession interface {
 // A git-receive-pack session has two steps: reference discovery
 // (AdvertisedReferences) and receiving pack (ReceivePack).
 // In that order.
+type UploadArchiveSession interface {
+	// UploadArchive sends the git-archive arguments of the request and
+	// returns the archive produced by the server. Reading it until io.EOF
+	// and closing it ends the session.
+	UploadArchive(context.Context, *packp.UploadArchiveRequest) (io.ReadCloser, error)
+	io.Closer
+}
+
+// LsRefsSession represents a git-upload-pack session listing the
+// references of a server.
+type LsRefsSession interface {
+	// LsRefs returns the references matching the request. The servers not
+	// supporting the protocol v2 advertise all of them, which are filtered
+	// then by the session.
+	// If the repository does not exist, returns ErrRepositoryNotFound.
+	LsRefs(context.Context, *packp.LsRefsRequest) (*packp.AdvRefs, error)
+	io.Closer
+}
+
 type ReceivePackSession interface {
 	Session
 	// ReceivePack sends an update references request and a packfile
@@ -107,6 +173,45 @@ type Endpoint struct {
 	Port int
 	// Path is the repository path.
 	Path string
+	// HTTP holds the options of the requests sent to an HTTP endpoint, nil
+	// for the defaults of the client.
+	HTTP *HTTPOptions
+}
+
+// HTTPOptions are the options of the requests sent to an HTTP endpoint, the
+// counterpart of the http.<url>.* git config.
+type HTTPOptions struct {
+	// ExtraHeaders are added to every request, as http.extraHeader.
+	ExtraHeaders http.Header
+	// UserAgent, if not empty, replaces the default User-Agent, as
+	// http.userAgent.
+	UserAgent string
+	// InsecureSkipTLS disables the verification of the certificate of the
+	// server, as http.sslVerify set to false.
+	InsecureSkipTLS bool
+	// ClientCert and ClientKey are the paths to the PEM encoded certificate,
+	// and its key, presented to the server, as http.sslCert and http.sslKey.
+	ClientCert, ClientKey string
+	// ClientCertificates are presented to the server, along with the one of
+	// ClientCert if any.
+	ClientCertificates []tls.Certificate
+	// RootCAs, if not nil, are the certificate authorities the certificate
+	// of the server is verified against instead of the ones of the system, as
+	// http.sslCAInfo.
+	RootCAs *x509.CertPool
+	// MinTLSVersion, if not zero, is the minimum TLS version accepted, such
+	// as tls.VersionTLS12, as http.sslVersion.
+	MinTLSVersion uint16
+	// FollowRedirects is "true" to follow every redirect, "false" to follow
+	// none and "initial" to follow only those of the reference discovery
+	// request, as http.followRedirects. Empty keeps the policy of the
+	// http.Client.
+	FollowRedirects string
+	// LowSpeedLimit and LowSpeedTime abort the transfers slower than
+	// LowSpeedLimit bytes per second during LowSpeedTime, as
+	// http.lowSpeedLimit and http.lowSpeedTime. Both must be set.
+	LowSpeedLimit int
+	LowSpeedTime  time.Duration
 }
 
 var defaultPorts = map[string]int{
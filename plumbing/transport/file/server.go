@@ -7,12 +7,14 @@ import (
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport/internal/common"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport/server"
+	"gopkg.in/src-d/go-git.v4/storage"
 	"gopkg.in/src-d/go-git.v4/utils/ioutil"
 )
 
 // ServeUploadPack serves a git-upload-pack request using standard output, input
 // and error. This is meant to be used when implementing a git-upload-pack
-// command.
+// command. Only the references of the namespace at GIT_NAMESPACE are served,
+// if set.
 func ServeUploadPack(path string) error {
 	ep, err := transport.NewEndpoint(path)
 	if err != nil {
@@ -20,7 +22,7 @@ func ServeUploadPack(path string) error {
 	}
 
 	// TODO: define and implement a server-side AuthMethod
-	s, err := server.DefaultServer.NewUploadPackSession(ep, nil)
+	s, err := environmentServer().NewUploadPackSession(ep, nil)
 	if err != nil {
 		return fmt.Errorf("error creating session: %s", err)
 	}
@@ -30,7 +32,8 @@ func ServeUploadPack(path string) error {
 
 // ServeReceivePack serves a git-receive-pack request using standard output,
 // input and error. This is meant to be used when implementing a
-// git-receive-pack command.
+// git-receive-pack command. Only the references of the namespace at
+// GIT_NAMESPACE are served, if set.
 func ServeReceivePack(path string) error {
 	ep, err := transport.NewEndpoint(path)
 	if err != nil {
@@ -38,7 +41,7 @@ func ServeReceivePack(path string) error {
 	}
 
 	// TODO: define and implement a server-side AuthMethod
-	s, err := server.DefaultServer.NewReceivePackSession(ep, nil)
+	s, err := environmentServer().NewReceivePackSession(ep, nil)
 	if err != nil {
 		return fmt.Errorf("error creating session: %s", err)
 	}
@@ -46,6 +49,40 @@ func ServeReceivePack(path string) error {
 	return common.ServeReceivePack(srvCmd, s)
 }
 
+// ServeUploadArchive serves a git-upload-archive request using standard
+// output, input and error. This is meant to be used when implementing a
+// git-upload-archive command. Only the references of the namespace at
+// GIT_NAMESPACE are served, if set.
+func ServeUploadArchive(path string) error {
+	ep, err := transport.NewEndpoint(path)
+	if err != nil {
+		return err
+	}
+
+	t, ok := environmentServer().(transport.UploadArchiveTransport)
+	if !ok {
+		return fmt.Errorf("upload-archive not supported")
+	}
+
+	// TODO: define and implement a server-side AuthMethod
+	s, err := t.NewUploadArchiveSession(ep, nil)
+	if err != nil {
+		return fmt.Errorf("error creating session: %s", err)
+	}
+
+	return common.ServeUploadArchive(srvCmd, s)
+}
+
+// environmentServer returns the server of the namespace at GIT_NAMESPACE, or
+// server.DefaultServer if not set.
+func environmentServer() transport.Transport {
+	if ns := os.Getenv(storage.NamespaceEnvironment); ns != "" {
+		return server.NewServer(server.NewNamespacedLoader(server.DefaultLoader, ns))
+	}
+
+	return server.DefaultServer
+}
+
 var srvCmd = common.ServerCommand{
 	Stdin:  os.Stdin,
 	Stdout: ioutil.WriteNopCloser(os.Stdout),
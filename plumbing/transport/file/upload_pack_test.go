@@ -1,9 +1,13 @@
 package file
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/capability"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport/test"
 
@@ -84,3 +88,33 @@ func (s *UploadPackSuite) TestUploadPackWithContextOnRead(c *C) {
 	// canceled context when the packfile is being read.
 	c.Skip("UploadPack has a race condition when we Close the session")
 }
+
+func (s *UploadPackSuite) lsRefs(c *C, prefixes ...string) *packp.AdvRefs {
+	session, err := DefaultClient.(transport.LsRefsTransport).NewLsRefsSession(s.Endpoint, s.EmptyAuth)
+	c.Assert(err, IsNil)
+	defer func() { c.Assert(session.Close(), IsNil) }()
+
+	req := packp.NewLsRefsRequest()
+	req.Prefixes = prefixes
+
+	ar, err := session.LsRefs(context.Background(), req)
+	c.Assert(err, IsNil)
+	return ar
+}
+
+func (s *UploadPackSuite) TestLsRefs(c *C) {
+	ar := s.lsRefs(c, "refs/heads/")
+	c.Assert(ar.Head, IsNil)
+	c.Assert(ar.References["refs/heads/master"].String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	for name := range ar.References {
+		c.Assert(strings.HasPrefix(name, "refs/heads/"), Equals, true, Commentf("%s listed", name))
+	}
+}
+
+func (s *UploadPackSuite) TestLsRefsHEAD(c *C) {
+	ar := s.lsRefs(c, "HEAD")
+	c.Assert(ar.Head, NotNil)
+	c.Assert(ar.Head.String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	c.Assert(ar.References, HasLen, 0)
+	c.Assert(ar.Capabilities.Get(capability.SymRef), DeepEquals, []string{"HEAD:refs/heads/master"})
+}
@@ -33,20 +33,32 @@ func (r *runner) Command(cmd string, ep *transport.Endpoint, auth transport.Auth
 }
 
 type command struct {
-	conn      net.Conn
-	connected bool
-	command   string
-	endpoint  *transport.Endpoint
+	conn        net.Conn
+	connected   bool
+	command     string
+	endpoint    *transport.Endpoint
+	gitProtocol string
 }
 
 // Start executes the command sending the required message to the TCP connection
 func (c *command) Start() error {
 	cmd := endpointToCommand(c.command, c.endpoint)
+	if c.gitProtocol != "" {
+		// extra parameters follow the host one after an additional NUL
+		cmd = fmt.Sprintf("%s%c%s%c", cmd, 0, c.gitProtocol, 0)
+	}
 
 	e := pktline.NewEncoder(c.conn)
 	return e.Encode([]byte(cmd))
 }
 
+// SetGitProtocol sends the value as an extra parameter of the request, as
+// the GIT_PROTOCOL environment variable is set by the git daemon.
+func (c *command) SetGitProtocol(value string) error {
+	c.gitProtocol = value
+	return nil
+}
+
 func (c *command) connect() error {
 	if c.connected {
 		return transport.ErrAlreadyConnected
@@ -3,20 +3,39 @@ package http
 
 import (
 	"bytes"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/capability"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 	"gopkg.in/src-d/go-git.v4/utils/ioutil"
 )
 
+// DefaultUserAgent is the User-Agent of the requests without a
+// HTTPOptions.UserAgent, prefixed by git/ as some servers expect.
+const DefaultUserAgent = "git/1.0 (" + capability.DefaultAgent + ")"
+
+var (
+	// ErrLowSpeed is returned when a transfer is slower than the
+	// LowSpeedLimit of the endpoint HTTP options.
+	ErrLowSpeed = errors.New("transfer slower than the low speed limit")
+	// ErrTLSOptionsUnsupported is returned when TLS options are set on an
+	// endpoint but the http.Client doesn't use a *http.Transport.
+	ErrTLSOptionsUnsupported = errors.New("tls options require an *http.Transport")
+)
+
 // it requires a bytes.Buffer, because we need to know the length
 func applyHeadersToRequest(req *http.Request, content *bytes.Buffer, host string, requestType string) {
-	req.Header.Add("User-Agent", "git/1.0")
+	req.Header.Add("User-Agent", DefaultUserAgent)
 	req.Header.Add("Host", host) // host:port
 
 	if content == nil {
@@ -44,11 +63,14 @@ func advertisedReferences(s *session, serviceName string) (ref *packp.AdvRefs, e
 
 	s.ApplyAuthToRequest(req)
 	applyHeadersToRequest(req, nil, s.endpoint.Host, serviceName)
+	s.ApplyOptionsToRequest(req)
 	res, err := s.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 
+	s.ApplyOptionsToResponse(res)
+
 	s.ModifyEndpointIfRedirect(res)
 	defer ioutil.CheckClose(res.Body, &err)
 
@@ -116,6 +138,11 @@ type session struct {
 }
 
 func newSession(c *http.Client, ep *transport.Endpoint, auth transport.AuthMethod) (*session, error) {
+	c, err := applyOptionsToClient(c, ep.HTTP)
+	if err != nil {
+		return nil, err
+	}
+
 	s := &session{
 		auth:     basicAuthFromEndpoint(ep),
 		client:   c,
@@ -141,6 +168,36 @@ func (s *session) ApplyAuthToRequest(req *http.Request) {
 	s.auth.setAuth(req)
 }
 
+// ApplyOptionsToRequest sets the user agent and the extra headers of the
+// endpoint HTTP options.
+func (s *session) ApplyOptionsToRequest(req *http.Request) {
+	o := s.endpoint.HTTP
+	if o == nil {
+		return
+	}
+
+	if o.UserAgent != "" {
+		req.Header.Set("User-Agent", o.UserAgent)
+	}
+
+	for key, values := range o.ExtraHeaders {
+		for _, v := range values {
+			req.Header.Add(key, v)
+		}
+	}
+}
+
+// ApplyOptionsToResponse enforces the low speed limit of the endpoint HTTP
+// options while reading the response body.
+func (s *session) ApplyOptionsToResponse(res *http.Response) {
+	o := s.endpoint.HTTP
+	if o == nil || o.LowSpeedLimit <= 0 || o.LowSpeedTime <= 0 {
+		return
+	}
+
+	res.Body = newLowSpeedReader(res.Body, o.LowSpeedLimit, o.LowSpeedTime)
+}
+
 func (s *session) ModifyEndpointIfRedirect(res *http.Response) {
 	if res.Request == nil {
 		return
@@ -159,6 +216,190 @@ func (*session) Close() error {
 	return nil
 }
 
+// applyOptionsToClient returns a copy of c following the TLS and redirect
+// settings of the given options, or c itself if there are none.
+func applyOptionsToClient(c *http.Client, o *transport.HTTPOptions) (*http.Client, error) {
+	if o == nil {
+		return c, nil
+	}
+
+	cc := *c
+	if hasTLSOptions(o) {
+		rt := cc.Transport
+		if rt == nil {
+			rt = http.DefaultTransport
+		}
+
+		t, ok := rt.(*http.Transport)
+		if !ok {
+			return nil, ErrTLSOptionsUnsupported
+		}
+
+		t, err := applyTLSOptions(t, o)
+		if err != nil {
+			return nil, err
+		}
+
+		cc.Transport = t
+	}
+
+	switch o.FollowRedirects {
+	case "true":
+		cc.CheckRedirect = nil
+	case "false":
+		cc.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	case "initial":
+		cc.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if !strings.HasSuffix(via[0].URL.Path, infoRefsPath) {
+				return http.ErrUseLastResponse
+			}
+
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+
+			return nil
+		}
+	}
+
+	return &cc, nil
+}
+
+// applyTLSOptions returns a copy of t with the TLS settings of o.
+func applyTLSOptions(t *http.Transport, o *transport.HTTPOptions) (*http.Transport, error) {
+	t = cloneTransport(t)
+
+	if o.InsecureSkipTLS {
+		t.TLSClientConfig.InsecureSkipVerify = true
+	}
+
+	if o.RootCAs != nil {
+		t.TLSClientConfig.RootCAs = o.RootCAs
+	}
+
+	if o.MinTLSVersion != 0 {
+		t.TLSClientConfig.MinVersion = o.MinTLSVersion
+	}
+
+	if len(o.ClientCertificates) > 0 {
+		t.TLSClientConfig.Certificates = append([]tls.Certificate(nil), o.ClientCertificates...)
+	}
+
+	if o.ClientCert != "" {
+		key := o.ClientKey
+		if key == "" {
+			key = o.ClientCert
+		}
+
+		cert, err := tls.LoadX509KeyPair(o.ClientCert, key)
+		if err != nil {
+			return nil, err
+		}
+
+		t.TLSClientConfig.Certificates = append(t.TLSClientConfig.Certificates, cert)
+	}
+
+	return t, nil
+}
+
+func hasTLSOptions(o *transport.HTTPOptions) bool {
+	return o.InsecureSkipTLS || o.ClientCert != "" || len(o.ClientCertificates) > 0 ||
+		o.RootCAs != nil || o.MinTLSVersion != 0
+}
+
+// cloneTransport returns a copy of t, with its own TLS configuration, that
+// can be modified without altering t.
+func cloneTransport(t *http.Transport) *http.Transport {
+	tlsConfig := &tls.Config{}
+	if t.TLSClientConfig != nil {
+		tlsConfig = t.TLSClientConfig.Clone()
+	}
+
+	return &http.Transport{
+		Proxy:                  t.Proxy,
+		DialContext:            t.DialContext,
+		Dial:                   t.Dial,
+		DialTLS:                t.DialTLS,
+		TLSClientConfig:        tlsConfig,
+		TLSHandshakeTimeout:    t.TLSHandshakeTimeout,
+		DisableKeepAlives:      t.DisableKeepAlives,
+		DisableCompression:     t.DisableCompression,
+		MaxIdleConns:           t.MaxIdleConns,
+		MaxIdleConnsPerHost:    t.MaxIdleConnsPerHost,
+		IdleConnTimeout:        t.IdleConnTimeout,
+		ResponseHeaderTimeout:  t.ResponseHeaderTimeout,
+		ExpectContinueTimeout:  t.ExpectContinueTimeout,
+		ProxyConnectHeader:     t.ProxyConnectHeader,
+		MaxResponseHeaderBytes: t.MaxResponseHeaderBytes,
+	}
+}
+
+// lowSpeedReader fails the reads once less than min bytes were read during a
+// window, closing the underlying reader so a stalled read is unblocked.
+type lowSpeedReader struct {
+	rc     io.ReadCloser
+	min    int64
+	window time.Duration
+	timer  *time.Timer
+
+	m       sync.Mutex
+	n       int64
+	tooSlow bool
+	closed  bool
+}
+
+func newLowSpeedReader(rc io.ReadCloser, limit int, window time.Duration) *lowSpeedReader {
+	r := &lowSpeedReader{
+		rc:     rc,
+		min:    int64(float64(limit) * window.Seconds()),
+		window: window,
+	}
+
+	r.timer = time.AfterFunc(window, r.check)
+	return r
+}
+
+func (r *lowSpeedReader) check() {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if r.closed {
+		return
+	}
+
+	if r.n < r.min {
+		r.tooSlow = true
+		_ = r.rc.Close()
+		return
+	}
+
+	r.n = 0
+	r.timer.Reset(r.window)
+}
+
+func (r *lowSpeedReader) Read(p []byte) (int, error) {
+	n, err := r.rc.Read(p)
+
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.n += int64(n)
+	if r.tooSlow {
+		return n, ErrLowSpeed
+	}
+
+	return n, err
+}
+
+func (r *lowSpeedReader) Close() error {
+	r.m.Lock()
+	r.closed = true
+	r.m.Unlock()
+
+	r.timer.Stop()
+	return r.rc.Close()
+}
+
 // AuthMethod is concrete implementation of common.AuthMethod for HTTP services
 type AuthMethod interface {
 	transport.AuthMethod
@@ -231,7 +472,10 @@ type Err struct {
 	Response *http.Response
 }
 
-// NewErr returns a new Err based on a http response
+// NewErr returns a new Err based on a http response. The status codes with
+// a transport error return it as is, so it can be compared with ==, but 401
+// which returns a transport.AuthenticationRequiredError with the realm of the
+// WWW-Authenticate header, its Err being transport.ErrAuthenticationRequired.
 func NewErr(r *http.Response) error {
 	if r.StatusCode >= http.StatusOK && r.StatusCode < http.StatusMultipleChoices {
 		return nil
@@ -239,7 +483,9 @@ func NewErr(r *http.Response) error {
 
 	switch r.StatusCode {
 	case http.StatusUnauthorized:
-		return transport.ErrAuthenticationRequired
+		return transport.NewAuthenticationRequiredError(
+			authenticateRealm(r.Header.Get("WWW-Authenticate")),
+		)
 	case http.StatusForbidden:
 		return transport.ErrAuthorizationFailed
 	case http.StatusNotFound:
@@ -249,6 +495,31 @@ func NewErr(r *http.Response) error {
 	return plumbing.NewUnexpectedError(&Err{r})
 }
 
+// authenticateRealm returns the realm of a WWW-Authenticate challenge, such
+// as `Basic realm="GitHub"`.
+func authenticateRealm(challenge string) string {
+	const key = "realm="
+	i := strings.Index(strings.ToLower(challenge), key)
+	if i < 0 {
+		return ""
+	}
+
+	realm := challenge[i+len(key):]
+	if strings.HasPrefix(realm, `"`) {
+		if end := strings.Index(realm[1:], `"`); end >= 0 {
+			return realm[1 : end+1]
+		}
+
+		return realm[1:]
+	}
+
+	if end := strings.IndexAny(realm, ", "); end >= 0 {
+		return realm[:end]
+	}
+
+	return realm
+}
+
 // StatusCode returns the status code of the response
 func (e *Err) StatusCode() int {
 	return e.Response.StatusCode
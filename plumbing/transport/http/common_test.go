@@ -2,18 +2,23 @@ package http
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"net/http/cgi"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 
 	. "gopkg.in/check.v1"
@@ -77,6 +82,42 @@ func (s *ClientSuite) TestNewErrUnauthorized(c *C) {
 	s.testNewHTTPError(c, http.StatusUnauthorized, "authentication required")
 }
 
+func (s *ClientSuite) TestNewErrUnauthorizedRealm(c *C) {
+	req, _ := http.NewRequest("GET", "foo", nil)
+	res := &http.Response{
+		StatusCode: http.StatusUnauthorized,
+		Request:    req,
+		Header:     http.Header{"Www-Authenticate": []string{`Basic realm="GitHub"`}},
+	}
+
+	err := NewErr(res)
+	c.Assert(err, ErrorMatches, `authentication required: realm "GitHub"`)
+
+	authErr, ok := err.(*transport.AuthenticationRequiredError)
+	c.Assert(ok, Equals, true)
+	c.Assert(authErr.Err, Equals, transport.ErrAuthenticationRequired)
+	c.Assert(authErr.Realm, Equals, "GitHub")
+
+	for challenge, realm := range map[string]string{
+		"":                                "",
+		`Bearer realm=example, scope="x"`: "example",
+		`Basic REALM="a b"`:               "a b",
+	} {
+		res.Header.Set("Www-Authenticate", challenge)
+		err := NewErr(res).(*transport.AuthenticationRequiredError)
+		c.Assert(err.Realm, Equals, realm)
+	}
+}
+
+func (s *ClientSuite) TestNewHTTPErrorUnwrap(c *C) {
+	req, _ := http.NewRequest("GET", "foo", nil)
+	err := NewErr(&http.Response{StatusCode: http.StatusPaymentRequired, Request: req})
+
+	unexpected, ok := err.(*plumbing.UnexpectedError)
+	c.Assert(ok, Equals, true)
+	c.Assert(unexpected.Unwrap().(*Err).StatusCode(), Equals, http.StatusPaymentRequired)
+}
+
 func (s *ClientSuite) TestNewErrForbidden(c *C) {
 	s.testNewHTTPError(c, http.StatusForbidden, "authorization failed")
 }
@@ -119,6 +160,120 @@ func (s *ClientSuite) TestSetAuthWrongType(c *C) {
 	c.Assert(err, Equals, transport.ErrInvalidAuthMethod)
 }
 
+func (s *ClientSuite) TestApplyOptionsToRequest(c *C) {
+	ep, err := transport.NewEndpoint("https://github.com/git-fixtures/basic")
+	c.Assert(err, IsNil)
+
+	ep.HTTP = &transport.HTTPOptions{
+		UserAgent:    "go-git/test",
+		ExtraHeaders: http.Header{"X-Foo": []string{"foo", "bar"}},
+	}
+
+	r, err := DefaultClient.NewUploadPackSession(ep, nil)
+	c.Assert(err, IsNil)
+
+	req, err := http.NewRequest("GET", "https://github.com/git-fixtures/basic", nil)
+	c.Assert(err, IsNil)
+
+	applyHeadersToRequest(req, nil, ep.Host, transport.UploadPackServiceName)
+	r.(*upSession).ApplyOptionsToRequest(req)
+	c.Assert(req.Header.Get("User-Agent"), Equals, "go-git/test")
+	c.Assert(req.Header["X-Foo"], DeepEquals, []string{"foo", "bar"})
+}
+
+func (s *ClientSuite) TestApplyOptionsToClientInsecureSkipTLS(c *C) {
+	// the default transport may have configured TLS already if it was used
+	def := http.DefaultTransport.(*http.Transport)
+	tlsConfig := def.TLSClientConfig
+
+	cl, err := applyOptionsToClient(http.DefaultClient, &transport.HTTPOptions{
+		InsecureSkipTLS: true,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(cl, Not(Equals), http.DefaultClient)
+
+	t := cl.Transport.(*http.Transport)
+	c.Assert(t, Not(Equals), def)
+	c.Assert(t.TLSClientConfig, Not(Equals), tlsConfig)
+	c.Assert(t.TLSClientConfig.InsecureSkipVerify, Equals, true)
+
+	c.Assert(def.TLSClientConfig, Equals, tlsConfig)
+	if tlsConfig != nil {
+		c.Assert(tlsConfig.InsecureSkipVerify, Equals, false)
+	}
+}
+
+func (s *ClientSuite) TestApplyOptionsToClientTLS(c *C) {
+	cert := tls.Certificate{Certificate: [][]byte{[]byte("foo")}}
+	cl, err := applyOptionsToClient(http.DefaultClient, &transport.HTTPOptions{
+		ClientCertificates: []tls.Certificate{cert},
+		MinTLSVersion:      tls.VersionTLS12,
+	})
+	c.Assert(err, IsNil)
+
+	cfg := cl.Transport.(*http.Transport).TLSClientConfig
+	c.Assert(cfg.Certificates, DeepEquals, []tls.Certificate{cert})
+	c.Assert(cfg.MinVersion, Equals, uint16(tls.VersionTLS12))
+	c.Assert(cfg.InsecureSkipVerify, Equals, false)
+}
+
+func (s *ClientSuite) TestApplyOptionsToClientRootCAs(c *C) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	_, err := http.DefaultClient.Get(server.URL)
+	c.Assert(err, NotNil)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	cl, err := applyOptionsToClient(http.DefaultClient, &transport.HTTPOptions{RootCAs: pool})
+	c.Assert(err, IsNil)
+
+	res, err := cl.Get(server.URL)
+	c.Assert(err, IsNil)
+	c.Assert(res.Body.Close(), IsNil)
+	c.Assert(res.StatusCode, Equals, http.StatusOK)
+}
+
+type mockRoundTripper struct{}
+
+func (*mockRoundTripper) RoundTrip(*http.Request) (*http.Response, error) { return nil, nil }
+
+func (s *ClientSuite) TestApplyOptionsToClientTLSUnsupported(c *C) {
+	_, err := applyOptionsToClient(&http.Client{Transport: &mockRoundTripper{}}, &transport.HTTPOptions{
+		InsecureSkipTLS: true,
+	})
+	c.Assert(err, Equals, ErrTLSOptionsUnsupported)
+}
+
+func (s *ClientSuite) TestApplyOptionsToClientFollowRedirects(c *C) {
+	refs, err := http.NewRequest("GET", "https://github.com/git-fixtures/basic/info/refs", nil)
+	c.Assert(err, IsNil)
+	pack, err := http.NewRequest("POST", "https://github.com/git-fixtures/basic/git-upload-pack", nil)
+	c.Assert(err, IsNil)
+
+	cl, err := applyOptionsToClient(http.DefaultClient, &transport.HTTPOptions{FollowRedirects: "false"})
+	c.Assert(err, IsNil)
+	c.Assert(cl.CheckRedirect(refs, []*http.Request{refs}), Equals, http.ErrUseLastResponse)
+
+	cl, err = applyOptionsToClient(http.DefaultClient, &transport.HTTPOptions{FollowRedirects: "initial"})
+	c.Assert(err, IsNil)
+	c.Assert(cl.CheckRedirect(refs, []*http.Request{refs}), IsNil)
+	c.Assert(cl.CheckRedirect(pack, []*http.Request{pack}), Equals, http.ErrUseLastResponse)
+}
+
+func (s *ClientSuite) TestLowSpeedReader(c *C) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	r := newLowSpeedReader(pr, 1000, 10*time.Millisecond)
+	defer r.Close()
+
+	_, err := r.Read(make([]byte, 1))
+	c.Assert(err, Equals, ErrLowSpeed)
+}
+
 type BaseSuite struct {
 	fixtures.Suite
 
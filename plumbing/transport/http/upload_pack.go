@@ -89,12 +89,15 @@ func (s *upSession) doRequest(
 
 	applyHeadersToRequest(req, content, s.endpoint.Host, transport.UploadPackServiceName)
 	s.ApplyAuthToRequest(req)
+	s.ApplyOptionsToRequest(req)
 
 	res, err := s.client.Do(req.WithContext(ctx))
 	if err != nil {
 		return nil, plumbing.NewUnexpectedError(err)
 	}
 
+	s.ApplyOptionsToResponse(res)
+
 	if err := NewErr(res); err != nil {
 		_ = res.Body.Close()
 		return nil, err
@@ -7,6 +7,7 @@ package common
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -25,6 +26,9 @@ import (
 
 const (
 	readErrorSecondsTimeout = 10
+
+	// protocolV2 is the GIT_PROTOCOL value asking for the protocol v2.
+	protocolV2 = "version=2"
 )
 
 var (
@@ -66,6 +70,16 @@ type Command interface {
 	Close() error
 }
 
+// GitProtocolCommand expands the Command interface, enabling it for asking
+// the server for a version of the protocol, as the GIT_PROTOCOL environment
+// variable does.
+type GitProtocolCommand interface {
+	// SetGitProtocol sets the GIT_PROTOCOL value, e.g. version=2. It should
+	// not be called after Start. The servers not supporting the version
+	// asked for ignore it.
+	SetGitProtocol(value string) error
+}
+
 // CommandKiller expands the Command interface, enableing it for being killed.
 type CommandKiller interface {
 	// Kill and close the session whatever the state it is. It will block until
@@ -86,14 +100,35 @@ func NewClient(runner Commander) transport.Transport {
 func (c *client) NewUploadPackSession(ep *transport.Endpoint, auth transport.AuthMethod) (
 	transport.UploadPackSession, error) {
 
-	return c.newSession(transport.UploadPackServiceName, ep, auth)
+	return c.newSession(transport.UploadPackServiceName, ep, auth, "")
 }
 
 // NewReceivePackSession creates a new ReceivePackSession.
 func (c *client) NewReceivePackSession(ep *transport.Endpoint, auth transport.AuthMethod) (
 	transport.ReceivePackSession, error) {
 
-	return c.newSession(transport.ReceivePackServiceName, ep, auth)
+	return c.newSession(transport.ReceivePackServiceName, ep, auth, "")
+}
+
+// NewUploadArchiveSession creates a new UploadArchiveSession.
+func (c *client) NewUploadArchiveSession(ep *transport.Endpoint, auth transport.AuthMethod) (
+	transport.UploadArchiveSession, error) {
+
+	return c.newSession(transport.UploadArchiveServiceName, ep, auth, "")
+}
+
+// NewLsRefsSession creates a new LsRefsSession.
+func (c *client) NewLsRefsSession(ep *transport.Endpoint, auth transport.AuthMethod) (
+	transport.LsRefsSession, error) {
+
+	s, err := c.newSession(transport.UploadPackServiceName, ep, auth, protocolV2)
+	if err != nil {
+		return nil, err
+	}
+
+	// the first pkt-line tells whether the server speaks the protocol v2
+	s.Stdout = bufio.NewReader(s.Stdout)
+	return s, nil
 }
 
 type session struct {
@@ -108,12 +143,20 @@ type session struct {
 	firstErrLine  chan string
 }
 
-func (c *client) newSession(s string, ep *transport.Endpoint, auth transport.AuthMethod) (*session, error) {
+func (c *client) newSession(s string, ep *transport.Endpoint, auth transport.AuthMethod,
+	gitProtocol string) (*session, error) {
+
 	cmd, err := c.cmdr.Command(s, ep, auth)
 	if err != nil {
 		return nil, err
 	}
 
+	if gc, ok := cmd.(GitProtocolCommand); ok && gitProtocol != "" {
+		if err := gc.SetGitProtocol(gitProtocol); err != nil {
+			return nil, err
+		}
+	}
+
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		return nil, err
@@ -330,6 +373,105 @@ func (s *session) ReceivePack(ctx context.Context, req *packp.ReferenceUpdateReq
 	return report, s.Command.Close()
 }
 
+// LsRefs lists the references matching the request with the ls-refs command,
+// if the server speaks the protocol v2, or filters the advertised ones
+// otherwise.
+func (s *session) LsRefs(ctx context.Context, req *packp.LsRefsRequest) (*packp.AdvRefs, error) {
+	if !s.isProtocolV2() {
+		ar, err := s.AdvertisedReferences()
+		if err != nil {
+			return nil, err
+		}
+
+		req.FilterAdvRefs(ar)
+		return ar, nil
+	}
+
+	caps := packp.NewCapabilityAdvertisement()
+	if err := caps.Decode(s.StdoutContext(ctx)); err != nil {
+		return nil, err
+	}
+
+	if !caps.Supports(packp.LsRefsCapability) {
+		return nil, fmt.Errorf("server does not support %s", packp.LsRefsCapability)
+	}
+
+	if err := req.Encode(s.StdinContext(ctx)); err != nil {
+		return nil, err
+	}
+
+	ar := packp.NewAdvRefs()
+	if err := ar.DecodeLsRefs(s.StdoutContext(ctx)); err != nil {
+		return nil, err
+	}
+
+	if len(req.Prefixes) == 0 && ar.Head == nil && len(ar.References) == 0 {
+		return nil, transport.ErrEmptyRemoteRepository
+	}
+
+	return ar, nil
+}
+
+// versionLine is the first pkt-line sent by the protocol v2 servers.
+var versionLine = []byte("000eversion 2\n")
+
+// isProtocolV2 returns true if the first pkt-line sent by the server is the
+// one of the protocol v2, without reading it. Only the length of the
+// pkt-line is peeked first, as a protocol v0 server advertising no
+// references waits for the client after sending a flush-pkt.
+func (s *session) isProtocolV2() bool {
+	r, ok := s.Stdout.(*bufio.Reader)
+	if !ok {
+		return false
+	}
+
+	b, err := r.Peek(4)
+	if err != nil || !bytes.Equal(b, versionLine[:4]) {
+		return false
+	}
+
+	b, err = r.Peek(len(versionLine))
+	return err == nil && bytes.Equal(b, versionLine)
+}
+
+// UploadArchive performs a request to the server to produce an archive. A
+// reader is returned with the archive content. The reader must be closed
+// after reading.
+func (s *session) UploadArchive(ctx context.Context, req *packp.UploadArchiveRequest) (io.ReadCloser, error) {
+	s.packRun = true
+
+	w := s.StdinContext(ctx)
+	if err := req.Encode(w); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	r := s.StdoutContext(ctx)
+
+	status := &packp.UploadArchiveStatus{}
+	if err := status.Decode(r); err != nil {
+		if err == packp.ErrEmptyInput {
+			if err := s.checkNotFoundError(); err != nil {
+				return nil, err
+			}
+		}
+
+		return nil, err
+	}
+
+	if err := status.Err(); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+
+	d := sideband.NewDemuxer(sideband.Sideband64k, r)
+	d.Progress = req.Progress
+	return ioutil.NewReadCloser(d, s), nil
+}
+
 func (s *session) finish() error {
 	if s.finished {
 		return nil
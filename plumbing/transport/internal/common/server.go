@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"io"
 
+	"gopkg.in/src-d/go-git.v4/plumbing/format/pktline"
 	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/sideband"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 	"gopkg.in/src-d/go-git.v4/utils/ioutil"
 )
@@ -71,3 +73,36 @@ func ServeReceivePack(cmd ServerCommand, s transport.ReceivePackSession) error {
 
 	return nil
 }
+
+func ServeUploadArchive(cmd ServerCommand, s transport.UploadArchiveSession) (err error) {
+	ioutil.CheckClose(cmd.Stdout, &err)
+
+	req := packp.NewUploadArchiveRequest()
+	if err := req.Decode(cmd.Stdin); err != nil {
+		return fmt.Errorf("error decoding: %s", err)
+	}
+
+	r, err := s.UploadArchive(context.TODO(), req)
+	if err != nil {
+		status := &packp.UploadArchiveStatus{Error: err.Error()}
+		if err := status.Encode(cmd.Stdout); err != nil {
+			return fmt.Errorf("error in encoding status: %s", err)
+		}
+
+		return fmt.Errorf("error in upload archive: %s", err)
+	}
+
+	defer ioutil.CheckClose(r, &err)
+
+	status := &packp.UploadArchiveStatus{}
+	if err := status.Encode(cmd.Stdout); err != nil {
+		return fmt.Errorf("error in encoding status: %s", err)
+	}
+
+	m := sideband.NewMuxer(sideband.Sideband64k, cmd.Stdout)
+	if _, err := io.Copy(m, r); err != nil {
+		return fmt.Errorf("error in archive: %s", err)
+	}
+
+	return pktline.NewEncoder(cmd.Stdout).Flush()
+}
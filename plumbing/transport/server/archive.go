@@ -0,0 +1,241 @@
+package server
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/archive"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+var (
+	// ErrMissingArchiveRevision is returned by UploadArchive if the request
+	// names no tree-ish to archive.
+	ErrMissingArchiveRevision = errors.New("no tree-ish to archive")
+	// ErrUnknownArchiveRevision is returned by UploadArchive if the tree-ish
+	// is not a reference nor, unless uploadarchive.allowUnreachable is set,
+	// the hash of an advertised one.
+	ErrUnknownArchiveRevision = errors.New("not a valid tree-ish to archive")
+)
+
+func (s *server) NewUploadArchiveSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.UploadArchiveSession, error) {
+	sto, err := s.loader.Load(ep)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.handler.NewUploadArchiveSession(sto)
+}
+
+func (h *handler) NewUploadArchiveSession(s storer.Storer) (transport.UploadArchiveSession, error) {
+	c, err := storerConfig(s)
+	if err != nil {
+		return nil, err
+	}
+
+	sess := &uaSession{session: session{storer: s, asClient: h.asClient}}
+	if c == nil {
+		return sess, nil
+	}
+
+	if sess.allowUnreachable, err = c.UploadArchiveAllowUnreachable(); err != nil {
+		return nil, err
+	}
+
+	cfg, err := c.UploadPack()
+	if err != nil {
+		return nil, err
+	}
+
+	sess.hideRefs = cfg.HideRefs
+	return sess, nil
+}
+
+type uaSession struct {
+	session
+	allowUnreachable bool
+}
+
+func (s *uaSession) UploadArchive(ctx context.Context, req *packp.UploadArchiveRequest) (io.ReadCloser, error) {
+	opts, rev, err := parseArchiveArguments(req.Arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := s.resolveArchiveRevision(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := s.archiveTree(obj, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(archive.Write(pw, s.storer, tree, opts))
+	}()
+
+	return ioutil.NewContextReadCloser(ctx, pr), nil
+}
+
+// parseArchiveArguments parses the git-archive arguments of a request,
+// returning the options of the archive and its tree-ish. The compression
+// levels, -0 to -9, are ignored.
+func parseArchiveArguments(args []string) (*archive.Options, string, error) {
+	opts := &archive.Options{}
+
+	var rev string
+	var options = true
+	for _, arg := range args {
+		switch {
+		case options && arg == "--":
+			options = false
+		case options && strings.HasPrefix(arg, "--format="):
+			opts.Format = archive.Format(strings.TrimPrefix(arg, "--format="))
+		case options && strings.HasPrefix(arg, "--prefix="):
+			opts.Prefix = strings.TrimPrefix(arg, "--prefix=")
+		case options && len(arg) == 2 && arg[0] == '-' && arg[1] >= '0' && arg[1] <= '9':
+		case options && strings.HasPrefix(arg, "-"):
+			return nil, "", fmt.Errorf("unsupported archive argument: %s", arg)
+		case rev == "":
+			rev = arg
+		default:
+			opts.Paths = append(opts.Paths, arg)
+		}
+	}
+
+	if rev == "" {
+		return nil, "", ErrMissingArchiveRevision
+	}
+
+	switch opts.Format {
+	case archive.Tar, archive.Zip, "":
+	default:
+		return nil, "", archive.ErrUnsupportedFormat
+	}
+
+	return opts, rev, nil
+}
+
+// resolveArchiveRevision returns the object named by the tree-ish, a not
+// hidden reference or, if allowed, a hash.
+func (s *uaSession) resolveArchiveRevision(rev string) (plumbing.EncodedObject, error) {
+	for _, n := range []plumbing.ReferenceName{
+		plumbing.ReferenceName(rev),
+		plumbing.ReferenceName("refs/heads/" + rev),
+		plumbing.ReferenceName("refs/tags/" + rev),
+	} {
+		if n != plumbing.HEAD && !strings.HasPrefix(n.String(), "refs/") {
+			continue
+		}
+
+		if config.IsHiddenRef(s.hideRefs, n) {
+			continue
+		}
+
+		ref, err := storer.ResolveReference(s.storer, n)
+		if err == plumbing.ErrReferenceNotFound {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return s.storer.EncodedObject(plumbing.AnyObject, ref.Hash())
+	}
+
+	if !isHash(rev) {
+		return nil, ErrUnknownArchiveRevision
+	}
+
+	h := plumbing.NewHash(rev)
+	if !s.allowUnreachable {
+		advertised, err := s.isAdvertised(h)
+		if err != nil {
+			return nil, err
+		}
+
+		if !advertised {
+			return nil, ErrUnknownArchiveRevision
+		}
+	}
+
+	obj, err := s.storer.EncodedObject(plumbing.AnyObject, h)
+	if err == plumbing.ErrObjectNotFound {
+		return nil, ErrUnknownArchiveRevision
+	}
+
+	return obj, err
+}
+
+func isHash(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// isAdvertised returns true if h is the hash of a not hidden reference.
+func (s *uaSession) isAdvertised(h plumbing.Hash) (bool, error) {
+	iter, err := s.storer.IterReferences()
+	if err != nil {
+		return false, err
+	}
+
+	var found bool
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference || ref.Hash() != h {
+			return nil
+		}
+
+		if config.IsHiddenRef(s.hideRefs, ref.Name()) {
+			return nil
+		}
+
+		found = true
+		return storer.ErrStop
+	})
+
+	return found, err
+}
+
+// archiveTree peels the object to its tree, filling the commit and the
+// modification time of the options when archiving a commit.
+func (s *uaSession) archiveTree(obj plumbing.EncodedObject, opts *archive.Options) (*object.Tree, error) {
+	o, err := object.DecodeObject(s.storer, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch v := o.(type) {
+		case *object.Tag:
+			if o, err = v.Object(); err != nil {
+				return nil, err
+			}
+		case *object.Commit:
+			opts.Commit = v.Hash
+			opts.ModTime = v.Committer.When
+			return v.Tree()
+		case *object.Tree:
+			return v, nil
+		default:
+			return nil, ErrUnknownArchiveRevision
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/revlist"
+)
+
+// ErrUnsupportedFilter is returned by UploadPack if the filter-spec of the
+// request is not one of blob:none, blob:limit=<n> and tree:depth=<n>.
+var ErrUnsupportedFilter = errors.New("unsupported filter")
+
+// objectFilter holds a parsed filter-spec, a negative value means the
+// objects are not filtered by that criteria.
+type objectFilter struct {
+	// blobLimit is the size from which blobs are omitted.
+	blobLimit int64
+	// treeDepth is the depth from the root tree from which trees and blobs
+	// are omitted.
+	treeDepth int
+}
+
+func parseFilter(f packp.Filter) (*objectFilter, error) {
+	of := &objectFilter{blobLimit: -1, treeDepth: -1}
+
+	spec := string(f)
+	switch {
+	case spec == string(packp.FilterBlobNone):
+		of.blobLimit = 0
+	case strings.HasPrefix(spec, "blob:limit="):
+		n, err := parseFilterSize(strings.TrimPrefix(spec, "blob:limit="))
+		if err != nil {
+			return nil, ErrUnsupportedFilter
+		}
+
+		of.blobLimit = n
+	case strings.HasPrefix(spec, "tree:depth="):
+		n, err := strconv.Atoi(strings.TrimPrefix(spec, "tree:depth="))
+		if err != nil || n < 0 {
+			return nil, ErrUnsupportedFilter
+		}
+
+		of.treeDepth = n
+	default:
+		return nil, ErrUnsupportedFilter
+	}
+
+	return of, nil
+}
+
+// parseFilterSize parses a size in bytes with an optional k, m or g suffix.
+func parseFilterSize(s string) (int64, error) {
+	var mult int64 = 1
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'k', 'K':
+			mult = 1 << 10
+		case 'm', 'M':
+			mult = 1 << 20
+		case 'g', 'G':
+			mult = 1 << 30
+		}
+
+		if mult != 1 {
+			s = s[:n-1]
+		}
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if n < 0 {
+		return 0, strconv.ErrRange
+	}
+
+	return n * mult, nil
+}
+
+// applyFilter sets up the walker of the objects to upload to omit the ones
+// excluded by the given filter-spec. The wanted objects are never omitted,
+// even if the filter excludes them.
+func applyFilter(w *revlist.ObjectWalker, f packp.Filter, wants []plumbing.Hash) error {
+	of, err := parseFilter(f)
+	if err != nil {
+		return err
+	}
+
+	w.MaxTreeDepth = of.treeDepth
+	if of.blobLimit < 0 {
+		return nil
+	}
+
+	wanted := make(map[plumbing.Hash]bool, len(wants))
+	for _, h := range wants {
+		wanted[h] = true
+	}
+
+	limit := revlist.BlobLimitFilter(of.blobLimit)
+	w.Filters = append(w.Filters, func(o plumbing.EncodedObject) bool {
+		return wanted[o.Hash()] || limit(o)
+	})
+
+	return nil
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/storage"
 	"gopkg.in/src-d/go-git.v4/storage/filesystem"
 
 	"gopkg.in/src-d/go-billy.v4"
@@ -61,3 +62,33 @@ func (l MapLoader) Load(ep *transport.Endpoint) (storer.Storer, error) {
 
 	return s, nil
 }
+
+type namespacedLoader struct {
+	Loader
+	namespace string
+}
+
+// NewNamespacedLoader creates a Loader that loads the storers of l viewing
+// only the references of the given namespace, as GIT_NAMESPACE does, see
+// storage.NewNamespacedStorer.
+func NewNamespacedLoader(l Loader, namespace string) Loader {
+	return &namespacedLoader{Loader: l, namespace: namespace}
+}
+
+// Load loads the storer.Storer of the endpoint with the underlying Loader,
+// returning a view of its namespace.
+func (l *namespacedLoader) Load(ep *transport.Endpoint) (storer.Storer, error) {
+	s, err := l.Loader.Load(ep)
+	if err != nil {
+		return nil, err
+	}
+
+	if s, ok := s.(storage.Storer); ok {
+		return storage.NewNamespacedStorer(s, l.namespace), nil
+	}
+
+	return &struct {
+		storer.EncodedObjectStorer
+		storer.ReferenceStorer
+	}{s, storage.NewNamespacedReferenceStorer(s, l.namespace)}, nil
+}
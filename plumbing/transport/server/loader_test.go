@@ -4,6 +4,7 @@ import (
 	"os/exec"
 	"path/filepath"
 
+	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 	"gopkg.in/src-d/go-git.v4/storage/memory"
 
@@ -70,3 +71,25 @@ func (s *LoaderSuite) TestMapLoader(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(sto, Equals, loaderSto)
 }
+
+func (s *LoaderSuite) TestNamespacedLoader(c *C) {
+	ep, err := transport.NewEndpoint("file://test")
+	c.Assert(err, IsNil)
+
+	sto := memory.NewStorage()
+	h := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	c.Assert(sto.SetReference(plumbing.NewHashReference("refs/namespaces/foo/refs/heads/master", h)), IsNil)
+	c.Assert(sto.SetReference(plumbing.NewHashReference("refs/heads/master", plumbing.ZeroHash)), IsNil)
+
+	loader := NewNamespacedLoader(MapLoader{ep.String(): sto}, "foo")
+
+	loaderSto, err := loader.Load(ep)
+	c.Assert(err, IsNil)
+
+	ref, err := loaderSto.Reference(plumbing.Master)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, h)
+
+	_, err = loader.Load(&transport.Endpoint{Path: "missing"})
+	c.Assert(err, Equals, transport.ErrRepositoryNotFound)
+}
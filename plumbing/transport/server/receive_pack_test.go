@@ -1,7 +1,19 @@
 package server_test
 
 import (
+	"bytes"
+	"context"
+	"io/ioutil"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/fsck"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/capability"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/server"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
 
 	. "gopkg.in/check.v1"
 )
@@ -31,3 +43,87 @@ func (s *ReceivePackSuite) TestAdvertisedReferencesNotExists(c *C) {
 	c.Assert(err, Equals, transport.ErrRepositoryNotFound)
 	c.Assert(r, IsNil)
 }
+
+func (s *ReceivePackSuite) TestReceivePackHideRefs(c *C) {
+	cs := s.loader[s.Endpoint.String()].(config.ConfigStorer)
+	cfg, err := cs.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.AddOption("transfer", "", "hideRefs", "refs/remotes")
+	cfg.Raw.AddOption("receive", "", "hideRefs", "refs/heads/branch")
+	c.Assert(cs.SetConfig(cfg), IsNil)
+
+	r, err := s.Client.NewReceivePackSession(s.Endpoint, s.EmptyAuth)
+	c.Assert(err, IsNil)
+	defer func() { c.Assert(r.Close(), IsNil) }()
+
+	info, err := r.AdvertisedReferences()
+	c.Assert(err, IsNil)
+	for name := range info.References {
+		c.Assert(config.IsHiddenRef(cfg.ReceivePackHideRefs(), plumbing.ReferenceName(name)), Equals, false)
+	}
+	c.Assert(info.References["refs/heads/master"], Not(Equals), plumbing.ZeroHash)
+
+	req := packp.NewReferenceUpdateRequest()
+	req.Capabilities.Set(capability.ReportStatus)
+	req.Commands = []*packp.Command{{
+		Name: "refs/heads/branch",
+		Old:  plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881"),
+		New:  plumbing.ZeroHash,
+	}}
+
+	report, err := r.ReceivePack(context.Background(), req)
+	c.Assert(err, Equals, server.ErrUpdateHiddenReference)
+	c.Assert(report.CommandStatuses, HasLen, 1)
+	c.Assert(report.CommandStatuses[0].Status, Equals, server.ErrUpdateHiddenReference.Error())
+}
+
+func (s *ReceivePackSuite) TestReceivePackFsckObjects(c *C) {
+	sto := s.loader[s.Endpoint.String()]
+	cs := sto.(config.ConfigStorer)
+	cfg, err := cs.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.AddOption("receive", "", "fsckObjects", "true")
+	c.Assert(cs.SetConfig(cfg), IsNil)
+
+	r, err := s.Client.NewReceivePackSession(s.Endpoint, s.EmptyAuth)
+	c.Assert(err, IsNil)
+	defer func() { c.Assert(r.Close(), IsNil) }()
+
+	_, err = r.AdvertisedReferences()
+	c.Assert(err, IsNil)
+
+	// a packfile with a commit whose tree is missing
+	missing := plumbing.NewHash("0000000000000000000000000000000000000001")
+	objects := memory.NewStorage()
+	commit := objects.NewEncodedObject()
+	commit.SetType(plumbing.CommitObject)
+	w, err := commit.Writer()
+	c.Assert(err, IsNil)
+	ident := "foo <foo@example.com> 1257894000 +0100"
+	_, err = w.Write([]byte("tree " + missing.String() + "\nauthor " + ident +
+		"\ncommitter " + ident + "\n\nfoo\n"))
+	c.Assert(err, IsNil)
+	c.Assert(w.Close(), IsNil)
+	h, err := objects.SetEncodedObject(commit)
+	c.Assert(err, IsNil)
+
+	var pack bytes.Buffer
+	_, err = packfile.NewEncoder(&pack, objects, false).Encode([]plumbing.Hash{h}, 0)
+	c.Assert(err, IsNil)
+
+	req := packp.NewReferenceUpdateRequest()
+	req.Capabilities.Set(capability.ReportStatus)
+	req.Commands = []*packp.Command{{
+		Name: "refs/heads/new",
+		Old:  plumbing.ZeroHash,
+		New:  h,
+	}}
+	req.Packfile = ioutil.NopCloser(&pack)
+
+	report, err := r.ReceivePack(context.Background(), req)
+	c.Assert(err, DeepEquals, &fsck.MissingObjectError{Hash: missing})
+	c.Assert(report.UnpackStatus, Equals, err.Error())
+
+	_, err = sto.Reference("refs/heads/new")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
@@ -8,8 +8,10 @@ import (
 	"fmt"
 	"io"
 
+	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/fsck"
 	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
 	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/capability"
 	"gopkg.in/src-d/go-git.v4/plumbing/revlist"
@@ -52,6 +54,20 @@ func (s *server) NewUploadPackSession(ep *transport.Endpoint, auth transport.Aut
 	return s.handler.NewUploadPackSession(sto)
 }
 
+func (s *server) NewLsRefsSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.LsRefsSession, error) {
+	sto, err := s.loader.Load(ep)
+	if err != nil {
+		return nil, err
+	}
+
+	sess, err := s.handler.NewUploadPackSession(sto)
+	if err != nil {
+		return nil, err
+	}
+
+	return sess.(*upSession), nil
+}
+
 func (s *server) NewReceivePackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.ReceivePackSession, error) {
 	sto, err := s.loader.Load(ep)
 	if err != nil {
@@ -66,22 +82,69 @@ type handler struct {
 }
 
 func (h *handler) NewUploadPackSession(s storer.Storer) (transport.UploadPackSession, error) {
+	c, err := storerConfig(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg config.UploadPack
+	if c != nil {
+		if cfg, err = c.UploadPack(); err != nil {
+			return nil, err
+		}
+	}
+
 	return &upSession{
-		session: session{storer: s, asClient: h.asClient},
+		session: session{storer: s, asClient: h.asClient, hideRefs: cfg.HideRefs},
+		config:  cfg,
 	}, nil
 }
 
 func (h *handler) NewReceivePackSession(s storer.Storer) (transport.ReceivePackSession, error) {
+	c, err := storerConfig(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var hideRefs []string
+	var fsckObjects bool
+	var fsckSeverities fsck.Severities
+	if c != nil {
+		hideRefs = c.ReceivePackHideRefs()
+		if fsckObjects, err = c.ReceiveFsckObjects(); err != nil {
+			return nil, err
+		}
+
+		if fsckSeverities, err = fsck.ParseSeverities(c.ReceiveFsckOptions()); err != nil {
+			return nil, err
+		}
+	}
+
 	return &rpSession{
-		session:   session{storer: s, asClient: h.asClient},
-		cmdStatus: map[plumbing.ReferenceName]error{},
+		session:        session{storer: s, asClient: h.asClient, hideRefs: hideRefs},
+		cmdStatus:      map[plumbing.ReferenceName]error{},
+		fsckObjects:    fsckObjects,
+		fsckSeverities: fsckSeverities,
 	}, nil
 }
 
+// storerConfig returns the config of the storer, nil if it doesn't store one.
+func storerConfig(s storer.Storer) (*config.Config, error) {
+	cs, ok := s.(config.ConfigStorer)
+	if !ok {
+		return nil, nil
+	}
+
+	return cs.Config()
+}
+
 type session struct {
 	storer   storer.Storer
 	caps     *capability.List
 	asClient bool
+	// hideRefs are the patterns of the references omitted from the
+	// advertisement, see config.IsHiddenRef.
+	hideRefs []string
 }
 
 func (s *session) Close() error {
@@ -105,6 +168,7 @@ func (s *session) checkSupportedCapabilities(cl *capability.List) error {
 
 type upSession struct {
 	session
+	config config.UploadPack
 }
 
 func (s *upSession) AdvertisedReferences() (*packp.AdvRefs, error) {
@@ -116,11 +180,11 @@ func (s *upSession) AdvertisedReferences() (*packp.AdvRefs, error) {
 
 	s.caps = ar.Capabilities
 
-	if err := setReferences(s.storer, ar); err != nil {
+	if err := setReferences(s.storer, ar, s.hideRefs); err != nil {
 		return nil, err
 	}
 
-	if err := setHEAD(s.storer, ar); err != nil {
+	if err := setHEAD(s.storer, ar, s.hideRefs); err != nil {
 		return nil, err
 	}
 
@@ -131,6 +195,17 @@ func (s *upSession) AdvertisedReferences() (*packp.AdvRefs, error) {
 	return ar, nil
 }
 
+// LsRefs returns the advertised references matching the request.
+func (s *upSession) LsRefs(ctx context.Context, req *packp.LsRefsRequest) (*packp.AdvRefs, error) {
+	ar, err := s.AdvertisedReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	req.FilterAdvRefs(ar)
+	return ar, nil
+}
+
 func (s *upSession) UploadPack(ctx context.Context, req *packp.UploadPackRequest) (*packp.UploadPackResponse, error) {
 	if req.IsEmpty() {
 		return nil, transport.ErrEmptyUploadPackRequest
@@ -157,6 +232,10 @@ func (s *upSession) UploadPack(ctx context.Context, req *packp.UploadPackRequest
 		return nil, fmt.Errorf("shallow not supported")
 	}
 
+	if err := s.checkWants(req.Wants); err != nil {
+		return nil, err
+	}
+
 	objs, err := s.objectsToUpload(req)
 	if err != nil {
 		return nil, err
@@ -175,16 +254,56 @@ func (s *upSession) UploadPack(ctx context.Context, req *packp.UploadPackRequest
 	), nil
 }
 
+// objectsToUpload returns the objects reachable from the wants and not from
+// the haves, omitting the ones excluded by the filter of the request.
 func (s *upSession) objectsToUpload(req *packp.UploadPackRequest) ([]plumbing.Hash, error) {
-	haves, err := revlist.Objects(s.storer, req.Haves, nil)
+	w := revlist.NewObjectWalker(s.storer)
+	if req.Filter != "" {
+		if err := applyFilter(w, req.Filter, req.Wants); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.Hide(req.Haves...); err != nil {
+		return nil, err
+	}
+
+	var objs []plumbing.Hash
+	err := w.Walk(func(h plumbing.Hash, _ plumbing.ObjectType) error {
+		objs = append(objs, h)
+		return nil
+	}, req.Wants...)
 	if err != nil {
 		return nil, err
 	}
 
-	return revlist.Objects(s.storer, req.Wants, haves)
+	return objs, nil
 }
 
-func (*upSession) setSupportedCapabilities(c *capability.List) error {
+// checkWants returns ErrHiddenReferenceWanted if any of the wants is the tip
+// of a hidden reference not advertised, unless uploadpack.allowTipSHA1InWant
+// or uploadpack.allowReachableSHA1InWant allow it.
+func (s *upSession) checkWants(wants []plumbing.Hash) error {
+	if len(s.hideRefs) == 0 ||
+		s.config.AllowTipSHA1InWant || s.config.AllowReachableSHA1InWant {
+		return nil
+	}
+
+	hidden, err := hiddenTips(s.storer, s.hideRefs)
+	if err != nil {
+		return err
+	}
+
+	for _, w := range wants {
+		if hidden[w] {
+			return ErrHiddenReferenceWanted
+		}
+	}
+
+	return nil
+}
+
+func (s *upSession) setSupportedCapabilities(c *capability.List) error {
 	if err := c.Set(capability.Agent, capability.DefaultAgent); err != nil {
 		return err
 	}
@@ -193,6 +312,22 @@ func (*upSession) setSupportedCapabilities(c *capability.List) error {
 		return err
 	}
 
+	if err := c.Set(capability.Filter); err != nil {
+		return err
+	}
+
+	if s.config.AllowTipSHA1InWant {
+		if err := c.Set(capability.AllowTipSHA1InWant); err != nil {
+			return err
+		}
+	}
+
+	if s.config.AllowReachableSHA1InWant {
+		if err := c.Set(capability.AllowReachableSHA1InWant); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -201,6 +336,12 @@ type rpSession struct {
 	cmdStatus map[plumbing.ReferenceName]error
 	firstErr  error
 	unpackErr error
+	// fsckObjects checks the pushed objects before updating the references,
+	// as receive.fsckObjects says.
+	fsckObjects bool
+	// fsckSeverities override the severity of the problems found, as
+	// receive.fsck.<msg-id> says.
+	fsckSeverities fsck.Severities
 }
 
 func (s *rpSession) AdvertisedReferences() (*packp.AdvRefs, error) {
@@ -212,11 +353,11 @@ func (s *rpSession) AdvertisedReferences() (*packp.AdvRefs, error) {
 
 	s.caps = ar.Capabilities
 
-	if err := setReferences(s.storer, ar); err != nil {
+	if err := setReferences(s.storer, ar, s.hideRefs); err != nil {
 		return nil, err
 	}
 
-	if err := setHEAD(s.storer, ar); err != nil {
+	if err := setHEAD(s.storer, ar, s.hideRefs); err != nil {
 		return nil, err
 	}
 
@@ -225,6 +366,12 @@ func (s *rpSession) AdvertisedReferences() (*packp.AdvRefs, error) {
 
 var (
 	ErrUpdateReference = errors.New("failed to update ref")
+	// ErrUpdateHiddenReference is the status of the commands updating a
+	// reference hidden by receive.hideRefs.
+	ErrUpdateHiddenReference = errors.New("deny updating a hidden ref")
+	// ErrHiddenReferenceWanted is returned by UploadPack when a want is the
+	// tip of a reference hidden by uploadpack.hideRefs.
+	ErrHiddenReferenceWanted = errors.New("want of a hidden ref not allowed")
 )
 
 func (s *rpSession) ReceivePack(ctx context.Context, req *packp.ReferenceUpdateRequest) (*packp.ReportStatus, error) {
@@ -243,19 +390,34 @@ func (s *rpSession) ReceivePack(ctx context.Context, req *packp.ReferenceUpdateR
 
 	//TODO: Implement 'atomic' update of references.
 
-	r := ioutil.NewContextReadCloser(ctx, req.Packfile)
+	var r io.ReadCloser
+	if req.Packfile != nil {
+		r = ioutil.NewContextReadCloser(ctx, req.Packfile)
+	}
+
 	if err := s.writePackfile(r); err != nil {
 		s.unpackErr = err
 		s.firstErr = err
 		return s.reportStatus(), err
 	}
 
+	if err := s.checkObjects(req); err != nil {
+		s.unpackErr = err
+		s.firstErr = err
+		return s.reportStatus(), err
+	}
+
 	s.updateReferences(req)
 	return s.reportStatus(), s.firstErr
 }
 
 func (s *rpSession) updateReferences(req *packp.ReferenceUpdateRequest) {
 	for _, cmd := range req.Commands {
+		if config.IsHiddenRef(s.hideRefs, cmd.Name) {
+			s.setStatus(cmd.Name, ErrUpdateHiddenReference)
+			continue
+		}
+
 		exists, err := referenceExists(s.storer, cmd.Name)
 		if err != nil {
 			s.setStatus(cmd.Name, err)
@@ -298,6 +460,48 @@ func (s *rpSession) updateReferences(req *packp.ReferenceUpdateRequest) {
 	}
 }
 
+// checkObjects checks the objects reachable from the new values of the
+// references, if fsckObjects is set, but not from their current values.
+func (s *rpSession) checkObjects(req *packp.ReferenceUpdateRequest) error {
+	if !s.fsckObjects {
+		return nil
+	}
+
+	var tips []plumbing.Hash
+	for _, cmd := range req.Commands {
+		if cmd.Action() != packp.Delete {
+			tips = append(tips, cmd.New)
+		}
+	}
+
+	iter, err := s.storer.IterReferences()
+	if err != nil {
+		return err
+	}
+
+	var known []plumbing.Hash
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference {
+			known = append(known, ref.Hash())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c := fsck.NewChecker(s.storer)
+	c.Severities = s.fsckSeverities
+	if ss, ok := s.storer.(storer.ShallowStorer); ok {
+		if c.Shallow, err = ss.Shallow(); err != nil {
+			return err
+		}
+	}
+
+	return c.Check(tips, known)
+}
+
 func (s *rpSession) writePackfile(r io.ReadCloser) error {
 	if r == nil {
 		return nil
@@ -365,7 +569,11 @@ func (*rpSession) setSupportedCapabilities(c *capability.List) error {
 	return c.Set(capability.ReportStatus)
 }
 
-func setHEAD(s storer.Storer, ar *packp.AdvRefs) error {
+func setHEAD(s storer.Storer, ar *packp.AdvRefs, hideRefs []string) error {
+	if config.IsHiddenRef(hideRefs, plumbing.HEAD) {
+		return nil
+	}
+
 	ref, err := s.Reference(plumbing.HEAD)
 	if err == plumbing.ErrReferenceNotFound {
 		return nil
@@ -400,7 +608,7 @@ func setHEAD(s storer.Storer, ar *packp.AdvRefs) error {
 	return nil
 }
 
-func setReferences(s storer.Storer, ar *packp.AdvRefs) error {
+func setReferences(s storer.Storer, ar *packp.AdvRefs, hideRefs []string) error {
 	//TODO: add peeled references.
 	iter, err := s.IterReferences()
 	if err != nil {
@@ -412,11 +620,49 @@ func setReferences(s storer.Storer, ar *packp.AdvRefs) error {
 			return nil
 		}
 
+		if config.IsHiddenRef(hideRefs, ref.Name()) {
+			return nil
+		}
+
 		ar.References[ref.Name().String()] = ref.Hash()
 		return nil
 	})
 }
 
+// hiddenTips returns the hashes of the hidden references not advertised by
+// any other reference.
+func hiddenTips(s storer.Storer, hideRefs []string) (map[plumbing.Hash]bool, error) {
+	iter, err := s.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	hidden := make(map[plumbing.Hash]bool)
+	advertised := make(map[plumbing.Hash]bool)
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+
+		if config.IsHiddenRef(hideRefs, ref.Name()) {
+			hidden[ref.Hash()] = true
+		} else {
+			advertised[ref.Hash()] = true
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for h := range advertised {
+		delete(hidden, h)
+	}
+
+	return hidden, nil
+}
+
 func referenceExists(s storer.ReferenceStorer, n plumbing.ReferenceName) (bool, error) {
 	_, err := s.Reference(n)
 	if err == plumbing.ErrReferenceNotFound {
@@ -0,0 +1,132 @@
+package server_test
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/archive"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/server"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+)
+
+type UploadArchiveSuite struct {
+	fixtures.Suite
+
+	loader   server.MapLoader
+	client   transport.UploadArchiveTransport
+	endpoint *transport.Endpoint
+}
+
+var _ = Suite(&UploadArchiveSuite{})
+
+func (s *UploadArchiveSuite) SetUpTest(c *C) {
+	s.loader = server.MapLoader{}
+	s.client = server.NewServer(s.loader).(transport.UploadArchiveTransport)
+
+	fs := fixtures.Basic().One().DotGit()
+
+	var err error
+	s.endpoint, err = transport.NewEndpoint(fs.Root())
+	c.Assert(err, IsNil)
+	s.loader[s.endpoint.String()], err = filesystem.NewStorage(fs)
+	c.Assert(err, IsNil)
+}
+
+func (s *UploadArchiveSuite) uploadArchive(c *C, args ...string) (map[string]*tar.Header, error) {
+	sess, err := s.client.NewUploadArchiveSession(s.endpoint, nil)
+	c.Assert(err, IsNil)
+	defer func() { c.Assert(sess.Close(), IsNil) }()
+
+	req := packp.NewUploadArchiveRequest()
+	req.Arguments = args
+
+	r, err := sess.UploadArchive(context.Background(), req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { c.Assert(r.Close(), IsNil) }()
+
+	headers := make(map[string]*tar.Header)
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return headers, nil
+		}
+
+		c.Assert(err, IsNil)
+		headers[h.Name] = h
+	}
+}
+
+func (s *UploadArchiveSuite) setConfig(c *C, section, key, value string) {
+	cs := s.loader[s.endpoint.String()].(config.ConfigStorer)
+	cfg, err := cs.Config()
+	c.Assert(err, IsNil)
+
+	cfg.Raw.AddOption(section, "", key, value)
+	c.Assert(cs.SetConfig(cfg), IsNil)
+}
+
+func (s *UploadArchiveSuite) TestUploadArchive(c *C) {
+	headers, err := s.uploadArchive(c, "--format=tar", "--prefix=basic/", "master", "--", "vendor")
+	c.Assert(err, IsNil)
+	c.Assert(headers, HasLen, 4)
+	c.Assert(headers["pax_global_header"].PAXRecords["comment"], Equals,
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	c.Assert(headers["basic/"], NotNil)
+	c.Assert(headers["basic/vendor/"], NotNil)
+	c.Assert(headers["basic/vendor/foo.go"], NotNil)
+}
+
+func (s *UploadArchiveSuite) TestUploadArchiveTag(c *C) {
+	headers, err := s.uploadArchive(c, "v1.0.0", "json")
+	c.Assert(err, IsNil)
+	c.Assert(headers, HasLen, 4)
+	c.Assert(headers["json/long.json"], NotNil)
+}
+
+func (s *UploadArchiveSuite) TestUploadArchiveMissingRevision(c *C) {
+	_, err := s.uploadArchive(c, "--format=tar")
+	c.Assert(err, Equals, server.ErrMissingArchiveRevision)
+}
+
+func (s *UploadArchiveSuite) TestUploadArchiveUnsupportedFormat(c *C) {
+	_, err := s.uploadArchive(c, "--format=rar", "master")
+	c.Assert(err, Equals, archive.ErrUnsupportedFormat)
+}
+
+func (s *UploadArchiveSuite) TestUploadArchiveUnknownRevision(c *C) {
+	_, err := s.uploadArchive(c, "foo")
+	c.Assert(err, Equals, server.ErrUnknownArchiveRevision)
+}
+
+func (s *UploadArchiveSuite) TestUploadArchiveUnreachable(c *C) {
+	parent := "918c48b83bd081e863dbe1b80f8998f058cd8294"
+	_, err := s.uploadArchive(c, parent)
+	c.Assert(err, Equals, server.ErrUnknownArchiveRevision)
+
+	s.setConfig(c, "uploadarchive", "allowUnreachable", "true")
+	headers, err := s.uploadArchive(c, parent)
+	c.Assert(err, IsNil)
+	c.Assert(headers["pax_global_header"].PAXRecords["comment"], Equals, parent)
+}
+
+func (s *UploadArchiveSuite) TestUploadArchiveHiddenRef(c *C) {
+	s.setConfig(c, "uploadpack", "hideRefs", "refs/heads/branch")
+	s.setConfig(c, "uploadpack", "hideRefs", "refs/remotes")
+
+	_, err := s.uploadArchive(c, "branch")
+	c.Assert(err, Equals, server.ErrUnknownArchiveRevision)
+
+	_, err = s.uploadArchive(c, "e8d3ffab552895c19b9fcf7aa264d277cde33881")
+	c.Assert(err, Equals, server.ErrUnknownArchiveRevision)
+}
@@ -1,7 +1,20 @@
 package server_test
 
 import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/capability"
 	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/server"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
 
 	. "gopkg.in/check.v1"
 )
@@ -32,6 +45,181 @@ func (s *UploadPackSuite) TestUploadPackWithContext(c *C) {
 	c.Skip("UploadPack cannot be canceled on server")
 }
 
+func (s *UploadPackSuite) TestAdvertisedReferencesFilter(c *C) {
+	r, err := s.Client.NewUploadPackSession(s.Endpoint, s.EmptyAuth)
+	c.Assert(err, IsNil)
+	defer func() { c.Assert(r.Close(), IsNil) }()
+
+	info, err := r.AdvertisedReferences()
+	c.Assert(err, IsNil)
+	c.Assert(info.Capabilities.Supports(capability.Filter), Equals, true)
+}
+
+func (s *UploadPackSuite) TestUploadPackFilterBlobNone(c *C) {
+	sto := s.uploadPackFiltered(c, packp.FilterBlobNone)
+	c.Assert(s.countObjects(c, sto, plumbing.BlobObject), Equals, 0)
+	c.Assert(s.countObjects(c, sto, plumbing.TreeObject), Not(Equals), 0)
+	c.Assert(s.countObjects(c, sto, plumbing.CommitObject), Equals, 8)
+}
+
+func (s *UploadPackSuite) TestUploadPackFilterBlobLimit(c *C) {
+	none := s.uploadPackFiltered(c, packp.FilterBlobLimit(0))
+	c.Assert(s.countObjects(c, none, plumbing.BlobObject), Equals, 0)
+
+	all := s.uploadPackFiltered(c, packp.FilterBlobLimit(1<<30))
+	some := s.uploadPackFiltered(c, packp.Filter("blob:limit=1k"))
+
+	iter, err := some.IterEncodedObjects(plumbing.BlobObject)
+	c.Assert(err, IsNil)
+	err = iter.ForEach(func(o plumbing.EncodedObject) error {
+		c.Assert(o.Size() < 1024, Equals, true)
+		return nil
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(s.countObjects(c, some, plumbing.BlobObject) <
+		s.countObjects(c, all, plumbing.BlobObject), Equals, true)
+}
+
+func (s *UploadPackSuite) TestUploadPackFilterTreeDepth(c *C) {
+	sto := s.uploadPackFiltered(c, packp.FilterTreeDepth(0))
+	c.Assert(s.countObjects(c, sto, plumbing.BlobObject), Equals, 0)
+	c.Assert(s.countObjects(c, sto, plumbing.TreeObject), Equals, 0)
+	c.Assert(s.countObjects(c, sto, plumbing.CommitObject), Equals, 8)
+
+	sto = s.uploadPackFiltered(c, packp.FilterTreeDepth(1))
+	c.Assert(s.countObjects(c, sto, plumbing.BlobObject), Equals, 0)
+
+	commits, err := sto.IterEncodedObjects(plumbing.CommitObject)
+	c.Assert(err, IsNil)
+	roots := map[plumbing.Hash]bool{}
+	err = commits.ForEach(func(o plumbing.EncodedObject) error {
+		commit, err := object.DecodeCommit(sto, o)
+		c.Assert(err, IsNil)
+		roots[commit.TreeHash] = true
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(s.countObjects(c, sto, plumbing.TreeObject), Equals, len(roots))
+}
+
+func (s *UploadPackSuite) TestUploadPackFilterUnsupported(c *C) {
+	r, err := s.Client.NewUploadPackSession(s.Endpoint, s.EmptyAuth)
+	c.Assert(err, IsNil)
+	defer func() { c.Assert(r.Close(), IsNil) }()
+
+	req := packp.NewUploadPackRequest()
+	req.Wants = append(req.Wants, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	req.Capabilities.Set(capability.Filter)
+	req.Filter = packp.Filter("sparse:oid=master:.gitignore")
+
+	_, err = r.UploadPack(context.Background(), req)
+	c.Assert(err, Equals, server.ErrUnsupportedFilter)
+}
+
+func (s *UploadPackSuite) setHideRefs(c *C, allowTip bool) {
+	cs := s.loader[s.Endpoint.String()].(config.ConfigStorer)
+	cfg, err := cs.Config()
+	c.Assert(err, IsNil)
+
+	for _, p := range []string{"refs/remotes", "refs/heads/branch", "refs/tags"} {
+		cfg.Raw.AddOption("uploadpack", "", "hideRefs", p)
+	}
+
+	if allowTip {
+		cfg.Raw.SetOption("uploadpack", "", "allowTipSHA1InWant", "true")
+	}
+
+	c.Assert(cs.SetConfig(cfg), IsNil)
+}
+
+func (s *UploadPackSuite) TestAdvertisedReferencesHideRefs(c *C) {
+	s.setHideRefs(c, false)
+
+	r, err := s.Client.NewUploadPackSession(s.Endpoint, s.EmptyAuth)
+	c.Assert(err, IsNil)
+	defer func() { c.Assert(r.Close(), IsNil) }()
+
+	info, err := r.AdvertisedReferences()
+	c.Assert(err, IsNil)
+	for name := range info.References {
+		c.Assert(name == "refs/heads/branch" || strings.HasPrefix(name, "refs/remotes/") ||
+			strings.HasPrefix(name, "refs/tags/"), Equals, false)
+	}
+
+	c.Assert(info.References["refs/heads/master"], Equals,
+		plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	c.Assert(info.Capabilities.Supports(capability.AllowTipSHA1InWant), Equals, false)
+}
+
+func (s *UploadPackSuite) TestUploadPackHiddenRefWanted(c *C) {
+	s.setHideRefs(c, false)
+
+	r, err := s.Client.NewUploadPackSession(s.Endpoint, s.EmptyAuth)
+	c.Assert(err, IsNil)
+	defer func() { c.Assert(r.Close(), IsNil) }()
+
+	req := packp.NewUploadPackRequest()
+	req.Wants = append(req.Wants, plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881"))
+
+	_, err = r.UploadPack(context.Background(), req)
+	c.Assert(err, Equals, server.ErrHiddenReferenceWanted)
+}
+
+func (s *UploadPackSuite) TestUploadPackHiddenRefWantedAllowTip(c *C) {
+	s.setHideRefs(c, true)
+
+	r, err := s.Client.NewUploadPackSession(s.Endpoint, s.EmptyAuth)
+	c.Assert(err, IsNil)
+	defer func() { c.Assert(r.Close(), IsNil) }()
+
+	info, err := r.AdvertisedReferences()
+	c.Assert(err, IsNil)
+	c.Assert(info.Capabilities.Supports(capability.AllowTipSHA1InWant), Equals, true)
+
+	req := packp.NewUploadPackRequest()
+	req.Wants = append(req.Wants, plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881"))
+
+	resp, err := r.UploadPack(context.Background(), req)
+	c.Assert(err, IsNil)
+	c.Assert(resp.Close(), IsNil)
+}
+
+func (s *UploadPackSuite) uploadPackFiltered(c *C, f packp.Filter) *memory.Storage {
+	r, err := s.Client.NewUploadPackSession(s.Endpoint, s.EmptyAuth)
+	c.Assert(err, IsNil)
+	defer func() { c.Assert(r.Close(), IsNil) }()
+
+	req := packp.NewUploadPackRequest()
+	req.Wants = append(req.Wants, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	req.Capabilities.Set(capability.Filter)
+	req.Filter = f
+
+	resp, err := r.UploadPack(context.Background(), req)
+	c.Assert(err, IsNil)
+	defer func() { c.Assert(resp.Close(), IsNil) }()
+
+	b, err := ioutil.ReadAll(resp)
+	c.Assert(err, IsNil)
+
+	sto := memory.NewStorage()
+	c.Assert(packfile.UpdateObjectStorage(sto, bytes.NewReader(b)), IsNil)
+	return sto
+}
+
+func (s *UploadPackSuite) countObjects(c *C, sto *memory.Storage, t plumbing.ObjectType) int {
+	iter, err := sto.IterEncodedObjects(t)
+	c.Assert(err, IsNil)
+
+	var n int
+	err = iter.ForEach(func(plumbing.EncodedObject) error {
+		n++
+		return nil
+	})
+	c.Assert(err, IsNil)
+	return n
+}
+
 // Tests server with `asClient = true`. This is recommended when using a server
 // registered directly with `client.InstallProtocol`.
 type ClientLikeUploadPackSuite struct {
@@ -48,3 +236,22 @@ func (s *ClientLikeUploadPackSuite) SetUpSuite(c *C) {
 func (s *ClientLikeUploadPackSuite) TestAdvertisedReferencesEmpty(c *C) {
 	s.UploadPackSuite.TestAdvertisedReferencesEmpty(c)
 }
+
+func (s *UploadPackSuite) TestLsRefs(c *C) {
+	r, err := s.client.(transport.LsRefsTransport).NewLsRefsSession(s.Endpoint, s.EmptyAuth)
+	c.Assert(err, IsNil)
+	defer func() { c.Assert(r.Close(), IsNil) }()
+
+	req := packp.NewLsRefsRequest()
+	req.Prefixes = []string{"refs/tags/"}
+
+	ar, err := r.LsRefs(context.Background(), req)
+	c.Assert(err, IsNil)
+	c.Assert(ar.Head, IsNil)
+	c.Assert(ar.References["refs/tags/v1.0.0"].IsZero(), Equals, false)
+	for name := range ar.References {
+		c.Assert(strings.HasPrefix(name, "refs/tags/"), Equals, true, Commentf("%s listed", name))
+	}
+
+	c.Assert(ar.Capabilities.Supports(capability.SymRef), Equals, false)
+}
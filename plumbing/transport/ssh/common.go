@@ -77,6 +77,14 @@ func (c *command) Start() error {
 	return c.Session.Start(endpointToCommand(c.command, c.endpoint))
 }
 
+// SetGitProtocol sets the GIT_PROTOCOL environment variable of the session.
+// Most servers accept it, the ones refusing it get ignored, as they don't
+// support any other version than the protocol v0 anyway.
+func (c *command) SetGitProtocol(value string) error {
+	_ = c.Session.Setenv("GIT_PROTOCOL", value)
+	return nil
+}
+
 // Close closes the SSH session and connection.
 func (c *command) Close() error {
 	if !c.connected {
@@ -13,8 +13,17 @@ type PruneOptions struct {
 	// OnlyObjectsOlderThan if set to non-zero value
 	// selects only objects older than the time provided.
 	OnlyObjectsOlderThan time.Time
+	// Expire if set to non-zero value, and OnlyObjectsOlderThan is not,
+	// selects only objects older than the given duration, as git prune
+	// --expire does.
+	Expire time.Duration
 	// Handler is called on matching objects
 	Handler PruneHandler
+	// DryRun reports the objects to prune to Pruned, without calling
+	// Handler.
+	DryRun bool
+	// Pruned, if not nil, is called with every object pruned.
+	Pruned func(plumbing.Hash)
 }
 
 var ErrLooseObjectsNotSupported = errors.New("Loose objects not supported")
@@ -27,20 +36,60 @@ func (r *Repository) DeleteObject(hash plumbing.Hash) error {
 		return ErrLooseObjectsNotSupported
 	}
 
+	if err := r.checkPreciousObjects(); err != nil {
+		return err
+	}
+
 	return los.DeleteLooseObject(hash)
 }
 
+// checkPreciousObjects returns ErrPreciousObjects if the objects of the
+// repository can't be deleted.
+func (r *Repository) checkPreciousObjects() error {
+	cfg, err := r.Storer.Config()
+	if err != nil {
+		return err
+	}
+
+	if cfg.Extensions.PreciousObjects {
+		return ErrPreciousObjects
+	}
+
+	return nil
+}
+
+// Prune calls the handler of the options with every unreachable loose object,
+// as git prune does. The objects reachable from the references, from the
+// entries of their reflogs and from the index are kept.
 func (r *Repository) Prune(opt PruneOptions) error {
 	los, ok := r.Storer.(storer.LooseObjectStorer)
 	if !ok {
 		return ErrLooseObjectsNotSupported
 	}
 
+	if err := r.checkPreciousObjects(); err != nil {
+		return err
+	}
+
 	pw := newObjectWalker(r.Storer)
 	err := pw.walkAllRefs()
 	if err != nil {
 		return err
 	}
+
+	if err := r.walkReflogs(pw); err != nil {
+		return err
+	}
+
+	if err := r.walkIndex(pw); err != nil {
+		return err
+	}
+
+	expire := opt.OnlyObjectsOlderThan
+	if expire.IsZero() && opt.Expire != 0 {
+		expire = time.Now().Add(-opt.Expire)
+	}
+
 	// Now walk all (loose) objects in storage.
 	return los.ForEachObjectHash(func(hash plumbing.Hash) error {
 		// Get out if we have seen this object.
@@ -49,7 +98,7 @@ func (r *Repository) Prune(opt PruneOptions) error {
 		}
 		// Otherwise it is a candidate for pruning.
 		// Check out for too new objects next.
-		if opt.OnlyObjectsOlderThan != (time.Time{}) {
+		if expire != (time.Time{}) {
 			// Errors here are non-fatal. The object may be e.g. packed.
 			// Or concurrently deleted. Skip such objects.
 			t, err := los.LooseObjectTime(hash)
@@ -57,10 +106,72 @@ func (r *Repository) Prune(opt PruneOptions) error {
 				return nil
 			}
 			// Skip too new objects.
-			if !t.Before(opt.OnlyObjectsOlderThan) {
+			if !t.Before(expire) {
 				return nil
 			}
 		}
-		return opt.Handler(hash)
+
+		if !opt.DryRun {
+			if err := opt.Handler(hash); err != nil {
+				return err
+			}
+		}
+
+		if opt.Pruned != nil {
+			opt.Pruned(hash)
+		}
+
+		return nil
 	})
 }
+
+// walkReflogs walks the objects of the entries of every reflog, the missing
+// ones being skipped.
+func (r *Repository) walkReflogs(pw *objectWalker) error {
+	names, err := reflogNames(r)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		entries, err := readReflog(r, name)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range entries {
+			if err := pw.walkExistingObjects(e.old, e.new); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// walkIndex walks the blobs of the entries of the index and the trees of its
+// cached tree, the missing ones being skipped.
+func (r *Repository) walkIndex(pw *objectWalker) error {
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range idx.Entries {
+		if err := pw.walkExistingObjects(e.Hash); err != nil {
+			return err
+		}
+	}
+
+	if idx.Cache == nil {
+		return nil
+	}
+
+	for _, e := range idx.Cache.Entries {
+		if err := pw.walkExistingObjects(e.Hash); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,14 +1,17 @@
 package git
 
 import (
+	"strings"
 	"time"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 	"gopkg.in/src-d/go-git.v4/storage"
 	"gopkg.in/src-d/go-git.v4/storage/filesystem"
 
 	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/util"
 	"gopkg.in/src-d/go-git-fixtures.v3"
 )
 
@@ -44,6 +47,8 @@ func (s *PruneSuite) testPrune(c *C, deleteTime time.Time) {
 	c.Assert(err, IsNil)
 	err = sto.RemoveReference(plumbing.ReferenceName("refs/remotes/origin/v4"))
 	c.Assert(err, IsNil)
+	// The reflogs would keep the objects of the branch reachable.
+	c.Assert(util.RemoveAll(srcFs, "logs"), IsNil)
 
 	err = r.Prune(PruneOptions{
 		OnlyObjectsOlderThan: deleteTime,
@@ -71,3 +76,111 @@ func (s *PruneSuite) TestPrune(c *C) {
 func (s *PruneSuite) TestPruneWithNoDelete(c *C) {
 	s.testPrune(c, time.Unix(0, 1))
 }
+
+func (s *PruneSuite) TestPrunePreciousObjects(c *C) {
+	srcFs := fixtures.ByTag("unpacked").One().DotGit()
+	sto, err := filesystem.NewStorage(srcFs)
+	c.Assert(err, IsNil)
+
+	r, err := Open(sto, srcFs)
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Extensions.PreciousObjects = true
+	c.Assert(sto.SetConfig(cfg), IsNil)
+
+	err = sto.RemoveReference(plumbing.ReferenceName("refs/heads/v4"))
+	c.Assert(err, IsNil)
+	err = sto.RemoveReference(plumbing.ReferenceName("refs/remotes/origin/v4"))
+	c.Assert(err, IsNil)
+
+	err = r.Prune(PruneOptions{Handler: r.DeleteObject})
+	c.Assert(err, Equals, ErrPreciousObjects)
+
+	err = r.RepackObjects(&RepackConfig{})
+	c.Assert(err, Equals, ErrPreciousObjects)
+}
+
+func (s *PruneSuite) writeBlob(c *C, r *Repository, content string) plumbing.Hash {
+	obj := r.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	c.Assert(err, IsNil)
+	_, err = w.Write([]byte(content))
+	c.Assert(err, IsNil)
+	c.Assert(w.Close(), IsNil)
+
+	h, err := r.Storer.SetEncodedObject(obj)
+	c.Assert(err, IsNil)
+	return h
+}
+
+func (s *PruneSuite) TestPruneDryRun(c *C) {
+	r, err := PlainInit(c.MkDir(), true)
+	c.Assert(err, IsNil)
+
+	h := s.writeBlob(c, r, "foo")
+
+	var pruned []plumbing.Hash
+	err = r.Prune(PruneOptions{
+		DryRun: true,
+		Pruned: func(h plumbing.Hash) { pruned = append(pruned, h) },
+	})
+	c.Assert(err, IsNil)
+	c.Assert(pruned, DeepEquals, []plumbing.Hash{h})
+	c.Assert(r.Storer.HasEncodedObject(h), IsNil)
+
+	pruned = nil
+	err = r.Prune(PruneOptions{
+		Handler: r.DeleteObject,
+		Pruned:  func(h plumbing.Hash) { pruned = append(pruned, h) },
+	})
+	c.Assert(err, IsNil)
+	c.Assert(pruned, DeepEquals, []plumbing.Hash{h})
+	c.Assert(r.Storer.HasEncodedObject(h), Equals, plumbing.ErrObjectNotFound)
+}
+
+func (s *PruneSuite) TestPruneExpire(c *C) {
+	r, err := PlainInit(c.MkDir(), true)
+	c.Assert(err, IsNil)
+
+	h := s.writeBlob(c, r, "foo")
+
+	err = r.Prune(PruneOptions{Expire: time.Hour, Handler: r.DeleteObject})
+	c.Assert(err, IsNil)
+	c.Assert(r.Storer.HasEncodedObject(h), IsNil)
+}
+
+func (s *PruneSuite) TestPruneReflogAndIndex(c *C) {
+	r, err := PlainInit(c.MkDir(), true)
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitChanges("foo\n", &CommitChangesOptions{
+		Branch:  "refs/heads/foo",
+		Author:  defaultSignature(),
+		Changes: []FileChange{{Path: "foo", Content: strings.NewReader("foo")}},
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(r.Storer.RemoveReference("refs/heads/foo"), IsNil)
+	err = appendReflog(r, "refs/heads/foo", &reflogEntry{new: commit}, defaultSignature())
+	c.Assert(err, IsNil)
+
+	blob := s.writeBlob(c, r, "bar")
+	err = r.Storer.SetIndex(&index.Index{
+		Version: 2,
+		Entries: []*index.Entry{{Name: "bar", Hash: blob}},
+	})
+	c.Assert(err, IsNil)
+
+	unreachable := s.writeBlob(c, r, "qux")
+
+	var pruned []plumbing.Hash
+	err = r.Prune(PruneOptions{
+		DryRun: true,
+		Pruned: func(h plumbing.Hash) { pruned = append(pruned, h) },
+	})
+	c.Assert(err, IsNil)
+	c.Assert(pruned, DeepEquals, []plumbing.Hash{unreachable})
+}
@@ -0,0 +1,256 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage"
+
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+var (
+	// ErrOctopusMergeHeadNotSupported is returned recording several commits
+	// at MERGE_HEAD of a repository not stored at a filesystem.
+	ErrOctopusMergeHeadNotSupported = errors.New("several merge heads not supported by the storage")
+)
+
+// FetchHeadEntry is a reference fetched by the last fetch, as recorded at
+// FETCH_HEAD.
+type FetchHeadEntry struct {
+	// Hash is the commit, or the object, the reference pointed to.
+	Hash plumbing.Hash
+	// Name is the name of the reference at the remote.
+	Name plumbing.ReferenceName
+	// URL is the URL of the remote.
+	URL string
+	// NotForMerge is true for the references not to be merged by a pull:
+	// all the fetched references but the branches configured as upstream
+	// of the current branch, or the ones given explicitly to the fetch
+	// without wildcards.
+	NotForMerge bool
+}
+
+// String returns the entry as a line of FETCH_HEAD, as git writes it.
+func (e *FetchHeadEntry) String() string {
+	var status string
+	if e.NotForMerge {
+		status = "not-for-merge"
+	}
+
+	return fmt.Sprintf("%s\t%s\t%s", e.Hash, status, fetchHeadDescription(e.Name, e.URL))
+}
+
+func fetchHeadDescription(name plumbing.ReferenceName, url string) string {
+	switch {
+	case name == plumbing.HEAD:
+		return url
+	case name.IsBranch():
+		return fmt.Sprintf("branch '%s' of %s", name.Short(), url)
+	case name.IsTag():
+		return fmt.Sprintf("tag '%s' of %s", name.Short(), url)
+	default:
+		return fmt.Sprintf("'%s' of %s", name, url)
+	}
+}
+
+// parseFetchHeadEntry parses a line of FETCH_HEAD, returning nil if invalid.
+func parseFetchHeadEntry(line string) *FetchHeadEntry {
+	parts := strings.SplitN(line, "\t", 3)
+	if len(parts) != 3 || len(parts[0]) != 40 {
+		return nil
+	}
+
+	e := &FetchHeadEntry{
+		Hash:        plumbing.NewHash(parts[0]),
+		NotForMerge: parts[1] == "not-for-merge",
+		Name:        plumbing.HEAD,
+		URL:         parts[2],
+	}
+
+	for _, kind := range []struct {
+		prefix string
+		ref    string
+	}{{"branch '", "refs/heads/"}, {"tag '", "refs/tags/"}, {"'", ""}} {
+		if !strings.HasPrefix(parts[2], kind.prefix) {
+			continue
+		}
+
+		desc := parts[2][len(kind.prefix):]
+		i := strings.Index(desc, "' of ")
+		if i == -1 {
+			continue
+		}
+
+		e.Name = plumbing.ReferenceName(kind.ref + desc[:i])
+		e.URL = desc[i+len("' of "):]
+		break
+	}
+
+	return e
+}
+
+// FetchHead returns the references fetched by the last fetch, as recorded at
+// FETCH_HEAD. If the repository isn't stored at a filesystem, only the first
+// one to be merged is recorded, as the FETCH_HEAD reference, without its
+// name nor URL.
+func (r *Repository) FetchHead() ([]*FetchHeadEntry, error) {
+	dot, isFSBased := storerFilesystem(r)
+	if !isFSBased {
+		ref, err := r.Storer.Reference(plumbing.FetchHead)
+		if err == plumbing.ErrReferenceNotFound {
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return []*FetchHeadEntry{{Hash: ref.Hash()}}, nil
+	}
+
+	content, err := readFile(dot, plumbing.FetchHead.String())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*FetchHeadEntry
+	for _, line := range strings.Split(string(content), "\n") {
+		if e := parseFetchHeadEntry(line); e != nil {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, nil
+}
+
+// writeFetchHead records the entries at FETCH_HEAD, leaving it empty if there
+// are no entries, or removing it if the storage isn't a filesystem.
+func writeFetchHead(s storage.Storer, entries []*FetchHeadEntry) error {
+	dot, isFSBased := storageFilesystem(s)
+	if !isFSBased {
+		if len(entries) == 0 {
+			return removePseudoRef(s, plumbing.FetchHead)
+		}
+
+		first := entries[0]
+		for _, e := range entries {
+			if !e.NotForMerge {
+				first = e
+				break
+			}
+		}
+
+		return s.SetReference(plumbing.NewHashReference(plumbing.FetchHead, first.Hash))
+	}
+
+	buf := bytes.NewBuffer(nil)
+	for _, e := range entries {
+		fmt.Fprintln(buf, e.String())
+	}
+
+	return util.WriteFile(dot, plumbing.FetchHead.String(), buf.Bytes(), 0666)
+}
+
+// MergeHead returns the commits being merged into HEAD, as recorded at
+// MERGE_HEAD, or none if there is no merge in progress.
+func (r *Repository) MergeHead() ([]plumbing.Hash, error) {
+	dot, isFSBased := storerFilesystem(r)
+	if !isFSBased {
+		ref, err := r.Storer.Reference(plumbing.MergeHead)
+		if err == plumbing.ErrReferenceNotFound {
+			return nil, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return []plumbing.Hash{ref.Hash()}, nil
+	}
+
+	content, err := readFile(dot, plumbing.MergeHead.String())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []plumbing.Hash
+	for _, line := range strings.Fields(string(content)) {
+		if len(line) == 40 {
+			hashes = append(hashes, plumbing.NewHash(line))
+		}
+	}
+
+	return hashes, nil
+}
+
+// SetMergeHead records the commits being merged into HEAD at MERGE_HEAD, so
+// the next commit of the worktree concludes the merge, having them as
+// parents besides HEAD. No commits removes MERGE_HEAD. Only one commit is
+// supported if the repository isn't stored at a filesystem.
+func (r *Repository) SetMergeHead(commits ...plumbing.Hash) error {
+	dot, isFSBased := storerFilesystem(r)
+	if len(commits) == 0 && isFSBased {
+		err := dot.Remove(plumbing.MergeHead.String())
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	if !isFSBased {
+		if len(commits) == 0 {
+			return removePseudoRef(r.Storer, plumbing.MergeHead)
+		}
+
+		if len(commits) > 1 {
+			return ErrOctopusMergeHeadNotSupported
+		}
+
+		return r.Storer.SetReference(plumbing.NewHashReference(plumbing.MergeHead, commits[0]))
+	}
+
+	buf := bytes.NewBuffer(nil)
+	for _, h := range commits {
+		fmt.Fprintln(buf, h)
+	}
+
+	return util.WriteFile(dot, plumbing.MergeHead.String(), buf.Bytes(), 0666)
+}
+
+// setOrigHead records at ORIG_HEAD the commit of HEAD, if any, before it is
+// changed by a rebase or a merge.
+func (r *Repository) setOrigHead() error {
+	head, err := r.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return r.Storer.SetReference(plumbing.NewHashReference(plumbing.OrigHead, head.Hash()))
+}
+
+func removePseudoRef(s storage.Storer, name plumbing.ReferenceName) error {
+	err := s.RemoveReference(name)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil
+	}
+
+	return err
+}
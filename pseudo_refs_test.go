@@ -0,0 +1,214 @@
+package git
+
+import (
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type PseudoRefsSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&PseudoRefsSuite{})
+
+func (s *PseudoRefsSuite) commit(c *C, w *Worktree, name, content string) plumbing.Hash {
+	c.Assert(util.WriteFile(w.Filesystem, name, []byte(content), 0644), IsNil)
+	_, err := w.Add(name)
+	c.Assert(err, IsNil)
+
+	h, err := w.Commit(name+"\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+	return h
+}
+
+func (s *PseudoRefsSuite) TestFetchHeadEntryString(c *C) {
+	h := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	url := "https://example.com/foo"
+
+	for _, t := range []struct {
+		entry *FetchHeadEntry
+		line  string
+	}{{
+		&FetchHeadEntry{Hash: h, Name: "refs/heads/master", URL: url},
+		h.String() + "\t\tbranch 'master' of " + url,
+	}, {
+		&FetchHeadEntry{Hash: h, Name: "refs/tags/v1.0.0", URL: url, NotForMerge: true},
+		h.String() + "\tnot-for-merge\ttag 'v1.0.0' of " + url,
+	}, {
+		&FetchHeadEntry{Hash: h, Name: "refs/pull/1/head", URL: url},
+		h.String() + "\t\t'refs/pull/1/head' of " + url,
+	}, {
+		&FetchHeadEntry{Hash: h, Name: plumbing.HEAD, URL: url},
+		h.String() + "\t\t" + url,
+	}} {
+		c.Assert(t.entry.String(), Equals, t.line)
+		c.Assert(parseFetchHeadEntry(t.line), DeepEquals, t.entry)
+	}
+
+	c.Assert(parseFetchHeadEntry("foo"), IsNil)
+}
+
+func (s *PseudoRefsSuite) TestFetch(c *C) {
+	server, err := PlainInit(c.MkDir(), false)
+	c.Assert(err, IsNil)
+
+	sw, err := server.Worktree()
+	c.Assert(err, IsNil)
+	s.commit(c, sw, "foo", "foo")
+
+	r, err := PlainClone(c.MkDir(), false, &CloneOptions{URL: sw.Filesystem.Root()})
+	c.Assert(err, IsNil)
+
+	master := s.commit(c, sw, "bar", "bar")
+	c.Assert(sw.Checkout(&CheckoutOptions{Branch: "refs/heads/b", Create: true}), IsNil)
+	b := s.commit(c, sw, "baz", "baz")
+
+	c.Assert(r.Fetch(&FetchOptions{}), IsNil)
+
+	entries, err := r.FetchHead()
+	c.Assert(err, IsNil)
+	c.Assert(entries, DeepEquals, []*FetchHeadEntry{
+		{Hash: master, Name: "refs/heads/master", URL: sw.Filesystem.Root()},
+		{Hash: b, Name: "refs/heads/b", URL: sw.Filesystem.Root(), NotForMerge: true},
+	})
+
+	h, err := r.ResolveRevision(plumbing.Revision(plumbing.FetchHead))
+	c.Assert(err, IsNil)
+	c.Assert(*h, Equals, master)
+
+	err = r.Fetch(&FetchOptions{
+		RefSpecs: []config.RefSpec{"refs/heads/b:refs/remotes/origin/b"},
+	})
+	c.Assert(err, Equals, NoErrAlreadyUpToDate)
+
+	entries, err = r.FetchHead()
+	c.Assert(err, IsNil)
+	c.Assert(entries, DeepEquals, []*FetchHeadEntry{
+		{Hash: b, Name: "refs/heads/b", URL: sw.Filesystem.Root()},
+	})
+}
+
+func (s *PseudoRefsSuite) TestFetchNoWriteFetchHead(c *C) {
+	server, err := PlainInit(c.MkDir(), false)
+	c.Assert(err, IsNil)
+
+	sw, err := server.Worktree()
+	c.Assert(err, IsNil)
+	s.commit(c, sw, "foo", "foo")
+
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{sw.Filesystem.Root()},
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(r.Fetch(&FetchOptions{NoWriteFetchHead: true}), IsNil)
+
+	entries, err := r.FetchHead()
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 0)
+}
+
+func (s *PseudoRefsSuite) TestResetOrigHead(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	first := s.commit(c, w, "foo", "foo")
+	second := s.commit(c, w, "bar", "bar")
+	c.Assert(r.SetMergeHead(first), IsNil)
+
+	c.Assert(w.Reset(&ResetOptions{Mode: HardReset, Commit: first}), IsNil)
+
+	orig, err := r.Reference(plumbing.OrigHead, false)
+	c.Assert(err, IsNil)
+	c.Assert(orig.Hash(), Equals, second)
+
+	merging, err := r.MergeHead()
+	c.Assert(err, IsNil)
+	c.Assert(merging, HasLen, 0)
+}
+
+func (s *PseudoRefsSuite) TestRebaseOrigHead(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	base := s.commit(c, w, "foo", "foo")
+	u := s.commit(c, w, "bar", "bar")
+	c.Assert(w.Reset(&ResetOptions{Mode: HardReset, Commit: base}), IsNil)
+	local := s.commit(c, w, "qux", "qux")
+
+	c.Assert(w.Rebase(&RebaseOptions{Upstream: u}), IsNil)
+
+	orig, err := r.Reference(plumbing.OrigHead, false)
+	c.Assert(err, IsNil)
+	c.Assert(orig.Hash(), Equals, local)
+}
+
+func (s *PseudoRefsSuite) TestCommitMergeHead(c *C) {
+	r, err := PlainInit(c.MkDir(), false)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	base := s.commit(c, w, "foo", "foo")
+	side := s.commit(c, w, "bar", "bar")
+	c.Assert(w.Reset(&ResetOptions{Mode: SoftReset, Commit: base}), IsNil)
+
+	c.Assert(r.SetMergeHead(side), IsNil)
+	merging, err := r.MergeHead()
+	c.Assert(err, IsNil)
+	c.Assert(merging, DeepEquals, []plumbing.Hash{side})
+
+	h, err := w.Commit("merge\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(h)
+	c.Assert(err, IsNil)
+	c.Assert(commit.ParentHashes, DeepEquals, []plumbing.Hash{base, side})
+
+	merging, err = r.MergeHead()
+	c.Assert(err, IsNil)
+	c.Assert(merging, HasLen, 0)
+}
+
+func (s *PseudoRefsSuite) TestSetMergeHeadOctopus(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	err = r.SetMergeHead(plumbing.ZeroHash, plumbing.ZeroHash)
+	c.Assert(err, Equals, ErrOctopusMergeHeadNotSupported)
+}
+
+func (s *PseudoRefsSuite) TestCheckoutDetach(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	h := s.commit(c, w, "foo", "foo")
+
+	c.Assert(w.Checkout(&CheckoutOptions{Branch: plumbing.Master, Detach: true}), IsNil)
+
+	head, err := r.Storer.Reference(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(head.Type(), Equals, plumbing.HashReference)
+	c.Assert(head.Hash(), Equals, h)
+
+	err = w.Checkout(&CheckoutOptions{Branch: "refs/heads/b", Create: true, Detach: true})
+	c.Assert(err, Equals, ErrDetachCreateExclusive)
+}
@@ -0,0 +1,410 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
+)
+
+var (
+	// ErrRebaseConflict is returned rebasing a commit changing the same
+	// files changed by the upstream commits. Nothing is changed at the
+	// repository or the worktree.
+	ErrRebaseConflict = errors.New("rebase conflict")
+)
+
+// Rebase replays the commits of the current branch not reachable from the
+// upstream commit on top of it, as git rebase does, and checks out the
+// result. The commits are replayed file by file, any commit changing a file
+// also changed by the upstream commits returning ErrRebaseConflict, and the
+// commits whose changes are already at the upstream are dropped. The
+// worktree must be clean, ErrWorktreeNotClean is returned otherwise.
+//
+// If the branch is already based on the upstream, NoErrAlreadyUpToDate is
+// returned. With RebaseOptions.Autostash or rebase.autoStash, the changes of
+// the tracked files are stashed before the rebase and reapplied afterwards,
+// returning an *AutostashConflictError if they can't be reapplied.
+func (w *Worktree) Rebase(o *RebaseOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	head, err := w.r.Head()
+	if err != nil {
+		return err
+	}
+
+	return w.rebase(head.Hash(), o)
+}
+
+func (w *Worktree) rebase(head plumbing.Hash, o *RebaseOptions) error {
+	based, err := isFastForward(w.r.Storer, o.Upstream, head)
+	if err != nil {
+		return err
+	}
+
+	if based {
+		return NoErrAlreadyUpToDate
+	}
+
+	autostash := o.Autostash
+	if !autostash {
+		cfg, err := w.r.Config()
+		if err != nil {
+			return err
+		}
+
+		autostash = cfg.Rebase.AutoStash
+	}
+
+	if autostash {
+		return w.withAutostash(o.Committer, func() error {
+			return w.rebaseOnto(head, o)
+		})
+	}
+
+	return w.rebaseOnto(head, o)
+}
+
+func (w *Worktree) rebaseOnto(head plumbing.Hash, o *RebaseOptions) error {
+	status, err := w.Status()
+	if err != nil {
+		return err
+	}
+
+	if !status.IsClean() {
+		return ErrWorktreeNotClean
+	}
+
+	tip, err := w.r.rebaseCommits(head, o)
+	if err != nil {
+		return err
+	}
+
+	if err := w.r.setOrigHead(); err != nil {
+		return err
+	}
+
+	if err := w.updateHEAD(tip); err != nil {
+		return err
+	}
+
+	return w.resetContext(context.Background(), &ResetOptions{Mode: MergeReset, Commit: tip})
+}
+
+// rebaseCommits replays the commits reachable from head and not from the
+// upstream on top of it, returning the new tip.
+func (r *Repository) rebaseCommits(head plumbing.Hash, o *RebaseOptions) (plumbing.Hash, error) {
+	headCommit, err := r.CommitObject(head)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	upstream, err := r.CommitObject(o.Upstream)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	commits, err := r.rebasedCommits(headCommit, upstream)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	rb := &rebaser{
+		r:         r,
+		o:         o,
+		onto:      upstream,
+		rewritten: make(map[plumbing.Hash]plumbing.Hash),
+	}
+
+	tip := upstream.Hash
+	for _, c := range commits {
+		if !o.Merges && c.NumParents() > 1 {
+			continue
+		}
+
+		if tip, err = rb.pick(c, tip); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	if o.Merges {
+		if h, ok := rb.rewritten[head]; ok {
+			return h, nil
+		}
+	}
+
+	return tip, nil
+}
+
+// rebasedCommits returns the commits reachable from head and not from the
+// upstream, parents first.
+func (r *Repository) rebasedCommits(head, upstream *object.Commit) ([]*object.Commit, error) {
+	bases, err := head.MergeBase(upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	// the commits reachable from both are the ancestors of the merge bases
+	excluded := make(map[plumbing.Hash]bool)
+	for _, b := range bases {
+		if excluded[b.Hash] {
+			continue
+		}
+
+		iter := object.NewCommitPreorderIter(b, excluded, nil)
+		err := iter.ForEach(func(c *object.Commit) error {
+			excluded[c.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var commits []*object.Commit
+	visited := make(map[plumbing.Hash]bool)
+	type frame struct {
+		c    *object.Commit
+		next int
+	}
+
+	stack := []*frame{{c: head}}
+	visited[head.Hash] = true
+	for len(stack) > 0 {
+		f := stack[len(stack)-1]
+		if f.next == f.c.NumParents() {
+			commits = append(commits, f.c)
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		p := f.c.ParentHashes[f.next]
+		f.next++
+		if visited[p] || excluded[p] {
+			continue
+		}
+
+		visited[p] = true
+		pc, err := r.CommitObject(p)
+		if err != nil {
+			return nil, err
+		}
+
+		stack = append(stack, &frame{c: pc})
+	}
+
+	return commits, nil
+}
+
+type rebaser struct {
+	r    *Repository
+	o    *RebaseOptions
+	onto *object.Commit
+	// rewritten are the commits replayed, by their original hash.
+	rewritten map[plumbing.Hash]plumbing.Hash
+}
+
+// pick replays the changes of c since its first parent on top of tip, or,
+// recreating the merges, on top of its rewritten first parent, returning
+// the new commit, or the commit it is based on if the changes are already
+// there.
+func (rb *rebaser) pick(c *object.Commit, tip plumbing.Hash) (plumbing.Hash, error) {
+	parents := []plumbing.Hash{tip}
+	if rb.o.Merges {
+		parents = rb.parents(c)
+	}
+
+	if equalHashes(parents, c.ParentHashes) {
+		rb.rewritten[c.Hash] = c.Hash
+		return c.Hash, nil
+	}
+
+	base := &object.Tree{}
+	if c.NumParents() != 0 {
+		p, err := c.Parent(0)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		if base, err = p.Tree(); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	newParent, err := rb.r.CommitObject(parents[0])
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	ours, err := newParent.Tree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	theirs, err := c.Tree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	tree, err := mergeTrees(rb.r.Storer, base, ours, theirs)
+	if _, ok := err.(*treeMergeConflictError); ok {
+		return plumbing.ZeroHash, ErrRebaseConflict
+	}
+
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if len(parents) == 1 && tree == newParent.TreeHash {
+		rb.rewritten[c.Hash] = newParent.Hash
+		return newParent.Hash, nil
+	}
+
+	committer := c.Committer
+	committer.When = time.Now()
+	if rb.o.Committer != nil {
+		committer = *rb.o.Committer
+	}
+
+	h, err := storeCommit(rb.r.Storer, &object.Commit{
+		Author:       c.Author,
+		Committer:    committer,
+		Message:      c.Message,
+		TreeHash:     tree,
+		ParentHashes: parents,
+	})
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	rb.rewritten[c.Hash] = h
+	return h, nil
+}
+
+// parents returns the rewritten parents of c, the first parent being the
+// upstream if it isn't rebased. The rest of parents not rebased are kept.
+func (rb *rebaser) parents(c *object.Commit) []plumbing.Hash {
+	var parents []plumbing.Hash
+	seen := make(map[plumbing.Hash]bool)
+	for i, p := range c.ParentHashes {
+		if h, ok := rb.rewritten[p]; ok {
+			p = h
+		} else if i == 0 {
+			p = rb.onto.Hash
+		}
+
+		if !seen[p] {
+			seen[p] = true
+			parents = append(parents, p)
+		}
+	}
+
+	if len(parents) == 0 {
+		parents = append(parents, rb.onto.Hash)
+	}
+
+	return parents
+}
+
+func equalHashes(a, b []plumbing.Hash) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// treeMergeConflictError is returned by mergeTrees with the files changed in
+// a different way by both trees.
+type treeMergeConflictError struct {
+	paths []string
+}
+
+func (e *treeMergeConflictError) Error() string {
+	return fmt.Sprintf("tree merge conflict: %s", strings.Join(e.paths, ", "))
+}
+
+// mergeTrees applies the changes of theirs since base to ours, file by file,
+// returning a *treeMergeConflictError if any file changed was also changed by
+// ours in a different way.
+func mergeTrees(s storer.EncodedObjectStorer, base, ours, theirs *object.Tree) (plumbing.Hash, error) {
+	changes, err := object.DiffTree(base, theirs)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	var conflicts []string
+	b := object.NewTreeBuilder(s, ours)
+	for _, ch := range changes {
+		action, err := ch.Action()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		name := ch.To.Name
+		if action == merkletrie.Delete {
+			name = ch.From.Name
+		}
+
+		current, err := ours.FindEntry(name)
+		if err == object.ErrEntryNotFound || err == object.ErrDirectoryNotFound {
+			current, err = nil, nil
+		}
+
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		var from, to *object.TreeEntry
+		if action != merkletrie.Insert {
+			from = &ch.From.TreeEntry
+		}
+
+		if action != merkletrie.Delete {
+			to = &ch.To.TreeEntry
+		}
+
+		switch {
+		case sameTreeEntry(current, to):
+			continue
+		case !sameTreeEntry(current, from):
+			conflicts = append(conflicts, name)
+		case to == nil:
+			err = b.Remove(name)
+		default:
+			err = b.Add(name, to.Mode, to.Hash)
+		}
+
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	if len(conflicts) != 0 {
+		return plumbing.ZeroHash, &treeMergeConflictError{paths: conflicts}
+	}
+
+	return b.Build()
+}
+
+func sameTreeEntry(a, b *object.TreeEntry) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Hash == b.Hash && a.Mode == b.Mode
+}
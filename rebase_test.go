@@ -0,0 +1,238 @@
+package git
+
+import (
+	"io/ioutil"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type RebaseSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&RebaseSuite{})
+
+func (s *RebaseSuite) commit(c *C, w *Worktree, name, content string) plumbing.Hash {
+	return s.commitMessage(c, w, name, content, name+"\n")
+}
+
+func (s *RebaseSuite) commitMessage(c *C, w *Worktree, name, content, msg string) plumbing.Hash {
+	c.Assert(util.WriteFile(w.Filesystem, name, []byte(content), 0644), IsNil)
+	_, err := w.Add(name)
+	c.Assert(err, IsNil)
+
+	h, err := w.Commit(msg, &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+	return h
+}
+
+// diverge creates a repository with a base commit, an upstream commit
+// writing the upstream file, and a local commit at master writing the local
+// one.
+func (s *RebaseSuite) diverge(c *C, upstream, local [2]string) (*Repository, *Worktree, plumbing.Hash) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	base := s.commit(c, w, "foo", "foo")
+	u := s.commit(c, w, upstream[0], upstream[1])
+	c.Assert(w.Reset(&ResetOptions{Mode: HardReset, Commit: base}), IsNil)
+	s.commitMessage(c, w, local[0], local[1], "local\n")
+	return r, w, u
+}
+
+func (s *RebaseSuite) TestRebase(c *C) {
+	r, w, u := s.diverge(c, [2]string{"bar", "bar"}, [2]string{"qux", "qux"})
+
+	c.Assert(w.Rebase(&RebaseOptions{Upstream: u}), IsNil)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, plumbing.Master)
+
+	commit, err := r.CommitObject(head.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "local\n")
+	c.Assert(commit.ParentHashes, DeepEquals, []plumbing.Hash{u})
+
+	for _, name := range []string{"foo", "bar", "qux"} {
+		_, err := commit.File(name)
+		c.Assert(err, IsNil)
+
+		f, err := w.Filesystem.Open(name)
+		c.Assert(err, IsNil)
+		content, err := ioutil.ReadAll(f)
+		c.Assert(err, IsNil)
+		c.Assert(f.Close(), IsNil)
+		c.Assert(string(content), Equals, name)
+	}
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+}
+
+func (s *RebaseSuite) TestRebaseAlreadyApplied(c *C) {
+	r, w, u := s.diverge(c, [2]string{"bar", "bar"}, [2]string{"bar", "bar"})
+
+	c.Assert(w.Rebase(&RebaseOptions{Upstream: u}), IsNil)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, u)
+}
+
+func (s *RebaseSuite) TestRebaseConflict(c *C) {
+	r, w, u := s.diverge(c, [2]string{"foo", "bar"}, [2]string{"foo", "qux"})
+
+	before, err := r.Head()
+	c.Assert(err, IsNil)
+
+	err = w.Rebase(&RebaseOptions{Upstream: u})
+	c.Assert(err, Equals, ErrRebaseConflict)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, before.Hash())
+}
+
+func (s *RebaseSuite) TestRebaseAlreadyUpToDate(c *C) {
+	r, w, _ := s.diverge(c, [2]string{"bar", "bar"}, [2]string{"qux", "qux"})
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	commit, err := r.CommitObject(head.Hash())
+	c.Assert(err, IsNil)
+
+	err = w.Rebase(&RebaseOptions{Upstream: commit.ParentHashes[0]})
+	c.Assert(err, Equals, NoErrAlreadyUpToDate)
+}
+
+func (s *RebaseSuite) TestRebaseNotClean(c *C) {
+	_, w, u := s.diverge(c, [2]string{"bar", "bar"}, [2]string{"qux", "qux"})
+
+	c.Assert(util.WriteFile(w.Filesystem, "foo", []byte("baz"), 0644), IsNil)
+
+	err := w.Rebase(&RebaseOptions{Upstream: u})
+	c.Assert(err, Equals, ErrWorktreeNotClean)
+}
+
+func (s *RebaseSuite) TestRebaseMerges(c *C) {
+	r, w, u := s.diverge(c, [2]string{"bar", "bar"}, [2]string{"qux", "qux"})
+
+	local, err := r.Head()
+	c.Assert(err, IsNil)
+
+	// a side commit, merged into master
+	side := s.commit(c, w, "baz", "baz")
+	c.Assert(w.Reset(&ResetOptions{Mode: HardReset, Commit: local.Hash()}), IsNil)
+
+	c.Assert(util.WriteFile(w.Filesystem, "baz", []byte("baz"), 0644), IsNil)
+	_, err = w.Add("baz")
+	c.Assert(err, IsNil)
+	_, err = w.Commit("merge\n", &CommitOptions{
+		Author:  defaultSignature(),
+		Parents: []plumbing.Hash{local.Hash(), side},
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(w.Rebase(&RebaseOptions{Upstream: u, Merges: true}), IsNil)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	rebased, err := r.CommitObject(head.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(rebased.Message, Equals, "merge\n")
+	c.Assert(rebased.NumParents(), Equals, 2)
+
+	first, err := rebased.Parent(0)
+	c.Assert(err, IsNil)
+	c.Assert(first.ParentHashes, DeepEquals, []plumbing.Hash{u})
+
+	second, err := rebased.Parent(1)
+	c.Assert(err, IsNil)
+	c.Assert(second.ParentHashes, DeepEquals, []plumbing.Hash{first.Hash})
+
+	for _, name := range []string{"foo", "bar", "qux", "baz"} {
+		_, err := rebased.File(name)
+		c.Assert(err, IsNil)
+	}
+}
+
+func (s *RebaseSuite) TestPullRebase(c *C) {
+	server, err := PlainInit(c.MkDir(), false)
+	c.Assert(err, IsNil)
+
+	sw, err := server.Worktree()
+	c.Assert(err, IsNil)
+	s.commit(c, sw, "foo", "foo")
+
+	r, err := Clone(memory.NewStorage(), memfs.New(), &CloneOptions{
+		URL: sw.Filesystem.Root(),
+	})
+	c.Assert(err, IsNil)
+
+	u := s.commit(c, sw, "bar", "bar")
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	s.commit(c, w, "qux", "qux")
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Pull.Rebase = "true"
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	err = w.Pull(&PullOptions{Rebase: PullRebaseFalse})
+	c.Assert(err, Equals, ErrNonFastForwardUpdate)
+
+	c.Assert(w.Pull(&PullOptions{}), IsNil)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	commit, err := r.CommitObject(head.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "qux\n")
+	c.Assert(commit.ParentHashes, DeepEquals, []plumbing.Hash{u})
+}
+
+func (s *RebaseSuite) TestPullRebaseBranchConfig(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Pull.Rebase = "true"
+	cfg.Branches["master"] = &config.Branch{Name: "master", Rebase: "merges"}
+	cfg.Branches["foo"] = &config.Branch{Name: "foo", Rebase: "bar"}
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	head := plumbing.NewHashReference(plumbing.Master, plumbing.ZeroHash)
+	mode, err := w.pullRebaseMode(&PullOptions{}, head)
+	c.Assert(err, IsNil)
+	c.Assert(mode, Equals, PullRebaseMerges)
+
+	mode, err = w.pullRebaseMode(&PullOptions{Rebase: PullRebaseFalse}, head)
+	c.Assert(err, IsNil)
+	c.Assert(mode, Equals, PullRebaseFalse)
+
+	head = plumbing.NewHashReference("refs/heads/qux", plumbing.ZeroHash)
+	mode, err = w.pullRebaseMode(&PullOptions{}, head)
+	c.Assert(err, IsNil)
+	c.Assert(mode, Equals, PullRebaseTrue)
+
+	head = plumbing.NewHashReference("refs/heads/foo", plumbing.ZeroHash)
+	_, err = w.pullRebaseMode(&PullOptions{}, head)
+	c.Assert(err, Equals, ErrInvalidPullRebaseMode)
+}
@@ -1,6 +1,7 @@
 package git
 
 import (
+	"context"
 	"io"
 	"sort"
 
@@ -24,10 +25,10 @@ import (
 // - Cherry-picks are not detected unless there are no commits between them and
 // therefore can appear repeated in the list. (see git path-id for hints on how
 // to fix this).
-func references(c *object.Commit, path string) ([]*object.Commit, error) {
+func references(ctx context.Context, c *object.Commit, path string) ([]*object.Commit, error) {
 	var result []*object.Commit
 	seen := make(map[plumbing.Hash]struct{})
-	if err := walkGraph(&result, &seen, c, path); err != nil {
+	if err := walkGraph(ctx, &result, &seen, c, path); err != nil {
 		return nil, err
 	}
 
@@ -62,7 +63,11 @@ func sortCommits(l []*object.Commit) {
 
 // Recursive traversal of the commit graph, generating a linear history of the
 // path.
-func walkGraph(result *[]*object.Commit, seen *map[plumbing.Hash]struct{}, current *object.Commit, path string) error {
+func walkGraph(ctx context.Context, result *[]*object.Commit, seen *map[plumbing.Hash]struct{}, current *object.Commit, path string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// check and update seen
 	if _, ok := (*seen)[current.Hash]; ok {
 		return nil
@@ -98,12 +103,12 @@ func walkGraph(result *[]*object.Commit, seen *map[plumbing.Hash]struct{}, curre
 			*result = append(*result, current)
 		}
 		// in any case, walk the parent
-		return walkGraph(result, seen, parents[0], path)
+		return walkGraph(ctx, result, seen, parents[0], path)
 	default: // more than one parent contains the path
 		// TODO: detect merges that had a conflict, because they must be
 		// included in the result here.
 		for _, p := range parents {
-			err := walkGraph(result, seen, p, path)
+			err := walkGraph(ctx, result, seen, p, path)
 			if err != nil {
 				return err
 			}
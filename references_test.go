@@ -2,6 +2,7 @@ package git
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
@@ -304,7 +305,7 @@ func (s *ReferencesSuite) TestObjectNotFoundError(c *C) {
 	commit, err := r.CommitObject(h1)
 	c.Assert(err, IsNil)
 
-	_, err = references(commit, "LICENSE")
+	_, err = references(context.Background(), commit, "LICENSE")
 	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
 }
 
@@ -315,7 +316,7 @@ func (s *ReferencesSuite) TestRevList(c *C) {
 		commit, err := r.CommitObject(plumbing.NewHash(t.commit))
 		c.Assert(err, IsNil)
 
-		revs, err := references(commit, t.path)
+		revs, err := references(context.Background(), commit, t.path)
 		c.Assert(err, IsNil)
 		c.Assert(len(revs), Equals, len(t.revs))
 
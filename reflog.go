@@ -0,0 +1,136 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+
+	"gopkg.in/src-d/go-billy.v4"
+)
+
+// logsDir is the directory, at the git directory, of the reflogs.
+const logsDir = "logs"
+
+// reflogEntry is an update of a reference recorded at its reflog.
+type reflogEntry struct {
+	old plumbing.Hash
+	new plumbing.Hash
+	// message is the reason of the update
+	message string
+}
+
+// readReflog returns the entries of the reflog of the reference, from the
+// oldest to the newest. It returns no entries if the reference has no reflog
+// or if the repository is not stored at a filesystem.
+func readReflog(r *Repository, name plumbing.ReferenceName) ([]*reflogEntry, error) {
+	dot, isFSBased := storerFilesystem(r)
+	if !isFSBased {
+		return nil, nil
+	}
+
+	content, err := readFile(dot, path.Join(logsDir, name.String()))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*reflogEntry
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		// <old> SP <new> SP <committer> TAB <message>
+		if len(line) < 2*40+2 || line[40] != ' ' || line[81] != ' ' {
+			continue
+		}
+
+		e := &reflogEntry{
+			old: plumbing.NewHash(string(line[:40])),
+			new: plumbing.NewHash(string(line[41:81])),
+		}
+
+		if i := bytes.IndexByte(line, '\t'); i != -1 {
+			e.message = string(line[i+1:])
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// reflogNames returns the names of the references with a reflog, HEAD
+// included. It returns no names if the repository is not stored at a
+// filesystem.
+func reflogNames(r *Repository) ([]plumbing.ReferenceName, error) {
+	dot, isFSBased := storerFilesystem(r)
+	if !isFSBased {
+		return nil, nil
+	}
+
+	var names []plumbing.ReferenceName
+	if err := addReflogNames(dot, logsDir, &names); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+func addReflogNames(fs billy.Filesystem, dir string, names *[]plumbing.ReferenceName) error {
+	files, err := fs.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, fi := range files {
+		name := path.Join(dir, fi.Name())
+		if fi.IsDir() {
+			if err := addReflogNames(fs, name, names); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		*names = append(*names, plumbing.ReferenceName(strings.TrimPrefix(name, logsDir+"/")))
+	}
+
+	return nil
+}
+
+// appendReflog appends the entry to the reflog of the reference, made by the
+// given committer. It does nothing if the repository is not stored at a
+// filesystem.
+func appendReflog(r *Repository, name plumbing.ReferenceName, e *reflogEntry, committer *object.Signature) (err error) {
+	dot, isFSBased := storerFilesystem(r)
+	if !isFSBased {
+		return nil
+	}
+
+	f, err := dot.OpenFile(path.Join(logsDir, name.String()), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	buf := bytes.NewBuffer(nil)
+	fmt.Fprintf(buf, "%s %s ", e.old, e.new)
+	if err := committer.Encode(buf); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(buf, "\t%s\n", e.message)
+	_, err = f.Write(buf.Bytes())
+	return err
+}
@@ -2,13 +2,23 @@ package git
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	stdioutil "io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/fsck"
 	"gopkg.in/src-d/go-git.v4/plumbing/object"
 	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
 	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/capability"
@@ -26,8 +36,33 @@ var (
 	NoErrAlreadyUpToDate     = errors.New("already up-to-date")
 	ErrDeleteRefNotSupported = errors.New("server does not support delete-refs")
 	ErrForceNeeded           = errors.New("some refs were not updated")
+	// ErrNonFastForwardUpdate is returned when a reference would be updated
+	// to a commit not descending from its current one without forcing it.
+	ErrNonFastForwardUpdate = errors.New("non-fast-forward update")
+	// ErrUnadvertisedObject is returned when fetching a hash not advertised
+	// by the remote, as the tip of a reference hidden by its hideRefs, and
+	// the remote doesn't allow it.
+	ErrUnadvertisedObject = errors.New("server does not allow request for unadvertised object")
+	// ErrUploadArchiveNotSupported is returned by Archive when the transport
+	// of the remote, e.g. HTTP, doesn't support the git-upload-archive
+	// service.
+	ErrUploadArchiveNotSupported = errors.New("transport does not support upload-archive")
+	// ErrPushOptionsNotSupported is returned when pushing with push options
+	// to a server not advertising the push-options capability.
+	ErrPushOptionsNotSupported = errors.New("server does not support push options")
 )
 
+// nonFastForwardError is the ErrNonFastForwardUpdate of the command of a
+// push, reported to PushOptions.Updated before ErrNonFastForwardUpdate is
+// returned.
+type nonFastForwardError struct {
+	cmd *packp.Command
+}
+
+func (e *nonFastForwardError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrNonFastForwardUpdate, e.cmd.Name)
+}
+
 const (
 	// This describes the maximum number of commits to walk when
 	// computing the haves to send to a server, for each ref in the
@@ -38,8 +73,14 @@ const (
 
 // Remote represents a connection to a remote repository.
 type Remote struct {
-	c *config.RemoteConfig
-	s storage.Storer
+	c         *config.RemoteConfig
+	s         storage.Storer
+	hooks     HookRunner
+	callbacks *Hooks
+	tracer    Tracer
+	// storageLock, if not nil, is held by the fetches while they access the
+	// storage, so the fetches of several remotes can be run concurrently.
+	storageLock sync.Locker
 }
 
 func newRemote(s storage.Storer, c *config.RemoteConfig) *Remote {
@@ -55,25 +96,94 @@ func (r *Remote) String() string {
 	var fetch, push string
 	if len(r.c.URLs) > 0 {
 		fetch = r.c.URLs[0]
-		push = r.c.URLs[0]
+	}
+
+	if len(r.c.PushURLs) > 0 {
+		push = r.c.PushURLs[0]
+	} else {
+		push = fetch
 	}
 
 	return fmt.Sprintf("%s\t%s (fetch)\n%[1]s\t%[3]s (push)", r.c.Name, fetch, push)
 }
 
+// openUploadPackSession opens an upload-pack session to the first URL of the
+// remote, rewritten by the url.<base>.insteadOf rules of the repository
+// config, with the given HTTP options over the http.<url>.* config.
+func (r *Remote) openUploadPackSession(
+	auth transport.AuthMethod, o *transport.HTTPOptions,
+) (transport.UploadPackSession, error) {
+	c, ep, err := r.fetchClient(o)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.NewUploadPackSession(ep, auth)
+}
+
+// fetchClient returns the client and the endpoint of the first URL of the
+// remote, as openUploadPackSession opens its session.
+func (r *Remote) fetchClient(o *transport.HTTPOptions) (transport.Transport, *transport.Endpoint, error) {
+	cfg, err := r.s.Config()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	url := cfg.RewriteURL(r.c.URLs[0])
+	h, err := r.httpOptions(cfg, url, o)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return newClient(url, h)
+}
+
+// pushURLs returns the URLs the pushes are sent to, the push URLs if any or
+// the URLs otherwise. The push URLs are rewritten by the url.<base>.insteadOf
+// rules and the URLs by the url.<base>.pushInsteadOf ones as well, as git
+// does.
+func (r *Remote) pushURLs() ([]string, error) {
+	cfg, err := r.s.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	if len(r.c.PushURLs) > 0 {
+		for _, url := range r.c.PushURLs {
+			urls = append(urls, cfg.RewriteURL(url))
+		}
+
+		return urls, nil
+	}
+
+	for _, url := range r.c.URLs {
+		urls = append(urls, cfg.RewritePushURL(url))
+	}
+
+	return urls, nil
+}
+
 // Push performs a push to the remote. Returns NoErrAlreadyUpToDate if the
 // remote was already up-to-date.
 func (r *Remote) Push(o *PushOptions) error {
 	return r.PushContext(context.Background(), o)
 }
 
-// PushContext performs a push to the remote. Returns NoErrAlreadyUpToDate if
-// the remote was already up-to-date.
+// PushContext performs a push to the remote, to each of its push URLs, or
+// URLs if none, in order. Returns NoErrAlreadyUpToDate if the remote was
+// already up-to-date at all of them.
 //
 // The provided Context must be non-nil. If the context expires before the
 // operation is complete, an error is returned. The context only affects to the
 // transport operations.
-func (r *Remote) PushContext(ctx context.Context, o *PushOptions) (err error) {
+func (r *Remote) PushContext(ctx context.Context, o *PushOptions) error {
+	if r.c.Mirror && len(o.RefSpecs) == 0 {
+		mirror := *o
+		mirror.Mirror = true
+		o = &mirror
+	}
+
 	if err := o.Validate(); err != nil {
 		return err
 	}
@@ -82,14 +192,55 @@ func (r *Remote) PushContext(ctx context.Context, o *PushOptions) (err error) {
 		return fmt.Errorf("remote names don't match: %s != %s", o.RemoteName, r.c.Name)
 	}
 
-	s, err := newSendPackSession(r.c.URLs[0], o.Auth)
+	urls, err := r.pushURLs()
+	if err != nil {
+		return err
+	}
+
+	upToDate := true
+	for _, url := range urls {
+		err := r.push(ctx, url, o)
+		if err == NoErrAlreadyUpToDate {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		upToDate = false
+	}
+
+	if upToDate {
+		return NoErrAlreadyUpToDate
+	}
+
+	return nil
+}
+
+func (r *Remote) push(ctx context.Context, url string, o *PushOptions) (err error) {
+	ctx, span := startSpan(ctx, r.tracer, TracePush)
+	span.SetAttribute(TraceURL, url)
+	defer func() { span.End(err) }()
+
+	cfg, err := r.s.Config()
+	if err != nil {
+		return err
+	}
+
+	h, err := r.httpOptions(cfg, url, o.HTTPOptions)
+	if err != nil {
+		return err
+	}
+
+	s, err := newSendPackSession(url, o.Auth, h)
 	if err != nil {
 		return err
 	}
 
 	defer ioutil.CheckClose(s, &err)
 
-	ar, err := s.AdvertisedReferences()
+	ar, err := r.advertisedReferences(ctx, s)
 	if err != nil {
 		return err
 	}
@@ -126,10 +277,37 @@ func (r *Remote) PushContext(ctx context.Context, o *PushOptions) (err error) {
 		return err
 	}
 
+	if o.Updated != nil {
+		reportUpToDatePushes(o, localRefs, remoteRefs)
+	}
+
+	if len(req.Commands) != 0 && o.PrePush != nil {
+		if err := runPushPrePush(o, localRefs, req); err != nil {
+			return err
+		}
+
+		// The updates returned by the callback may differ from the ones
+		// requested by the refspecs.
+		allDelete = true
+		for _, cmd := range req.Commands {
+			if cmd.Action() != packp.Delete {
+				allDelete = false
+			} else if !ar.Capabilities.Supports(capability.DeleteRefs) {
+				return ErrDeleteRefNotSupported
+			}
+		}
+	}
+
 	if len(req.Commands) == 0 {
 		return NoErrAlreadyUpToDate
 	}
 
+	if !o.DryRun {
+		if err := r.runPrePushHook(url, o, localRefs, req); err != nil {
+			return err
+		}
+	}
+
 	objects := objectsToPush(req.Commands)
 
 	haves, err := referencesToHashes(remoteRefs)
@@ -155,11 +333,16 @@ func (r *Remote) PushContext(ctx context.Context, o *PushOptions) (err error) {
 		}
 	}
 
-	rs, err := pushHashes(ctx, s, r.s, req, hashesToPush)
+	if o.DryRun {
+		return r.reportDryRunPush(o, req, hashesToPush)
+	}
+
+	rs, err := r.pushHashes(ctx, s, req, hashesToPush)
 	if err != nil {
 		return err
 	}
 
+	r.reportPushUpdates(o, req, rs)
 	if err = rs.Error(); err != nil {
 		return err
 	}
@@ -167,30 +350,336 @@ func (r *Remote) PushContext(ctx context.Context, o *PushOptions) (err error) {
 	return r.updateRemoteReferenceStorage(req, rs)
 }
 
+// reportDryRunPush reports to the PushOptions callbacks the updates requested
+// by req and the size of the objects that would be sent.
+func (r *Remote) reportDryRunPush(
+	o *PushOptions,
+	req *packp.ReferenceUpdateRequest,
+	hashes []plumbing.Hash,
+) error {
+	if o.PackSize != nil {
+		var size int64
+		for _, h := range hashes {
+			obj, err := r.s.EncodedObject(plumbing.AnyObject, h)
+			if err != nil {
+				return err
+			}
+
+			size += obj.Size()
+		}
+
+		o.PackSize(len(hashes), size)
+	}
+
+	r.reportPushUpdates(o, req, nil)
+	return nil
+}
+
+// reportPushUpdates reports to PushOptions.Updated the outcome of the
+// commands of req, given the status reported by the remote, if any.
+func (r *Remote) reportPushUpdates(
+	o *PushOptions,
+	req *packp.ReferenceUpdateRequest,
+	rs *packp.ReportStatus,
+) {
+	if o.Updated == nil {
+		return
+	}
+
+	rejected := make(map[plumbing.ReferenceName]string)
+	if rs != nil {
+		for _, cs := range rs.CommandStatuses {
+			if cs.Error() != nil {
+				rejected[cs.ReferenceName] = cs.Status
+			}
+		}
+	}
+
+	for _, cmd := range req.Commands {
+		u := &RefResult{Name: cmd.Name, Old: cmd.Old, New: cmd.New}
+		if reason, ok := rejected[cmd.Name]; ok {
+			u.Status, u.Reason = RefRejected, reason
+		} else {
+			switch cmd.Action() {
+			case packp.Create:
+				u.Status = RefCreated
+			case packp.Delete:
+				u.Status = RefDeleted
+			default:
+				// the old commit may be missing locally, as it happens on
+				// most forced updates
+				if ff, err := isFastForward(r.s, cmd.Old, cmd.New); err == nil && ff {
+					u.Status = RefFastForward
+				} else {
+					u.Status = RefForced
+				}
+			}
+		}
+
+		o.Updated(u)
+	}
+}
+
+// reportUpToDatePushes reports to PushOptions.Updated the references of the
+// remote matched by the RefSpecs that already point to the local ones.
+func reportUpToDatePushes(
+	o *PushOptions,
+	localRefs []*plumbing.Reference,
+	remoteRefs storer.ReferenceStorer,
+) {
+	seen := make(map[plumbing.ReferenceName]bool)
+	for _, rs := range o.RefSpecs {
+		if rs.IsDelete() || rs.IsNegative() {
+			continue
+		}
+
+		for _, ref := range localRefs {
+			if ref.Type() != plumbing.HashReference || !rs.Match(ref.Name()) ||
+				config.ExcludedByAny(o.RefSpecs, ref.Name()) {
+				continue
+			}
+
+			name := rs.Dst(ref.Name())
+			remoteRef, err := remoteRefs.Reference(name)
+			if err != nil || seen[name] || remoteRef.Hash() != ref.Hash() {
+				continue
+			}
+
+			seen[name] = true
+			o.Updated(&RefResult{
+				Name:   name,
+				Old:    ref.Hash(),
+				New:    ref.Hash(),
+				Status: RefUpToDate,
+			})
+		}
+	}
+}
+
 func (r *Remote) newReferenceUpdateRequest(
 	o *PushOptions,
 	localRefs []*plumbing.Reference,
 	remoteRefs storer.ReferenceStorer,
 	ar *packp.AdvRefs,
 ) (*packp.ReferenceUpdateRequest, error) {
-	req := packp.NewReferenceUpdateRequestFromCapabilities(ar.Capabilities)
+	caps, err := r.requestableCapabilities(ar.Capabilities, o.DisabledCapabilities, true)
+	if err != nil {
+		return nil, err
+	}
+
+	req := packp.NewReferenceUpdateRequestFromCapabilities(caps)
+	if err := r.setAgent(req.Capabilities); err != nil {
+		return nil, err
+	}
+
+	if caps.Supports(capability.OFSDelta) {
+		req.Capabilities.Set(capability.OFSDelta)
+	}
+
+	if len(o.Options) != 0 {
+		if !caps.Supports(capability.PushOptions) {
+			return nil, ErrPushOptionsNotSupported
+		}
+
+		req.Capabilities.Set(capability.PushOptions)
+		req.Options = o.Options
+	}
 
 	if o.Progress != nil {
 		req.Progress = o.Progress
-		if ar.Capabilities.Supports(capability.Sideband64k) {
+		if caps.Supports(capability.Sideband64k) {
 			req.Capabilities.Set(capability.Sideband64k)
-		} else if ar.Capabilities.Supports(capability.Sideband) {
+		} else if caps.Supports(capability.Sideband) {
 			req.Capabilities.Set(capability.Sideband)
 		}
 	}
 
 	if err := r.addReferencesToUpdate(o.RefSpecs, localRefs, remoteRefs, req); err != nil {
+		if nff, ok := err.(*nonFastForwardError); ok {
+			if o.Updated != nil {
+				o.Updated(&RefResult{
+					Name:   nff.cmd.Name,
+					Old:    nff.cmd.Old,
+					New:    nff.cmd.New,
+					Status: RefRejected,
+					Reason: "non-fast-forward",
+				})
+			}
+
+			return nil, ErrNonFastForwardUpdate
+		}
+
 		return nil, err
 	}
 
+	if o.Mirror {
+		if err := r.addMirrorDeletesToUpdate(localRefs, remoteRefs, ar, req); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.Tags || o.FollowTags {
+		if err := r.addTagsToUpdate(o, localRefs, remoteRefs, req); err != nil {
+			return nil, err
+		}
+	}
+
 	return req, nil
 }
 
+// addMirrorDeletesToUpdate adds to req the deletion of the references of the
+// remote missing locally.
+func (r *Remote) addMirrorDeletesToUpdate(
+	localRefs []*plumbing.Reference,
+	remoteRefs storer.ReferenceStorer,
+	ar *packp.AdvRefs,
+	req *packp.ReferenceUpdateRequest,
+) error {
+	local := make(map[plumbing.ReferenceName]bool)
+	for _, ref := range localRefs {
+		local[ref.Name()] = true
+	}
+
+	iter, err := remoteRefs.IterReferences()
+	if err != nil {
+		return err
+	}
+
+	var deletes []*packp.Command
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference || local[ref.Name()] ||
+			!strings.HasPrefix(ref.Name().String(), "refs/") {
+			return nil
+		}
+
+		deletes = append(deletes, &packp.Command{
+			Name: ref.Name(),
+			Old:  ref.Hash(),
+			New:  plumbing.ZeroHash,
+		})
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if len(deletes) > 0 && !ar.Capabilities.Supports(capability.DeleteRefs) {
+		return ErrDeleteRefNotSupported
+	}
+
+	req.Commands = append(req.Commands, deletes...)
+	return nil
+}
+
+// addTagsToUpdate adds to req the creation of the local tags missing on the
+// remote: all of them if Tags is set, or the annotated tags pointing into the
+// history of the commits being pushed if FollowTags is set. The tags excluded
+// by the negative RefSpecs are never pushed.
+func (r *Remote) addTagsToUpdate(
+	o *PushOptions,
+	localRefs []*plumbing.Reference,
+	remoteRefs storer.ReferenceStorer,
+	req *packp.ReferenceUpdateRequest,
+) error {
+	pushed := make(map[plumbing.ReferenceName]bool)
+	for _, cmd := range req.Commands {
+		pushed[cmd.Name] = true
+	}
+
+	var reachable map[plumbing.Hash]bool
+	for _, ref := range localRefs {
+		if !ref.Name().IsTag() || ref.Type() != plumbing.HashReference ||
+			pushed[ref.Name()] || config.ExcludedByAny(o.RefSpecs, ref.Name()) {
+			continue
+		}
+
+		remoteRef, err := remoteRefs.Reference(ref.Name())
+		if err == nil {
+			if o.Tags && remoteRef.Hash() != ref.Hash() {
+				return fmt.Errorf("tag already exists: %s", ref.Name())
+			}
+
+			continue
+		}
+
+		if err != plumbing.ErrReferenceNotFound {
+			return err
+		}
+
+		if !o.Tags {
+			target, err := r.annotatedTagTarget(ref.Hash())
+			if err != nil {
+				return err
+			}
+
+			if target.IsZero() {
+				continue
+			}
+
+			if reachable == nil {
+				if reachable, err = r.reachableCommits(req.Commands); err != nil {
+					return err
+				}
+			}
+
+			if !reachable[target] {
+				continue
+			}
+		}
+
+		req.Commands = append(req.Commands, &packp.Command{
+			Name: ref.Name(),
+			Old:  plumbing.ZeroHash,
+			New:  ref.Hash(),
+		})
+	}
+
+	return nil
+}
+
+// annotatedTagTarget returns the commit the annotated tag h points to, after
+// peeling the nested tags, or a zero hash if h is not an annotated tag or it
+// doesn't point to a commit.
+func (r *Remote) annotatedTagTarget(h plumbing.Hash) (plumbing.Hash, error) {
+	tag, err := object.GetTag(r.s, h)
+	if err == plumbing.ErrObjectNotFound || err == object.ErrUnsupportedObject {
+		return plumbing.ZeroHash, nil
+	}
+
+	for err == nil {
+		switch tag.TargetType {
+		case plumbing.CommitObject:
+			return tag.Target, nil
+		case plumbing.TagObject:
+			tag, err = object.GetTag(r.s, tag.Target)
+		default:
+			return plumbing.ZeroHash, nil
+		}
+	}
+
+	return plumbing.ZeroHash, err
+}
+
+// reachableCommits returns the commits reachable from the new values of the
+// commands.
+func (r *Remote) reachableCommits(commands []*packp.Command) (map[plumbing.Hash]bool, error) {
+	w := revlist.NewObjectWalker(r.s)
+	w.Filters = []revlist.ObjectFilter{revlist.TypeFilter(plumbing.CommitObject)}
+
+	seen := make(map[plumbing.Hash]bool)
+	err := w.Walk(func(h plumbing.Hash, _ plumbing.ObjectType) error {
+		seen[h] = true
+		return nil
+	}, objectsToPush(commands)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return seen, nil
+}
+
 func (r *Remote) updateRemoteReferenceStorage(
 	req *packp.ReferenceUpdateRequest,
 	result *packp.ReportStatus,
@@ -198,7 +687,7 @@ func (r *Remote) updateRemoteReferenceStorage(
 
 	for _, spec := range r.c.Fetch {
 		for _, c := range req.Commands {
-			if !spec.Match(c.Name) {
+			if !spec.Match(c.Name) || config.ExcludedByAny(r.c.Fetch, c.Name) {
 				continue
 			}
 
@@ -252,22 +741,32 @@ func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.Referen
 		return nil, err
 	}
 
-	if len(o.RefSpecs) == 0 {
+	explicit := len(o.RefSpecs) != 0
+	if !explicit {
 		o.RefSpecs = r.c.Fetch
 	}
 
-	s, err := newUploadPackSession(r.c.URLs[0], o.Auth)
+	ctx, span := startSpan(ctx, r.tracer, TraceFetch)
+	span.SetAttribute(TraceURL, r.c.URLs[0])
+	defer func() { span.End(err) }()
+
+	s, err := r.openUploadPackSession(o.Auth, o.HTTPOptions)
 	if err != nil {
 		return nil, err
 	}
 
 	defer ioutil.CheckClose(s, &err)
 
-	ar, err := s.AdvertisedReferences()
+	ar, err := r.advertisedReferences(ctx, s)
 	if err != nil {
 		return nil, err
 	}
 
+	if r.storageLock != nil {
+		r.storageLock.Lock()
+		defer r.storageLock.Unlock()
+	}
+
 	req, err := r.newUploadPackRequest(o, ar)
 	if err != nil {
 		return nil, err
@@ -288,41 +787,435 @@ func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.Referen
 		return nil, err
 	}
 
-	req.Wants, err = getWants(r.s, refs)
-	if len(req.Wants) > 0 {
-		req.Haves, err = getHaves(localRefs, remoteRefs, r.s)
-		if err != nil {
-			return nil, err
-		}
+	if err = addHashRefSpecs(ar, o.RefSpecs, refs); err != nil {
+		return nil, err
+	}
 
-		if err = r.fetchPack(ctx, o, s, req); err != nil {
+	if o.Tags == TagFollowing {
+		// the tags pointing to objects already present are wanted, since
+		// they aren't sent along with the packfile
+		if err = r.addFollowedTags(ar, remoteRefs, refs); err != nil {
 			return nil, err
 		}
 	}
 
-	updated, err := r.updateLocalReferenceStorage(o.RefSpecs, refs, remoteRefs, o.Tags, o.Force)
-	if err != nil {
+	if o.Refetch {
+		req.Wants = refsHashes(refs)
+	} else if req.Wants, err = getWants(r.s, refs); err != nil {
 		return nil, err
 	}
 
-	if !updated {
-		return remoteRefs, NoErrAlreadyUpToDate
-	}
-
-	return remoteRefs, nil
-}
+	if len(req.Wants) > 0 {
+		// A refetch sends no haves, so every object is sent again.
+		if !o.Refetch {
+			req.Haves, err = getHaves(localRefs, remoteRefs, r.s)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if !o.DryRun {
+			if err = r.fetchPack(ctx, o, s, req); err != nil {
+				return nil, err
+			}
+		}
+
+		if o.Tags == TagFollowing && !o.DryRun {
+			if err = r.fetchFollowedTags(ctx, o, ar, remoteRefs); err != nil {
+				return nil, err
+			}
+		}
+
+		if !o.DryRun {
+			if err = r.checkFetchedObjects(o, ar, remoteRefs, refs, localRefs); err != nil {
+				return nil, err
+			}
+		}
+	}
 
-func newUploadPackSession(url string, auth transport.AuthMethod) (transport.UploadPackSession, error) {
-	c, ep, err := newClient(url)
+	updated, err := r.updateLocalReferenceStorage(o, refs, remoteRefs)
 	if err != nil {
 		return nil, err
 	}
 
-	return c.NewUploadPackSession(ep, auth)
+	pruned, err := r.pruneReferences(o, remoteRefs)
+	if err != nil {
+		return nil, err
+	}
+
+	if !o.DryRun && !o.NoWriteFetchHead {
+		entries, err := r.fetchHeadEntries(o, refs, explicit)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = writeFetchHead(r.s, entries); err != nil {
+			return nil, err
+		}
+	}
+
+	if updated && !o.DryRun {
+		if err = r.writeFetchCommitGraph(o); err != nil {
+			return nil, err
+		}
+	}
+
+	if !updated && !pruned {
+		return remoteRefs, NoErrAlreadyUpToDate
+	}
+
+	return remoteRefs, nil
+}
+
+// checkFetchedObjects checks the objects reachable from the fetched
+// references, and the followed tags, if the options or fetch.fsckObjects say
+// so, with the severities of fetch.fsck.<msg-id>. The objects reachable from
+// the local references are not checked.
+func (r *Remote) checkFetchedObjects(
+	o *FetchOptions,
+	ar *packp.AdvRefs,
+	remoteRefs storer.ReferenceStorer,
+	refs memory.ReferenceStorage,
+	localRefs []*plumbing.Reference,
+) error {
+	cfg, err := r.s.Config()
+	if err != nil {
+		return err
+	}
+
+	if !o.FsckObjects {
+		check, err := cfg.FetchFsckObjects()
+		if err != nil || !check {
+			return err
+		}
+	}
+
+	severities, err := fsck.ParseSeverities(cfg.FetchFsckOptions())
+	if err != nil {
+		return err
+	}
+
+	tips := refsHashes(refs)
+	if o.Tags == TagFollowing {
+		tags := make(memory.ReferenceStorage)
+		if err := r.addFollowedTags(ar, remoteRefs, tags); err != nil {
+			return err
+		}
+
+		tips = append(tips, refsHashes(tags)...)
+	}
+
+	var known []plumbing.Hash
+	for _, ref := range localRefs {
+		if ref.Type() == plumbing.HashReference {
+			known = append(known, ref.Hash())
+		}
+	}
+
+	shallow, err := r.s.Shallow()
+	if err != nil {
+		return err
+	}
+
+	c := fsck.NewChecker(r.s)
+	c.Shallow = shallow
+	c.Severities = severities
+	if o.Progress != nil {
+		c.Warn = func(p *fsck.Problem) {
+			fmt.Fprintf(o.Progress, "warning: %s\n", p)
+		}
+	}
+
+	return c.Check(tips, known)
+}
+
+// writeFetchCommitGraph adds the fetched commits to the commit-graph, if the
+// options or fetch.writeCommitGraph say so.
+func (r *Remote) writeFetchCommitGraph(o *FetchOptions) error {
+	if !o.WriteCommitGraph {
+		cfg, err := r.s.Config()
+		if err != nil {
+			return err
+		}
+
+		if !cfg.Fetch.WriteCommitGraph {
+			return nil
+		}
+	}
+
+	return writeCommitGraphLayer(r.s)
+}
+
+// fetchHeadEntries returns the fetched references to be recorded at
+// FETCH_HEAD, in the order of the RefSpecs, the ones to be merged first. With
+// explicit RefSpecs, the references given without wildcards are to be merged,
+// otherwise only the upstream of the current branch, if it is tracking this
+// remote.
+func (r *Remote) fetchHeadEntries(
+	o *FetchOptions,
+	refs memory.ReferenceStorage,
+	explicit bool,
+) ([]*FetchHeadEntry, error) {
+	var merge plumbing.ReferenceName
+	if !explicit {
+		var err error
+		if merge, err = r.currentBranchMerge(); err != nil {
+			return nil, err
+		}
+	}
+
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name.String())
+	}
+
+	sort.Strings(names)
+
+	var forMerge, notForMerge []*FetchHeadEntry
+	seen := make(map[plumbing.ReferenceName]bool)
+	add := func(name plumbing.ReferenceName, isForMerge bool) {
+		if seen[name] {
+			return
+		}
+
+		seen[name] = true
+		e := &FetchHeadEntry{
+			Hash:        refs[name].Hash(),
+			Name:        name,
+			URL:         r.c.URLs[0],
+			NotForMerge: !isForMerge,
+		}
+
+		if isForMerge {
+			forMerge = append(forMerge, e)
+		} else {
+			notForMerge = append(notForMerge, e)
+		}
+	}
+
+	for _, spec := range o.RefSpecs {
+		if spec.IsNegative() {
+			continue
+		}
+
+		for _, n := range names {
+			name := plumbing.ReferenceName(n)
+			if !spec.Match(name) {
+				continue
+			}
+
+			if explicit {
+				add(name, !spec.IsWildcard())
+			} else {
+				add(name, name == merge)
+			}
+		}
+	}
+
+	// the tags fetched along
+	for _, n := range names {
+		add(plumbing.ReferenceName(n), false)
+	}
+
+	return append(forMerge, notForMerge...), nil
+}
+
+// currentBranchMerge returns the branch.<name>.merge of the current branch,
+// if it is tracking this remote.
+func (r *Remote) currentBranchMerge() (plumbing.ReferenceName, error) {
+	head, err := r.s.Reference(plumbing.HEAD)
+	if err == plumbing.ErrReferenceNotFound {
+		return "", nil
+	}
+
+	if err != nil || head.Type() != plumbing.SymbolicReference {
+		return "", err
+	}
+
+	cfg, err := r.s.Config()
+	if err != nil {
+		return "", err
+	}
+
+	b, ok := cfg.Branches[head.Target().Short()]
+	if !ok || b.Remote != r.c.Name || !head.Target().IsBranch() {
+		return "", nil
+	}
+
+	return b.Merge, nil
+}
+
+// pruneReferences deletes the local references mapped by the fetch RefSpecs,
+// and the tags if PruneTags is enabled, whose remote references no longer
+// exist, returning true if any reference is deleted, or would be with DryRun.
+func (r *Remote) pruneReferences(o *FetchOptions, remoteRefs storer.ReferenceStorer) (bool, error) {
+	prune, pruneTags, err := r.pruneModes(o)
+	if err != nil || (!prune && !pruneTags) {
+		return false, err
+	}
+
+	var specs []config.RefSpec
+	if prune {
+		specs = append(specs, o.RefSpecs...)
+	}
+
+	if pruneTags {
+		specs = append(specs, refspecTag)
+	}
+
+	return r.removeStaleReferences(o, specs, remoteRefs)
+}
+
+// removeStaleReferences deletes the local references that are the
+// destination of any of the specs, and whose sources no longer exist at the
+// remote, returning true if any reference is deleted, or would be with DryRun.
+func (r *Remote) removeStaleReferences(
+	o *FetchOptions,
+	specs []config.RefSpec,
+	remoteRefs storer.ReferenceStorer,
+) (bool, error) {
+	iter, err := r.s.IterReferences()
+	if err != nil {
+		return false, err
+	}
+
+	var stale []*plumbing.Reference
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+
+		isStale, err := isStaleReference(specs, o.RefSpecs, remoteRefs, ref.Name())
+		if isStale {
+			stale = append(stale, ref)
+		}
+
+		return err
+	})
+
+	if err != nil {
+		return false, err
+	}
+
+	for _, ref := range stale {
+		if o.DryRun {
+			if o.Pruned != nil {
+				o.Pruned(ref)
+			}
+
+			continue
+		}
+
+		if err := r.s.RemoveReference(ref.Name()); err != nil {
+			return false, err
+		}
+
+		if o.Pruned != nil {
+			o.Pruned(ref)
+		}
+	}
+
+	return len(stale) > 0, nil
+}
+
+// isStaleReference returns true if the local reference is the destination of
+// any of the RefSpecs, and none of its sources exist at the remote. The
+// references whose sources are excluded by the negative fetch RefSpecs are
+// never stale.
+func isStaleReference(specs, fetch []config.RefSpec, remoteRefs storer.ReferenceStorer,
+	name plumbing.ReferenceName) (bool, error) {
+
+	var isStale bool
+	for _, rs := range specs {
+		if rs.IsNegative() || rs.IsDelete() {
+			continue
+		}
+
+		rs = rs.Reverse()
+		if !rs.Match(name) {
+			continue
+		}
+
+		src := rs.Dst(name)
+		if config.ExcludedByAny(fetch, src) {
+			return false, nil
+		}
+
+		_, err := remoteRefs.Reference(src)
+		if err != plumbing.ErrReferenceNotFound {
+			return false, err
+		}
+
+		isStale = true
+	}
+
+	return isStale, nil
+}
+
+// pruneModes returns if the remote-tracking references and the tags are
+// pruned, following the configuration if the options don't enable them.
+func (r *Remote) pruneModes(o *FetchOptions) (prune, pruneTags bool, err error) {
+	prune, pruneTags = o.Prune, o.PruneTags
+	if prune && pruneTags {
+		return prune, pruneTags, nil
+	}
+
+	cfg, err := r.s.Config()
+	if err != nil {
+		return false, false, err
+	}
+
+	if !prune {
+		prune = r.c.Prune == "true" || (r.c.Prune == "" && cfg.Fetch.Prune)
+	}
+
+	if !pruneTags {
+		pruneTags = r.c.PruneTags == "true" || (r.c.PruneTags == "" && cfg.Fetch.PruneTags)
+	}
+
+	return prune, pruneTags, nil
+}
+
+// Prune deletes the remote-tracking references, the destinations of the fetch
+// refspecs of the remote, whose sources no longer exist at the remote, as
+// `git remote prune` does. No object is fetched. Returns NoErrAlreadyUpToDate
+// if there is nothing to prune.
+func (r *Remote) Prune(o *RemotePruneOptions) (err error) {
+	s, err := r.openUploadPackSession(o.Auth, o.HTTPOptions)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(s, &err)
+
+	ar, err := s.AdvertisedReferences()
+	if err != nil {
+		return err
+	}
+
+	remoteRefs, err := ar.AllReferences()
+	if err != nil {
+		return err
+	}
+
+	pruned, err := r.removeStaleReferences(&FetchOptions{
+		RefSpecs: r.c.Fetch,
+		DryRun:   o.DryRun,
+		Pruned:   o.Pruned,
+	}, r.c.Fetch, remoteRefs)
+	if err != nil {
+		return err
+	}
+
+	if !pruned {
+		return NoErrAlreadyUpToDate
+	}
+
+	return nil
 }
 
-func newSendPackSession(url string, auth transport.AuthMethod) (transport.ReceivePackSession, error) {
-	c, ep, err := newClient(url)
+func newSendPackSession(url string, auth transport.AuthMethod, h *transport.HTTPOptions) (transport.ReceivePackSession, error) {
+	c, ep, err := newClient(url, h)
 	if err != nil {
 		return nil, err
 	}
@@ -330,12 +1223,14 @@ func newSendPackSession(url string, auth transport.AuthMethod) (transport.Receiv
 	return c.NewReceivePackSession(ep, auth)
 }
 
-func newClient(url string) (transport.Transport, *transport.Endpoint, error) {
+func newClient(url string, h *transport.HTTPOptions) (transport.Transport, *transport.Endpoint, error) {
 	ep, err := transport.NewEndpoint(url)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	ep.HTTP = h
+
 	c, err := client.NewClient(ep)
 	if err != nil {
 		return nil, nil, err
@@ -344,10 +1239,133 @@ func newClient(url string) (transport.Transport, *transport.Endpoint, error) {
 	return c, ep, err
 }
 
+// httpOptions returns the HTTP options of the requests to the given URL, as
+// httpOptions does, with the agent of the remote as User-Agent if neither
+// the config nor o set one.
+func (r *Remote) httpOptions(cfg *config.Config, url string, o *transport.HTTPOptions) (*transport.HTTPOptions, error) {
+	h, err := httpOptions(cfg.HTTPFor(url), o)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.UserAgent == "" {
+		h.UserAgent = cfg.Agent(r.c)
+	}
+
+	return h, nil
+}
+
+// httpOptions returns the HTTP options of the given http.<url>.* config,
+// overridden by the set fields of o.
+func httpOptions(cfg *config.HTTP, o *transport.HTTPOptions) (*transport.HTTPOptions, error) {
+	h := &transport.HTTPOptions{
+		ExtraHeaders:    make(http.Header),
+		UserAgent:       cfg.UserAgent,
+		InsecureSkipTLS: isFalse(cfg.SSLVerify),
+		ClientCert:      cfg.SSLCert,
+		ClientKey:       cfg.SSLKey,
+		FollowRedirects: cfg.FollowRedirects,
+		LowSpeedLimit:   cfg.LowSpeedLimit,
+		LowSpeedTime:    time.Duration(cfg.LowSpeedTime) * time.Second,
+	}
+
+	if cfg.SSLCAInfo != "" {
+		pem, err := stdioutil.ReadFile(cfg.SSLCAInfo)
+		if err != nil {
+			return nil, err
+		}
+
+		h.RootCAs = x509.NewCertPool()
+		if !h.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("invalid http.sslCAInfo: %q", cfg.SSLCAInfo)
+		}
+	}
+
+	if cfg.SSLVersion != "" {
+		v, ok := tlsVersions[strings.ToLower(cfg.SSLVersion)]
+		if !ok {
+			return nil, fmt.Errorf("invalid http.sslVersion: %q", cfg.SSLVersion)
+		}
+
+		h.MinTLSVersion = v
+	}
+
+	for _, header := range cfg.ExtraHeaders {
+		i := strings.Index(header, ":")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid http.extraHeader: %q", header)
+		}
+
+		h.ExtraHeaders.Add(strings.TrimSpace(header[:i]), strings.TrimSpace(header[i+1:]))
+	}
+
+	if o == nil {
+		return h, nil
+	}
+
+	for key, values := range o.ExtraHeaders {
+		for _, v := range values {
+			h.ExtraHeaders.Add(key, v)
+		}
+	}
+
+	if o.UserAgent != "" {
+		h.UserAgent = o.UserAgent
+	}
+
+	if o.InsecureSkipTLS {
+		h.InsecureSkipTLS = true
+	}
+
+	if o.ClientCert != "" {
+		h.ClientCert, h.ClientKey = o.ClientCert, o.ClientKey
+	}
+
+	h.ClientCertificates = append(h.ClientCertificates, o.ClientCertificates...)
+	if o.RootCAs != nil {
+		h.RootCAs = o.RootCAs
+	}
+
+	if o.MinTLSVersion != 0 {
+		h.MinTLSVersion = o.MinTLSVersion
+	}
+
+	if o.FollowRedirects != "" {
+		h.FollowRedirects = o.FollowRedirects
+	}
+
+	if o.LowSpeedLimit > 0 {
+		h.LowSpeedLimit = o.LowSpeedLimit
+	}
+
+	if o.LowSpeedTime > 0 {
+		h.LowSpeedTime = o.LowSpeedTime
+	}
+
+	return h, nil
+}
+
+var tlsVersions = map[string]uint16{
+	"tlsv1.0": tls.VersionTLS10,
+	"tlsv1.1": tls.VersionTLS11,
+	"tlsv1.2": tls.VersionTLS12,
+	"tlsv1.3": tls.VersionTLS13,
+}
+
+// isFalse returns true if value is one of the false booleans of git config.
+func isFalse(value string) bool {
+	switch strings.ToLower(value) {
+	case "false", "no", "off", "0":
+		return true
+	}
+
+	return false
+}
+
 func (r *Remote) fetchPack(ctx context.Context, o *FetchOptions, s transport.UploadPackSession,
 	req *packp.UploadPackRequest) (err error) {
 
-	reader, err := s.UploadPack(ctx, req)
+	reader, err := r.negotiate(ctx, s, req)
 	if err != nil {
 		return err
 	}
@@ -358,21 +1376,111 @@ func (r *Remote) fetchPack(ctx context.Context, o *FetchOptions, s transport.Upl
 		return err
 	}
 
-	if err = packfile.UpdateObjectStorage(r.s,
+	if err = r.updateObjectStorage(ctx, o, ioutil.NewContextReader(ctx,
 		buildSidebandIfSupported(req.Capabilities, reader, o.Progress),
-	); err != nil {
+	)); err != nil {
 		return err
 	}
 
 	return err
 }
 
+// advertisedReferences requests the references advertised by the remote.
+func (r *Remote) advertisedReferences(ctx context.Context, s transport.Session) (ar *packp.AdvRefs, err error) {
+	_, span := startSpan(ctx, r.tracer, TraceAdvertisedReferences)
+	defer func() { span.End(err) }()
+
+	ar, err = s.AdvertisedReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	span.SetAttribute(TraceReferences, len(ar.References))
+	return ar, nil
+}
+
+// negotiate sends the wants and haves of req, returning the response of the
+// remote, ready to read the packfile.
+func (r *Remote) negotiate(ctx context.Context, s transport.UploadPackSession,
+	req *packp.UploadPackRequest) (resp *packp.UploadPackResponse, err error) {
+
+	ctx, span := startSpan(ctx, r.tracer, TraceNegotiation)
+	span.SetAttribute(TraceWants, len(req.Wants))
+	span.SetAttribute(TraceHaves, len(req.Haves))
+	defer func() { span.End(err) }()
+
+	return s.UploadPack(ctx, req)
+}
+
+// updateObjectStorage stores the objects of pack, tracing its download
+// apart from its indexing when the storage writes the packfiles as they are.
+// The storages streaming the packfiles to disk are preferred.
+func (r *Remote) updateObjectStorage(ctx context.Context, o *FetchOptions, pack io.Reader) (err error) {
+	pw, ok := r.packfileWriter(o)
+	if !ok {
+		_, span := startSpan(ctx, r.tracer, TracePackDownload)
+		cr := &countingReader{r: pack}
+		defer func() {
+			span.SetAttribute(TraceBytes, cr.n)
+			span.End(err)
+		}()
+
+		return packfile.UpdateObjectStorage(r.s, cr)
+	}
+
+	_, span := startSpan(ctx, r.tracer, TracePackDownload)
+	w, err := pw()
+	if err != nil {
+		span.End(err)
+		return err
+	}
+
+	n, err := io.Copy(w, pack)
+	span.SetAttribute(TraceBytes, n)
+	span.End(err)
+	if err != nil {
+		w.Close()
+		return err
+	}
+
+	_, span = startSpan(ctx, r.tracer, TracePackIndex)
+	err = w.Close()
+	span.End(err)
+	return err
+}
+
+// packfileWriter returns the function opening a writer of packfiles to the
+// storage, if it supports writing them as they are.
+func (r *Remote) packfileWriter(o *FetchOptions) (func() (io.WriteCloser, error), bool) {
+	if sw, ok := r.s.(storer.StreamPackfileWriter); ok {
+		return func() (io.WriteCloser, error) {
+			return sw.StreamPackfileWriter(o.DeltaBaseCacheSize)
+		}, true
+	}
+
+	if pw, ok := r.s.(storer.PackfileWriter); ok {
+		return pw.PackfileWriter, true
+	}
+
+	return nil, false
+}
+
 func (r *Remote) addReferencesToUpdate(
 	refspecs []config.RefSpec,
 	localRefs []*plumbing.Reference,
 	remoteRefs storer.ReferenceStorer,
 	req *packp.ReferenceUpdateRequest,
 ) error {
+	// The references excluded by the negative refspecs are never pushed.
+	var pushable []*plumbing.Reference
+	for _, ref := range localRefs {
+		if !config.ExcludedByAny(refspecs, ref.Name()) {
+			pushable = append(pushable, ref)
+		}
+	}
+
+	localRefs = pushable
+
 	// This references dictionary will be used to search references by name.
 	refsDict := make(map[string]*plumbing.Reference)
 	for _, ref := range localRefs {
@@ -658,6 +1766,62 @@ func calculateRefs(
 	})
 }
 
+// addHashRefSpecs adds to refs the hashes fetched by the refspecs with an
+// exact hash as source, each one named as the hash so the refspec matches
+// it. A hash not advertised is only allowed if the remote supports
+// allow-tip-sha1-in-want or allow-reachable-sha1-in-want.
+func addHashRefSpecs(ar *packp.AdvRefs, specs []config.RefSpec, refs memory.ReferenceStorage) error {
+	allowed := ar.Capabilities.Supports(capability.AllowTipSHA1InWant) ||
+		ar.Capabilities.Supports(capability.AllowReachableSHA1InWant)
+
+	for _, spec := range specs {
+		if spec.IsNegative() || spec.IsDelete() || spec.IsWildcard() {
+			continue
+		}
+
+		src := spec.Src()
+		if !isHashRefSpecSource(src) {
+			continue
+		}
+
+		h := plumbing.NewHash(src)
+		if !allowed && !isAdvertised(ar, h) {
+			return ErrUnadvertisedObject
+		}
+
+		if err := refs.SetReference(plumbing.NewHashReference(plumbing.ReferenceName(src), h)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func isHashRefSpecSource(src string) bool {
+	if len(src) != 40 {
+		return false
+	}
+
+	_, err := hex.DecodeString(src)
+	return err == nil
+}
+
+func isAdvertised(ar *packp.AdvRefs, h plumbing.Hash) bool {
+	if ar.Head != nil && *ar.Head == h {
+		return true
+	}
+
+	for _, refs := range []map[string]plumbing.Hash{ar.References, ar.Peeled} {
+		for _, ref := range refs {
+			if ref == h {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 func getWants(localStorer storage.Storer, refs memory.ReferenceStorage) ([]plumbing.Hash, error) {
 	wants := map[plumbing.Hash]bool{}
 	for _, ref := range refs {
@@ -680,6 +1844,23 @@ func getWants(localStorer storage.Storer, refs memory.ReferenceStorage) ([]plumb
 	return result, nil
 }
 
+// refsHashes returns the hashes the given references point to, whether
+// present locally or not.
+func refsHashes(refs memory.ReferenceStorage) []plumbing.Hash {
+	seen := map[plumbing.Hash]bool{}
+	var result []plumbing.Hash
+	for _, ref := range refs {
+		if seen[ref.Hash()] {
+			continue
+		}
+
+		seen[ref.Hash()] = true
+		result = append(result, ref.Hash())
+	}
+
+	return result
+}
+
 func objectExists(s storer.EncodedObjectStorer, h plumbing.Hash) (bool, error) {
 	_, err := s.EncodedObject(plumbing.AnyObject, h)
 	if err == plumbing.ErrObjectNotFound {
@@ -700,73 +1881,162 @@ func checkFastForwardUpdate(s storer.EncodedObjectStorer, remoteRefs storer.Refe
 			return err
 		}
 
-		return fmt.Errorf("non-fast-forward update: %s", cmd.Name.String())
+		return &nonFastForwardError{cmd}
 	}
 
 	ff, err := isFastForward(s, cmd.Old, cmd.New)
 	if err != nil {
-		return err
+		return err
+	}
+
+	if !ff {
+		return &nonFastForwardError{cmd}
+	}
+
+	return nil
+}
+
+func isFastForward(s storer.EncodedObjectStorer, old, new plumbing.Hash) (bool, error) {
+	c, err := object.GetCommit(s, new)
+	if err != nil {
+		return false, err
+	}
+
+	found := false
+	iter := object.NewCommitPreorderIter(c, nil, nil)
+	err = iter.ForEach(func(c *object.Commit) error {
+		if c.Hash != old {
+			return nil
+		}
+
+		found = true
+		return storer.ErrStop
+	})
+	return found, err
+}
+
+func (r *Remote) newUploadPackRequest(o *FetchOptions,
+	ar *packp.AdvRefs) (*packp.UploadPackRequest, error) {
+
+	caps, err := r.requestableCapabilities(ar.Capabilities, o.DisabledCapabilities, false)
+	if err != nil {
+		return nil, err
+	}
+
+	req := packp.NewUploadPackRequestFromCapabilities(caps)
+	if err := r.setAgent(req.Capabilities); err != nil {
+		return nil, err
+	}
+
+	if o.Depth != 0 {
+		req.Depth = packp.DepthCommits(o.Depth)
+		if err := req.Capabilities.Set(capability.Shallow); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.Progress == nil && caps.Supports(capability.NoProgress) {
+		if err := req.Capabilities.Set(capability.NoProgress); err != nil {
+			return nil, err
+		}
+	}
+
+	if o.Tags == TagFollowing && caps.Supports(capability.IncludeTag) {
+		if err := req.Capabilities.Set(capability.IncludeTag); err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+// requestableCapabilities returns the capabilities advertised by the server
+// which may be requested: all of them except the disabled ones and the ones
+// disabled by the config, ofs-delta by repack.useDeltaBaseOffset and, for
+// the pushes, the side-bands by sendpack.sideband.
+func (r *Remote) requestableCapabilities(
+	adv *capability.List, disabled []capability.Capability, push bool,
+) (*capability.List, error) {
+	cfg, err := r.s.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	disabled = append([]capability.Capability(nil), disabled...)
+	ofsDelta, err := cfg.UseDeltaBaseOffset()
+	if err != nil {
+		return nil, err
 	}
 
-	if !ff {
-		return fmt.Errorf("non-fast-forward update: %s", cmd.Name.String())
+	if !ofsDelta {
+		disabled = append(disabled, capability.OFSDelta)
 	}
 
-	return nil
-}
+	if push {
+		sideband, err := cfg.SendPackSideband()
+		if err != nil {
+			return nil, err
+		}
 
-func isFastForward(s storer.EncodedObjectStorer, old, new plumbing.Hash) (bool, error) {
-	c, err := object.GetCommit(s, new)
-	if err != nil {
-		return false, err
+		if !sideband {
+			disabled = append(disabled, capability.Sideband, capability.Sideband64k)
+		}
 	}
 
-	found := false
-	iter := object.NewCommitPreorderIter(c, nil, nil)
-	err = iter.ForEach(func(c *object.Commit) error {
-		if c.Hash != old {
-			return nil
+	caps := capability.NewList()
+	for _, c := range adv.All() {
+		if isDisabledCapability(disabled, c) {
+			continue
 		}
 
-		found = true
-		return storer.ErrStop
-	})
-	return found, err
-}
+		if err := caps.Add(c, adv.Get(c)...); err != nil {
+			return nil, err
+		}
+	}
 
-func (r *Remote) newUploadPackRequest(o *FetchOptions,
-	ar *packp.AdvRefs) (*packp.UploadPackRequest, error) {
+	return caps, nil
+}
 
-	req := packp.NewUploadPackRequestFromCapabilities(ar.Capabilities)
+// setAgent replaces the default agent of the requested capabilities by the
+// one of the config, if any. As git does, the characters not allowed in a
+// capability value are replaced by dots.
+func (r *Remote) setAgent(caps *capability.List) error {
+	if !caps.Supports(capability.Agent) {
+		return nil
+	}
 
-	if o.Depth != 0 {
-		req.Depth = packp.DepthCommits(o.Depth)
-		if err := req.Capabilities.Set(capability.Shallow); err != nil {
-			return nil, err
-		}
+	cfg, err := r.s.Config()
+	if err != nil {
+		return err
 	}
 
-	if o.Progress == nil && ar.Capabilities.Supports(capability.NoProgress) {
-		if err := req.Capabilities.Set(capability.NoProgress); err != nil {
-			return nil, err
-		}
+	agent := cfg.Agent(r.c)
+	if agent == "" {
+		return nil
 	}
 
-	isWildcard := true
-	for _, s := range o.RefSpecs {
-		if !s.IsWildcard() {
-			isWildcard = false
-			break
+	return caps.Set(capability.Agent, sanitizeAgent(agent))
+}
+
+func sanitizeAgent(agent string) string {
+	b := []byte(agent)
+	for i, c := range b {
+		if c <= ' ' || c > '~' {
+			b[i] = '.'
 		}
 	}
 
-	if isWildcard && o.Tags == TagFollowing && ar.Capabilities.Supports(capability.IncludeTag) {
-		if err := req.Capabilities.Set(capability.IncludeTag); err != nil {
-			return nil, err
+	return string(b)
+}
+
+func isDisabledCapability(disabled []capability.Capability, c capability.Capability) bool {
+	for _, d := range disabled {
+		if d == c {
+			return true
 		}
 	}
 
-	return req, nil
+	return false
 }
 
 func buildSidebandIfSupported(l *capability.List, reader io.Reader, p sideband.Progress) io.Reader {
@@ -788,19 +2058,12 @@ func buildSidebandIfSupported(l *capability.List, reader io.Reader, p sideband.P
 }
 
 func (r *Remote) updateLocalReferenceStorage(
-	specs []config.RefSpec,
+	o *FetchOptions,
 	fetchedRefs, remoteRefs memory.ReferenceStorage,
-	tagMode TagMode,
-	force bool,
 ) (updated bool, err error) {
-	isWildcard := true
 	forceNeeded := false
 
-	for _, spec := range specs {
-		if !spec.IsWildcard() {
-			isWildcard = false
-		}
-
+	for _, spec := range o.RefSpecs {
 		for _, ref := range fetchedRefs {
 			if !spec.Match(ref.Name()) {
 				continue
@@ -816,19 +2079,29 @@ func (r *Remote) updateLocalReferenceStorage(
 
 			// If the ref exists locally as a branch and force is not specified,
 			// only update if the new ref is an ancestor of the old
-			if old != nil && old.Name().IsBranch() && !force && !spec.IsForceUpdate() {
-				ff, err := isFastForward(r.s, old.Hash(), new.Hash())
+			if old != nil && old.Name().IsBranch() && !o.Force && !spec.IsForceUpdate() {
+				ff, err := r.isFetchFastForward(o, old.Hash(), new.Hash())
 				if err != nil {
 					return updated, err
 				}
 
 				if !ff {
 					forceNeeded = true
+					if o.Updated != nil {
+						o.Updated(&RefResult{
+							Name:   localName,
+							Old:    old.Hash(),
+							New:    new.Hash(),
+							Status: RefRejected,
+							Reason: "non-fast-forward",
+						})
+					}
+
 					continue
 				}
 			}
 
-			refUpdated, err := checkAndUpdateReferenceStorerIfNeeded(r.s, new, old)
+			refUpdated, err := r.updateFetchedReference(o, new, old)
 			if err != nil {
 				return updated, err
 			}
@@ -839,15 +2112,18 @@ func (r *Remote) updateLocalReferenceStorage(
 		}
 	}
 
-	if tagMode == NoTags {
+	if o.Tags == NoTags {
 		return updated, nil
 	}
 
-	tags := fetchedRefs
-	if isWildcard {
-		tags = remoteRefs
+	tags := remoteRefs
+	if o.DryRun {
+		// the objects of the tags aren't fetched, only the wanted ones
+		// would be present
+		tags = fetchedRefs
 	}
-	tagUpdated, err := r.buildFetchedTags(tags)
+
+	tagUpdated, err := r.buildFetchedTags(o, tags)
 	if err != nil {
 		return updated, err
 	}
@@ -863,22 +2139,191 @@ func (r *Remote) updateLocalReferenceStorage(
 	return
 }
 
-func (r *Remote) buildFetchedTags(refs memory.ReferenceStorage) (updated bool, err error) {
+// isFetchFastForward returns true if the update from old to new is a
+// fast-forward. With DryRun, the updates to commits not present locally are
+// assumed to be fast-forwards, since their histories aren't fetched.
+func (r *Remote) isFetchFastForward(o *FetchOptions, old, new plumbing.Hash) (bool, error) {
+	if o.DryRun {
+		exists, err := objectExists(r.s, new)
+		if err != nil || !exists {
+			return true, err
+		}
+	}
+
+	return isFastForward(r.s, old, new)
+}
+
+// updateFetchedReference sets the reference new, if it differs from the
+// current one, checking that the current one is old, and reports the outcome
+// to FetchOptions.Updated. Nothing is set with DryRun.
+func (r *Remote) updateFetchedReference(o *FetchOptions, new, old *plumbing.Reference) (bool, error) {
+	cur, err := r.s.Reference(new.Name())
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return false, err
+	}
+
+	update := &RefResult{Name: new.Name(), New: new.Hash()}
+	if old != nil {
+		update.Old = old.Hash()
+	} else if cur != nil && cur.Type() == plumbing.HashReference {
+		update.Old = cur.Hash()
+	}
+
+	// we use the string method to compare references, is the easiest way
+	if err == nil && cur.String() == new.String() {
+		if o.Updated != nil {
+			update.Status = RefUpToDate
+			o.Updated(update)
+		}
+
+		return false, nil
+	}
+
+	if !o.DryRun {
+		if err := r.s.CheckAndSetReference(new, old); err != nil {
+			return false, err
+		}
+	}
+
+	if o.Updated != nil {
+		if update.Status, err = r.fetchUpdateStatus(o, update); err != nil {
+			return true, err
+		}
+
+		o.Updated(update)
+	}
+
+	return true, nil
+}
+
+// fetchUpdateStatus returns the status of an update performed by a fetch.
+// The tags not pointing to commits are always forced when they change.
+func (r *Remote) fetchUpdateStatus(o *FetchOptions, u *RefResult) (RefStatus, error) {
+	if u.Old.IsZero() {
+		return RefCreated, nil
+	}
+
+	ff, err := r.isFetchFastForward(o, u.Old, u.New)
+	if err == plumbing.ErrObjectNotFound || err == object.ErrUnsupportedObject {
+		return RefForced, nil
+	}
+
+	if err != nil {
+		return RefForced, err
+	}
+
+	if !ff {
+		return RefForced, nil
+	}
+
+	return RefFastForward, nil
+}
+
+// addFollowedTags adds to refs the tags of the remote whose target is present
+// in the local storage, peeling the annotated tags with the peeled references
+// advertised by the remote.
+func (r *Remote) addFollowedTags(
+	ar *packp.AdvRefs,
+	remoteRefs storer.ReferenceStorer,
+	refs memory.ReferenceStorage,
+) error {
+	iter, err := remoteRefs.IterReferences()
+	if err != nil {
+		return err
+	}
+
+	return iter.ForEach(func(ref *plumbing.Reference) error {
+		if !ref.Name().IsTag() || ref.Type() != plumbing.HashReference {
+			return nil
+		}
+
+		exists, err := objectExists(r.s, peeledTarget(ar, ref))
+		if err != nil || !exists {
+			return err
+		}
+
+		return refs.SetReference(ref)
+	})
+}
+
+// fetchFollowedTags fetches the annotated tags pointing into the fetched
+// history that were not sent along with the packfile, as happens with the
+// servers not supporting the include-tag capability.
+func (r *Remote) fetchFollowedTags(
+	ctx context.Context,
+	o *FetchOptions,
+	ar *packp.AdvRefs,
+	remoteRefs storer.ReferenceStorer,
+) (err error) {
+	tags := make(memory.ReferenceStorage)
+	if err := r.addFollowedTags(ar, remoteRefs, tags); err != nil {
+		return err
+	}
+
+	wants, err := getWants(r.s, tags)
+	if err != nil || len(wants) == 0 {
+		return err
+	}
+
+	s, err := r.openUploadPackSession(o.Auth, o.HTTPOptions)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(s, &err)
+
+	ar, err = r.advertisedReferences(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	// the tags are fetched on top of the fetched history, whatever the
+	// requested depth
+	opts := *o
+	opts.Depth = 0
+
+	req, err := r.newUploadPackRequest(&opts, ar)
+	if err != nil {
+		return err
+	}
+
+	req.Wants = wants
+	for _, ref := range tags {
+		req.Haves = append(req.Haves, peeledTarget(ar, ref))
+	}
+
+	return r.fetchPack(ctx, &opts, s, req)
+}
+
+// peeledTarget returns the object a tag advertised by the remote points to,
+// which is its hash for the lightweight tags and for the annotated tags whose
+// peeled reference is not advertised.
+func peeledTarget(ar *packp.AdvRefs, ref *plumbing.Reference) plumbing.Hash {
+	if h, ok := ar.Peeled[ref.Name().String()]; ok {
+		return h
+	}
+
+	return ref.Hash()
+}
+
+func (r *Remote) buildFetchedTags(o *FetchOptions, refs memory.ReferenceStorage) (updated bool, err error) {
 	for _, ref := range refs {
 		if !ref.Name().IsTag() {
 			continue
 		}
 
-		_, err := r.s.EncodedObject(plumbing.AnyObject, ref.Hash())
-		if err == plumbing.ErrObjectNotFound {
-			continue
-		}
+		if !o.DryRun {
+			exists, err := objectExists(r.s, ref.Hash())
+			if err != nil {
+				return false, err
+			}
 
-		if err != nil {
-			return false, err
+			if !exists {
+				continue
+			}
 		}
 
-		refUpdated, err := updateReferenceStorerIfNeeded(r.s, ref)
+		refUpdated, err := r.updateFetchedReference(o, ref, nil)
 		if err != nil {
 			return updated, err
 		}
@@ -891,16 +2336,87 @@ func (r *Remote) buildFetchedTags(refs memory.ReferenceStorage) (updated bool, e
 	return
 }
 
-// List the references on the remote repository.
-func (r *Remote) List(o *ListOptions) (rfs []*plumbing.Reference, err error) {
-	s, err := newUploadPackSession(r.c.URLs[0], o.Auth)
+// Archive writes to w the archive of a tree-ish of the remote repository,
+// produced by the server as `git archive --remote` does.
+func (r *Remote) Archive(w io.Writer, o *ArchiveOptions) error {
+	return r.ArchiveContext(context.Background(), w, o)
+}
+
+// ArchiveContext writes to w the archive of a tree-ish of the remote
+// repository, produced by the server as `git archive --remote` does.
+//
+// The provided Context must be non-nil. If the context expires before the
+// operation is complete, an error is returned. The context only affects to the
+// transport operations.
+func (r *Remote) ArchiveContext(ctx context.Context, w io.Writer, o *ArchiveOptions) (err error) {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	cfg, err := r.s.Config()
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	c, ep, err := newClient(cfg.RewriteURL(r.c.URLs[0]), nil)
+	if err != nil {
+		return err
+	}
+
+	t, ok := c.(transport.UploadArchiveTransport)
+	if !ok {
+		return ErrUploadArchiveNotSupported
+	}
+
+	s, err := t.NewUploadArchiveSession(ep, o.Auth)
+	if err != nil {
+		return err
 	}
 
 	defer ioutil.CheckClose(s, &err)
 
-	ar, err := s.AdvertisedReferences()
+	req := packp.NewUploadArchiveRequest()
+	req.Progress = o.Progress
+	req.Arguments = archiveArguments(o)
+
+	rc, err := s.UploadArchive(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(rc, &err)
+
+	_, err = io.Copy(w, rc)
+	return err
+}
+
+// archiveArguments returns the git-archive arguments of the options.
+func archiveArguments(o *ArchiveOptions) []string {
+	var args []string
+	if o.Format != "" {
+		args = append(args, "--format="+string(o.Format))
+	}
+
+	if o.Prefix != "" {
+		args = append(args, "--prefix="+o.Prefix)
+	}
+
+	args = append(args, o.Revision)
+	if len(o.Paths) > 0 {
+		args = append(args, "--")
+		args = append(args, o.Paths...)
+	}
+
+	return args
+}
+
+// List the references on the remote repository.
+func (r *Remote) List(o *ListOptions) (rfs []*plumbing.Reference, err error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	ar, err := r.listAdvertisedReferences(o)
 	if err != nil {
 		return nil, err
 	}
@@ -917,13 +2433,126 @@ func (r *Remote) List(o *ListOptions) (rfs []*plumbing.Reference, err error) {
 
 	var resultRefs []*plumbing.Reference
 	refs.ForEach(func(ref *plumbing.Reference) error {
-		resultRefs = append(resultRefs, ref)
+		if listMatch(o, ref.Name()) {
+			resultRefs = append(resultRefs, ref)
+		}
+
 		return nil
 	})
 
+	if o.Peeled {
+		for name, h := range ar.Peeled {
+			if listMatch(o, plumbing.ReferenceName(name)) {
+				peeled := plumbing.ReferenceName(name + peeledSuffix)
+				resultRefs = append(resultRefs, plumbing.NewHashReference(peeled, h))
+			}
+		}
+	}
+
+	sortListedReferences(resultRefs)
 	return resultRefs, nil
 }
 
+// listAdvertisedReferences returns the references advertised by the remote.
+// Only the ones starting with the prefixes of the options are asked for, to
+// the transports able to, see listPrefixes.
+func (r *Remote) listAdvertisedReferences(o *ListOptions) (ar *packp.AdvRefs, err error) {
+	c, ep, err := r.fetchClient(o.HTTPOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	req := packp.NewLsRefsRequest()
+	req.Prefixes = listPrefixes(o)
+	if t, ok := c.(transport.LsRefsTransport); ok && len(req.Prefixes) > 0 {
+		s, err := t.NewLsRefsSession(ep, o.Auth)
+		if err != nil {
+			return nil, err
+		}
+
+		defer ioutil.CheckClose(s, &err)
+		return s.LsRefs(context.Background(), req)
+	}
+
+	s, err := c.NewUploadPackSession(ep, o.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(s, &err)
+	return s.AdvertisedReferences()
+}
+
+// listPrefixes returns the prefixes of the references listed with the
+// options, none if any reference may be listed. The patterns match the
+// trailing components of the names, so they restrict nothing.
+func listPrefixes(o *ListOptions) []string {
+	if len(o.RefPrefixes) > 0 {
+		return o.RefPrefixes
+	}
+
+	var prefixes []string
+	if o.Heads {
+		prefixes = append(prefixes, "refs/heads/")
+	}
+
+	if o.Tags {
+		prefixes = append(prefixes, "refs/tags/")
+	}
+
+	return prefixes
+}
+
+// peeledSuffix is the suffix of the names of the peeled tags listed.
+const peeledSuffix = "^{}"
+
+func listMatch(o *ListOptions, name plumbing.ReferenceName) bool {
+	if (o.Heads || o.Tags) && !(o.Heads && name.IsBranch()) && !(o.Tags && name.IsTag()) {
+		return false
+	}
+
+	prefixes := &packp.LsRefsRequest{Prefixes: o.RefPrefixes}
+	if !prefixes.Match(name.String()) {
+		return false
+	}
+
+	if len(o.Patterns) == 0 {
+		return true
+	}
+
+	for _, p := range o.Patterns {
+		if refPatternMatch(p, name) != -1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sortListedReferences sorts the references by name as git does, with HEAD
+// first and each peeled tag right after its tag.
+func sortListedReferences(refs []*plumbing.Reference) {
+	key := func(ref *plumbing.Reference) (string, bool) {
+		name := ref.Name().String()
+		if name == plumbing.HEAD.String() {
+			return "", false
+		}
+
+		trimmed := strings.TrimSuffix(name, peeledSuffix)
+		return trimmed, trimmed != name
+	}
+
+	sort.Slice(refs, func(i, j int) bool {
+		a, peeledA := key(refs[i])
+		b, peeledB := key(refs[j])
+		if a != b {
+			return a < b
+		}
+
+		return !peeledA && peeledB
+	})
+}
+
 func objectsToPush(commands []*packp.Command) []plumbing.Hash {
 	var objects []plumbing.Hash
 	for _, cmd := range commands {
@@ -958,15 +2587,24 @@ func referencesToHashes(refs storer.ReferenceStorer) ([]plumbing.Hash, error) {
 	return hs, nil
 }
 
-func pushHashes(
+func (r *Remote) pushHashes(
 	ctx context.Context,
 	sess transport.ReceivePackSession,
-	s storage.Storer,
 	req *packp.ReferenceUpdateRequest,
 	hs []plumbing.Hash,
-) (*packp.ReportStatus, error) {
+) (rs *packp.ReportStatus, err error) {
+
+	ctx, span := startSpan(ctx, r.tracer, TracePackUpload)
+	span.SetAttribute(TraceObjects, len(hs))
+	cw := &countingWriter{}
+	defer func() {
+		span.SetAttribute(TraceBytes, cw.n)
+		span.End(err)
+	}()
 
+	s := r.s
 	rd, wr := io.Pipe()
+	cw.w = wr
 	req.Packfile = rd
 	config, err := s.Config()
 	if err != nil {
@@ -974,7 +2612,8 @@ func pushHashes(
 	}
 	done := make(chan error)
 	go func() {
-		e := packfile.NewEncoder(wr, s, false)
+		useRefDeltas := !req.Capabilities.Supports(capability.OFSDelta)
+		e := packfile.NewEncoder(ioutil.NewContextWriter(ctx, cw), s, useRefDeltas)
 		if _, err := e.Encode(hs, config.Pack.Window); err != nil {
 			done <- wr.CloseWithError(err)
 			return
@@ -983,7 +2622,7 @@ func pushHashes(
 		done <- wr.Close()
 	}()
 
-	rs, err := sess.ReceivePack(ctx, req)
+	rs, err = sess.ReceivePack(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,159 @@
+package git
+
+import (
+	"context"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// RefStatus is the outcome of the update of a reference by a fetch or a push.
+type RefStatus int
+
+const (
+	// RefUpToDate the reference already pointed to the new hash.
+	RefUpToDate RefStatus = iota
+	// RefCreated the reference didn't exist.
+	RefCreated
+	// RefFastForward the new hash descends from the old one.
+	RefFastForward
+	// RefForced the new hash doesn't descend from the old one.
+	RefForced
+	// RefRejected the reference was not updated, see RefResult.Reason.
+	RefRejected
+	// RefDeleted the reference was deleted.
+	RefDeleted
+)
+
+func (s RefStatus) String() string {
+	switch s {
+	case RefUpToDate:
+		return "up-to-date"
+	case RefCreated:
+		return "created"
+	case RefFastForward:
+		return "fast-forward"
+	case RefForced:
+		return "forced"
+	case RefRejected:
+		return "rejected"
+	case RefDeleted:
+		return "deleted"
+	}
+
+	return "unknown"
+}
+
+// RefResult describes the outcome of the update of a reference by a fetch or
+// a push.
+type RefResult struct {
+	// Name is the name of the reference.
+	Name plumbing.ReferenceName
+	// Old is the hash the reference pointed to, zero if it's created.
+	Old plumbing.Hash
+	// New is the hash the reference points to, zero if it's deleted.
+	New plumbing.Hash
+	// Status is the outcome of the update.
+	Status RefStatus
+	// Reason is why the update was rejected, empty otherwise.
+	Reason string
+}
+
+// FetchResult is the result of a fetch.
+type FetchResult struct {
+	// Refs are the local references processed by the fetch, in the order
+	// they were processed.
+	Refs []*RefResult
+}
+
+// PushResult is the result of a push.
+type PushResult struct {
+	// Refs are the references of the remote processed by the push, in the
+	// order they were processed.
+	Refs []*RefResult
+}
+
+// FetchWithResult fetches as FetchContext does, returning the references
+// processed. NoErrAlreadyUpToDate is never returned, the references are
+// reported as RefUpToDate instead. The result is returned along with the
+// error, if any, covering the references processed before it.
+func (r *Remote) FetchWithResult(ctx context.Context, o *FetchOptions) (*FetchResult, error) {
+	result := &FetchResult{}
+
+	opts := *o
+	opts.Updated = func(u *RefResult) {
+		result.Refs = append(result.Refs, u)
+		if o.Updated != nil {
+			o.Updated(u)
+		}
+	}
+
+	opts.Pruned = func(ref *plumbing.Reference) {
+		result.Refs = append(result.Refs, &RefResult{
+			Name:   ref.Name(),
+			Old:    ref.Hash(),
+			Status: RefDeleted,
+		})
+
+		if o.Pruned != nil {
+			o.Pruned(ref)
+		}
+	}
+
+	err := r.FetchContext(ctx, &opts)
+	if err == NoErrAlreadyUpToDate {
+		err = nil
+	}
+
+	return result, err
+}
+
+// PushWithResult pushes as PushContext does, returning the references
+// processed. NoErrAlreadyUpToDate is never returned, the references are
+// reported as RefUpToDate instead. The result is returned along with the
+// error, if any, including the references rejected by the remote.
+func (r *Remote) PushWithResult(ctx context.Context, o *PushOptions) (*PushResult, error) {
+	result := &PushResult{}
+
+	opts := *o
+	opts.Updated = func(u *RefResult) {
+		result.Refs = append(result.Refs, u)
+		if o.Updated != nil {
+			o.Updated(u)
+		}
+	}
+
+	err := r.PushContext(ctx, &opts)
+	if err == NoErrAlreadyUpToDate {
+		err = nil
+	}
+
+	return result, err
+}
+
+// FetchWithResult fetches as FetchContext does, from the remote named as
+// FetchOptions.RemoteName, returning the references processed, see
+// Remote.FetchWithResult.
+func (r *Repository) FetchWithResult(ctx context.Context, o *FetchOptions) (*FetchResult, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	remote, err := r.Remote(o.RemoteName)
+	if err != nil {
+		return nil, err
+	}
+
+	return remote.FetchWithResult(ctx, o)
+}
+
+// PushWithResult pushes as PushContext does, to the remote named as
+// PushOptions.RemoteName, returning the references processed, see
+// Remote.PushWithResult.
+func (r *Repository) PushWithResult(ctx context.Context, o *PushOptions) (*PushResult, error) {
+	remote, err := r.pushRemote(o)
+	if err != nil {
+		return nil, err
+	}
+
+	return remote.PushWithResult(ctx, o)
+}
@@ -1,16 +1,27 @@
 package git
 
 import (
+	"archive/tar"
 	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/archive"
+	"gopkg.in/src-d/go-git.v4/plumbing/fsck"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
 	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp"
+	"gopkg.in/src-d/go-git.v4/plumbing/protocol/packp/capability"
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 	"gopkg.in/src-d/go-git.v4/storage"
 	"gopkg.in/src-d/go-git.v4/storage/filesystem"
 	"gopkg.in/src-d/go-git.v4/storage/memory"
@@ -27,26 +38,26 @@ type RemoteSuite struct {
 var _ = Suite(&RemoteSuite{})
 
 func (s *RemoteSuite) TestFetchInvalidEndpoint(c *C) {
-	r := newRemote(nil, &config.RemoteConfig{Name: "foo", URLs: []string{"http://\\"}})
+	r := newRemote(memory.NewStorage(), &config.RemoteConfig{Name: "foo", URLs: []string{"http://\\"}})
 	err := r.Fetch(&FetchOptions{RemoteName: "foo"})
 	c.Assert(err, ErrorMatches, ".*invalid character.*")
 }
 
 func (s *RemoteSuite) TestFetchNonExistentEndpoint(c *C) {
-	r := newRemote(nil, &config.RemoteConfig{Name: "foo", URLs: []string{"ssh://non-existent/foo.git"}})
+	r := newRemote(memory.NewStorage(), &config.RemoteConfig{Name: "foo", URLs: []string{"ssh://non-existent/foo.git"}})
 	err := r.Fetch(&FetchOptions{})
 	c.Assert(err, NotNil)
 }
 
 func (s *RemoteSuite) TestFetchInvalidSchemaEndpoint(c *C) {
-	r := newRemote(nil, &config.RemoteConfig{Name: "foo", URLs: []string{"qux://foo"}})
+	r := newRemote(memory.NewStorage(), &config.RemoteConfig{Name: "foo", URLs: []string{"qux://foo"}})
 	err := r.Fetch(&FetchOptions{})
 	c.Assert(err, ErrorMatches, ".*unsupported scheme.*")
 }
 
 func (s *RemoteSuite) TestFetchInvalidFetchOptions(c *C) {
-	r := newRemote(nil, &config.RemoteConfig{Name: "foo", URLs: []string{"qux://foo"}})
-	invalid := config.RefSpec("^*$ñ")
+	r := newRemote(memory.NewStorage(), &config.RemoteConfig{Name: "foo", URLs: []string{"qux://foo"}})
+	invalid := config.RefSpec("*$ñ")
 	err := r.Fetch(&FetchOptions{RefSpecs: []config.RefSpec{invalid}})
 	c.Assert(err, Equals, config.ErrRefSpecMalformedSeparator)
 }
@@ -97,7 +108,118 @@ func (s *RemoteSuite) TestFetch(c *C) {
 		},
 	}, []*plumbing.Reference{
 		plumbing.NewReferenceFromStrings("refs/remotes/origin/master", "f7b877701fbf855b44c0a9e86f3fdce2c298b07f"),
+		plumbing.NewReferenceFromStrings("refs/tags/annotated-tag", "b742a2a9fa0afcfa9a6fad080980fbc26b007c69"),
+		plumbing.NewReferenceFromStrings("refs/tags/tree-tag", "152175bf7e5580299fa1f0ba41ef6474cc043b70"),
+		plumbing.NewReferenceFromStrings("refs/tags/commit-tag", "ad7897c0fb8e7d9a9ba41fa66072cf06095a6cfc"),
+		plumbing.NewReferenceFromStrings("refs/tags/blob-tag", "fe6cb94756faa81e5ed9240f9191b833db5f40ae"),
+		plumbing.NewReferenceFromStrings("refs/tags/lightweight-tag", "f7b877701fbf855b44c0a9e86f3fdce2c298b07f"),
+	})
+}
+
+func (s *RemoteSuite) TestFetchRefetch(c *C) {
+	sto := memory.NewStorage()
+	r := newRemote(sto, &config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{s.GetBasicLocalRepositoryURL()},
+	})
+
+	o := &FetchOptions{
+		RefSpecs: []config.RefSpec{"+refs/heads/master:refs/remotes/origin/master"},
+	}
+	c.Assert(r.Fetch(o), IsNil)
+
+	commit, err := object.GetCommit(sto, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	c.Assert(err, IsNil)
+	tree, err := commit.Tree()
+	c.Assert(err, IsNil)
+
+	// a blob lost by a corruption isn't fetched again by a regular fetch
+	blob := tree.Entries[0].Hash
+	delete(sto.ObjectStorage.Objects, blob)
+	delete(sto.ObjectStorage.Blobs, blob)
+
+	c.Assert(r.Fetch(o), Equals, NoErrAlreadyUpToDate)
+	_, err = sto.EncodedObject(plumbing.BlobObject, blob)
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+
+	o.Refetch = true
+	c.Assert(r.Fetch(o), Equals, NoErrAlreadyUpToDate)
+	_, err = sto.EncodedObject(plumbing.BlobObject, blob)
+	c.Assert(err, IsNil)
+}
+
+func (s *RemoteSuite) TestFetchFsckObjects(c *C) {
+	r := newRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{s.GetBasicLocalRepositoryURL()},
+	})
+
+	c.Assert(r.Fetch(&FetchOptions{
+		RefSpecs:    []config.RefSpec{"+refs/heads/*:refs/remotes/origin/*"},
+		FsckObjects: true,
+	}), IsNil)
+
+	url := c.MkDir()
+	server, err := PlainInit(url, true)
+	c.Assert(err, IsNil)
+
+	tree := &plumbing.MemoryObject{}
+	tree.SetType(plumbing.TreeObject)
+	_, err = server.Storer.SetEncodedObject(tree)
+	c.Assert(err, IsNil)
+
+	// a commit without author
+	commit := &plumbing.MemoryObject{}
+	commit.SetType(plumbing.CommitObject)
+	_, err = commit.Write([]byte("tree " + tree.Hash().String() + "\n" +
+		"committer Foo <foo@foo.com> 1257894000 +0100\n\nfoo\n"))
+	c.Assert(err, IsNil)
+	_, err = server.Storer.SetEncodedObject(commit)
+	c.Assert(err, IsNil)
+	ref := plumbing.NewHashReference("refs/heads/master", commit.Hash())
+	c.Assert(server.Storer.SetReference(ref), IsNil)
+
+	sto := memory.NewStorage()
+	cfg, err := sto.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.AddOption("transfer", "", "fsckObjects", "true")
+	c.Assert(sto.SetConfig(cfg), IsNil)
+
+	r = newRemote(sto, &config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{url},
+	})
+
+	o := &FetchOptions{
+		RefSpecs: []config.RefSpec{"+refs/heads/master:refs/remotes/origin/master"},
+	}
+
+	err = r.Fetch(o)
+	p, ok := err.(*fsck.Problem)
+	c.Assert(ok, Equals, true)
+	c.Assert(p.Hash, Equals, commit.Hash())
+	c.Assert(p.ID, Equals, fsck.MissingAuthor)
+
+	_, err = sto.Reference("refs/remotes/origin/master")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	// the known bad commit is accepted
+	sto = memory.NewStorage()
+	cfg.Raw.Section("fetch").Subsection("fsck").
+		AddOption("missingAuthor", "ignore").
+		AddOption("skipList", ".git/fsck-skip")
+	c.Assert(sto.SetConfig(cfg), IsNil)
+
+	r = newRemote(sto, &config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{url},
 	})
+
+	c.Assert(r.Fetch(o), IsNil)
+
+	ref, err = sto.Reference("refs/remotes/origin/master")
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, commit.Hash())
 }
 
 func (s *RemoteSuite) TestFetchContext(c *C) {
@@ -189,6 +311,34 @@ func (s *RemoteSuite) testFetch(c *C, r *Remote, o *FetchOptions, expected []*pl
 	}
 }
 
+func (s *RemoteSuite) TestFetchHashRefSpec(c *C) {
+	r := newRemote(memory.NewStorage(), &config.RemoteConfig{
+		URLs: []string{s.GetBasicLocalRepositoryURL()},
+	})
+
+	s.testFetch(c, r, &FetchOptions{
+		RefSpecs: []config.RefSpec{
+			config.RefSpec("e8d3ffab552895c19b9fcf7aa264d277cde33881:refs/heads/foo"),
+		},
+		Tags: NoTags,
+	}, []*plumbing.Reference{
+		plumbing.NewReferenceFromStrings("refs/heads/foo", "e8d3ffab552895c19b9fcf7aa264d277cde33881"),
+	})
+}
+
+func (s *RemoteSuite) TestFetchHashRefSpecUnadvertised(c *C) {
+	r := newRemote(memory.NewStorage(), &config.RemoteConfig{
+		URLs: []string{s.GetBasicLocalRepositoryURL()},
+	})
+
+	err := r.Fetch(&FetchOptions{
+		RefSpecs: []config.RefSpec{
+			config.RefSpec("918c48b83bd081e863dbe1b80f8998f058cd8294:refs/heads/foo"),
+		},
+	})
+	c.Assert(err, Equals, ErrUnadvertisedObject)
+}
+
 func (s *RemoteSuite) TestFetchWithProgress(c *C) {
 	url := s.GetBasicLocalRepositoryURL()
 	sto := memory.NewStorage()
@@ -320,6 +470,7 @@ func (s *RemoteSuite) testFetchFastForward(c *C, sto storage.Storer) {
 		},
 	}, []*plumbing.Reference{
 		plumbing.NewReferenceFromStrings("refs/heads/master", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+		plumbing.NewReferenceFromStrings("refs/tags/v1.0.0", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
 	})
 
 	// First make sure that we error correctly when a force is required.
@@ -348,6 +499,7 @@ func (s *RemoteSuite) testFetchFastForward(c *C, sto storage.Storer) {
 		},
 	}, []*plumbing.Reference{
 		plumbing.NewReferenceFromStrings("refs/heads/master", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+		plumbing.NewReferenceFromStrings("refs/tags/v1.0.0", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
 	})
 }
 
@@ -469,6 +621,92 @@ func (s *RemoteSuite) TestPushTags(c *C) {
 	})
 }
 
+func (s *RemoteSuite) TestPushAllTags(c *C) {
+	url := c.MkDir()
+	server, err := PlainInit(url, true)
+	c.Assert(err, IsNil)
+
+	fs := fixtures.ByURL("https://github.com/git-fixtures/tags.git").One().DotGit()
+	sto, err := filesystem.NewStorage(fs)
+	c.Assert(err, IsNil)
+
+	r := newRemote(sto, &config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{url},
+	})
+
+	err = r.Push(&PushOptions{
+		RefSpecs: []config.RefSpec{"refs/heads/master:refs/heads/master"},
+		Tags:     true,
+	})
+	c.Assert(err, IsNil)
+
+	AssertReferences(c, server, map[string]string{
+		"refs/heads/master":         "f7b877701fbf855b44c0a9e86f3fdce2c298b07f",
+		"refs/tags/lightweight-tag": "f7b877701fbf855b44c0a9e86f3fdce2c298b07f",
+		"refs/tags/annotated-tag":   "b742a2a9fa0afcfa9a6fad080980fbc26b007c69",
+		"refs/tags/commit-tag":      "ad7897c0fb8e7d9a9ba41fa66072cf06095a6cfc",
+		"refs/tags/blob-tag":        "fe6cb94756faa81e5ed9240f9191b833db5f40ae",
+		"refs/tags/tree-tag":        "152175bf7e5580299fa1f0ba41ef6474cc043b70",
+	})
+}
+
+func (s *RemoteSuite) TestPushFollowTags(c *C) {
+	url := c.MkDir()
+	server, err := PlainInit(url, true)
+	c.Assert(err, IsNil)
+
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	commits := make(map[plumbing.ReferenceName]plumbing.Hash)
+	for _, branch := range []plumbing.ReferenceName{"refs/heads/master", "refs/heads/other"} {
+		h, err := r.CommitChanges("foo\n", &CommitChangesOptions{
+			Branch:  branch,
+			Author:  defaultSignature(),
+			Changes: []FileChange{{Path: branch.Short(), Content: strings.NewReader("foo")}},
+		})
+		c.Assert(err, IsNil)
+		commits[branch] = h
+	}
+
+	annotated, err := r.CreateTag("annotated", commits["refs/heads/master"], &CreateTagOptions{
+		Tagger:  defaultSignature(),
+		Message: "foo",
+	})
+	c.Assert(err, IsNil)
+
+	_, err = r.CreateTag("lightweight", commits["refs/heads/master"], nil)
+	c.Assert(err, IsNil)
+
+	_, err = r.CreateTag("other", commits["refs/heads/other"], &CreateTagOptions{
+		Tagger:  defaultSignature(),
+		Message: "foo",
+	})
+	c.Assert(err, IsNil)
+
+	remote := newRemote(r.Storer, &config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{url},
+	})
+
+	err = remote.Push(&PushOptions{
+		RefSpecs:   []config.RefSpec{"refs/heads/master:refs/heads/master"},
+		FollowTags: true,
+	})
+	c.Assert(err, IsNil)
+
+	AssertReferences(c, server, map[string]string{
+		"refs/heads/master":   commits["refs/heads/master"].String(),
+		"refs/tags/annotated": annotated.Hash().String(),
+	})
+
+	for _, name := range []plumbing.ReferenceName{"refs/tags/lightweight", "refs/tags/other"} {
+		_, err = server.Storer.Reference(name)
+		c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+	}
+}
+
 func (s *RemoteSuite) TestPushNoErrAlreadyUpToDate(c *C) {
 	fs := fixtures.Basic().One().DotGit()
 	sto, err := filesystem.NewStorage(fs)
@@ -528,10 +766,22 @@ func (s *RemoteSuite) TestPushRejectNonFastForward(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(oldRef, NotNil)
 
-	err = remote.Push(&PushOptions{RefSpecs: []config.RefSpec{
-		"refs/heads/master:refs/heads/branch",
+	master, err := r.Reference(plumbing.Master, true)
+	c.Assert(err, IsNil)
+
+	var updated []*RefResult
+	err = remote.Push(&PushOptions{
+		RefSpecs: []config.RefSpec{"refs/heads/master:refs/heads/branch"},
+		Updated:  func(u *RefResult) { updated = append(updated, u) },
+	})
+	c.Assert(err == ErrNonFastForwardUpdate, Equals, true)
+	c.Assert(updated, DeepEquals, []*RefResult{{
+		Name:   branch,
+		Old:    oldRef.Hash(),
+		New:    master.Hash(),
+		Status: RefRejected,
+		Reason: "non-fast-forward",
 	}})
-	c.Assert(err, ErrorMatches, "non-fast-forward update: refs/heads/branch")
 
 	newRef, err := server.Reference(branch)
 	c.Assert(err, IsNil)
@@ -638,37 +888,37 @@ func (s *RemoteSuite) TestPushNewReferenceAndDeleteInBatch(c *C) {
 }
 
 func (s *RemoteSuite) TestPushInvalidEndpoint(c *C) {
-	r := newRemote(nil, &config.RemoteConfig{Name: "foo", URLs: []string{"http://\\"}})
+	r := newRemote(memory.NewStorage(), &config.RemoteConfig{Name: "foo", URLs: []string{"http://\\"}})
 	err := r.Push(&PushOptions{RemoteName: "foo"})
 	c.Assert(err, ErrorMatches, ".*invalid character.*")
 }
 
 func (s *RemoteSuite) TestPushNonExistentEndpoint(c *C) {
-	r := newRemote(nil, &config.RemoteConfig{Name: "foo", URLs: []string{"ssh://non-existent/foo.git"}})
+	r := newRemote(memory.NewStorage(), &config.RemoteConfig{Name: "foo", URLs: []string{"ssh://non-existent/foo.git"}})
 	err := r.Push(&PushOptions{})
 	c.Assert(err, NotNil)
 }
 
 func (s *RemoteSuite) TestPushInvalidSchemaEndpoint(c *C) {
-	r := newRemote(nil, &config.RemoteConfig{Name: "origin", URLs: []string{"qux://foo"}})
+	r := newRemote(memory.NewStorage(), &config.RemoteConfig{Name: "origin", URLs: []string{"qux://foo"}})
 	err := r.Push(&PushOptions{})
 	c.Assert(err, ErrorMatches, ".*unsupported scheme.*")
 }
 
 func (s *RemoteSuite) TestPushInvalidFetchOptions(c *C) {
-	r := newRemote(nil, &config.RemoteConfig{Name: "foo", URLs: []string{"qux://foo"}})
-	invalid := config.RefSpec("^*$ñ")
+	r := newRemote(memory.NewStorage(), &config.RemoteConfig{Name: "foo", URLs: []string{"qux://foo"}})
+	invalid := config.RefSpec("*$ñ")
 	err := r.Push(&PushOptions{RefSpecs: []config.RefSpec{invalid}})
 	c.Assert(err, Equals, config.ErrRefSpecMalformedSeparator)
 }
 
 func (s *RemoteSuite) TestPushInvalidRefSpec(c *C) {
-	r := newRemote(nil, &config.RemoteConfig{
+	r := newRemote(memory.NewStorage(), &config.RemoteConfig{
 		Name: DefaultRemoteName,
 		URLs: []string{"some-url"},
 	})
 
-	rs := config.RefSpec("^*$**")
+	rs := config.RefSpec("*$**")
 	err := r.Push(&PushOptions{
 		RefSpecs: []config.RefSpec{rs},
 	})
@@ -676,7 +926,7 @@ func (s *RemoteSuite) TestPushInvalidRefSpec(c *C) {
 }
 
 func (s *RemoteSuite) TestPushWrongRemoteName(c *C) {
-	r := newRemote(nil, &config.RemoteConfig{
+	r := newRemote(memory.NewStorage(), &config.RemoteConfig{
 		Name: DefaultRemoteName,
 		URLs: []string{"some-url"},
 	})
@@ -743,53 +993,952 @@ func (s *RemoteSuite) TestList(c *C) {
 	}
 }
 
-func (s *RemoteSuite) TestUpdateShallows(c *C) {
-	hashes := []plumbing.Hash{
-		plumbing.NewHash("0000000000000000000000000000000000000001"),
-		plumbing.NewHash("0000000000000000000000000000000000000002"),
-		plumbing.NewHash("0000000000000000000000000000000000000003"),
-		plumbing.NewHash("0000000000000000000000000000000000000004"),
-		plumbing.NewHash("0000000000000000000000000000000000000005"),
-		plumbing.NewHash("0000000000000000000000000000000000000006"),
-	}
+func (s *RemoteSuite) TestListOptions(c *C) {
+	url := c.MkDir()
+	r, err := PlainInit(url, true)
+	c.Assert(err, IsNil)
 
-	tests := []struct {
-		hashes []plumbing.Hash
-		result []plumbing.Hash
-	}{
-		// add to empty shallows
-		{hashes[0:2], hashes[0:2]},
-		// add new hashes
-		{hashes[2:4], hashes[0:4]},
-		// add some hashes already in shallow list
-		{hashes[2:6], hashes[0:6]},
-		// add all hashes
-		{hashes[0:6], hashes[0:6]},
-		// add empty list
-		{nil, hashes[0:6]},
+	commit := func(branch plumbing.ReferenceName) plumbing.Hash {
+		h, err := r.CommitChanges("foo\n", &CommitChangesOptions{
+			Branch:  branch,
+			Author:  defaultSignature(),
+			Changes: []FileChange{{Path: branch.Short(), Content: strings.NewReader("foo")}},
+		})
+		c.Assert(err, IsNil)
+		return h
 	}
 
+	master := commit("refs/heads/master")
+	feature := commit("refs/heads/feature/v1.0")
+
+	_, err = r.CreateTag("v1.0", master, nil)
+	c.Assert(err, IsNil)
+	tag, err := r.CreateTag("v1.0.1", feature, &CreateTagOptions{
+		Tagger:  defaultSignature(),
+		Message: "v1.0.1",
+	})
+	c.Assert(err, IsNil)
+
 	remote := newRemote(memory.NewStorage(), &config.RemoteConfig{
 		Name: DefaultRemoteName,
+		URLs: []string{url},
 	})
 
-	shallows, err := remote.s.Shallow()
-	c.Assert(err, IsNil)
-	c.Assert(len(shallows), Equals, 0)
+	list := func(o *ListOptions) []string {
+		refs, err := remote.List(o)
+		c.Assert(err, IsNil)
 
-	resp := new(packp.UploadPackResponse)
-	o := &FetchOptions{
-		Depth: 1,
+		var names []string
+		for _, ref := range refs {
+			names = append(names, ref.Name().String())
+		}
+
+		return names
 	}
 
-	for _, t := range tests {
-		resp.Shallows = t.hashes
-		err = remote.updateShallow(o, resp)
+	c.Assert(list(&ListOptions{}), DeepEquals, []string{
+		"HEAD",
+		"refs/heads/feature/v1.0",
+		"refs/heads/master",
+		"refs/tags/v1.0",
+		"refs/tags/v1.0.1",
+	})
+
+	c.Assert(list(&ListOptions{Patterns: []string{"v1.0"}}), DeepEquals, []string{
+		"refs/heads/feature/v1.0",
+		"refs/tags/v1.0",
+	})
+
+	c.Assert(list(&ListOptions{Patterns: []string{"tags/v1*"}}), DeepEquals, []string{
+		"refs/tags/v1.0",
+		"refs/tags/v1.0.1",
+	})
+
+	c.Assert(list(&ListOptions{Heads: true}), DeepEquals, []string{
+		"refs/heads/feature/v1.0",
+		"refs/heads/master",
+	})
+
+	c.Assert(list(&ListOptions{Heads: true, Tags: true, Patterns: []string{"v1.0"}}), DeepEquals, []string{
+		"refs/heads/feature/v1.0",
+		"refs/tags/v1.0",
+	})
+
+	refs, err := remote.List(&ListOptions{Tags: true, Peeled: true})
+	c.Assert(err, IsNil)
+	c.Assert(refs, DeepEquals, []*plumbing.Reference{
+		plumbing.NewHashReference("refs/tags/v1.0", master),
+		plumbing.NewHashReference("refs/tags/v1.0.1", tag.Hash()),
+		plumbing.NewHashReference("refs/tags/v1.0.1^{}", feature),
+	})
+
+	refs, err = remote.List(&ListOptions{Patterns: []string{"HEAD"}})
+	c.Assert(err, IsNil)
+	c.Assert(refs, DeepEquals, []*plumbing.Reference{
+		plumbing.NewSymbolicReference(plumbing.HEAD, "refs/heads/master"),
+	})
+
+	_, err = remote.List(&ListOptions{Patterns: []string{"["}})
+	c.Assert(err, NotNil)
+}
+
+func (s *RemoteSuite) TestFetchNegativeRefSpec(c *C) {
+	url := c.MkDir()
+	r, err := PlainInit(url, true)
+	c.Assert(err, IsNil)
+
+	for _, branch := range []plumbing.ReferenceName{"refs/heads/master", "refs/heads/wip/foo"} {
+		_, err := r.CommitChanges("foo\n", &CommitChangesOptions{
+			Branch:  branch,
+			Author:  defaultSignature(),
+			Changes: []FileChange{{Path: branch.Short(), Content: strings.NewReader("foo")}},
+		})
 		c.Assert(err, IsNil)
+	}
 
-		shallow, err := remote.s.Shallow()
+	sto := memory.NewStorage()
+	remote := newRemote(sto, &config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{url},
+	})
+
+	err = remote.Fetch(&FetchOptions{RefSpecs: []config.RefSpec{
+		"+refs/heads/*:refs/remotes/origin/*",
+		"^refs/heads/wip/*",
+	}})
+	c.Assert(err, IsNil)
+
+	_, err = sto.Reference("refs/remotes/origin/master")
+	c.Assert(err, IsNil)
+	_, err = sto.Reference("refs/remotes/origin/wip/foo")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *RemoteSuite) TestPushNegativeRefSpec(c *C) {
+	url := c.MkDir()
+	_, err := PlainInit(url, true)
+	c.Assert(err, IsNil)
+
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	for _, branch := range []plumbing.ReferenceName{"refs/heads/master", "refs/heads/wip/foo"} {
+		_, err := r.CommitChanges("foo\n", &CommitChangesOptions{
+			Branch:  branch,
+			Author:  defaultSignature(),
+			Changes: []FileChange{{Path: branch.Short(), Content: strings.NewReader("foo")}},
+		})
 		c.Assert(err, IsNil)
-		c.Assert(len(shallow), Equals, len(t.result))
-		c.Assert(shallow, DeepEquals, t.result)
 	}
+
+	remote := newRemote(r.Storer, &config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{url},
+	})
+
+	err = remote.Push(&PushOptions{RefSpecs: []config.RefSpec{
+		"refs/heads/*:refs/heads/*",
+		"^refs/heads/wip/*",
+	}})
+	c.Assert(err, IsNil)
+
+	dst, err := PlainOpen(url)
+	c.Assert(err, IsNil)
+
+	_, err = dst.Storer.Reference("refs/heads/master")
+	c.Assert(err, IsNil)
+	_, err = dst.Storer.Reference("refs/heads/wip/foo")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *RemoteSuite) TestPushMirror(c *C) {
+	url := c.MkDir()
+	server, err := PlainInit(url, true)
+	c.Assert(err, IsNil)
+
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	commits := make(map[plumbing.ReferenceName]plumbing.Hash)
+	for _, branch := range []plumbing.ReferenceName{"refs/heads/master", "refs/heads/foo"} {
+		h, err := r.CommitChanges("foo\n", &CommitChangesOptions{
+			Branch:  branch,
+			Author:  defaultSignature(),
+			Changes: []FileChange{{Path: branch.Short(), Content: strings.NewReader("foo")}},
+		})
+		c.Assert(err, IsNil)
+		commits[branch] = h
+	}
+
+	remote := newRemote(r.Storer, &config.RemoteConfig{
+		Name:   DefaultRemoteName,
+		URLs:   []string{url},
+		Mirror: true,
+	})
+
+	o := &PushOptions{}
+	err = remote.Push(o)
+	c.Assert(err, IsNil)
+	c.Assert(o.Mirror, Equals, false)
+	c.Assert(o.RefSpecs, HasLen, 0)
+
+	AssertReferences(c, server, map[string]string{
+		"refs/heads/master": commits["refs/heads/master"].String(),
+		"refs/heads/foo":    commits["refs/heads/foo"].String(),
+	})
+
+	err = r.Storer.RemoveReference("refs/heads/foo")
+	c.Assert(err, IsNil)
+
+	err = remote.Push(o)
+	c.Assert(err, IsNil)
+
+	_, err = server.Storer.Reference("refs/heads/foo")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	err = remote.Push(&PushOptions{
+		Mirror:   true,
+		RefSpecs: []config.RefSpec{"refs/heads/*:refs/heads/*"},
+	})
+	c.Assert(err, Equals, ErrMirrorRefSpecs)
+}
+
+func (s *RemoteSuite) TestFetchDryRun(c *C) {
+	sto := memory.NewStorage()
+	r := newRemote(sto, &config.RemoteConfig{
+		URLs: []string{s.GetBasicLocalRepositoryURL()},
+	})
+
+	updates := make(map[plumbing.ReferenceName]*RefResult)
+	err := r.Fetch(&FetchOptions{
+		RefSpecs: []config.RefSpec{
+			config.RefSpec("+refs/heads/*:refs/remotes/origin/*"),
+		},
+		DryRun: true,
+		Updated: func(u *RefResult) {
+			updates[u.Name] = u
+		},
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(updates, DeepEquals, map[plumbing.ReferenceName]*RefResult{
+		"refs/remotes/origin/master": {
+			Name:   "refs/remotes/origin/master",
+			New:    plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+			Status: RefCreated,
+		},
+		"refs/remotes/origin/branch": {
+			Name:   "refs/remotes/origin/branch",
+			New:    plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881"),
+			Status: RefCreated,
+		},
+	})
+
+	c.Assert(sto.Objects, HasLen, 0)
+	c.Assert(sto.ReferenceStorage, HasLen, 0)
+}
+
+func (s *RemoteSuite) TestPushDryRun(c *C) {
+	url := c.MkDir()
+	server, err := PlainInit(url, true)
+	c.Assert(err, IsNil)
+
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	h, err := r.CommitChanges("foo\n", &CommitChangesOptions{
+		Author:  defaultSignature(),
+		Changes: []FileChange{{Path: "foo", Content: strings.NewReader("foo")}},
+	})
+	c.Assert(err, IsNil)
+
+	remote := newRemote(r.Storer, &config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{url},
+	})
+
+	var updates []*RefResult
+	var objects int
+	var size int64
+	err = remote.Push(&PushOptions{
+		RefSpecs: []config.RefSpec{"refs/heads/master:refs/heads/master"},
+		DryRun:   true,
+		Updated: func(u *RefResult) {
+			updates = append(updates, u)
+		},
+		PackSize: func(n int, s int64) {
+			objects, size = n, s
+		},
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(updates, DeepEquals, []*RefResult{{Name: "refs/heads/master", New: h, Status: RefCreated}})
+	c.Assert(objects, Equals, 3)
+	c.Assert(size > 0, Equals, true)
+
+	_, err = server.Storer.Reference("refs/heads/master")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *RemoteSuite) TestFetchWithResult(c *C) {
+	r := newRemote(memory.NewStorage(), &config.RemoteConfig{
+		URLs: []string{s.GetBasicLocalRepositoryURL()},
+	})
+
+	o := &FetchOptions{
+		RefSpecs: []config.RefSpec{
+			config.RefSpec("+refs/heads/*:refs/remotes/origin/*"),
+		},
+	}
+
+	result, err := r.FetchWithResult(context.Background(), o)
+	c.Assert(err, IsNil)
+
+	statuses := make(map[plumbing.ReferenceName]RefStatus)
+	for _, u := range result.Refs {
+		statuses[u.Name] = u.Status
+	}
+
+	c.Assert(statuses, DeepEquals, map[plumbing.ReferenceName]RefStatus{
+		"refs/remotes/origin/master": RefCreated,
+		"refs/remotes/origin/branch": RefCreated,
+		"refs/tags/v1.0.0":           RefCreated,
+	})
+
+	result, err = r.FetchWithResult(context.Background(), o)
+	c.Assert(err, IsNil)
+	c.Assert(result.Refs, HasLen, 3)
+	for _, u := range result.Refs {
+		c.Assert(u.Status, Equals, RefUpToDate)
+		c.Assert(u.Old, Equals, u.New)
+	}
+}
+
+func (s *RemoteSuite) TestPushWithResult(c *C) {
+	url := c.MkDir()
+	_, err := PlainInit(url, true)
+	c.Assert(err, IsNil)
+
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	commit := func(content string) plumbing.Hash {
+		h, err := r.CommitChanges("foo\n", &CommitChangesOptions{
+			Author:  defaultSignature(),
+			Changes: []FileChange{{Path: "foo", Content: strings.NewReader(content)}},
+		})
+		c.Assert(err, IsNil)
+		return h
+	}
+
+	remote := newRemote(r.Storer, &config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{url},
+	})
+
+	o := &PushOptions{RefSpecs: []config.RefSpec{"refs/heads/master:refs/heads/master"}}
+
+	first := commit("foo")
+	result, err := remote.PushWithResult(context.Background(), o)
+	c.Assert(err, IsNil)
+	c.Assert(result.Refs, DeepEquals, []*RefResult{
+		{Name: "refs/heads/master", New: first, Status: RefCreated},
+	})
+
+	second := commit("bar")
+	result, err = remote.PushWithResult(context.Background(), o)
+	c.Assert(err, IsNil)
+	c.Assert(result.Refs, DeepEquals, []*RefResult{
+		{Name: "refs/heads/master", Old: first, New: second, Status: RefFastForward},
+	})
+
+	result, err = remote.PushWithResult(context.Background(), o)
+	c.Assert(err, IsNil)
+	c.Assert(result.Refs, DeepEquals, []*RefResult{
+		{Name: "refs/heads/master", Old: second, New: second, Status: RefUpToDate},
+	})
+}
+
+func (s *RemoteSuite) TestPushPushURLs(c *C) {
+	var urls []string
+	var servers []*Repository
+	for i := 0; i < 2; i++ {
+		url := c.MkDir()
+		server, err := PlainInit(url, true)
+		c.Assert(err, IsNil)
+
+		urls = append(urls, url)
+		servers = append(servers, server)
+	}
+
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	h, err := r.CommitChanges("foo\n", &CommitChangesOptions{
+		Author:  defaultSignature(),
+		Changes: []FileChange{{Path: "foo", Content: strings.NewReader("foo")}},
+	})
+	c.Assert(err, IsNil)
+
+	remote := newRemote(r.Storer, &config.RemoteConfig{
+		Name:     DefaultRemoteName,
+		URLs:     []string{"file:///non-existent"},
+		PushURLs: urls,
+	})
+
+	err = remote.Push(&PushOptions{})
+	c.Assert(err, IsNil)
+
+	for _, server := range servers {
+		AssertReferences(c, server, map[string]string{
+			"refs/heads/master": h.String(),
+		})
+	}
+
+	err = remote.Push(&PushOptions{})
+	c.Assert(err, Equals, NoErrAlreadyUpToDate)
+}
+
+func (s *RemoteSuite) TestFetchInsteadOf(c *C) {
+	sto := memory.NewStorage()
+	cfg, err := sto.Config()
+	c.Assert(err, IsNil)
+
+	url := s.GetBasicLocalRepositoryURL()
+	cfg.URLs[url] = &config.URL{
+		Name:      url,
+		InsteadOf: []string{"https://example.com/"},
+	}
+	c.Assert(sto.SetConfig(cfg), IsNil)
+
+	r := newRemote(sto, &config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{"https://example.com/"},
+	})
+
+	err = r.Fetch(&FetchOptions{
+		RefSpecs: []config.RefSpec{"+refs/heads/master:refs/remotes/origin/master"},
+	})
+	c.Assert(err, IsNil)
+
+	ref, err := sto.Reference("refs/remotes/origin/master")
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash().String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+}
+
+func (s *RemoteSuite) TestPushPushInsteadOf(c *C) {
+	url := c.MkDir()
+	server, err := PlainInit(url, true)
+	c.Assert(err, IsNil)
+
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	h, err := r.CommitChanges("foo\n", &CommitChangesOptions{
+		Author:  defaultSignature(),
+		Changes: []FileChange{{Path: "foo", Content: strings.NewReader("foo")}},
+	})
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Storer.Config()
+	c.Assert(err, IsNil)
+
+	cfg.URLs[url] = &config.URL{
+		Name:          url,
+		PushInsteadOf: []string{"https://example.com/"},
+	}
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	remote := newRemote(r.Storer, &config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{"https://example.com/"},
+	})
+
+	err = remote.Push(&PushOptions{})
+	c.Assert(err, IsNil)
+
+	AssertReferences(c, server, map[string]string{
+		"refs/heads/master": h.String(),
+	})
+}
+
+func (s *RemoteSuite) TestHTTPOptions(c *C) {
+	h, err := httpOptions(&config.HTTP{
+		ExtraHeaders:  []string{"X-Foo: foo"},
+		SSLVerify:     "false",
+		UserAgent:     "go-git/config",
+		LowSpeedLimit: 1000,
+		LowSpeedTime:  30,
+	}, &transport.HTTPOptions{
+		ExtraHeaders: http.Header{"X-Bar": []string{"bar"}},
+		UserAgent:    "go-git/options",
+	})
+	c.Assert(err, IsNil)
+	c.Assert(h.ExtraHeaders, DeepEquals, http.Header{
+		"X-Foo": []string{"foo"},
+		"X-Bar": []string{"bar"},
+	})
+	c.Assert(h.InsecureSkipTLS, Equals, true)
+	c.Assert(h.UserAgent, Equals, "go-git/options")
+	c.Assert(h.LowSpeedLimit, Equals, 1000)
+	c.Assert(h.LowSpeedTime, Equals, 30*time.Second)
+
+	_, err = httpOptions(&config.HTTP{ExtraHeaders: []string{"X-Foo"}}, nil)
+	c.Assert(err, NotNil)
+}
+
+func (s *RemoteSuite) TestHTTPOptionsTLS(c *C) {
+	h, err := httpOptions(&config.HTTP{SSLVersion: "TLSv1.2"}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(h.MinTLSVersion, Equals, uint16(tls.VersionTLS12))
+	c.Assert(h.RootCAs, IsNil)
+
+	pool := x509.NewCertPool()
+	h, err = httpOptions(&config.HTTP{SSLVersion: "tlsv1.2"}, &transport.HTTPOptions{
+		RootCAs:       pool,
+		MinTLSVersion: tls.VersionTLS13,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(h.MinTLSVersion, Equals, uint16(tls.VersionTLS13))
+	c.Assert(h.RootCAs, Equals, pool)
+
+	_, err = httpOptions(&config.HTTP{SSLVersion: "sslv3"}, nil)
+	c.Assert(err, NotNil)
+
+	_, err = httpOptions(&config.HTTP{SSLCAInfo: "/non-existent"}, nil)
+	c.Assert(err, NotNil)
+}
+
+func (s *RemoteSuite) TestRemotePrune(c *C) {
+	sto := memory.NewStorage()
+	r := newRemote(sto, &config.RemoteConfig{
+		Name:  DefaultRemoteName,
+		URLs:  []string{s.GetBasicLocalRepositoryURL()},
+		Fetch: []config.RefSpec{"+refs/heads/*:refs/remotes/origin/*"},
+	})
+
+	stale := plumbing.NewReferenceFromStrings("refs/remotes/origin/stale", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	kept := plumbing.NewReferenceFromStrings("refs/remotes/origin/master", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	tag := plumbing.NewReferenceFromStrings("refs/tags/stale", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	for _, ref := range []*plumbing.Reference{stale, kept, tag} {
+		c.Assert(sto.SetReference(ref), IsNil)
+	}
+
+	var pruned []*plumbing.Reference
+	err := r.Prune(&RemotePruneOptions{
+		Pruned: func(ref *plumbing.Reference) {
+			pruned = append(pruned, ref)
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(pruned, DeepEquals, []*plumbing.Reference{stale})
+
+	_, err = sto.Reference(stale.Name())
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+	for _, ref := range []*plumbing.Reference{kept, tag} {
+		_, err = sto.Reference(ref.Name())
+		c.Assert(err, IsNil)
+	}
+
+	err = r.Prune(&RemotePruneOptions{})
+	c.Assert(err, Equals, NoErrAlreadyUpToDate)
+}
+
+func (s *RemoteSuite) TestFetchPrune(c *C) {
+	url := c.MkDir()
+	src, err := PlainInit(url, true)
+	c.Assert(err, IsNil)
+
+	for _, branch := range []plumbing.ReferenceName{"refs/heads/master", "refs/heads/feature"} {
+		h, err := src.CommitChanges("foo\n", &CommitChangesOptions{
+			Branch:  branch,
+			Author:  defaultSignature(),
+			Changes: []FileChange{{Path: branch.Short(), Content: strings.NewReader("foo")}},
+		})
+		c.Assert(err, IsNil)
+
+		_, err = src.CreateTag(branch.Short(), h, nil)
+		c.Assert(err, IsNil)
+	}
+
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{url},
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(r.Fetch(&FetchOptions{Tags: AllTags}), IsNil)
+
+	c.Assert(src.Storer.RemoveReference("refs/heads/feature"), IsNil)
+	c.Assert(src.Storer.RemoveReference("refs/tags/feature"), IsNil)
+
+	var pruned []plumbing.ReferenceName
+	err = r.Fetch(&FetchOptions{
+		Prune:  true,
+		Pruned: func(ref *plumbing.Reference) { pruned = append(pruned, ref.Name()) },
+	})
+	c.Assert(err, IsNil)
+	c.Assert(pruned, DeepEquals, []plumbing.ReferenceName{"refs/remotes/origin/feature"})
+
+	_, err = r.Storer.Reference("refs/remotes/origin/feature")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+	_, err = r.Storer.Reference("refs/remotes/origin/master")
+	c.Assert(err, IsNil)
+	_, err = r.Storer.Reference("refs/tags/feature")
+	c.Assert(err, IsNil)
+
+	err = r.Fetch(&FetchOptions{Prune: true})
+	c.Assert(err, Equals, NoErrAlreadyUpToDate)
+
+	err = r.Fetch(&FetchOptions{PruneTags: true})
+	c.Assert(err, IsNil)
+
+	_, err = r.Storer.Reference("refs/tags/feature")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+	_, err = r.Storer.Reference("refs/tags/master")
+	c.Assert(err, IsNil)
+}
+
+func (s *RemoteSuite) TestFetchPruneConfig(c *C) {
+	url := c.MkDir()
+	src, err := PlainInit(url, true)
+	c.Assert(err, IsNil)
+
+	for _, branch := range []plumbing.ReferenceName{"refs/heads/master", "refs/heads/feature"} {
+		_, err := src.CommitChanges("foo\n", &CommitChangesOptions{
+			Branch:  branch,
+			Author:  defaultSignature(),
+			Changes: []FileChange{{Path: branch.Short(), Content: strings.NewReader("foo")}},
+		})
+		c.Assert(err, IsNil)
+	}
+
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name:  DefaultRemoteName,
+		URLs:  []string{url},
+		Prune: "false",
+	})
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Fetch.Prune = true
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	c.Assert(r.Fetch(&FetchOptions{}), IsNil)
+	c.Assert(src.Storer.RemoveReference("refs/heads/feature"), IsNil)
+
+	// remote.origin.prune takes precedence over fetch.prune
+	c.Assert(r.Fetch(&FetchOptions{}), Equals, NoErrAlreadyUpToDate)
+	_, err = r.Storer.Reference("refs/remotes/origin/feature")
+	c.Assert(err, IsNil)
+
+	cfg, err = r.Config()
+	c.Assert(err, IsNil)
+	cfg.Remotes[DefaultRemoteName].Prune = ""
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+
+	c.Assert(r.Fetch(&FetchOptions{}), IsNil)
+	_, err = r.Storer.Reference("refs/remotes/origin/feature")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *RemoteSuite) TestUpdateShallows(c *C) {
+	hashes := []plumbing.Hash{
+		plumbing.NewHash("0000000000000000000000000000000000000001"),
+		plumbing.NewHash("0000000000000000000000000000000000000002"),
+		plumbing.NewHash("0000000000000000000000000000000000000003"),
+		plumbing.NewHash("0000000000000000000000000000000000000004"),
+		plumbing.NewHash("0000000000000000000000000000000000000005"),
+		plumbing.NewHash("0000000000000000000000000000000000000006"),
+	}
+
+	tests := []struct {
+		hashes []plumbing.Hash
+		result []plumbing.Hash
+	}{
+		// add to empty shallows
+		{hashes[0:2], hashes[0:2]},
+		// add new hashes
+		{hashes[2:4], hashes[0:4]},
+		// add some hashes already in shallow list
+		{hashes[2:6], hashes[0:6]},
+		// add all hashes
+		{hashes[0:6], hashes[0:6]},
+		// add empty list
+		{nil, hashes[0:6]},
+	}
+
+	remote := newRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: DefaultRemoteName,
+	})
+
+	shallows, err := remote.s.Shallow()
+	c.Assert(err, IsNil)
+	c.Assert(len(shallows), Equals, 0)
+
+	resp := new(packp.UploadPackResponse)
+	o := &FetchOptions{
+		Depth: 1,
+	}
+
+	for _, t := range tests {
+		resp.Shallows = t.hashes
+		err = remote.updateShallow(o, resp)
+		c.Assert(err, IsNil)
+
+		shallow, err := remote.s.Shallow()
+		c.Assert(err, IsNil)
+		c.Assert(len(shallow), Equals, len(t.result))
+		c.Assert(shallow, DeepEquals, t.result)
+	}
+}
+
+func (s *RemoteSuite) TestArchive(c *C) {
+	r := newRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{s.GetBasicLocalRepositoryURL()},
+	})
+
+	buf := bytes.NewBuffer(nil)
+	err := r.Archive(buf, &ArchiveOptions{
+		Format: archive.Tar,
+		Prefix: "basic/",
+		Paths:  []string{"json"},
+	})
+	c.Assert(err, IsNil)
+
+	var names []string
+	tr := tar.NewReader(buf)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		c.Assert(err, IsNil)
+		names = append(names, h.Name)
+	}
+
+	c.Assert(names, DeepEquals, []string{
+		"pax_global_header",
+		"basic/",
+		"basic/json/",
+		"basic/json/long.json",
+		"basic/json/short.json",
+	})
+}
+
+func (s *RemoteSuite) TestArchiveUnknownRevision(c *C) {
+	r := newRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{s.GetBasicLocalRepositoryURL()},
+	})
+
+	err := r.Archive(bytes.NewBuffer(nil), &ArchiveOptions{Revision: "foo"})
+	c.Assert(err, NotNil)
+}
+
+func (s *RemoteSuite) TestArchiveNotSupported(c *C) {
+	r := newRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{"https://github.com/git-fixtures/basic.git"},
+	})
+
+	err := r.Archive(bytes.NewBuffer(nil), &ArchiveOptions{})
+	c.Assert(err, Equals, ErrUploadArchiveNotSupported)
+}
+
+func (s *RemoteSuite) TestArchiveOptionsValidate(c *C) {
+	o := &ArchiveOptions{}
+	c.Assert(o.Validate(), IsNil)
+	c.Assert(o.Revision, Equals, "HEAD")
+
+	o = &ArchiveOptions{Format: "rar"}
+	c.Assert(o.Validate(), Equals, archive.ErrUnsupportedFormat)
+}
+
+func (s *RemoteSuite) TestListRefPrefixes(c *C) {
+	r := newRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{s.GetBasicLocalRepositoryURL()},
+	})
+
+	refs, err := r.List(&ListOptions{RefPrefixes: []string{"HEAD", "refs/heads/mas"}})
+	c.Assert(err, IsNil)
+	c.Assert(refs, DeepEquals, []*plumbing.Reference{
+		plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.Master),
+		plumbing.NewReferenceFromStrings("refs/heads/master", "6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+	})
+
+	refs, err = r.List(&ListOptions{Tags: true, RefPrefixes: []string{"refs/heads/"}})
+	c.Assert(err, IsNil)
+	c.Assert(refs, HasLen, 0)
+}
+
+func (s *RemoteSuite) TestListPrefixes(c *C) {
+	c.Assert(listPrefixes(&ListOptions{Patterns: []string{"master"}}), HasLen, 0)
+	c.Assert(listPrefixes(&ListOptions{Heads: true, Tags: true}), DeepEquals,
+		[]string{"refs/heads/", "refs/tags/"})
+	c.Assert(listPrefixes(&ListOptions{Heads: true, RefPrefixes: []string{"HEAD"}}), DeepEquals,
+		[]string{"HEAD"})
+}
+
+func (s *RemoteSuite) TestFetchDisabledCapabilities(c *C) {
+	r := newRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{s.GetBasicLocalRepositoryURL()},
+	})
+
+	ar := packp.NewAdvRefs()
+	for _, name := range []capability.Capability{
+		capability.MultiACKDetailed, capability.Sideband64k, capability.Sideband,
+		capability.OFSDelta, capability.ThinPack, capability.NoProgress,
+	} {
+		c.Assert(ar.Capabilities.Add(name), IsNil)
+	}
+
+	c.Assert(ar.Capabilities.Add(capability.Agent, "git/2.39.5"), IsNil)
+
+	o := &FetchOptions{DisabledCapabilities: []capability.Capability{
+		capability.Sideband64k, capability.ThinPack, capability.Agent,
+	}}
+	c.Assert(o.Validate(), IsNil)
+
+	req, err := r.newUploadPackRequest(o, ar)
+	c.Assert(err, IsNil)
+	c.Assert(req.Capabilities.Supports(capability.Sideband), Equals, true)
+	c.Assert(req.Capabilities.Supports(capability.OFSDelta), Equals, true)
+	c.Assert(req.Capabilities.Supports(capability.NoProgress), Equals, true)
+	c.Assert(req.Capabilities.Supports(capability.Sideband64k), Equals, false)
+	c.Assert(req.Capabilities.Supports(capability.ThinPack), Equals, false)
+	c.Assert(req.Capabilities.Supports(capability.Agent), Equals, false)
+	c.Assert(ar.Capabilities.Supports(capability.Sideband64k), Equals, true)
+}
+
+func (s *RemoteSuite) TestAgent(c *C) {
+	sto := memory.NewStorage()
+	cfg, err := sto.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.SetOption("transfer", "", "agent", "foo/1.0 (linux)")
+	c.Assert(sto.SetConfig(cfg), IsNil)
+
+	r := newRemote(sto, &config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{"https://github.com/git-fixtures/basic.git"},
+	})
+
+	ar := packp.NewAdvRefs()
+	c.Assert(ar.Capabilities.Add(capability.Agent, "git/2.39.5"), IsNil)
+	c.Assert(ar.Capabilities.Add(capability.ReportStatus), IsNil)
+
+	req, err := r.newUploadPackRequest(&FetchOptions{}, ar)
+	c.Assert(err, IsNil)
+	c.Assert(req.Capabilities.Get(capability.Agent), DeepEquals, []string{"foo/1.0.(linux)"})
+
+	_, ep, err := r.fetchClient(nil)
+	c.Assert(err, IsNil)
+	c.Assert(ep.HTTP.UserAgent, Equals, "foo/1.0 (linux)")
+
+	r.c.Agent = "bar/2.0"
+	ureq, err := r.newReferenceUpdateRequest(&PushOptions{}, nil, memory.NewStorage(), ar)
+	c.Assert(err, IsNil)
+	c.Assert(ureq.Capabilities.Get(capability.Agent), DeepEquals, []string{"bar/2.0"})
+
+	_, ep, err = r.fetchClient(&transport.HTTPOptions{UserAgent: "qux/3.0"})
+	c.Assert(err, IsNil)
+	c.Assert(ep.HTTP.UserAgent, Equals, "qux/3.0")
+}
+
+func (s *RemoteSuite) TestPushOptions(c *C) {
+	r := newRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{s.GetBasicLocalRepositoryURL()},
+	})
+
+	ar := packp.NewAdvRefs()
+	c.Assert(ar.Capabilities.Add(capability.ReportStatus), IsNil)
+
+	o := &PushOptions{
+		RefSpecs: []config.RefSpec{"refs/heads/master:refs/for/master%topic=foo,r=alice@example.com"},
+		Options:  []string{"topic=foo", "wip"},
+	}
+	c.Assert(o.Validate(), IsNil)
+
+	localRefs := []*plumbing.Reference{plumbing.NewHashReference(
+		"refs/heads/master", plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+	)}
+
+	_, err := r.newReferenceUpdateRequest(o, localRefs, memory.NewStorage(), ar)
+	c.Assert(err, Equals, ErrPushOptionsNotSupported)
+
+	c.Assert(ar.Capabilities.Add(capability.PushOptions), IsNil)
+	req, err := r.newReferenceUpdateRequest(o, localRefs, memory.NewStorage(), ar)
+	c.Assert(err, IsNil)
+	c.Assert(req.Capabilities.Supports(capability.PushOptions), Equals, true)
+	c.Assert(req.Options, DeepEquals, []string{"topic=foo", "wip"})
+	c.Assert(req.Commands, HasLen, 1)
+	c.Assert(req.Commands[0].Name, Equals, plumbing.ReferenceName("refs/for/master%topic=foo,r=alice@example.com"))
+
+	req, err = r.newReferenceUpdateRequest(&PushOptions{}, nil, memory.NewStorage(), ar)
+	c.Assert(err, IsNil)
+	c.Assert(req.Capabilities.Supports(capability.PushOptions), Equals, false)
+}
+
+func (s *RemoteSuite) TestPushOptionsValidate(c *C) {
+	o := &PushOptions{Options: []string{"topic=foo\nbar"}}
+	c.Assert(o.Validate(), Equals, ErrInvalidPushOption)
+}
+
+func (s *RemoteSuite) TestDefaultAgent(c *C) {
+	r := newRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{"https://github.com/git-fixtures/basic.git"},
+	})
+
+	ar := packp.NewAdvRefs()
+	c.Assert(ar.Capabilities.Add(capability.Agent, "git/2.39.5"), IsNil)
+
+	req, err := r.newUploadPackRequest(&FetchOptions{}, ar)
+	c.Assert(err, IsNil)
+	c.Assert(req.Capabilities.Get(capability.Agent), DeepEquals, []string{capability.DefaultAgent})
+
+	_, ep, err := r.fetchClient(nil)
+	c.Assert(err, IsNil)
+	c.Assert(ep.HTTP.UserAgent, Equals, "")
+}
+
+func (s *RemoteSuite) TestRequestableCapabilitiesConfig(c *C) {
+	sto := memory.NewStorage()
+	cfg, err := sto.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.SetOption("repack", "", "useDeltaBaseOffset", "false")
+	cfg.Raw.SetOption("sendpack", "", "sideband", "false")
+	c.Assert(sto.SetConfig(cfg), IsNil)
+
+	r := newRemote(sto, &config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{s.GetBasicLocalRepositoryURL()},
+	})
+
+	adv := capability.NewList()
+	for _, name := range []capability.Capability{
+		capability.Sideband64k, capability.OFSDelta, capability.ReportStatus,
+	} {
+		c.Assert(adv.Add(name), IsNil)
+	}
+
+	caps, err := r.requestableCapabilities(adv, nil, false)
+	c.Assert(err, IsNil)
+	c.Assert(caps.All(), DeepEquals, []capability.Capability{
+		capability.Sideband64k, capability.ReportStatus,
+	})
+
+	caps, err = r.requestableCapabilities(adv, nil, true)
+	c.Assert(err, IsNil)
+	c.Assert(caps.All(), DeepEquals, []capability.Capability{capability.ReportStatus})
 }
@@ -0,0 +1,143 @@
+package git
+
+import (
+	"sort"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+// repackGeometric packs the loose objects and the packs breaking the
+// geometric progression of RepackConfig.Geometric into a new pack, deleting
+// them.
+func (r *Repository) repackGeometric(cfg *RepackConfig) error {
+	pos, ok := r.Storer.(storer.PackedObjectStorer)
+	if !ok {
+		return ErrPackedObjectsNotSupported
+	}
+
+	pol, ok := r.Storer.(storer.PackedObjectLister)
+	if !ok {
+		return ErrPackedObjectsNotSupported
+	}
+
+	packs, err := pos.ObjectPacks()
+	if err != nil {
+		return err
+	}
+
+	objects := make(map[plumbing.Hash][]plumbing.Hash, len(packs))
+	for _, pack := range packs {
+		if objects[pack], err = pol.ObjectPackHashes(pack); err != nil {
+			return err
+		}
+	}
+
+	sort.SliceStable(packs, func(i, j int) bool {
+		return len(objects[packs[i]]) < len(objects[packs[j]])
+	})
+
+	weights := make([]int, len(packs))
+	for i, pack := range packs {
+		weights[i] = len(objects[pack])
+	}
+
+	rollup := packs[:geometricSplit(weights, cfg.Geometric)]
+
+	var loose []plumbing.Hash
+	los, isLoose := r.Storer.(storer.LooseObjectStorer)
+	if isLoose {
+		err := los.ForEachObjectHash(func(h plumbing.Hash) error {
+			loose = append(loose, h)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(loose) == 0 && len(rollup) < 2 {
+		return nil
+	}
+
+	seen := make(map[plumbing.Hash]struct{})
+	var hashes []plumbing.Hash
+	add := func(objs []plumbing.Hash) {
+		for _, h := range objs {
+			if _, ok := seen[h]; !ok {
+				seen[h] = struct{}{}
+				hashes = append(hashes, h)
+			}
+		}
+	}
+
+	for _, pack := range rollup {
+		add(objects[pack])
+	}
+
+	add(loose)
+
+	nh, err := r.writeObjectPack(hashes, cfg.UseRefDeltas)
+	if err != nil {
+		return err
+	}
+
+	for _, pack := range rollup {
+		if pack == nh {
+			continue
+		}
+
+		if err := pos.DeleteOldObjectPackAndIndex(pack, cfg.OnlyDeletePacksOlderThan); err != nil {
+			return err
+		}
+	}
+
+	for _, h := range loose {
+		if err := los.DeleteLooseObject(h); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// geometricSplit returns the number of the smallest packs, given their
+// ascending number of objects, to be packed together for the packs to form a
+// geometric progression of the given factor, as git repack --geometric does.
+// The largest packs already forming a progression are kept, the smaller ones
+// are rolled up, along with the next packs not being factor times larger
+// than the rolled up ones.
+func geometricSplit(weights []int, factor int) int {
+	if len(weights) == 0 {
+		return 0
+	}
+
+	i := len(weights) - 1
+	for ; i > 0; i-- {
+		if weights[i] < factor*weights[i-1] {
+			break
+		}
+	}
+
+	split := i
+	if split > 0 {
+		// the larger pack of the last pair compared isn't in the
+		// progression either
+		split++
+	}
+
+	total := 0
+	for _, w := range weights[:split] {
+		total += w
+	}
+
+	for ; split < len(weights); split++ {
+		if weights[split] >= factor*total {
+			break
+		}
+
+		total += weights[split]
+	}
+
+	return split
+}
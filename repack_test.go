@@ -0,0 +1,93 @@
+package git
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+)
+
+type RepackSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&RepackSuite{})
+
+func (s *RepackSuite) TestGeometricSplit(c *C) {
+	for _, t := range []struct {
+		weights  []int
+		expected int
+	}{
+		{nil, 0},
+		{[]int{5}, 0},
+		{[]int{1, 2, 4}, 0},
+		{[]int{2, 2, 100}, 2},
+		{[]int{1, 1, 3, 100}, 3},
+		{[]int{3, 4, 5}, 3},
+	} {
+		c.Assert(geometricSplit(t.weights, 2), Equals, t.expected, Commentf("%v", t.weights))
+	}
+}
+
+func (s *RepackSuite) TestRepackGeometric(c *C) {
+	fs := fixtures.ByTag("unpacked").One().DotGit()
+	sto, err := filesystem.NewStorage(fs)
+	c.Assert(err, IsNil)
+
+	r, err := Open(sto, fs)
+	c.Assert(err, IsNil)
+
+	var loose []plumbing.Hash
+	err = sto.ForEachObjectHash(func(h plumbing.Hash) error {
+		loose = append(loose, h)
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(len(loose) > 12, Equals, true)
+
+	n := len(loose)
+	_, err = r.writeObjectPack(loose[:n-4], false)
+	c.Assert(err, IsNil)
+	_, err = r.writeObjectPack(loose[n-4:n-2], false)
+	c.Assert(err, IsNil)
+	_, err = r.writeObjectPack(loose[n-2:], false)
+	c.Assert(err, IsNil)
+
+	for _, h := range loose {
+		c.Assert(sto.DeleteLooseObject(h), IsNil)
+	}
+
+	// the largest pack of the fixture is more than twice as large as the
+	// rest together, so it's the only one kept
+	packs, err := sto.ObjectPacks()
+	c.Assert(err, IsNil)
+	c.Assert(packs, HasLen, 5)
+
+	var largest plumbing.Hash
+	var max int
+	for _, pack := range packs {
+		hashes, err := sto.ObjectPackHashes(pack)
+		c.Assert(err, IsNil)
+		if len(hashes) > max {
+			largest, max = pack, len(hashes)
+		}
+	}
+
+	c.Assert(r.RepackObjects(&RepackConfig{Geometric: 2}), IsNil)
+
+	packs, err = sto.ObjectPacks()
+	c.Assert(err, IsNil)
+	c.Assert(packs, HasLen, 2)
+	c.Assert(packs[0] == largest || packs[1] == largest, Equals, true)
+
+	for _, h := range loose {
+		c.Assert(sto.HasEncodedObject(h), IsNil)
+	}
+
+	c.Assert(r.RepackObjects(&RepackConfig{Geometric: 2}), IsNil)
+
+	after, err := sto.ObjectPacks()
+	c.Assert(err, IsNil)
+	c.Assert(after, DeepEquals, packs)
+}
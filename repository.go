@@ -31,7 +31,11 @@ var (
 	// ErrBranchExists an error stating the specified branch already exists
 	ErrBranchExists = errors.New("branch already exists")
 	// ErrBranchNotFound an error stating the specified branch does not exist
-	ErrBranchNotFound            = errors.New("branch not found")
+	ErrBranchNotFound = errors.New("branch not found")
+	// ErrTagExists an error stating the specified tag already exists
+	ErrTagExists = errors.New("tag already exists")
+	// ErrTagNotFound an error stating the specified tag does not exist
+	ErrTagNotFound               = errors.New("tag not found")
 	ErrInvalidReference          = errors.New("invalid reference, should be a tag or a branch")
 	ErrRepositoryNotExists       = errors.New("repository does not exist")
 	ErrRepositoryAlreadyExists   = errors.New("repository already exists")
@@ -39,22 +43,72 @@ var (
 	ErrRemoteExists              = errors.New("remote already exists")
 	ErrWorktreeNotProvided       = errors.New("worktree should be provided")
 	ErrIsBareRepository          = errors.New("worktree not available in a bare repository")
+	ErrMirrorNotBare             = errors.New("mirror clone requires a bare repository")
 	ErrUnableToResolveCommit     = errors.New("unable to resolve commit")
 	ErrPackedObjectsNotSupported = errors.New("Packed objects not supported")
+	// ErrNoForkPoint is returned by ForkPoint when the commit didn't fork
+	// from any of the commits the reference pointed to.
+	ErrNoForkPoint = errors.New("no fork point found")
+	// ErrNameRevNotFound is returned by NameRev when the object can't be
+	// named by any of the references.
+	ErrNameRevNotFound = errors.New("cannot name revision")
+	// ErrNotSymbolicReference is returned when a symbolic reference is
+	// expected and the reference points to a hash.
+	ErrNotSymbolicReference = errors.New("reference is not a symbolic reference")
+	// ErrInvalidSymbolicReference is returned by SetSymbolicReference when
+	// the name or the target of the reference aren't below refs/.
+	ErrInvalidSymbolicReference = errors.New("invalid symbolic reference")
+	// ErrDeleteHEAD is returned by DeleteSymbolicReference for HEAD.
+	ErrDeleteHEAD = errors.New("HEAD cannot be deleted")
+	// ErrPreciousObjects is returned when deleting objects of a repository
+	// with extensions.preciousObjects enabled.
+	ErrPreciousObjects = errors.New("objects are precious, deleting them is not allowed")
 )
 
 // Repository represents a git repository
 type Repository struct {
 	Storer storage.Storer
-
-	r  map[string]*Remote
-	wt billy.Filesystem
+	// HookRunner runs the repository hooks during commit, checkout, pull and
+	// push operations, if nil no hooks are executed. NewScriptHookRunner
+	// returns a HookRunner executing the hooks as git does.
+	HookRunner HookRunner
+	// Hooks are Go callbacks invoked during commit, checkout and push
+	// operations.
+	Hooks Hooks
+	// FSMonitor reports the files changed at the worktree, allowing the
+	// worktree status to skip the unchanged ones, if nil every file is
+	// checked. NewHookFSMonitor returns a FSMonitor running the program
+	// configured at core.fsmonitor, such as the Watchman hook of git.
+	FSMonitor FSMonitor
+	// Tracer, if not nil, observes the steps of the clones, fetches, pushes
+	// and checkouts, see Tracer.
+	Tracer Tracer
+	// AutoGC, if true, runs the gc maintenance task after the fetches and
+	// commits when the repository has more loose objects than gc.auto or
+	// more packs than gc.autoPackLimit, as git does. The Hooks.PreAutoGC
+	// callback and the pre-auto-gc hook can veto it. By default it runs in
+	// the background, see WaitAutoGC.
+	AutoGC bool
+
+	r      map[string]*Remote
+	wt     billy.Filesystem
+	autoGC autoGCState
 }
 
 // Init creates an empty git repository, based on the given Storer and worktree.
 // The worktree Filesystem is optional, if nil a bare repository is created. If
 // the given storer is not empty ErrRepositoryAlreadyExists is returned
 func Init(s storage.Storer, worktree billy.Filesystem) (*Repository, error) {
+	return InitWithOptions(s, worktree, &InitOptions{})
+}
+
+// InitWithOptions creates an empty git repository as Init does, with the
+// given options.
+func InitWithOptions(s storage.Storer, worktree billy.Filesystem, o *InitOptions) (*Repository, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
 	if err := initStorer(s); err != nil {
 		return nil, err
 	}
@@ -69,7 +123,18 @@ func Init(s storage.Storer, worktree billy.Filesystem) (*Repository, error) {
 		return nil, err
 	}
 
-	h := plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.Master)
+	if err := copyTemplates(r, o.TemplateDir); err != nil {
+		return nil, err
+	}
+
+	branch := o.DefaultBranch
+	if branch == "" {
+		if branch, err = defaultBranch(r); err != nil {
+			return nil, err
+		}
+	}
+
+	h := plumbing.NewSymbolicReference(plumbing.HEAD, branch)
 	if err := s.SetReference(h); err != nil {
 		return nil, err
 	}
@@ -82,6 +147,31 @@ func Init(s storage.Storer, worktree billy.Filesystem) (*Repository, error) {
 	return r, setWorktreeAndStoragePaths(r, worktree)
 }
 
+// defaultBranch returns the branch HEAD points to in a new repository, the
+// init.defaultBranch of the config of the user and of the system for the
+// repositories at the OS filesystem, and master otherwise.
+func defaultBranch(r *Repository) (plumbing.ReferenceName, error) {
+	if !isOSStorage(r) {
+		return plumbing.Master, nil
+	}
+
+	cfg, err := r.ConfigScoped(config.SystemScope)
+	if err != nil {
+		return "", err
+	}
+
+	if cfg.Init.DefaultBranch == "" {
+		return plumbing.Master, nil
+	}
+
+	o := &InitOptions{DefaultBranch: plumbing.ReferenceName(cfg.Init.DefaultBranch)}
+	if err := o.Validate(); err != nil {
+		return "", err
+	}
+
+	return o.DefaultBranch, nil
+}
+
 func initStorer(s storer.Storer) error {
 	i, ok := s.(storer.Initializer)
 	if !ok {
@@ -92,22 +182,18 @@ func initStorer(s storer.Storer) error {
 }
 
 func setWorktreeAndStoragePaths(r *Repository, worktree billy.Filesystem) error {
-	type fsBased interface {
-		Filesystem() billy.Filesystem
-	}
-
 	// .git file is only created if the storage is file based and the file
 	// system is osfs.OS
-	fs, isFSBased := r.Storer.(fsBased)
+	fs, isFSBased := storerFilesystem(r)
 	if !isFSBased {
 		return nil
 	}
 
-	if err := createDotGitFile(worktree, fs.Filesystem()); err != nil {
+	if err := createDotGitFile(worktree, fs); err != nil {
 		return err
 	}
 
-	return setConfigWorktree(r, worktree, fs.Filesystem())
+	return setConfigWorktree(r, worktree, fs)
 }
 
 func createDotGitFile(worktree, storage billy.Filesystem) error {
@@ -116,6 +202,8 @@ func createDotGitFile(worktree, storage billy.Filesystem) error {
 		path = storage.Root()
 	}
 
+	// the gitdir is always written with forward slashes, as git does
+	path = filepath.ToSlash(path)
 	if path == GitDirName {
 		// not needed, since the folder is the default place
 		return nil
@@ -171,6 +259,10 @@ func Open(s storage.Storer, worktree billy.Filesystem) (*Repository, error) {
 		return nil, err
 	}
 
+	if err := cfg.CheckExtensions(); err != nil {
+		return nil, err
+	}
+
 	if !cfg.Core.IsBare && worktree == nil {
 		return nil, ErrWorktreeNotProvided
 	}
@@ -211,9 +303,19 @@ func CloneContext(
 // if the repository will have worktree (non-bare) or not (bare), if the path
 // is not empty ErrRepositoryAlreadyExists is returned.
 func PlainInit(path string, isBare bool) (*Repository, error) {
+	return PlainInitWithOptions(path, &PlainInitOptions{Bare: isBare})
+}
+
+// PlainInitWithOptions creates an empty git repository at the given path as
+// PlainInit does, with the given options.
+func PlainInitWithOptions(path string, o *PlainInitOptions) (*Repository, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
 	var wt, dot billy.Filesystem
 
-	if isBare {
+	if o.Bare {
 		dot = osfs.New(path)
 	} else {
 		wt = osfs.New(path)
@@ -225,7 +327,7 @@ func PlainInit(path string, isBare bool) (*Repository, error) {
 		return nil, err
 	}
 
-	return Init(s, wt)
+	return InitWithOptions(s, wt, &o.InitOptions)
 }
 
 // PlainOpen opens a git repository from the given path. It detects if the
@@ -361,11 +463,85 @@ func newRepository(s storage.Storer, worktree billy.Filesystem) *Repository {
 	}
 }
 
+// Namespace returns a bare view of the repository seeing only the references
+// of the given namespace, as GIT_NAMESPACE does, see
+// storage.NewNamespacedStorer. The objects and the config are shared with r.
+func (r *Repository) Namespace(namespace string) *Repository {
+	ns := newRepository(storage.NewNamespacedStorer(r.Storer, namespace), nil)
+	ns.HookRunner = r.HookRunner
+	ns.Hooks = r.Hooks
+	ns.Tracer = r.Tracer
+	return ns
+}
+
 // Config return the repository config
 func (r *Repository) Config() (*config.Config, error) {
 	return r.Storer.Config()
 }
 
+// ConfigScoped returns the config of the repository merged with the ones of
+// the wider scopes up to scope: LocalScope returns the repository config,
+// GlobalScope adds the config of the user and SystemScope the one of the
+// system too. The config of the worktree, if extensions.worktreeConfig is
+// enabled, overrides the repository one at every scope, and WorktreeScope
+// returns them merged, storing the options at the worktree config.
+// Config.Origin reports the file every option comes from.
+func (r *Repository) ConfigScoped(scope config.Scope) (*config.Config, error) {
+	if scope == config.WorktreeScope {
+		if s, ok := r.Storer.(config.WorktreeConfigStorer); ok {
+			return s.WorktreeConfig()
+		}
+
+		return r.Storer.Config()
+	}
+
+	local, err := r.Storer.Config()
+	if err != nil || scope == config.LocalScope {
+		return local, err
+	}
+
+	var cfgs []*config.Config
+	for s := scope; s > config.LocalScope; s-- {
+		cfg, err := config.LoadConfig(s)
+		if err != nil {
+			return nil, err
+		}
+
+		cfgs = append(cfgs, cfg)
+	}
+
+	return config.Merge(append(cfgs, local)...)
+}
+
+// SetConfigScoped stores the config at the given scope, the repository for
+// LocalScope, its worktree for WorktreeScope, and the file of the user or of
+// the system for the others. The options read from wider scopes are not
+// stored, but cfg is expected to be read at the same scope: with Config for
+// LocalScope, ConfigScoped for WorktreeScope and config.LoadConfig for the
+// rest.
+func (r *Repository) SetConfigScoped(scope config.Scope, cfg *config.Config) error {
+	switch scope {
+	case config.LocalScope:
+		return r.Storer.SetConfig(cfg)
+	case config.WorktreeScope:
+		if s, ok := r.Storer.(config.WorktreeConfigStorer); ok {
+			return s.SetWorktreeConfig(cfg)
+		}
+
+		return r.Storer.SetConfig(cfg)
+	}
+
+	return config.SaveConfig(scope, cfg)
+}
+
+func (r *Repository) newRemote(c *config.RemoteConfig) *Remote {
+	remote := newRemote(r.Storer, c)
+	remote.hooks = r.HookRunner
+	remote.callbacks = &r.Hooks
+	remote.tracer = r.Tracer
+	return remote
+}
+
 // Remote return a remote if exists
 func (r *Repository) Remote(name string) (*Remote, error) {
 	cfg, err := r.Storer.Config()
@@ -378,7 +554,7 @@ func (r *Repository) Remote(name string) (*Remote, error) {
 		return nil, ErrRemoteNotFound
 	}
 
-	return newRemote(r.Storer, c), nil
+	return r.newRemote(c), nil
 }
 
 // Remotes returns a list with all the remotes
@@ -392,7 +568,7 @@ func (r *Repository) Remotes() ([]*Remote, error) {
 
 	var i int
 	for _, c := range cfg.Remotes {
-		remotes[i] = newRemote(r.Storer, c)
+		remotes[i] = r.newRemote(c)
 		i++
 	}
 
@@ -405,7 +581,7 @@ func (r *Repository) CreateRemote(c *config.RemoteConfig) (*Remote, error) {
 		return nil, err
 	}
 
-	remote := newRemote(r.Storer, c)
+	remote := r.newRemote(c)
 
 	cfg, err := r.Storer.Config()
 	if err != nil {
@@ -484,6 +660,88 @@ func (r *Repository) DeleteBranch(name string) error {
 	return r.Storer.SetConfig(cfg)
 }
 
+// CreateTag creates a tag. If opts is included, the tag is an annotated tag,
+// otherwise a lightweight tag is created.
+func (r *Repository) CreateTag(name string, hash plumbing.Hash, opts *CreateTagOptions) (*plumbing.Reference, error) {
+	rname := plumbing.ReferenceName("refs/tags/" + name)
+
+	_, err := r.Storer.Reference(rname)
+	switch err {
+	case nil:
+		return nil, ErrTagExists
+	case plumbing.ErrReferenceNotFound:
+	default:
+		return nil, err
+	}
+
+	target := hash
+	if opts != nil {
+		target, err = r.createTagObject(name, hash, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ref := plumbing.NewHashReference(rname, target)
+	if err = r.Storer.SetReference(ref); err != nil {
+		return nil, err
+	}
+
+	return ref, nil
+}
+
+func (r *Repository) createTagObject(name string, hash plumbing.Hash, opts *CreateTagOptions) (plumbing.Hash, error) {
+	if err := opts.Validate(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	obj, err := r.Storer.EncodedObject(plumbing.AnyObject, hash)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	tag := &object.Tag{
+		Name:       name,
+		Tagger:     *opts.Tagger,
+		Message:    opts.Message,
+		TargetType: obj.Type(),
+		Target:     hash,
+	}
+
+	if opts.Signer != nil {
+		sig, err := signObject(opts.Signer, tag)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		tag.PGPSignature = sig
+	}
+
+	o := r.Storer.NewEncodedObject()
+	if err := tag.Encode(o); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return r.Storer.SetEncodedObject(o)
+}
+
+// DeleteTag deletes a tag from the repository, the tag object of annotated
+// tags is not removed.
+func (r *Repository) DeleteTag(name string) error {
+	rname := plumbing.ReferenceName("refs/tags/" + name)
+
+	_, err := r.Storer.Reference(rname)
+	if err == plumbing.ErrReferenceNotFound {
+		return ErrTagNotFound
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return r.Storer.RemoveReference(rname)
+}
+
 func (r *Repository) resolveToCommitHash(h plumbing.Hash) (plumbing.Hash, error) {
 	obj, err := r.Storer.EncodedObject(plumbing.AnyObject, h)
 	if err != nil {
@@ -504,26 +762,47 @@ func (r *Repository) resolveToCommitHash(h plumbing.Hash) (plumbing.Hash, error)
 }
 
 // Clone clones a remote repository
-func (r *Repository) clone(ctx context.Context, o *CloneOptions) error {
+func (r *Repository) clone(ctx context.Context, o *CloneOptions) (err error) {
 	if err := o.Validate(); err != nil {
 		return err
 	}
 
+	if o.Tracer != nil {
+		r.Tracer = o.Tracer
+	}
+
+	ctx, span := startSpan(ctx, r.Tracer, TraceClone)
+	span.SetAttribute(TraceURL, o.URL)
+	defer func() { span.End(err) }()
+
 	c := &config.RemoteConfig{
 		Name: o.RemoteName,
 		URLs: []string{o.URL},
 	}
 
+	if o.Mirror {
+		if r.wt != nil {
+			return ErrMirrorNotBare
+		}
+
+		c.Fetch = []config.RefSpec{config.MirrorRefSpec}
+		c.Mirror = true
+	}
+
 	if _, err := r.CreateRemote(c); err != nil {
 		return err
 	}
 
 	ref, err := r.fetchAndUpdateReferences(ctx, &FetchOptions{
-		RefSpecs: r.cloneRefSpec(o, c),
-		Depth:    o.Depth,
-		Auth:     o.Auth,
-		Progress: o.Progress,
-		Tags:     o.Tags,
+		RefSpecs:           r.cloneRefSpec(o, c),
+		Depth:              o.Depth,
+		Auth:               o.Auth,
+		HTTPOptions:        o.HTTPOptions,
+		Progress:           o.Progress,
+		Tags:               o.Tags,
+		WriteCommitGraph:   o.WriteCommitGraph,
+		DeltaBaseCacheSize: o.DeltaBaseCacheSize,
+		FsckObjects:        o.FsckObjects,
 	}, o.ReferenceName)
 	if err != nil {
 		return err
@@ -540,9 +819,11 @@ func (r *Repository) clone(ctx context.Context, o *CloneOptions) error {
 			return err
 		}
 
-		if err := w.Reset(&ResetOptions{
-			Mode:   MergeReset,
-			Commit: head.Hash(),
+		if err := w.resetContext(ctx, &ResetOptions{
+			Mode:     MergeReset,
+			Commit:   head.Hash(),
+			Workers:  o.Workers,
+			Progress: o.CheckoutProgress,
 		}); err != nil {
 			return err
 		}
@@ -551,6 +832,7 @@ func (r *Repository) clone(ctx context.Context, o *CloneOptions) error {
 			if err := w.updateSubmodules(&SubmoduleUpdateOptions{
 				RecurseSubmodules: o.RecurseSubmodules,
 				Auth:              o.Auth,
+				Depth:             o.SubmoduleDepth,
 			}); err != nil {
 				return err
 			}
@@ -561,7 +843,7 @@ func (r *Repository) clone(ctx context.Context, o *CloneOptions) error {
 		return err
 	}
 
-	if ref.Name().IsBranch() {
+	if ref.Name().IsBranch() && !o.Mirror {
 		branchRef := ref.Name()
 		branchName := strings.Split(string(branchRef), "refs/heads/")[1]
 
@@ -592,6 +874,8 @@ func (r *Repository) cloneRefSpec(o *CloneOptions, c *config.RemoteConfig) []con
 	var rs string
 
 	switch {
+	case o.Mirror:
+		return c.Fetch
 	case o.ReferenceName.IsTag() && o.Depth > 0:
 		rs = fmt.Sprintf(refspecTagWithDepth, o.ReferenceName.Short())
 	case o.SingleBranch && o.ReferenceName == plumbing.HEAD:
@@ -616,7 +900,7 @@ func (r *Repository) setIsBare(isBare bool) error {
 }
 
 func (r *Repository) updateRemoteConfigIfNeeded(o *CloneOptions, c *config.RemoteConfig, head *plumbing.Reference) error {
-	if !o.SingleBranch {
+	if !o.SingleBranch || o.Mirror {
 		return nil
 	}
 
@@ -664,6 +948,16 @@ func (r *Repository) fetchAndUpdateReferences(
 		return nil, err
 	}
 
+	// a mirror has no remote-tracking references to point to
+	if !remote.c.Mirror {
+		headUpdated, err := r.updateRemoteHead(remote.c.Name, o.RefSpecs, remoteRefs)
+		if err != nil {
+			return nil, err
+		}
+
+		refsUpdated = refsUpdated || headUpdated
+	}
+
 	if !objsUpdated && !refsUpdated {
 		return nil, NoErrAlreadyUpToDate
 	}
@@ -707,6 +1001,46 @@ func (r *Repository) updateReferences(spec []config.RefSpec,
 	return
 }
 
+// updateRemoteHead points refs/remotes/<remote>/HEAD to the reference
+// fetched for the branch of the HEAD of the remote, as git clone does.
+func (r *Repository) updateRemoteHead(remote string, specs []config.RefSpec,
+	remoteRefs storer.ReferenceStorer) (bool, error) {
+
+	head, err := remoteRefs.Reference(plumbing.HEAD)
+	if err == plumbing.ErrReferenceNotFound {
+		return false, nil
+	}
+
+	if err != nil || head.Type() != plumbing.SymbolicReference {
+		return false, err
+	}
+
+	name := plumbing.ReferenceName(fmt.Sprintf("refs/remotes/%s/HEAD", remote))
+	for _, rs := range specs {
+		if !rs.Match(head.Target()) {
+			continue
+		}
+
+		dst := rs.Dst(head.Target())
+		if dst == name || !dst.IsRemote() {
+			continue
+		}
+
+		_, err := r.Storer.Reference(dst)
+		if err == plumbing.ErrReferenceNotFound {
+			continue
+		}
+
+		if err != nil {
+			return false, err
+		}
+
+		return updateReferenceStorerIfNeeded(r.Storer, plumbing.NewSymbolicReference(name, dst))
+	}
+
+	return false, nil
+}
+
 func (r *Repository) calculateRemoteHeadReference(spec []config.RefSpec,
 	resolvedHead *plumbing.Reference) []*plumbing.Reference {
 
@@ -783,7 +1117,15 @@ func (r *Repository) FetchContext(ctx context.Context, o *FetchOptions) error {
 		return err
 	}
 
-	return remote.FetchContext(ctx, o)
+	err = remote.FetchContext(ctx, o)
+	if err != nil && (!o.Refetch || err != NoErrAlreadyUpToDate) {
+		return err
+	}
+
+	// The objects of a refetch are received again even if the references
+	// are up-to-date.
+	r.runAutoGC(o.Refetch)
+	return err
 }
 
 // Push performs a push to the remote. Returns NoErrAlreadyUpToDate if
@@ -801,11 +1143,7 @@ func (r *Repository) Push(o *PushOptions) error {
 // operation is complete, an error is returned. The context only affects to the
 // transport operations.
 func (r *Repository) PushContext(ctx context.Context, o *PushOptions) error {
-	if err := o.Validate(); err != nil {
-		return err
-	}
-
-	remote, err := r.Remote(o.RemoteName)
+	remote, err := r.pushRemote(o)
 	if err != nil {
 		return err
 	}
@@ -813,8 +1151,37 @@ func (r *Repository) PushContext(ctx context.Context, o *PushOptions) error {
 	return remote.PushContext(ctx, o)
 }
 
+// pushRemote returns the remote named as PushOptions.RemoteName. The options
+// are validated by the remote, since the default RefSpecs depend on whether it
+// is a mirror.
+func (r *Repository) pushRemote(o *PushOptions) (*Remote, error) {
+	name := o.RemoteName
+	if name == "" {
+		name = DefaultRemoteName
+	}
+
+	return r.Remote(name)
+}
+
 // Log returns the commit history from the given LogOptions.
 func (r *Repository) Log(o *LogOptions) (object.CommitIter, error) {
+	return r.LogContext(context.Background(), o)
+}
+
+// LogContext returns the commit history from the given LogOptions.
+//
+// The provided Context must be non-nil. Once the context expires, the
+// returned iterator fails with the error of the context.
+func (r *Repository) LogContext(ctx context.Context, o *LogOptions) (object.CommitIter, error) {
+	iter, err := r.log(o)
+	if err != nil {
+		return nil, err
+	}
+
+	return &contextCommitIter{ctx: ctx, CommitIter: iter}, nil
+}
+
+func (r *Repository) log(o *LogOptions) (object.CommitIter, error) {
 	h := o.From
 	if o.From == plumbing.ZeroHash {
 		head, err := r.Head()
@@ -830,19 +1197,56 @@ func (r *Repository) Log(o *LogOptions) (object.CommitIter, error) {
 		return nil, err
 	}
 
+	var iter object.CommitIter
 	switch o.Order {
 	case LogOrderDefault:
-		return object.NewCommitPreorderIter(commit, nil, nil), nil
+		iter = object.NewCommitPreorderIter(commit, nil, nil)
 	case LogOrderDFS:
-		return object.NewCommitPreorderIter(commit, nil, nil), nil
+		iter = object.NewCommitPreorderIter(commit, nil, nil)
 	case LogOrderDFSPost:
-		return object.NewCommitPostorderIter(commit, nil), nil
+		iter = object.NewCommitPostorderIter(commit, nil)
 	case LogOrderBSF:
-		return object.NewCommitIterBSF(commit, nil, nil), nil
+		iter = object.NewCommitIterBSF(commit, nil, nil)
 	case LogOrderCommitterTime:
-		return object.NewCommitIterCTime(commit, nil, nil), nil
+		iter = object.NewCommitIterCTime(commit, nil, nil)
+	default:
+		return nil, fmt.Errorf("invalid Order=%v", o.Order)
 	}
-	return nil, fmt.Errorf("invalid Order=%v", o.Order)
+
+	if !o.UseMailmap {
+		return iter, nil
+	}
+
+	m, err := r.Mailmap()
+	if err != nil {
+		return nil, err
+	}
+
+	return &mailmapCommitIter{m: m, CommitIter: iter}, nil
+}
+
+// contextCommitIter is a CommitIter failing once its context expires.
+type contextCommitIter struct {
+	ctx context.Context
+	object.CommitIter
+}
+
+func (i *contextCommitIter) Next() (*object.Commit, error) {
+	if err := i.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return i.CommitIter.Next()
+}
+
+func (i *contextCommitIter) ForEach(cb func(*object.Commit) error) error {
+	return i.CommitIter.ForEach(func(c *object.Commit) error {
+		if err := i.ctx.Err(); err != nil {
+			return err
+		}
+
+		return cb(c)
+	})
 }
 
 // Tags returns all the References from Tags. This method returns all the tag
@@ -907,6 +1311,95 @@ func (r *Repository) CommitObject(h plumbing.Hash) (*object.Commit, error) {
 	return object.GetCommit(r.Storer, h)
 }
 
+// AheadBehind returns the number of commits reachable from a and not from b,
+// and the number of commits reachable from b and not from a. Only the history
+// down to the merge bases of both commits is walked.
+func (r *Repository) AheadBehind(a, b plumbing.Hash) (ahead, behind int, err error) {
+	ca, err := r.CommitObject(a)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	cb, err := r.CommitObject(b)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return ca.AheadBehind(cb)
+}
+
+// ForkPoint mimics the behavior of `git merge-base --fork-point ref commit`,
+// returning the commit where the history of commit forked from the branch at
+// ref, taking into account the commits ref pointed to according to its
+// reflog. If ref has no reflog only its current commit is considered. If the
+// best common ancestor is not any of those commits, ErrNoForkPoint is
+// returned.
+func (r *Repository) ForkPoint(ref plumbing.ReferenceName, commit plumbing.Hash) (*object.Commit, error) {
+	entries, err := readReflog(r, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []plumbing.Hash
+	for i, e := range entries {
+		if i == 0 {
+			hashes = append(hashes, e.old)
+		}
+
+		hashes = append(hashes, e.new)
+	}
+
+	if len(hashes) == 0 {
+		tip, err := r.Reference(ref, true)
+		if err != nil {
+			return nil, err
+		}
+
+		hashes = append(hashes, tip.Hash())
+	}
+
+	var candidates []*object.Commit
+	seen := make(map[plumbing.Hash]bool)
+	for _, h := range hashes {
+		if h.IsZero() || seen[h] {
+			continue
+		}
+
+		seen[h] = true
+		c, err := r.CommitObject(h)
+		if err == plumbing.ErrObjectNotFound {
+			// the reflog may point to pruned commits
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, c)
+	}
+
+	c, err := r.CommitObject(commit)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(candidates) == 0 {
+		return nil, ErrNoForkPoint
+	}
+
+	bases, err := c.MergeBase(candidates...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bases) != 1 || !seen[bases[0].Hash] {
+		return nil, ErrNoForkPoint
+	}
+
+	return bases[0], nil
+}
+
 // CommitObjects returns an unsorted CommitIter with all the commits in the repository.
 func (r *Repository) CommitObjects() (object.CommitIter, error) {
 	iter, err := r.Storer.IterEncodedObjects(plumbing.CommitObject)
@@ -1138,6 +1631,13 @@ type RepackConfig struct {
 	// OnlyDeletePacksOlderThan if set to non-zero value
 	// selects only objects older than the time provided.
 	OnlyDeletePacksOlderThan time.Time
+	// Geometric, if greater than 1, only packs the loose objects and the
+	// smallest packs into a new pack, so the number of objects of every pack
+	// is at least Geometric times the one of the next smaller pack, as `git
+	// repack --geometric` does. The largest packs are left untouched and no
+	// object is dropped, even if unreachable, so huge repositories aren't
+	// repacked fully.
+	Geometric int
 }
 
 func (r *Repository) RepackObjects(cfg *RepackConfig) (err error) {
@@ -1146,6 +1646,14 @@ func (r *Repository) RepackObjects(cfg *RepackConfig) (err error) {
 		return ErrPackedObjectsNotSupported
 	}
 
+	if err := r.checkPreciousObjects(); err != nil {
+		return err
+	}
+
+	if cfg.Geometric > 1 {
+		return r.repackGeometric(cfg)
+	}
+
 	// Get the existing object packs.
 	hs, err := pos.ObjectPacks()
 	if err != nil {
@@ -1174,8 +1682,7 @@ func (r *Repository) RepackObjects(cfg *RepackConfig) (err error) {
 }
 
 // createNewObjectPack is a helper for RepackObjects taking care
-// of creating a new pack. It is used so the the PackfileWriter
-// deferred close has the right scope.
+// of creating a new pack with the reachable objects.
 func (r *Repository) createNewObjectPack(cfg *RepackConfig) (h plumbing.Hash, err error) {
 	ow := newObjectWalker(r.Storer)
 	err = ow.walkAllRefs()
@@ -1186,21 +1693,7 @@ func (r *Repository) createNewObjectPack(cfg *RepackConfig) (h plumbing.Hash, er
 	for h := range ow.seen {
 		objs = append(objs, h)
 	}
-	pfw, ok := r.Storer.(storer.PackfileWriter)
-	if !ok {
-		return h, fmt.Errorf("Repository storer is not a storer.PackfileWriter")
-	}
-	wc, err := pfw.PackfileWriter()
-	if err != nil {
-		return h, err
-	}
-	defer ioutil.CheckClose(wc, &err)
-	scfg, err := r.Storer.Config()
-	if err != nil {
-		return h, err
-	}
-	enc := packfile.NewEncoder(wc, r.Storer, cfg.UseRefDeltas)
-	h, err = enc.Encode(objs, scfg.Pack.Window)
+	h, err = r.writeObjectPack(objs, cfg.UseRefDeltas)
 	if err != nil {
 		return h, err
 	}
@@ -1223,3 +1716,24 @@ func (r *Repository) createNewObjectPack(cfg *RepackConfig) (h plumbing.Hash, er
 
 	return h, err
 }
+
+// writeObjectPack writes a new pack with the given objects, returning its
+// hash. It is used so the the PackfileWriter deferred close has the right
+// scope.
+func (r *Repository) writeObjectPack(objs []plumbing.Hash, useRefDeltas bool) (h plumbing.Hash, err error) {
+	pfw, ok := r.Storer.(storer.PackfileWriter)
+	if !ok {
+		return h, fmt.Errorf("Repository storer is not a storer.PackfileWriter")
+	}
+	wc, err := pfw.PackfileWriter()
+	if err != nil {
+		return h, err
+	}
+	defer ioutil.CheckClose(wc, &err)
+	scfg, err := r.Storer.Config()
+	if err != nil {
+		return h, err
+	}
+	enc := packfile.NewEncoder(wc, r.Storer, useRefDeltas)
+	return enc.Encode(objs, scfg.Pack.Window)
+}
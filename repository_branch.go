@@ -0,0 +1,149 @@
+package git
+
+import (
+	"os"
+	"path"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+// RenameBranch renames the local branch old to name, as `git branch -m` does:
+// the reference, its configuration and its reflog are moved, and HEAD is
+// updated if it points to the branch. ErrBranchExists is returned if a branch
+// named name already exists.
+func (r *Repository) RenameBranch(old, name string) error {
+	if err := r.copyBranch(old, name, true); err != nil {
+		return err
+	}
+
+	from := branchReferenceName(old)
+	head, err := r.Storer.Reference(plumbing.HEAD)
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return err
+	}
+
+	if head != nil && head.Type() == plumbing.SymbolicReference && head.Target() == from {
+		ref := plumbing.NewSymbolicReference(plumbing.HEAD, branchReferenceName(name))
+		if err := r.Storer.SetReference(ref); err != nil {
+			return err
+		}
+	}
+
+	return r.Storer.RemoveReference(from)
+}
+
+// CopyBranch copies the local branch src to a new branch named dst, along with
+// its configuration and its reflog, as `git branch -c` does. ErrBranchExists
+// is returned if a branch named dst already exists.
+func (r *Repository) CopyBranch(src, dst string) error {
+	return r.copyBranch(src, dst, false)
+}
+
+// copyBranch creates the branch dst pointing to the commit of src, with the
+// configuration and the reflog of src, which are moved instead of copied if
+// move is true.
+func (r *Repository) copyBranch(src, dst string, move bool) error {
+	if err := (&config.Branch{Name: dst}).Validate(); err != nil {
+		return err
+	}
+
+	from, to := branchReferenceName(src), branchReferenceName(dst)
+	ref, err := r.Storer.Reference(from)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Storer.Reference(to)
+	if err == nil {
+		return ErrBranchExists
+	}
+
+	if err != plumbing.ErrReferenceNotFound {
+		return err
+	}
+
+	cfg, err := r.Storer.Config()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := cfg.Branches[dst]; ok {
+		return ErrBranchExists
+	}
+
+	if move {
+		err = renameReflog(r, from, to)
+	} else {
+		err = copyReflog(r, from, to)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := r.Storer.SetReference(plumbing.NewHashReference(to, ref.Hash())); err != nil {
+		return err
+	}
+
+	b, ok := cfg.Branches[src]
+	if !ok {
+		return nil
+	}
+
+	if move {
+		// the section is renamed, keeping the options not known by config
+		delete(cfg.Branches, src)
+		b.Name = dst
+	} else {
+		b = &config.Branch{
+			Name:        dst,
+			Remote:      b.Remote,
+			Merge:       b.Merge,
+			Description: b.Description,
+		}
+	}
+
+	cfg.Branches[dst] = b
+	return r.Storer.SetConfig(cfg)
+}
+
+func branchReferenceName(name string) plumbing.ReferenceName {
+	return plumbing.ReferenceName("refs/heads/" + name)
+}
+
+// renameReflog moves the reflog of a reference, if any.
+func renameReflog(r *Repository, from, to plumbing.ReferenceName) error {
+	dot, isFSBased := storerFilesystem(r)
+	if !isFSBased {
+		return nil
+	}
+
+	err := dot.Rename(path.Join(logsDir, from.String()), path.Join(logsDir, to.String()))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// copyReflog copies the reflog of a reference, if any.
+func copyReflog(r *Repository, from, to plumbing.ReferenceName) error {
+	dot, isFSBased := storerFilesystem(r)
+	if !isFSBased {
+		return nil
+	}
+
+	content, err := readFile(dot, path.Join(logsDir, from.String()))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFile(dot, path.Join(logsDir, to.String()), content, 0644)
+}
@@ -0,0 +1,145 @@
+package git
+
+import (
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type RepositoryBranchSuite struct {
+	BaseSuite
+	r      *Repository
+	commit plumbing.Hash
+}
+
+var _ = Suite(&RepositoryBranchSuite{})
+
+func (s *RepositoryBranchSuite) SetUpTest(c *C) {
+	var err error
+	s.r, err = PlainInit(c.MkDir(), true)
+	c.Assert(err, IsNil)
+
+	s.commit, err = s.r.CommitChanges("foo\n", &CommitChangesOptions{
+		Branch:  "refs/heads/master",
+		Author:  defaultSignature(),
+		Changes: []FileChange{{Path: "foo", Content: strings.NewReader("foo")}},
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(s.r.CreateBranch(&config.Branch{
+		Name:        "master",
+		Remote:      "origin",
+		Merge:       "refs/heads/master",
+		Description: "the main branch\n",
+	}), IsNil)
+
+	dot, _ := storerFilesystem(s.r)
+	err = util.WriteFile(dot, "logs/refs/heads/master", []byte(
+		plumbing.ZeroHash.String()+" "+s.commit.String()+" foo <foo@foo.foo> 1494000000 +0200\tcommit (initial): foo\n",
+	), 0644)
+	c.Assert(err, IsNil)
+}
+
+func (s *RepositoryBranchSuite) TestRenameBranch(c *C) {
+	c.Assert(s.r.RenameBranch("master", "main"), IsNil)
+
+	_, err := s.r.Storer.Reference("refs/heads/master")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	ref, err := s.r.Storer.Reference("refs/heads/main")
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, s.commit)
+
+	head, err := s.r.Storer.Reference(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(head.Target(), Equals, plumbing.ReferenceName("refs/heads/main"))
+
+	_, err = s.r.Branch("master")
+	c.Assert(err, Equals, ErrBranchNotFound)
+
+	b, err := s.r.Branch("main")
+	c.Assert(err, IsNil)
+	c.Assert(b.Remote, Equals, "origin")
+	c.Assert(b.Merge, Equals, plumbing.ReferenceName("refs/heads/master"))
+	c.Assert(b.Description, Equals, "the main branch\n")
+
+	entries, err := readReflog(s.r, "refs/heads/master")
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 0)
+
+	entries, err = readReflog(s.r, "refs/heads/main")
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	c.Assert(entries[0].new, Equals, s.commit)
+}
+
+func (s *RepositoryBranchSuite) TestRenameBranchNotHEAD(c *C) {
+	c.Assert(s.r.CopyBranch("master", "feature"), IsNil)
+	c.Assert(s.r.RenameBranch("feature", "topic/foo"), IsNil)
+
+	head, err := s.r.Storer.Reference(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(head.Target(), Equals, plumbing.ReferenceName("refs/heads/master"))
+
+	ref, err := s.r.Storer.Reference("refs/heads/topic/foo")
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, s.commit)
+
+	_, err = s.r.Storer.Reference("refs/heads/feature")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *RepositoryBranchSuite) TestCopyBranch(c *C) {
+	c.Assert(s.r.CopyBranch("master", "feature"), IsNil)
+
+	for _, name := range []string{"master", "feature"} {
+		ref, err := s.r.Storer.Reference(plumbing.ReferenceName("refs/heads/" + name))
+		c.Assert(err, IsNil)
+		c.Assert(ref.Hash(), Equals, s.commit)
+
+		b, err := s.r.Branch(name)
+		c.Assert(err, IsNil)
+		c.Assert(b.Name, Equals, name)
+		c.Assert(b.Description, Equals, "the main branch\n")
+
+		entries, err := readReflog(s.r, ref.Name())
+		c.Assert(err, IsNil)
+		c.Assert(entries, HasLen, 1)
+	}
+
+	head, err := s.r.Storer.Reference(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(head.Target(), Equals, plumbing.ReferenceName("refs/heads/master"))
+}
+
+func (s *RepositoryBranchSuite) TestCopyBranchErrors(c *C) {
+	c.Assert(s.r.CopyBranch("foo", "bar"), Equals, plumbing.ErrReferenceNotFound)
+	c.Assert(s.r.CopyBranch("master", "master"), Equals, ErrBranchExists)
+	c.Assert(s.r.RenameBranch("master", ""), NotNil)
+
+	c.Assert(s.r.CreateBranch(&config.Branch{Name: "stale"}), IsNil)
+	c.Assert(s.r.RenameBranch("master", "stale"), Equals, ErrBranchExists)
+}
+
+func (s *RepositoryBranchSuite) TestRenameBranchMemory(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	_, err = r.CommitChanges("foo\n", &CommitChangesOptions{
+		Branch:  "refs/heads/master",
+		Author:  defaultSignature(),
+		Changes: []FileChange{{Path: "foo", Content: strings.NewReader("foo")}},
+	})
+	c.Assert(err, IsNil)
+
+	c.Assert(r.RenameBranch("master", "main"), IsNil)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, plumbing.ReferenceName("refs/heads/main"))
+}
@@ -0,0 +1,114 @@
+package git
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+// CommitChanges creates a commit applying the given changes to the tree of
+// the parent commit, without a worktree nor an index, and updates the branch
+// to point to it. The blobs and trees are written to the object storer and
+// only the subtrees with changes are read. No hooks are executed.
+func (r *Repository) CommitChanges(msg string, opts *CommitChangesOptions) (plumbing.Hash, error) {
+	if err := opts.Validate(r); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	old, err := r.Storer.Reference(opts.Branch)
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return plumbing.ZeroHash, err
+	}
+
+	if opts.Parent.IsZero() && old != nil {
+		opts.Parent = old.Hash()
+	}
+
+	if old != nil {
+		// the branch is expected to be at the parent
+		old = plumbing.NewHashReference(opts.Branch, opts.Parent)
+	}
+
+	tree, err := r.buildChangesTree(opts)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	commit := &object.Commit{
+		Author:    *opts.Author,
+		Committer: *opts.Committer,
+		Message:   msg,
+		TreeHash:  tree,
+	}
+
+	if !opts.Parent.IsZero() {
+		commit.ParentHashes = []plumbing.Hash{opts.Parent}
+	}
+
+	if opts.Signer != nil {
+		sig, err := signObject(opts.Signer, commit)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		commit.PGPSignature = sig
+	}
+
+	obj := r.Storer.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	h, err := r.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	ref := plumbing.NewHashReference(opts.Branch, h)
+	return h, r.Storer.CheckAndSetReference(ref, old)
+}
+
+func (r *Repository) buildChangesTree(opts *CommitChangesOptions) (plumbing.Hash, error) {
+	var base *object.Tree
+	if !opts.Parent.IsZero() {
+		parent, err := r.CommitObject(opts.Parent)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		if base, err = parent.Tree(); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	b := object.NewTreeBuilder(r.Storer, base)
+	for _, c := range opts.Changes {
+		if c.Delete {
+			if err := b.Remove(c.Path); err != nil {
+				return plumbing.ZeroHash, err
+			}
+
+			continue
+		}
+
+		h, err := storer.StoreObject(r.Storer, plumbing.BlobObject, -1, c.Content)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		if err := b.Add(c.Path, c.Mode, h); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	tree, err := b.Build()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if !opts.AllowEmpty && base != nil && tree == base.Hash {
+		return plumbing.ZeroHash, ErrEmptyCommit
+	}
+
+	return tree, nil
+}
@@ -0,0 +1,184 @@
+package git
+
+import (
+	"bytes"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+type CommitChangesSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&CommitChangesSuite{})
+
+func (s *CommitChangesSuite) file(c *C, r *Repository, commit plumbing.Hash, path string) string {
+	o, err := r.CommitObject(commit)
+	c.Assert(err, IsNil)
+
+	f, err := o.File(path)
+	c.Assert(err, IsNil)
+
+	content, err := f.Contents()
+	c.Assert(err, IsNil)
+	return content
+}
+
+func (s *CommitChangesSuite) TestCommitChanges(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	first, err := r.CommitChanges("foo\n", &CommitChangesOptions{
+		Author: defaultSignature(),
+		Changes: []FileChange{
+			{Path: "foo", Content: strings.NewReader("foo")},
+			{Path: "qux/bar", Content: strings.NewReader("bar")},
+			{Path: "qux/baz", Content: strings.NewReader("baz"), Mode: filemode.Executable},
+		},
+	})
+	c.Assert(err, IsNil)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Name(), Equals, plumbing.Master)
+	c.Assert(head.Hash(), Equals, first)
+
+	commit, err := r.CommitObject(first)
+	c.Assert(err, IsNil)
+	c.Assert(commit.ParentHashes, HasLen, 0)
+	c.Assert(commit.Message, Equals, "foo\n")
+
+	second, err := r.CommitChanges("bar\n", &CommitChangesOptions{
+		Author: defaultSignature(),
+		Changes: []FileChange{
+			{Path: "foo", Content: bytes.NewBufferString("modified")},
+			{Path: "qux/bar", Delete: true},
+		},
+	})
+	c.Assert(err, IsNil)
+
+	commit, err = r.CommitObject(second)
+	c.Assert(err, IsNil)
+	c.Assert(commit.ParentHashes, DeepEquals, []plumbing.Hash{first})
+	c.Assert(s.file(c, r, second, "foo"), Equals, "modified")
+	c.Assert(s.file(c, r, second, "qux/baz"), Equals, "baz")
+
+	_, err = commit.File("qux/bar")
+	c.Assert(err, Equals, object.ErrFileNotFound)
+
+	baz, err := commit.File("qux/baz")
+	c.Assert(err, IsNil)
+	c.Assert(baz.Mode, Equals, filemode.Executable)
+}
+
+func (s *CommitChangesSuite) TestBranchAndParent(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	first, err := r.CommitChanges("foo\n", &CommitChangesOptions{
+		Author:  defaultSignature(),
+		Changes: []FileChange{{Path: "foo", Content: strings.NewReader("foo")}},
+	})
+	c.Assert(err, IsNil)
+
+	branch := plumbing.ReferenceName("refs/heads/feature")
+	h, err := r.CommitChanges("bar\n", &CommitChangesOptions{
+		Author:  defaultSignature(),
+		Branch:  branch,
+		Parent:  first,
+		Changes: []FileChange{{Path: "bar", Content: strings.NewReader("bar")}},
+	})
+	c.Assert(err, IsNil)
+
+	ref, err := r.Reference(branch, false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, h)
+	c.Assert(s.file(c, r, h, "foo"), Equals, "foo")
+
+	// the branch no longer points to the parent
+	_, err = r.CommitChanges("qux\n", &CommitChangesOptions{
+		Author:  defaultSignature(),
+		Branch:  branch,
+		Parent:  first,
+		Changes: []FileChange{{Path: "qux", Content: strings.NewReader("qux")}},
+	})
+	c.Assert(err, NotNil)
+
+	ref, err = r.Reference(branch, false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, h)
+}
+
+func (s *CommitChangesSuite) TestEmptyCommit(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	first, err := r.CommitChanges("foo\n", &CommitChangesOptions{
+		Author:  defaultSignature(),
+		Changes: []FileChange{{Path: "foo", Content: strings.NewReader("foo")}},
+	})
+	c.Assert(err, IsNil)
+
+	opts := &CommitChangesOptions{
+		Author:  defaultSignature(),
+		Changes: []FileChange{{Path: "foo", Content: strings.NewReader("foo")}},
+	}
+
+	_, err = r.CommitChanges("bar\n", opts)
+	c.Assert(err, Equals, ErrEmptyCommit)
+
+	opts.AllowEmpty = true
+	opts.Changes = nil
+	h, err := r.CommitChanges("bar\n", opts)
+	c.Assert(err, IsNil)
+	c.Assert(h, Not(Equals), first)
+}
+
+func (s *CommitChangesSuite) TestFilesystem(c *C) {
+	r, err := PlainInit(c.MkDir(), true)
+	c.Assert(err, IsNil)
+
+	for _, content := range []string{"foo", "bar"} {
+		_, err := r.CommitChanges(content+"\n", &CommitChangesOptions{
+			Author:  defaultSignature(),
+			Changes: []FileChange{{Path: "foo", Content: strings.NewReader(content)}},
+		})
+		c.Assert(err, IsNil)
+	}
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(s.file(c, r, head.Hash(), "foo"), Equals, "bar")
+}
+
+func (s *CommitChangesSuite) TestValidate(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	_, err = r.CommitChanges("foo\n", &CommitChangesOptions{})
+	c.Assert(err, Equals, ErrMissingAuthor)
+
+	_, err = r.CommitChanges("foo\n", &CommitChangesOptions{
+		Author:  defaultSignature(),
+		Changes: []FileChange{{Path: "foo"}},
+	})
+	c.Assert(err, Equals, ErrMissingChangeContent)
+
+	_, err = r.CommitChanges("foo\n", &CommitChangesOptions{
+		Author:  defaultSignature(),
+		Changes: []FileChange{{Content: strings.NewReader("foo")}},
+	})
+	c.Assert(err, Equals, ErrMissingChangePath)
+
+	_, err = r.CommitChanges("foo\n", &CommitChangesOptions{
+		Author:  defaultSignature(),
+		Changes: []FileChange{{Path: "foo", Delete: true}},
+	})
+	c.Assert(err, Equals, object.ErrEntryNotFound)
+}
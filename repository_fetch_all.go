@@ -0,0 +1,131 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrFetchAllRemoteOptions = errors.New("RemoteName and RefSpecs can't be set fetching all the remotes")
+)
+
+// FetchAllOptions describes how the fetch of all the remotes should be
+// performed.
+type FetchAllOptions struct {
+	// Options are the options of the fetch of every remote. RemoteName and
+	// RefSpecs must be empty, every remote is fetched with its own fetch
+	// refspecs. The callbacks are never called concurrently, but the
+	// Progress of several remotes may be interleaved.
+	Options FetchOptions
+	// Workers is the number of remotes fetched concurrently, by default the
+	// remotes are fetched one by one. The negotiations with the remotes are
+	// run concurrently, while the accesses to the storage are serialized.
+	Workers int
+}
+
+// Validate validates the fields and sets the default values.
+func (o *FetchAllOptions) Validate() error {
+	if o.Options.RemoteName != "" || len(o.Options.RefSpecs) != 0 {
+		return ErrFetchAllRemoteOptions
+	}
+
+	if o.Workers < 1 {
+		o.Workers = 1
+	}
+
+	return nil
+}
+
+// FetchAllError is returned by FetchAll when the fetch of any remote fails.
+type FetchAllError struct {
+	// Errors are the errors of the failed fetches, by remote name.
+	Errors map[string]error
+}
+
+func (e *FetchAllError) Error() string {
+	var names []string
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	msgs := make([]string, len(names))
+	for i, name := range names {
+		msgs[i] = fmt.Sprintf("%s: %s", name, e.Errors[name])
+	}
+
+	return fmt.Sprintf("fetch failed for %s", strings.Join(msgs, ", "))
+}
+
+// FetchAll fetches all the remotes of the repository, see FetchAllContext.
+func (r *Repository) FetchAll(o *FetchAllOptions) (map[string]*FetchResult, error) {
+	return r.FetchAllContext(context.Background(), o)
+}
+
+// FetchAllContext fetches all the remotes of the repository, up to
+// FetchAllOptions.Workers at the same time, returning the result of every
+// fetch by remote name, as Remote.FetchWithResult does. If any fetch fails,
+// the rest are completed and a *FetchAllError is returned along with the
+// results.
+//
+// The provided Context must be non-nil. If the context expires before the
+// operation is complete, an error is returned. The context only affects to the
+// transport operations.
+func (r *Repository) FetchAllContext(ctx context.Context, o *FetchAllOptions) (map[string]*FetchResult, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	remotes, err := r.Remotes()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*FetchResult, len(remotes))
+	errs := make([]error, len(remotes))
+
+	var storageLock sync.Mutex
+	next := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(o.Workers)
+	for i := 0; i < o.Workers; i++ {
+		go func() {
+			defer wg.Done()
+			for j := range next {
+				opts := o.Options
+				opts.RemoteName = remotes[j].c.Name
+
+				remotes[j].storageLock = &storageLock
+				results[j], errs[j] = remotes[j].FetchWithResult(ctx, &opts)
+			}
+		}()
+	}
+
+	for i := range remotes {
+		next <- i
+	}
+
+	close(next)
+	wg.Wait()
+	r.runAutoGC(o.Options.Refetch)
+
+	byName := make(map[string]*FetchResult, len(remotes))
+	failed := make(map[string]error)
+	for i, remote := range remotes {
+		byName[remote.c.Name] = results[i]
+		if errs[i] != nil {
+			failed[remote.c.Name] = errs[i]
+		}
+	}
+
+	if len(failed) > 0 {
+		return byName, &FetchAllError{Errors: failed}
+	}
+
+	return byName, nil
+}
@@ -0,0 +1,65 @@
+package git
+
+import (
+	"path/filepath"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+type RepositoryFetchAllSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&RepositoryFetchAllSuite{})
+
+func (s *RepositoryFetchAllSuite) TestFetchAll(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	for _, name := range []string{"origin", "other"} {
+		_, err := r.CreateRemote(&config.RemoteConfig{
+			Name: name,
+			URLs: []string{s.GetBasicLocalRepositoryURL()},
+		})
+		c.Assert(err, IsNil)
+	}
+
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: "broken",
+		URLs: []string{filepath.Join(c.MkDir(), "missing")},
+	})
+	c.Assert(err, IsNil)
+
+	results, err := r.FetchAll(&FetchAllOptions{Workers: 2})
+	c.Assert(err, FitsTypeOf, &FetchAllError{})
+	c.Assert(err.(*FetchAllError).Errors, HasLen, 1)
+	c.Assert(err.(*FetchAllError).Errors["broken"], NotNil)
+
+	c.Assert(results, HasLen, 3)
+	for _, name := range []string{"origin", "other"} {
+		statuses := make(map[plumbing.ReferenceName]RefStatus)
+		for _, u := range results[name].Refs {
+			statuses[u.Name] = u.Status
+		}
+
+		master := plumbing.ReferenceName("refs/remotes/" + name + "/master")
+		c.Assert(statuses[master], Equals, RefCreated)
+	}
+
+	AssertReferences(c, r, map[string]string{
+		"refs/remotes/origin/master": "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"refs/remotes/other/master":  "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+	})
+}
+
+func (s *RepositoryFetchAllSuite) TestFetchAllRemoteOptions(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	_, err = r.FetchAll(&FetchAllOptions{Options: FetchOptions{RemoteName: "origin"}})
+	c.Assert(err, Equals, ErrFetchAllRemoteOptions)
+}
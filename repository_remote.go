@@ -0,0 +1,237 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// RenameRemote renames the remote oldName to newName, as `git remote rename`
+// does: the fetch refspecs and the branches tracking the remote are updated,
+// and the remote-tracking references under refs/remotes/<oldName>/ are moved
+// to refs/remotes/<newName>/.
+func (r *Repository) RenameRemote(oldName, newName string) error {
+	if newName == "" {
+		return config.ErrRemoteConfigEmptyName
+	}
+
+	cfg, err := r.Storer.Config()
+	if err != nil {
+		return err
+	}
+
+	c, ok := cfg.Remotes[oldName]
+	if !ok {
+		return ErrRemoteNotFound
+	}
+
+	if _, ok := cfg.Remotes[newName]; ok {
+		return ErrRemoteExists
+	}
+
+	oldPrefix := remoteRefsPrefix(oldName)
+	newPrefix := remoteRefsPrefix(newName)
+
+	for i, rs := range c.Fetch {
+		c.Fetch[i] = config.RefSpec(strings.Replace(
+			rs.String(), ":"+oldPrefix, ":"+newPrefix, 1,
+		))
+	}
+
+	c.Name = newName
+	delete(cfg.Remotes, oldName)
+	cfg.Remotes[newName] = c
+
+	for _, b := range cfg.Branches {
+		if b.Remote == oldName {
+			b.Remote = newName
+		}
+	}
+
+	if err := r.Storer.SetConfig(cfg); err != nil {
+		return err
+	}
+
+	return r.moveRemoteReferences(oldPrefix, newPrefix)
+}
+
+func remoteRefsPrefix(remote string) string {
+	return "refs/remotes/" + remote + "/"
+}
+
+// moveRemoteReferences renames the references with the prefix oldPrefix to
+// newPrefix, retargeting the symbolic ones pointing to them.
+func (r *Repository) moveRemoteReferences(oldPrefix, newPrefix string) error {
+	iter, err := r.Storer.IterReferences()
+	if err != nil {
+		return err
+	}
+
+	var refs []*plumbing.Reference
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if strings.HasPrefix(ref.Name().String(), oldPrefix) {
+			refs = append(refs, ref)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	rename := func(name plumbing.ReferenceName) plumbing.ReferenceName {
+		if !strings.HasPrefix(name.String(), oldPrefix) {
+			return name
+		}
+
+		return plumbing.ReferenceName(newPrefix + strings.TrimPrefix(name.String(), oldPrefix))
+	}
+
+	for _, ref := range refs {
+		var moved *plumbing.Reference
+		if ref.Type() == plumbing.SymbolicReference {
+			moved = plumbing.NewSymbolicReference(rename(ref.Name()), rename(ref.Target()))
+		} else {
+			moved = plumbing.NewHashReference(rename(ref.Name()), ref.Hash())
+		}
+
+		if err := r.Storer.SetReference(moved); err != nil {
+			return err
+		}
+
+		if err := r.Storer.RemoveReference(ref.Name()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetRemoteURLs sets the URLs of the remote, as `git remote set-url` does.
+// The first one is used by fetch, while push uses all of them unless the
+// remote has push URLs.
+func (r *Repository) SetRemoteURLs(name string, urls ...string) error {
+	if len(urls) == 0 {
+		return config.ErrRemoteConfigEmptyURL
+	}
+
+	return r.updateRemoteConfig(name, func(c *config.RemoteConfig) {
+		c.URLs = urls
+	})
+}
+
+// SetRemotePushURLs sets the URLs push uses instead of the URLs of the
+// remote, as `git remote set-url --push` does. Without urls, push uses the
+// URLs of the remote again.
+func (r *Repository) SetRemotePushURLs(name string, urls ...string) error {
+	return r.updateRemoteConfig(name, func(c *config.RemoteConfig) {
+		c.PushURLs = urls
+	})
+}
+
+// SetRemoteBranches sets the branches fetched from the remote, names or glob
+// patterns such as "feature/*", replacing its fetch refspecs, as `git remote
+// set-branches` does.
+func (r *Repository) SetRemoteBranches(name string, branches ...string) error {
+	if len(branches) == 0 {
+		return ErrMissingBranchNames
+	}
+
+	specs, err := remoteBranchesRefSpecs(name, branches)
+	if err != nil {
+		return err
+	}
+
+	return r.updateRemoteConfig(name, func(c *config.RemoteConfig) {
+		c.Fetch = specs
+	})
+}
+
+// AddRemoteBranches adds the branches to the ones fetched from the remote, as
+// `git remote set-branches --add` does, and fetches them, so a clone with
+// SingleBranch can follow other branches. Only the given branches are fetched,
+// and the branches already fetched by the refspecs of the remote are not added
+// again. Returns NoErrAlreadyUpToDate if the branches were already fetched.
+func (r *Repository) AddRemoteBranches(o *AddRemoteBranchesOptions) error {
+	return r.AddRemoteBranchesContext(context.Background(), o)
+}
+
+// AddRemoteBranchesContext adds the branches to the ones fetched from the
+// remote, and fetches them, see AddRemoteBranches.
+//
+// The provided Context must be non-nil. If the context expires before the
+// operation is complete, an error is returned. The context only affects to the
+// transport operations.
+func (r *Repository) AddRemoteBranchesContext(ctx context.Context, o *AddRemoteBranchesOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	specs, err := remoteBranchesRefSpecs(o.RemoteName, o.Branches)
+	if err != nil {
+		return err
+	}
+
+	err = r.updateRemoteConfig(o.RemoteName, func(c *config.RemoteConfig) {
+		for _, spec := range specs {
+			if !fetchesBranch(c.Fetch, spec) {
+				c.Fetch = append(c.Fetch, spec)
+			}
+		}
+	})
+	if err != nil || o.NoFetch {
+		return err
+	}
+
+	return r.FetchContext(ctx, &FetchOptions{
+		RemoteName: o.RemoteName,
+		RefSpecs:   specs,
+		Depth:      o.Depth,
+		Auth:       o.Auth,
+		Progress:   o.Progress,
+	})
+}
+
+func remoteBranchesRefSpecs(remote string, branches []string) ([]config.RefSpec, error) {
+	specs := make([]config.RefSpec, len(branches))
+	for i, b := range branches {
+		specs[i] = config.RefSpec(fmt.Sprintf(refspecSingleBranch, b, remote))
+		if err := specs[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return specs, nil
+}
+
+// fetchesBranch returns true if the refspec is already one of the refspecs,
+// or the branch it fetches is already fetched by them.
+func fetchesBranch(specs []config.RefSpec, spec config.RefSpec) bool {
+	src := plumbing.ReferenceName(spec.Src())
+	for _, s := range specs {
+		if s == spec || (!spec.IsWildcard() && s.Match(src)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Repository) updateRemoteConfig(name string, update func(*config.RemoteConfig)) error {
+	cfg, err := r.Storer.Config()
+	if err != nil {
+		return err
+	}
+
+	c, ok := cfg.Remotes[name]
+	if !ok {
+		return ErrRemoteNotFound
+	}
+
+	update(c)
+	return r.Storer.SetConfig(cfg)
+}
@@ -0,0 +1,181 @@
+package git
+
+import (
+	"context"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+type RepositoryRemoteSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&RepositoryRemoteSuite{})
+
+func (s *RepositoryRemoteSuite) TestRenameRemote(c *C) {
+	r, _ := Init(memory.NewStorage(), nil)
+	err := r.clone(context.Background(), &CloneOptions{
+		URL: s.GetBasicLocalRepositoryURL(),
+	})
+	c.Assert(err, IsNil)
+
+	err = r.RenameRemote("origin", "upstream")
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Remotes, HasLen, 1)
+	c.Assert(cfg.Remotes["upstream"].Name, Equals, "upstream")
+	c.Assert(cfg.Remotes["upstream"].Fetch, DeepEquals, []config.RefSpec{
+		"+refs/heads/*:refs/remotes/upstream/*",
+	})
+	c.Assert(cfg.Branches["master"].Remote, Equals, "upstream")
+
+	AssertReferences(c, r, map[string]string{
+		"refs/remotes/upstream/master": "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"refs/remotes/upstream/branch": "e8d3ffab552895c19b9fcf7aa264d277cde33881",
+	})
+
+	head, err := r.Reference("refs/remotes/upstream/HEAD", false)
+	c.Assert(err, IsNil)
+	c.Assert(head.Target(), Equals, plumbing.ReferenceName("refs/remotes/upstream/master"))
+
+	_, err = r.Reference("refs/remotes/origin/master", false)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	_, err = r.Remote("upstream")
+	c.Assert(err, IsNil)
+}
+
+func (s *RepositoryRemoteSuite) TestRenameRemoteErrors(c *C) {
+	r, _ := Init(memory.NewStorage(), nil)
+	for _, name := range []string{"foo", "bar"} {
+		_, err := r.CreateRemote(&config.RemoteConfig{
+			Name: name,
+			URLs: []string{"http://foo/foo.git"},
+		})
+		c.Assert(err, IsNil)
+	}
+
+	c.Assert(r.RenameRemote("qux", "baz"), Equals, ErrRemoteNotFound)
+	c.Assert(r.RenameRemote("foo", "bar"), Equals, ErrRemoteExists)
+	c.Assert(r.RenameRemote("foo", ""), Equals, config.ErrRemoteConfigEmptyName)
+}
+
+func (s *RepositoryRemoteSuite) TestSetRemoteURLs(c *C) {
+	r, _ := Init(memory.NewStorage(), nil)
+	_, err := r.CreateRemote(&config.RemoteConfig{
+		Name: "foo",
+		URLs: []string{"http://foo/foo.git"},
+	})
+	c.Assert(err, IsNil)
+
+	err = r.SetRemoteURLs("foo", "http://bar/foo.git", "http://qux/foo.git")
+	c.Assert(err, IsNil)
+	err = r.SetRemotePushURLs("foo", "ssh://bar/foo.git")
+	c.Assert(err, IsNil)
+
+	remote, err := r.Remote("foo")
+	c.Assert(err, IsNil)
+	c.Assert(remote.Config().URLs, DeepEquals, []string{"http://bar/foo.git", "http://qux/foo.git"})
+	c.Assert(remote.Config().PushURLs, DeepEquals, []string{"ssh://bar/foo.git"})
+	c.Assert(remote.String(), Equals, "foo\thttp://bar/foo.git (fetch)\nfoo\tssh://bar/foo.git (push)")
+
+	err = r.SetRemotePushURLs("foo")
+	c.Assert(err, IsNil)
+
+	remote, err = r.Remote("foo")
+	c.Assert(err, IsNil)
+	c.Assert(remote.Config().PushURLs, HasLen, 0)
+
+	c.Assert(r.SetRemoteURLs("foo"), Equals, config.ErrRemoteConfigEmptyURL)
+	c.Assert(r.SetRemoteURLs("bar", "http://bar/foo.git"), Equals, ErrRemoteNotFound)
+}
+
+func (s *RepositoryRemoteSuite) TestAddRemoteBranches(c *C) {
+	r, _ := Init(memory.NewStorage(), nil)
+	err := r.clone(context.Background(), &CloneOptions{
+		URL:          s.GetBasicLocalRepositoryURL(),
+		SingleBranch: true,
+	})
+	c.Assert(err, IsNil)
+
+	_, err = r.Reference("refs/remotes/origin/branch", false)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	err = r.AddRemoteBranches(&AddRemoteBranchesOptions{Branches: []string{"branch"}})
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Remotes["origin"].Fetch, DeepEquals, []config.RefSpec{
+		"+refs/heads/master:refs/remotes/origin/master",
+		"+refs/heads/branch:refs/remotes/origin/branch",
+	})
+
+	AssertReferences(c, r, map[string]string{
+		"refs/remotes/origin/master": "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"refs/remotes/origin/branch": "e8d3ffab552895c19b9fcf7aa264d277cde33881",
+	})
+
+	err = r.AddRemoteBranches(&AddRemoteBranchesOptions{Branches: []string{"branch", "master"}})
+	c.Assert(err, Equals, NoErrAlreadyUpToDate)
+
+	cfg, err = r.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Remotes["origin"].Fetch, HasLen, 2)
+}
+
+func (s *RepositoryRemoteSuite) TestAddRemoteBranchesNoFetch(c *C) {
+	r, _ := Init(memory.NewStorage(), nil)
+	_, err := r.CreateRemote(&config.RemoteConfig{
+		Name: "foo",
+		URLs: []string{"http://foo/foo.git"},
+	})
+	c.Assert(err, IsNil)
+
+	err = r.AddRemoteBranches(&AddRemoteBranchesOptions{
+		RemoteName: "foo",
+		Branches:   []string{"feature/*"},
+		NoFetch:    true,
+	})
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Remotes["foo"].Fetch, DeepEquals, []config.RefSpec{
+		"+refs/heads/*:refs/remotes/foo/*",
+		"+refs/heads/feature/*:refs/remotes/foo/feature/*",
+	})
+
+	err = r.AddRemoteBranches(&AddRemoteBranchesOptions{RemoteName: "foo"})
+	c.Assert(err, Equals, ErrMissingBranchNames)
+
+	err = r.AddRemoteBranches(&AddRemoteBranchesOptions{RemoteName: "bar", Branches: []string{"qux"}})
+	c.Assert(err, Equals, ErrRemoteNotFound)
+}
+
+func (s *RepositoryRemoteSuite) TestSetRemoteBranches(c *C) {
+	r, _ := Init(memory.NewStorage(), nil)
+	_, err := r.CreateRemote(&config.RemoteConfig{
+		Name: "foo",
+		URLs: []string{"http://foo/foo.git"},
+	})
+	c.Assert(err, IsNil)
+
+	err = r.SetRemoteBranches("foo", "master", "feature/*")
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Remotes["foo"].Fetch, DeepEquals, []config.RefSpec{
+		"+refs/heads/master:refs/remotes/foo/master",
+		"+refs/heads/feature/*:refs/remotes/foo/feature/*",
+	})
+
+	c.Assert(r.SetRemoteBranches("foo"), Equals, ErrMissingBranchNames)
+}
@@ -0,0 +1,55 @@
+package git
+
+import (
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// SymbolicReference returns the target of the symbolic reference with the
+// given name, as `git symbolic-ref` does, such as refs/remotes/origin/master
+// for refs/remotes/origin/HEAD. ErrNotSymbolicReference is returned if the
+// reference points to a hash.
+func (r *Repository) SymbolicReference(name plumbing.ReferenceName) (plumbing.ReferenceName, error) {
+	ref, err := r.Storer.Reference(name)
+	if err != nil {
+		return "", err
+	}
+
+	if ref.Type() != plumbing.SymbolicReference {
+		return "", ErrNotSymbolicReference
+	}
+
+	return ref.Target(), nil
+}
+
+// SetSymbolicReference makes the reference with the given name point to the
+// target, as `git symbolic-ref <name> <target>` does, replacing the reference
+// if it exists. Both the name, unless it is HEAD, and the target must be
+// below refs/; the target doesn't need to exist.
+func (r *Repository) SetSymbolicReference(name, target plumbing.ReferenceName) error {
+	if !isRefsName(target) || (name != plumbing.HEAD && !isRefsName(name)) {
+		return ErrInvalidSymbolicReference
+	}
+
+	return r.Storer.SetReference(plumbing.NewSymbolicReference(name, target))
+}
+
+// DeleteSymbolicReference deletes the symbolic reference with the given name,
+// as `git symbolic-ref --delete` does. ErrNotSymbolicReference is returned if
+// the reference points to a hash, and ErrDeleteHEAD for HEAD.
+func (r *Repository) DeleteSymbolicReference(name plumbing.ReferenceName) error {
+	if name == plumbing.HEAD {
+		return ErrDeleteHEAD
+	}
+
+	if _, err := r.SymbolicReference(name); err != nil {
+		return err
+	}
+
+	return r.Storer.RemoveReference(name)
+}
+
+func isRefsName(name plumbing.ReferenceName) bool {
+	return strings.HasPrefix(string(name), "refs/") && len(name) > len("refs/")
+}
@@ -0,0 +1,94 @@
+package git
+
+import (
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	. "gopkg.in/check.v1"
+)
+
+type SymbolicReferenceSuite struct {
+	BaseSuite
+	r *Repository
+}
+
+var _ = Suite(&SymbolicReferenceSuite{})
+
+func (s *SymbolicReferenceSuite) SetUpTest(c *C) {
+	var err error
+	s.r, err = PlainInit(c.MkDir(), true)
+	c.Assert(err, IsNil)
+
+	_, err = s.r.CommitChanges("foo\n", &CommitChangesOptions{
+		Branch:  "refs/heads/master",
+		Author:  defaultSignature(),
+		Changes: []FileChange{{Path: "foo", Content: strings.NewReader("foo")}},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *SymbolicReferenceSuite) TestSetSymbolicReference(c *C) {
+	name := plumbing.ReferenceName("refs/remotes/origin/HEAD")
+	err := s.r.SetSymbolicReference(name, "refs/remotes/origin/master")
+	c.Assert(err, IsNil)
+
+	target, err := s.r.SymbolicReference(name)
+	c.Assert(err, IsNil)
+	c.Assert(target, Equals, plumbing.ReferenceName("refs/remotes/origin/master"))
+
+	err = s.r.SetSymbolicReference(name, "refs/remotes/origin/develop")
+	c.Assert(err, IsNil)
+
+	target, err = s.r.SymbolicReference(name)
+	c.Assert(err, IsNil)
+	c.Assert(target, Equals, plumbing.ReferenceName("refs/remotes/origin/develop"))
+}
+
+func (s *SymbolicReferenceSuite) TestSetSymbolicReferenceHEAD(c *C) {
+	err := s.r.SetSymbolicReference(plumbing.HEAD, "refs/heads/develop")
+	c.Assert(err, IsNil)
+
+	target, err := s.r.SymbolicReference(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(target, Equals, plumbing.ReferenceName("refs/heads/develop"))
+}
+
+func (s *SymbolicReferenceSuite) TestSetSymbolicReferenceInvalid(c *C) {
+	for _, ref := range [][2]plumbing.ReferenceName{
+		{"FOO", "refs/heads/master"},
+		{"refs/", "refs/heads/master"},
+		{"refs/heads/foo", "master"},
+		{"refs/heads/foo", "HEAD"},
+	} {
+		err := s.r.SetSymbolicReference(ref[0], ref[1])
+		c.Assert(err, Equals, ErrInvalidSymbolicReference, Commentf("%s -> %s", ref[0], ref[1]))
+	}
+}
+
+func (s *SymbolicReferenceSuite) TestSymbolicReferenceNotSymbolic(c *C) {
+	_, err := s.r.SymbolicReference("refs/heads/master")
+	c.Assert(err, Equals, ErrNotSymbolicReference)
+
+	_, err = s.r.SymbolicReference("refs/heads/foo")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *SymbolicReferenceSuite) TestDeleteSymbolicReference(c *C) {
+	name := plumbing.ReferenceName("refs/remotes/origin/HEAD")
+	c.Assert(s.r.SetSymbolicReference(name, "refs/heads/master"), IsNil)
+	c.Assert(s.r.DeleteSymbolicReference(name), IsNil)
+
+	_, err := s.r.Storer.Reference(name)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	// the target is kept
+	_, err = s.r.Storer.Reference("refs/heads/master")
+	c.Assert(err, IsNil)
+}
+
+func (s *SymbolicReferenceSuite) TestDeleteSymbolicReferenceErrors(c *C) {
+	c.Assert(s.r.DeleteSymbolicReference(plumbing.HEAD), Equals, ErrDeleteHEAD)
+	c.Assert(s.r.DeleteSymbolicReference("refs/heads/master"), Equals, ErrNotSymbolicReference)
+	c.Assert(s.r.DeleteSymbolicReference("refs/heads/foo"), Equals, plumbing.ErrReferenceNotFound)
+}
@@ -0,0 +1,284 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+var (
+	// ErrNotAnnotatedTag is returned by VerifyTag given a lightweight tag,
+	// which can't be signed.
+	ErrNotAnnotatedTag = errors.New("tag is not annotated")
+)
+
+// VerifyTag verifies the signature of the annotated tag with the given short
+// name, e.g. v1.0.0, against the keys trusted by the Verifier, as git
+// verify-tag does. NewOpenPGPVerifier can be used to verify it against an
+// OpenPGP keyring. ErrTagNotFound is returned if the tag doesn't exist,
+// ErrNotAnnotatedTag if it's a lightweight tag and object.ErrUnsignedObject if
+// it isn't signed.
+func (r *Repository) VerifyTag(name string, v object.Verifier) (*object.VerificationResult, error) {
+	ref, err := r.Storer.Reference(plumbing.ReferenceName("refs/tags/" + name))
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, ErrTagNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	tag, err := r.TagObject(ref.Hash())
+	if err == plumbing.ErrObjectNotFound {
+		return nil, ErrNotAnnotatedTag
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tag.VerifySignature(v)
+}
+
+// ReplaceTag creates a tag as CreateTag does, replacing the existing tag with
+// the same name, if any, as git tag --force does. The tag is annotated if the
+// options have a message, lightweight otherwise. The replacement is recorded
+// at the reflog of the tag.
+func (r *Repository) ReplaceTag(name string, hash plumbing.Hash, opts *ReplaceTagOptions) (*plumbing.Reference, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	rname := plumbing.ReferenceName("refs/tags/" + name)
+
+	var old plumbing.Hash
+	ref, err := r.Storer.Reference(rname)
+	switch err {
+	case nil:
+		old = ref.Hash()
+	case plumbing.ErrReferenceNotFound:
+	default:
+		return nil, err
+	}
+
+	target := hash
+	if opts.Message != "" {
+		target, err = r.createTagObject(name, hash, &CreateTagOptions{
+			Tagger:  opts.Tagger,
+			Message: opts.Message,
+			Signer:  opts.Signer,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ref = plumbing.NewHashReference(rname, target)
+	if err = r.Storer.SetReference(ref); err != nil {
+		return nil, err
+	}
+
+	err = appendReflog(r, rname, &reflogEntry{
+		old:     old,
+		new:     target,
+		message: r.tagReflogMessage(hash),
+	}, opts.Tagger)
+	if err != nil {
+		return nil, err
+	}
+
+	return ref, nil
+}
+
+// tagReflogMessage returns the reflog message of tagging the object, as git
+// tag does.
+func (r *Repository) tagReflogMessage(h plumbing.Hash) string {
+	msg := "tag: tagging " + h.String()[:7]
+	if c, err := r.CommitObject(h); err == nil {
+		msg += fmt.Sprintf(" (%s, %s)", commitSubject(c.Message), c.Committer.When.Format("2006-01-02"))
+	}
+
+	return msg
+}
+
+// TagSort is the order of the tags listed by Repository.ListTags.
+type TagSort int
+
+const (
+	// TagSortName sorts the tags by name.
+	TagSortName TagSort = iota
+	// TagSortCreatorDate sorts the tags by creation date, oldest first, the
+	// date of the annotated tags being the date of the tagger and the date
+	// of the lightweight tags the date of the committer of their commit, as
+	// git tag --sort=creatordate does.
+	TagSortCreatorDate
+	// TagSortVersion sorts the tags by name, the numbers at the names being
+	// compared by their value, e.g. v1.9 before v1.10, as git tag
+	// --sort=version:refname does.
+	TagSortVersion
+)
+
+// TagKind are the kinds of tags listed by Repository.ListTags.
+type TagKind int
+
+const (
+	// AnyTag lists the annotated and the lightweight tags.
+	AnyTag TagKind = iota
+	// AnnotatedTag lists only the tags pointing to a tag object.
+	AnnotatedTag
+	// LightweightTag lists only the tags pointing to any other object.
+	LightweightTag
+)
+
+// ListTags returns the tags of the given kind, sorted as the options say.
+func (r *Repository) ListTags(o *ListTagsOptions) (storer.ReferenceIter, error) {
+	if o == nil {
+		o = &ListTagsOptions{}
+	}
+
+	iter, err := r.Tags()
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []*plumbing.Reference
+	dates := make(map[plumbing.ReferenceName]time.Time)
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+
+		date, annotated, err := r.tagDate(ref.Hash())
+		if err != nil {
+			return err
+		}
+
+		if o.Kind == AnnotatedTag && !annotated || o.Kind == LightweightTag && annotated {
+			return nil
+		}
+
+		tags = append(tags, ref)
+		dates[ref.Name()] = date
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	less := func(a, b *plumbing.Reference) bool {
+		return a.Name() < b.Name()
+	}
+
+	switch o.Sort {
+	case TagSortCreatorDate:
+		less = func(a, b *plumbing.Reference) bool {
+			da, db := dates[a.Name()], dates[b.Name()]
+			if !da.Equal(db) {
+				return da.Before(db)
+			}
+
+			return a.Name() < b.Name()
+		}
+	case TagSortVersion:
+		less = func(a, b *plumbing.Reference) bool {
+			return versionLess(a.Name().String(), b.Name().String())
+		}
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		if o.Reverse {
+			i, j = j, i
+		}
+
+		return less(tags[i], tags[j])
+	})
+
+	return storer.NewReferenceSliceIter(tags), nil
+}
+
+// tagDate returns the creation date of the tag pointing to the object, and
+// whether the object is a tag object. The date of the tags pointing to
+// neither a tag object nor a commit is zero.
+func (r *Repository) tagDate(h plumbing.Hash) (time.Time, bool, error) {
+	obj, err := r.Storer.EncodedObject(plumbing.AnyObject, h)
+	if err == plumbing.ErrObjectNotFound {
+		return time.Time{}, false, nil
+	}
+
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	switch obj.Type() {
+	case plumbing.TagObject:
+		t, err := object.DecodeTag(r.Storer, obj)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+
+		return t.Tagger.When, true, nil
+	case plumbing.CommitObject:
+		c, err := object.DecodeCommit(r.Storer, obj)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+
+		return c.Committer.When, false, nil
+	default:
+		return time.Time{}, false, nil
+	}
+}
+
+// versionLess compares the strings as versions, the runs of digits being
+// compared by their numeric value.
+func versionLess(a, b string) bool {
+	for a != "" && b != "" {
+		if isDigit(a[0]) && isDigit(b[0]) {
+			na, ra := splitDigits(a)
+			nb, rb := splitDigits(b)
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+
+			if na != nb {
+				return na < nb
+			}
+
+			a, b = ra, rb
+			continue
+		}
+
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+
+		a, b = a[1:], b[1:]
+	}
+
+	return len(a) < len(b)
+}
+
+// splitDigits splits the leading run of digits of s, without its leading
+// zeros, from the rest.
+func splitDigits(s string) (digits, rest string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+
+	digits = s[:i]
+	for len(digits) > 1 && digits[0] == '0' {
+		digits = digits[1:]
+	}
+
+	return digits, s[i:]
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
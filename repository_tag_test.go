@@ -0,0 +1,127 @@
+package git
+
+import (
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+
+	. "gopkg.in/check.v1"
+)
+
+type RepositoryTagSuite struct {
+	BaseSuite
+	r       *Repository
+	commits []plumbing.Hash
+}
+
+var _ = Suite(&RepositoryTagSuite{})
+
+func (s *RepositoryTagSuite) SetUpTest(c *C) {
+	var err error
+	s.r, err = PlainInit(c.MkDir(), true)
+	c.Assert(err, IsNil)
+
+	s.commits = nil
+	for i, name := range []string{"foo", "bar"} {
+		h, err := s.r.CommitChanges(name+"\n", &CommitChangesOptions{
+			Branch:  "refs/heads/master",
+			Author:  s.signature(i),
+			Changes: []FileChange{{Path: name, Content: strings.NewReader(name)}},
+		})
+		c.Assert(err, IsNil)
+		s.commits = append(s.commits, h)
+	}
+}
+
+func (s *RepositoryTagSuite) signature(hours int) *object.Signature {
+	return &object.Signature{
+		Name:  "foo",
+		Email: "foo@foo.foo",
+		When:  time.Unix(1500000000+int64(hours)*3600, 0).UTC(),
+	}
+}
+
+func (s *RepositoryTagSuite) TestReplaceTag(c *C) {
+	_, err := s.r.ReplaceTag("v1.0.0", s.commits[0], &ReplaceTagOptions{})
+	c.Assert(err, Equals, ErrMissingTagger)
+
+	ref, err := s.r.ReplaceTag("v1.0.0", s.commits[0], &ReplaceTagOptions{Tagger: s.signature(2)})
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, s.commits[0])
+
+	ref, err = s.r.ReplaceTag("v1.0.0", s.commits[1], &ReplaceTagOptions{
+		Tagger:  s.signature(3),
+		Message: "bar",
+	})
+	c.Assert(err, IsNil)
+
+	tag, err := s.r.TagObject(ref.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(tag.Target, Equals, s.commits[1])
+
+	stored, err := s.r.Storer.Reference("refs/tags/v1.0.0")
+	c.Assert(err, IsNil)
+	c.Assert(stored.Hash(), Equals, tag.Hash)
+
+	entries, err := readReflog(s.r, "refs/tags/v1.0.0")
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	c.Assert(entries[0].old, Equals, plumbing.ZeroHash)
+	c.Assert(entries[0].new, Equals, s.commits[0])
+	c.Assert(entries[0].message, Equals, "tag: tagging "+s.commits[0].String()[:7]+" (foo, 2017-07-14)")
+	c.Assert(entries[1].old, Equals, s.commits[0])
+	c.Assert(entries[1].new, Equals, tag.Hash)
+}
+
+func (s *RepositoryTagSuite) TestListTags(c *C) {
+	_, err := s.r.CreateTag("v1.10", s.commits[0], nil)
+	c.Assert(err, IsNil)
+	_, err = s.r.CreateTag("v1.9", s.commits[1], nil)
+	c.Assert(err, IsNil)
+	_, err = s.r.CreateTag("v1.2", s.commits[1], &CreateTagOptions{
+		Tagger:  s.signature(-1),
+		Message: "foo",
+	})
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		opts     *ListTagsOptions
+		expected []string
+	}{
+		{nil, []string{"v1.10", "v1.2", "v1.9"}},
+		{&ListTagsOptions{Sort: TagSortVersion}, []string{"v1.2", "v1.9", "v1.10"}},
+		{&ListTagsOptions{Sort: TagSortVersion, Reverse: true}, []string{"v1.10", "v1.9", "v1.2"}},
+		{&ListTagsOptions{Sort: TagSortCreatorDate}, []string{"v1.2", "v1.10", "v1.9"}},
+		{&ListTagsOptions{Kind: AnnotatedTag}, []string{"v1.2"}},
+		{&ListTagsOptions{Kind: LightweightTag}, []string{"v1.10", "v1.9"}},
+	} {
+		iter, err := s.r.ListTags(t.opts)
+		c.Assert(err, IsNil)
+
+		var names []string
+		err = iter.ForEach(func(ref *plumbing.Reference) error {
+			names = append(names, ref.Name().Short())
+			return nil
+		})
+		c.Assert(err, IsNil)
+		c.Assert(names, DeepEquals, t.expected, Commentf("%+v", t.opts))
+	}
+}
+
+func (s *RepositoryTagSuite) TestVersionLess(c *C) {
+	for _, t := range []struct {
+		a, b     string
+		expected bool
+	}{
+		{"v1.9", "v1.10", true},
+		{"v1.10", "v1.9", false},
+		{"v1.09", "v1.10", true},
+		{"v1.0", "v1.0.1", true},
+		{"v1.0", "v1.0", false},
+		{"v1.0-rc1", "v1.0-rc2", true},
+	} {
+		c.Assert(versionLess(t.a, t.b), Equals, t.expected, Commentf("%s < %s", t.a, t.b))
+	}
+}
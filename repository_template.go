@@ -0,0 +1,131 @@
+package git
+
+import (
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+)
+
+// copyTemplates copies the template directory of a new repository into its
+// git directory, if the storage is based on a filesystem.
+func copyTemplates(r *Repository, dir string) error {
+	dot, isFSBased := storerFilesystem(r)
+	if !isFSBased {
+		return nil
+	}
+
+	dir, err := templateDir(r, dir)
+	if err != nil || dir == "" {
+		return err
+	}
+
+	src := osfs.New(dir)
+	if _, err := src.Stat(""); os.IsNotExist(err) {
+		return nil
+	}
+
+	return copyTemplateDir(src, dot, "")
+}
+
+// templateDir returns the template directory of a new repository: the given
+// one, or for the repositories at the OS filesystem the one at the
+// GIT_TEMPLATE_DIR environment variable, or at the init.templateDir option of
+// the config of the user and of the system, as git does. It's empty if none
+// is set.
+func templateDir(r *Repository, dir string) (string, error) {
+	if dir == "" && isOSStorage(r) {
+		dir = os.Getenv("GIT_TEMPLATE_DIR")
+		if dir == "" {
+			cfg, err := r.ConfigScoped(config.SystemScope)
+			if err != nil {
+				return "", err
+			}
+
+			dir = cfg.Init.TemplateDir
+		}
+	}
+
+	if strings.HasPrefix(dir, "~/") {
+		usr, err := user.Current()
+		if err != nil {
+			return "", err
+		}
+
+		dir = filepath.Join(usr.HomeDir, dir[2:])
+	}
+
+	return dir, nil
+}
+
+// copyTemplateDir copies the directory at path of src, recursively, skipping
+// the files starting with a dot and the ones already existing at dst.
+func copyTemplateDir(src, dst billy.Filesystem, path string) error {
+	files, err := src.ReadDir(path)
+	if err != nil {
+		return err
+	}
+
+	for _, fi := range files {
+		if strings.HasPrefix(fi.Name(), ".") {
+			continue
+		}
+
+		name := src.Join(path, fi.Name())
+		switch {
+		case fi.IsDir():
+			if err := dst.MkdirAll(name, 0755); err != nil {
+				return err
+			}
+
+			if err := copyTemplateDir(src, dst, name); err != nil {
+				return err
+			}
+		case fi.Mode()&os.ModeSymlink != 0:
+			target, err := src.Readlink(name)
+			if err != nil {
+				return err
+			}
+
+			if err := dst.Symlink(target, name); err != nil && !os.IsExist(err) {
+				return err
+			}
+		default:
+			if err := copyTemplateFile(src, dst, name, fi.Mode()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func copyTemplateFile(src, dst billy.Filesystem, name string, mode os.FileMode) (err error) {
+	in, err := src.Open(name)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(in, &err)
+
+	out, err := dst.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode.Perm())
+	if os.IsExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(out, &err)
+
+	_, err = io.Copy(out, in)
+	return err
+}
@@ -0,0 +1,88 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	. "gopkg.in/check.v1"
+)
+
+type TemplateSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&TemplateSuite{})
+
+func (s *TemplateSuite) newTemplateDir(c *C) string {
+	dir := c.MkDir()
+	files := map[string]string{
+		"description":      "my repository\n",
+		"info/exclude":     "*.log\n",
+		"hooks/pre-commit": "#!/bin/sh\nexit 0\n",
+		".hidden":          "foo\n",
+		"config":           "[user]\n\tname = foo\n",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		c.Assert(os.MkdirAll(filepath.Dir(path), 0755), IsNil)
+		c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+	}
+
+	c.Assert(os.Chmod(filepath.Join(dir, "hooks", "pre-commit"), 0755), IsNil)
+	return dir
+}
+
+func (s *TemplateSuite) TestPlainInitTemplateDir(c *C) {
+	template := s.newTemplateDir(c)
+	dir := c.MkDir()
+
+	r, err := PlainInitWithOptions(dir, &PlainInitOptions{
+		InitOptions: InitOptions{TemplateDir: template},
+	})
+	c.Assert(err, IsNil)
+
+	dot := filepath.Join(dir, GitDirName)
+	b, err := ioutil.ReadFile(filepath.Join(dot, "description"))
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "my repository\n")
+
+	b, err = ioutil.ReadFile(filepath.Join(dot, "info", "exclude"))
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "*.log\n")
+
+	fi, err := os.Stat(filepath.Join(dot, "hooks", "pre-commit"))
+	c.Assert(err, IsNil)
+	if runtime.GOOS != "windows" {
+		c.Assert(fi.Mode()&0111, Not(Equals), os.FileMode(0))
+	}
+
+	_, err = os.Stat(filepath.Join(dot, ".hidden"))
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Raw.Section("user").Option("name"), Equals, "foo")
+	c.Assert(cfg.Core.IsBare, Equals, false)
+}
+
+func (s *TemplateSuite) TestPlainInitTemplateDirEnv(c *C) {
+	defer os.Setenv("GIT_TEMPLATE_DIR", os.Getenv("GIT_TEMPLATE_DIR"))
+	os.Setenv("GIT_TEMPLATE_DIR", s.newTemplateDir(c))
+
+	dir := c.MkDir()
+	_, err := PlainInit(dir, true)
+	c.Assert(err, IsNil)
+
+	_, err = os.Stat(filepath.Join(dir, "description"))
+	c.Assert(err, IsNil)
+}
+
+func (s *TemplateSuite) TestPlainInitTemplateDirMissing(c *C) {
+	_, err := PlainInitWithOptions(c.MkDir(), &PlainInitOptions{
+		InitOptions: InitOptions{TemplateDir: filepath.Join(c.MkDir(), "missing")},
+	})
+	c.Assert(err, IsNil)
+}
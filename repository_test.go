@@ -44,6 +44,29 @@ func (s *RepositorySuite) TestInit(c *C) {
 	c.Assert(cfg.Core.IsBare, Equals, false)
 }
 
+func (s *RepositorySuite) TestInitWithOptions(c *C) {
+	r, err := InitWithOptions(memory.NewStorage(), memfs.New(), &InitOptions{
+		DefaultBranch: "main",
+	})
+	c.Assert(err, IsNil)
+
+	head, err := r.Reference(plumbing.HEAD, false)
+	c.Assert(err, IsNil)
+	c.Assert(head.Target(), Equals, plumbing.ReferenceName("refs/heads/main"))
+}
+
+func (s *RepositorySuite) TestInitWithOptionsInvalid(c *C) {
+	_, err := InitWithOptions(memory.NewStorage(), nil, &InitOptions{
+		DefaultBranch: "foo..bar",
+	})
+	c.Assert(err, Equals, ErrInvalidDefaultBranch)
+
+	_, err = InitWithOptions(memory.NewStorage(), nil, &InitOptions{
+		ObjectFormat: "sha256",
+	})
+	c.Assert(err, Equals, ErrUnsupportedObjectFormat)
+}
+
 func (s *RepositorySuite) TestInitNonStandardDotGit(c *C) {
 	dir, err := ioutil.TempDir("", "init-non-standard")
 	c.Assert(err, IsNil)
@@ -153,6 +176,23 @@ func (s *RepositorySuite) TestOpenMissingWorktree(c *C) {
 	c.Assert(r, IsNil)
 }
 
+func (s *RepositorySuite) TestOpenUnsupportedExtension(c *C) {
+	st := memory.NewStorage()
+
+	r, err := Init(st, nil)
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Raw.Section("core").SetOption("repositoryformatversion", "1")
+	cfg.Raw.Section("extensions").SetOption("foo", "bar")
+	c.Assert(st.SetConfig(cfg), IsNil)
+
+	r, err = Open(st, nil)
+	c.Assert(err, FitsTypeOf, &config.UnsupportedExtensionError{})
+	c.Assert(r, IsNil)
+}
+
 func (s *RepositorySuite) TestOpenNotExists(c *C) {
 	r, err := Open(memory.NewStorage(), nil)
 	c.Assert(err, Equals, ErrRepositoryNotExists)
@@ -200,7 +240,54 @@ func (s *RepositorySuite) TestCloneWithTags(c *C) {
 	var count int
 	i.ForEach(func(r *plumbing.Reference) error { count++; return nil })
 
-	c.Assert(count, Equals, 3)
+	// HEAD, refs/heads/master, refs/remotes/origin/master and the
+	// refs/remotes/origin/HEAD pointing to it
+	c.Assert(count, Equals, 4)
+
+	ref, err := r.Reference("refs/remotes/origin/HEAD", false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Target(), Equals, plumbing.ReferenceName("refs/remotes/origin/master"))
+}
+
+func (s *RepositorySuite) TestConfigScoped(c *C) {
+	global := filepath.Join(c.MkDir(), "gitconfig")
+	err := ioutil.WriteFile(global, []byte("[url \"git@github.com:\"]\n\tinsteadOf = https://github.com/\n"), 0644)
+	c.Assert(err, IsNil)
+
+	defer os.Setenv("GIT_CONFIG_GLOBAL", os.Getenv("GIT_CONFIG_GLOBAL"))
+	defer os.Setenv("GIT_CONFIG_NOSYSTEM", os.Getenv("GIT_CONFIG_NOSYSTEM"))
+	os.Setenv("GIT_CONFIG_GLOBAL", global)
+	os.Setenv("GIT_CONFIG_NOSYSTEM", "true")
+
+	r, _ := Init(memory.NewStorage(), nil)
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{"https://github.com/git-fixtures/basic"},
+	})
+	c.Assert(err, IsNil)
+
+	cfg, err := r.ConfigScoped(config.LocalScope)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.URLs, HasLen, 0)
+
+	cfg, err = r.ConfigScoped(config.SystemScope)
+	c.Assert(err, IsNil)
+	c.Assert(cfg.URLs, HasLen, 1)
+	c.Assert(cfg.Remotes, HasLen, 1)
+
+	o := cfg.Raw.Section("url").Subsection("git@github.com:").Options[0]
+	c.Assert(cfg.Origin(o), Equals, config.Origin{Scope: config.GlobalScope, Path: global})
+
+	globalCfg, err := config.LoadConfig(config.GlobalScope)
+	c.Assert(err, IsNil)
+	globalCfg.URLs["git@github.com:"].InsteadOf = append(globalCfg.URLs["git@github.com:"].InsteadOf, "https://gitlab.com/")
+
+	err = r.SetConfigScoped(config.GlobalScope, globalCfg)
+	c.Assert(err, IsNil)
+
+	b, err := ioutil.ReadFile(global)
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "[url \"git@github.com:\"]\n\tinsteadOf = https://github.com/\n\tinsteadOf = https://gitlab.com/\n[core]\n\tbare = false\n")
 }
 
 func (s *RepositorySuite) TestCreateRemoteAndRemote(c *C) {
@@ -357,6 +444,63 @@ func (s *RepositorySuite) TestDeleteBranch(c *C) {
 	c.Assert(err, Equals, ErrBranchNotFound)
 }
 
+func (s *RepositorySuite) TestCreateTagLightweight(c *C) {
+	r, _ := Init(memory.NewStorage(), nil)
+	hash := plumbing.NewHash("b8e471f58bcbca63b07bda20e428190409c2db47")
+
+	ref, err := r.CreateTag("foo", hash, nil)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Name(), Equals, plumbing.ReferenceName("refs/tags/foo"))
+	c.Assert(ref.Hash(), Equals, hash)
+
+	_, err = r.CreateTag("foo", hash, nil)
+	c.Assert(err, Equals, ErrTagExists)
+}
+
+func (s *RepositorySuite) TestCreateTagAnnotated(c *C) {
+	r, _ := Init(memory.NewStorage(), memfs.New())
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	hash, err := commitTestFile(c, w, nil)
+	c.Assert(err, IsNil)
+
+	_, err = r.CreateTag("foo", hash, &CreateTagOptions{Message: "foo"})
+	c.Assert(err, Equals, ErrMissingTagger)
+
+	_, err = r.CreateTag("foo", hash, &CreateTagOptions{Tagger: defaultSignature()})
+	c.Assert(err, Equals, ErrMissingMessage)
+
+	ref, err := r.CreateTag("foo", hash, &CreateTagOptions{
+		Tagger:  defaultSignature(),
+		Message: "foo bar\n\n",
+	})
+	c.Assert(err, IsNil)
+
+	tag, err := r.TagObject(ref.Hash())
+	c.Assert(err, IsNil)
+	c.Assert(tag.Name, Equals, "foo")
+	c.Assert(tag.Message, Equals, "foo bar\n")
+	c.Assert(tag.TargetType, Equals, plumbing.CommitObject)
+	c.Assert(tag.Target, Equals, hash)
+	c.Assert(tag.PGPSignature, Equals, "")
+}
+
+func (s *RepositorySuite) TestDeleteTag(c *C) {
+	r, _ := Init(memory.NewStorage(), nil)
+	_, err := r.CreateTag("foo", plumbing.NewHash("b8e471f58bcbca63b07bda20e428190409c2db47"), nil)
+	c.Assert(err, IsNil)
+
+	err = r.DeleteTag("foo")
+	c.Assert(err, IsNil)
+
+	_, err = r.Reference(plumbing.ReferenceName("refs/tags/foo"), false)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	err = r.DeleteTag("foo")
+	c.Assert(err, Equals, ErrTagNotFound)
+}
+
 func (s *RepositorySuite) TestPlainInit(c *C) {
 	dir, err := ioutil.TempDir("", "plain-init")
 	c.Assert(err, IsNil)
@@ -371,6 +515,38 @@ func (s *RepositorySuite) TestPlainInit(c *C) {
 	c.Assert(cfg.Core.IsBare, Equals, true)
 }
 
+func (s *RepositorySuite) TestPlainInitDefaultBranch(c *C) {
+	global := filepath.Join(c.MkDir(), "gitconfig")
+	err := ioutil.WriteFile(global, []byte("[init]\n\tdefaultBranch = trunk\n"), 0644)
+	c.Assert(err, IsNil)
+
+	defer os.Setenv("GIT_CONFIG_GLOBAL", os.Getenv("GIT_CONFIG_GLOBAL"))
+	defer os.Setenv("GIT_CONFIG_NOSYSTEM", os.Getenv("GIT_CONFIG_NOSYSTEM"))
+	os.Setenv("GIT_CONFIG_GLOBAL", global)
+	os.Setenv("GIT_CONFIG_NOSYSTEM", "true")
+
+	r, err := PlainInit(c.MkDir(), false)
+	c.Assert(err, IsNil)
+
+	head, err := r.Reference(plumbing.HEAD, false)
+	c.Assert(err, IsNil)
+	c.Assert(head.Target(), Equals, plumbing.ReferenceName("refs/heads/trunk"))
+
+	r, err = PlainInitWithOptions(c.MkDir(), &PlainInitOptions{
+		InitOptions: InitOptions{DefaultBranch: "refs/heads/main"},
+		Bare:        true,
+	})
+	c.Assert(err, IsNil)
+
+	head, err = r.Reference(plumbing.HEAD, false)
+	c.Assert(err, IsNil)
+	c.Assert(head.Target(), Equals, plumbing.ReferenceName("refs/heads/main"))
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Core.IsBare, Equals, true)
+}
+
 func (s *RepositorySuite) TestPlainInitAlreadyExists(c *C) {
 	dir, err := ioutil.TempDir("", "plain-init")
 	c.Assert(err, IsNil)
@@ -564,6 +740,10 @@ func (s *RepositorySuite) TestPlainClone(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(cfg.Branches, HasLen, 1)
 	c.Assert(cfg.Branches["master"].Name, Equals, "master")
+
+	target, err := r.SymbolicReference("refs/remotes/origin/HEAD")
+	c.Assert(err, IsNil)
+	c.Assert(target, Equals, plumbing.ReferenceName("refs/remotes/origin/master"))
 }
 
 func (s *RepositorySuite) TestPlainCloneContext(c *C) {
@@ -737,6 +917,52 @@ func (s *RepositorySuite) TestCloneConfig(c *C) {
 	c.Assert(cfg.Branches["master"].Name, Equals, "master")
 }
 
+func (s *RepositorySuite) TestCloneMirror(c *C) {
+	r, _ := Init(memory.NewStorage(), nil)
+
+	err := r.clone(context.Background(), &CloneOptions{
+		URL:    s.GetBasicLocalRepositoryURL(),
+		Mirror: true,
+	})
+
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Core.IsBare, Equals, true)
+	c.Assert(cfg.Remotes["origin"].Mirror, Equals, true)
+	c.Assert(cfg.Remotes["origin"].Fetch, DeepEquals, []config.RefSpec{config.MirrorRefSpec})
+	c.Assert(cfg.Branches, HasLen, 0)
+
+	head, err := r.Reference(plumbing.HEAD, false)
+	c.Assert(err, IsNil)
+	c.Assert(head.Target().String(), Equals, "refs/heads/master")
+
+	AssertReferences(c, r, map[string]string{
+		"refs/heads/master": "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		"refs/heads/branch": "e8d3ffab552895c19b9fcf7aa264d277cde33881",
+		"refs/tags/v1.0.0":  "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+	})
+
+	// the fixture's own refs/remotes/origin/HEAD is mirrored verbatim, no
+	// symbolic reference is created on top of it
+	ref, err := r.Reference("refs/remotes/origin/HEAD", false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Type(), Equals, plumbing.HashReference)
+	c.Assert(ref.Hash().String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+}
+
+func (s *RepositorySuite) TestCloneMirrorWithWorktree(c *C) {
+	r, _ := Init(memory.NewStorage(), memfs.New())
+
+	err := r.clone(context.Background(), &CloneOptions{
+		URL:    s.GetBasicLocalRepositoryURL(),
+		Mirror: true,
+	})
+
+	c.Assert(err, Equals, ErrMirrorNotBare)
+}
+
 func (s *RepositorySuite) TestCloneSingleBranchAndNonHEAD(c *C) {
 	r, _ := Init(memory.NewStorage(), nil)
 
@@ -1070,6 +1296,29 @@ func (s *RepositorySuite) TestLog(c *C) {
 	c.Assert(err, Equals, io.EOF)
 }
 
+func (s *RepositorySuite) TestLogContextCancel(c *C) {
+	r, _ := Init(memory.NewStorage(), nil)
+	err := r.clone(context.Background(), &CloneOptions{
+		URL: s.GetBasicLocalRepositoryURL(),
+	})
+
+	c.Assert(err, IsNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cIter, err := r.LogContext(ctx, &LogOptions{})
+	c.Assert(err, IsNil)
+
+	_, err = cIter.Next()
+	c.Assert(err, IsNil)
+
+	cancel()
+	_, err = cIter.Next()
+	c.Assert(err, Equals, context.Canceled)
+
+	err = cIter.ForEach(func(*object.Commit) error { return nil })
+	c.Assert(err, Equals, context.Canceled)
+}
+
 func (s *RepositorySuite) TestLogHead(c *C) {
 	r, _ := Init(memory.NewStorage(), nil)
 	err := r.clone(context.Background(), &CloneOptions{
@@ -1163,6 +1412,105 @@ func (s *RepositorySuite) TestCommits(c *C) {
 	c.Assert(count, Equals, 9)
 }
 
+func (s *RepositorySuite) TestAheadBehind(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	commit := func(content string) plumbing.Hash {
+		err := util.WriteFile(w.Filesystem, "foo", []byte(content), 0644)
+		c.Assert(err, IsNil)
+
+		_, err = w.Add("foo")
+		c.Assert(err, IsNil)
+
+		h, err := w.Commit(content, &CommitOptions{Author: defaultSignature()})
+		c.Assert(err, IsNil)
+		return h
+	}
+
+	base := commit("base")
+	local := commit("local")
+
+	err = w.Checkout(&CheckoutOptions{Hash: base})
+	c.Assert(err, IsNil)
+
+	commit("upstream 1")
+	upstream := commit("upstream 2")
+
+	ahead, behind, err := r.AheadBehind(local, upstream)
+	c.Assert(err, IsNil)
+	c.Assert(ahead, Equals, 1)
+	c.Assert(behind, Equals, 2)
+
+	ahead, behind, err = r.AheadBehind(base, local)
+	c.Assert(err, IsNil)
+	c.Assert(ahead, Equals, 0)
+	c.Assert(behind, Equals, 1)
+
+	ahead, behind, err = r.AheadBehind(base, base)
+	c.Assert(err, IsNil)
+	c.Assert(ahead, Equals, 0)
+	c.Assert(behind, Equals, 0)
+
+	_, _, err = r.AheadBehind(base, plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"))
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+}
+
+func (s *RepositorySuite) TestForkPoint(c *C) {
+	r, err := PlainInit(c.MkDir(), false)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	commit := func(content string) plumbing.Hash {
+		err := util.WriteFile(w.Filesystem, "foo", []byte(content), 0644)
+		c.Assert(err, IsNil)
+
+		_, err = w.Add("foo")
+		c.Assert(err, IsNil)
+
+		h, err := w.Commit(content, &CommitOptions{Author: defaultSignature()})
+		c.Assert(err, IsNil)
+		return h
+	}
+
+	first := commit("first")
+	second := commit("second")
+	topic := commit("topic")
+
+	// master is rewritten after topic forked from it
+	err = w.Checkout(&CheckoutOptions{Hash: first})
+	c.Assert(err, IsNil)
+	rewritten := commit("rewritten")
+
+	ref := plumbing.NewHashReference(plumbing.Master, rewritten)
+	c.Assert(r.Storer.SetReference(ref), IsNil)
+
+	fp, err := r.ForkPoint(plumbing.Master, topic)
+	c.Assert(err, Equals, ErrNoForkPoint)
+	c.Assert(fp, IsNil)
+
+	dot, _ := storerFilesystem(r)
+	err = util.WriteFile(dot, "logs/refs/heads/master", []byte(
+		plumbing.ZeroHash.String()+" "+first.String()+" foo <foo@foo.foo> 1494000000 +0200\tcommit (initial): first\n"+
+			first.String()+" "+second.String()+" foo <foo@foo.foo> 1494000000 +0200\tcommit: second\n"+
+			second.String()+" "+rewritten.String()+" foo <foo@foo.foo> 1494000000 +0200\treset: moving to HEAD~1\n",
+	), 0644)
+	c.Assert(err, IsNil)
+
+	fp, err = r.ForkPoint(plumbing.Master, topic)
+	c.Assert(err, IsNil)
+	c.Assert(fp.Hash, Equals, second)
+
+	fp, err = r.ForkPoint(plumbing.Master, rewritten)
+	c.Assert(err, IsNil)
+	c.Assert(fp.Hash, Equals, rewritten)
+}
+
 func (s *RepositorySuite) TestBlob(c *C) {
 	r, _ := Init(memory.NewStorage(), nil)
 	err := r.clone(context.Background(), &CloneOptions{
@@ -1467,6 +1815,36 @@ func (s *RepositorySuite) TestWorktreeBare(c *C) {
 	c.Assert(w, IsNil)
 }
 
+func (s *RepositorySuite) TestNamespace(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	ns := r.Namespace("foo")
+	_, err = ns.Worktree()
+	c.Assert(err, Equals, ErrIsBareRepository)
+
+	h, err := ns.CommitChanges("foo\n", &CommitChangesOptions{
+		Author:  defaultSignature(),
+		Branch:  plumbing.Master,
+		Changes: []FileChange{{Path: "foo", Content: strings.NewReader("foo")}},
+	})
+	c.Assert(err, IsNil)
+
+	ref, err := ns.Reference(plumbing.Master, false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, h)
+
+	ref, err = r.Reference("refs/namespaces/foo/refs/heads/master", false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, h)
+
+	_, err = r.Reference(plumbing.Master, false)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	_, err = r.CommitObject(h)
+	c.Assert(err, IsNil)
+}
+
 func (s *RepositorySuite) TestResolveRevision(c *C) {
 	f := fixtures.ByURL("https://github.com/git-fixtures/basic.git").One()
 	sto, err := filesystem.NewStorage(f.DotGit())
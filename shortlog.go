@@ -0,0 +1,207 @@
+package git
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/mailmap"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+var (
+	// ErrInvalidRevisionRange is returned by Shortlog given a range with
+	// more than one ".." or "...".
+	ErrInvalidRevisionRange = errors.New("invalid revision range")
+)
+
+// ShortlogEntry are the commits of an author, or a committer, of a Shortlog.
+type ShortlogEntry struct {
+	// Name is the name of the author.
+	Name string
+	// Email is the email of the author, only set with ShortlogOptions.Email.
+	Email string
+	// Count is the number of commits of the author.
+	Count int
+	// Subjects are the subjects of the commits, oldest first, unless
+	// ShortlogOptions.Summary is set.
+	Subjects []string
+	// Commits are the hashes of the commits of Subjects.
+	Commits []plumbing.Hash
+}
+
+// Shortlog summarizes the commits of the range by author, as git shortlog
+// does, for release notes and contributor stats. The range is a revision,
+// the commits reachable from it, "<from>..<to>", the commits reachable from
+// to and not from from, or "<a>...<b>", the commits reachable from either
+// but not from both. An empty range, or side of a range, is HEAD.
+//
+// The authors are sorted by name, or by number of commits with
+// ShortlogOptions.Numbered.
+func (r *Repository) Shortlog(revRange string, o *ShortlogOptions) ([]*ShortlogEntry, error) {
+	if o == nil {
+		o = &ShortlogOptions{}
+	}
+
+	commits, err := r.rangeCommits(revRange)
+	if err != nil {
+		return nil, err
+	}
+
+	var m *mailmap.Mailmap
+	if o.UseMailmap {
+		if m, err = r.Mailmap(); err != nil {
+			return nil, err
+		}
+	}
+
+	var entries []*ShortlogEntry
+	byIdentity := make(map[[2]string]*ShortlogEntry)
+	// the commits are reported oldest first
+	for i := len(commits) - 1; i >= 0; i-- {
+		c := commits[i]
+		if o.NoMerges && c.NumParents() > 1 {
+			continue
+		}
+
+		sig := c.Author
+		if o.Committer {
+			sig = c.Committer
+		}
+
+		if m != nil {
+			sig = mailmapSignature(m, sig)
+		}
+
+		id := [2]string{sig.Name, ""}
+		if o.Email {
+			id[1] = sig.Email
+		}
+
+		e, ok := byIdentity[id]
+		if !ok {
+			e = &ShortlogEntry{Name: id[0], Email: id[1]}
+			byIdentity[id] = e
+			entries = append(entries, e)
+		}
+
+		e.Count++
+		if !o.Summary {
+			e.Subjects = append(e.Subjects, commitSubject(c.Message))
+			e.Commits = append(e.Commits, c.Hash)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if o.Numbered && a.Count != b.Count {
+			return a.Count > b.Count
+		}
+
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+
+		return a.Email < b.Email
+	})
+
+	return entries, nil
+}
+
+// rangeCommits returns the commits of the revision range, newest first by
+// committer time.
+func (r *Repository) rangeCommits(revRange string) ([]*object.Commit, error) {
+	var revs []string
+	symmetric := false
+	switch {
+	case strings.Contains(revRange, "..."):
+		revs = strings.Split(revRange, "...")
+		symmetric = true
+	case strings.Contains(revRange, ".."):
+		revs = strings.Split(revRange, "..")
+	default:
+		revs = []string{revRange}
+	}
+
+	if len(revs) > 2 {
+		return nil, ErrInvalidRevisionRange
+	}
+
+	tips := make([]*object.Commit, len(revs))
+	for i, rev := range revs {
+		if rev == "" {
+			rev = string(plumbing.HEAD)
+		}
+
+		h, err := r.ResolveRevision(plumbing.Revision(rev))
+		if err != nil {
+			return nil, err
+		}
+
+		if tips[i], err = r.CommitObject(*h); err != nil {
+			return nil, err
+		}
+	}
+
+	include, exclude := tips, []*object.Commit(nil)
+	if len(tips) == 2 && symmetric {
+		bases, err := tips[0].MergeBase(tips[1])
+		if err != nil {
+			return nil, err
+		}
+
+		exclude = bases
+	} else if len(tips) == 2 {
+		include, exclude = tips[1:], tips[:1]
+	}
+
+	// the commits reachable from the excluded ones are skipped
+	seen := make(map[plumbing.Hash]bool)
+	for _, c := range exclude {
+		if seen[c.Hash] {
+			continue
+		}
+
+		err := object.NewCommitPreorderIter(c, seen, nil).ForEach(func(c *object.Commit) error {
+			seen[c.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var commits []*object.Commit
+	for _, c := range include {
+		err := object.NewCommitPreorderIter(c, seen, nil).ForEach(func(c *object.Commit) error {
+			seen[c.Hash] = true
+			commits = append(commits, c)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(commits, func(i, j int) bool {
+		return commits[i].Committer.When.After(commits[j].Committer.When)
+	})
+
+	return commits, nil
+}
+
+// commitSubject returns the first paragraph of the message, in a single line.
+func commitSubject(msg string) string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimLeft(msg, "\n"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, " ")
+}
@@ -0,0 +1,156 @@
+package git
+
+import (
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type ShortlogSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&ShortlogSuite{})
+
+// commit commits the file with the given content by the given author, the
+// nth hour after the first commit.
+func (s *ShortlogSuite) commit(c *C, w *Worktree, n int, name, email, file, content, msg string) plumbing.Hash {
+	c.Assert(util.WriteFile(w.Filesystem, file, []byte(content), 0644), IsNil)
+	_, err := w.Add(file)
+	c.Assert(err, IsNil)
+
+	h, err := w.Commit(msg, &CommitOptions{Author: &object.Signature{
+		Name:  name,
+		Email: email,
+		When:  time.Unix(1500000000+int64(n)*3600, 0),
+	}})
+	c.Assert(err, IsNil)
+	return h
+}
+
+func (s *ShortlogSuite) repository(c *C) (*Repository, *Worktree, []plumbing.Hash) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	hashes := []plumbing.Hash{
+		s.commit(c, w, 0, "foo", "foo@foo.foo", "foo", "foo\n", "foo\n"),
+		s.commit(c, w, 1, "bar", "bar@bar.bar", "bar", "bar\n", "bar\nbar\n\nbody\n"),
+		s.commit(c, w, 2, "foo", "foo@qux.qux", "foo", "qux\n", "qux\n"),
+		s.commit(c, w, 3, "Foo", "foo@foo.foo", ".mailmap",
+			"Foo <foo@foo.foo>\nFoo <foo@foo.foo> <foo@qux.qux>\n", "mailmap\n"),
+	}
+
+	return r, w, hashes
+}
+
+func (s *ShortlogSuite) TestShortlog(c *C) {
+	r, _, hashes := s.repository(c)
+
+	entries, err := r.Shortlog("", nil)
+	c.Assert(err, IsNil)
+	c.Assert(entries, DeepEquals, []*ShortlogEntry{{
+		Name:     "Foo",
+		Count:    1,
+		Subjects: []string{"mailmap"},
+		Commits:  hashes[3:4],
+	}, {
+		Name:     "bar",
+		Count:    1,
+		Subjects: []string{"bar bar"},
+		Commits:  hashes[1:2],
+	}, {
+		Name:     "foo",
+		Count:    2,
+		Subjects: []string{"foo", "qux"},
+		Commits:  []plumbing.Hash{hashes[0], hashes[2]},
+	}})
+}
+
+func (s *ShortlogSuite) TestShortlogEmail(c *C) {
+	r, _, _ := s.repository(c)
+
+	entries, err := r.Shortlog("", &ShortlogOptions{Email: true, Summary: true})
+	c.Assert(err, IsNil)
+	c.Assert(entries, DeepEquals, []*ShortlogEntry{
+		{Name: "Foo", Email: "foo@foo.foo", Count: 1},
+		{Name: "bar", Email: "bar@bar.bar", Count: 1},
+		{Name: "foo", Email: "foo@foo.foo", Count: 1},
+		{Name: "foo", Email: "foo@qux.qux", Count: 1},
+	})
+}
+
+func (s *ShortlogSuite) TestShortlogMailmap(c *C) {
+	r, _, _ := s.repository(c)
+
+	entries, err := r.Shortlog("", &ShortlogOptions{
+		Email:      true,
+		Summary:    true,
+		Numbered:   true,
+		UseMailmap: true,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(entries, DeepEquals, []*ShortlogEntry{
+		{Name: "Foo", Email: "foo@foo.foo", Count: 3},
+		{Name: "bar", Email: "bar@bar.bar", Count: 1},
+	})
+}
+
+func (s *ShortlogSuite) TestShortlogRange(c *C) {
+	r, w, hashes := s.repository(c)
+
+	entries, err := r.Shortlog(hashes[1].String()+"..", &ShortlogOptions{Summary: true})
+	c.Assert(err, IsNil)
+	c.Assert(entries, DeepEquals, []*ShortlogEntry{
+		{Name: "Foo", Count: 1},
+		{Name: "foo", Count: 1},
+	})
+
+	entries, err = r.Shortlog(hashes[1].String(), &ShortlogOptions{Summary: true})
+	c.Assert(err, IsNil)
+	c.Assert(entries, DeepEquals, []*ShortlogEntry{
+		{Name: "bar", Count: 1},
+		{Name: "foo", Count: 1},
+	})
+
+	err = w.Checkout(&CheckoutOptions{Hash: hashes[1], Branch: "refs/heads/feature", Create: true})
+	c.Assert(err, IsNil)
+	s.commit(c, w, 4, "baz", "baz@baz.baz", "baz", "baz\n", "baz\n")
+
+	entries, err = r.Shortlog("master...feature", &ShortlogOptions{Summary: true})
+	c.Assert(err, IsNil)
+	c.Assert(entries, DeepEquals, []*ShortlogEntry{
+		{Name: "Foo", Count: 1},
+		{Name: "baz", Count: 1},
+		{Name: "foo", Count: 1},
+	})
+
+	_, err = r.Shortlog("master..feature..HEAD", nil)
+	c.Assert(err, Equals, ErrInvalidRevisionRange)
+}
+
+func (s *ShortlogSuite) TestShortlogNoMerges(c *C) {
+	r, w, hashes := s.repository(c)
+
+	_, err := w.Commit("merge\n", &CommitOptions{
+		Author:  defaultSignature(),
+		Parents: []plumbing.Hash{hashes[3], hashes[1]},
+	})
+	c.Assert(err, IsNil)
+
+	entries, err := r.Shortlog(hashes[3].String()+"..", &ShortlogOptions{NoMerges: true})
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 0)
+
+	entries, err = r.Shortlog(hashes[3].String()+"..", &ShortlogOptions{Summary: true})
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+}
@@ -0,0 +1,84 @@
+package git
+
+import (
+	"bytes"
+	"io"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/sshsig"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/ssh"
+)
+
+// Signer signs the payload of commit and tag objects, allowing the use of
+// external signing backends such as gpg-agent, KMS or x509 certificates. The
+// returned signature is stored as is, at the object.
+type Signer interface {
+	Sign(message io.Reader) ([]byte, error)
+}
+
+type openPGPSigner struct {
+	key *openpgp.Entity
+}
+
+// NewOpenPGPSigner returns a Signer creating armored detached OpenPGP
+// signatures with the given key, the private key must be already decrypted.
+func NewOpenPGPSigner(key *openpgp.Entity) Signer {
+	return &openPGPSigner{key: key}
+}
+
+// Sign implements the Signer interface.
+func (s *openPGPSigner) Sign(message io.Reader) ([]byte, error) {
+	var b bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&b, s.key, message, nil); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+type sshSigner struct {
+	signer ssh.Signer
+}
+
+// NewSSHSigner returns a Signer creating SSH signatures with the given key,
+// as git does when gpg.format is ssh. The signatures can be verified with
+// Commit.VerifySSH and Tag.VerifySSH.
+func NewSSHSigner(signer ssh.Signer) Signer {
+	return &sshSigner{signer: signer}
+}
+
+// Sign implements the Signer interface.
+func (s *sshSigner) Sign(message io.Reader) ([]byte, error) {
+	sig, err := sshsig.Sign(s.signer, message, sshsig.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return sig.Marshal(), nil
+}
+
+type signableObject interface {
+	EncodeWithoutSignature(o plumbing.EncodedObject) error
+}
+
+// signObject returns the signature of the given object, created by signer.
+func signObject(signer Signer, o signableObject) (string, error) {
+	encoded := &plumbing.MemoryObject{}
+	if err := o.EncodeWithoutSignature(encoded); err != nil {
+		return "", err
+	}
+
+	r, err := encoded.Reader()
+	if err != nil {
+		return "", err
+	}
+
+	sig, err := signer.Sign(r)
+	if err != nil {
+		return "", err
+	}
+
+	return string(sig), nil
+}
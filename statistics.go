@@ -0,0 +1,427 @@
+package git
+
+import (
+	"fmt"
+	"sort"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/idxfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+
+	"gopkg.in/src-d/go-billy.v4"
+)
+
+// statisticsLargest is the number of largest objects reported.
+const statisticsLargest = 10
+
+// Statistics are the statistics of a repository, as reported by
+// `git count-objects -v` and by git-sizer.
+type Statistics struct {
+	// LooseObjects is the number of loose objects, and LooseSize their size
+	// on disk. The size is only known for repositories on a filesystem.
+	LooseObjects int
+	LooseSize    int64
+	// Packs is the number of packfiles, PackedObjects the number of objects
+	// in them and PackSize the size on disk of the packfiles.
+	Packs         int
+	PackedObjects int
+	PackSize      int64
+	// Objects are the number and the total size of the objects of every
+	// type, counting once the objects both loose and packed.
+	Objects map[plumbing.ObjectType]ObjectCount
+	// LargestObjects are the largest objects, and LargestBlobs the largest
+	// blobs, the largest first.
+	LargestObjects []storer.ObjectInfo
+	LargestBlobs   []storer.ObjectInfo
+	// References are the number of references of every kind.
+	References ReferenceCount
+	// Checkouts are the largest values of the checkouts of the commits
+	// reachable from the references.
+	Checkouts CheckoutStatistics
+}
+
+// ObjectCount is a number of objects and their total size.
+type ObjectCount struct {
+	Count int
+	Size  int64
+}
+
+// ReferenceCount is the number of references of every kind, HEAD isn't
+// counted.
+type ReferenceCount struct {
+	Branches int
+	Tags     int
+	Remotes  int
+	Other    int
+}
+
+// Total returns the number of references.
+func (c ReferenceCount) Total() int {
+	return c.Branches + c.Tags + c.Remotes + c.Other
+}
+
+// CheckoutStatistics are the largest values of the checkouts of a set of
+// commits, as git-sizer reports them.
+type CheckoutStatistics struct {
+	// Directories is the number of directories, including the root one.
+	Directories CheckoutValue
+	// MaxPathDepth is the number of components of the deepest path.
+	MaxPathDepth CheckoutValue
+	// MaxPathLength is the length, in bytes, of the longest path.
+	MaxPathLength CheckoutValue
+	// Files is the number of files, and FilesSize their total size, not
+	// counting the symbolic links.
+	Files     CheckoutValue
+	FilesSize CheckoutValue
+	// Symlinks is the number of symbolic links.
+	Symlinks CheckoutValue
+	// Submodules is the number of submodules.
+	Submodules CheckoutValue
+}
+
+// CheckoutValue is the largest value of a checkout, and the commit with it.
+type CheckoutValue struct {
+	Value  int64
+	Commit plumbing.Hash
+}
+
+func (v *CheckoutValue) update(value int64, commit plumbing.Hash) {
+	if value > v.Value {
+		v.Value, v.Commit = value, commit
+	}
+}
+
+// Statistics returns the statistics of the objects, the references and the
+// checkouts of the repository. Every reachable tree is read once, and only
+// the headers of the objects are read when the storer supports it.
+func (r *Repository) Statistics() (*Statistics, error) {
+	s := &Statistics{Objects: make(map[plumbing.ObjectType]ObjectCount)}
+
+	hashes, err := r.statisticsObjects(s)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := storer.NewObjectBatch(r.Storer)
+	if err != nil {
+		return nil, err
+	}
+
+	defer b.Close()
+
+	for _, h := range hashes {
+		info, err := b.ObjectInfo(h)
+		if err != nil {
+			return nil, err
+		}
+
+		count := s.Objects[info.Type]
+		count.Count++
+		count.Size += info.Size
+		s.Objects[info.Type] = count
+
+		s.LargestObjects = appendLargest(s.LargestObjects, *info)
+		if info.Type == plumbing.BlobObject {
+			s.LargestBlobs = appendLargest(s.LargestBlobs, *info)
+		}
+	}
+
+	tips, err := r.statisticsReferences(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.statisticsCheckouts(s, b, tips); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// appendLargest adds the object to the largest objects, if it is one of
+// them.
+func appendLargest(largest []storer.ObjectInfo, info storer.ObjectInfo) []storer.ObjectInfo {
+	i := sort.Search(len(largest), func(i int) bool {
+		return largest[i].Size < info.Size ||
+			(largest[i].Size == info.Size && largest[i].Hash.String() > info.Hash.String())
+	})
+
+	if i == statisticsLargest {
+		return largest
+	}
+
+	largest = append(largest, storer.ObjectInfo{})
+	copy(largest[i+1:], largest[i:])
+	largest[i] = info
+
+	if len(largest) > statisticsLargest {
+		largest = largest[:statisticsLargest]
+	}
+
+	return largest
+}
+
+// statisticsObjects counts the loose and the packed objects, and returns
+// the hashes of all the objects.
+func (r *Repository) statisticsObjects(s *Statistics) ([]plumbing.Hash, error) {
+	fs, _ := storerFilesystem(r)
+
+	var hashes []plumbing.Hash
+	seen := make(map[plumbing.Hash]bool)
+	add := func(h plumbing.Hash) {
+		if !seen[h] {
+			seen[h] = true
+			hashes = append(hashes, h)
+		}
+	}
+
+	los, ok := r.Storer.(storer.LooseObjectStorer)
+	if !ok {
+		iter, err := r.Storer.IterEncodedObjects(plumbing.AnyObject)
+		if err != nil {
+			return nil, err
+		}
+
+		err = iter.ForEach(func(obj plumbing.EncodedObject) error {
+			add(obj.Hash())
+			return nil
+		})
+
+		return hashes, err
+	}
+
+	err := los.ForEachObjectHash(func(h plumbing.Hash) error {
+		s.LooseObjects++
+		add(h)
+
+		if fs == nil {
+			return nil
+		}
+
+		hex := h.String()
+		fi, err := fs.Stat(fs.Join("objects", hex[:2], hex[2:]))
+		if err != nil {
+			return err
+		}
+
+		s.LooseSize += fi.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pos, ok := r.Storer.(storer.PackedObjectStorer)
+	if !ok || fs == nil {
+		return hashes, nil
+	}
+
+	packs, err := pos.ObjectPacks()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pack := range packs {
+		name := fs.Join("objects", "pack", fmt.Sprintf("pack-%s", pack))
+		fi, err := fs.Stat(name + ".pack")
+		if err != nil {
+			return nil, err
+		}
+
+		idx, err := readIdxfile(fs, name+".idx")
+		if err != nil {
+			return nil, err
+		}
+
+		s.Packs++
+		s.PackSize += fi.Size()
+		s.PackedObjects += len(idx.Entries)
+		for _, e := range idx.Entries {
+			add(e.Hash)
+		}
+	}
+
+	return hashes, nil
+}
+
+func readIdxfile(fs billy.Filesystem, name string) (idx *idxfile.Idxfile, err error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	idx = idxfile.NewIdxfile()
+	if err := idxfile.NewDecoder(f).Decode(idx); err != nil {
+		return nil, err
+	}
+
+	return idx, nil
+}
+
+// statisticsReferences counts the references, and returns the hashes they
+// point to.
+func (r *Repository) statisticsReferences(s *Statistics) ([]plumbing.Hash, error) {
+	iter, err := r.Storer.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	var tips []plumbing.Hash
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name()
+		switch {
+		case name == plumbing.HEAD:
+			return nil
+		case name.IsBranch():
+			s.References.Branches++
+		case name.IsTag():
+			s.References.Tags++
+		case name.IsRemote():
+			s.References.Remotes++
+		default:
+			s.References.Other++
+		}
+
+		if ref.Type() == plumbing.HashReference {
+			tips = append(tips, ref.Hash())
+		}
+
+		return nil
+	})
+
+	return tips, err
+}
+
+// checkoutStats are the values of the checkout of a tree.
+type checkoutStats struct {
+	directories, depth, pathLength    int64
+	files, size, symlinks, submodules int64
+}
+
+// statisticsCheckouts computes the values of the checkouts of the commits
+// reachable from the given tips.
+func (r *Repository) statisticsCheckouts(s *Statistics, b storer.ObjectBatch, tips []plumbing.Hash) error {
+	trees := make(map[plumbing.Hash]*checkoutStats)
+	seen := make(map[plumbing.Hash]bool)
+
+	pending := tips
+	for len(pending) > 0 {
+		h := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if seen[h] {
+			continue
+		}
+
+		seen[h] = true
+		obj, err := r.Storer.EncodedObject(plumbing.AnyObject, h)
+		if err == plumbing.ErrObjectNotFound {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		switch obj.Type() {
+		case plumbing.TagObject:
+			tag, err := object.DecodeTag(r.Storer, obj)
+			if err != nil {
+				return err
+			}
+
+			pending = append(pending, tag.Target)
+		case plumbing.CommitObject:
+			c, err := object.DecodeCommit(r.Storer, obj)
+			if err != nil {
+				return err
+			}
+
+			stats, err := checkoutTreeStats(r.Storer, b, trees, c.TreeHash)
+			if err != nil {
+				return err
+			}
+
+			s.Checkouts.update(stats, c.Hash)
+			pending = append(pending, c.ParentHashes...)
+		}
+	}
+
+	return nil
+}
+
+func (s *CheckoutStatistics) update(c *checkoutStats, commit plumbing.Hash) {
+	s.Directories.update(c.directories, commit)
+	s.MaxPathDepth.update(c.depth, commit)
+	s.MaxPathLength.update(c.pathLength, commit)
+	s.Files.update(c.files, commit)
+	s.FilesSize.update(c.size, commit)
+	s.Symlinks.update(c.symlinks, commit)
+	s.Submodules.update(c.submodules, commit)
+}
+
+// checkoutTreeStats returns the values of the checkout of a tree, reading
+// every tree once.
+func checkoutTreeStats(st storer.EncodedObjectStorer, b storer.ObjectBatch,
+	trees map[plumbing.Hash]*checkoutStats, h plumbing.Hash) (*checkoutStats, error) {
+	if stats, ok := trees[h]; ok {
+		return stats, nil
+	}
+
+	t, err := object.GetTree(st, h)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &checkoutStats{directories: 1}
+	for _, e := range t.Entries {
+		length := int64(len(e.Name))
+		stats.depth = max64(stats.depth, 1)
+		stats.pathLength = max64(stats.pathLength, length)
+
+		switch e.Mode {
+		case filemode.Dir:
+			sub, err := checkoutTreeStats(st, b, trees, e.Hash)
+			if err != nil {
+				return nil, err
+			}
+
+			stats.directories += sub.directories
+			stats.files += sub.files
+			stats.size += sub.size
+			stats.symlinks += sub.symlinks
+			stats.submodules += sub.submodules
+			if sub.depth > 0 {
+				stats.depth = max64(stats.depth, sub.depth+1)
+				stats.pathLength = max64(stats.pathLength, length+1+sub.pathLength)
+			}
+		case filemode.Submodule:
+			stats.submodules++
+		case filemode.Symlink:
+			stats.symlinks++
+		default:
+			info, err := b.ObjectInfo(e.Hash)
+			if err != nil {
+				return nil, err
+			}
+
+			stats.files++
+			stats.size += info.Size
+		}
+	}
+
+	trees[h] = stats
+	return stats, nil
+}
+
+func max64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+
+	return b
+}
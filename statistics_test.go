@@ -0,0 +1,124 @@
+package git
+
+import (
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+type StatisticsSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&StatisticsSuite{})
+
+func (s *StatisticsSuite) commit(c *C, r *Repository) (first, second plumbing.Hash) {
+	var err error
+	first, err = r.CommitChanges("first\n", &CommitChangesOptions{
+		Author: defaultSignature(),
+		Changes: []FileChange{
+			{Path: "foo", Content: strings.NewReader("foo")},
+			{Path: "bar/baz", Content: strings.NewReader("bazz")},
+			{Path: "link", Content: strings.NewReader("foo/target"), Mode: filemode.Symlink},
+		},
+	})
+	c.Assert(err, IsNil)
+
+	second, err = r.CommitChanges("second\n", &CommitChangesOptions{
+		Author:  defaultSignature(),
+		Changes: []FileChange{{Path: "bar/qux/quux", Content: strings.NewReader("q")}},
+	})
+	c.Assert(err, IsNil)
+
+	refs := []*plumbing.Reference{
+		plumbing.NewHashReference("refs/tags/v1", first),
+		plumbing.NewHashReference("refs/remotes/origin/master", first),
+		plumbing.NewSymbolicReference("refs/remotes/origin/HEAD", "refs/remotes/origin/master"),
+		plumbing.NewHashReference("refs/notes/commits", first),
+	}
+
+	for _, ref := range refs {
+		c.Assert(r.Storer.SetReference(ref), IsNil)
+	}
+
+	return first, second
+}
+
+func (s *StatisticsSuite) TestStatistics(c *C) {
+	r, err := PlainInit(c.MkDir(), true)
+	c.Assert(err, IsNil)
+
+	_, second := s.commit(c, r)
+
+	stats, err := r.Statistics()
+	c.Assert(err, IsNil)
+
+	c.Assert(stats.LooseObjects, Equals, 11)
+	c.Assert(stats.LooseSize > 0, Equals, true)
+	c.Assert(stats.Packs, Equals, 0)
+	c.Assert(stats.Objects[plumbing.CommitObject].Count, Equals, 2)
+	c.Assert(stats.Objects[plumbing.BlobObject], Equals, ObjectCount{Count: 4, Size: 18})
+	c.Assert(stats.Objects[plumbing.TreeObject].Count, Equals, 5)
+
+	c.Assert(stats.LargestBlobs, HasLen, 4)
+	c.Assert(stats.LargestBlobs[0].Size, Equals, int64(10))
+	c.Assert(stats.LargestBlobs[3].Size, Equals, int64(1))
+	c.Assert(stats.LargestObjects, HasLen, 10)
+	c.Assert(stats.LargestObjects[0].Type, Equals, plumbing.CommitObject)
+
+	c.Assert(stats.References, Equals, ReferenceCount{Branches: 1, Tags: 1, Remotes: 2, Other: 1})
+	c.Assert(stats.References.Total(), Equals, 5)
+
+	checkouts := stats.Checkouts
+	c.Assert(checkouts.Directories, Equals, CheckoutValue{3, second})
+	c.Assert(checkouts.MaxPathDepth, Equals, CheckoutValue{3, second})
+	c.Assert(checkouts.MaxPathLength, Equals, CheckoutValue{12, second})
+	c.Assert(checkouts.Files, Equals, CheckoutValue{3, second})
+	c.Assert(checkouts.FilesSize, Equals, CheckoutValue{8, second})
+	c.Assert(checkouts.Symlinks.Value, Equals, int64(1))
+	c.Assert(checkouts.Submodules, Equals, CheckoutValue{})
+
+}
+
+func (s *StatisticsSuite) TestStatisticsPacked(c *C) {
+	r, err := PlainInit(c.MkDir(), true)
+	c.Assert(err, IsNil)
+
+	_, err = r.CommitChanges("foo\n", &CommitChangesOptions{
+		Author:  defaultSignature(),
+		Changes: []FileChange{{Path: "foo/bar", Content: strings.NewReader("bar")}},
+	})
+	c.Assert(err, IsNil)
+
+	loose, err := r.Statistics()
+	c.Assert(err, IsNil)
+	c.Assert(r.RepackObjects(&RepackConfig{}), IsNil)
+
+	stats, err := r.Statistics()
+	c.Assert(err, IsNil)
+	c.Assert(stats.LooseObjects, Equals, 0)
+	c.Assert(stats.Packs, Equals, 1)
+	c.Assert(stats.PackSize > 0, Equals, true)
+	c.Assert(stats.PackedObjects, Equals, 4)
+	c.Assert(stats.Objects, DeepEquals, loose.Objects)
+}
+
+func (s *StatisticsSuite) TestStatisticsMemory(c *C) {
+	r, err := Init(memory.NewStorage(), nil)
+	c.Assert(err, IsNil)
+
+	first, _ := s.commit(c, r)
+	c.Assert(r.Storer.SetReference(plumbing.NewHashReference("refs/heads/old", first)), IsNil)
+
+	stats, err := r.Statistics()
+	c.Assert(err, IsNil)
+	c.Assert(stats.LooseObjects, Equals, 11)
+	c.Assert(stats.LooseSize, Equals, int64(0))
+	c.Assert(stats.Objects[plumbing.CommitObject].Count, Equals, 2)
+	c.Assert(stats.References.Branches, Equals, 2)
+	c.Assert(stats.Checkouts.Files.Value, Equals, int64(3))
+}
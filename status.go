@@ -36,7 +36,7 @@ func (s Status) String() string {
 		}
 
 		if status.Staging == Renamed {
-			path = fmt.Sprintf("%s -> %s", path, status.Extra)
+			path = fmt.Sprintf("%s -> %s", status.Extra, path)
 		}
 
 		fmt.Fprintf(buf, "%c%c %s\n", status.Staging, status.Worktree, path)
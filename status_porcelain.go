@@ -0,0 +1,166 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// PorcelainV2Encoder writes a StatusReport with the format of
+// git status --porcelain=v2 --branch --show-stash.
+type PorcelainV2Encoder struct {
+	w io.Writer
+}
+
+// NewPorcelainV2Encoder returns a new encoder that writes to w.
+func NewPorcelainV2Encoder(w io.Writer) *PorcelainV2Encoder {
+	return &PorcelainV2Encoder{w}
+}
+
+// Encode writes the headers of the branch and the stash, the changed files and
+// then the untracked ones.
+func (e *PorcelainV2Encoder) Encode(r *StatusReport) error {
+	buf := bytes.NewBuffer(nil)
+	encodeBranchHeaders(buf, r)
+
+	var untracked []*FileReport
+	for _, f := range r.Files {
+		if f.Worktree == Untracked {
+			untracked = append(untracked, f)
+			continue
+		}
+
+		encodeFileReport(buf, f)
+	}
+
+	for _, f := range untracked {
+		fmt.Fprintf(buf, "? %s\n", quotePath(f.Path))
+	}
+
+	_, err := e.w.Write(buf.Bytes())
+	return err
+}
+
+func encodeBranchHeaders(buf *bytes.Buffer, r *StatusReport) {
+	if r.Head.IsZero() {
+		buf.WriteString("# branch.oid (initial)\n")
+	} else {
+		fmt.Fprintf(buf, "# branch.oid %s\n", r.Head)
+	}
+
+	if r.Branch == "" {
+		buf.WriteString("# branch.head (detached)\n")
+	} else {
+		fmt.Fprintf(buf, "# branch.head %s\n", r.Branch.Short())
+	}
+
+	if r.Upstream != "" {
+		fmt.Fprintf(buf, "# branch.upstream %s\n", r.Upstream.Short())
+		if !r.UpstreamGone {
+			fmt.Fprintf(buf, "# branch.ab +%d -%d\n", r.Ahead, r.Behind)
+		}
+	}
+
+	if r.Stashes != 0 {
+		fmt.Fprintf(buf, "# stash %d\n", r.Stashes)
+	}
+}
+
+func encodeFileReport(buf *bytes.Buffer, f *FileReport) {
+	kind := '1'
+	if f.Staging == Renamed || f.Staging == Copied {
+		kind = '2'
+	}
+
+	fmt.Fprintf(buf, "%c %c%c %s %06o %06o %06o %s %s ",
+		kind, porcelainCode(f.Staging), porcelainCode(f.Worktree),
+		porcelainSubmodule(f), uint32(f.HeadMode), uint32(f.IndexMode),
+		uint32(f.WorktreeMode), f.HeadHash, f.IndexHash,
+	)
+
+	if kind == '2' {
+		// only exact renames are detected
+		fmt.Fprintf(buf, "%c100 %s\t%s\n", f.Staging, quotePath(f.Path), quotePath(f.Extra))
+		return
+	}
+
+	fmt.Fprintf(buf, "%s\n", quotePath(f.Path))
+}
+
+func porcelainCode(c StatusCode) StatusCode {
+	if c == Unmodified {
+		return '.'
+	}
+
+	return c
+}
+
+func porcelainSubmodule(f *FileReport) string {
+	s := f.Submodule
+	if s == nil {
+		return "N..."
+	}
+
+	flag := func(set bool, c byte) byte {
+		if set {
+			return c
+		}
+
+		return '.'
+	}
+
+	return string([]byte{'S',
+		flag(s.CommitChanged, 'C'),
+		flag(s.Modified, 'M'),
+		flag(s.Untracked, 'U'),
+	})
+}
+
+// quotePath quotes the paths with special characters as git does with
+// core.quotePath enabled, using C-style escapes.
+func quotePath(p string) string {
+	needsQuote := false
+	for i := 0; i < len(p); i++ {
+		if c := p[i]; c < 0x20 || c == '"' || c == '\\' || c >= 0x7f {
+			needsQuote = true
+			break
+		}
+	}
+
+	if !needsQuote {
+		return p
+	}
+
+	buf := bytes.NewBuffer(nil)
+	buf.WriteByte('"')
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; c {
+		case '\a':
+			buf.WriteString(`\a`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\v':
+			buf.WriteString(`\v`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		default:
+			if c < 0x20 || c >= 0x7f {
+				fmt.Fprintf(buf, `\%03o`, c)
+			} else {
+				buf.WriteByte(c)
+			}
+		}
+	}
+
+	buf.WriteByte('"')
+	return buf.String()
+}
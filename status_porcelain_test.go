@@ -0,0 +1,88 @@
+package git
+
+import (
+	"bytes"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+
+	. "gopkg.in/check.v1"
+)
+
+type PorcelainV2Suite struct{}
+
+var _ = Suite(&PorcelainV2Suite{})
+
+func (s *PorcelainV2Suite) TestEncode(c *C) {
+	head := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	foo := plumbing.NewHash("d96c7efbfec2814ae0301ad054dc8d9fc416c9b5")
+	bar := plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881")
+
+	report := &StatusReport{
+		Head:     head,
+		Branch:   plumbing.Master,
+		Upstream: plumbing.ReferenceName("refs/remotes/origin/master"),
+		Ahead:    1,
+		Behind:   2,
+		Stashes:  3,
+		Files: []*FileReport{{
+			Path:       "bar",
+			FileStatus: FileStatus{Staging: Renamed, Worktree: Unmodified, Extra: "foo"},
+			HeadMode:   filemode.Regular, IndexMode: filemode.Regular, WorktreeMode: filemode.Regular,
+			HeadHash: foo, IndexHash: foo,
+		}, {
+			Path:       "new file",
+			FileStatus: FileStatus{Staging: Added, Worktree: Modified},
+			IndexMode:  filemode.Executable, WorktreeMode: filemode.Executable,
+			IndexHash: bar,
+		}, {
+			Path:       "qux\tbaz",
+			FileStatus: FileStatus{Staging: Untracked, Worktree: Untracked},
+		}, {
+			Path:       "sub",
+			FileStatus: FileStatus{Staging: Unmodified, Worktree: Modified},
+			HeadMode:   filemode.Submodule, IndexMode: filemode.Submodule, WorktreeMode: filemode.Submodule,
+			HeadHash: head, IndexHash: head,
+			Submodule: &SubmoduleState{Modified: true, Untracked: true},
+		}},
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err := NewPorcelainV2Encoder(buf).Encode(report)
+	c.Assert(err, IsNil)
+	c.Assert(buf.String(), Equals, ""+
+		"# branch.oid 6ecf0ef2c2dffb796033e5a02219af86ec6584e5\n"+
+		"# branch.head master\n"+
+		"# branch.upstream origin/master\n"+
+		"# branch.ab +1 -2\n"+
+		"# stash 3\n"+
+		"2 R. N... 100644 100644 100644 d96c7efbfec2814ae0301ad054dc8d9fc416c9b5 d96c7efbfec2814ae0301ad054dc8d9fc416c9b5 R100 bar\tfoo\n"+
+		"1 AM N... 000000 100755 100755 0000000000000000000000000000000000000000 e8d3ffab552895c19b9fcf7aa264d277cde33881 new file\n"+
+		"1 .M S.MU 160000 160000 160000 6ecf0ef2c2dffb796033e5a02219af86ec6584e5 6ecf0ef2c2dffb796033e5a02219af86ec6584e5 sub\n"+
+		"? \"qux\\tbaz\"\n",
+	)
+}
+
+func (s *PorcelainV2Suite) TestEncodeInitialDetached(c *C) {
+	report := &StatusReport{
+		Upstream:     plumbing.ReferenceName("refs/remotes/origin/gone"),
+		UpstreamGone: true,
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err := NewPorcelainV2Encoder(buf).Encode(report)
+	c.Assert(err, IsNil)
+	c.Assert(buf.String(), Equals, ""+
+		"# branch.oid (initial)\n"+
+		"# branch.head (detached)\n"+
+		"# branch.upstream origin/gone\n",
+	)
+}
+
+func (s *PorcelainV2Suite) TestQuotePath(c *C) {
+	c.Assert(quotePath("foo bar"), Equals, "foo bar")
+	c.Assert(quotePath(`foo"bar`), Equals, `"foo\"bar"`)
+	c.Assert(quotePath(`foo\bar`), Equals, `"foo\\bar"`)
+	c.Assert(quotePath("foo\nbar"), Equals, `"foo\nbar"`)
+	c.Assert(quotePath("ñ"), Equals, `"\303\261"`)
+}
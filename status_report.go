@@ -0,0 +1,348 @@
+package git
+
+import (
+	"os"
+	"sort"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+const stashReference plumbing.ReferenceName = "refs/stash"
+
+// StatusReport is the status of a worktree along with the state of its
+// branch, the data shown by git status --porcelain=v2 --branch --show-stash.
+type StatusReport struct {
+	// Head is the commit checked out, zero if the branch has no commits.
+	Head plumbing.Hash
+	// Branch is the branch checked out, empty if HEAD is detached.
+	Branch plumbing.ReferenceName
+	// Upstream is the branch tracked by Branch, empty if it has none.
+	Upstream plumbing.ReferenceName
+	// UpstreamGone is true if Upstream is configured but doesn't exist.
+	UpstreamGone bool
+	// Ahead is the number of commits reachable from Head and not from
+	// Upstream.
+	Ahead int
+	// Behind is the number of commits reachable from Upstream and not from
+	// Head.
+	Behind int
+	// Stashes is the number of entries at the stash.
+	Stashes int
+	// Files are the changed and untracked files, sorted by path.
+	Files []*FileReport
+}
+
+// FileReport is the status of a file with its mode and hash at HEAD, at the
+// index and at the worktree. The modes and hashes missing are zero.
+type FileReport struct {
+	// Path is the path of the file, the previous path of the renamed files
+	// is at Extra.
+	Path string
+	FileStatus
+
+	HeadMode     filemode.FileMode
+	IndexMode    filemode.FileMode
+	WorktreeMode filemode.FileMode
+	HeadHash     plumbing.Hash
+	IndexHash    plumbing.Hash
+
+	// Submodule is the state of the submodule at Path, nil if Path is not
+	// an initialized submodule.
+	Submodule *SubmoduleState
+}
+
+// SubmoduleState is the state of the worktree of a submodule.
+type SubmoduleState struct {
+	// CommitChanged is true if the submodule HEAD is not the commit at the
+	// index of the parent repository.
+	CommitChanged bool
+	// Modified is true if the submodule has changes at its tracked files.
+	Modified bool
+	// Untracked is true if the submodule has untracked files.
+	Untracked bool
+}
+
+// IsClean returns true if the submodule has no changes.
+func (s *SubmoduleState) IsClean() bool {
+	return !s.CommitChanged && !s.Modified && !s.Untracked
+}
+
+// StatusReport returns the status of the worktree with the state of the
+// branch checked out: its upstream and the commits ahead and behind of it,
+// the number of stashes and the state of the submodules.
+func (w *Worktree) StatusReport() (*StatusReport, error) {
+	head, err := w.r.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &StatusReport{}
+	if head.Type() == plumbing.SymbolicReference {
+		r.Branch = head.Target()
+	}
+
+	ref, err := w.r.Head()
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return nil, err
+	}
+
+	if err == nil {
+		r.Head = ref.Hash()
+	}
+
+	s, err := w.status(r.Head)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.reportUpstream(r); err != nil {
+		return nil, err
+	}
+
+	if r.Stashes, err = countStashes(w.r); err != nil {
+		return nil, err
+	}
+
+	if r.Files, err = w.reportFiles(r.Head, s); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (w *Worktree) reportUpstream(r *StatusReport) error {
+	if r.Branch == "" {
+		return nil
+	}
+
+	cfg, err := w.r.Storer.Config()
+	if err != nil {
+		return err
+	}
+
+	info := &BranchInfo{Name: r.Branch, Hash: r.Head}
+	if err := w.r.resolveUpstream(cfg, info); err != nil {
+		return err
+	}
+
+	r.Upstream, r.UpstreamGone = info.Upstream, info.UpstreamGone
+	r.Ahead, r.Behind = info.Ahead, info.Behind
+	return nil
+}
+
+// upstreamReference returns the reference tracked by the branch, the one
+// fetched from branch.<name>.merge of branch.<name>.remote, empty if the
+// branch has no upstream.
+func upstreamReference(cfg *config.Config, b *config.Branch) plumbing.ReferenceName {
+	if b.Remote == "" || b.Merge == "" {
+		return ""
+	}
+
+	if b.Remote == "." {
+		return b.Merge
+	}
+
+	rc, ok := cfg.Remotes[b.Remote]
+	if !ok {
+		return ""
+	}
+
+	for _, rs := range rc.Fetch {
+		if rs.Match(b.Merge) {
+			return rs.Dst(b.Merge)
+		}
+	}
+
+	return ""
+}
+
+// countStashes returns the number of entries at the reflog of refs/stash.
+func countStashes(r *Repository) (int, error) {
+	entries, err := readReflog(r, stashReference)
+	return len(entries), err
+}
+
+func (w *Worktree) reportFiles(commit plumbing.Hash, s Status) ([]*FileReport, error) {
+	var t *object.Tree
+	if !commit.IsZero() {
+		c, err := w.r.CommitObject(commit)
+		if err != nil {
+			return nil, err
+		}
+
+		if t, err = c.Tree(); err != nil {
+			return nil, err
+		}
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	states, err := w.submoduleStates()
+	if err != nil {
+		return nil, err
+	}
+
+	// as git does, the submodules with changes are modified at the worktree
+	for name, state := range states {
+		if state.IsClean() {
+			continue
+		}
+
+		fs, ok := s[name]
+		if !ok {
+			fs = &FileStatus{Staging: Unmodified}
+			s[name] = fs
+		}
+
+		fs.Worktree = Modified
+	}
+
+	var files []*FileReport
+	for name, fs := range s {
+		if fs.Staging == Unmodified && fs.Worktree == Unmodified {
+			continue
+		}
+
+		f := &FileReport{Path: name, FileStatus: *fs}
+		if fs.Worktree == Untracked {
+			if d := deletedFileReport(name, t); d != nil {
+				files = append(files, d)
+			}
+
+			files = append(files, f)
+			continue
+		}
+
+		files = append(files, f)
+
+		if err := w.fillFileReport(f, t, idx); err != nil {
+			return nil, err
+		}
+
+		f.Submodule = states[name]
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	return files, nil
+}
+
+// deletedFileReport returns the report of an untracked file deleted from the
+// index, Status reports only the untracked one, nil if it is not at HEAD.
+func deletedFileReport(name string, t *object.Tree) *FileReport {
+	if t == nil {
+		return nil
+	}
+
+	e, err := t.FindEntry(name)
+	if err != nil {
+		return nil
+	}
+
+	return &FileReport{
+		Path:       name,
+		FileStatus: FileStatus{Staging: Deleted, Worktree: Unmodified},
+		HeadMode:   e.Mode,
+		HeadHash:   e.Hash,
+	}
+}
+
+func (w *Worktree) fillFileReport(f *FileReport, t *object.Tree, idx *index.Index) error {
+	from := f.Path
+	if f.Staging == Renamed {
+		from = f.Extra
+	}
+
+	if t != nil {
+		if e, err := t.FindEntry(from); err == nil {
+			f.HeadMode, f.HeadHash = e.Mode, e.Hash
+		}
+	}
+
+	e, err := idx.Entry(f.Path)
+	if err != nil && err != index.ErrEntryNotFound {
+		return err
+	}
+
+	if e != nil {
+		f.IndexMode, f.IndexHash = e.Mode, e.Hash
+	}
+
+	fi, err := w.Filesystem.Lstat(f.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if fi.IsDir() {
+		if f.IndexMode == filemode.Submodule || f.HeadMode == filemode.Submodule {
+			f.WorktreeMode = filemode.Submodule
+		}
+
+		return nil
+	}
+
+	f.WorktreeMode, err = filemode.NewFromOSFileMode(fi.Mode())
+	return err
+}
+
+// submoduleStates returns the state of the initialized submodules.
+func (w *Worktree) submoduleStates() (map[string]*SubmoduleState, error) {
+	subs, err := w.Submodules()
+	if err != nil {
+		return nil, err
+	}
+
+	states := make(map[string]*SubmoduleState, len(subs))
+	for _, sub := range subs {
+		status, err := sub.Status()
+		if err != nil {
+			return nil, err
+		}
+
+		if status.Current.IsZero() {
+			continue
+		}
+
+		state := &SubmoduleState{CommitChanged: !status.IsClean()}
+		states[status.Path] = state
+
+		r, err := sub.Repository()
+		if err != nil {
+			return nil, err
+		}
+
+		sw, err := r.Worktree()
+		if err != nil {
+			return nil, err
+		}
+
+		s, err := sw.Status()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, fs := range s {
+			switch {
+			case fs.Worktree == Untracked:
+				state.Untracked = true
+			case fs.Staging != Unmodified || fs.Worktree != Unmodified:
+				state.Modified = true
+			}
+		}
+	}
+
+	return states, nil
+}
@@ -0,0 +1,220 @@
+package git
+
+import (
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type StatusReportSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&StatusReportSuite{})
+
+func (s *StatusReportSuite) newWorktree(c *C) (*Worktree, plumbing.Hash) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	for _, name := range []string{"foo", "bar", "qux/baz"} {
+		err := util.WriteFile(w.Filesystem, name, []byte(name), 0644)
+		c.Assert(err, IsNil)
+	}
+
+	_, err = w.Add(".")
+	c.Assert(err, IsNil)
+
+	h, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	return w, h
+}
+
+func (s *StatusReportSuite) TestInitial(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "foo", []byte("foo"), 0644)
+	c.Assert(err, IsNil)
+
+	report, err := w.StatusReport()
+	c.Assert(err, IsNil)
+	c.Assert(report.Head.IsZero(), Equals, true)
+	c.Assert(report.Branch, Equals, plumbing.Master)
+	c.Assert(report.Upstream, Equals, plumbing.ReferenceName(""))
+	c.Assert(report.Files, HasLen, 1)
+	c.Assert(report.Files[0].Path, Equals, "foo")
+	c.Assert(report.Files[0].Worktree, Equals, Untracked)
+}
+
+func (s *StatusReportSuite) TestFiles(c *C) {
+	w, h := s.newWorktree(c)
+
+	_, err := w.Move("foo", "moved")
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "bar", []byte("modified"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = w.Remove("qux/baz")
+	c.Assert(err, IsNil)
+
+	report, err := w.StatusReport()
+	c.Assert(err, IsNil)
+	c.Assert(report.Head, Equals, h)
+	c.Assert(report.Files, HasLen, 3)
+
+	bar := report.Files[0]
+	c.Assert(bar.Path, Equals, "bar")
+	c.Assert(bar.FileStatus, Equals, FileStatus{Staging: Unmodified, Worktree: Modified})
+	c.Assert(bar.HeadMode, Equals, filemode.Regular)
+	c.Assert(bar.IndexMode, Equals, filemode.Regular)
+	c.Assert(bar.WorktreeMode, Equals, filemode.Regular)
+	c.Assert(bar.HeadHash, Equals, bar.IndexHash)
+	c.Assert(bar.Submodule, IsNil)
+
+	moved := report.Files[1]
+	c.Assert(moved.Path, Equals, "moved")
+	c.Assert(moved.FileStatus, Equals, FileStatus{Staging: Renamed, Worktree: Unmodified, Extra: "foo"})
+	c.Assert(moved.HeadHash, Equals, plumbing.ComputeHash(plumbing.BlobObject, []byte("foo")))
+	c.Assert(moved.IndexHash, Equals, moved.HeadHash)
+
+	baz := report.Files[2]
+	c.Assert(baz.Path, Equals, "qux/baz")
+	c.Assert(baz.FileStatus, Equals, FileStatus{Staging: Deleted, Worktree: Unmodified})
+	c.Assert(baz.HeadMode, Equals, filemode.Regular)
+	c.Assert(baz.IndexMode, Equals, filemode.Empty)
+	c.Assert(baz.WorktreeMode, Equals, filemode.Empty)
+	c.Assert(baz.IndexHash.IsZero(), Equals, true)
+}
+
+func (s *StatusReportSuite) TestDeletedAndUntracked(c *C) {
+	w, _ := s.newWorktree(c)
+
+	idx, err := w.r.Storer.Index()
+	c.Assert(err, IsNil)
+	_, err = idx.Remove("foo")
+	c.Assert(err, IsNil)
+	c.Assert(w.r.Storer.SetIndex(idx), IsNil)
+
+	report, err := w.StatusReport()
+	c.Assert(err, IsNil)
+	c.Assert(report.Files, HasLen, 2)
+
+	staged := report.Files[0]
+	c.Assert(staged.FileStatus, Equals, FileStatus{Staging: Deleted, Worktree: Unmodified})
+	c.Assert(staged.HeadMode, Equals, filemode.Regular)
+
+	untracked := report.Files[1]
+	c.Assert(untracked.Path, Equals, "foo")
+	c.Assert(untracked.Worktree, Equals, Untracked)
+}
+
+func (s *StatusReportSuite) TestEmptyFilesAreNotRenames(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "foo", nil, 0644)
+	c.Assert(err, IsNil)
+
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	_, err = w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	_, err = w.Move("foo", "bar")
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 2)
+	c.Assert(status.File("foo").Staging, Equals, Deleted)
+	c.Assert(status.File("bar").Staging, Equals, Added)
+}
+
+func (s *StatusReportSuite) TestUpstream(c *C) {
+	w, h := s.newWorktree(c)
+
+	err := w.r.Storer.SetConfig(&config.Config{
+		Remotes: map[string]*config.RemoteConfig{
+			"origin": {
+				Name:  "origin",
+				URLs:  []string{"https://example.com/foo.git"},
+				Fetch: []config.RefSpec{"+refs/heads/*:refs/remotes/origin/*"},
+			},
+		},
+		Branches: map[string]*config.Branch{
+			"master": {Name: "master", Remote: "origin", Merge: plumbing.Master},
+		},
+	})
+	c.Assert(err, IsNil)
+
+	report, err := w.StatusReport()
+	c.Assert(err, IsNil)
+	c.Assert(report.Upstream, Equals, plumbing.ReferenceName("refs/remotes/origin/master"))
+	c.Assert(report.UpstreamGone, Equals, true)
+
+	upstream := plumbing.ReferenceName("refs/remotes/origin/master")
+	err = w.r.Storer.SetReference(plumbing.NewHashReference(upstream, h))
+	c.Assert(err, IsNil)
+
+	for i := 0; i < 2; i++ {
+		err = util.WriteFile(w.Filesystem, "foo", []byte{byte(i)}, 0644)
+		c.Assert(err, IsNil)
+
+		_, err = w.Commit("foo\n", &CommitOptions{All: true, Author: defaultSignature()})
+		c.Assert(err, IsNil)
+	}
+
+	report, err = w.StatusReport()
+	c.Assert(err, IsNil)
+	c.Assert(report.UpstreamGone, Equals, false)
+	c.Assert(report.Ahead, Equals, 2)
+	c.Assert(report.Behind, Equals, 0)
+
+	err = w.Checkout(&CheckoutOptions{Hash: h})
+	c.Assert(err, IsNil)
+
+	report, err = w.StatusReport()
+	c.Assert(err, IsNil)
+	c.Assert(report.Branch, Equals, plumbing.ReferenceName(""))
+	c.Assert(report.Upstream, Equals, plumbing.ReferenceName(""))
+}
+
+func (s *StatusReportSuite) TestStashes(c *C) {
+	r, err := PlainInit(c.MkDir(), false)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	report, err := w.StatusReport()
+	c.Assert(err, IsNil)
+	c.Assert(report.Stashes, Equals, 0)
+
+	dot, _ := storerFilesystem(r)
+	err = util.WriteFile(dot, "logs/refs/stash", []byte(
+		"0000000000000000000000000000000000000000 6ecf0ef2c2dffb796033e5a02219af86ec6584e5 foo <foo@foo.foo> 1494000000 +0200\tWIP on master: foo\n"+
+			"6ecf0ef2c2dffb796033e5a02219af86ec6584e5 e8d3ffab552895c19b9fcf7aa264d277cde33881 foo <foo@foo.foo> 1494000000 +0200\tWIP on master: bar\n",
+	), 0644)
+	c.Assert(err, IsNil)
+
+	report, err = w.StatusReport()
+	c.Assert(err, IsNil)
+	c.Assert(report.Stashes, Equals, 2)
+}
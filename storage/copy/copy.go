@@ -0,0 +1,230 @@
+// Package copy implements the copy of whole repositories between storers.
+package copy
+
+import (
+	"io"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+// Copy copies the whole repository stored at src into dst: the objects, the
+// references, the config, the index, the shallow commits and, recursively,
+// the storers of the submodules found at the config.
+//
+// The packfiles of src are copied as they are, without decoding their
+// objects, if src implements storer.PackedObjectStorer, storer.PackfileOpener
+// and storer.LooseObjectStorer, as the filesystem storage does. The rest of
+// the objects are written as a single packfile, without delta compression, if
+// dst implements storer.PackfileWriter, or one by one otherwise.
+func Copy(dst, src storage.Storer) error {
+	if err := copyObjects(dst, src); err != nil {
+		return err
+	}
+
+	if err := copyReferences(dst, src); err != nil {
+		return err
+	}
+
+	cfg, err := src.Config()
+	if err != nil {
+		return err
+	}
+
+	if err := dst.SetConfig(cfg); err != nil {
+		return err
+	}
+
+	idx, err := src.Index()
+	if err != nil {
+		return err
+	}
+
+	if len(idx.Entries) != 0 {
+		if err := dst.SetIndex(idx); err != nil {
+			return err
+		}
+	}
+
+	shallow, err := src.Shallow()
+	if err != nil {
+		return err
+	}
+
+	if len(shallow) != 0 {
+		if err := dst.SetShallow(shallow); err != nil {
+			return err
+		}
+	}
+
+	for name := range cfg.Submodules {
+		if err := copyModule(dst, src, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyObjects(dst, src storage.Storer) error {
+	copied, err := copyPackfiles(dst, src)
+	if err != nil {
+		return err
+	}
+
+	var iter storer.EncodedObjectIter
+	if copied {
+		// only the loose objects are left
+		var hashes []plumbing.Hash
+		err = src.(storer.LooseObjectStorer).ForEachObjectHash(func(h plumbing.Hash) error {
+			hashes = append(hashes, h)
+			return nil
+		})
+
+		iter = storer.NewEncodedObjectLookupIter(src, plumbing.AnyObject, hashes)
+	} else {
+		iter, err = src.IterEncodedObjects(plumbing.AnyObject)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer iter.Close()
+
+	if pw, ok := dst.(storer.PackfileWriter); ok {
+		return encodeObjects(pw, src, iter)
+	}
+
+	return iter.ForEach(func(o plumbing.EncodedObject) error {
+		return copyObject(dst, o)
+	})
+}
+
+// copyPackfiles copies the packfiles of src as they are, returning false if
+// src doesn't support it.
+func copyPackfiles(dst, src storage.Storer) (bool, error) {
+	packed, ok := src.(storer.PackedObjectStorer)
+	if !ok {
+		return false, nil
+	}
+
+	opener, ok := src.(storer.PackfileOpener)
+	if !ok {
+		return false, nil
+	}
+
+	if _, ok := src.(storer.LooseObjectStorer); !ok {
+		return false, nil
+	}
+
+	packs, err := packed.ObjectPacks()
+	if err != nil {
+		return false, err
+	}
+
+	for _, h := range packs {
+		if err := copyPackfile(dst, opener, h); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func copyPackfile(dst storer.EncodedObjectStorer, src storer.PackfileOpener, h plumbing.Hash) (err error) {
+	r, err := src.ObjectPackfile(h)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+	return packfile.UpdateObjectStorage(dst, r)
+}
+
+func encodeObjects(dst storer.PackfileWriter, src storer.EncodedObjectStorer, iter storer.EncodedObjectIter) (err error) {
+	var hashes []plumbing.Hash
+	err = iter.ForEach(func(o plumbing.EncodedObject) error {
+		hashes = append(hashes, o.Hash())
+		return nil
+	})
+	if err != nil || len(hashes) == 0 {
+		return err
+	}
+
+	w, err := dst.PackfileWriter()
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(w, &err)
+	_, err = packfile.NewEncoder(w, src, false).Encode(hashes, 0)
+	return err
+}
+
+func copyObject(dst storer.EncodedObjectStorer, o plumbing.EncodedObject) (err error) {
+	obj := dst.NewEncodedObject()
+	obj.SetType(o.Type())
+	obj.SetSize(o.Size())
+
+	r, err := o.Reader()
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+
+	w, err := obj.Writer()
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(w, r); err != nil {
+		_ = w.Close()
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	_, err = dst.SetEncodedObject(obj)
+	return err
+}
+
+func copyReferences(dst, src storage.Storer) error {
+	iter, err := src.IterReferences()
+	if err != nil {
+		return err
+	}
+
+	defer iter.Close()
+	return iter.ForEach(dst.SetReference)
+}
+
+// copyModule copies the storer of the submodule, if it is cloned.
+func copyModule(dst, src storage.Storer, name string) error {
+	sm, err := src.Module(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = sm.Reference(plumbing.HEAD)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	dm, err := dst.Module(name)
+	if err != nil {
+		return err
+	}
+
+	return Copy(dm, sm)
+}
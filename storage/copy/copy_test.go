@@ -0,0 +1,148 @@
+package copy_test
+
+import (
+	"testing"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage"
+	"gopkg.in/src-d/go-git.v4/storage/copy"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type CopySuite struct {
+	fixtures.Suite
+}
+
+var _ = Suite(&CopySuite{})
+
+func (s *CopySuite) assertEqual(c *C, a, b storage.Storer) {
+	count := func(st storage.Storer) int {
+		iter, err := st.IterEncodedObjects(plumbing.AnyObject)
+		c.Assert(err, IsNil)
+
+		var n int
+		c.Assert(iter.ForEach(func(plumbing.EncodedObject) error {
+			n++
+			return nil
+		}), IsNil)
+		return n
+	}
+
+	c.Assert(count(a), Equals, count(b))
+
+	refs, err := a.IterReferences()
+	c.Assert(err, IsNil)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		other, err := b.Reference(ref.Name())
+		c.Assert(err, IsNil)
+		c.Assert(other.Strings(), DeepEquals, ref.Strings())
+		return nil
+	})
+	c.Assert(err, IsNil)
+
+	ca, err := a.Config()
+	c.Assert(err, IsNil)
+	cb, err := b.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cb.Remotes, HasLen, len(ca.Remotes))
+}
+
+func (s *CopySuite) TestCopyFilesystemToMemory(c *C) {
+	src, err := filesystem.NewStorage(fixtures.Basic().ByTag(".git").One().DotGit())
+	c.Assert(err, IsNil)
+
+	dst := memory.NewStorage()
+	c.Assert(copy.Copy(dst, src), IsNil)
+	s.assertEqual(c, src, dst)
+}
+
+func (s *CopySuite) TestCopyMemoryToFilesystem(c *C) {
+	src := memory.NewStorage()
+	c.Assert(copy.Copy(src, s.basic(c)), IsNil)
+
+	c.Assert(src.SetShallow([]plumbing.Hash{
+		plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+	}), IsNil)
+	c.Assert(src.SetIndex(&index.Index{Version: 2, Entries: []*index.Entry{
+		{Name: "foo", Hash: plumbing.NewHash("32858aad3c383ed1ff0a0f9bdf231d54a00c9e88")},
+	}}), IsNil)
+
+	dst, err := filesystem.NewStorage(memfs.New())
+	c.Assert(err, IsNil)
+	c.Assert(copy.Copy(dst, src), IsNil)
+	s.assertEqual(c, src, dst)
+
+	packs, err := dst.ObjectPacks()
+	c.Assert(err, IsNil)
+	c.Assert(packs, HasLen, 1)
+
+	var loose int
+	c.Assert(dst.ForEachObjectHash(func(plumbing.Hash) error {
+		loose++
+		return nil
+	}), IsNil)
+	c.Assert(loose, Equals, 0)
+
+	shallow, err := dst.Shallow()
+	c.Assert(err, IsNil)
+	c.Assert(shallow, HasLen, 1)
+
+	idx, err := dst.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Entries, HasLen, 1)
+}
+
+func (s *CopySuite) TestCopyPackfiles(c *C) {
+	src := s.basic(c)
+
+	// a loose object, besides the packfile
+	obj := src.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	c.Assert(err, IsNil)
+	_, err = w.Write([]byte("foo"))
+	c.Assert(err, IsNil)
+	c.Assert(w.Close(), IsNil)
+	h, err := src.SetEncodedObject(obj)
+	c.Assert(err, IsNil)
+
+	dst, err := filesystem.NewStorage(memfs.New())
+	c.Assert(err, IsNil)
+	c.Assert(copy.Copy(dst, src), IsNil)
+	s.assertEqual(c, src, dst)
+
+	srcPacks, err := src.ObjectPacks()
+	c.Assert(err, IsNil)
+	dstPacks, err := dst.ObjectPacks()
+	c.Assert(err, IsNil)
+	c.Assert(dstPacks, HasLen, len(srcPacks)+1)
+
+	copied := make(map[plumbing.Hash]bool)
+	for _, p := range dstPacks {
+		copied[p] = true
+	}
+
+	for _, p := range srcPacks {
+		c.Assert(copied[p], Equals, true)
+	}
+
+	c.Assert(dst.HasEncodedObject(h), IsNil)
+}
+
+// basic returns a copy of the basic fixture, at a filesystem storage.
+func (s *CopySuite) basic(c *C) *filesystem.Storage {
+	st, err := filesystem.NewStorage(fixtures.Basic().ByTag(".git").One().DotGit())
+	c.Assert(err, IsNil)
+
+	var _ storer.PackfileOpener = st
+	return st
+}
@@ -0,0 +1,254 @@
+package filesystem
+
+import (
+	"bytes"
+	"os"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/objfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+
+	"gopkg.in/src-d/go-billy.v4"
+)
+
+// ObjectBatch returns a storer.ObjectBatch keeping open the packfiles it
+// reads from until it is closed.
+func (s *ObjectStorage) ObjectBatch() (storer.ObjectBatch, error) {
+	if err := s.requireIndex(); err != nil {
+		return nil, err
+	}
+
+	return &objectBatch{s: s, packs: make(map[plumbing.Hash]*batchPack)}, nil
+}
+
+type objectBatch struct {
+	s     *ObjectStorage
+	packs map[plumbing.Hash]*batchPack
+}
+
+// batchPack is a packfile open by a batch, with the decoder of its objects
+// and the types of the objects found while resolving deltas.
+type batchPack struct {
+	f     billy.File
+	idx   *packfile.Index
+	d     *packfile.Decoder
+	types map[int64]plumbing.ObjectType
+}
+
+func (b *objectBatch) pack(h plumbing.Hash) (*batchPack, error) {
+	if p, ok := b.packs[h]; ok {
+		return p, nil
+	}
+
+	f, err := b.s.dir.ObjectPack(h)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := packfile.NewDecoderWithCache(packfile.NewScanner(f), memory.NewStorage(),
+		b.s.deltaBaseCache)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	idx := b.s.index[h]
+	d.SetIndex(idx)
+	d.SetDeltaChainCache(b.s.deltaChainCache(h))
+
+	p := &batchPack{f: f, idx: idx, d: d, types: make(map[int64]plumbing.ObjectType)}
+	b.packs[h] = p
+	return p, nil
+}
+
+// EncodedObject returns the object with the given hash, as
+// ObjectStorage.EncodedObject does.
+func (b *objectBatch) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	pack, _, offset := b.s.findObjectInPackfile(h)
+	if offset == -1 {
+		return b.s.EncodedObject(t, h)
+	}
+
+	p, err := b.pack(pack)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := p.d.DecodeObjectAt(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	if plumbing.AnyObject != t && obj.Type() != t {
+		return nil, plumbing.ErrObjectNotFound
+	}
+
+	return obj, nil
+}
+
+// ObjectInfo returns the type and the size of the object with the given
+// hash, reading only the headers of the objects, and the header of the
+// delta of a deltified object.
+func (b *objectBatch) ObjectInfo(h plumbing.Hash) (*storer.ObjectInfo, error) {
+	info := &storer.ObjectInfo{Hash: h}
+
+	pack, _, offset := b.s.findObjectInPackfile(h)
+	if offset != -1 {
+		p, err := b.pack(pack)
+		if err != nil {
+			return nil, err
+		}
+
+		info.Type, info.Size, err = b.packedInfo(p, offset)
+		return info, err
+	}
+
+	var err error
+	info.Type, info.Size, err = b.looseInfo(h)
+	if err == plumbing.ErrObjectNotFound {
+		// it may be at an alternate object database
+		obj, err := b.s.EncodedObject(plumbing.AnyObject, h)
+		if err != nil {
+			return nil, err
+		}
+
+		info.Type, info.Size = obj.Type(), obj.Size()
+		return info, nil
+	}
+
+	return info, err
+}
+
+func (b *objectBatch) looseInfo(h plumbing.Hash) (t plumbing.ObjectType, size int64, err error) {
+	f, err := b.s.dir.Object(h)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return plumbing.InvalidObject, 0, plumbing.ErrObjectNotFound
+		}
+
+		return plumbing.InvalidObject, 0, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	r, err := objfile.NewReader(f)
+	if err != nil {
+		return plumbing.InvalidObject, 0, err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+	return r.Header()
+}
+
+func (b *objectBatch) packedInfo(p *batchPack, offset int64) (plumbing.ObjectType, int64, error) {
+	h, s, err := b.header(p, offset)
+	if err != nil {
+		return plumbing.InvalidObject, 0, err
+	}
+
+	if !h.Type.IsDelta() {
+		return h.Type, h.Length, nil
+	}
+
+	// the size of the object is the target size of the delta
+	delta := bytes.NewBuffer(nil)
+	if _, _, err := s.NextObject(delta); err != nil {
+		return plumbing.InvalidObject, 0, err
+	}
+
+	_, rest := decodeDeltaSize(delta.Bytes())
+	size, _ := decodeDeltaSize(rest)
+
+	t, err := b.deltaType(p, h)
+	return t, int64(size), err
+}
+
+// deltaType returns the type of a deltified object, the type of the base of
+// its delta chain.
+func (b *objectBatch) deltaType(p *batchPack, h *packfile.ObjectHeader) (plumbing.ObjectType, error) {
+	var chain []int64
+	t := plumbing.InvalidObject
+	for t == plumbing.InvalidObject {
+		if known, ok := p.types[h.Offset]; ok {
+			t = known
+			break
+		}
+
+		if !h.Type.IsDelta() {
+			t = h.Type
+			break
+		}
+
+		chain = append(chain, h.Offset)
+
+		offset := h.OffsetReference
+		if h.Type == plumbing.REFDeltaObject {
+			e, ok := p.idx.LookupHash(h.Reference)
+			if !ok {
+				info, err := b.ObjectInfo(h.Reference)
+				if err != nil {
+					return plumbing.InvalidObject, err
+				}
+
+				t = info.Type
+				break
+			}
+
+			offset = int64(e.Offset)
+		}
+
+		var err error
+		if h, _, err = b.header(p, offset); err != nil {
+			return plumbing.InvalidObject, err
+		}
+	}
+
+	for _, o := range chain {
+		p.types[o] = t
+	}
+
+	return t, nil
+}
+
+// header reads the header of the object at the given offset, returning the
+// scanner positioned at the content of the object.
+func (b *objectBatch) header(p *batchPack, offset int64) (*packfile.ObjectHeader, *packfile.Scanner, error) {
+	s := packfile.NewScanner(p.f)
+	if _, err := s.SeekFromStart(offset); err != nil {
+		return nil, nil, err
+	}
+
+	h, err := s.NextObjectHeader()
+	return h, s, err
+}
+
+func decodeDeltaSize(delta []byte) (uint, []byte) {
+	var size uint
+	var shift uint
+	for i, c := range delta {
+		size |= uint(c&0x7f) << shift
+		shift += 7
+		if c&0x80 == 0 {
+			return size, delta[i+1:]
+		}
+	}
+
+	return size, nil
+}
+
+// Close closes the packfiles open by the batch.
+func (b *objectBatch) Close() error {
+	var err error
+	for h, p := range b.packs {
+		if cerr := p.f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+
+		delete(b.packs, h)
+	}
+
+	return err
+}
@@ -0,0 +1,72 @@
+package filesystem
+
+import (
+	"io/ioutil"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem/dotgit"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+)
+
+func (s *FsSuite) TestObjectBatch(c *C) {
+	fixtures.ByTag(".git").Test(c, func(f *fixtures.Fixture) {
+		o, err := NewObjectStorage(dotgit.New(f.DotGit()))
+		c.Assert(err, IsNil)
+
+		b, err := o.ObjectBatch()
+		c.Assert(err, IsNil)
+		defer func() { c.Assert(b.Close(), IsNil) }()
+
+		iter, err := o.IterEncodedObjects(plumbing.AnyObject)
+		c.Assert(err, IsNil)
+
+		err = iter.ForEach(func(expected plumbing.EncodedObject) error {
+			info, err := b.ObjectInfo(expected.Hash())
+			c.Assert(err, IsNil)
+			c.Assert(info.Hash, Equals, expected.Hash())
+			c.Assert(info.Type, Equals, expected.Type())
+			c.Assert(info.Size, Equals, expected.Size())
+
+			obj, err := b.EncodedObject(plumbing.AnyObject, expected.Hash())
+			c.Assert(err, IsNil)
+			c.Assert(obj.Type(), Equals, expected.Type())
+			c.Assert(readObject(c, obj), DeepEquals, readObject(c, expected))
+			return nil
+		})
+
+		c.Assert(err, IsNil)
+	})
+}
+
+func (s *FsSuite) TestObjectBatchNotFound(c *C) {
+	fs := fixtures.Basic().ByTag(".git").One().DotGit()
+	o, err := NewObjectStorage(dotgit.New(fs))
+	c.Assert(err, IsNil)
+
+	b, err := o.ObjectBatch()
+	c.Assert(err, IsNil)
+	defer func() { c.Assert(b.Close(), IsNil) }()
+
+	h := plumbing.NewHash("0000000000000000000000000000000000000001")
+	_, err = b.ObjectInfo(h)
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+	_, err = b.EncodedObject(plumbing.AnyObject, h)
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+
+	// a blob looked up as a commit
+	h = plumbing.NewHash("32858aad3c383ed1ff0a0f9bdf231d54a00c9e88")
+	_, err = b.EncodedObject(plumbing.CommitObject, h)
+	c.Assert(err, Equals, plumbing.ErrObjectNotFound)
+}
+
+func readObject(c *C, obj plumbing.EncodedObject) []byte {
+	r, err := obj.Reader()
+	c.Assert(err, IsNil)
+	defer r.Close()
+
+	content, err := ioutil.ReadAll(r)
+	c.Assert(err, IsNil)
+	return content
+}
@@ -0,0 +1,66 @@
+package filesystem
+
+import (
+	"errors"
+	"io"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// ErrBigObjectReadOnly is returned by the Writer of the objects over
+// core.bigFileThreshold, read from the storage.
+var ErrBigObjectReadOnly = errors.New("big objects are read-only")
+
+// bigObject is a plumbing.BigObject whose content is streamed from the loose
+// object or the packfile on every read.
+type bigObject struct {
+	hash plumbing.Hash
+	typ  plumbing.ObjectType
+	size int64
+	open func() (io.ReadCloser, error)
+}
+
+func newBigObject(
+	hash plumbing.Hash,
+	typ plumbing.ObjectType,
+	size int64,
+	open func() (io.ReadCloser, error)) plumbing.BigObject {
+	return &bigObject{
+		hash: hash,
+		typ:  typ,
+		size: size,
+		open: open,
+	}
+}
+
+func (o *bigObject) Hash() plumbing.Hash {
+	return o.hash
+}
+
+func (o *bigObject) Type() plumbing.ObjectType {
+	return o.typ
+}
+
+func (o *bigObject) SetType(t plumbing.ObjectType) {
+	o.typ = t
+}
+
+func (o *bigObject) Size() int64 {
+	return o.size
+}
+
+func (o *bigObject) SetSize(s int64) {
+	o.size = s
+}
+
+func (o *bigObject) Reader() (io.ReadCloser, error) {
+	return o.open()
+}
+
+func (o *bigObject) Writer() (io.WriteCloser, error) {
+	return nil, ErrBigObjectReadOnly
+}
+
+func (o *bigObject) IsBig() bool {
+	return true
+}
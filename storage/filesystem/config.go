@@ -3,20 +3,57 @@ package filesystem
 import (
 	stdioutil "io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/storage/filesystem/dotgit"
 	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+
+	"gopkg.in/src-d/go-billy.v4"
+)
+
+const (
+	configFile         = "config"
+	worktreeConfigFile = "config.worktree"
 )
 
 type ConfigStorage struct {
 	dir *dotgit.DotGit
 }
 
-func (c *ConfigStorage) Config() (conf *config.Config, err error) {
+func (c *ConfigStorage) Config() (*config.Config, error) {
+	return c.config(config.LocalScope)
+}
+
+// WorktreeConfig returns the config as Config does, but storing its options
+// at the config of the worktree, config.worktree, if extensions.worktreeConfig
+// is enabled.
+func (c *ConfigStorage) WorktreeConfig() (*config.Config, error) {
+	return c.config(config.WorktreeScope)
+}
+
+// config returns the config of the repository merged with the one of the
+// worktree, if enabled, storing the options of the given scope.
+func (c *ConfigStorage) config(stored config.Scope) (*config.Config, error) {
+	local, err := c.readConfig(c.dir.Config, configFile, config.LocalScope)
+	if err != nil || !local.Extensions.WorktreeConfig {
+		return local, err
+	}
+
+	wt, err := c.readConfig(c.dir.WorktreeConfig, worktreeConfigFile, config.WorktreeScope)
+	if err != nil {
+		return nil, err
+	}
+
+	return config.MergeWorktree(local, wt, stored)
+}
+
+func (c *ConfigStorage) readConfig(open func() (billy.File, error), name string, scope config.Scope) (conf *config.Config, err error) {
 	cfg := config.NewConfig()
 
-	f, err := c.dir.Config()
+	f, err := open()
 	if err != nil {
 		if os.IsNotExist(err) {
 			return cfg, nil
@@ -32,19 +69,72 @@ func (c *ConfigStorage) Config() (conf *config.Config, err error) {
 		return nil, err
 	}
 
-	if err = cfg.Unmarshal(b); err != nil {
+	if err = cfg.UnmarshalIncludes(b, c.includeOptions(name, scope)); err != nil {
 		return nil, err
 	}
 
 	return cfg, err
 }
 
-func (c *ConfigStorage) SetConfig(cfg *config.Config) (err error) {
+// includeOptions returns the settings resolving the files included by the
+// config file name: the ones inside the git directory are read from its
+// filesystem, the rest from the OS.
+func (c *ConfigStorage) includeOptions(name string, scope config.Scope) *config.IncludeOptions {
+	fs := c.dir.Fs()
+	root := fs.Root()
+	o := &config.IncludeOptions{
+		Path:   filepath.Join(root, name),
+		GitDir: root,
+		ReadFile: func(path string) ([]byte, error) {
+			rel, err := filepath.Rel(root, path)
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return stdioutil.ReadFile(path)
+			}
+
+			f, err := fs.Open(rel)
+			if err != nil {
+				return nil, err
+			}
+
+			defer f.Close()
+			return stdioutil.ReadAll(f)
+		},
+		Scope: scope,
+	}
+
+	head, err := c.dir.Ref(plumbing.HEAD)
+	if err == nil && head.Type() == plumbing.SymbolicReference && head.Target().IsBranch() {
+		o.Branch = head.Target().Short()
+	}
+
+	return o
+}
+
+func (c *ConfigStorage) SetConfig(cfg *config.Config) error {
+	return c.writeConfig(c.dir.ConfigWriter, cfg)
+}
+
+// SetWorktreeConfig stores a config returned by WorktreeConfig, at the
+// config of the worktree if extensions.worktreeConfig is enabled.
+func (c *ConfigStorage) SetWorktreeConfig(cfg *config.Config) error {
+	local, err := c.readConfig(c.dir.Config, configFile, config.LocalScope)
+	if err != nil {
+		return err
+	}
+
+	if !local.Extensions.WorktreeConfig {
+		return c.SetConfig(cfg)
+	}
+
+	return c.writeConfig(c.dir.WorktreeConfigWriter, cfg)
+}
+
+func (c *ConfigStorage) writeConfig(create func() (billy.File, error), cfg *config.Config) (err error) {
 	if err = cfg.Validate(); err != nil {
 		return err
 	}
 
-	f, err := c.dir.ConfigWriter()
+	f, err := create()
 	if err != nil {
 		return err
 	}
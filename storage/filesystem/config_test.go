@@ -3,6 +3,7 @@ package filesystem
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 
 	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/storage/filesystem/dotgit"
@@ -44,6 +45,100 @@ func (s *ConfigSuite) TestRemotes(c *C) {
 	c.Assert(remote.Fetch, DeepEquals, []config.RefSpec{config.RefSpec("+refs/heads/*:refs/remotes/origin/*")})
 }
 
+func (s *ConfigSuite) TestIncludes(c *C) {
+	files := map[string]string{
+		"config":        "[include]\n\tpath = shared.config\n[includeIf \"onbranch:master\"]\n\tpath = master.config\n",
+		"shared.config": "[remote \"origin\"]\n\turl = https://github.com/git-fixtures/basic\n",
+		"master.config": "[core]\n\tworktree = /foo\n",
+		"HEAD":          "ref: refs/heads/master\n",
+	}
+
+	for name, content := range files {
+		err := ioutil.WriteFile(filepath.Join(s.path, name), []byte(content), 0644)
+		c.Assert(err, IsNil)
+	}
+
+	storer := &ConfigStorage{s.dir}
+	cfg, err := storer.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Remotes, HasLen, 1)
+	c.Assert(cfg.Core.Worktree, Equals, "/foo")
+
+	err = storer.SetConfig(cfg)
+	c.Assert(err, IsNil)
+
+	b, err := ioutil.ReadFile(filepath.Join(s.path, "config"))
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, files["config"]+"[core]\n\tbare = false\n")
+}
+
+func (s *ConfigSuite) TestWorktreeConfig(c *C) {
+	files := map[string]string{
+		"config":          "[core]\n\tbare = false\n[extensions]\n\tworktreeConfig = true\n",
+		"config.worktree": "[core]\n\tworktree = /foo\n[remote \"origin\"]\n\turl = https://github.com/git-fixtures/basic\n",
+	}
+
+	for name, content := range files {
+		err := ioutil.WriteFile(filepath.Join(s.path, name), []byte(content), 0644)
+		c.Assert(err, IsNil)
+	}
+
+	storer := &ConfigStorage{s.dir}
+	cfg, err := storer.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Extensions.WorktreeConfig, Equals, true)
+	c.Assert(cfg.Core.Worktree, Equals, "/foo")
+	c.Assert(cfg.Remotes, HasLen, 1)
+
+	opt := cfg.Raw.Section("remote").Subsection("origin").Options[0]
+	c.Assert(cfg.Origin(opt), Equals, config.Origin{
+		Scope: config.WorktreeScope,
+		Path:  filepath.Join(s.path, "config.worktree"),
+	})
+
+	err = storer.SetConfig(cfg)
+	c.Assert(err, IsNil)
+
+	b, err := ioutil.ReadFile(filepath.Join(s.path, "config"))
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, files["config"])
+
+	cfg, err = storer.WorktreeConfig()
+	c.Assert(err, IsNil)
+	cfg.Core.HooksPath = "hooks"
+
+	err = storer.SetWorktreeConfig(cfg)
+	c.Assert(err, IsNil)
+
+	b, err = ioutil.ReadFile(filepath.Join(s.path, "config.worktree"))
+	c.Assert(err, IsNil)
+	// the remote belongs to config.worktree, so it gets the default fetch
+	// refspec as with any config
+	c.Assert(string(b), Equals, "[core]\n\tworktree = /foo\n\thooksPath = hooks\n[remote \"origin\"]\n\turl = https://github.com/git-fixtures/basic\n\tfetch = +refs/heads/*:refs/remotes/origin/*\n")
+
+	b, err = ioutil.ReadFile(filepath.Join(s.path, "config"))
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, files["config"])
+}
+
+func (s *ConfigSuite) TestWorktreeConfigDisabled(c *C) {
+	err := ioutil.WriteFile(filepath.Join(s.path, "config.worktree"), []byte("[core]\n\tworktree = /foo\n"), 0644)
+	c.Assert(err, IsNil)
+
+	storer := &ConfigStorage{s.dir}
+	cfg, err := storer.WorktreeConfig()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Core.Worktree, Equals, "")
+
+	cfg.Core.HooksPath = "hooks"
+	err = storer.SetWorktreeConfig(cfg)
+	c.Assert(err, IsNil)
+
+	b, err := ioutil.ReadFile(filepath.Join(s.path, "config"))
+	c.Assert(err, IsNil)
+	c.Assert(string(b), Equals, "[core]\n\tbare = false\n\thooksPath = hooks\n")
+}
+
 func (s *ConfigSuite) TearDownTest(c *C) {
 	defer os.RemoveAll(s.path)
 }
@@ -14,21 +14,32 @@ import (
 
 	"gopkg.in/src-d/go-billy.v4/osfs"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 	"gopkg.in/src-d/go-git.v4/utils/ioutil"
 
 	"gopkg.in/src-d/go-billy.v4"
 )
 
 const (
-	suffix         = ".git"
-	packedRefsPath = "packed-refs"
-	configPath     = "config"
-	indexPath      = "index"
-	shallowPath    = "shallow"
-	modulePath     = "modules"
-	objectsPath    = "objects"
-	packPath       = "pack"
-	refsPath       = "refs"
+	suffix             = ".git"
+	packedRefsPath     = "packed-refs"
+	configPath         = "config"
+	worktreeConfigPath = "config.worktree"
+	indexPath          = "index"
+	sharedIndexPrefix  = "sharedindex."
+	shallowPath        = "shallow"
+	modulePath         = "modules"
+	objectsPath        = "objects"
+	packPath           = "pack"
+	refsPath           = "refs"
+	infoPath           = "info"
+
+	commitGraphPath      = "commit-graph"
+	commitGraphsPath     = "commit-graphs"
+	commitGraphChainPath = "commit-graph-chain"
+
+	multiPackIndexPath = "multi-pack-index"
 
 	tmpPackedRefsPrefix = "._packed-refs"
 
@@ -97,6 +108,11 @@ func (d *DotGit) Initialize() error {
 	return nil
 }
 
+// Fs returns the filesystem of the git directory.
+func (d *DotGit) Fs() billy.Filesystem {
+	return d.fs
+}
+
 // ConfigWriter returns a file pointer for write to the config file
 func (d *DotGit) ConfigWriter() (billy.File, error) {
 	return d.fs.Create(configPath)
@@ -107,6 +123,18 @@ func (d *DotGit) Config() (billy.File, error) {
 	return d.fs.Open(configPath)
 }
 
+// WorktreeConfigWriter returns a file pointer for write to the config file
+// of the worktree, config.worktree.
+func (d *DotGit) WorktreeConfigWriter() (billy.File, error) {
+	return d.fs.Create(worktreeConfigPath)
+}
+
+// WorktreeConfig returns a file pointer for read to the config file of the
+// worktree, config.worktree.
+func (d *DotGit) WorktreeConfig() (billy.File, error) {
+	return d.fs.Open(worktreeConfigPath)
+}
+
 // IndexWriter returns a file pointer for write to the index file
 func (d *DotGit) IndexWriter() (billy.File, error) {
 	return d.fs.Create(indexPath)
@@ -117,6 +145,12 @@ func (d *DotGit) Index() (billy.File, error) {
 	return d.fs.Open(indexPath)
 }
 
+// SharedIndex returns a file pointer for read to the shared index file with
+// the given hash, used by the split index.
+func (d *DotGit) SharedIndex(h plumbing.Hash) (billy.File, error) {
+	return d.fs.Open(sharedIndexPrefix + h.String())
+}
+
 // ShallowWriter returns a file pointer for write to the shallow file
 func (d *DotGit) ShallowWriter() (billy.File, error) {
 	return d.fs.Create(shallowPath)
@@ -136,12 +170,220 @@ func (d *DotGit) Shallow() (billy.File, error) {
 	return f, nil
 }
 
+// CommitGraph returns a file pointer for read to the commit-graph file, nil
+// if the repository has none.
+func (d *DotGit) CommitGraph() (billy.File, error) {
+	return d.openIfExists(d.fs.Join(objectsPath, infoPath, commitGraphPath))
+}
+
+// CommitGraphChain returns a file pointer for read to the file listing the
+// layers of the commit-graph chain, nil if the repository has none.
+func (d *DotGit) CommitGraphChain() (billy.File, error) {
+	return d.openIfExists(d.fs.Join(objectsPath, infoPath, commitGraphsPath, commitGraphChainPath))
+}
+
+// CommitGraphLayer returns a file pointer for read to the layer of the
+// commit-graph chain with the given hash.
+func (d *DotGit) CommitGraphLayer(h plumbing.Hash) (billy.File, error) {
+	return d.fs.Open(d.fs.Join(objectsPath, infoPath, commitGraphsPath, commitGraphLayerName(h)))
+}
+
+// NewCommitGraphTempFile returns a new temporary file, to hold the content
+// of a commit-graph before it is set with SetCommitGraph.
+func (d *DotGit) NewCommitGraphTempFile() (billy.File, error) {
+	return d.fs.TempFile(d.fs.Join(objectsPath, infoPath), "tmp_graph_")
+}
+
+// RemoveCommitGraphTempFile closes, if not closed yet, and removes a file
+// returned by NewCommitGraphTempFile.
+func (d *DotGit) RemoveCommitGraphTempFile(f billy.File) error {
+	_ = f.Close()
+	return d.fs.Remove(f.Name())
+}
+
+// SetCommitGraph makes the closed file returned by NewCommitGraphTempFile the
+// commit-graph file, removing the commit-graph chain if any.
+func (d *DotGit) SetCommitGraph(f billy.File) error {
+	if err := d.fs.Rename(f.Name(), d.fs.Join(objectsPath, infoPath, commitGraphPath)); err != nil {
+		return err
+	}
+
+	dir := d.fs.Join(objectsPath, infoPath, commitGraphsPath)
+	files, err := d.fs.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, fi := range files {
+		if err := d.fs.Remove(d.fs.Join(dir, fi.Name())); err != nil {
+			return err
+		}
+	}
+
+	return d.fs.Remove(dir)
+}
+
+// SetCommitGraphChain makes the closed file returned by
+// NewCommitGraphTempFile the top layer of the commit-graph chain, listing the
+// given layers, from the first one to the hash of the file. The commit-graph
+// file, if any, becomes a layer of the chain if listed, the files of the
+// layers not listed are removed.
+func (d *DotGit) SetCommitGraphChain(f billy.File, layers []plumbing.Hash) error {
+	dir := d.fs.Join(objectsPath, infoPath, commitGraphsPath)
+	if err := d.fs.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	listed := make(map[string]bool, len(layers))
+	for _, h := range layers {
+		listed[commitGraphLayerName(h)] = true
+	}
+
+	if err := d.fs.Rename(f.Name(), d.fs.Join(dir, commitGraphLayerName(layers[len(layers)-1]))); err != nil {
+		return err
+	}
+
+	if err := d.moveCommitGraphToChain(listed); err != nil {
+		return err
+	}
+
+	chain, err := d.fs.TempFile(dir, "tmp_graph_chain_")
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(chain)
+	for _, h := range layers {
+		fmt.Fprintln(w, h)
+	}
+
+	if err := w.Flush(); err != nil {
+		_ = chain.Close()
+		return err
+	}
+
+	if err := chain.Close(); err != nil {
+		return err
+	}
+
+	if err := d.fs.Rename(chain.Name(), d.fs.Join(dir, commitGraphChainPath)); err != nil {
+		return err
+	}
+
+	files, err := d.fs.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, fi := range files {
+		if fi.Name() == commitGraphChainPath || listed[fi.Name()] {
+			continue
+		}
+
+		if err := d.fs.Remove(d.fs.Join(dir, fi.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// moveCommitGraphToChain moves the commit-graph file, if any, to the layer of
+// the chain with its hash if listed, removing it otherwise.
+func (d *DotGit) moveCommitGraphToChain(listed map[string]bool) error {
+	path := d.fs.Join(objectsPath, infoPath, commitGraphPath)
+	f, err := d.openIfExists(path)
+	if err != nil || f == nil {
+		return err
+	}
+
+	var h plumbing.Hash
+	_, err = f.Seek(-int64(len(h)), io.SeekEnd)
+	if err == nil {
+		_, err = io.ReadFull(f, h[:])
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	name := commitGraphLayerName(h)
+	if !listed[name] {
+		return d.fs.Remove(path)
+	}
+
+	return d.fs.Rename(path, d.fs.Join(objectsPath, infoPath, commitGraphsPath, name))
+}
+
+func commitGraphLayerName(h plumbing.Hash) string {
+	return fmt.Sprintf("graph-%s.graph", h)
+}
+
+func (d *DotGit) openIfExists(path string) (billy.File, error) {
+	f, err := d.fs.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	return f, err
+}
+
+// MultiPackIndex returns a file pointer for read to the multi-pack-index
+// file, nil if the repository has none.
+func (d *DotGit) MultiPackIndex() (billy.File, error) {
+	return d.openIfExists(d.fs.Join(objectsPath, packPath, multiPackIndexPath))
+}
+
+// NewMultiPackIndexTempFile returns a new temporary file, to hold the content
+// of a multi-pack-index before it is set with SetMultiPackIndex.
+func (d *DotGit) NewMultiPackIndexTempFile() (billy.File, error) {
+	return d.fs.TempFile(d.fs.Join(objectsPath, packPath), "tmp_midx_")
+}
+
+// RemoveMultiPackIndexTempFile closes, if not closed yet, and removes a file
+// returned by NewMultiPackIndexTempFile.
+func (d *DotGit) RemoveMultiPackIndexTempFile(f billy.File) error {
+	_ = f.Close()
+	return d.fs.Remove(f.Name())
+}
+
+// SetMultiPackIndex makes the closed file returned by
+// NewMultiPackIndexTempFile the multi-pack-index file.
+func (d *DotGit) SetMultiPackIndex(f billy.File) error {
+	return d.fs.Rename(f.Name(), d.fs.Join(objectsPath, packPath, multiPackIndexPath))
+}
+
+// removeMultiPackIndex removes the multi-pack-index file, if any.
+func (d *DotGit) removeMultiPackIndex() error {
+	err := d.fs.Remove(d.fs.Join(objectsPath, packPath, multiPackIndexPath))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
 // NewObjectPack return a writer for a new packfile, it saves the packfile to
 // disk and also generates and save the index for the given packfile.
 func (d *DotGit) NewObjectPack() (*PackWriter, error) {
 	return newPackWrite(d.fs)
 }
 
+// NewStreamObjectPack returns a writer for a new packfile, that streams the
+// packfile to disk and indexes it in place once written, caching up to
+// cacheSize of delta bases while indexing.
+func (d *DotGit) NewStreamObjectPack(cacheSize cache.FileSize) (*StreamPackWriter, error) {
+	return newStreamPackWrite(d.fs, cacheSize)
+}
+
 // ObjectPacks returns the list of availables packfiles
 func (d *DotGit) ObjectPacks() ([]plumbing.Hash, error) {
 	packDir := d.fs.Join(objectsPath, packPath)
@@ -199,6 +441,10 @@ func (d *DotGit) ObjectPackIdx(hash plumbing.Hash) (billy.File, error) {
 	return d.objectPackOpen(hash, `idx`)
 }
 
+// DeleteOldObjectPackAndIndex deletes the packfile with the given hash and
+// its index, if the packfile is older than t, or whatever its age if t is
+// zero. The multi-pack-index file, if any, is removed along with them, since
+// it would index a missing pack.
 func (d *DotGit) DeleteOldObjectPackAndIndex(hash plumbing.Hash, t time.Time) error {
 	path := d.objectPackPath(hash, `pack`)
 	if !t.IsZero() {
@@ -215,7 +461,12 @@ func (d *DotGit) DeleteOldObjectPackAndIndex(hash plumbing.Hash, t time.Time) er
 	if err != nil {
 		return err
 	}
-	return d.fs.Remove(d.objectPackPath(hash, `idx`))
+
+	if err := d.fs.Remove(d.objectPackPath(hash, `idx`)); err != nil {
+		return err
+	}
+
+	return d.removeMultiPackIndex()
 }
 
 // NewObject return a writer for a new object file.
@@ -223,6 +474,22 @@ func (d *DotGit) NewObject() (*ObjectWriter, error) {
 	return newObjectWriter(d.fs)
 }
 
+// NewObjectTempFile returns a new temporary file, to hold the content of an
+// object before it is written with NewObject.
+func (d *DotGit) NewObjectTempFile() (billy.File, error) {
+	return d.fs.TempFile(d.fs.Join(objectsPath, packPath), "tmp_obj_")
+}
+
+// RemoveObjectTempFile closes and removes a file returned by
+// NewObjectTempFile.
+func (d *DotGit) RemoveObjectTempFile(f billy.File) error {
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return d.fs.Remove(f.Name())
+}
+
 // Objects returns a slice with the hashes of objects found under the
 // .git/objects/ directory.
 func (d *DotGit) Objects() ([]plumbing.Hash, error) {
@@ -300,7 +567,13 @@ func (d *DotGit) readReferenceFrom(rd io.Reader, name string) (ref *plumbing.Ref
 		return nil, err
 	}
 
+	// the pseudo-references as FETCH_HEAD and MERGE_HEAD may have several
+	// lines, with annotations after the hash, and resolve to the first one
 	line := strings.TrimSpace(string(b))
+	if i := strings.IndexAny(line, "\n\t "); i != -1 && !strings.HasPrefix(line, "ref: ") {
+		line = line[:i]
+	}
+
 	return plumbing.NewReferenceFromStrings(name, line), nil
 }
 
@@ -313,7 +586,7 @@ func (d *DotGit) checkReferenceAndTruncate(f billy.File, old *plumbing.Reference
 		return err
 	}
 	if ref.Hash() != old.Hash() {
-		return fmt.Errorf("reference has changed concurrently")
+		return storer.ErrReferenceHasChanged
 	}
 	_, err = f.Seek(0, io.SeekStart)
 	if err != nil {
@@ -3,9 +3,8 @@
 package dotgit
 
 import (
-	"fmt"
-
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 )
 
 // There are some filesystems that don't support opening files in RDWD mode.
@@ -31,7 +30,7 @@ func (d *DotGit) setRef(fileName, content string, old *plumbing.Reference) error
 		}
 
 		if ref.Hash() != old.Hash() {
-			return fmt.Errorf("reference has changed concurrently")
+			return storer.ErrReferenceHasChanged
 		}
 	}
 
@@ -12,7 +12,9 @@ import (
 	"gopkg.in/src-d/go-git.v4/plumbing"
 
 	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
 	"gopkg.in/src-d/go-billy.v4/osfs"
+	"gopkg.in/src-d/go-billy.v4/util"
 	"gopkg.in/src-d/go-git-fixtures.v3"
 )
 
@@ -312,6 +314,20 @@ func (s *SuiteDotGit) TestRefsFromHEADFile(c *C) {
 	c.Assert(string(ref.Target()), Equals, "refs/heads/master")
 }
 
+func (s *SuiteDotGit) TestRefFetchHead(c *C) {
+	fs := memfs.New()
+	err := util.WriteFile(fs, "FETCH_HEAD", []byte(
+		"6ecf0ef2c2dffb796033e5a02219af86ec6584e5\t\tbranch 'master' of https://example.com/foo\n"+
+			"e8d3ffab552895c19b9fcf7aa264d277cde33881\tnot-for-merge\tbranch 'branch' of https://example.com/foo\n",
+	), 0644)
+	c.Assert(err, IsNil)
+
+	ref, err := New(fs).Ref(plumbing.FetchHead)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Type(), Equals, plumbing.HashReference)
+	c.Assert(ref.Hash().String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+}
+
 func (s *SuiteDotGit) TestConfig(c *C) {
 	fs := fixtures.Basic().ByTag(".git").One().DotGit()
 	dir := New(fs)
@@ -1,14 +1,21 @@
 package dotgit
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"sync/atomic"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/idxfile"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/objfile"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
 
 	"gopkg.in/src-d/go-billy.v4"
 )
@@ -127,13 +134,19 @@ func (w *PackWriter) clean() error {
 }
 
 func (w *PackWriter) save() error {
-	base := w.fs.Join(objectsPath, packPath, fmt.Sprintf("pack-%s", w.checksum))
-	idx, err := w.fs.Create(fmt.Sprintf("%s.idx", base))
+	return savePackfile(w.fs, w.fw.Name(), w.checksum, w.index)
+}
+
+// savePackfile writes the index of the packfile at the temp file and moves
+// the file next to it, both named after the checksum of the packfile.
+func savePackfile(fs billy.Filesystem, tmp string, checksum plumbing.Hash, index *packfile.Index) error {
+	base := fs.Join(objectsPath, packPath, fmt.Sprintf("pack-%s", checksum))
+	idx, err := fs.Create(fmt.Sprintf("%s.idx", base))
 	if err != nil {
 		return err
 	}
 
-	if err := w.encodeIdx(idx); err != nil {
+	if err := encodeIdx(idx, checksum, index); err != nil {
 		return err
 	}
 
@@ -141,18 +154,160 @@ func (w *PackWriter) save() error {
 		return err
 	}
 
-	return w.fs.Rename(w.fw.Name(), fmt.Sprintf("%s.pack", base))
+	return fs.Rename(tmp, fmt.Sprintf("%s.pack", base))
 }
 
-func (w *PackWriter) encodeIdx(writer io.Writer) error {
-	idx := w.index.ToIdxFile()
-	idx.PackfileChecksum = w.checksum
+func encodeIdx(writer io.Writer, checksum plumbing.Hash, index *packfile.Index) error {
+	idx := index.ToIdxFile()
+	idx.PackfileChecksum = checksum
 	idx.Version = idxfile.VersionSupported
 	e := idxfile.NewEncoder(writer)
 	_, err := e.Encode(idx)
 	return err
 }
 
+// ErrPackfileChecksum is returned by StreamPackWriter.Close when the trailing
+// checksum of the packfile doesn't match its content.
+var ErrPackfileChecksum = errors.New("packfile checksum mismatch")
+
+// streamPackBufferSize is the size of the buffer of the writes to the
+// packfile of a StreamPackWriter.
+const streamPackBufferSize = 64 * 1024
+
+// StreamPackWriter is a io.Writer that writes a packfile straight to a temp
+// file, hashing it on the way, and indexes it in place once complete, when
+// Close is called, instead of indexing it simultaneously as PackWriter does.
+// Only a buffer of the writes and the cache of the delta bases, while
+// indexing, are held in memory. The packfile is moved to its final location
+// after being indexed, if nothing was written, nothing is saved.
+type StreamPackWriter struct {
+	Notify func(plumbing.Hash, *packfile.Index)
+
+	fs     billy.Filesystem
+	fw     billy.File
+	buf    *bufio.Writer
+	hasher hash.Hash
+	// tail are the last bytes written, up to a hash, not hashed yet since
+	// they may be the trailing checksum
+	tail    []byte
+	written int64
+	cache   cache.Object
+}
+
+func newStreamPackWrite(fs billy.Filesystem, cacheSize cache.FileSize) (*StreamPackWriter, error) {
+	fw, err := fs.TempFile(fs.Join(objectsPath, packPath), "tmp_pack_")
+	if err != nil {
+		return nil, err
+	}
+
+	if cacheSize <= 0 {
+		cacheSize = cache.DefaultMaxSize
+	}
+
+	return &StreamPackWriter{
+		fs:     fs,
+		fw:     fw,
+		buf:    bufio.NewWriterSize(fw, streamPackBufferSize),
+		hasher: sha1.New(),
+		tail:   make([]byte, 0, sha1.Size),
+		cache:  cache.NewObjectLRU(cacheSize),
+	}, nil
+}
+
+func (w *StreamPackWriter) Write(p []byte) (int, error) {
+	n, err := w.buf.Write(p)
+	w.written += int64(n)
+	w.hash(p[:n])
+	return n, err
+}
+
+// hash hashes the bytes written, except the last sha1.Size ones, which are
+// kept at the tail.
+func (w *StreamPackWriter) hash(p []byte) {
+	if len(p) >= sha1.Size {
+		w.hasher.Write(w.tail)
+		w.hasher.Write(p[:len(p)-sha1.Size])
+		w.tail = append(w.tail[:0], p[len(p)-sha1.Size:]...)
+		return
+	}
+
+	if extra := len(w.tail) + len(p) - sha1.Size; extra > 0 {
+		w.hasher.Write(w.tail[:extra])
+		w.tail = append(w.tail[:0], w.tail[extra:]...)
+	}
+
+	w.tail = append(w.tail, p...)
+}
+
+// Close flushes the packfile, checks its trailing checksum and indexes it,
+// saving it at its final location. If nothing was written, the temp file is
+// deleted without writing a packfile.
+func (w *StreamPackWriter) Close() (err error) {
+	defer func() {
+		if err != nil {
+			_ = w.fs.Remove(w.fw.Name())
+		}
+	}()
+
+	if err := w.buf.Flush(); err != nil {
+		_ = w.fw.Close()
+		return err
+	}
+
+	if err := w.fw.Close(); err != nil {
+		return err
+	}
+
+	if w.written == 0 {
+		return w.fs.Remove(w.fw.Name())
+	}
+
+	if !bytes.Equal(w.hasher.Sum(nil), w.tail) {
+		return ErrPackfileChecksum
+	}
+
+	checksum, index, err := w.buildIndex()
+	if err != nil {
+		return err
+	}
+
+	if index.Size() == 0 {
+		return w.fs.Remove(w.fw.Name())
+	}
+
+	if err := savePackfile(w.fs, w.fw.Name(), checksum, index); err != nil {
+		return err
+	}
+
+	if w.Notify != nil {
+		w.Notify(checksum, index)
+	}
+
+	return nil
+}
+
+// buildIndex indexes the packfile written at the temp file.
+func (w *StreamPackWriter) buildIndex() (checksum plumbing.Hash, index *packfile.Index, err error) {
+	f, err := w.fs.Open(w.fw.Name())
+	if err != nil {
+		return plumbing.ZeroHash, nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	d, err := packfile.NewDecoderWithCache(packfile.NewScanner(f), nil, w.cache)
+	if err != nil {
+		return plumbing.ZeroHash, nil, err
+	}
+
+	checksum, err = d.Decode()
+	if err != nil {
+		return plumbing.ZeroHash, nil, err
+	}
+
+	return checksum, d.Index(), nil
+}
+
 type syncedReader struct {
 	w io.Writer
 	r io.ReadSeeker
@@ -274,6 +429,15 @@ func (w *ObjectWriter) Close() error {
 	return w.save()
 }
 
+// Abort discards the object being written, removing its temporary file.
+func (w *ObjectWriter) Abort() error {
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+
+	return w.fs.Remove(w.f.Name())
+}
+
 func (w *ObjectWriter) save() error {
 	hash := w.Hash().String()
 	file := w.fs.Join(objectsPath, hash[0:2], hash[2:40])
@@ -154,3 +154,75 @@ func (s *SuiteDotGit) TestPackWriterUnusedNotify(c *C) {
 
 	c.Assert(w.Close(), IsNil)
 }
+
+func (s *SuiteDotGit) TestNewStreamObjectPack(c *C) {
+	f := fixtures.Basic().One()
+
+	fs := osfs.New(c.MkDir())
+	dot := New(fs)
+
+	w, err := dot.NewStreamObjectPack(1024)
+	c.Assert(err, IsNil)
+
+	var notified plumbing.Hash
+	w.Notify = func(h plumbing.Hash, idx *packfile.Index) {
+		notified = h
+		c.Assert(idx.Size(), Equals, 31)
+	}
+
+	_, err = io.Copy(w, f.Packfile())
+	c.Assert(err, IsNil)
+
+	c.Assert(w.Close(), IsNil)
+	c.Assert(notified.String(), Equals, f.PackfileHash.String())
+
+	stat, err := fs.Stat(fmt.Sprintf("objects/pack/pack-%s.pack", f.PackfileHash))
+	c.Assert(err, IsNil)
+	c.Assert(stat.Size(), Equals, int64(84794))
+
+	stat, err = fs.Stat(fmt.Sprintf("objects/pack/pack-%s.idx", f.PackfileHash))
+	c.Assert(err, IsNil)
+	c.Assert(stat.Size(), Equals, int64(1940))
+
+	files, err := fs.ReadDir("objects/pack")
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 2)
+}
+
+func (s *SuiteDotGit) TestNewStreamObjectPackUnused(c *C) {
+	fs := osfs.New(c.MkDir())
+
+	w, err := New(fs).NewStreamObjectPack(0)
+	c.Assert(err, IsNil)
+
+	w.Notify = func(h plumbing.Hash, idx *packfile.Index) {
+		c.Fatal("unexpected call to StreamPackWriter.Notify")
+	}
+
+	c.Assert(w.Close(), IsNil)
+
+	files, err := fs.ReadDir("objects/pack")
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 0)
+}
+
+func (s *SuiteDotGit) TestNewStreamObjectPackChecksum(c *C) {
+	f := fixtures.Basic().One()
+
+	fs := osfs.New(c.MkDir())
+
+	w, err := New(fs).NewStreamObjectPack(0)
+	c.Assert(err, IsNil)
+
+	data, err := ioutil.ReadAll(f.Packfile())
+	c.Assert(err, IsNil)
+	data[len(data)-1] ^= 0xff
+
+	_, err = w.Write(data)
+	c.Assert(err, IsNil)
+	c.Assert(w.Close(), Equals, ErrPackfileChecksum)
+
+	files, err := fs.ReadDir("objects/pack")
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 0)
+}
@@ -42,6 +42,30 @@ func (s *IndexStorage) Index() (i *index.Index, err error) {
 	defer ioutil.CheckClose(f, &err)
 
 	d := index.NewDecoder(f)
-	err = d.Decode(idx)
-	return idx, err
+	if err := d.Decode(idx); err != nil {
+		return idx, err
+	}
+
+	return idx, s.applySharedIndex(idx)
+}
+
+// applySharedIndex reads the shared index of a split index, if any.
+func (s *IndexStorage) applySharedIndex(idx *index.Index) (err error) {
+	if idx.SplitIndex == nil || idx.SplitIndex.SharedIndex.IsZero() {
+		return nil
+	}
+
+	f, err := s.dir.SharedIndex(idx.SplitIndex.SharedIndex)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	shared := &index.Index{}
+	if err := index.NewDecoder(f).Decode(shared); err != nil {
+		return err
+	}
+
+	return idx.ApplySharedIndex(shared)
 }
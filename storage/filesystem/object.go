@@ -1,13 +1,18 @@
 package filesystem
 
 import (
+	"bufio"
 	"io"
 	"os"
+	"sync"
 	"time"
 
+	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/commitgraph"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/idxfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/midx"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/objfile"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
@@ -22,21 +27,52 @@ type ObjectStorage struct {
 	// deltaBaseCache is an object cache uses to cache delta's bases when
 	deltaBaseCache cache.Object
 
-	dir   *dotgit.DotGit
+	dir *dotgit.DotGit
+	// m protects the loading of index, allowing to read objects from
+	// several goroutines
+	m     *sync.Mutex
 	index map[plumbing.Hash]*packfile.Index
+	// deltaChainCaches are the caches of the objects resolved along the
+	// delta chains of every packfile, keyed by their offset
+	deltaChainCaches map[plumbing.Hash]cache.Offset
+
+	commitGraph       *commitgraph.CommitGraph
+	commitGraphLoaded bool
+
+	// bigFileThreshold is the size above which the objects are streamed from
+	// disk instead of being read in memory
+	bigFileThreshold int64
 }
 
 // NewObjectStorage creates a new ObjectStorage with the given .git directory.
 func NewObjectStorage(dir *dotgit.DotGit) (ObjectStorage, error) {
 	s := ObjectStorage{
-		deltaBaseCache: cache.NewObjectLRUDefault(),
-		dir:            dir,
+		deltaBaseCache:   cache.NewObjectLRUDefault(),
+		dir:              dir,
+		m:                &sync.Mutex{},
+		bigFileThreshold: config.DefaultBigFileThreshold,
 	}
 
 	return s, nil
 }
 
+// SetBigFileThreshold sets the size, in bytes, above which the objects are
+// returned as plumbing.BigObject, streaming their content from disk instead
+// of reading it in memory, as core.bigFileThreshold does. The deltified
+// objects of the packfiles are always read in memory. If zero,
+// config.DefaultBigFileThreshold is used.
+func (s *ObjectStorage) SetBigFileThreshold(size int64) {
+	if size == 0 {
+		size = config.DefaultBigFileThreshold
+	}
+
+	s.bigFileThreshold = size
+}
+
 func (s *ObjectStorage) requireIndex() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
 	if s.index != nil {
 		return nil
 	}
@@ -73,6 +109,194 @@ func (s *ObjectStorage) loadIdxFile(h plumbing.Hash) (err error) {
 	return err
 }
 
+// CommitGraph returns the commit-graph of the repository, read from its
+// commit-graph file or from its commit-graph chain, or nil if it has none.
+func (s *ObjectStorage) CommitGraph() (*commitgraph.CommitGraph, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.commitGraphLoaded {
+		return s.commitGraph, nil
+	}
+
+	g, err := s.loadCommitGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	s.commitGraph, s.commitGraphLoaded = g, true
+	return g, nil
+}
+
+// SetCommitGraph writes the given commit-graph as the commit-graph file of
+// the repository, replacing the one it has, or its commit-graph chain.
+func (s *ObjectStorage) SetCommitGraph(g *commitgraph.CommitGraph) error {
+	f, err := s.dir.NewCommitGraphTempFile()
+	if err != nil {
+		return err
+	}
+
+	if err := commitgraph.NewEncoder(f).Encode(g); err != nil {
+		_ = s.dir.RemoveCommitGraphTempFile(f)
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		_ = s.dir.RemoveCommitGraphTempFile(f)
+		return err
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.commitGraph, s.commitGraphLoaded = nil, false
+	return s.dir.SetCommitGraph(f)
+}
+
+// SetCommitGraphChain writes the given commit-graph as the top layer of the
+// commit-graph chain of the repository, on top of its base layers, which must
+// be the layers of the current commit-graph or some of its first ones. The
+// layers not in the base of the commit-graph are removed.
+func (s *ObjectStorage) SetCommitGraphChain(g *commitgraph.CommitGraph) error {
+	f, err := s.dir.NewCommitGraphTempFile()
+	if err != nil {
+		return err
+	}
+
+	if err := commitgraph.NewEncoder(f).Encode(g); err != nil {
+		_ = s.dir.RemoveCommitGraphTempFile(f)
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		_ = s.dir.RemoveCommitGraphTempFile(f)
+		return err
+	}
+
+	var layers []plumbing.Hash
+	for l := g; l != nil; l = l.Base {
+		layers = append([]plumbing.Hash{l.Checksum}, layers...)
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.commitGraph, s.commitGraphLoaded = nil, false
+	return s.dir.SetCommitGraphChain(f, layers)
+}
+
+func (s *ObjectStorage) loadCommitGraph() (g *commitgraph.CommitGraph, err error) {
+	f, err := s.dir.CommitGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	if f != nil {
+		return decodeCommitGraph(f, nil)
+	}
+
+	chain, err := s.dir.CommitGraphChain()
+	if err != nil || chain == nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(chain, &err)
+	scanner := bufio.NewScanner(chain)
+	for scanner.Scan() {
+		h := plumbing.NewHash(scanner.Text())
+		if h.IsZero() {
+			return nil, commitgraph.ErrMalformedCommitGraph
+		}
+
+		f, err := s.dir.CommitGraphLayer(h)
+		if err != nil {
+			return nil, err
+		}
+
+		if g, err = decodeCommitGraph(f, g); err != nil {
+			return nil, err
+		}
+	}
+
+	return g, scanner.Err()
+}
+
+func decodeCommitGraph(f billy.File, base *commitgraph.CommitGraph) (g *commitgraph.CommitGraph, err error) {
+	defer ioutil.CheckClose(f, &err)
+
+	g = commitgraph.New()
+	g.Base = base
+	if err := commitgraph.NewDecoder(f).Decode(g); err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+// MultiPackIndex returns the multi-pack-index of the repository, read from its
+// multi-pack-index file, or nil if it has none.
+func (s *ObjectStorage) MultiPackIndex() (m *midx.MultiPackIndex, err error) {
+	f, err := s.dir.MultiPackIndex()
+	if err != nil || f == nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	m = &midx.MultiPackIndex{}
+	if err := midx.NewDecoder(f).Decode(m); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// WriteMultiPackIndex writes the multi-pack-index file of the repository,
+// indexing all its packs, replacing the one it has.
+func (s *ObjectStorage) WriteMultiPackIndex() error {
+	if err := s.requireIndex(); err != nil {
+		return err
+	}
+
+	s.m.Lock()
+	var names []string
+	for h := range s.index {
+		names = append(names, multiPackIndexPackName(h))
+	}
+
+	m := midx.New(names...)
+	for h, idx := range s.index {
+		pack, _ := m.Pack(multiPackIndexPackName(h))
+		for _, e := range idx.ToIdxFile().Entries {
+			m.Add(e.Hash, pack, e.Offset)
+		}
+	}
+	s.m.Unlock()
+
+	f, err := s.dir.NewMultiPackIndexTempFile()
+	if err != nil {
+		return err
+	}
+
+	if err := midx.NewEncoder(f).Encode(m); err != nil {
+		_ = s.dir.RemoveMultiPackIndexTempFile(f)
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		_ = s.dir.RemoveMultiPackIndexTempFile(f)
+		return err
+	}
+
+	return s.dir.SetMultiPackIndex(f)
+}
+
+// multiPackIndexPackName returns the name of the index file of the pack with
+// the given hash, as listed by a multi-pack-index.
+func multiPackIndexPackName(h plumbing.Hash) string {
+	return "pack-" + h.String() + ".idx"
+}
+
 func (s *ObjectStorage) NewEncodedObject() plumbing.EncodedObject {
 	return &plumbing.MemoryObject{}
 }
@@ -94,6 +318,32 @@ func (s *ObjectStorage) PackfileWriter() (io.WriteCloser, error) {
 	return w, nil
 }
 
+// StreamPackfileWriter returns a writer for a new packfile, that is written
+// straight to disk and indexed once complete, instead of while being written
+// as PackfileWriter does. Up to cacheSize of delta bases are cached while
+// indexing, if zero cache.DefaultMaxSize is used.
+func (s *ObjectStorage) StreamPackfileWriter(cacheSize cache.FileSize) (io.WriteCloser, error) {
+	if err := s.requireIndex(); err != nil {
+		return nil, err
+	}
+
+	w, err := s.dir.NewStreamObjectPack(cacheSize)
+	if err != nil {
+		return nil, err
+	}
+
+	w.Notify = func(h plumbing.Hash, idx *packfile.Index) {
+		s.index[h] = idx
+	}
+
+	return w, nil
+}
+
+// ObjectPackfile returns a reader of the object pack with the given hash.
+func (s *ObjectStorage) ObjectPackfile(h plumbing.Hash) (io.ReadCloser, error) {
+	return s.dir.ObjectPack(h)
+}
+
 // SetEncodedObject adds a new object to the storage.
 func (s *ObjectStorage) SetEncodedObject(o plumbing.EncodedObject) (h plumbing.Hash, err error) {
 	if o.Type() == plumbing.OFSDeltaObject || o.Type() == plumbing.REFDeltaObject {
@@ -171,6 +421,7 @@ func (s *ObjectStorage) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (p
 				if oe != nil {
 					continue
 				}
+				o.bigFileThreshold = s.bigFileThreshold
 				enobj, enerr := o.EncodedObject(t, h)
 				if enerr != nil {
 					continue
@@ -236,6 +487,12 @@ func (s *ObjectStorage) getFromUnpacked(h plumbing.Hash) (obj plumbing.EncodedOb
 		return nil, err
 	}
 
+	if size > s.bigFileThreshold {
+		return newBigObject(h, t, size, func() (io.ReadCloser, error) {
+			return s.openUnpacked(h)
+		}), nil
+	}
+
 	obj.SetType(t)
 	obj.SetSize(size)
 	w, err := obj.Writer()
@@ -247,6 +504,29 @@ func (s *ObjectStorage) getFromUnpacked(h plumbing.Hash) (obj plumbing.EncodedOb
 	return obj, err
 }
 
+// openUnpacked returns a reader of the content of the loose object with the
+// given hash.
+func (s *ObjectStorage) openUnpacked(h plumbing.Hash) (io.ReadCloser, error) {
+	f, err := s.dir.Object(h)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := objfile.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if _, _, err := r.Header(); err != nil {
+		r.Close()
+		f.Close()
+		return nil, err
+	}
+
+	return ioutil.NewReadCloser(r, closers{r, f}), nil
+}
+
 // Get returns the object with the given hash, by searching for it in
 // the packfile.
 func (s *ObjectStorage) getFromPackfile(h plumbing.Hash, canBeDelta bool) (
@@ -268,17 +548,114 @@ func (s *ObjectStorage) getFromPackfile(h plumbing.Hash, canBeDelta bool) (
 
 	defer ioutil.CheckClose(f, &err)
 
+	big, err := s.getBigFromPackfile(f, pack, hash, offset)
+	if big != nil || err != nil {
+		return big, err
+	}
+
 	idx := s.index[pack]
 	if canBeDelta {
-		return s.decodeDeltaObjectAt(f, idx, offset, hash)
+		return s.decodeDeltaObjectAt(f, idx, s.deltaChainCache(pack), offset, hash)
+	}
+
+	return s.decodeObjectAt(f, idx, s.deltaChainCache(pack), offset)
+}
+
+// getBigFromPackfile returns the object at the given offset of the packfile
+// as a plumbing.BigObject, if it is over the bigFileThreshold and not a delta.
+func (s *ObjectStorage) getBigFromPackfile(
+	f billy.File,
+	pack plumbing.Hash,
+	hash plumbing.Hash,
+	offset int64) (plumbing.EncodedObject, error) {
+	p := packfile.NewScanner(f)
+	if _, err := p.SeekFromStart(offset); err != nil {
+		return nil, err
+	}
+
+	header, err := p.NextObjectHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	if header.Type.IsDelta() || header.Length <= s.bigFileThreshold {
+		return nil, nil
+	}
+
+	return newBigObject(hash, header.Type, header.Length, func() (io.ReadCloser, error) {
+		return s.openPacked(pack, offset)
+	}), nil
+}
+
+// openPacked returns a reader of the content of the non-delta object at the
+// given offset of the packfile, inflated while read.
+func (s *ObjectStorage) openPacked(pack plumbing.Hash, offset int64) (io.ReadCloser, error) {
+	f, err := s.dir.ObjectPack(pack)
+	if err != nil {
+		return nil, err
+	}
+
+	p := packfile.NewScanner(f)
+	if _, err := p.SeekFromStart(offset); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if _, err := p.NextObjectHeader(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, _, err := p.NextObject(pw)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+
+		pw.CloseWithError(err)
+	}()
+
+	return pr, nil
+}
+
+// closers closes all its io.Closer, returning the first error.
+type closers []io.Closer
+
+func (cs closers) Close() error {
+	var err error
+	for _, c := range cs {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+// deltaChainCache returns the cache of the objects resolved along the delta
+// chains of the given packfile.
+func (s *ObjectStorage) deltaChainCache(pack plumbing.Hash) cache.Offset {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.deltaChainCaches == nil {
+		s.deltaChainCaches = make(map[plumbing.Hash]cache.Offset)
+	}
+
+	c, ok := s.deltaChainCaches[pack]
+	if !ok {
+		c = cache.NewOffsetLRUDefault()
+		s.deltaChainCaches[pack] = c
 	}
 
-	return s.decodeObjectAt(f, idx, offset)
+	return c
 }
 
 func (s *ObjectStorage) decodeObjectAt(
 	f billy.File,
 	idx *packfile.Index,
+	chain cache.Offset,
 	offset int64) (plumbing.EncodedObject, error) {
 	if _, err := f.Seek(0, io.SeekStart); err != nil {
 		return nil, err
@@ -293,6 +670,7 @@ func (s *ObjectStorage) decodeObjectAt(
 	}
 
 	d.SetIndex(idx)
+	d.SetDeltaChainCache(chain)
 	obj, err := d.DecodeObjectAt(offset)
 	return obj, err
 }
@@ -300,6 +678,7 @@ func (s *ObjectStorage) decodeObjectAt(
 func (s *ObjectStorage) decodeDeltaObjectAt(
 	f billy.File,
 	idx *packfile.Index,
+	chain cache.Offset,
 	offset int64,
 	hash plumbing.Hash) (plumbing.EncodedObject, error) {
 	if _, err := f.Seek(0, io.SeekStart); err != nil {
@@ -331,7 +710,7 @@ func (s *ObjectStorage) decodeDeltaObjectAt(
 
 		base = e.Hash
 	default:
-		return s.decodeObjectAt(f, idx, offset)
+		return s.decodeObjectAt(f, idx, chain, offset)
 	}
 
 	obj := &plumbing.MemoryObject{}
@@ -590,5 +969,32 @@ func (s *ObjectStorage) ObjectPacks() ([]plumbing.Hash, error) {
 }
 
 func (s *ObjectStorage) DeleteOldObjectPackAndIndex(h plumbing.Hash, t time.Time) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	// The index is reloaded on the next use, without the deleted pack.
+	s.index = nil
+	delete(s.deltaChainCaches, h)
 	return s.dir.DeleteOldObjectPackAndIndex(h, t)
 }
+
+// ObjectPackHashes returns the hashes of the objects in the object pack with
+// the given hash.
+func (s *ObjectStorage) ObjectPackHashes(pack plumbing.Hash) ([]plumbing.Hash, error) {
+	if err := s.requireIndex(); err != nil {
+		return nil, err
+	}
+
+	idx, ok := s.index[pack]
+	if !ok {
+		return nil, plumbing.ErrObjectNotFound
+	}
+
+	entries := idx.ToIdxFile().Entries
+	hashes := make([]plumbing.Hash, len(entries))
+	for i, e := range entries {
+		hashes[i] = e.Hash
+	}
+
+	return hashes, nil
+}
@@ -1,10 +1,16 @@
 package filesystem
 
 import (
+	"bytes"
+	"time"
+
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/commitgraph"
 	"gopkg.in/src-d/go-git.v4/storage/filesystem/dotgit"
 
 	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
 	"gopkg.in/src-d/go-git-fixtures.v3"
 )
 
@@ -128,3 +134,99 @@ func (s *FsSuite) TestPackfileIter(c *C) {
 	})
 
 }
+
+func (s *FsSuite) TestCommitGraph(c *C) {
+	root := plumbing.NewHash("289f376f226198fad43f884d6e6bda458fbe8528")
+	child := plumbing.NewHash("48cd64650b5bb64d4848769aebe795d246a44c28")
+
+	base := commitgraph.New()
+	base.Add(&commitgraph.CommitData{Hash: root, Generation: 1, When: time.Unix(0, 0)})
+	layer := commitgraph.New()
+	layer.Base = base
+	layer.Add(&commitgraph.CommitData{
+		Hash: child, ParentHashes: []plumbing.Hash{root},
+		Generation: 2, When: time.Unix(0, 0),
+	})
+
+	fs := memfs.New()
+	for _, g := range []*commitgraph.CommitGraph{base, layer} {
+		buf := bytes.NewBuffer(nil)
+		c.Assert(commitgraph.NewEncoder(buf).Encode(g), IsNil)
+
+		name := "objects/info/commit-graphs/graph-" + g.Checksum.String() + ".graph"
+		c.Assert(util.WriteFile(fs, name, buf.Bytes(), 0444), IsNil)
+	}
+
+	o, err := NewObjectStorage(dotgit.New(fs))
+	c.Assert(err, IsNil)
+
+	g, err := o.CommitGraph()
+	c.Assert(err, IsNil)
+	c.Assert(g, IsNil)
+
+	chain := base.Checksum.String() + "\n" + layer.Checksum.String() + "\n"
+	err = util.WriteFile(fs, "objects/info/commit-graphs/commit-graph-chain", []byte(chain), 0444)
+	c.Assert(err, IsNil)
+
+	o, err = NewObjectStorage(dotgit.New(fs))
+	c.Assert(err, IsNil)
+
+	g, err = o.CommitGraph()
+	c.Assert(err, IsNil)
+	c.Assert(g.Len(), Equals, 2)
+
+	generation, ok := g.Generation(child)
+	c.Assert(ok, Equals, true)
+	c.Assert(generation, Equals, uint64(2))
+}
+
+func (s *FsSuite) TestBigObject(c *C) {
+	for _, f := range []*fixtures.Fixture{
+		fixtures.Basic().ByTag(".git").One(),
+		fixtures.ByTag(".git").ByTag("unpacked").One(),
+	} {
+		fs := f.DotGit()
+		o, err := NewObjectStorage(dotgit.New(fs))
+		c.Assert(err, IsNil)
+
+		big, err := NewObjectStorage(dotgit.New(fs))
+		c.Assert(err, IsNil)
+		big.SetBigFileThreshold(16)
+
+		iter, err := o.IterEncodedObjects(plumbing.BlobObject)
+		c.Assert(err, IsNil)
+
+		var count int
+		err = iter.ForEach(func(expected plumbing.EncodedObject) error {
+			obj, err := big.EncodedObject(plumbing.BlobObject, expected.Hash())
+			c.Assert(err, IsNil)
+			c.Assert(obj.Size(), Equals, expected.Size())
+
+			if !plumbing.IsBigObject(obj) {
+				return nil
+			}
+
+			count++
+			c.Assert(obj.Size() > 16, Equals, true)
+			c.Assert(objectContent(c, obj), DeepEquals, objectContent(c, expected))
+
+			_, err = obj.Writer()
+			c.Assert(err, Equals, ErrBigObjectReadOnly)
+			return nil
+		})
+		c.Assert(err, IsNil)
+		c.Assert(count > 0, Equals, true)
+	}
+}
+
+func objectContent(c *C, obj plumbing.EncodedObject) []byte {
+	r, err := obj.Reader()
+	c.Assert(err, IsNil)
+
+	buf := bytes.NewBuffer(nil)
+	_, err = buf.ReadFrom(r)
+	c.Assert(err, IsNil)
+	c.Assert(r.Close(), IsNil)
+
+	return buf.Bytes()
+}
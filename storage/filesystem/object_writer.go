@@ -0,0 +1,136 @@
+package filesystem
+
+import (
+	"io"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem/dotgit"
+
+	"gopkg.in/src-d/go-billy.v4"
+)
+
+// ObjectWriter returns a storer.ObjectWriter writing a loose object as its
+// content is written. The content of an object of unknown size, a negative
+// size, is written to a temporary file until the writer is closed.
+func (s *ObjectStorage) ObjectWriter(t plumbing.ObjectType, size int64) (storer.ObjectWriter, error) {
+	if !t.Valid() || t.IsDelta() {
+		return nil, plumbing.ErrInvalidType
+	}
+
+	if size < 0 {
+		f, err := s.dir.NewObjectTempFile()
+		if err != nil {
+			return nil, err
+		}
+
+		return &spooledObjectWriter{s: s, t: t, f: f}, nil
+	}
+
+	return s.newLooseObjectWriter(t, size)
+}
+
+func (s *ObjectStorage) newLooseObjectWriter(t plumbing.ObjectType, size int64) (*looseObjectWriter, error) {
+	w, err := s.dir.NewObject()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.WriteHeader(t, size); err != nil {
+		w.Abort()
+		return nil, err
+	}
+
+	return &looseObjectWriter{w: w, size: size}, nil
+}
+
+// looseObjectWriter writes a loose object of known size, the object is
+// discarded if its content is not of that size.
+type looseObjectWriter struct {
+	w    *dotgit.ObjectWriter
+	size int64
+	n    int64
+	err  error
+	h    plumbing.Hash
+}
+
+func (w *looseObjectWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+
+	return n, err
+}
+
+func (w *looseObjectWriter) Close() error {
+	if w.err == nil && w.n != w.size {
+		w.err = storer.ErrObjectSizeMismatch
+	}
+
+	if w.err != nil {
+		w.w.Abort()
+		return w.err
+	}
+
+	if err := w.w.Close(); err != nil {
+		return err
+	}
+
+	w.h = w.w.Hash()
+	return nil
+}
+
+func (w *looseObjectWriter) Hash() plumbing.Hash {
+	return w.h
+}
+
+// spooledObjectWriter writes the content of an object of unknown size to a
+// temporary file, the object is written once its size is known.
+type spooledObjectWriter struct {
+	s *ObjectStorage
+	t plumbing.ObjectType
+	f billy.File
+	n int64
+	h plumbing.Hash
+}
+
+func (w *spooledObjectWriter) Write(p []byte) (int, error) {
+	n, err := w.f.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+func (w *spooledObjectWriter) Close() (err error) {
+	defer func() {
+		if rerr := w.s.dir.RemoveObjectTempFile(w.f); rerr != nil && err == nil {
+			err = rerr
+		}
+	}()
+
+	if _, err := w.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	lw, err := w.s.newLooseObjectWriter(w.t, w.n)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(lw, w.f); err != nil {
+		lw.Close()
+		return err
+	}
+
+	if err := lw.Close(); err != nil {
+		return err
+	}
+
+	w.h = lw.Hash()
+	return nil
+}
+
+func (w *spooledObjectWriter) Hash() plumbing.Hash {
+	return w.h
+}
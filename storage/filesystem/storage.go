@@ -2,6 +2,7 @@
 package filesystem
 
 import (
+	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/storage/filesystem/dotgit"
 
 	"gopkg.in/src-d/go-billy.v4"
@@ -30,7 +31,7 @@ func NewStorage(fs billy.Filesystem) (*Storage, error) {
 		return nil, err
 	}
 
-	return &Storage{
+	s := &Storage{
 		fs:  fs,
 		dir: dir,
 
@@ -40,7 +41,36 @@ func NewStorage(fs billy.Filesystem) (*Storage, error) {
 		ShallowStorage:   ShallowStorage{dir: dir},
 		ConfigStorage:    ConfigStorage{dir: dir},
 		ModuleStorage:    ModuleStorage{dir: dir},
-	}, nil
+	}
+
+	// a malformed config is reported when read by the repository
+	if cfg, err := s.ConfigStorage.Config(); err == nil {
+		s.SetBigFileThreshold(cfg.Core.BigFileThreshold)
+	}
+
+	return s, nil
+}
+
+// SetConfig stores the given config, applying its core.bigFileThreshold to
+// the objects read from now on.
+func (s *Storage) SetConfig(cfg *config.Config) error {
+	if err := s.ConfigStorage.SetConfig(cfg); err != nil {
+		return err
+	}
+
+	s.SetBigFileThreshold(cfg.Core.BigFileThreshold)
+	return nil
+}
+
+// SetWorktreeConfig stores the given config as ConfigStorage.SetWorktreeConfig
+// does, applying its core.bigFileThreshold as SetConfig does.
+func (s *Storage) SetWorktreeConfig(cfg *config.Config) error {
+	if err := s.ConfigStorage.SetWorktreeConfig(cfg); err != nil {
+		return err
+	}
+
+	s.SetBigFileThreshold(cfg.Core.BigFileThreshold)
+	return nil
 }
 
 // Filesystem returns the underlying filesystem
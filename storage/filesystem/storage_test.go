@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"testing"
 
+	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 	"gopkg.in/src-d/go-git.v4/storage/test"
 
@@ -33,6 +34,9 @@ func (s *StorageSuite) SetUpTest(c *C) {
 	var _ storer.ShallowStorer = storage
 	var _ storer.DeltaObjectStorer = storage
 	var _ storer.PackfileWriter = storage
+	var _ storer.StreamPackfileWriter = storage
+	var _ storer.ObjectWriterStorer = storage
+	var _ storer.PackfileOpener = storage
 
 	s.BaseStorageSuite = test.NewBaseStorageSuite(storage)
 	s.BaseStorageSuite.SetUpTest(c)
@@ -46,6 +50,22 @@ func (s *StorageSuite) TestFilesystem(c *C) {
 	c.Assert(storage.Filesystem(), Equals, fs)
 }
 
+func (s *StorageSuite) TestBigFileThreshold(c *C) {
+	fs := memfs.New()
+	storage, err := NewStorage(fs)
+	c.Assert(err, IsNil)
+	c.Assert(storage.bigFileThreshold, Equals, config.DefaultBigFileThreshold)
+
+	cfg := config.NewConfig()
+	cfg.Core.BigFileThreshold = 1024
+	c.Assert(storage.SetConfig(cfg), IsNil)
+	c.Assert(storage.bigFileThreshold, Equals, int64(1024))
+
+	storage, err = NewStorage(fs)
+	c.Assert(err, IsNil)
+	c.Assert(storage.bigFileThreshold, Equals, int64(1024))
+}
+
 func (s *StorageSuite) TestNewStorageShouldNotAddAnyContentsToDir(c *C) {
 	fis, err := ioutil.ReadDir(s.dir)
 	c.Assert(err, IsNil)
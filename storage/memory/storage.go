@@ -13,7 +13,7 @@ import (
 )
 
 var ErrUnsupportedObjectType = fmt.Errorf("unsupported object type")
-var ErrRefHasChanged = fmt.Errorf("reference has changed concurrently")
+var ErrRefHasChanged = storer.ErrReferenceHasChanged
 
 // Storage is an implementation of git.Storer that stores data on memory, being
 // ephemeral. The use of this storage should be done in controlled envoriments,
@@ -264,9 +264,15 @@ func (r ReferenceStorage) Reference(n plumbing.ReferenceName) (*plumbing.Referen
 	return ref, nil
 }
 
+// IterReferences iterates the references, excluding the pseudo-references, as
+// FETCH_HEAD, as the filesystem storage does.
 func (r ReferenceStorage) IterReferences() (storer.ReferenceIter, error) {
 	var refs []*plumbing.Reference
 	for _, ref := range r {
+		if isPseudoReference(ref.Name()) {
+			continue
+		}
+
 		refs = append(refs, ref)
 	}
 
@@ -286,6 +292,10 @@ func (r ReferenceStorage) RemoveReference(n plumbing.ReferenceName) error {
 	return nil
 }
 
+func isPseudoReference(n plumbing.ReferenceName) bool {
+	return n == plumbing.FetchHead || n == plumbing.OrigHead || n == plumbing.MergeHead
+}
+
 type ShallowStorage []plumbing.Hash
 
 func (s *ShallowStorage) SetShallow(commits []plumbing.Hash) error {
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+// NamespaceEnvironment is the name of the environment variable holding the
+// namespace of the references served, as used by git.
+const NamespaceEnvironment = "GIT_NAMESPACE"
+
+// NamespacePrefix returns the prefix of the names of the references of the
+// given namespace, e.g. refs/namespaces/foo/ for foo. The namespaces can be
+// nested splitting them by /, foo/bar is refs/namespaces/foo/refs/namespaces/bar/.
+func NamespacePrefix(namespace string) string {
+	var prefix string
+	for _, ns := range strings.Split(namespace, "/") {
+		if ns != "" {
+			prefix += "refs/namespaces/" + ns + "/"
+		}
+	}
+
+	return prefix
+}
+
+// NewNamespacedStorer returns a Storer viewing only the references of the
+// given namespace of s, as GIT_NAMESPACE does, so a single repository can
+// hold several logical ones. A reference named refs/heads/master is stored
+// at s as refs/namespaces/<namespace>/refs/heads/master, and HEAD as
+// refs/namespaces/<namespace>/HEAD. The objects, config, index and shallow
+// commits are the ones of s, shared by all the namespaces.
+func NewNamespacedStorer(s Storer, namespace string) Storer {
+	return &namespacedStorer{
+		Storer: s,
+		refs:   NewNamespacedReferenceStorer(s, namespace),
+	}
+}
+
+type namespacedStorer struct {
+	Storer
+	refs storer.ReferenceStorer
+}
+
+func (s *namespacedStorer) SetReference(ref *plumbing.Reference) error {
+	return s.refs.SetReference(ref)
+}
+
+func (s *namespacedStorer) CheckAndSetReference(new, old *plumbing.Reference) error {
+	return s.refs.CheckAndSetReference(new, old)
+}
+
+func (s *namespacedStorer) Reference(n plumbing.ReferenceName) (*plumbing.Reference, error) {
+	return s.refs.Reference(n)
+}
+
+func (s *namespacedStorer) IterReferences() (storer.ReferenceIter, error) {
+	return s.refs.IterReferences()
+}
+
+func (s *namespacedStorer) RemoveReference(n plumbing.ReferenceName) error {
+	return s.refs.RemoveReference(n)
+}
+
+// NewNamespacedReferenceStorer returns a storer.ReferenceStorer viewing only
+// the references of the given namespace of s, see NewNamespacedStorer. The
+// loose references are counted and packed for the whole s.
+func NewNamespacedReferenceStorer(s storer.ReferenceStorer, namespace string) storer.ReferenceStorer {
+	return &namespacedReferenceStorer{
+		ReferenceStorer: s,
+		prefix:          NamespacePrefix(namespace),
+	}
+}
+
+type namespacedReferenceStorer struct {
+	storer.ReferenceStorer
+	prefix string
+}
+
+func (s *namespacedReferenceStorer) SetReference(ref *plumbing.Reference) error {
+	return s.ReferenceStorer.SetReference(s.toStored(ref))
+}
+
+func (s *namespacedReferenceStorer) CheckAndSetReference(new, old *plumbing.Reference) error {
+	if old != nil {
+		old = s.toStored(old)
+	}
+
+	return s.ReferenceStorer.CheckAndSetReference(s.toStored(new), old)
+}
+
+func (s *namespacedReferenceStorer) Reference(n plumbing.ReferenceName) (*plumbing.Reference, error) {
+	ref, err := s.ReferenceStorer.Reference(s.storedName(n))
+	if err != nil {
+		return nil, err
+	}
+
+	ref, _ = s.fromStored(ref)
+	return ref, nil
+}
+
+func (s *namespacedReferenceStorer) IterReferences() (storer.ReferenceIter, error) {
+	iter, err := s.ReferenceStorer.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []*plumbing.Reference
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref, ok := s.fromStored(ref); ok {
+			refs = append(refs, ref)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return storer.NewReferenceSliceIter(refs), nil
+}
+
+func (s *namespacedReferenceStorer) RemoveReference(n plumbing.ReferenceName) error {
+	return s.ReferenceStorer.RemoveReference(s.storedName(n))
+}
+
+func (s *namespacedReferenceStorer) storedName(n plumbing.ReferenceName) plumbing.ReferenceName {
+	return plumbing.ReferenceName(s.prefix + n.String())
+}
+
+// toStored returns the reference as stored at the underlying storer, with
+// its name, and target if symbolic, inside the namespace.
+func (s *namespacedReferenceStorer) toStored(ref *plumbing.Reference) *plumbing.Reference {
+	if ref.Type() == plumbing.SymbolicReference {
+		return plumbing.NewSymbolicReference(s.storedName(ref.Name()), s.storedName(ref.Target()))
+	}
+
+	return plumbing.NewHashReference(s.storedName(ref.Name()), ref.Hash())
+}
+
+// fromStored returns the reference as seen from the namespace, false if it
+// is outside of it. The symbolic targets outside of the namespace are kept.
+func (s *namespacedReferenceStorer) fromStored(ref *plumbing.Reference) (*plumbing.Reference, bool) {
+	name := ref.Name().String()
+	if !strings.HasPrefix(name, s.prefix) {
+		return nil, false
+	}
+
+	n := plumbing.ReferenceName(name[len(s.prefix):])
+	if ref.Type() == plumbing.SymbolicReference {
+		target := strings.TrimPrefix(ref.Target().String(), s.prefix)
+		return plumbing.NewSymbolicReference(n, plumbing.ReferenceName(target)), true
+	}
+
+	return plumbing.NewHashReference(n, ref.Hash()), true
+}
@@ -0,0 +1,80 @@
+package storage_test
+
+import (
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+)
+
+type NamespaceSuite struct{}
+
+var _ = Suite(&NamespaceSuite{})
+
+var namespaceHash = plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+func (s *NamespaceSuite) TestNamespacePrefix(c *C) {
+	c.Assert(storage.NamespacePrefix("foo"), Equals, "refs/namespaces/foo/")
+	c.Assert(storage.NamespacePrefix("foo/bar/"), Equals, "refs/namespaces/foo/refs/namespaces/bar/")
+	c.Assert(storage.NamespacePrefix(""), Equals, "")
+}
+
+func (s *NamespaceSuite) TestSetReference(c *C) {
+	sto := memory.NewStorage()
+	ns := storage.NewNamespacedStorer(sto, "foo")
+
+	c.Assert(ns.SetReference(plumbing.NewHashReference(plumbing.Master, namespaceHash)), IsNil)
+	c.Assert(ns.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.Master)), IsNil)
+
+	ref, err := sto.Reference("refs/namespaces/foo/refs/heads/master")
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, namespaceHash)
+
+	ref, err = sto.Reference("refs/namespaces/foo/HEAD")
+	c.Assert(err, IsNil)
+	c.Assert(ref.Target(), Equals, plumbing.ReferenceName("refs/namespaces/foo/refs/heads/master"))
+
+	_, err = sto.Reference(plumbing.Master)
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	ref, err = storer.ResolveReference(ns, plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Name(), Equals, plumbing.Master)
+	c.Assert(ref.Hash(), Equals, namespaceHash)
+}
+
+func (s *NamespaceSuite) TestIterReferences(c *C) {
+	sto := memory.NewStorage()
+	c.Assert(sto.SetReference(plumbing.NewHashReference("refs/namespaces/foo/refs/heads/master", namespaceHash)), IsNil)
+	c.Assert(sto.SetReference(plumbing.NewHashReference("refs/namespaces/bar/refs/heads/master", namespaceHash)), IsNil)
+	c.Assert(sto.SetReference(plumbing.NewHashReference(plumbing.Master, namespaceHash)), IsNil)
+
+	iter, err := storage.NewNamespacedStorer(sto, "foo").IterReferences()
+	c.Assert(err, IsNil)
+
+	var names []plumbing.ReferenceName
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		names = append(names, ref.Name())
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(names, DeepEquals, []plumbing.ReferenceName{plumbing.Master})
+}
+
+func (s *NamespaceSuite) TestCheckAndSetReference(c *C) {
+	sto := memory.NewStorage()
+	ns := storage.NewNamespacedStorer(sto, "foo")
+
+	old := plumbing.NewHashReference(plumbing.Master, namespaceHash)
+	c.Assert(ns.SetReference(old), IsNil)
+
+	new := plumbing.NewHashReference(plumbing.Master, plumbing.ZeroHash)
+	c.Assert(ns.CheckAndSetReference(new, old), IsNil)
+	c.Assert(ns.CheckAndSetReference(old, old), Equals, storer.ErrReferenceHasChanged)
+
+	c.Assert(ns.RemoveReference(plumbing.Master), IsNil)
+	_, err := sto.Reference("refs/namespaces/foo/refs/heads/master")
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
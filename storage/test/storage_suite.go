@@ -1,6 +1,7 @@
 package test
 
 import (
+	"bytes"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -179,6 +180,42 @@ func (s *BaseStorageSuite) TestPackfileWriter(c *C) {
 	c.Assert(objects, Equals, 31)
 }
 
+func (s *BaseStorageSuite) TestStoreObject(c *C) {
+	expected := plumbing.NewHash("19102815663d23f8b75a47e7a01965dcdc96468c")
+	for _, size := range []int64{3, -1} {
+		comment := Commentf("failed for size %d", size)
+
+		h, err := storer.StoreObject(s.Storer, plumbing.BlobObject, size, bytes.NewBufferString("foo"))
+		c.Assert(err, IsNil, comment)
+		c.Assert(h, Equals, expected, comment)
+
+		o, err := s.Storer.EncodedObject(plumbing.BlobObject, h)
+		c.Assert(err, IsNil, comment)
+		c.Assert(o.Size(), Equals, int64(3), comment)
+
+		r, err := o.Reader()
+		c.Assert(err, IsNil, comment)
+		content, err := ioutil.ReadAll(r)
+		c.Assert(err, IsNil, comment)
+		c.Assert(r.Close(), IsNil, comment)
+		c.Assert(string(content), Equals, "foo", comment)
+	}
+}
+
+func (s *BaseStorageSuite) TestStoreObjectSizeMismatch(c *C) {
+	for _, size := range []int64{2, 4} {
+		_, err := storer.StoreObject(s.Storer, plumbing.BlobObject, size, bytes.NewBufferString("foo"))
+		c.Assert(err, NotNil)
+	}
+
+	iter, err := s.Storer.IterEncodedObjects(plumbing.AnyObject)
+	c.Assert(err, IsNil)
+	err = iter.ForEach(func(o plumbing.EncodedObject) error {
+		return fmt.Errorf("unexpected object %s", o.Hash())
+	})
+	c.Assert(err, IsNil)
+}
+
 func (s *BaseStorageSuite) TestObjectStorerTxSetEncodedObjectAndCommit(c *C) {
 	storer, ok := s.Storer.(storer.Transactioner)
 	if !ok {
@@ -5,16 +5,39 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 
 	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/util"
 	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
 )
 
 var (
 	ErrSubmoduleAlreadyInitialized = errors.New("submodule already initialized")
 	ErrSubmoduleNotInitialized     = errors.New("submodule not initialized")
+	// ErrSubmoduleBranchDetached is returned updating a submodule to the tip
+	// of the branch "." while the HEAD of the superproject is detached.
+	ErrSubmoduleBranchDetached = errors.New("submodule branch is '.' but the superproject HEAD is detached")
+	// ErrSubmoduleCommitBeyondDepth is returned updating a submodule fetched
+	// with a depth not reaching the commit recorded at the superproject.
+	ErrSubmoduleCommitBeyondDepth = errors.New("submodule commit not fetched within the depth")
+	// ErrSubmoduleGitDirExists is returned absorbing the git directory of a
+	// submodule whose git directory at the superproject already exists.
+	ErrSubmoduleGitDirExists = errors.New("submodule git directory already exists")
+	// ErrSubmoduleAbsorbNotSupported is returned absorbing the git directory
+	// of a submodule of a superproject whose storage isn't based on a
+	// filesystem.
+	ErrSubmoduleAbsorbNotSupported = errors.New("submodule git directories can only be absorbed by a filesystem storage")
+	// ErrSubmoduleModified is returned deinitializing, without Force, a
+	// submodule whose worktree contains local modifications.
+	ErrSubmoduleModified = errors.New("submodule worktree contains local modifications")
 )
 
 // Submodule a submodule allows you to keep another Git repository in a
@@ -158,6 +181,10 @@ func (s *Submodule) UpdateContext(ctx context.Context, o *SubmoduleUpdateOptions
 }
 
 func (s *Submodule) update(ctx context.Context, o *SubmoduleUpdateOptions, forceHash plumbing.Hash) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
 	if !s.initialized && !o.Init {
 		return ErrSubmoduleNotInitialized
 	}
@@ -168,6 +195,15 @@ func (s *Submodule) update(ctx context.Context, o *SubmoduleUpdateOptions, force
 		}
 	}
 
+	mode, err := s.updateMode(o)
+	if err != nil {
+		return err
+	}
+
+	if mode == NoneSubmoduleUpdate {
+		return nil
+	}
+
 	idx, err := s.w.r.Storer.Index()
 	if err != nil {
 		return err
@@ -188,14 +224,33 @@ func (s *Submodule) update(ctx context.Context, o *SubmoduleUpdateOptions, force
 		return err
 	}
 
-	if err := s.fetchAndCheckout(ctx, r, o, hash); err != nil {
+	if err := s.fetchAndCheckout(ctx, r, o, hash, mode); err != nil {
 		return err
 	}
 
-	return s.doRecursiveUpdate(r, o)
+	return s.doRecursiveUpdate(ctx, r, o)
+}
+
+// updateMode returns the mode the submodule is updated with, the one of the
+// options or else submodule.<name>.update.
+func (s *Submodule) updateMode(o *SubmoduleUpdateOptions) (SubmoduleUpdateMode, error) {
+	mode := o.Mode
+	if mode == "" {
+		mode = SubmoduleUpdateMode(s.c.Update)
+	}
+
+	switch mode {
+	case "":
+		return CheckoutSubmoduleUpdate, nil
+	case CheckoutSubmoduleUpdate, RebaseSubmoduleUpdate,
+		MergeSubmoduleUpdate, NoneSubmoduleUpdate:
+		return mode, nil
+	}
+
+	return "", ErrInvalidSubmoduleUpdateMode
 }
 
-func (s *Submodule) doRecursiveUpdate(r *Repository, o *SubmoduleUpdateOptions) error {
+func (s *Submodule) doRecursiveUpdate(ctx context.Context, r *Repository, o *SubmoduleUpdateOptions) error {
 	if o.RecurseSubmodules == NoRecurseSubmodules {
 		return nil
 	}
@@ -214,17 +269,47 @@ func (s *Submodule) doRecursiveUpdate(r *Repository, o *SubmoduleUpdateOptions)
 	*new = *o
 
 	new.RecurseSubmodules--
-	return l.Update(new)
+	return l.UpdateContext(ctx, new)
 }
 
 func (s *Submodule) fetchAndCheckout(
-	ctx context.Context, r *Repository, o *SubmoduleUpdateOptions, hash plumbing.Hash,
+	ctx context.Context, r *Repository, o *SubmoduleUpdateOptions, hash plumbing.Hash, mode SubmoduleUpdateMode,
 ) error {
+	head, err := r.Head()
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return err
+	}
+
+	// the submodules not checked out yet are cloned
+	cloned := err == plumbing.ErrReferenceNotFound
+
 	if !o.NoFetch {
-		err := r.FetchContext(ctx, &FetchOptions{Auth: o.Auth})
+		depth := o.Depth
+		if depth == 0 && cloned && s.c.Shallow && !o.NoRecommendShallow {
+			depth = 1
+		}
+
+		err := r.FetchContext(ctx, &FetchOptions{Auth: o.Auth, Depth: depth})
 		if err != nil && err != NoErrAlreadyUpToDate {
 			return err
 		}
+
+		if depth > 0 && !o.Remote {
+			err := r.Storer.HasEncodedObject(hash)
+			if err == plumbing.ErrObjectNotFound {
+				return ErrSubmoduleCommitBeyondDepth
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if o.Remote {
+		if hash, err = s.remoteHash(r, o); err != nil {
+			return err
+		}
 	}
 
 	w, err := r.Worktree()
@@ -232,12 +317,312 @@ func (s *Submodule) fetchAndCheckout(
 		return err
 	}
 
-	if err := w.Checkout(&CheckoutOptions{Hash: hash}); err != nil {
+	if !cloned && mode != CheckoutSubmoduleUpdate {
+		return s.fastForward(ctx, r, w, head.Hash(), hash)
+	}
+
+	if err := w.CheckoutContext(ctx, &CheckoutOptions{Hash: hash}); err != nil {
+		return err
+	}
+
+	ref := plumbing.NewHashReference(plumbing.HEAD, hash)
+	return r.Storer.SetReference(ref)
+}
+
+// fastForward updates the current branch of the submodule to the commit, as
+// the merge and rebase update modes do, only fast-forwards are supported. A
+// commit already merged into the branch leaves it untouched.
+func (s *Submodule) fastForward(ctx context.Context, r *Repository, w *Worktree, head, hash plumbing.Hash) error {
+	if head == hash {
+		return nil
+	}
+
+	ff, err := isFastForward(r.Storer, head, hash)
+	if err != nil {
+		return err
+	}
+
+	if !ff {
+		merged, err := isFastForward(r.Storer, hash, head)
+		if err != nil {
+			return err
+		}
+
+		if merged {
+			return nil
+		}
+
+		return ErrNonFastForwardUpdate
+	}
+
+	if err := w.updateHEAD(hash); err != nil {
+		return err
+	}
+
+	return w.resetContext(ctx, &ResetOptions{Mode: MergeReset, Commit: hash})
+}
+
+// remoteHash returns the tip of the remote-tracking branch the submodule is
+// updated to with SubmoduleUpdateOptions.Remote.
+func (s *Submodule) remoteHash(r *Repository, o *SubmoduleUpdateOptions) (plumbing.Hash, error) {
+	branch := s.c.Branch
+	if branch == "." {
+		head, err := s.w.r.Storer.Reference(plumbing.HEAD)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		if head.Type() != plumbing.SymbolicReference {
+			return plumbing.ZeroHash, ErrSubmoduleBranchDetached
+		}
+
+		branch = strings.TrimPrefix(head.Target().String(), "refs/heads/")
+	}
+
+	if branch == "" {
+		branch = "HEAD"
+	}
+
+	name := plumbing.ReferenceName(fmt.Sprintf("refs/remotes/%s/%s", DefaultRemoteName, branch))
+	ref, err := r.Reference(name, true)
+	if err == nil {
+		return ref.Hash(), nil
+	}
+
+	if err != plumbing.ErrReferenceNotFound || branch != "HEAD" || o.NoFetch {
+		return plumbing.ZeroHash, err
+	}
+
+	// the remote HEAD isn't fetched, it's asked to the remote
+	remote, err := r.Remote(DefaultRemoteName)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	refs, err := remote.List(&ListOptions{
+		Auth:        o.Auth,
+		RefPrefixes: []string{plumbing.HEAD.String(), "refs/heads/"},
+	})
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	byName := make(map[plumbing.ReferenceName]*plumbing.Reference, len(refs))
+	for _, ref := range refs {
+		byName[ref.Name()] = ref
+	}
+
+	ref = byName[plumbing.HEAD]
+	for i := 0; ref != nil && ref.Type() == plumbing.SymbolicReference && i < 10; i++ {
+		ref = byName[ref.Target()]
+	}
+
+	if ref == nil || ref.Type() != plumbing.HashReference {
+		return plumbing.ZeroHash, plumbing.ErrReferenceNotFound
+	}
+
+	return ref.Hash(), nil
+}
+
+// AbsorbGitDir moves the git directory embedded at the worktree of the
+// submodule, if any, into the git directory of the superproject, at
+// modules/<name>, replacing it with a .git file pointing to it, as git
+// submodule absorbgitdirs does.
+func (s *Submodule) AbsorbGitDir() error {
+	wt, err := s.w.Filesystem.Chroot(s.c.Path)
+	if err != nil {
+		return err
+	}
+
+	fi, err := wt.Lstat(GitDirName)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !fi.IsDir() {
+		// already pointing to its git directory
+		return nil
+	}
+
+	sto, err := s.w.r.Storer.Module(s.c.Name)
+	if err != nil {
+		return err
+	}
+
+	fsBased, ok := sto.(interface{ Filesystem() billy.Filesystem })
+	if !ok {
+		return ErrSubmoduleAbsorbNotSupported
+	}
+
+	gitDir := fsBased.Filesystem()
+	if files, err := gitDir.ReadDir(""); err == nil && len(files) > 0 {
+		return ErrSubmoduleGitDirExists
+	}
+
+	if err := copyDir(wt, GitDirName, gitDir, ""); err != nil {
+		return err
+	}
+
+	if err := util.RemoveAll(wt, GitDirName); err != nil {
+		return err
+	}
+
+	if err := createDotGitFile(wt, gitDir); err != nil {
+		return err
+	}
+
+	cfg, err := sto.Config()
+	if err != nil {
+		return err
+	}
+
+	worktree, err := filepath.Rel(gitDir.Root(), wt.Root())
+	if err != nil {
+		worktree = wt.Root()
+	}
+
+	cfg.Core.Worktree = filepath.ToSlash(worktree)
+	return sto.SetConfig(cfg)
+}
+
+// copyDir copies the directory at srcPath of src, recursively, to dstPath
+// at dst.
+func copyDir(src billy.Filesystem, srcPath string, dst billy.Filesystem, dstPath string) error {
+	if err := dst.MkdirAll(dstPath, 0755); err != nil {
+		return err
+	}
+
+	files, err := src.ReadDir(srcPath)
+	if err != nil {
+		return err
+	}
+
+	for _, fi := range files {
+		from, to := src.Join(srcPath, fi.Name()), dst.Join(dstPath, fi.Name())
+		switch {
+		case fi.IsDir():
+			if err := copyDir(src, from, dst, to); err != nil {
+				return err
+			}
+		case fi.Mode()&os.ModeSymlink != 0:
+			target, err := src.Readlink(from)
+			if err != nil {
+				return err
+			}
+
+			if err := dst.Symlink(target, to); err != nil {
+				return err
+			}
+		default:
+			if err := copyFile(src, from, dst, to, fi.Mode()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func copyFile(src billy.Filesystem, from string, dst billy.Filesystem, to string, mode os.FileMode) (err error) {
+	in, err := src.Open(from)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(in, &err)
+
+	out, err := dst.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(out, &err)
+
+	_, err = io.Copy(out, in)
+	return err
+}
+
+// Deinit unregisters the submodule, removing it from the config of the
+// superproject and removing the files of its worktree, as git submodule
+// deinit does. Its git directory is kept, so it can be initialized again
+// without cloning it, an embedded one is absorbed first, see AbsorbGitDir.
+func (s *Submodule) Deinit(o *SubmoduleDeinitOptions) error {
+	if o == nil {
+		o = &SubmoduleDeinitOptions{}
+	}
+
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	if !s.initialized {
+		return ErrSubmoduleNotInitialized
+	}
+
+	if err := s.AbsorbGitDir(); err != nil {
+		return err
+	}
+
+	if !o.Force {
+		clean, err := s.isClean()
+		if err != nil {
+			return err
+		}
+
+		if !clean {
+			return ErrSubmoduleModified
+		}
+	}
+
+	if err := util.RemoveAll(s.w.Filesystem, s.c.Path); err != nil {
+		return err
+	}
+
+	if err := s.w.Filesystem.MkdirAll(s.c.Path, 0755); err != nil {
+		return err
+	}
+
+	cfg, err := s.w.r.Storer.Config()
+	if err != nil {
+		return err
+	}
+
+	delete(cfg.Submodules, s.c.Name)
+	if err := s.w.r.Storer.SetConfig(cfg); err != nil {
 		return err
 	}
 
-	head := plumbing.NewHashReference(plumbing.HEAD, hash)
-	return r.Storer.SetReference(head)
+	s.initialized = false
+	return nil
+}
+
+// isClean returns true if the worktree of the submodule, if checked out, has
+// no local modifications.
+func (s *Submodule) isClean() (bool, error) {
+	r, err := s.Repository()
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := r.Head(); err == plumbing.ErrReferenceNotFound {
+		return true, nil
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		return false, err
+	}
+
+	status, err := w.Status()
+	if err != nil {
+		return false, err
+	}
+
+	return status.IsClean(), nil
 }
 
 // Submodules list of several submodules from the same repository.
@@ -254,24 +639,122 @@ func (s Submodules) Init() error {
 	return nil
 }
 
+// AbsorbGitDirs absorbs the git directories embedded at the worktree of the
+// submodules in this list, see Submodule.AbsorbGitDir.
+func (s Submodules) AbsorbGitDirs() error {
+	for _, sub := range s {
+		if err := sub.AbsorbGitDir(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Deinit deinitializes the initialized submodules in this list, see
+// Submodule.Deinit.
+func (s Submodules) Deinit(o *SubmoduleDeinitOptions) error {
+	for _, sub := range s {
+		if !sub.initialized {
+			continue
+		}
+
+		if err := sub.Deinit(o); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Update updates all the submodules in this list.
 func (s Submodules) Update(o *SubmoduleUpdateOptions) error {
 	return s.UpdateContext(context.Background(), o)
 }
 
-// UpdateContext updates all the submodules in this list.
+// UpdateContext updates all the submodules in this list, up to
+// SubmoduleUpdateOptions.Jobs or submodule.fetchJobs in parallel, stopping at
+// the first error.
 //
 // The provided Context must be non-nil. If the context expires before the
 // operation is complete, an error is returned. The context only affects to the
 // transport operations.
 func (s Submodules) UpdateContext(ctx context.Context, o *SubmoduleUpdateOptions) error {
+	if len(s) == 0 {
+		return nil
+	}
+
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	jobs := o.Jobs
+	if jobs == 0 {
+		cfg, err := s[0].w.r.Config()
+		if err != nil {
+			return err
+		}
+
+		jobs = cfg.Submodule.FetchJobs
+	}
+
+	if jobs <= 1 {
+		for _, sub := range s {
+			if err := sub.UpdateContext(ctx, o); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	// the submodules are initialized one at a time, as they are written to
+	// the config of the superproject
 	for _, sub := range s {
-		if err := sub.UpdateContext(ctx, o); err != nil {
+		if sub.initialized || !o.Init {
+			continue
+		}
+
+		if err := sub.Init(); err != nil && err != ErrSubmoduleAlreadyInitialized {
 			return err
 		}
+
+		sub.initialized = true
 	}
 
-	return nil
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		m        sync.Mutex
+		firstErr error
+	)
+
+	sem := make(chan struct{}, jobs)
+	for _, sub := range s {
+		sub := sub
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			if err := sub.UpdateContext(ctx, o); err != nil {
+				m.Lock()
+				if firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				m.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+	return firstErr
 }
 
 // Status returns the status of the submodules.
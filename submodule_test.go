@@ -234,3 +234,251 @@ func (s *SubmoduleSuite) TestSubmodulesUpdateContext(c *C) {
 	err = sm.UpdateContext(ctx, &SubmoduleUpdateOptions{Init: true})
 	c.Assert(err, NotNil)
 }
+
+func (s *SubmoduleSuite) localBasic(c *C) *Submodule {
+	sm, err := s.Worktree.Submodule("basic")
+	c.Assert(err, IsNil)
+
+	sm.Config().URL = s.GetBasicLocalRepositoryURL()
+	return sm
+}
+
+func (s *SubmoduleSuite) TestUpdateRemote(c *C) {
+	sm := s.localBasic(c)
+	sm.Config().Branch = "branch"
+
+	err := sm.Update(&SubmoduleUpdateOptions{Init: true, Remote: true})
+	c.Assert(err, IsNil)
+
+	r, err := sm.Repository()
+	c.Assert(err, IsNil)
+
+	branch, err := r.Reference("refs/remotes/origin/branch", true)
+	c.Assert(err, IsNil)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, branch.Hash())
+	c.Assert(head.Hash().String(), Not(Equals), "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+}
+
+func (s *SubmoduleSuite) TestUpdateRemoteHEAD(c *C) {
+	sm := s.localBasic(c)
+
+	err := sm.Update(&SubmoduleUpdateOptions{Init: true, Remote: true})
+	c.Assert(err, IsNil)
+
+	r, err := sm.Repository()
+	c.Assert(err, IsNil)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash().String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+}
+
+func (s *SubmoduleSuite) TestUpdateModeNone(c *C) {
+	sm := s.localBasic(c)
+	sm.Config().Update = "none"
+
+	err := sm.Update(&SubmoduleUpdateOptions{Init: true})
+	c.Assert(err, IsNil)
+
+	r, err := sm.Repository()
+	c.Assert(err, IsNil)
+
+	_, err = r.Head()
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+}
+
+func (s *SubmoduleSuite) TestUpdateInvalidMode(c *C) {
+	sm := s.localBasic(c)
+
+	err := sm.Update(&SubmoduleUpdateOptions{Init: true, Mode: "foo"})
+	c.Assert(err, Equals, ErrInvalidSubmoduleUpdateMode)
+
+	sm.Config().Update = "!make"
+	err = sm.Update(&SubmoduleUpdateOptions{Init: true})
+	c.Assert(err, Equals, ErrInvalidSubmoduleUpdateMode)
+}
+
+func (s *SubmoduleSuite) TestUpdateMerge(c *C) {
+	sm := s.localBasic(c)
+
+	err := sm.Update(&SubmoduleUpdateOptions{Init: true})
+	c.Assert(err, IsNil)
+
+	r, err := sm.Repository()
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	err = w.Checkout(&CheckoutOptions{
+		Branch: "refs/heads/foo",
+		Create: true,
+		Hash:   plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d"),
+	})
+	c.Assert(err, IsNil)
+
+	err = sm.Update(&SubmoduleUpdateOptions{Mode: MergeSubmoduleUpdate})
+	c.Assert(err, IsNil)
+
+	head, err := r.Storer.Reference(plumbing.HEAD)
+	c.Assert(err, IsNil)
+	c.Assert(head.Target(), Equals, plumbing.ReferenceName("refs/heads/foo"))
+
+	branch, err := r.Reference("refs/heads/foo", false)
+	c.Assert(err, IsNil)
+	c.Assert(branch.Hash().String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+	status, err := sm.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+}
+
+func (s *SubmoduleSuite) TestUpdateShallow(c *C) {
+	if testing.Short() {
+		c.Skip("skipping test in short mode.")
+	}
+
+	sm, err := s.Worktree.Submodule("basic")
+	c.Assert(err, IsNil)
+	sm.Config().Shallow = true
+
+	err = sm.Update(&SubmoduleUpdateOptions{Init: true})
+	c.Assert(err, IsNil)
+
+	r, err := sm.Repository()
+	c.Assert(err, IsNil)
+
+	shallows, err := r.Storer.Shallow()
+	c.Assert(err, IsNil)
+	c.Assert(shallows, Not(HasLen), 0)
+}
+
+func (s *SubmoduleSuite) TestSubmodulesUpdateJobs(c *C) {
+	if testing.Short() {
+		c.Skip("skipping test in short mode.")
+	}
+
+	sm, err := s.Worktree.Submodules()
+	c.Assert(err, IsNil)
+
+	err = sm.Update(&SubmoduleUpdateOptions{Init: true, Jobs: 2})
+	c.Assert(err, IsNil)
+
+	status, err := sm.Status()
+	c.Assert(err, IsNil)
+	for _, st := range status {
+		c.Assert(st.IsClean(), Equals, true, Commentf("%s", st.Path))
+	}
+}
+
+func (s *SubmoduleSuite) embedGitDir(c *C, sm *Submodule) string {
+	root := s.Worktree.Filesystem.Root()
+	path := filepath.Join(root, sm.Config().Path)
+
+	c.Assert(os.Remove(filepath.Join(path, GitDirName)), IsNil)
+	err := os.Rename(
+		filepath.Join(root, GitDirName, "modules", sm.Config().Name),
+		filepath.Join(path, GitDirName),
+	)
+	c.Assert(err, IsNil)
+
+	r, err := PlainOpen(path)
+	c.Assert(err, IsNil)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	cfg.Core.Worktree = ""
+	c.Assert(r.Storer.SetConfig(cfg), IsNil)
+	return path
+}
+
+func (s *SubmoduleSuite) TestAbsorbGitDir(c *C) {
+	sm := s.localBasic(c)
+	c.Assert(sm.Update(&SubmoduleUpdateOptions{Init: true}), IsNil)
+
+	path := s.embedGitDir(c, sm)
+	c.Assert(sm.AbsorbGitDir(), IsNil)
+
+	fi, err := os.Lstat(filepath.Join(path, GitDirName))
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode().IsRegular(), Equals, true)
+
+	content, err := ioutil.ReadFile(filepath.Join(path, GitDirName))
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "gitdir: ../.git/modules/basic\n")
+
+	r, err := PlainOpen(path)
+	c.Assert(err, IsNil)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash().String(), Equals, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+	status, err := sm.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+
+	c.Assert(sm.AbsorbGitDir(), IsNil)
+}
+
+func (s *SubmoduleSuite) TestAbsorbGitDirExists(c *C) {
+	sm := s.localBasic(c)
+	c.Assert(sm.Update(&SubmoduleUpdateOptions{Init: true}), IsNil)
+
+	path := filepath.Join(s.Worktree.Filesystem.Root(), "basic", GitDirName)
+	c.Assert(os.Remove(path), IsNil)
+	c.Assert(os.Mkdir(path, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(path, "HEAD"), []byte("ref: refs/heads/master\n"), 0644), IsNil)
+
+	c.Assert(sm.AbsorbGitDir(), Equals, ErrSubmoduleGitDirExists)
+}
+
+func (s *SubmoduleSuite) TestDeinit(c *C) {
+	sm := s.localBasic(c)
+	c.Assert(sm.Update(&SubmoduleUpdateOptions{Init: true}), IsNil)
+
+	c.Assert(sm.Deinit(nil), IsNil)
+	c.Assert(sm.initialized, Equals, false)
+
+	cfg, err := s.Repository.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Submodules["basic"], IsNil)
+
+	fs := s.Worktree.Filesystem
+	files, err := fs.ReadDir("basic")
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 0)
+
+	_, err = os.Stat(filepath.Join(fs.Root(), GitDirName, "modules", "basic", "HEAD"))
+	c.Assert(err, IsNil)
+
+	c.Assert(sm.Deinit(nil), Equals, ErrSubmoduleNotInitialized)
+}
+
+func (s *SubmoduleSuite) TestDeinitEmbedded(c *C) {
+	sm := s.localBasic(c)
+	c.Assert(sm.Update(&SubmoduleUpdateOptions{Init: true}), IsNil)
+
+	s.embedGitDir(c, sm)
+	c.Assert(sm.Deinit(nil), IsNil)
+
+	_, err := os.Stat(filepath.Join(s.Worktree.Filesystem.Root(), GitDirName, "modules", "basic", "HEAD"))
+	c.Assert(err, IsNil)
+}
+
+func (s *SubmoduleSuite) TestDeinitModified(c *C) {
+	sm := s.localBasic(c)
+	c.Assert(sm.Update(&SubmoduleUpdateOptions{Init: true}), IsNil)
+
+	path := filepath.Join(s.Worktree.Filesystem.Root(), "basic", "LICENSE")
+	c.Assert(ioutil.WriteFile(path, []byte("foo"), 0644), IsNil)
+
+	c.Assert(sm.Deinit(nil), Equals, ErrSubmoduleModified)
+	c.Assert(sm.Deinit(&SubmoduleDeinitOptions{Force: true}), IsNil)
+
+	_, err := os.Stat(path)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
@@ -0,0 +1,634 @@
+package git
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+var (
+	// ErrSubtreePrefixNotFound is returned splitting or merging a subtree
+	// whose prefix doesn't exist.
+	ErrSubtreePrefixNotFound = errors.New("subtree prefix not found")
+	// ErrSubtreePrefixExists is returned adding a subtree at a prefix
+	// already existing.
+	ErrSubtreePrefixExists = errors.New("subtree prefix already exists")
+	// ErrSubtreeMergeConflict is returned merging a subtree changing the
+	// same files changed at the prefix since the last merge.
+	ErrSubtreeMergeConflict = errors.New("subtree merge conflict")
+)
+
+// the trailers of the commits adding, merging and squashing subtrees, as
+// git subtree writes them.
+const (
+	subtreeDirTrailer      = "git-subtree-dir"
+	subtreeMainlineTrailer = "git-subtree-mainline"
+	subtreeSplitTrailer    = "git-subtree-split"
+)
+
+// SubtreeSplit rewrites the history of the subdirectory at the prefix as a
+// standalone history, as git subtree split does, returning the commit of the
+// split. The commits not changing the subdirectory are skipped, and the
+// rewritten commits keep their authors and messages, so splitting again the
+// same history returns the same commits.
+//
+// The subtrees added or merged as git subtree add and merge do, squashed or
+// not, are recognized by the trailers of their commits, and their history is
+// kept as is.
+func (r *Repository) SubtreeSplit(o *SubtreeSplitOptions) (plumbing.Hash, error) {
+	if err := o.Validate(r); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	c, err := r.CommitObject(o.Commit)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	s := &subtreeSplitter{
+		r:      r,
+		prefix: o.Prefix,
+		splits: make(map[plumbing.Hash]plumbing.Hash),
+		trees:  make(map[plumbing.Hash]plumbing.Hash),
+	}
+
+	split, err := s.split(c)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if split.IsZero() {
+		return plumbing.ZeroHash, ErrSubtreePrefixNotFound
+	}
+
+	if o.Branch == "" {
+		return split, nil
+	}
+
+	old, err := r.Storer.Reference(o.Branch)
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return plumbing.ZeroHash, err
+	}
+
+	if old != nil && old.Hash() != split {
+		ff, err := isFastForward(r.Storer, old.Hash(), split)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		if !ff {
+			return plumbing.ZeroHash, ErrNonFastForwardUpdate
+		}
+	}
+
+	ref := plumbing.NewHashReference(o.Branch, split)
+	return split, r.Storer.CheckAndSetReference(ref, old)
+}
+
+// subtreeSplitter rewrites the history of a subdirectory.
+type subtreeSplitter struct {
+	r      *Repository
+	prefix string
+	// splits are the rewritten commits by original commit, zero for the
+	// commits without the subdirectory nor rewritten parents.
+	splits map[plumbing.Hash]plumbing.Hash
+	// trees are the trees of the rewritten commits.
+	trees map[plumbing.Hash]plumbing.Hash
+}
+
+// split returns the rewritten commit of c, rewriting the parents first.
+func (s *subtreeSplitter) split(c *object.Commit) (plumbing.Hash, error) {
+	type frame struct {
+		c        *object.Commit
+		expanded bool
+	}
+
+	stack := []*frame{{c: c}}
+	for len(stack) > 0 {
+		f := stack[len(stack)-1]
+		if _, ok := s.splits[f.c.Hash]; ok {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		if f.expanded {
+			stack = stack[:len(stack)-1]
+			h, err := s.rewrite(f.c)
+			if err != nil {
+				return plumbing.ZeroHash, err
+			}
+
+			s.splits[f.c.Hash] = h
+			continue
+		}
+
+		f.expanded = true
+		done, err := s.recognize(f.c)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		if done {
+			continue
+		}
+
+		err = f.c.Parents().ForEach(func(p *object.Commit) error {
+			if _, ok := s.splits[p.Hash]; !ok {
+				stack = append(stack, &frame{c: p})
+			}
+
+			return nil
+		})
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	return s.splits[c.Hash], nil
+}
+
+// recognize recognizes the commits adding, merging and squashing the
+// subtree, keeping the history of the subtree as is. It returns true if the
+// split of c is already known.
+func (s *subtreeSplitter) recognize(c *object.Commit) (bool, error) {
+	t := subtreeTrailers(c.Message)
+	if t[subtreeDirTrailer] == s.prefix && t[subtreeSplitTrailer] != "" {
+		split := plumbing.NewHash(t[subtreeSplitTrailer])
+		if t[subtreeMainlineTrailer] == "" {
+			// a squashed subtree, split as the commit it squashes, if known
+			if _, err := s.r.CommitObject(split); err != nil {
+				if err != plumbing.ErrObjectNotFound {
+					return false, err
+				}
+
+				split = c.Hash
+			}
+
+			s.splits[c.Hash] = split
+			s.trees[split] = c.TreeHash
+			return true, nil
+		}
+
+		for _, p := range c.ParentHashes {
+			if p == split {
+				s.keep(p)
+			}
+		}
+	}
+
+	// the merges of the subtree with the whole subtree as the tree of one of
+	// their parents
+	tree, err := s.prefixTree(c)
+	if err != nil || tree.IsZero() || len(c.ParentHashes) < 2 {
+		return false, err
+	}
+
+	err = c.Parents().ForEach(func(p *object.Commit) error {
+		if p.TreeHash != tree || subtreeTrailers(p.Message)[subtreeDirTrailer] == s.prefix {
+			return nil
+		}
+
+		pt, err := s.prefixTree(p)
+		if err == nil && pt.IsZero() {
+			s.keep(p.Hash)
+		}
+
+		return err
+	})
+
+	return false, err
+}
+
+// keep keeps the commit of the subtree history as its own split.
+func (s *subtreeSplitter) keep(h plumbing.Hash) {
+	if _, ok := s.splits[h]; !ok {
+		s.splits[h] = h
+	}
+}
+
+// rewrite returns the rewritten commit of c, given the splits of its
+// parents. The commits with the same subdirectory as a parent aren't
+// rewritten, their split is the one of the parent.
+func (s *subtreeSplitter) rewrite(c *object.Commit) (plumbing.Hash, error) {
+	var parents []plumbing.Hash
+	seen := make(map[plumbing.Hash]bool)
+	for _, p := range c.ParentHashes {
+		h := s.splits[p]
+		if h.IsZero() || seen[h] {
+			continue
+		}
+
+		seen[h] = true
+		parents = append(parents, h)
+	}
+
+	tree, err := s.prefixTree(c)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if tree.IsZero() {
+		if len(parents) > 0 {
+			return parents[0], nil
+		}
+
+		return plumbing.ZeroHash, nil
+	}
+
+	for _, p := range parents {
+		pt, err := s.tree(p)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		if pt != tree {
+			continue
+		}
+
+		redundant, err := s.contains(p, parents)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		if redundant {
+			return p, nil
+		}
+	}
+
+	h, err := storeCommit(s.r.Storer, &object.Commit{
+		Author:       c.Author,
+		Committer:    c.Committer,
+		Message:      c.Message,
+		TreeHash:     tree,
+		ParentHashes: parents,
+	})
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	s.trees[h] = tree
+	return h, nil
+}
+
+// contains returns true if every one of the commits is an ancestor of p.
+func (s *subtreeSplitter) contains(p plumbing.Hash, commits []plumbing.Hash) (bool, error) {
+	for _, h := range commits {
+		if h == p {
+			continue
+		}
+
+		ok, err := isFastForward(s.r.Storer, h, p)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// prefixTree returns the hash of the subdirectory at the tree of c, zero if
+// it doesn't exist.
+func (s *subtreeSplitter) prefixTree(c *object.Commit) (plumbing.Hash, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return subtreeHash(tree, s.prefix)
+}
+
+// tree returns the tree of a rewritten commit.
+func (s *subtreeSplitter) tree(h plumbing.Hash) (plumbing.Hash, error) {
+	if t, ok := s.trees[h]; ok {
+		return t, nil
+	}
+
+	c, err := s.r.CommitObject(h)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	s.trees[h] = c.TreeHash
+	return c.TreeHash, nil
+}
+
+// subtreeHash returns the hash of the directory at path of the tree, zero if
+// it doesn't exist.
+func subtreeHash(tree *object.Tree, path string) (plumbing.Hash, error) {
+	e, err := tree.FindEntry(path)
+	if err == object.ErrEntryNotFound || err == object.ErrDirectoryNotFound {
+		return plumbing.ZeroHash, nil
+	}
+
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if e.Mode != filemode.Dir {
+		return plumbing.ZeroHash, nil
+	}
+
+	return e.Hash, nil
+}
+
+// subtreeTrailers returns the git subtree trailers of a commit message.
+func subtreeTrailers(msg string) map[string]string {
+	trailers := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(msg))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		switch key := strings.TrimSpace(parts[0]); key {
+		case subtreeDirTrailer, subtreeMainlineTrailer, subtreeSplitTrailer:
+			trailers[key] = strings.TrimSpace(parts[1])
+		}
+	}
+
+	return trailers
+}
+
+func storeCommit(s storer.EncodedObjectStorer, c *object.Commit) (plumbing.Hash, error) {
+	obj := s.NewEncodedObject()
+	if err := c.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return s.SetEncodedObject(obj)
+}
+
+// SubtreeAdd adds the tree of the given commit at the prefix, with a merge
+// commit of the commit into HEAD, as git subtree add does, returning the new
+// commit. The commit, usually of another repository, must be fetched first.
+// The worktree must be clean.
+func (w *Worktree) SubtreeAdd(o *SubtreeOptions) (plumbing.Hash, error) {
+	head, headTree, err := w.subtreeHead(o)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	_, err = headTree.FindEntry(o.Prefix)
+	if err == nil {
+		return plumbing.ZeroHash, ErrSubtreePrefixExists
+	}
+
+	if err != object.ErrEntryNotFound && err != object.ErrDirectoryNotFound {
+		return plumbing.ZeroHash, err
+	}
+
+	c, err := w.r.CommitObject(o.Commit)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	msg := o.Message
+	merged := c.Hash
+	if o.Squash {
+		merged, err = w.subtreeSquash(o, plumbing.ZeroHash, plumbing.ZeroHash, c)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		if msg == "" {
+			msg = fmt.Sprintf("Merge commit '%s' as '%s'\n", merged, o.Prefix)
+		}
+	} else if msg == "" {
+		msg = fmt.Sprintf("Add '%s/' from commit '%s'\n", o.Prefix, c.Hash)
+		msg += subtreeMessageTrailers(o.Prefix, head.Hash, c.Hash)
+	}
+
+	return w.subtreeCommit(o, head, headTree, c.TreeHash, msg, merged)
+}
+
+// SubtreeMerge merges the tree of the given commit into the subtree at the
+// prefix, added with SubtreeAdd, as git subtree merge does, returning the
+// new merge commit. The changes since the last split added or merged are
+// merged file by file, ErrSubtreeMergeConflict is returned if both sides
+// changed the same file. NoErrAlreadyUpToDate is returned if the commit is
+// already merged. The worktree must be clean.
+func (w *Worktree) SubtreeMerge(o *SubtreeOptions) (plumbing.Hash, error) {
+	head, headTree, err := w.subtreeHead(o)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	ours, err := subtreeHash(headTree, o.Prefix)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if ours.IsZero() {
+		return plumbing.ZeroHash, ErrSubtreePrefixNotFound
+	}
+
+	c, err := w.r.CommitObject(o.Commit)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	split, squash, err := w.r.lastSubtreeSplit(head, o.Prefix)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	base := &object.Tree{}
+	if !split.IsZero() {
+		if split == c.Hash {
+			return plumbing.ZeroHash, NoErrAlreadyUpToDate
+		}
+
+		merged, err := isFastForward(w.r.Storer, c.Hash, split)
+		if err != nil && err != plumbing.ErrObjectNotFound {
+			return plumbing.ZeroHash, err
+		}
+
+		if merged {
+			return plumbing.ZeroHash, NoErrAlreadyUpToDate
+		}
+
+		sc, err := w.r.CommitObject(split)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		if base, err = sc.Tree(); err != nil {
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	tree, err := w.mergeSubtrees(base, ours, c)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	msg := o.Message
+	merged := c.Hash
+	if o.Squash {
+		merged, err = w.subtreeSquash(o, squash, split, c)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		if msg == "" {
+			msg = fmt.Sprintf("Merge commit '%s'\n", merged)
+		}
+	} else if msg == "" {
+		msg = fmt.Sprintf("Merge commit '%s'\n", c.Hash)
+		msg += subtreeMessageTrailers(o.Prefix, head.Hash, c.Hash)
+	}
+
+	return w.subtreeCommit(o, head, headTree, tree, msg, merged)
+}
+
+// subtreeHead returns the HEAD commit and its tree, checking the worktree is
+// clean.
+func (w *Worktree) subtreeHead(o *SubtreeOptions) (*object.Commit, *object.Tree, error) {
+	if err := o.Validate(w.r); err != nil {
+		return nil, nil, err
+	}
+
+	status, err := w.Status()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !status.IsClean() {
+		return nil, nil, ErrWorktreeNotClean
+	}
+
+	ref, err := w.r.Head()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	head, err := w.r.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tree, err := head.Tree()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return head, tree, nil
+}
+
+// mergeSubtrees merges the changes of the tree of c since base into the
+// subtree ours, file by file.
+func (w *Worktree) mergeSubtrees(base *object.Tree, ours plumbing.Hash, c *object.Commit) (plumbing.Hash, error) {
+	theirs, err := c.Tree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	oursTree, err := w.r.TreeObject(ours)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	h, err := mergeTrees(w.r.Storer, base, oursTree, theirs)
+	if _, ok := err.(*treeMergeConflictError); ok {
+		return plumbing.ZeroHash, ErrSubtreeMergeConflict
+	}
+
+	return h, err
+}
+
+// subtreeSquash creates a commit squashing the history of the subtree up to
+// c, with the tree of c, as a child of the previous squash commit, if any.
+func (w *Worktree) subtreeSquash(o *SubtreeOptions, parent, from plumbing.Hash, c *object.Commit) (plumbing.Hash, error) {
+	msg := fmt.Sprintf("Squashed '%s/' content from commit %s\n", o.Prefix, c.Hash.String()[:7])
+	if !from.IsZero() {
+		msg = fmt.Sprintf("Squashed '%s/' changes from %s..%s\n", o.Prefix, from.String()[:7], c.Hash.String()[:7])
+	}
+
+	msg += fmt.Sprintf("\n%s: %s\n%s: %s\n", subtreeDirTrailer, o.Prefix, subtreeSplitTrailer, c.Hash)
+
+	commit := &object.Commit{
+		Author:    *o.Author,
+		Committer: *o.Committer,
+		Message:   msg,
+		TreeHash:  c.TreeHash,
+	}
+
+	if !parent.IsZero() {
+		commit.ParentHashes = []plumbing.Hash{parent}
+	}
+
+	return storeCommit(w.r.Storer, commit)
+}
+
+// subtreeCommit commits the tree at the prefix of the tree of HEAD, merging
+// the given commit, and updates the worktree to it.
+func (w *Worktree) subtreeCommit(o *SubtreeOptions, head *object.Commit, headTree *object.Tree, tree plumbing.Hash, msg string, merged plumbing.Hash) (plumbing.Hash, error) {
+	b := object.NewTreeBuilder(w.r.Storer, headTree)
+	if err := b.Add(o.Prefix, filemode.Dir, tree); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	root, err := b.Build()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	h, err := storeCommit(w.r.Storer, &object.Commit{
+		Author:       *o.Author,
+		Committer:    *o.Committer,
+		Message:      msg,
+		TreeHash:     root,
+		ParentHashes: []plumbing.Hash{head.Hash, merged},
+	})
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if err := w.r.setOrigHead(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if err := w.updateHEAD(h); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return h, w.resetContext(context.Background(), &ResetOptions{Mode: MergeReset, Commit: h})
+}
+
+func subtreeMessageTrailers(prefix string, mainline, split plumbing.Hash) string {
+	return fmt.Sprintf("\n%s: %s\n%s: %s\n%s: %s\n",
+		subtreeDirTrailer, prefix,
+		subtreeMainlineTrailer, mainline,
+		subtreeSplitTrailer, split,
+	)
+}
+
+// lastSubtreeSplit returns the last split of the subtree at the prefix
+// added or merged, and the last squash commit, if squashed, found at the
+// history of c, nearest commits first.
+func (r *Repository) lastSubtreeSplit(c *object.Commit, prefix string) (split, squash plumbing.Hash, err error) {
+	iter := object.NewCommitIterBSF(c, nil, nil)
+	err = iter.ForEach(func(c *object.Commit) error {
+		t := subtreeTrailers(c.Message)
+		if t[subtreeDirTrailer] != prefix || t[subtreeSplitTrailer] == "" {
+			return nil
+		}
+
+		split = plumbing.NewHash(t[subtreeSplitTrailer])
+		if t[subtreeMainlineTrailer] == "" {
+			squash = c.Hash
+		}
+
+		return storer.ErrStop
+	})
+
+	return split, squash, err
+}
@@ -0,0 +1,225 @@
+package git
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type SubtreeSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&SubtreeSuite{})
+
+func (s *SubtreeSuite) newRepository(c *C) (*Repository, *Worktree) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	return r, w
+}
+
+// commit commits the files at branch, updating the worktree if it is HEAD.
+func (s *SubtreeSuite) commit(c *C, r *Repository, branch plumbing.ReferenceName, files map[string]string) plumbing.Hash {
+	var changes []FileChange
+	for path, content := range files {
+		changes = append(changes, FileChange{Path: path, Content: strings.NewReader(content)})
+	}
+
+	h, err := r.CommitChanges("update\n", &CommitChangesOptions{
+		Author:  defaultSignature(),
+		Branch:  branch,
+		Changes: changes,
+	})
+	c.Assert(err, IsNil)
+
+	if branch == plumbing.Master {
+		w, err := r.Worktree()
+		c.Assert(err, IsNil)
+		c.Assert(w.Reset(&ResetOptions{Mode: HardReset, Commit: h}), IsNil)
+	}
+
+	return h
+}
+
+func (s *SubtreeSuite) file(c *C, r *Repository, commit plumbing.Hash, path string) string {
+	o, err := r.CommitObject(commit)
+	c.Assert(err, IsNil)
+
+	f, err := o.File(path)
+	c.Assert(err, IsNil)
+
+	content, err := f.Contents()
+	c.Assert(err, IsNil)
+	return content
+}
+
+func (s *SubtreeSuite) TestSplit(c *C) {
+	r, _ := s.newRepository(c)
+
+	s.commit(c, r, plumbing.Master, map[string]string{"README": "foo"})
+	s.commit(c, r, plumbing.Master, map[string]string{"lib/a": "1"})
+	s.commit(c, r, plumbing.Master, map[string]string{"README": "bar"})
+	s.commit(c, r, plumbing.Master, map[string]string{"lib/a": "2", "lib/b/c": "3"})
+
+	branch := plumbing.ReferenceName("refs/heads/lib")
+	split, err := r.SubtreeSplit(&SubtreeSplitOptions{Prefix: "lib/", Branch: branch})
+	c.Assert(err, IsNil)
+	c.Assert(s.file(c, r, split, "a"), Equals, "2")
+	c.Assert(s.file(c, r, split, "b/c"), Equals, "3")
+
+	ref, err := r.Reference(branch, false)
+	c.Assert(err, IsNil)
+	c.Assert(ref.Hash(), Equals, split)
+
+	commit, err := r.CommitObject(split)
+	c.Assert(err, IsNil)
+	c.Assert(commit.ParentHashes, HasLen, 1)
+	c.Assert(s.file(c, r, commit.ParentHashes[0], "a"), Equals, "1")
+
+	parent, err := r.CommitObject(commit.ParentHashes[0])
+	c.Assert(err, IsNil)
+	c.Assert(parent.ParentHashes, HasLen, 0)
+
+	again, err := r.SubtreeSplit(&SubtreeSplitOptions{Prefix: "lib", Branch: branch})
+	c.Assert(err, IsNil)
+	c.Assert(again, Equals, split)
+}
+
+func (s *SubtreeSuite) TestSplitPrefixNotFound(c *C) {
+	r, _ := s.newRepository(c)
+	s.commit(c, r, plumbing.Master, map[string]string{"README": "foo"})
+
+	_, err := r.SubtreeSplit(&SubtreeSplitOptions{Prefix: "lib"})
+	c.Assert(err, Equals, ErrSubtreePrefixNotFound)
+
+	_, err = r.SubtreeSplit(&SubtreeSplitOptions{Prefix: "/"})
+	c.Assert(err, Equals, ErrMissingSubtreePrefix)
+}
+
+func (s *SubtreeSuite) testAddMergeSplit(c *C, squash bool) {
+	r, w := s.newRepository(c)
+	lib := plumbing.ReferenceName("refs/heads/lib")
+
+	main := s.commit(c, r, plumbing.Master, map[string]string{"README": "foo"})
+	first := s.commit(c, r, lib, map[string]string{"x": "1", "y": "1"})
+
+	added, err := w.SubtreeAdd(&SubtreeOptions{
+		Prefix: "vendor/lib",
+		Commit: first,
+		Squash: squash,
+		Author: defaultSignature(),
+	})
+	c.Assert(err, IsNil)
+
+	commit, err := r.CommitObject(added)
+	c.Assert(err, IsNil)
+	c.Assert(commit.ParentHashes, HasLen, 2)
+	c.Assert(commit.ParentHashes[0], Equals, main)
+	c.Assert(commit.ParentHashes[1] == first, Equals, !squash)
+	c.Assert(s.file(c, r, added, "README"), Equals, "foo")
+
+	f, err := w.Filesystem.Open("vendor/lib/x")
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "1")
+
+	s.commit(c, r, plumbing.Master, map[string]string{"vendor/lib/y": "2"})
+	second := s.commit(c, r, lib, map[string]string{"x": "2"})
+
+	merged, err := w.SubtreeMerge(&SubtreeOptions{
+		Prefix: "vendor/lib",
+		Commit: second,
+		Squash: squash,
+		Author: defaultSignature(),
+	})
+	c.Assert(err, IsNil)
+	c.Assert(s.file(c, r, merged, "vendor/lib/x"), Equals, "2")
+	c.Assert(s.file(c, r, merged, "vendor/lib/y"), Equals, "2")
+
+	_, err = w.SubtreeMerge(&SubtreeOptions{
+		Prefix: "vendor/lib",
+		Commit: second,
+		Squash: squash,
+		Author: defaultSignature(),
+	})
+	c.Assert(err, Equals, NoErrAlreadyUpToDate)
+
+	s.commit(c, r, plumbing.Master, map[string]string{"vendor/lib/x": "3"})
+
+	split, err := r.SubtreeSplit(&SubtreeSplitOptions{Prefix: "vendor/lib"})
+	c.Assert(err, IsNil)
+	c.Assert(s.file(c, r, split, "x"), Equals, "3")
+	c.Assert(s.file(c, r, split, "y"), Equals, "2")
+
+	commit, err = r.CommitObject(split)
+	c.Assert(err, IsNil)
+	c.Assert(commit.ParentHashes, HasLen, 1)
+
+	// the merge, with the change of y at the prefix and the second commit
+	merge, err := r.CommitObject(commit.ParentHashes[0])
+	c.Assert(err, IsNil)
+	c.Assert(merge.ParentHashes, HasLen, 2)
+	c.Assert(merge.ParentHashes[1], Equals, second)
+	c.Assert(s.file(c, r, merge.ParentHashes[0], "y"), Equals, "2")
+
+	change, err := r.CommitObject(merge.ParentHashes[0])
+	c.Assert(err, IsNil)
+	c.Assert(change.ParentHashes, DeepEquals, []plumbing.Hash{first})
+}
+
+func (s *SubtreeSuite) TestAddMergeSplit(c *C) {
+	s.testAddMergeSplit(c, false)
+}
+
+func (s *SubtreeSuite) TestAddMergeSplitSquash(c *C) {
+	s.testAddMergeSplit(c, true)
+}
+
+func (s *SubtreeSuite) TestAddPrefixExists(c *C) {
+	r, w := s.newRepository(c)
+	s.commit(c, r, plumbing.Master, map[string]string{"lib/a": "foo"})
+	lib := s.commit(c, r, plumbing.ReferenceName("refs/heads/lib"), map[string]string{"x": "1"})
+
+	_, err := w.SubtreeAdd(&SubtreeOptions{Prefix: "lib", Commit: lib, Author: defaultSignature()})
+	c.Assert(err, Equals, ErrSubtreePrefixExists)
+}
+
+func (s *SubtreeSuite) TestAddNotClean(c *C) {
+	r, w := s.newRepository(c)
+	s.commit(c, r, plumbing.Master, map[string]string{"README": "foo"})
+	lib := s.commit(c, r, plumbing.ReferenceName("refs/heads/lib"), map[string]string{"x": "1"})
+
+	c.Assert(util.WriteFile(w.Filesystem, "README", []byte("bar"), 0644), IsNil)
+
+	_, err := w.SubtreeAdd(&SubtreeOptions{Prefix: "lib", Commit: lib, Author: defaultSignature()})
+	c.Assert(err, Equals, ErrWorktreeNotClean)
+}
+
+func (s *SubtreeSuite) TestMergeConflict(c *C) {
+	r, w := s.newRepository(c)
+	lib := plumbing.ReferenceName("refs/heads/lib")
+
+	s.commit(c, r, plumbing.Master, map[string]string{"README": "foo"})
+	first := s.commit(c, r, lib, map[string]string{"x": "1"})
+
+	_, err := w.SubtreeAdd(&SubtreeOptions{Prefix: "lib", Commit: first, Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	s.commit(c, r, plumbing.Master, map[string]string{"lib/x": "2"})
+	second := s.commit(c, r, lib, map[string]string{"x": "3"})
+
+	_, err = w.SubtreeMerge(&SubtreeOptions{Prefix: "lib", Commit: second, Author: defaultSignature()})
+	c.Assert(err, Equals, ErrSubtreeMergeConflict)
+}
@@ -0,0 +1,153 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Names of the spans started by go-git.
+const (
+	// TraceClone covers a whole clone, the fetch and the checkout of HEAD.
+	TraceClone = "clone"
+	// TraceFetch covers a whole fetch from a remote.
+	TraceFetch = "fetch"
+	// TracePush covers a whole push to a remote.
+	TracePush = "push"
+	// TraceAdvertisedReferences is the request of the references advertised
+	// by the remote, the first round of the negotiation.
+	TraceAdvertisedReferences = "advertised-references"
+	// TraceNegotiation is the sending of the wants and haves of a fetch, up
+	// to the server answering with the packfile.
+	TraceNegotiation = "negotiation"
+	// TracePackDownload is the reception of a packfile. With storages
+	// decoding the packfile while it is received, it includes the delta
+	// resolution.
+	TracePackDownload = "pack-download"
+	// TracePackIndex is the delta resolution and indexing of a received
+	// packfile, once fully downloaded.
+	TracePackIndex = "pack-index"
+	// TracePackUpload is the encoding and sending of the packfile of a push.
+	TracePackUpload = "pack-upload"
+	// TraceCheckout covers a whole update of the worktree; its phases are
+	// traced as "checkout-" followed by the CheckoutPhase name.
+	TraceCheckout = "checkout"
+)
+
+// Keys of the attributes set on the spans.
+const (
+	// TraceURL is the url of the remote, a string.
+	TraceURL = "url"
+	// TraceWants and TraceHaves are the number of objects requested and
+	// announced during the negotiation, ints.
+	TraceWants = "wants"
+	TraceHaves = "haves"
+	// TraceReferences is the number of references advertised, an int.
+	TraceReferences = "references"
+	// TraceObjects is the number of objects sent, an int.
+	TraceObjects = "objects"
+	// TraceBytes is the size of the packfile transferred or of the files
+	// written, an int64.
+	TraceBytes = "bytes"
+	// TraceFiles is the number of files written, an int.
+	TraceFiles = "files"
+)
+
+// Tracer observes the long running operations of go-git, such as clone,
+// fetch, push and checkout. A span is started at the boundary of every step:
+// the negotiation rounds, the transfer and indexing of the packfiles and the
+// checkout phases. Its shape matches the tracers of OpenTelemetry, so those
+// can be adapted with a few lines.
+type Tracer interface {
+	// Start starts a span, the returned context carries it and is the one
+	// passed to the spans of the inner steps.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a step of an operation, started by Tracer.Start.
+type Span interface {
+	// SetAttribute sets an attribute of the step, such as its size.
+	SetAttribute(key string, value interface{})
+	// End is called once the step is finished, with its error, if any.
+	End(err error)
+}
+
+// NewLogTracer returns a Tracer writing a line to w for every finished span,
+// with its duration and attributes.
+func NewLogTracer(w io.Writer) Tracer {
+	return &logTracer{w: w}
+}
+
+type logTracer struct {
+	w io.Writer
+}
+
+func (t *logTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, &logSpan{w: t.w, name: name, start: time.Now()}
+}
+
+type logSpan struct {
+	w     io.Writer
+	name  string
+	start time.Time
+	attrs []string
+}
+
+func (s *logSpan) SetAttribute(key string, value interface{}) {
+	s.attrs = append(s.attrs, fmt.Sprintf("%s=%v", key, value))
+}
+
+func (s *logSpan) End(err error) {
+	sort.Strings(s.attrs)
+	line := fmt.Sprintf("%s: %s", s.name, time.Since(s.start))
+	if len(s.attrs) > 0 {
+		line += " " + strings.Join(s.attrs, " ")
+	}
+
+	if err != nil {
+		line += fmt.Sprintf(" error=%q", err)
+	}
+
+	fmt.Fprintln(s.w, line)
+}
+
+// startSpan starts a span at t, which may be nil.
+func startSpan(ctx context.Context, t Tracer, name string) (context.Context, Span) {
+	if t == nil {
+		return ctx, noopSpan{}
+	}
+
+	return t.Start(ctx, name)
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(string, interface{}) {}
+func (noopSpan) End(error)                        {}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
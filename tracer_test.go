@@ -0,0 +1,199 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+)
+
+type TracerSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&TracerSuite{})
+
+type recordedSpan struct {
+	name   string
+	parent string
+	attrs  map[string]interface{}
+	err    error
+}
+
+type spanKey struct{}
+
+// recordingTracer records the spans in the order they are ended.
+type recordingTracer struct {
+	m     sync.Mutex
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(spanKey{}).(string)
+	s := &recordingSpan{t: t, span: &recordedSpan{
+		name:   name,
+		parent: parent,
+		attrs:  make(map[string]interface{}),
+	}}
+
+	return context.WithValue(ctx, spanKey{}, name), s
+}
+
+func (t *recordingTracer) names() []string {
+	var names []string
+	for _, s := range t.spans {
+		names = append(names, s.name)
+	}
+
+	return names
+}
+
+func (t *recordingTracer) span(name string) *recordedSpan {
+	for _, s := range t.spans {
+		if s.name == name {
+			return s
+		}
+	}
+
+	return nil
+}
+
+type recordingSpan struct {
+	t    *recordingTracer
+	span *recordedSpan
+}
+
+func (s *recordingSpan) SetAttribute(key string, value interface{}) {
+	s.span.attrs[key] = value
+}
+
+func (s *recordingSpan) End(err error) {
+	s.t.m.Lock()
+	defer s.t.m.Unlock()
+
+	s.span.err = err
+	s.t.spans = append(s.t.spans, s.span)
+}
+
+func (s *TracerSuite) TestClone(c *C) {
+	t := &recordingTracer{}
+	url := s.GetBasicLocalRepositoryURL()
+	r, err := Clone(memory.NewStorage(), memfs.New(), &CloneOptions{
+		URL:    url,
+		Tracer: t,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(r.Tracer, Equals, t)
+
+	c.Assert(t.names(), DeepEquals, []string{
+		TraceAdvertisedReferences,
+		TraceNegotiation,
+		TracePackDownload,
+		TraceFetch,
+		"checkout-comparing",
+		"checkout-removing",
+		"checkout-writing",
+		TraceCheckout,
+		TraceClone,
+	})
+
+	for _, span := range t.spans {
+		c.Assert(span.err, IsNil)
+	}
+
+	c.Assert(t.span(TraceClone).attrs[TraceURL], Equals, url)
+	c.Assert(t.span(TraceClone).parent, Equals, "")
+	c.Assert(t.span(TraceFetch).parent, Equals, TraceClone)
+	c.Assert(t.span(TraceNegotiation).parent, Equals, TraceFetch)
+	// the tips of refs/heads/master and refs/heads/branch
+	c.Assert(t.span(TraceNegotiation).attrs[TraceWants], Equals, 2)
+	c.Assert(t.span(TraceNegotiation).attrs[TraceHaves], Equals, 0)
+	c.Assert(t.span(TraceAdvertisedReferences).attrs[TraceReferences], Not(Equals), 0)
+	c.Assert(t.span(TracePackDownload).attrs[TraceBytes].(int64) > 0, Equals, true)
+	c.Assert(t.span(TraceCheckout).parent, Equals, TraceClone)
+	c.Assert(t.span("checkout-writing").parent, Equals, TraceCheckout)
+	c.Assert(t.span("checkout-writing").attrs[TraceFiles], Equals, 9)
+}
+
+func (s *TracerSuite) TestFetchPackIndex(c *C) {
+	t := &recordingTracer{}
+	st, err := filesystem.NewStorage(memfs.New())
+	c.Assert(err, IsNil)
+
+	r, err := Init(st, nil)
+	c.Assert(err, IsNil)
+	r.Tracer = t
+
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: DefaultRemoteName,
+		URLs: []string{s.GetBasicLocalRepositoryURL()},
+	})
+	c.Assert(err, IsNil)
+
+	err = r.Fetch(&FetchOptions{})
+	c.Assert(err, IsNil)
+
+	c.Assert(t.names(), DeepEquals, []string{
+		TraceAdvertisedReferences,
+		TraceNegotiation,
+		TracePackDownload,
+		TracePackIndex,
+		TraceFetch,
+	})
+	c.Assert(t.span(TracePackIndex).parent, Equals, TraceFetch)
+}
+
+func (s *TracerSuite) TestPush(c *C) {
+	url := c.MkDir()
+	server, err := PlainInit(url, true)
+	c.Assert(err, IsNil)
+
+	t := &recordingTracer{}
+	r, err := Clone(memory.NewStorage(), nil, &CloneOptions{
+		URL: s.GetBasicLocalRepositoryURL(),
+	})
+	c.Assert(err, IsNil)
+	r.Tracer = t
+
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: "server",
+		URLs: []string{url},
+	})
+	c.Assert(err, IsNil)
+
+	err = r.Push(&PushOptions{
+		RemoteName: "server",
+		RefSpecs:   []config.RefSpec{"refs/heads/master:refs/heads/master"},
+	})
+	c.Assert(err, IsNil)
+
+	_, err = server.Reference("refs/heads/master", false)
+	c.Assert(err, IsNil)
+
+	c.Assert(t.names(), DeepEquals, []string{
+		TraceAdvertisedReferences,
+		TracePackUpload,
+		TracePush,
+	})
+	c.Assert(t.span(TracePackUpload).attrs[TraceObjects].(int) > 0, Equals, true)
+	c.Assert(t.span(TracePackUpload).attrs[TraceBytes].(int64) > 0, Equals, true)
+}
+
+func (s *TracerSuite) TestLogTracer(c *C) {
+	buf := bytes.NewBuffer(nil)
+	t := NewLogTracer(buf)
+
+	_, span := t.Start(context.Background(), TracePackDownload)
+	span.SetAttribute(TraceObjects, 2)
+	span.SetAttribute(TraceBytes, int64(42))
+	span.End(errors.New("foo"))
+
+	c.Assert(buf.String(), Matches, `pack-download: .+ bytes=42 objects=2 error="foo"\n`)
+}
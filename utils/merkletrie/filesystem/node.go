@@ -4,6 +4,7 @@ import (
 	"io"
 	"os"
 	"path"
+	"sync"
 
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
@@ -24,6 +25,7 @@ var ignore = map[string]bool{
 type node struct {
 	fs         billy.Filesystem
 	submodules map[string]plumbing.Hash
+	options    *Options
 
 	path     string
 	hash     []byte
@@ -40,7 +42,44 @@ func NewRootNode(
 	fs billy.Filesystem,
 	submodules map[string]plumbing.Hash,
 ) noder.Noder {
-	return &node{fs: fs, submodules: submodules, isDir: true}
+	return NewRootNodeWithOptions(fs, submodules, Options{})
+}
+
+// Options change the way the billy.Filesystem is read by the nodes.
+type Options struct {
+	// ReadDir lists the content of the directories, by default
+	// billy.Filesystem.ReadDir is used. It allows to skip files or to list
+	// the directories from a cache.
+	ReadDir ReadDirFunc
+	// Hash returns the hash of a file, as returned by node.Hash, when it is
+	// known without reading the file. If nil or if it returns nil, the hash
+	// is computed from the content of the file. It may be called from
+	// several goroutines when Workers is greater than 1.
+	Hash HashFunc
+	// Workers is the number of files of a directory hashed concurrently, by
+	// default the files are hashed one by one.
+	Workers int
+}
+
+// ReadDirFunc returns the files of the directory at the given path, the path
+// is relative to the root of the billy.Filesystem.
+type ReadDirFunc func(path string) ([]os.FileInfo, error)
+
+// HashFunc returns the known hash of the file at the given path, if any.
+type HashFunc func(path string, file os.FileInfo) []byte
+
+// NewRootNodeWithOptions returns the root node based on a given
+// billy.Filesystem, read as configured by the given options.
+func NewRootNodeWithOptions(
+	fs billy.Filesystem,
+	submodules map[string]plumbing.Hash,
+	options Options,
+) noder.Noder {
+	if options.ReadDir == nil {
+		options.ReadDir = fs.ReadDir
+	}
+
+	return &node{fs: fs, submodules: submodules, options: &options, isDir: true}
 }
 
 // Hash the hash of a filesystem is the result of concatenating the computed
@@ -86,7 +125,7 @@ func (n *node) calculateChildren() error {
 		return nil
 	}
 
-	files, err := n.fs.ReadDir(n.path)
+	files, err := n.options.ReadDir(n.path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil
@@ -95,11 +134,20 @@ func (n *node) calculateChildren() error {
 		return nil
 	}
 
+	var filtered []os.FileInfo
 	for _, file := range files {
 		if _, ok := ignore[file.Name()]; ok {
 			continue
 		}
 
+		filtered = append(filtered, file)
+	}
+
+	if n.options.Workers > 1 && len(filtered) > 1 {
+		return n.calculateChildrenConcurrently(filtered)
+	}
+
+	for _, file := range filtered {
 		c, err := n.newChildNode(file)
 		if err != nil {
 			return err
@@ -111,6 +159,50 @@ func (n *node) calculateChildren() error {
 	return nil
 }
 
+// calculateChildrenConcurrently creates the child nodes of the given files,
+// computing their hashes with options.Workers goroutines.
+func (n *node) calculateChildrenConcurrently(files []os.FileInfo) error {
+	children := make([]noder.Noder, len(files))
+	errs := make([]error, len(files))
+
+	workers := n.options.Workers
+	if workers > len(files) {
+		workers = len(files)
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				var c *node
+				c, errs[i] = n.newChildNode(files[i])
+				if errs[i] == nil {
+					children[i] = c
+				}
+			}
+		}()
+	}
+
+	for i := range files {
+		next <- i
+	}
+
+	close(next)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	n.children = children
+	return nil
+}
+
 func (n *node) newChildNode(file os.FileInfo) (*node, error) {
 	path := path.Join(n.path, file.Name())
 
@@ -122,6 +214,7 @@ func (n *node) newChildNode(file os.FileInfo) (*node, error) {
 	node := &node{
 		fs:         n.fs,
 		submodules: n.submodules,
+		options:    n.options,
 
 		path:  path,
 		hash:  hash,
@@ -141,6 +234,12 @@ func (n *node) calculateHash(path string, file os.FileInfo) ([]byte, error) {
 		return make([]byte, 24), nil
 	}
 
+	if n.options.Hash != nil {
+		if hash := n.options.Hash(path, file); hash != nil {
+			return hash, nil
+		}
+	}
+
 	var hash plumbing.Hash
 	var err error
 	if file.Mode()&os.ModeSymlink != 0 {
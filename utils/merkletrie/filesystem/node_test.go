@@ -2,9 +2,11 @@ package filesystem
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"os"
 	"path"
+	"sort"
 	"testing"
 
 	. "gopkg.in/check.v1"
@@ -195,6 +197,81 @@ func (s *NoderSuite) TestDiffDirectory(c *C) {
 	c.Assert(a, Equals, merkletrie.Modify)
 }
 
+func (s *NoderSuite) TestDiffWithReadDir(c *C) {
+	fsA := memfs.New()
+	WriteFile(fsA, "foo", []byte("foo"), 0644)
+
+	fsB := memfs.New()
+	WriteFile(fsB, "foo", []byte("foo"), 0644)
+	WriteFile(fsB, "qux/bar", []byte("foo"), 0644)
+
+	var read []string
+	readDir := func(path string) ([]os.FileInfo, error) {
+		read = append(read, path)
+
+		files, err := fsB.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var res []os.FileInfo
+		for _, f := range files {
+			if f.Name() != "qux" {
+				res = append(res, f)
+			}
+		}
+
+		return res, nil
+	}
+
+	ch, err := merkletrie.DiffTree(
+		NewRootNode(fsA, nil),
+		NewRootNodeWithOptions(fsB, nil, Options{ReadDir: readDir}),
+		IsEquals,
+	)
+
+	c.Assert(err, IsNil)
+	c.Assert(ch, HasLen, 0)
+	c.Assert(read, DeepEquals, []string{""})
+}
+
+func (s *NoderSuite) TestDiffWithHash(c *C) {
+	fsA := memfs.New()
+	WriteFile(fsA, "foo", []byte("foo"), 0644)
+	WriteFile(fsA, "bar", []byte("bar"), 0644)
+
+	fsB := memfs.New()
+	WriteFile(fsB, "foo", []byte("qux"), 0644)
+	WriteFile(fsB, "bar", []byte("qux"), 0644)
+
+	nodeA := NewRootNode(fsA, nil)
+	children, err := nodeA.Children()
+	c.Assert(err, IsNil)
+
+	known := make(map[string][]byte)
+	for _, child := range children {
+		known[child.Name()] = child.Hash()
+	}
+
+	hash := func(path string, file os.FileInfo) []byte {
+		if path == "foo" {
+			return known[path]
+		}
+
+		return nil
+	}
+
+	ch, err := merkletrie.DiffTree(
+		nodeA,
+		NewRootNodeWithOptions(fsB, nil, Options{Hash: hash}),
+		IsEquals,
+	)
+
+	c.Assert(err, IsNil)
+	c.Assert(ch, HasLen, 1)
+	c.Assert(ch[0].To.String(), Equals, "bar")
+}
+
 func WriteFile(fs billy.Filesystem, filename string, data []byte, perm os.FileMode) error {
 	f, err := fs.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
 	if err != nil {
@@ -220,3 +297,45 @@ func IsEquals(a, b noder.Hasher) bool {
 
 	return bytes.Equal(a.Hash(), b.Hash())
 }
+
+func (s *NoderSuite) TestDiffWithWorkers(c *C) {
+	fsA := memfs.New()
+	fsB := memfs.New()
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("qux/file%02d", i)
+		WriteFile(fsA, name, []byte(name), 0644)
+		WriteFile(fsB, name, []byte(name), 0644)
+	}
+
+	WriteFile(fsB, "qux/file07", []byte("foo"), 0644)
+
+	opts := Options{Workers: 4}
+	ch, err := merkletrie.DiffTree(
+		NewRootNode(fsA, nil),
+		NewRootNodeWithOptions(fsB, nil, opts),
+		IsEquals,
+	)
+
+	c.Assert(err, IsNil)
+	c.Assert(ch, HasLen, 1)
+	c.Assert(ch[0].To.String(), Equals, "qux/file07")
+
+	sequential := childrenNames(c, NewRootNode(fsB, nil))
+	c.Assert(sequential, HasLen, 20)
+	c.Assert(childrenNames(c, NewRootNodeWithOptions(fsB, nil, opts)), DeepEquals, sequential)
+}
+
+func childrenNames(c *C, root noder.Noder) []string {
+	children, err := root.Children()
+	c.Assert(err, IsNil)
+	children, err = children[0].Children()
+	c.Assert(err, IsNil)
+
+	var names []string
+	for _, child := range children {
+		names = append(names, child.Name())
+	}
+
+	sort.Strings(names)
+	return names
+}
@@ -7,8 +7,11 @@ import (
 	"io"
 	stdioutil "io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
 	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
@@ -29,6 +32,7 @@ var (
 	ErrSubmoduleNotFound = errors.New("submodule not found")
 	ErrUnstagedChanges   = errors.New("worktree contains unstaged changes")
 	ErrGitModulesSymlink = errors.New(gitmodulesFile + " is a symlink")
+	ErrPathNotFound      = errors.New("path did not match any file")
 )
 
 // Worktree represents a git worktree.
@@ -39,13 +43,21 @@ type Worktree struct {
 	Excludes []gitignore.Pattern
 
 	r *Repository
+
+	symlinksOnce sync.Once
+	symlinks     bool
+	symlinksErr  error
 }
 
 // Pull incorporates changes from a remote repository into the current branch.
 // Returns nil if the operation is successful, NoErrAlreadyUpToDate if there are
 // no changes to be fetched, or an error.
 //
-// Pull only supports merges where the can be resolved as a fast-forward.
+// Pull only supports merges where the can be resolved as a fast-forward,
+// otherwise the current branch is rebased onto the pulled commit if
+// PullOptions.Rebase, branch.<name>.rebase or pull.rebase say so, as
+// Worktree.Rebase does. The local changes are stashed and reapplied with
+// PullOptions.Autostash, merge.autoStash or rebase.autoStash.
 func (w *Worktree) Pull(o *PullOptions) error {
 	return w.PullContext(context.Background(), o)
 }
@@ -54,7 +66,11 @@ func (w *Worktree) Pull(o *PullOptions) error {
 // branch. Returns nil if the operation is successful, NoErrAlreadyUpToDate if
 // there are no changes to be fetched, or an error.
 //
-// Pull only supports merges where the can be resolved as a fast-forward.
+// Pull only supports merges where the can be resolved as a fast-forward,
+// otherwise the current branch is rebased onto the pulled commit if
+// PullOptions.Rebase, branch.<name>.rebase or pull.rebase say so, as
+// Worktree.Rebase does. The local changes are stashed and reapplied with
+// PullOptions.Autostash, merge.autoStash or rebase.autoStash.
 //
 // The provided Context must be non-nil. If the context expires before the
 // operation is complete, an error is returned. The context only affects to the
@@ -70,11 +86,12 @@ func (w *Worktree) PullContext(ctx context.Context, o *PullOptions) error {
 	}
 
 	fetchHead, err := remote.fetch(ctx, &FetchOptions{
-		RemoteName: o.RemoteName,
-		Depth:      o.Depth,
-		Auth:       o.Auth,
-		Progress:   o.Progress,
-		Force:      o.Force,
+		RemoteName:  o.RemoteName,
+		Depth:       o.Depth,
+		Auth:        o.Auth,
+		HTTPOptions: o.HTTPOptions,
+		Progress:    o.Progress,
+		Force:       o.Force,
 	})
 
 	updated := true
@@ -84,11 +101,16 @@ func (w *Worktree) PullContext(ctx context.Context, o *PullOptions) error {
 		return err
 	}
 
+	if updated {
+		w.r.runAutoGC(false)
+	}
+
 	ref, err := storer.ResolveReference(fetchHead, o.ReferenceName)
 	if err != nil {
 		return err
 	}
 
+	rebase := PullRebaseFalse
 	head, err := w.r.Head()
 	if err == nil {
 		if !updated && head.Hash() == ref.Hash() {
@@ -101,7 +123,13 @@ func (w *Worktree) PullContext(ctx context.Context, o *PullOptions) error {
 		}
 
 		if !ff {
-			return fmt.Errorf("non-fast-forward update")
+			if rebase, err = w.pullRebaseMode(o, head); err != nil {
+				return err
+			}
+
+			if rebase == PullRebaseFalse {
+				return ErrNonFastForwardUpdate
+			}
 		}
 	}
 
@@ -109,25 +137,98 @@ func (w *Worktree) PullContext(ctx context.Context, o *PullOptions) error {
 		return err
 	}
 
-	if err := w.updateHEAD(ref.Hash()); err != nil {
-		return err
+	if o.VerifySignatures != nil {
+		c, err := w.r.CommitObject(ref.Hash())
+		if err != nil {
+			return err
+		}
+
+		if _, err := c.VerifySignature(o.VerifySignatures); err != nil {
+			return err
+		}
 	}
 
-	if err := w.Reset(&ResetOptions{
-		Mode:   MergeReset,
-		Commit: ref.Hash(),
-	}); err != nil {
+	if rebase != PullRebaseFalse {
+		err = w.rebase(head.Hash(), &RebaseOptions{
+			Upstream:  ref.Hash(),
+			Merges:    rebase == PullRebaseMerges,
+			Committer: o.Committer,
+			Autostash: o.Autostash,
+		})
+	} else {
+		err = w.pullMerge(ctx, o, ref.Hash())
+	}
+
+	if err != nil {
 		return err
 	}
 
 	if o.RecurseSubmodules != NoRecurseSubmodules {
-		return w.updateSubmodules(&SubmoduleUpdateOptions{
+		if err := w.updateSubmodules(&SubmoduleUpdateOptions{
 			RecurseSubmodules: o.RecurseSubmodules,
 			Auth:              o.Auth,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return w.runPostMergeHook()
+}
+
+// pullMerge updates HEAD and the worktree to the pulled commit, stashing the
+// local changes with PullOptions.Autostash or merge.autoStash.
+func (w *Worktree) pullMerge(ctx context.Context, o *PullOptions, commit plumbing.Hash) error {
+	update := func() error {
+		if err := w.r.setOrigHead(); err != nil {
+			return err
+		}
+
+		if err := w.updateHEAD(commit); err != nil {
+			return err
+		}
+
+		return w.resetContext(ctx, &ResetOptions{
+			Mode:   MergeReset,
+			Commit: commit,
 		})
 	}
 
-	return nil
+	autostash := o.Autostash
+	if !autostash {
+		cfg, err := w.r.Config()
+		if err != nil {
+			return err
+		}
+
+		autostash = cfg.Merge.AutoStash
+	}
+
+	if autostash {
+		return w.withAutostash(o.Committer, update)
+	}
+
+	return update()
+}
+
+// pullRebaseMode returns the rebase mode of the pull into the HEAD, given by
+// the options, branch.<name>.rebase or pull.rebase.
+func (w *Worktree) pullRebaseMode(o *PullOptions, head *plumbing.Reference) (PullRebaseMode, error) {
+	if o.Rebase != "" {
+		return o.Rebase, nil
+	}
+
+	cfg, err := w.r.Config()
+	if err != nil {
+		return "", err
+	}
+
+	if name := head.Name(); name.IsBranch() {
+		if b, ok := cfg.Branches[name.Short()]; ok && b.Rebase != "" {
+			return parsePullRebaseMode(b.Rebase)
+		}
+	}
+
+	return parsePullRebaseMode(cfg.Pull.Rebase)
 }
 
 func (w *Worktree) updateSubmodules(o *SubmoduleUpdateOptions) error {
@@ -139,12 +240,39 @@ func (w *Worktree) updateSubmodules(o *SubmoduleUpdateOptions) error {
 	return s.Update(o)
 }
 
-// Checkout switch branches or restore working tree files.
+// Checkout switch branches or restore working tree files. The error of the
+// post-checkout hook, if any, is returned once the checkout is completed.
 func (w *Worktree) Checkout(opts *CheckoutOptions) error {
+	return w.CheckoutContext(context.Background(), opts)
+}
+
+// CheckoutContext switch branches or restore working tree files. The error of
+// the post-checkout hook, if any, is returned once the checkout is completed.
+// With CheckoutOptions.Autostash the local changes are stashed and reapplied
+// on the checked out commit, returning an *AutostashConflictError if they
+// can't be reapplied.
+//
+// The provided Context must be non-nil. If the context expires before the
+// operation is complete, an error is returned, leaving the files written so
+// far at the worktree.
+func (w *Worktree) CheckoutContext(ctx context.Context, opts *CheckoutOptions) error {
 	if err := opts.Validate(); err != nil {
 		return err
 	}
 
+	if opts.Autostash && !opts.Force {
+		o := *opts
+		o.Autostash = false
+		return w.withAutostash(nil, func() error {
+			return w.CheckoutContext(ctx, &o)
+		})
+	}
+
+	var old plumbing.Hash
+	if head, err := w.r.Head(); err == nil {
+		old = head.Hash()
+	}
+
 	if opts.Create {
 		if err := w.createBranch(opts); err != nil {
 			return err
@@ -167,13 +295,18 @@ func (w *Worktree) Checkout(opts *CheckoutOptions) error {
 		return err
 	}
 
-	ro := &ResetOptions{Commit: c, Mode: MergeReset}
+	ro := &ResetOptions{
+		Commit:   c,
+		Mode:     MergeReset,
+		Workers:  opts.Workers,
+		Progress: opts.Progress,
+	}
 	if opts.Force {
 		ro.Mode = HardReset
 	}
 
-	if !opts.Hash.IsZero() && !opts.Create {
-		err = w.setHEADToCommit(opts.Hash)
+	if opts.Detach || !opts.Hash.IsZero() && !opts.Create {
+		err = w.setHEADToCommit(c)
 	} else {
 		err = w.setHEADToBranch(opts.Branch, c)
 	}
@@ -182,7 +315,11 @@ func (w *Worktree) Checkout(opts *CheckoutOptions) error {
 		return err
 	}
 
-	return w.Reset(ro)
+	if err := w.resetContext(ctx, ro); err != nil {
+		return err
+	}
+
+	return w.runPostCheckoutHook(old, c)
 }
 func (w *Worktree) createBranch(opts *CheckoutOptions) error {
 	_, err := w.r.Storer.Reference(opts.Branch)
@@ -194,6 +331,15 @@ func (w *Worktree) createBranch(opts *CheckoutOptions) error {
 		return err
 	}
 
+	if opts.Start != "" {
+		h, err := w.r.ResolveRevision(plumbing.Revision(opts.Start))
+		if err != nil {
+			return err
+		}
+
+		opts.Hash = *h
+	}
+
 	if opts.Hash.IsZero() {
 		ref, err := w.r.Head()
 		if err != nil {
@@ -203,9 +349,15 @@ func (w *Worktree) createBranch(opts *CheckoutOptions) error {
 		opts.Hash = ref.Hash()
 	}
 
-	return w.r.Storer.SetReference(
+	err = w.r.Storer.SetReference(
 		plumbing.NewHashReference(opts.Branch, opts.Hash),
 	)
+
+	if err != nil || opts.Start == "" {
+		return err
+	}
+
+	return w.r.setupTracking(opts.Branch, opts.Start)
 }
 
 func (w *Worktree) getCommitFromCheckoutOptions(opts *CheckoutOptions) (plumbing.Hash, error) {
@@ -264,10 +416,63 @@ func (w *Worktree) setHEADToBranch(branch plumbing.ReferenceName, commit plumbin
 
 // Reset the worktree to a specified state.
 func (w *Worktree) Reset(opts *ResetOptions) error {
+	return w.ResetContext(context.Background(), opts)
+}
+
+// ResetContext resets the worktree to a specified state. Unless only some
+// paths are reset, the previous commit of HEAD is recorded at ORIG_HEAD and
+// any merge in progress, recorded at MERGE_HEAD, is aborted.
+//
+// The provided Context must be non-nil. If the context expires before the
+// operation is complete, an error is returned, leaving the files written so
+// far at the worktree.
+func (w *Worktree) ResetContext(ctx context.Context, opts *ResetOptions) error {
+	if err := opts.Validate(w.r); err != nil {
+		return err
+	}
+
+	if len(opts.Paths) != 0 {
+		return w.resetContext(ctx, opts)
+	}
+
+	head, err := w.r.Head()
+	if err != nil && err != plumbing.ErrReferenceNotFound {
+		return err
+	}
+
+	if err := w.resetContext(ctx, opts); err != nil {
+		return err
+	}
+
+	if head != nil {
+		orig := plumbing.NewHashReference(plumbing.OrigHead, head.Hash())
+		if err := w.r.Storer.SetReference(orig); err != nil {
+			return err
+		}
+	}
+
+	return w.r.SetMergeHead()
+}
+
+// resetContext resets the worktree as ResetContext does, without recording
+// ORIG_HEAD, for the operations updating HEAD by themselves.
+func (w *Worktree) resetContext(ctx context.Context, opts *ResetOptions) error {
 	if err := opts.Validate(w.r); err != nil {
 		return err
 	}
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if len(opts.Paths) != 0 {
+		return w.RestorePaths(&RestoreOptions{
+			Source: opts.Commit,
+			Staged: true,
+			Paths:  opts.Paths,
+		})
+	}
+
 	if opts.Mode == MergeReset {
 		unstaged, err := w.containsUnstagedChanges()
 		if err != nil {
@@ -279,6 +484,14 @@ func (w *Worktree) Reset(opts *ResetOptions) error {
 		}
 	}
 
+	var keep []string
+	if opts.Mode == KeepReset {
+		var err error
+		if keep, err = w.keepResetPaths(opts.Commit); err != nil {
+			return err
+		}
+	}
+
 	if err := w.setHEADCommit(opts.Commit); err != nil {
 		return err
 	}
@@ -292,14 +505,92 @@ func (w *Worktree) Reset(opts *ResetOptions) error {
 		return err
 	}
 
-	if opts.Mode == MixedReset || opts.Mode == MergeReset || opts.Mode == HardReset {
-		if err := w.resetIndex(t); err != nil {
+	if err := w.resetIndex(t); err != nil {
+		return err
+	}
+
+	if opts.Mode == KeepReset {
+		return w.resetWorktreePaths(t, keep)
+	}
+
+	if opts.Mode == MergeReset || opts.Mode == HardReset {
+		if err := w.resetWorktree(ctx, t, opts); err != nil {
 			return err
 		}
 	}
 
-	if opts.Mode == MergeReset || opts.Mode == HardReset {
-		if err := w.resetWorktree(t); err != nil {
+	return nil
+}
+
+// keepResetPaths returns the paths of the files that are different between
+// HEAD and the given commit, failing if any of them has local changes.
+func (w *Worktree) keepResetPaths(commit plumbing.Hash) ([]string, error) {
+	head, err := w.r.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := w.getTreeFromCommitHash(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := w.getTreeFromCommitHash(commit)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := from.Diff(to)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := w.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for _, ch := range changes {
+		name := ch.To.Name
+		if name == "" {
+			name = ch.From.Name
+		}
+
+		if _, ok := status[name]; ok {
+			return nil, ErrWorktreeNotClean
+		}
+
+		paths = append(paths, name)
+	}
+
+	return paths, nil
+}
+
+// resetWorktreePaths sets the given files of the worktree to the ones at the
+// tree, removing the missing ones.
+func (w *Worktree) resetWorktreePaths(t *object.Tree, paths []string) error {
+	for _, name := range paths {
+		f, err := t.File(name)
+		if err == object.ErrFileNotFound {
+			err = rmFileAndDirIfEmpty(w.Filesystem, name)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		err = w.Filesystem.Remove(name)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		if err := w.checkoutFile(f); err != nil {
 			return err
 		}
 	}
@@ -338,15 +629,16 @@ func (w *Worktree) resetIndex(t *object.Tree) error {
 			name = ch.From.String()
 		}
 
-		_, _ = idx.Remove(name)
+		old, _ := idx.Remove(name)
 		if e == nil {
 			continue
 		}
 
 		idx.Entries = append(idx.Entries, &index.Entry{
-			Name: name,
-			Hash: e.Hash,
-			Mode: e.Mode,
+			Name:         name,
+			Hash:         e.Hash,
+			Mode:         e.Mode,
+			SkipWorktree: old != nil && old.SkipWorktree,
 		})
 
 	}
@@ -354,19 +646,125 @@ func (w *Worktree) resetIndex(t *object.Tree) error {
 	return w.r.Storer.SetIndex(idx)
 }
 
-func (w *Worktree) resetWorktree(t *object.Tree) error {
+func (w *Worktree) resetWorktree(ctx context.Context, t *object.Tree, opts *ResetOptions) (err error) {
+	ctx, span := startSpan(ctx, w.r.Tracer, TraceCheckout)
+	defer func() { span.End(err) }()
+
+	r := &checkoutReporter{progress: opts.Progress}
+	r.phase(CheckoutComparing)
+	phase := w.startCheckoutPhase(ctx, CheckoutComparing)
+
 	changes, err := w.diffStagingWithWorktree(true)
+	if err == nil {
+		err = w.validateCheckoutPaths(changes)
+	}
+
 	if err != nil {
+		phase.End(err)
 		return err
 	}
 
 	idx, err := w.r.Storer.Index()
 	if err != nil {
+		phase.End(err)
 		return err
 	}
 
+	phase.End(nil)
+	r.phase(CheckoutRemoving)
+	phase = w.startCheckoutPhase(ctx, CheckoutRemoving)
+
+	var files []*checkoutEntry
 	for _, ch := range changes {
-		if err := w.checkoutChange(ch, t, idx); err != nil {
+		if err := ctx.Err(); err != nil {
+			phase.End(err)
+			return err
+		}
+
+		f, err := w.checkoutChange(ch, t, idx)
+		if err != nil {
+			phase.End(err)
+			return err
+		}
+
+		if f != nil {
+			files = append(files, f)
+		}
+	}
+
+	phase.End(nil)
+	r.writing(len(files))
+	phase = w.startCheckoutPhase(ctx, CheckoutWriting)
+	err = w.checkoutFiles(ctx, files, idx, opts.Workers, r)
+	if err == nil {
+		err = w.r.Storer.SetIndex(idx)
+	}
+
+	phase.SetAttribute(TraceFiles, r.status.Files)
+	phase.SetAttribute(TraceBytes, r.status.Bytes)
+	phase.End(err)
+	if err != nil {
+		return err
+	}
+
+	r.phase(CheckoutDone)
+	return nil
+}
+
+func (w *Worktree) startCheckoutPhase(ctx context.Context, p CheckoutPhase) Span {
+	_, span := startSpan(ctx, w.r.Tracer, TraceCheckout+"-"+p.String())
+	return span
+}
+
+// RestorePaths restores the given paths at the index and/or the worktree,
+// without changing HEAD, like `git restore` or `git checkout <commit> -- <path>`
+// do. The files matching the paths, tracked at the index but missing at the
+// source, are removed.
+func (w *Worktree) RestorePaths(opts *RestoreOptions) error {
+	if err := opts.Validate(w.r); err != nil {
+		return err
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	source, err := w.restoreSource(opts, idx)
+	if err != nil {
+		return err
+	}
+
+	var tracked []string
+	for _, e := range idx.Entries {
+		if matchesAnyPath(e.Name, opts.Paths) {
+			tracked = append(tracked, e.Name)
+		}
+	}
+
+	if len(source) == 0 && len(tracked) == 0 {
+		return ErrPathNotFound
+	}
+
+	for _, name := range tracked {
+		if _, ok := source[name]; ok {
+			continue
+		}
+
+		if opts.Worktree {
+			err := rmFileAndDirIfEmpty(w.Filesystem, name)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+
+		if opts.Staged {
+			_, _ = idx.Remove(name)
+		}
+	}
+
+	for name, e := range source {
+		if err := w.restorePath(name, e, idx, opts); err != nil {
 			return err
 		}
 	}
@@ -374,10 +772,97 @@ func (w *Worktree) resetWorktree(t *object.Tree) error {
 	return w.r.Storer.SetIndex(idx)
 }
 
-func (w *Worktree) checkoutChange(ch merkletrie.Change, t *object.Tree, idx *index.Index) error {
+// restoreSource returns the entries matching the paths to restore, from the
+// index or from the source commit.
+func (w *Worktree) restoreSource(opts *RestoreOptions, idx *index.Index) (map[string]*index.Entry, error) {
+	source := make(map[string]*index.Entry)
+	if opts.Source.IsZero() {
+		for _, e := range idx.Entries {
+			if e.Mode != filemode.Submodule && matchesAnyPath(e.Name, opts.Paths) {
+				source[e.Name] = &index.Entry{Name: e.Name, Hash: e.Hash, Mode: e.Mode}
+			}
+		}
+
+		return source, nil
+	}
+
+	commit, err := w.r.resolveToCommitHash(opts.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := w.getTreeFromCommitHash(commit)
+	if err != nil {
+		return nil, err
+	}
+
+	err = t.Files().ForEach(func(f *object.File) error {
+		if matchesAnyPath(f.Name, opts.Paths) {
+			source[f.Name] = &index.Entry{Name: f.Name, Hash: f.Hash, Mode: f.Mode}
+		}
+
+		return nil
+	})
+
+	return source, err
+}
+
+func (w *Worktree) restorePath(name string, e *index.Entry, idx *index.Index, opts *RestoreOptions) error {
+	if opts.Worktree {
+		blob, err := w.r.BlobObject(e.Hash)
+		if err != nil {
+			return err
+		}
+
+		err = w.Filesystem.Remove(name)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		if err := w.checkoutFile(object.NewFile(name, e.Mode, blob)); err != nil {
+			return err
+		}
+	}
+
+	if !opts.Staged {
+		return nil
+	}
+
+	if opts.Worktree {
+		return w.addIndexFromFile(name, e.Hash, idx)
+	}
+
+	_, _ = idx.Remove(name)
+	idx.Entries = append(idx.Entries, e)
+	return nil
+}
+
+// matchesAnyPath returns true if name is any of the paths or is contained in
+// any of them.
+func matchesAnyPath(name string, paths []string) bool {
+	for _, p := range paths {
+		p = strings.TrimSuffix(filepath.ToSlash(filepath.Clean(p)), "/")
+		if p == "." || name == p || strings.HasPrefix(name, p+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkoutEntry is a file of a tree to be written at the worktree.
+type checkoutEntry struct {
+	name  string
+	entry *object.TreeEntry
+}
+
+// checkoutChange applies the change to the worktree and the index, except
+// for the regular files and symlinks to be written, which are returned to be
+// written by checkoutFiles.
+func (w *Worktree) checkoutChange(ch merkletrie.Change, t *object.Tree, idx *index.Index) (*checkoutEntry, error) {
 	a, err := ch.Action()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var e *object.TreeEntry
@@ -389,19 +874,19 @@ func (w *Worktree) checkoutChange(ch merkletrie.Change, t *object.Tree, idx *ind
 		name = ch.To.String()
 		e, err = t.FindEntry(name)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		isSubmodule = e.Mode == filemode.Submodule
 	case merkletrie.Delete:
-		return rmFileAndDirIfEmpty(w.Filesystem, ch.From.String())
+		return nil, rmFileAndDirIfEmpty(w.Filesystem, ch.From.String())
 	}
 
 	if isSubmodule {
-		return w.checkoutChangeSubmodule(name, a, e, idx)
+		return nil, w.checkoutChangeSubmodule(name, a, e, idx)
 	}
 
-	return w.checkoutChangeRegularFile(name, a, t, e, idx)
+	return w.checkoutChangeRegularFile(name, a, e)
 }
 
 func (w *Worktree) containsUnstagedChanges() (bool, error) {
@@ -485,45 +970,149 @@ func (w *Worktree) checkoutChangeSubmodule(name string,
 
 func (w *Worktree) checkoutChangeRegularFile(name string,
 	a merkletrie.Action,
-	t *object.Tree,
 	e *object.TreeEntry,
-	idx *index.Index,
-) error {
+) (*checkoutEntry, error) {
 	switch a {
 	case merkletrie.Modify:
-		_, _ = idx.Remove(name)
-
 		// to apply perm changes the file is deleted, billy doesn't implement
 		// chmod
 		if err := w.Filesystem.Remove(name); err != nil {
-			return err
+			return nil, err
 		}
 
 		fallthrough
 	case merkletrie.Insert:
-		f, err := t.File(name)
+		return &checkoutEntry{name: name, entry: e}, nil
+	}
+
+	return nil, nil
+}
+
+// checkoutFiles writes the given files at the worktree using the given number
+// of goroutines, reading and decompressing the blobs concurrently, and
+// replaces their entries at the index once all of them are written.
+func (w *Worktree) checkoutFiles(
+	ctx context.Context,
+	files []*checkoutEntry,
+	idx *index.Index,
+	workers int,
+	r *checkoutReporter,
+) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	if workers > len(files) {
+		workers = len(files)
+	}
+
+	entries := make([]*index.Entry, len(files))
+	errs := make([]error, len(files))
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for j := range next {
+				entries[j], errs[j] = w.checkoutEntryFile(files[j], r)
+			}
+		}()
+	}
+
+	for i := range files {
+		if ctx.Err() != nil {
+			break
+		}
+
+		next <- i
+	}
+
+	close(next)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for _, err := range errs {
 		if err != nil {
 			return err
 		}
+	}
 
-		if err := w.checkoutFile(f); err != nil {
-			return err
+	written := make(map[string]*index.Entry, len(entries))
+	for _, e := range entries {
+		written[e.Name] = e
+	}
+
+	for i, e := range idx.Entries {
+		if n, ok := written[e.Name]; ok {
+			idx.Entries[i] = n
+			delete(written, e.Name)
 		}
+	}
 
-		return w.addIndexFromFile(name, e.Hash, idx)
+	for _, e := range entries {
+		if _, ok := written[e.Name]; ok {
+			idx.Entries = append(idx.Entries, e)
+		}
 	}
 
 	return nil
 }
 
-func (w *Worktree) checkoutFile(f *object.File) (err error) {
+// checkoutEntryFile writes the file, returning its entry for the index.
+func (w *Worktree) checkoutEntryFile(f *checkoutEntry, r *checkoutReporter) (*index.Entry, error) {
+	blob, err := object.GetBlob(w.r.Storer, f.entry.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.checkoutFile(object.NewFile(f.name, f.entry.Mode, blob)); err != nil {
+		return nil, err
+	}
+
+	e, err := w.newIndexEntryFromFile(f.name, f.entry.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.entry.Mode == filemode.Symlink {
+		// the link may be checked out as a plain file
+		e.Mode = filemode.Symlink
+	}
+
+	r.written(f.name, blob.Size)
+	return e, nil
+}
+
+func (w *Worktree) checkoutFile(f *object.File) error {
+	return writeFile(w.Filesystem, f, w.Capabilities)
+}
+
+// writeFile writes the file at the filesystem, as a symbolic link if it is
+// one and the capabilities of the filesystem allow it.
+func writeFile(fs billy.Filesystem, f *object.File, caps func() (FilesystemCapabilities, error)) (err error) {
 	mode, err := f.Mode.ToOSFileMode()
 	if err != nil {
 		return
 	}
 
+	if err = checkLeadingSymlinks(fs, f.Name); err != nil {
+		return
+	}
+
+	// a link left at the path would be followed writing the file
+	if fi, err := fs.Lstat(f.Name); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		if err := fs.Remove(f.Name); err != nil {
+			return err
+		}
+	}
+
 	if mode&os.ModeSymlink != 0 {
-		return w.checkoutFileSymlink(f)
+		return writeFileSymlink(fs, f, caps)
 	}
 
 	from, err := f.Reader()
@@ -533,7 +1122,7 @@ func (w *Worktree) checkoutFile(f *object.File) (err error) {
 
 	defer ioutil.CheckClose(from, &err)
 
-	to, err := w.Filesystem.OpenFile(f.Name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	to, err := fs.OpenFile(f.Name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
 	if err != nil {
 		return
 	}
@@ -544,7 +1133,7 @@ func (w *Worktree) checkoutFile(f *object.File) (err error) {
 	return
 }
 
-func (w *Worktree) checkoutFileSymlink(f *object.File) (err error) {
+func writeFileSymlink(fs billy.Filesystem, f *object.File, caps func() (FilesystemCapabilities, error)) (err error) {
 	from, err := f.Reader()
 	if err != nil {
 		return
@@ -557,14 +1146,23 @@ func (w *Worktree) checkoutFileSymlink(f *object.File) (err error) {
 		return
 	}
 
-	err = w.Filesystem.Symlink(string(bytes), f.Name)
+	c, err := caps()
+	if err != nil {
+		return
+	}
+
+	if c.Symlinks {
+		err = fs.Symlink(string(bytes), f.Name)
+	}
 
 	// On windows, this might fail.
-	// Follow Git on Windows behavior by writing the link as it is.
-	if err != nil && isSymlinkWindowsNonAdmin(err) {
+	// Follow Git on Windows behavior by writing the link as it is, as with
+	// the filesystems not supporting links.
+	if !c.Symlinks || err == billy.ErrNotSupported ||
+		(err != nil && isSymlinkWindowsNonAdmin(err)) {
 		mode, _ := f.Mode.ToOSFileMode()
 
-		to, err := w.Filesystem.OpenFile(f.Name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+		to, err := fs.OpenFile(f.Name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
 		if err != nil {
 			return err
 		}
@@ -590,14 +1188,24 @@ func (w *Worktree) addIndexFromTreeEntry(name string, f *object.TreeEntry, idx *
 
 func (w *Worktree) addIndexFromFile(name string, h plumbing.Hash, idx *index.Index) error {
 	_, _ = idx.Remove(name)
-	fi, err := w.Filesystem.Lstat(name)
+	e, err := w.newIndexEntryFromFile(name, h)
 	if err != nil {
 		return err
 	}
 
+	idx.Entries = append(idx.Entries, e)
+	return nil
+}
+
+func (w *Worktree) newIndexEntryFromFile(name string, h plumbing.Hash) (*index.Entry, error) {
+	fi, err := w.Filesystem.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+
 	mode, err := filemode.NewFromOSFileMode(fi.Mode())
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	e := &index.Entry{
@@ -614,8 +1222,7 @@ func (w *Worktree) addIndexFromFile(name string, h plumbing.Hash, idx *index.Ind
 		fillSystemInfo(e, fi.Sys())
 	}
 
-	idx.Entries = append(idx.Entries, e)
-	return nil
+	return e, nil
 }
 
 func (w *Worktree) getTreeFromCommitHash(commit plumbing.Hash) (*object.Tree, error) {
@@ -714,29 +1321,154 @@ func (w *Worktree) readGitmodulesFile() (*config.Modules, error) {
 
 // Clean the worktree by removing untracked files.
 func (w *Worktree) Clean(opts *CleanOptions) error {
-	s, err := w.Status()
+	_, err := w.CleanFiles(opts)
+	return err
+}
+
+// CleanFiles removes the untracked files of the worktree, as `git clean`
+// does, returning the paths removed, or the ones that would be removed if
+// DryRun is set. The paths of the removed directories end with a slash.
+func (w *Worktree) CleanFiles(opts *CleanOptions) ([]string, error) {
+	if opts == nil {
+		opts = &CleanOptions{}
+	}
+
+	idx, err := w.r.Storer.Index()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Check Worktree status to be Untracked, obtain absolute path and delete.
-	for relativePath, status := range s {
-		// Check if the path contains a directory and if Dir options is false,
-		// skip the path.
-		if relativePath != filepath.Base(relativePath) && !opts.Dir {
-			continue
+	patterns, err := w.ignorePatterns()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &cleaner{
+		w:    w,
+		opts: opts,
+		idx:  idx,
+		m:    gitignore.NewMatcher(patterns),
+	}
+
+	paths, _, err := c.walk("", nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(paths)
+	if opts.DryRun {
+		return paths, nil
+	}
+
+	for _, p := range paths {
+		if strings.HasSuffix(p, "/") {
+			err = util.RemoveAll(w.Filesystem, strings.TrimSuffix(p, "/"))
+		} else {
+			err = w.Filesystem.Remove(p)
 		}
 
-		// Remove the file only if it's an untracked file.
-		if status.Worktree == Untracked {
-			absPath := filepath.Join(w.Filesystem.Root(), relativePath)
-			if err := os.Remove(absPath); err != nil {
-				return err
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return paths, nil
+}
+
+type cleaner struct {
+	w    *Worktree
+	opts *CleanOptions
+	idx  *index.Index
+	m    gitignore.Matcher
+}
+
+// walk returns the paths to be removed at the given directory, and whether
+// every entry of the directory is going to be removed.
+func (c *cleaner) walk(dir string, parts []string, ignored bool) ([]string, bool, error) {
+	fis, err := c.w.Filesystem.ReadDir(dir)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var paths []string
+	all := true
+	for _, fi := range fis {
+		name := path.Join(dir, fi.Name())
+		p := append(append([]string{}, parts...), fi.Name())
+		isIgnored := ignored || c.m.Match(p, fi.IsDir())
+
+		if !fi.IsDir() {
+			if c.isTracked(name) || !c.isCandidate(name, isIgnored) {
+				all = false
+				continue
 			}
+
+			paths = append(paths, name)
+			continue
+		}
+
+		if fi.Name() == GitDirName || c.isTracked(name) || c.isRepository(name) {
+			all = false
+			continue
+		}
+
+		if !c.opts.Dir && !c.hasPathsInside(name) {
+			all = false
+			continue
 		}
+
+		sub, subAll, err := c.walk(name, p, isIgnored)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if subAll && c.opts.Dir && c.isCandidate(name, isIgnored) {
+			paths = append(paths, name+"/")
+			continue
+		}
+
+		all = false
+		paths = append(paths, sub...)
 	}
 
-	return nil
+	return paths, all, nil
+}
+
+func (c *cleaner) isTracked(name string) bool {
+	_, err := c.idx.Entry(name)
+	return err == nil
+}
+
+// isRepository returns true if the directory is a nested repository, those
+// are never removed.
+func (c *cleaner) isRepository(name string) bool {
+	_, err := c.w.Filesystem.Lstat(path.Join(name, GitDirName))
+	return err == nil
+}
+
+func (c *cleaner) isCandidate(name string, ignored bool) bool {
+	if len(c.opts.Paths) != 0 && !matchesAnyPath(name, c.opts.Paths) {
+		return false
+	}
+
+	if c.opts.OnlyIgnored {
+		return ignored
+	}
+
+	return !ignored || c.opts.Ignored
+}
+
+// hasPathsInside returns true if any of the paths of the options is inside of
+// the given directory.
+func (c *cleaner) hasPathsInside(dir string) bool {
+	for _, p := range c.opts.Paths {
+		p = strings.TrimSuffix(filepath.ToSlash(filepath.Clean(p)), "/")
+		if strings.HasPrefix(p, dir+"/") || matchesAnyPath(dir, []string{p}) {
+			return true
+		}
+	}
+
+	return false
 }
 
 // GrepResult is structure of a grep result.
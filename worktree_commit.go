@@ -14,18 +14,51 @@ import (
 )
 
 // Commit stores the current contents of the index in a new commit along with
-// a log message from the user describing the changes.
+// a log message from the user describing the changes. If a merge is in
+// progress, the commit concludes it, removing MERGE_HEAD. The trailers of the
+// options are added to the message before running the hooks, and the message
+// is cleaned up afterwards, as CommitOptions.Cleanup says.
 func (w *Worktree) Commit(msg string, opts *CommitOptions) (plumbing.Hash, error) {
 	if err := opts.Validate(w.r); err != nil {
 		return plumbing.ZeroHash, err
 	}
 
+	var amended plumbing.Hash
+	if opts.Amend {
+		head, err := w.r.Head()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		amended = head.Hash()
+	}
+
+	if opts.Amend && msg == "" {
+		m, err := w.headCommitMessage()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		msg = m
+	}
+
 	if opts.All {
 		if err := w.autoAddModifiedAndDeleted(); err != nil {
 			return plumbing.ZeroHash, err
 		}
 	}
 
+	msg = addCommitTrailers(msg, opts)
+	msg, err := w.runPreCommitHooks(msg, opts)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	msg, err = w.cleanupCommitMessage(msg, opts)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
 	idx, err := w.r.Storer.Index()
 	if err != nil {
 		return plumbing.ZeroHash, err
@@ -46,7 +79,40 @@ func (w *Worktree) Commit(msg string, opts *CommitOptions) (plumbing.Hash, error
 		return plumbing.ZeroHash, err
 	}
 
-	return commit, w.updateHEAD(commit)
+	if err := w.updateHEAD(commit); err != nil {
+		return commit, err
+	}
+
+	if opts.Amend {
+		if err := w.appendAmendReflog(amended, commit, msg, opts.Committer); err != nil {
+			return commit, err
+		}
+	}
+
+	if err := w.r.SetMergeHead(); err != nil {
+		return commit, err
+	}
+
+	if err := w.runPostCommitHook(commit); err != nil {
+		return commit, err
+	}
+
+	w.r.runAutoGC(false)
+	return commit, nil
+}
+
+func (w *Worktree) headCommitMessage() (string, error) {
+	head, err := w.r.Head()
+	if err != nil {
+		return "", err
+	}
+
+	commit, err := w.r.CommitObject(head.Hash())
+	if err != nil {
+		return "", err
+	}
+
+	return commit.Message, nil
 }
 
 func (w *Worktree) autoAddModifiedAndDeleted() error {
@@ -83,6 +149,34 @@ func (w *Worktree) updateHEAD(commit plumbing.Hash) error {
 	return w.r.Storer.SetReference(ref)
 }
 
+// appendAmendReflog records the amend of old by commit at the reflogs of HEAD
+// and of the checked-out branch, as git commit --amend does.
+func (w *Worktree) appendAmendReflog(old, commit plumbing.Hash, msg string, committer *object.Signature) error {
+	head, err := w.r.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return err
+	}
+
+	names := []plumbing.ReferenceName{plumbing.HEAD}
+	if head.Type() != plumbing.HashReference {
+		names = append(names, head.Target())
+	}
+
+	e := &reflogEntry{
+		old:     old,
+		new:     commit,
+		message: "commit (amend): " + commitSubject(msg),
+	}
+
+	for _, name := range names {
+		if err := appendReflog(w.r, name, e, committer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (w *Worktree) buildCommitObject(msg string, opts *CommitOptions, tree plumbing.Hash) (plumbing.Hash, error) {
 	commit := &object.Commit{
 		Author:       *opts.Author,
@@ -92,6 +186,15 @@ func (w *Worktree) buildCommitObject(msg string, opts *CommitOptions, tree plumb
 		ParentHashes: opts.Parents,
 	}
 
+	if opts.Signer != nil {
+		sig, err := signObject(opts.Signer, commit)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		commit.PGPSignature = sig
+	}
+
 	obj := w.r.Storer.NewEncodedObject()
 	if err := commit.Encode(obj); err != nil {
 		return plumbing.ZeroHash, err
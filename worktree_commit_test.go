@@ -135,6 +135,107 @@ func (s *WorktreeSuite) TestRemoveAndCommitAll(c *C) {
 	assertStorageStatus(c, s.Repository, 13, 11, 11, expected)
 }
 
+func (s *WorktreeSuite) TestCommitAmend(c *C) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	util.WriteFile(fs, "foo", []byte("foo"), 0644)
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	first, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	util.WriteFile(fs, "bar", []byte("bar"), 0644)
+	_, err = w.Add("bar")
+	c.Assert(err, IsNil)
+
+	second, err := w.Commit("bar\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	util.WriteFile(fs, "bar", []byte("qux"), 0644)
+	_, err = w.Add("bar")
+	c.Assert(err, IsNil)
+
+	committer := &object.Signature{Name: "bar", Email: "bar@bar.bar", When: time.Now()}
+	amended, err := w.Commit("", &CommitOptions{Amend: true, Committer: committer})
+	c.Assert(err, IsNil)
+	c.Assert(amended, Not(Equals), second)
+
+	ref, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(ref.Name(), Equals, plumbing.Master)
+	c.Assert(ref.Hash(), Equals, amended)
+
+	commit, err := r.CommitObject(amended)
+	c.Assert(err, IsNil)
+	c.Assert(commit.Message, Equals, "bar\n")
+	c.Assert(commit.ParentHashes, DeepEquals, []plumbing.Hash{first})
+	c.Assert(commit.Author.Name, Equals, defaultSignature().Name)
+	c.Assert(commit.Committer.Name, Equals, "bar")
+
+	file, err := commit.File("bar")
+	c.Assert(err, IsNil)
+	content, err := file.Contents()
+	c.Assert(err, IsNil)
+	c.Assert(content, Equals, "qux")
+}
+
+func (s *WorktreeSuite) TestCommitAmendReflog(c *C) {
+	r, err := PlainInit(c.MkDir(), false)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	util.WriteFile(w.Filesystem, "foo", []byte("foo"), 0644)
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	first, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	amended, err := w.Commit("bar\n\nqux\n", &CommitOptions{Amend: true, Committer: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	for _, name := range []plumbing.ReferenceName{plumbing.HEAD, plumbing.Master} {
+		entries, err := readReflog(r, name)
+		c.Assert(err, IsNil)
+		c.Assert(entries, HasLen, 1)
+		c.Assert(entries[0].old, Equals, first)
+		c.Assert(entries[0].new, Equals, amended)
+		c.Assert(entries[0].message, Equals, "commit (amend): bar")
+	}
+}
+
+func (s *WorktreeSuite) TestCommitAmendInvalidOptions(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	_, err = w.Commit("foo\n", &CommitOptions{Amend: true, Author: defaultSignature()})
+	c.Assert(err, Equals, plumbing.ErrReferenceNotFound)
+
+	hash, err := commitTestFile(c, w, nil)
+	c.Assert(err, IsNil)
+
+	_, err = w.Commit("foo\n", &CommitOptions{Amend: true})
+	c.Assert(err, Equals, ErrMissingCommitter)
+
+	_, err = w.Commit("foo\n", &CommitOptions{
+		Amend:   true,
+		Author:  defaultSignature(),
+		Parents: []plumbing.Hash{hash},
+	})
+	c.Assert(err, Equals, ErrParentsAndAmend)
+}
+
 func assertStorageStatus(
 	c *C, r *Repository,
 	treesCount, blobCount, commitCount int, head plumbing.Hash,
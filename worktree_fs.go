@@ -0,0 +1,204 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
+)
+
+var (
+	// ErrPathBeyondSymlink is returned checking out a file whose path goes
+	// through a symbolic link, which could write it outside the worktree.
+	ErrPathBeyondSymlink = errors.New("path is beyond a symbolic link")
+	// ErrPathTooLong is returned checking out a file whose path is longer
+	// than the supported by the worktree filesystem.
+	ErrPathTooLong = errors.New("path too long for the worktree filesystem")
+)
+
+// FilesystemCapabilities describes the features supported by the filesystem
+// of a worktree.
+type FilesystemCapabilities struct {
+	// Symlinks is true if the filesystem supports symbolic links, otherwise
+	// they are checked out as plain files with the link target as content,
+	// as git does with core.symlinks set to false.
+	Symlinks bool
+	// MaxPathLength is the maximum length of the paths relative to the root
+	// of the filesystem, 0 if unlimited. The paths are checked before
+	// changing any file of the worktree.
+	MaxPathLength int
+}
+
+// CapableFilesystem is implemented by the worktree filesystems declaring
+// their capabilities, instead of them being probed.
+type CapableFilesystem interface {
+	WorktreeCapabilities() FilesystemCapabilities
+}
+
+// Capabilities returns the capabilities of the worktree filesystem, as
+// declared by the filesystem if it implements CapableFilesystem. Otherwise
+// the support of symbolic links is given by core.symlinks or, if not set,
+// probed creating a link at the .git directory of the worktree, or its root
+// if there is none, setting core.symlinks to false if they aren't supported.
+func (w *Worktree) Capabilities() (FilesystemCapabilities, error) {
+	if fs, ok := w.Filesystem.(CapableFilesystem); ok {
+		return fs.WorktreeCapabilities(), nil
+	}
+
+	cfg, err := w.r.Config()
+	if err != nil {
+		return FilesystemCapabilities{}, err
+	}
+
+	if cfg.Core.Symlinks != "" {
+		return FilesystemCapabilities{Symlinks: cfg.Core.Symlinks != "false"}, nil
+	}
+
+	w.symlinksOnce.Do(func() {
+		w.symlinks, w.symlinksErr = w.probeSymlinks()
+		if w.symlinksErr != nil || w.symlinks {
+			return
+		}
+
+		cfg.Core.Symlinks = "false"
+		w.symlinksErr = w.r.Storer.SetConfig(cfg)
+	})
+
+	return FilesystemCapabilities{Symlinks: w.symlinks}, w.symlinksErr
+}
+
+func (w *Worktree) probeSymlinks() (bool, error) {
+	name := fmt.Sprintf(".git-symlink-probe-%d", time.Now().UnixNano())
+	if fi, err := w.Filesystem.Lstat(GitDirName); err == nil && fi.IsDir() {
+		name = w.Filesystem.Join(GitDirName, name)
+	}
+
+	err := w.Filesystem.Symlink(name, name)
+	if err == billy.ErrNotSupported || (err != nil && isSymlinkWindowsNonAdmin(err)) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	defer w.Filesystem.Remove(name)
+
+	// some filesystems write the link as a plain file
+	fi, err := w.Filesystem.Lstat(name)
+	if err != nil {
+		return false, err
+	}
+
+	return fi.Mode()&os.ModeSymlink != 0, nil
+}
+
+// writesSymlinksAsFiles returns true if the symbolic links are known to be
+// checked out as plain files, without probing the filesystem.
+func (w *Worktree) writesSymlinksAsFiles() bool {
+	if fs, ok := w.Filesystem.(CapableFilesystem); ok {
+		return !fs.WorktreeCapabilities().Symlinks
+	}
+
+	cfg, err := w.r.Config()
+	return err == nil && cfg.Core.Symlinks == "false"
+}
+
+// newLinkFileHasher returns a hash function for the filesystem noder hashing
+// the symbolic links of the index checked out as plain files as links, nil if
+// the links aren't checked out as files.
+func (w *Worktree) newLinkFileHasher(idx *index.Index) func(string, os.FileInfo) []byte {
+	if !w.writesSymlinksAsFiles() {
+		return nil
+	}
+
+	links := make(map[string]bool)
+	for _, e := range idx.Entries {
+		if e.Mode == filemode.Symlink {
+			links[e.Name] = true
+		}
+	}
+
+	if len(links) == 0 {
+		return nil
+	}
+
+	return func(name string, fi os.FileInfo) []byte {
+		if !links[name] || !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := w.Filesystem.Open(name)
+		if err != nil {
+			return nil
+		}
+
+		defer f.Close()
+
+		h := plumbing.NewHasher(plumbing.BlobObject, fi.Size())
+		if _, err := io.Copy(h, f); err != nil {
+			return nil
+		}
+
+		sum := h.Sum()
+		return append(sum[:], filemode.Symlink.Bytes()...)
+	}
+}
+
+// validateCheckoutPaths checks the length of the paths of the files written
+// by the changes, before writing any of them.
+func (w *Worktree) validateCheckoutPaths(changes merkletrie.Changes) error {
+	fs, ok := w.Filesystem.(CapableFilesystem)
+	if !ok {
+		return nil
+	}
+
+	max := fs.WorktreeCapabilities().MaxPathLength
+	if max <= 0 {
+		return nil
+	}
+
+	for _, ch := range changes {
+		if len(ch.To) != 0 && len(ch.To.String()) > max {
+			return ErrPathTooLong
+		}
+	}
+
+	return nil
+}
+
+// checkLeadingSymlinks returns ErrPathBeyondSymlink if any of the parent
+// directories of the file is a symbolic link, since writing the file would
+// follow the link, maybe out of the worktree.
+func checkLeadingSymlinks(fs billy.Filesystem, name string) error {
+	parts := strings.Split(path.Dir(name), "/")
+	for i := range parts {
+		if parts[i] == "." {
+			return nil
+		}
+
+		fi, err := fs.Lstat(strings.Join(parts[:i+1], "/"))
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return ErrPathBeyondSymlink
+		}
+	}
+
+	return nil
+}
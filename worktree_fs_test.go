@@ -0,0 +1,204 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type WorktreeFSSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&WorktreeFSSuite{})
+
+// noSymlinksFS is a filesystem without support for symbolic links.
+type noSymlinksFS struct {
+	billy.Filesystem
+}
+
+func (fs *noSymlinksFS) Symlink(target, link string) error {
+	return billy.ErrNotSupported
+}
+
+// symlinkRecorderFS is a filesystem recording the symbolic links created.
+type symlinkRecorderFS struct {
+	billy.Filesystem
+	links []string
+}
+
+func (fs *symlinkRecorderFS) Symlink(target, link string) error {
+	fs.links = append(fs.links, link)
+	return fs.Filesystem.Symlink(target, link)
+}
+
+// capableFS is a filesystem declaring its capabilities.
+type capableFS struct {
+	billy.Filesystem
+	caps FilesystemCapabilities
+}
+
+func (fs *capableFS) WorktreeCapabilities() FilesystemCapabilities {
+	return fs.caps
+}
+
+func (s *WorktreeFSSuite) checkout(c *C, fs billy.Filesystem, changes ...FileChange) (*Repository, *Worktree, error) {
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	h, err := r.CommitChanges("foo\n", &CommitChangesOptions{
+		Author:  defaultSignature(),
+		Changes: changes,
+	})
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+	return r, w, w.Reset(&ResetOptions{Mode: HardReset, Commit: h})
+}
+
+func (s *WorktreeFSSuite) TestCheckoutSymlinksAsFiles(c *C) {
+	fs := memfs.New()
+	r, w, err := s.checkout(c, &noSymlinksFS{fs},
+		FileChange{Path: "foo", Content: strings.NewReader("foo")},
+		FileChange{Path: "bar", Content: strings.NewReader("foo"), Mode: filemode.Symlink},
+	)
+	c.Assert(err, IsNil)
+
+	fi, err := fs.Lstat("bar")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode().IsRegular(), Equals, true)
+
+	f, err := fs.Open("bar")
+	c.Assert(err, IsNil)
+	defer f.Close()
+
+	content, err := ioutil.ReadAll(f)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "foo")
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Core.Symlinks, Equals, "false")
+
+	idx, err := r.Storer.Index()
+	c.Assert(err, IsNil)
+	e, err := idx.Entry("bar")
+	c.Assert(err, IsNil)
+	c.Assert(e.Mode, Equals, filemode.Symlink)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+
+	c.Assert(util.WriteFile(fs, "bar", []byte("qux"), 0644), IsNil)
+	_, err = w.Add("bar")
+	c.Assert(err, IsNil)
+
+	idx, err = r.Storer.Index()
+	c.Assert(err, IsNil)
+	e, err = idx.Entry("bar")
+	c.Assert(err, IsNil)
+	c.Assert(e.Mode, Equals, filemode.Symlink)
+}
+
+func (s *WorktreeFSSuite) TestCapabilitiesProbe(c *C) {
+	fs := memfs.New()
+	r, w, err := s.checkout(c, fs,
+		FileChange{Path: "bar", Content: strings.NewReader("foo"), Mode: filemode.Symlink},
+	)
+	c.Assert(err, IsNil)
+
+	caps, err := w.Capabilities()
+	c.Assert(err, IsNil)
+	c.Assert(caps.Symlinks, Equals, true)
+
+	fi, err := fs.Lstat("bar")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode()&os.ModeSymlink != 0, Equals, true)
+
+	files, err := fs.ReadDir(".")
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 1)
+
+	cfg, err := r.Config()
+	c.Assert(err, IsNil)
+	c.Assert(cfg.Core.Symlinks, Equals, "")
+}
+
+func (s *WorktreeFSSuite) TestCapabilitiesProbeGitDir(c *C) {
+	fs := &symlinkRecorderFS{Filesystem: memfs.New()}
+	c.Assert(fs.MkdirAll(GitDirName, 0755), IsNil)
+
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	caps, err := w.Capabilities()
+	c.Assert(err, IsNil)
+	c.Assert(caps.Symlinks, Equals, true)
+
+	c.Assert(fs.links, HasLen, 1)
+	c.Assert(strings.HasPrefix(fs.links[0], GitDirName+"/"), Equals, true)
+
+	files, err := fs.ReadDir(GitDirName)
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 0)
+}
+
+func (s *WorktreeFSSuite) TestCheckoutPathTooLong(c *C) {
+	fs := memfs.New()
+	caps := FilesystemCapabilities{Symlinks: true, MaxPathLength: 8}
+	_, _, err := s.checkout(c, &capableFS{fs, caps},
+		FileChange{Path: "foo", Content: strings.NewReader("foo")},
+		FileChange{Path: "qux/bar/baz", Content: strings.NewReader("baz")},
+	)
+	c.Assert(err, Equals, ErrPathTooLong)
+
+	_, err = fs.Lstat("foo")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *WorktreeFSSuite) TestCheckoutBeyondSymlink(c *C) {
+	fs := memfs.New()
+	r, w, err := s.checkout(c, fs,
+		FileChange{Path: "qux/bar", Content: strings.NewReader("bar")},
+	)
+	c.Assert(err, IsNil)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	commit, err := r.CommitObject(head.Hash())
+	c.Assert(err, IsNil)
+	f, err := commit.File("qux/bar")
+	c.Assert(err, IsNil)
+
+	c.Assert(fs.Symlink("/tmp", "link"), IsNil)
+
+	err = w.checkoutFile(object.NewFile("link/bar", filemode.Regular, &f.Blob))
+	c.Assert(err, Equals, ErrPathBeyondSymlink)
+
+	_, err = fs.Lstat("/tmp/bar")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	// a link at the path itself is replaced, instead of followed
+	c.Assert(fs.Symlink("/tmp/bar", "qux/baz"), IsNil)
+	c.Assert(w.checkoutFile(object.NewFile("qux/baz", filemode.Regular, &f.Blob)), IsNil)
+
+	fi, err := fs.Lstat("qux/baz")
+	c.Assert(err, IsNil)
+	c.Assert(fi.Mode().IsRegular(), Equals, true)
+
+	_, err = fs.Lstat("/tmp/bar")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
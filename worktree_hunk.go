@@ -0,0 +1,367 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/utils/diff"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+var (
+	// ErrHunkNotApplicable is returned when a hunk doesn't match the content
+	// it is applied to.
+	ErrHunkNotApplicable = errors.New("hunk does not apply")
+)
+
+// Hunk is a group of contiguous changed lines between two versions of a
+// file, as the hunks shown by `git add -p` without the context lines. Every
+// line includes its trailing newline, if any.
+type Hunk struct {
+	// FromLine is the index, starting at 0, of the first line of the hunk at
+	// the source version.
+	FromLine int
+	// ToLine is the index, starting at 0, of the first line of the hunk at
+	// the destination version.
+	ToLine int
+	// From are the lines removed from the source version.
+	From []string
+	// To are the lines added at the destination version.
+	To []string
+}
+
+// Select returns a copy of the hunk containing only the given removed and
+// added lines, the indexes refer to h.From and h.To. The removed lines not
+// selected are kept, and the added lines not selected are dropped, allowing
+// to stage individual lines.
+func (h *Hunk) Select(removed, added []int) *Hunk {
+	isRemoved := make(map[int]bool, len(removed))
+	for _, i := range removed {
+		isRemoved[i] = true
+	}
+
+	s := &Hunk{FromLine: h.FromLine, ToLine: h.ToLine, From: h.From}
+	for i, line := range h.From {
+		if !isRemoved[i] {
+			s.To = append(s.To, line)
+		}
+	}
+
+	for _, i := range added {
+		if i >= 0 && i < len(h.To) {
+			s.To = append(s.To, h.To[i])
+		}
+	}
+
+	return s
+}
+
+func (h *Hunk) equal(o *Hunk) bool {
+	return h.FromLine == o.FromLine &&
+		strings.Join(h.From, "") == strings.Join(o.From, "") &&
+		strings.Join(h.To, "") == strings.Join(o.To, "")
+}
+
+// Hunks returns the hunks of the changes of the given file between the index
+// and the worktree, a file missing at any of them is considered empty.
+func (w *Worktree) Hunks(path string) ([]*Hunk, error) {
+	src, err := w.indexFileContent(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dst, err := w.worktreeFileContent(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return computeHunks(src, dst), nil
+}
+
+// StagedHunks returns the hunks of the changes of the given file between HEAD
+// and the index, a file missing at any of them is considered empty.
+func (w *Worktree) StagedHunks(path string) ([]*Hunk, error) {
+	src, err := w.headFileContent(path)
+	if err != nil {
+		return nil, err
+	}
+
+	dst, err := w.indexFileContent(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return computeHunks(src, dst), nil
+}
+
+// StageHunks applies the given hunks, as returned by Hunks or selected with
+// Hunk.Select, to the content of the file at the index. The worktree is not
+// modified.
+func (w *Worktree) StageHunks(path string, hunks ...*Hunk) error {
+	content, err := w.indexFileContent(path)
+	if err != nil {
+		return err
+	}
+
+	content, err = applyHunks(content, hunks)
+	if err != nil {
+		return err
+	}
+
+	return w.setIndexFileContent(path, content)
+}
+
+// UnstageHunks reverts the given hunks, as returned by StagedHunks, from the
+// content of the file at the index. The worktree is not modified.
+func (w *Worktree) UnstageHunks(path string, hunks ...*Hunk) error {
+	staged, err := w.StagedHunks(path)
+	if err != nil {
+		return err
+	}
+
+	var keep []*Hunk
+	for _, s := range staged {
+		var unstage bool
+		for _, h := range hunks {
+			if s.equal(h) {
+				unstage = true
+				break
+			}
+		}
+
+		if !unstage {
+			keep = append(keep, s)
+		}
+	}
+
+	if len(keep)+len(hunks) != len(staged) {
+		return ErrHunkNotApplicable
+	}
+
+	content, err := w.headFileContent(path)
+	if err != nil {
+		return err
+	}
+
+	content, err = applyHunks(content, keep)
+	if err != nil {
+		return err
+	}
+
+	return w.setIndexFileContent(path, content)
+}
+
+func (w *Worktree) headFileContent(path string) (string, error) {
+	head, err := w.r.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	commit, err := w.r.CommitObject(head.Hash())
+	if err != nil {
+		return "", err
+	}
+
+	f, err := commit.File(path)
+	if err == object.ErrFileNotFound {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return f.Contents()
+}
+
+func (w *Worktree) indexFileContent(path string) (string, error) {
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return "", err
+	}
+
+	e, err := idx.Entry(path)
+	if err == index.ErrEntryNotFound {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	blob, err := w.r.BlobObject(e.Hash)
+	if err != nil {
+		return "", err
+	}
+
+	return blobContent(blob)
+}
+
+func blobContent(b *object.Blob) (s string, err error) {
+	r, err := b.Reader()
+	if err != nil {
+		return "", err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+
+	buf := bytes.NewBuffer(nil)
+	if _, err = buf.ReadFrom(r); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+func (w *Worktree) worktreeFileContent(path string) (string, error) {
+	content, err := readFile(w.Filesystem, path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+
+	return string(content), err
+}
+
+func (w *Worktree) setIndexFileContent(path, content string) error {
+	obj := w.r.Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(content)))
+
+	writer, err := obj.Writer()
+	if err != nil {
+		return err
+	}
+
+	if _, err := writer.Write([]byte(content)); err != nil {
+		return err
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	h, err := w.r.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return err
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	e, err := idx.Entry(path)
+	if err == index.ErrEntryNotFound {
+		e = idx.Add(path)
+		err = w.doUpdateFileToIndex(e, path, h)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	e.Hash = h
+	e.Size = uint32(len(content))
+
+	return w.r.Storer.SetIndex(idx)
+}
+
+// computeHunks returns the hunks changing src into dst.
+func computeHunks(src, dst string) []*Hunk {
+	var hunks []*Hunk
+	var current *Hunk
+	var from, to int
+
+	for _, d := range diff.Do(src, dst) {
+		lines := splitLines(d.Text)
+		if d.Type == diffmatchpatch.DiffEqual {
+			current = nil
+			from += len(lines)
+			to += len(lines)
+			continue
+		}
+
+		if current == nil {
+			current = &Hunk{FromLine: from, ToLine: to}
+			hunks = append(hunks, current)
+		}
+
+		if d.Type == diffmatchpatch.DiffDelete {
+			current.From = append(current.From, lines...)
+			from += len(lines)
+		} else {
+			current.To = append(current.To, lines...)
+			to += len(lines)
+		}
+	}
+
+	return hunks
+}
+
+// applyHunks replaces the From lines of every hunk with its To lines, the
+// hunks must not overlap and match the content.
+func applyHunks(content string, hunks []*Hunk) (string, error) {
+	sorted := make([]*Hunk, len(hunks))
+	copy(sorted, hunks)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].FromLine < sorted[j].FromLine
+	})
+
+	lines := splitLines(content)
+	buf := bytes.NewBuffer(nil)
+
+	var pos int
+	for _, h := range sorted {
+		if h.FromLine < pos || h.FromLine+len(h.From) > len(lines) {
+			return "", ErrHunkNotApplicable
+		}
+
+		for _, line := range lines[pos:h.FromLine] {
+			buf.WriteString(line)
+		}
+
+		for i, line := range h.From {
+			if lines[h.FromLine+i] != line {
+				return "", ErrHunkNotApplicable
+			}
+		}
+
+		for _, line := range h.To {
+			buf.WriteString(line)
+		}
+
+		pos = h.FromLine + len(h.From)
+	}
+
+	for _, line := range lines[pos:] {
+		buf.WriteString(line)
+	}
+
+	return buf.String(), nil
+}
+
+// splitLines splits s after every newline.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
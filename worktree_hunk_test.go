@@ -0,0 +1,146 @@
+package git
+
+import (
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type HunkSuite struct {
+	BaseSuite
+	w *Worktree
+}
+
+var _ = Suite(&HunkSuite{})
+
+func (s *HunkSuite) SetUpTest(c *C) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	s.w, err = r.Worktree()
+	c.Assert(err, IsNil)
+
+	s.writeFile(c, "a\nb\nc\nd\ne\n")
+	_, err = s.w.Add("foo")
+	c.Assert(err, IsNil)
+
+	_, err = s.w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+}
+
+func (s *HunkSuite) writeFile(c *C, content string) {
+	err := util.WriteFile(s.w.Filesystem, "foo", []byte(content), 0644)
+	c.Assert(err, IsNil)
+}
+
+func (s *HunkSuite) assertIndexContent(c *C, expected string) {
+	content, err := s.w.indexFileContent("foo")
+	c.Assert(err, IsNil)
+	c.Assert(content, Equals, expected)
+}
+
+func (s *HunkSuite) TestHunks(c *C) {
+	s.writeFile(c, "a\nB\nc\nd\ne\nf\n")
+
+	hunks, err := s.w.Hunks("foo")
+	c.Assert(err, IsNil)
+	c.Assert(hunks, DeepEquals, []*Hunk{
+		{FromLine: 1, ToLine: 1, From: []string{"b\n"}, To: []string{"B\n"}},
+		{FromLine: 5, ToLine: 5, To: []string{"f\n"}},
+	})
+}
+
+func (s *HunkSuite) TestStageHunks(c *C) {
+	s.writeFile(c, "a\nB\nc\nd\ne\nf\n")
+
+	hunks, err := s.w.Hunks("foo")
+	c.Assert(err, IsNil)
+
+	err = s.w.StageHunks("foo", hunks[1])
+	c.Assert(err, IsNil)
+	s.assertIndexContent(c, "a\nb\nc\nd\ne\nf\n")
+
+	status, err := s.w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Staging, Equals, Modified)
+	c.Assert(status.File("foo").Worktree, Equals, Modified)
+
+	hunks, err = s.w.Hunks("foo")
+	c.Assert(err, IsNil)
+	c.Assert(hunks, HasLen, 1)
+
+	err = s.w.StageHunks("foo", hunks...)
+	c.Assert(err, IsNil)
+	s.assertIndexContent(c, "a\nB\nc\nd\ne\nf\n")
+
+	status, err = s.w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Unmodified)
+}
+
+func (s *HunkSuite) TestStageHunksSelectLines(c *C) {
+	s.writeFile(c, "a\nb\nC\nD\nd\ne\n")
+
+	hunks, err := s.w.Hunks("foo")
+	c.Assert(err, IsNil)
+	c.Assert(hunks, HasLen, 1)
+	c.Assert(hunks[0].From, DeepEquals, []string{"c\n"})
+	c.Assert(hunks[0].To, DeepEquals, []string{"C\n", "D\n"})
+
+	err = s.w.StageHunks("foo", hunks[0].Select(nil, []int{1}))
+	c.Assert(err, IsNil)
+	s.assertIndexContent(c, "a\nb\nc\nD\nd\ne\n")
+}
+
+func (s *HunkSuite) TestStageHunksNotApplicable(c *C) {
+	err := s.w.StageHunks("foo", &Hunk{FromLine: 1, From: []string{"x\n"}})
+	c.Assert(err, Equals, ErrHunkNotApplicable)
+
+	err = s.w.StageHunks("foo", &Hunk{FromLine: 5, From: []string{"x\n"}})
+	c.Assert(err, Equals, ErrHunkNotApplicable)
+}
+
+func (s *HunkSuite) TestStageHunksNewFile(c *C) {
+	err := util.WriteFile(s.w.Filesystem, "bar", []byte("a\nb\n"), 0644)
+	c.Assert(err, IsNil)
+
+	hunks, err := s.w.Hunks("bar")
+	c.Assert(err, IsNil)
+	c.Assert(hunks, HasLen, 1)
+
+	err = s.w.StageHunks("bar", hunks[0].Select(nil, []int{0}))
+	c.Assert(err, IsNil)
+
+	content, err := s.w.indexFileContent("bar")
+	c.Assert(err, IsNil)
+	c.Assert(content, Equals, "a\n")
+
+	status, err := s.w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("bar").Staging, Equals, Added)
+}
+
+func (s *HunkSuite) TestUnstageHunks(c *C) {
+	s.writeFile(c, "a\nB\nc\nd\ne\nf\n")
+	_, err := s.w.Add("foo")
+	c.Assert(err, IsNil)
+
+	hunks, err := s.w.StagedHunks("foo")
+	c.Assert(err, IsNil)
+	c.Assert(hunks, HasLen, 2)
+
+	err = s.w.UnstageHunks("foo", hunks[0])
+	c.Assert(err, IsNil)
+	s.assertIndexContent(c, "a\nb\nc\nd\ne\nf\n")
+
+	err = s.w.UnstageHunks("foo", hunks[0])
+	c.Assert(err, Equals, ErrHunkNotApplicable)
+
+	hunks, err = s.w.Hunks("foo")
+	c.Assert(err, IsNil)
+	c.Assert(hunks, DeepEquals, []*Hunk{
+		{FromLine: 1, ToLine: 1, From: []string{"b\n"}, To: []string{"B\n"}},
+	})
+}
@@ -0,0 +1,288 @@
+package git
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitignore"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+)
+
+// infoExcludeFile is the path, relative to the git directory, of the
+// patterns ignored only by the repository.
+const infoExcludeFile = "info/exclude"
+
+// IgnoreRule is a pattern of the files ignored at a worktree.
+type IgnoreRule struct {
+	// Source is the file the pattern was read from: the path relative to
+	// the worktree of a .gitignore file, or the path of the exclude file,
+	// such as the one at core.excludesFile. It is empty for the patterns at
+	// Worktree.Excludes.
+	Source string
+	// Line is the line of the pattern at Source, starting at 1.
+	Line int
+	// Pattern is the pattern as written at Source, empty for the patterns
+	// at Worktree.Excludes.
+	Pattern string
+
+	pattern gitignore.Pattern
+}
+
+// IgnoreMatcher is a gitignore.Matcher with all the patterns applied to a
+// worktree, as returned by Worktree.IgnoreMatcher.
+type IgnoreMatcher struct {
+	// Rules are the patterns in ascending order of priority.
+	Rules []*IgnoreRule
+}
+
+// Match returns true if the path is ignored.
+func (m *IgnoreMatcher) Match(path []string, isDir bool) bool {
+	_, ignored := m.Explain(path, isDir)
+	return ignored
+}
+
+// Explain returns the rule deciding whether the path is ignored, as reported
+// by git check-ignore -v, and whether the path is ignored by it, false if the
+// rule is negated. It returns a nil rule if no rule matches the path.
+func (m *IgnoreMatcher) Explain(path []string, isDir bool) (rule *IgnoreRule, ignored bool) {
+	for i := len(m.Rules) - 1; i >= 0; i-- {
+		if match := m.Rules[i].pattern.Match(path, isDir); match > gitignore.NoMatch {
+			return m.Rules[i], match == gitignore.Exclude
+		}
+	}
+
+	return nil, false
+}
+
+func (m *IgnoreMatcher) patterns() []gitignore.Pattern {
+	ps := make([]gitignore.Pattern, len(m.Rules))
+	for i, r := range m.Rules {
+		ps[i] = r.pattern
+	}
+
+	return ps
+}
+
+// IgnoreMatcher returns the matcher of the files ignored at the worktree. The
+// patterns are read, in ascending order of priority, from core.excludesFile,
+// .git/info/exclude, the .gitignore files of the worktree and Excludes.
+func (w *Worktree) IgnoreMatcher() (*IgnoreMatcher, error) {
+	ex, err := w.readExcludes()
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := readIgnoreRules(w.Filesystem, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &IgnoreMatcher{}
+	m.Rules = append(m.Rules, ex.excludesFile.rules...)
+	m.Rules = append(m.Rules, ex.infoExclude.rules...)
+	m.Rules = append(m.Rules, rules...)
+	for _, p := range w.Excludes {
+		m.Rules = append(m.Rules, &IgnoreRule{pattern: p})
+	}
+
+	return m, nil
+}
+
+// readIgnoreRules reads the .gitignore files recursively, the same way as
+// gitignore.ReadPatterns does.
+func readIgnoreRules(fs billy.Filesystem, path []string) ([]*IgnoreRule, error) {
+	name := fs.Join(append(path, gitignoreFileName)...)
+
+	var rules []*IgnoreRule
+	if content, err := readFile(fs, name); err == nil {
+		rules = parseIgnoreRules(content, path, name)
+	}
+
+	fis, err := fs.ReadDir(fs.Join(path...))
+	if err != nil {
+		return rules, err
+	}
+
+	for _, fi := range fis {
+		if !fi.IsDir() || fi.Name() == GitDirName {
+			continue
+		}
+
+		sub, err := readIgnoreRules(fs, append(path[:len(path):len(path)], fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, sub...)
+	}
+
+	return rules, nil
+}
+
+// parseIgnoreRules parses the patterns of an ignore file, skipping the
+// comments and the blank lines.
+func parseIgnoreRules(content []byte, domain []string, source string) []*IgnoreRule {
+	var rules []*IgnoreRule
+	for i, s := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(s, "#") || len(strings.TrimSpace(s)) == 0 {
+			continue
+		}
+
+		rules = append(rules, &IgnoreRule{
+			Source:  source,
+			Line:    i + 1,
+			Pattern: s,
+			pattern: gitignore.ParsePattern(s, domain),
+		})
+	}
+
+	return rules
+}
+
+// excludeFile is a file of patterns applied to the whole worktree.
+type excludeFile struct {
+	rules []*IgnoreRule
+	// hash is the hash of the file as recorded by git at the untracked cache,
+	// zero if the file doesn't exist
+	hash plumbing.Hash
+	stat index.UntrackedCacheStat
+}
+
+// excludes are the exclude files of the worktree out of the worktree itself.
+type excludes struct {
+	// excludesFile is the file at core.excludesFile
+	excludesFile excludeFile
+	// infoExclude is the file .git/info/exclude
+	infoExclude excludeFile
+}
+
+// patterns returns the patterns of the exclude files, in ascending order of
+// priority.
+func (e *excludes) patterns() []gitignore.Pattern {
+	var ps []gitignore.Pattern
+	for _, r := range e.excludesFile.rules {
+		ps = append(ps, r.pattern)
+	}
+
+	for _, r := range e.infoExclude.rules {
+		ps = append(ps, r.pattern)
+	}
+
+	return ps
+}
+
+// readExcludes reads the file at core.excludesFile and .git/info/exclude. If
+// core.excludesFile is not set at the repository configuration, the one of
+// the user is used, only when the repository is stored at the OS filesystem.
+func (w *Worktree) readExcludes() (*excludes, error) {
+	cfg, err := w.r.Storer.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	ex := &excludes{}
+
+	dot, isFSBased := storerFilesystem(w.r)
+	if isFSBased {
+		if err := loadExcludeFile(&ex.infoExclude, dot, infoExcludeFile, dot.Join(dot.Root(), infoExcludeFile)); err != nil {
+			return nil, err
+		}
+	}
+
+	root := osfs.New("/")
+
+	path := cfg.Core.ExcludesFile
+	if path == "" && isOSRepository(w.r) {
+		if path, err = gitignore.ExcludesFile(root); err != nil {
+			return nil, err
+		}
+	}
+
+	if path == "" {
+		return ex, nil
+	}
+
+	if strings.HasPrefix(path, "~/") {
+		usr, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+
+		path = filepath.Join(usr.HomeDir, path[2:])
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(w.Filesystem.Root(), path)
+	}
+
+	if err := loadExcludeFile(&ex.excludesFile, root, path, path); err != nil {
+		return nil, err
+	}
+
+	return ex, nil
+}
+
+// loadExcludeFile reads the patterns of the file, a missing file is empty. As
+// git does, the hash is computed with a trailing newline, except for empty
+// files. The source is the path of the file reported at the rules.
+func loadExcludeFile(f *excludeFile, fs billy.Filesystem, name, source string) error {
+	fi, err := fs.Stat(name)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	content, err := readFile(fs, name)
+	if err != nil {
+		return err
+	}
+
+	f.rules = parseIgnoreRules(content, nil, source)
+	f.stat = newUntrackedCacheStat(fi)
+	if len(content) == 0 {
+		f.hash = plumbing.ComputeHash(plumbing.BlobObject, content)
+	} else {
+		f.hash = plumbing.ComputeHash(plumbing.BlobObject, append(content, '\n'))
+	}
+
+	return nil
+}
+
+// isOSRepository returns true if the git directory of the repository is at the
+// OS filesystem, so the configuration of the user applies to it.
+func isOSRepository(r *Repository) bool {
+	if !isOSStorage(r) {
+		return false
+	}
+
+	dot, _ := storerFilesystem(r)
+	_, err := os.Stat(filepath.Join(dot.Root(), "HEAD"))
+	return err == nil
+}
+
+// isOSStorage returns true if the storage of the repository is based on a
+// filesystem with an absolute root, as the OS one, even if the repository
+// isn't initialized yet.
+func isOSStorage(r *Repository) bool {
+	dot, isFSBased := storerFilesystem(r)
+	return isFSBased && filepath.IsAbs(dot.Root())
+}
+
+// ignorePatterns returns the patterns of the IgnoreMatcher of the worktree.
+func (w *Worktree) ignorePatterns() ([]gitignore.Pattern, error) {
+	m, err := w.IgnoreMatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	return m.patterns(), nil
+}
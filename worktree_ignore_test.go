@@ -0,0 +1,160 @@
+package git
+
+import (
+	stdioutil "io/ioutil"
+	"path/filepath"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitignore"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type IgnoreSuite struct {
+	BaseSuite
+}
+
+var _ = Suite(&IgnoreSuite{})
+
+func (s *IgnoreSuite) newWorktree(c *C) *Worktree {
+	r, err := PlainInit(c.MkDir(), false)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	for _, name := range []string{"foo", "bar", "qux/baz"} {
+		err := util.WriteFile(w.Filesystem, name, []byte(name), 0644)
+		c.Assert(err, IsNil)
+	}
+
+	return w
+}
+
+func (s *IgnoreSuite) TestStatusInfoExclude(c *C) {
+	w := s.newWorktree(c)
+
+	dot, _ := storerFilesystem(w.r)
+	err := util.WriteFile(dot, infoExcludeFile, []byte("foo\nqux/\n"), 0644)
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 1)
+	c.Assert(status.File("bar").Worktree, Equals, Untracked)
+}
+
+func (s *IgnoreSuite) TestStatusExcludesFile(c *C) {
+	w := s.newWorktree(c)
+
+	path := filepath.Join(c.MkDir(), "ignore")
+	err := stdioutil.WriteFile(path, []byte("bar\n"), 0644)
+	c.Assert(err, IsNil)
+
+	cfg, err := w.r.Config()
+	c.Assert(err, IsNil)
+	cfg.Core.ExcludesFile = path
+	c.Assert(w.r.Storer.SetConfig(cfg), IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 2)
+	c.Assert(status.File("foo").Worktree, Equals, Untracked)
+	c.Assert(status.File("qux/baz").Worktree, Equals, Untracked)
+}
+
+func (s *IgnoreSuite) TestStatusExcludesFileOverridden(c *C) {
+	w := s.newWorktree(c)
+
+	path := filepath.Join(c.MkDir(), "ignore")
+	err := stdioutil.WriteFile(path, []byte("ba*\n"), 0644)
+	c.Assert(err, IsNil)
+
+	cfg, err := w.r.Config()
+	c.Assert(err, IsNil)
+	cfg.Core.ExcludesFile = path
+	c.Assert(w.r.Storer.SetConfig(cfg), IsNil)
+
+	err = util.WriteFile(w.Filesystem, ".gitignore", []byte("!bar\n.gitignore\n"), 0644)
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 2)
+	c.Assert(status.File("foo").Worktree, Equals, Untracked)
+	c.Assert(status.File("bar").Worktree, Equals, Untracked)
+}
+
+func (s *IgnoreSuite) TestStatusExcludesFileNotFound(c *C) {
+	w := s.newWorktree(c)
+
+	cfg, err := w.r.Config()
+	c.Assert(err, IsNil)
+	cfg.Core.ExcludesFile = filepath.Join(c.MkDir(), "not-found")
+	c.Assert(w.r.Storer.SetConfig(cfg), IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 3)
+}
+
+func (s *IgnoreSuite) TestAddDirectorySkipsIgnored(c *C) {
+	w := s.newWorktree(c)
+
+	dot, _ := storerFilesystem(w.r)
+	err := util.WriteFile(dot, infoExcludeFile, []byte("qux/baz\n"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = w.Add("qux")
+	c.Assert(err, IsNil)
+
+	idx, err := w.r.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Entries, HasLen, 0)
+
+	_, err = w.Add(".")
+	c.Assert(err, IsNil)
+
+	idx, err = w.r.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Entries, HasLen, 2)
+}
+
+func (s *IgnoreSuite) TestIgnoreMatcherExplain(c *C) {
+	w := s.newWorktree(c)
+
+	dot, _ := storerFilesystem(w.r)
+	err := util.WriteFile(dot, infoExcludeFile, []byte("# comment\nba*\n"), 0644)
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "qux/.gitignore", []byte("\n!baz\n"), 0644)
+	c.Assert(err, IsNil)
+
+	w.Excludes = []gitignore.Pattern{gitignore.ParsePattern("foo", nil)}
+
+	m, err := w.IgnoreMatcher()
+	c.Assert(err, IsNil)
+	c.Assert(m.Rules, HasLen, 3)
+
+	rule, ignored := m.Explain([]string{"bar"}, false)
+	c.Assert(ignored, Equals, true)
+	c.Assert(rule.Source, Equals, filepath.Join(dot.Root(), "info", "exclude"))
+	c.Assert(rule.Line, Equals, 2)
+	c.Assert(rule.Pattern, Equals, "ba*")
+	c.Assert(m.Match([]string{"bar"}, false), Equals, true)
+
+	rule, ignored = m.Explain([]string{"qux", "baz"}, false)
+	c.Assert(ignored, Equals, false)
+	c.Assert(rule.Source, Equals, filepath.Join("qux", ".gitignore"))
+	c.Assert(rule.Line, Equals, 2)
+	c.Assert(rule.Pattern, Equals, "!baz")
+	c.Assert(m.Match([]string{"qux", "baz"}, false), Equals, false)
+
+	rule, ignored = m.Explain([]string{"foo"}, false)
+	c.Assert(ignored, Equals, true)
+	c.Assert(rule.Source, Equals, "")
+
+	rule, ignored = m.Explain([]string{"qux"}, true)
+	c.Assert(ignored, Equals, false)
+	c.Assert(rule, IsNil)
+}
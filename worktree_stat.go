@@ -0,0 +1,133 @@
+package git
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
+)
+
+const indexFileName = "index"
+
+// statCache compares the stat data of the worktree files with the one saved
+// at the index, the files with the same stat data are not hashed again. As
+// git does, an entry modified after, or at the same time as, the index was
+// written is racily clean: the file may have changed without changing its
+// stat data, so it is always hashed.
+type statCache struct {
+	entries map[string]*index.Entry
+	// written is the modification time of the index file
+	written time.Time
+	// started is the time when the status started, only the files modified
+	// before it are refreshed
+	started time.Time
+
+	m     sync.Mutex
+	stale map[string]os.FileInfo
+}
+
+// newStatCache returns the statCache of the index, nil if the modification
+// time of the index is unknown, since then every entry may be racily clean.
+func (w *Worktree) newStatCache(idx *index.Index) *statCache {
+	dot, isFSBased := storerFilesystem(w.r)
+	if !isFSBased {
+		return nil
+	}
+
+	fi, err := dot.Stat(indexFileName)
+	if err != nil {
+		return nil
+	}
+
+	c := &statCache{
+		entries: make(map[string]*index.Entry, len(idx.Entries)),
+		written: fi.ModTime(),
+		started: time.Now(),
+		stale:   make(map[string]os.FileInfo),
+	}
+
+	for _, e := range idx.Entries {
+		c.entries[e.Name] = e
+	}
+
+	return c
+}
+
+// hash returns the hash, as computed by the filesystem noder, of the regular
+// files with the same stat data as their entries. It is safe to call it from
+// several goroutines.
+func (c *statCache) hash(name string, fi os.FileInfo) []byte {
+	e, ok := c.entries[name]
+	if !ok || e.IntentToAdd || !e.Mode.IsRegular() || !fi.Mode().IsRegular() {
+		return nil
+	}
+
+	if sameStat(e, fi) && e.ModifiedAt.Before(c.written) {
+		return append(e.Hash[:], e.Mode.Bytes()...)
+	}
+
+	if fi.ModTime().Before(c.started) {
+		c.m.Lock()
+		c.stale[name] = fi
+		c.m.Unlock()
+	}
+
+	return nil
+}
+
+// refresh updates the stat data of the entries hashed again without changes
+// at the worktree, returning true if any entry was updated.
+func (c *statCache) refresh(changes merkletrie.Changes) bool {
+	if len(c.stale) == 0 {
+		return false
+	}
+
+	changed := make(map[string]bool, len(changes))
+	for _, ch := range changes {
+		changed[nameFromAction(&ch)] = true
+	}
+
+	var updated bool
+	for name, fi := range c.stale {
+		if changed[name] {
+			continue
+		}
+
+		e := c.entries[name]
+		e.ModifiedAt = fi.ModTime()
+		e.Size = uint32(fi.Size())
+		if fillSystemInfo != nil {
+			fillSystemInfo(e, fi.Sys())
+		}
+
+		updated = true
+	}
+
+	return updated
+}
+
+// sameStat returns true if the file has the mode, size, modification time and,
+// when available, the ctime, device, inode and owner saved at the entry.
+func sameStat(e *index.Entry, fi os.FileInfo) bool {
+	mode, err := filemode.NewFromOSFileMode(fi.Mode())
+	if err != nil || mode != e.Mode {
+		return false
+	}
+
+	if e.Size != uint32(fi.Size()) || !e.ModifiedAt.Equal(fi.ModTime()) {
+		return false
+	}
+
+	if fillSystemInfo == nil {
+		return true
+	}
+
+	s := &index.Entry{}
+	fillSystemInfo(s, fi.Sys())
+
+	return s.CreatedAt.Equal(e.CreatedAt) && s.Dev == e.Dev && s.Inode == e.Inode &&
+		s.UID == e.UID && s.GID == e.GID
+}
@@ -0,0 +1,111 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type StatCacheSuite struct {
+	BaseSuite
+	r   *Repository
+	w   *Worktree
+	dir string
+}
+
+var _ = Suite(&StatCacheSuite{})
+
+func (s *StatCacheSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+
+	var err error
+	s.r, err = PlainInit(s.dir, false)
+	c.Assert(err, IsNil)
+
+	s.w, err = s.r.Worktree()
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(s.w.Filesystem, "foo", []byte("foo"), 0644)
+	c.Assert(err, IsNil)
+
+	s.setModTime(c, "foo", time.Now().Add(-time.Hour))
+
+	_, err = s.w.Add("foo")
+	c.Assert(err, IsNil)
+}
+
+func (s *StatCacheSuite) setModTime(c *C, name string, t time.Time) {
+	err := os.Chtimes(filepath.Join(s.dir, name), t, t)
+	c.Assert(err, IsNil)
+}
+
+// setEntryHash replaces the hash of the entry, without changing its stat data.
+func (s *StatCacheSuite) setEntryHash(c *C, name string, h plumbing.Hash) {
+	idx, err := s.r.Storer.Index()
+	c.Assert(err, IsNil)
+
+	e, err := idx.Entry(name)
+	c.Assert(err, IsNil)
+	e.Hash = h
+
+	c.Assert(s.r.Storer.SetIndex(idx), IsNil)
+}
+
+func (s *StatCacheSuite) TestStatusUsesStatData(c *C) {
+	s.setEntryHash(c, "foo", plumbing.NewHash("4aaf4fa7e2ba4b2b2cc84b2f8e9b3e5b8a6e3e5b"))
+
+	// the file is not read, so the hash of the entry is taken as its hash
+	status, err := s.w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Unmodified)
+}
+
+func (s *StatCacheSuite) TestStatusRacilyClean(c *C) {
+	s.setEntryHash(c, "foo", plumbing.NewHash("4aaf4fa7e2ba4b2b2cc84b2f8e9b3e5b8a6e3e5b"))
+
+	idx, err := s.r.Storer.Index()
+	c.Assert(err, IsNil)
+	e, err := idx.Entry("foo")
+	c.Assert(err, IsNil)
+
+	// the entry is modified at the same time as the index
+	fi, err := os.Stat(filepath.Join(s.dir, ".git", "index"))
+	c.Assert(err, IsNil)
+	s.setModTime(c, ".git/index", e.ModifiedAt)
+	defer s.setModTime(c, ".git/index", fi.ModTime())
+
+	status, err := s.w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Modified)
+}
+
+func (s *StatCacheSuite) TestStatusRefreshesStatData(c *C) {
+	modified := time.Now().Add(-time.Minute).Truncate(time.Second)
+	s.setModTime(c, "foo", modified)
+
+	status, err := s.w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Unmodified)
+
+	idx, err := s.r.Storer.Index()
+	c.Assert(err, IsNil)
+	e, err := idx.Entry("foo")
+	c.Assert(err, IsNil)
+	c.Assert(e.ModifiedAt.Equal(modified), Equals, true)
+}
+
+func (s *StatCacheSuite) TestStatusChangedFile(c *C) {
+	err := util.WriteFile(s.w.Filesystem, "foo", []byte("bar"), 0644)
+	c.Assert(err, IsNil)
+
+	s.setModTime(c, "foo", time.Now().Add(-time.Hour))
+
+	status, err := s.w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Worktree, Equals, Modified)
+}
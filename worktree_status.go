@@ -7,6 +7,8 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
+	"strings"
 
 	"gopkg.in/src-d/go-billy.v4/util"
 	"gopkg.in/src-d/go-git.v4/plumbing"
@@ -25,12 +27,19 @@ var (
 	// ErrDestinationExists in an Move operation means that the target exists on
 	// the worktree.
 	ErrDestinationExists = errors.New("destination exists")
+	// ErrMoveIntoItself in an Move operation means that the target is the
+	// source or is within it.
+	ErrMoveIntoItself = errors.New("cannot move a path into itself")
 	// ErrGlobNoMatches in an AddGlob if the glob pattern does not match any
 	// files in the worktree.
 	ErrGlobNoMatches = errors.New("glob pattern did not match any files")
 )
 
-// Status returns the working tree status.
+// Status returns the working tree status. The files with the same stat data
+// as their index entries are not read, and the files of every directory are
+// hashed concurrently. The stat data of the entries found unchanged is
+// refreshed at the index. The files renamed at the staging area without
+// changes are reported as Renamed.
 func (w *Worktree) Status() (Status, error) {
 	var hash plumbing.Hash
 
@@ -54,6 +63,7 @@ func (w *Worktree) status(commit plumbing.Hash) (Status, error) {
 		return nil, err
 	}
 
+	var deleted, added merkletrie.Changes
 	for _, ch := range left {
 		a, err := ch.Action()
 		if err != nil {
@@ -66,8 +76,10 @@ func (w *Worktree) status(commit plumbing.Hash) (Status, error) {
 		switch a {
 		case merkletrie.Delete:
 			s.File(ch.From.String()).Staging = Deleted
+			deleted = append(deleted, ch)
 		case merkletrie.Insert:
 			s.File(ch.To.String()).Staging = Added
+			added = append(added, ch)
 		case merkletrie.Modify:
 			s.File(ch.To.String()).Staging = Modified
 		}
@@ -100,9 +112,46 @@ func (w *Worktree) status(commit plumbing.Hash) (Status, error) {
 		}
 	}
 
+	detectStagedRenames(s, deleted, added)
 	return s, nil
 }
 
+// detectStagedRenames replaces the files deleted at the staging area, and
+// added with the same content and mode, by renames. Only exact renames are
+// detected and, as git does, empty files are never renames. The FileStatus
+// of the new path holds the previous one at Extra.
+func detectStagedRenames(s Status, deleted, added merkletrie.Changes) {
+	if len(deleted) == 0 || len(added) == 0 {
+		return
+	}
+
+	sources := make(map[string][]string)
+	for _, ch := range deleted {
+		name := ch.From.String()
+		if s[name].Staging != Deleted {
+			continue
+		}
+
+		h := string(ch.From.Last().Hash())
+		sources[h] = append(sources[h], name)
+	}
+
+	for _, ch := range added {
+		h := string(ch.To.Last().Hash())
+		if len(sources[h]) == 0 || bytes.HasPrefix([]byte(h), emptyBlobHash[:]) {
+			continue
+		}
+
+		from := sources[h][0]
+		sources[h] = sources[h][1:]
+
+		fs := s[ch.To.String()]
+		fs.Staging = Renamed
+		fs.Extra = from
+		delete(s, from)
+	}
+}
+
 func nameFromAction(ch *merkletrie.Change) string {
 	name := ch.To.String()
 	if name == "" {
@@ -124,7 +173,46 @@ func (w *Worktree) diffStagingWithWorktree(reverse bool) (merkletrie.Changes, er
 		return nil, err
 	}
 
-	to := filesystem.NewRootNode(w.Filesystem, submodules)
+	u, err := w.newUntrackedCache(idx)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := w.newFSMonitor(idx)
+	if err != nil {
+		return nil, err
+	}
+
+	sc := w.newStatCache(idx)
+	lh := w.newLinkFileHasher(idx)
+	opts := filesystem.Options{
+		Hash: func(name string, fi os.FileInfo) []byte {
+			if m != nil {
+				if h := m.hash(name); h != nil {
+					return h
+				}
+			}
+
+			if lh != nil {
+				if h := lh(name, fi); h != nil {
+					return h
+				}
+			}
+
+			if sc != nil {
+				return sc.hash(name, fi)
+			}
+
+			return nil
+		},
+		Workers: runtime.NumCPU(),
+	}
+
+	if u != nil {
+		opts.ReadDir = u.readDir
+	}
+
+	to := filesystem.NewRootNodeWithOptions(w.Filesystem, submodules, opts)
 
 	var c merkletrie.Changes
 	if reverse {
@@ -137,19 +225,65 @@ func (w *Worktree) diffStagingWithWorktree(reverse bool) (merkletrie.Changes, er
 		return nil, err
 	}
 
-	return w.excludeIgnoredChanges(c), nil
+	updated := sc != nil && sc.refresh(c)
+	if m != nil {
+		m.update(c)
+		updated = true
+	}
+
+	if u != nil && u.updated {
+		idx.UntrackedCache = u.cache
+		updated = true
+	}
+
+	if updated {
+		if err := w.r.Storer.SetIndex(idx); err != nil {
+			return nil, err
+		}
+	}
+
+	if u == nil {
+		return excludeFlaggedChanges(idx, w.excludeIgnoredChanges(c)), nil
+	}
+
+	return excludeFlaggedChanges(idx, u.excludeIgnoredChanges(c)), nil
+}
+
+// excludeFlaggedChanges drops the changes of the entries flagged as assume
+// valid or skip worktree, whose worktree files are meant to be ignored.
+func excludeFlaggedChanges(idx *index.Index, changes merkletrie.Changes) merkletrie.Changes {
+	flagged := make(map[string]bool)
+	for _, e := range idx.Entries {
+		if e.AssumeValid || e.SkipWorktree {
+			flagged[e.Name] = true
+		}
+	}
+
+	if len(flagged) == 0 {
+		return changes
+	}
+
+	var res merkletrie.Changes
+	for _, ch := range changes {
+		if !flagged[nameFromAction(&ch)] {
+			res = append(res, ch)
+		}
+	}
+
+	return res
 }
 
 func (w *Worktree) excludeIgnoredChanges(changes merkletrie.Changes) merkletrie.Changes {
-	patterns, err := gitignore.ReadPatterns(w.Filesystem, nil)
+	patterns, err := w.ignorePatterns()
 	if err != nil || len(patterns) == 0 {
 		return changes
 	}
 
-	patterns = append(patterns, w.Excludes...)
-
-	m := gitignore.NewMatcher(patterns)
+	return excludeMatchingChanges(changes, gitignore.NewMatcher(patterns))
+}
 
+// excludeMatchingChanges removes the changes of the paths matched by m.
+func excludeMatchingChanges(changes merkletrie.Changes, m gitignore.Matcher) merkletrie.Changes {
 	var res merkletrie.Changes
 	for _, ch := range changes {
 		var path []string
@@ -236,6 +370,8 @@ func (w *Worktree) diffTreeWithStaging(t *object.Tree, reverse bool) (merkletrie
 
 var emptyNoderHash = make([]byte, 24)
 
+var emptyBlobHash = plumbing.ComputeHash(plumbing.BlobObject, nil)
+
 // diffTreeIsEquals is a implementation of noder.Equals, used to compare
 // noder.Noder, it compare the content and the length of the hashes.
 //
@@ -309,6 +445,11 @@ func (w *Worktree) doAddDirectory(idx *index.Index, s Status, directory string)
 			}
 			a, err = w.doAddDirectory(idx, s, name)
 		} else {
+			if _, ok := s[name]; !ok && !isTracked(idx, name) {
+				// the untracked files missing at the status are ignored
+				continue
+			}
+
 			a, _, err = w.doAddFile(idx, s, name)
 		}
 
@@ -377,6 +518,11 @@ func (w *Worktree) AddGlob(pattern string) error {
 	return nil
 }
 
+func isTracked(idx *index.Index, name string) bool {
+	_, err := idx.Entry(name)
+	return err == nil
+}
+
 // doAddFile create a new blob from path and update the index, added is true if
 // the file added is different from the index.
 func (w *Worktree) doAddFile(idx *index.Index, s Status, path string) (added bool, h plumbing.Hash, err error) {
@@ -479,6 +625,7 @@ func (w *Worktree) doUpdateFileToIndex(e *index.Entry, filename string, h plumbi
 		return err
 	}
 
+	prev := e.Mode
 	e.Hash = h
 	e.ModifiedAt = info.ModTime()
 	e.Mode, err = filemode.NewFromOSFileMode(info.Mode())
@@ -486,6 +633,12 @@ func (w *Worktree) doUpdateFileToIndex(e *index.Entry, filename string, h plumbi
 		return err
 	}
 
+	// a link checked out as a plain file keeps the permissions of the link,
+	// so it may be seen as executable
+	if prev == filemode.Symlink && e.Mode.IsFile() && w.writesSymlinksAsFiles() {
+		e.Mode = filemode.Symlink
+	}
+
 	if e.Mode.IsRegular() {
 		e.Size = uint32(info.Size())
 	}
@@ -622,16 +775,86 @@ func (w *Worktree) RemoveGlob(pattern string) error {
 	return w.r.Storer.SetIndex(idx)
 }
 
-// Move moves or rename a file in the worktree and the index, directories are
-// not supported.
+// SetAssumeUnchanged sets or clears the assume valid flag of the index entries
+// matching path, a file or a directory, as `git update-index
+// --[no-]assume-unchanged` does. The changes at the worktree of the flagged
+// files are not reported by Status.
+func (w *Worktree) SetAssumeUnchanged(path string, assume bool) error {
+	return w.setIndexFlag(path, func(e *index.Entry) {
+		e.AssumeValid = assume
+	})
+}
+
+// SetSkipWorktree sets or clears the skip worktree flag of the index entries
+// matching path, a file or a directory, as `git update-index
+// --[no-]skip-worktree` does. The flagged files are ignored at the worktree by
+// Status, Checkout and Reset, even if they are missing.
+func (w *Worktree) SetSkipWorktree(path string, skip bool) error {
+	return w.setIndexFlag(path, func(e *index.Entry) {
+		e.SkipWorktree = skip
+	})
+}
+
+func (w *Worktree) setIndexFlag(path string, set func(e *index.Entry)) error {
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	var found bool
+	for _, e := range idx.Entries {
+		if !matchesAnyPath(e.Name, []string{path}) {
+			continue
+		}
+
+		set(e)
+		found = true
+
+		// the extended flags are only supported since version 3
+		if (e.SkipWorktree || e.IntentToAdd) && idx.Version < 3 {
+			idx.Version = 3
+		}
+	}
+
+	if !found {
+		return index.ErrEntryNotFound
+	}
+
+	return w.r.Storer.SetIndex(idx)
+}
+
+// Move moves or rename a file or a directory in the worktree and the index,
+// as `git mv` does. If to is an existing directory, from is moved into it.
+// The index is only written once the worktree is renamed. The returned hash
+// is the one of the moved file, or zero for directories.
+//
+// Renames only changing the case of the name are supported, even on case
+// insensitive filesystems.
 func (w *Worktree) Move(from, to string) (plumbing.Hash, error) {
-	// TODO(mcuadros): support directories and/or implement support for glob
-	if _, err := w.Filesystem.Lstat(from); err != nil {
+	from = filepath.ToSlash(filepath.Clean(from))
+	to = filepath.ToSlash(filepath.Clean(to))
+
+	fi, err := w.Filesystem.Lstat(from)
+	if err != nil {
 		return plumbing.ZeroHash, err
 	}
 
-	if _, err := w.Filesystem.Lstat(to); err == nil {
-		return plumbing.ZeroHash, ErrDestinationExists
+	caseOnly := from != to && strings.EqualFold(from, to)
+	if !caseOnly {
+		if dst, err := w.Filesystem.Lstat(to); err == nil {
+			if !dst.IsDir() {
+				return plumbing.ZeroHash, ErrDestinationExists
+			}
+
+			to = path.Join(to, path.Base(from))
+			if _, err := w.Filesystem.Lstat(to); err == nil {
+				return plumbing.ZeroHash, ErrDestinationExists
+			}
+		}
+	}
+
+	if to == from || strings.HasPrefix(to, from+"/") {
+		return plumbing.ZeroHash, ErrMoveIntoItself
 	}
 
 	idx, err := w.r.Storer.Index()
@@ -639,12 +862,16 @@ func (w *Worktree) Move(from, to string) (plumbing.Hash, error) {
 		return plumbing.ZeroHash, err
 	}
 
+	if fi.IsDir() {
+		return plumbing.ZeroHash, w.moveDir(idx, from, to, caseOnly)
+	}
+
 	hash, err := w.deleteFromIndex(idx, from)
 	if err != nil {
 		return plumbing.ZeroHash, err
 	}
 
-	if err := w.Filesystem.Rename(from, to); err != nil {
+	if err := w.rename(from, to, caseOnly); err != nil {
 		return hash, err
 	}
 
@@ -654,3 +881,68 @@ func (w *Worktree) Move(from, to string) (plumbing.Hash, error) {
 
 	return hash, w.r.Storer.SetIndex(idx)
 }
+
+func (w *Worktree) moveDir(idx *index.Index, from, to string, caseOnly bool) error {
+	var entries []*index.Entry
+	for _, e := range idx.Entries {
+		if strings.HasPrefix(e.Name, from+"/") {
+			entries = append(entries, e)
+		}
+	}
+
+	if len(entries) == 0 {
+		return index.ErrEntryNotFound
+	}
+
+	if err := w.rename(from, to, caseOnly); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		e.Name = to + strings.TrimPrefix(e.Name, from)
+	}
+
+	return w.r.Storer.SetIndex(idx)
+}
+
+// rename renames from to to, a case only rename is made through a temporary
+// name, since on case insensitive filesystems both names are the same file.
+// Directories are renamed file by file, since not every filesystem supports
+// renaming them.
+func (w *Worktree) rename(from, to string, caseOnly bool) error {
+	if caseOnly {
+		tmp := from + ".mv-tmp"
+		if err := w.rename(from, tmp, false); err != nil {
+			return err
+		}
+
+		return w.rename(tmp, to, false)
+	}
+
+	fi, err := w.Filesystem.Lstat(from)
+	if err != nil {
+		return err
+	}
+
+	if !fi.IsDir() {
+		return w.Filesystem.Rename(from, to)
+	}
+
+	if err := w.Filesystem.MkdirAll(to, fi.Mode().Perm()); err != nil {
+		return err
+	}
+
+	fis, err := w.Filesystem.ReadDir(from)
+	if err != nil {
+		return err
+	}
+
+	for _, fi := range fis {
+		err := w.rename(path.Join(from, fi.Name()), path.Join(to, fi.Name()), false)
+		if err != nil {
+			return err
+		}
+	}
+
+	return w.Filesystem.Remove(from)
+}
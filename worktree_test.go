@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -491,6 +492,72 @@ func (s *WorktreeSuite) TestCheckoutIndexOS(c *C) {
 	}
 }
 
+func (s *WorktreeSuite) TestCheckoutWorkers(c *C) {
+	dir, err := ioutil.TempDir("", "checkout-workers")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+
+	r, err := PlainInit(dir, false)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	commit := func(files map[string]string) plumbing.Hash {
+		for name, content := range files {
+			if content == "" {
+				_, err := w.Remove(name)
+				c.Assert(err, IsNil)
+				continue
+			}
+
+			err := util.WriteFile(w.Filesystem, name, []byte(content), 0644)
+			c.Assert(err, IsNil)
+			_, err = w.Add(name)
+			c.Assert(err, IsNil)
+		}
+
+		h, err := w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+		c.Assert(err, IsNil)
+		return h
+	}
+
+	first := make(map[string]string)
+	for i := 0; i < 32; i++ {
+		first[fmt.Sprintf("dir%d/file%d", i%4, i)] = fmt.Sprintf("content %d", i)
+	}
+
+	from := commit(first)
+	commit(map[string]string{
+		"dir0/file0": "",
+		"dir1/file1": "modified",
+		"dir4/file":  "added",
+	})
+
+	err = w.Checkout(&CheckoutOptions{Hash: from, Workers: 4})
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+
+	for name, content := range first {
+		data, err := readFile(w.Filesystem, name)
+		c.Assert(err, IsNil)
+		c.Assert(string(data), Equals, content)
+	}
+
+	_, err = w.Filesystem.Lstat("dir4")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	idx, err := r.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Entries, HasLen, len(first))
+	for _, e := range idx.Entries {
+		c.Assert(e.ModifiedAt.IsZero(), Equals, false)
+	}
+}
+
 func (s *WorktreeSuite) TestCheckoutBranch(c *C) {
 	w := &Worktree{
 		r:          s.Repository,
@@ -733,6 +800,27 @@ func (s *WorktreeSuite) TestReset(c *C) {
 	c.Assert(status.IsClean(), Equals, true)
 }
 
+func (s *WorktreeSuite) TestResetContextCancel(c *C) {
+	fs := memfs.New()
+	w := &Worktree{
+		r:          s.Repository,
+		Filesystem: fs,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := w.ResetContext(ctx, &ResetOptions{
+		Mode:   HardReset,
+		Commit: plumbing.NewHash("35e85108805c84807bc66a02d91535e1e24b38b9"),
+	})
+	c.Assert(err, Equals, context.Canceled)
+
+	files, err := fs.ReadDir("/")
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 0)
+}
+
 func (s *WorktreeSuite) TestResetWithUntracked(c *C) {
 	fs := memfs.New()
 	w := &Worktree{
@@ -841,6 +929,120 @@ func (s *WorktreeSuite) TestResetMerge(c *C) {
 	c.Assert(branch.Hash(), Equals, commitA)
 }
 
+func (s *WorktreeSuite) newResetRepository(c *C) (*Worktree, plumbing.Hash) {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	for _, name := range []string{"a", "b", "c"} {
+		err = util.WriteFile(w.Filesystem, name, []byte("1\n"), 0644)
+		c.Assert(err, IsNil)
+	}
+
+	_, err = w.Add(".")
+	c.Assert(err, IsNil)
+
+	first, err := w.Commit("first\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "a", []byte("2\n"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = w.Commit("second\n", &CommitOptions{All: true, Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	return w, first
+}
+
+func (s *WorktreeSuite) TestResetKeep(c *C) {
+	w, first := s.newResetRepository(c)
+
+	err := util.WriteFile(w.Filesystem, "b", []byte("staged\n"), 0644)
+	c.Assert(err, IsNil)
+	_, err = w.Add("b")
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "c", []byte("unstaged\n"), 0644)
+	c.Assert(err, IsNil)
+
+	err = w.Reset(&ResetOptions{Mode: KeepReset, Commit: first})
+	c.Assert(err, IsNil)
+
+	head, err := w.r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Equals, first)
+
+	content, err := readFile(w.Filesystem, "a")
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "1\n")
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 2)
+	c.Assert(*status.File("b"), Equals, FileStatus{Staging: Unmodified, Worktree: Modified})
+	c.Assert(*status.File("c"), Equals, FileStatus{Staging: Unmodified, Worktree: Modified})
+}
+
+func (s *WorktreeSuite) TestResetKeepLocalChanges(c *C) {
+	w, first := s.newResetRepository(c)
+
+	head, err := w.r.Head()
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "a", []byte("3\n"), 0644)
+	c.Assert(err, IsNil)
+
+	err = w.Reset(&ResetOptions{Mode: KeepReset, Commit: first})
+	c.Assert(err, Equals, ErrWorktreeNotClean)
+
+	current, err := w.r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(current.Hash(), Equals, head.Hash())
+
+	content, err := readFile(w.Filesystem, "a")
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "3\n")
+}
+
+func (s *WorktreeSuite) TestResetPaths(c *C) {
+	w, first := s.newResetRepository(c)
+
+	head, err := w.r.Head()
+	c.Assert(err, IsNil)
+
+	for _, name := range []string{"b", "c"} {
+		err = util.WriteFile(w.Filesystem, name, []byte("staged\n"), 0644)
+		c.Assert(err, IsNil)
+		_, err = w.Add(name)
+		c.Assert(err, IsNil)
+	}
+
+	err = w.Reset(&ResetOptions{Paths: []string{"b"}})
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 2)
+	c.Assert(*status.File("b"), Equals, FileStatus{Staging: Unmodified, Worktree: Modified})
+	c.Assert(*status.File("c"), Equals, FileStatus{Staging: Modified, Worktree: Unmodified})
+
+	err = w.Reset(&ResetOptions{Commit: first, Paths: []string{"a"}})
+	c.Assert(err, IsNil)
+
+	current, err := w.r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(current.Hash(), Equals, head.Hash())
+
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(*status.File("a"), Equals, FileStatus{Staging: Modified, Worktree: Modified})
+
+	err = w.Reset(&ResetOptions{Mode: HardReset, Paths: []string{"a"}})
+	c.Assert(err, Equals, ErrResetPathsMode)
+}
+
 func (s *WorktreeSuite) TestResetHard(c *C) {
 	fs := memfs.New()
 	w := &Worktree{
@@ -884,6 +1086,170 @@ func (s *WorktreeSuite) TestStatusAfterCheckout(c *C) {
 
 }
 
+func (s *WorktreeSuite) newRestoreRepository(c *C) (*Repository, plumbing.Hash, plumbing.Hash) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), fs)
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	util.WriteFile(fs, "foo", []byte("foo"), 0644)
+	util.WriteFile(fs, "dir/bar", []byte("bar"), 0644)
+	_, err = w.Add(".")
+	c.Assert(err, IsNil)
+
+	first, err := w.Commit("first\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	util.WriteFile(fs, "foo", []byte("FOO"), 0644)
+	util.WriteFile(fs, "dir/qux", []byte("qux"), 0644)
+	_, err = w.Add(".")
+	c.Assert(err, IsNil)
+
+	second, err := w.Commit("second\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	return r, first, second
+}
+
+func (s *WorktreeSuite) TestRestorePathsWorktreeFromIndex(c *C) {
+	r, _, _ := s.newRestoreRepository(c)
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	util.WriteFile(w.Filesystem, "foo", []byte("modified"), 0644)
+	util.WriteFile(w.Filesystem, "dir/bar", []byte("modified"), 0644)
+
+	err = w.RestorePaths(&RestoreOptions{Paths: []string{"foo"}})
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 1)
+	c.Assert(status.File("dir/bar").Worktree, Equals, Modified)
+}
+
+func (s *WorktreeSuite) TestRestorePathsFromCommit(c *C) {
+	r, first, _ := s.newRestoreRepository(c)
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	err = w.RestorePaths(&RestoreOptions{
+		Source:   first,
+		Staged:   true,
+		Worktree: true,
+		Paths:    []string{"foo", "dir/"},
+	})
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Staging, Equals, Modified)
+	c.Assert(status.File("foo").Worktree, Equals, Unmodified)
+	c.Assert(status.File("dir/qux").Staging, Equals, Deleted)
+	c.Assert(status.File("dir/qux").Worktree, Equals, Unmodified)
+
+	content, err := readFile(w.Filesystem, "foo")
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "foo")
+
+	_, err = w.Filesystem.Lstat("dir/qux")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	head, err := r.Head()
+	c.Assert(err, IsNil)
+	c.Assert(head.Hash(), Not(Equals), first)
+}
+
+func (s *WorktreeSuite) TestRestorePathsStaged(c *C) {
+	r, _, _ := s.newRestoreRepository(c)
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	util.WriteFile(w.Filesystem, "foo", []byte("modified"), 0644)
+	_, err = w.Add("foo")
+	c.Assert(err, IsNil)
+
+	err = w.RestorePaths(&RestoreOptions{Staged: true, Paths: []string{"foo"}})
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.File("foo").Staging, Equals, Unmodified)
+	c.Assert(status.File("foo").Worktree, Equals, Modified)
+}
+
+func (s *WorktreeSuite) TestRestorePathsInvalid(c *C) {
+	r, _, _ := s.newRestoreRepository(c)
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	err = w.RestorePaths(&RestoreOptions{})
+	c.Assert(err, Equals, ErrNoRestorePaths)
+
+	err = w.RestorePaths(&RestoreOptions{Paths: []string{"missing"}})
+	c.Assert(err, Equals, ErrPathNotFound)
+}
+
+func (s *WorktreeSuite) TestSetAssumeUnchanged(c *C) {
+	w := s.newMoveRepository(c)
+
+	err := w.SetAssumeUnchanged("foo", true)
+	c.Assert(err, IsNil)
+
+	err = util.WriteFile(w.Filesystem, "foo", []byte("bar"), 0644)
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+
+	err = w.SetAssumeUnchanged("foo", false)
+	c.Assert(err, IsNil)
+
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 1)
+	c.Assert(status.File("foo").Worktree, Equals, Modified)
+
+	err = w.SetAssumeUnchanged("not-found", true)
+	c.Assert(err, Equals, index.ErrEntryNotFound)
+}
+
+func (s *WorktreeSuite) TestSetSkipWorktree(c *C) {
+	w := s.newMoveRepository(c)
+
+	err := w.SetSkipWorktree("dir", true)
+	c.Assert(err, IsNil)
+
+	idx, err := w.r.Storer.Index()
+	c.Assert(err, IsNil)
+	c.Assert(idx.Version, Equals, uint32(3))
+
+	err = util.RemoveAll(w.Filesystem, "dir")
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+
+	err = w.Checkout(&CheckoutOptions{Branch: plumbing.Master, Force: true})
+	c.Assert(err, IsNil)
+
+	_, err = w.Filesystem.Lstat("dir/a")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	err = w.SetSkipWorktree("dir", false)
+	c.Assert(err, IsNil)
+
+	status, err = w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 2)
+	c.Assert(status.File("dir/a").Worktree, Equals, Deleted)
+	c.Assert(status.File("dir/sub/b").Worktree, Equals, Deleted)
+}
+
 func (s *WorktreeSuite) TestStatusModified(c *C) {
 	dir, err := ioutil.TempDir("", "status")
 	c.Assert(err, IsNil)
@@ -1530,9 +1896,8 @@ func (s *WorktreeSuite) TestMove(c *C) {
 
 	status, err := w.Status()
 	c.Assert(err, IsNil)
-	c.Assert(status, HasLen, 2)
-	c.Assert(status.File("LICENSE").Staging, Equals, Deleted)
-	c.Assert(status.File("foo").Staging, Equals, Added)
+	c.Assert(status, HasLen, 1)
+	c.Assert(*status.File("foo"), Equals, FileStatus{Staging: Renamed, Worktree: Unmodified, Extra: "LICENSE"})
 
 }
 
@@ -1566,6 +1931,77 @@ func (s *WorktreeSuite) TestMoveToExistent(c *C) {
 	c.Assert(err, Equals, ErrDestinationExists)
 }
 
+func (s *WorktreeSuite) newMoveRepository(c *C) *Worktree {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	for _, name := range []string{"foo", "dir/a", "dir/sub/b", "other/c"} {
+		err = util.WriteFile(w.Filesystem, name, []byte(name), 0644)
+		c.Assert(err, IsNil)
+	}
+
+	_, err = w.Add(".")
+	c.Assert(err, IsNil)
+
+	_, err = w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	return w
+}
+
+func (s *WorktreeSuite) TestMoveDirectory(c *C) {
+	w := s.newMoveRepository(c)
+
+	hash, err := w.Move("dir", "moved")
+	c.Assert(err, IsNil)
+	c.Assert(hash.IsZero(), Equals, true)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 2)
+	c.Assert(*status.File("moved/a"), Equals, FileStatus{Staging: Renamed, Worktree: Unmodified, Extra: "dir/a"})
+	c.Assert(*status.File("moved/sub/b"), Equals, FileStatus{Staging: Renamed, Worktree: Unmodified, Extra: "dir/sub/b"})
+
+	_, err = w.Filesystem.Lstat("dir")
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *WorktreeSuite) TestMoveIntoDirectory(c *C) {
+	w := s.newMoveRepository(c)
+
+	_, err := w.Move("foo", "other")
+	c.Assert(err, IsNil)
+
+	_, err = w.Move("dir", "other")
+	c.Assert(err, IsNil)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 3)
+	c.Assert(status.File("other/foo").Staging, Equals, Renamed)
+	c.Assert(status.File("other/dir/a").Staging, Equals, Renamed)
+	c.Assert(status.File("other/dir/sub/b").Staging, Equals, Renamed)
+
+	_, err = w.Move("other", "other/dir")
+	c.Assert(err, Equals, ErrMoveIntoItself)
+}
+
+func (s *WorktreeSuite) TestMoveCaseOnly(c *C) {
+	w := s.newMoveRepository(c)
+
+	hash, err := w.Move("foo", "FOO")
+	c.Assert(err, IsNil)
+	c.Assert(hash.IsZero(), Equals, false)
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status, HasLen, 1)
+	c.Assert(*status.File("FOO"), Equals, FileStatus{Staging: Renamed, Worktree: Unmodified, Extra: "foo"})
+}
+
 func (s *WorktreeSuite) TestClean(c *C) {
 	fs := fixtures.ByTag("dirty").One().Worktree()
 
@@ -1601,6 +2037,103 @@ func (s *WorktreeSuite) TestClean(c *C) {
 	c.Assert(len(status), Equals, 0)
 }
 
+func (s *WorktreeSuite) newCleanRepository(c *C) *Worktree {
+	r, err := Init(memory.NewStorage(), memfs.New())
+	c.Assert(err, IsNil)
+
+	w, err := r.Worktree()
+	c.Assert(err, IsNil)
+
+	for _, name := range []string{".gitignore", "foo", "dir/tracked"} {
+		err = util.WriteFile(w.Filesystem, name, []byte("*.log\n"), 0644)
+		c.Assert(err, IsNil)
+
+		_, err = w.Add(name)
+		c.Assert(err, IsNil)
+	}
+
+	_, err = w.Commit("foo\n", &CommitOptions{Author: defaultSignature()})
+	c.Assert(err, IsNil)
+
+	for _, name := range []string{
+		"bar", "foo.log", "dir/untracked", "qux/a", "qux/b", "logs/a.log",
+	} {
+		err = util.WriteFile(w.Filesystem, name, []byte("foo"), 0644)
+		c.Assert(err, IsNil)
+	}
+
+	return w
+}
+
+func (s *WorktreeSuite) TestCleanFiles(c *C) {
+	w := s.newCleanRepository(c)
+
+	paths, err := w.CleanFiles(&CleanOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"bar"})
+
+	_, err = w.Filesystem.Lstat("bar")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	paths, err = w.CleanFiles(&CleanOptions{Dir: true})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"dir/untracked", "qux/"})
+
+	_, err = w.Filesystem.Lstat("qux")
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	_, err = w.Filesystem.Lstat("dir/tracked")
+	c.Assert(err, IsNil)
+
+	_, err = w.Filesystem.Lstat("foo.log")
+	c.Assert(err, IsNil)
+}
+
+func (s *WorktreeSuite) TestCleanFilesDryRun(c *C) {
+	w := s.newCleanRepository(c)
+
+	paths, err := w.CleanFiles(&CleanOptions{Dir: true, DryRun: true})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"bar", "dir/untracked", "qux/"})
+
+	for _, name := range paths {
+		_, err = w.Filesystem.Lstat(name)
+		c.Assert(err, IsNil)
+	}
+}
+
+func (s *WorktreeSuite) TestCleanFilesIgnored(c *C) {
+	w := s.newCleanRepository(c)
+
+	paths, err := w.CleanFiles(&CleanOptions{Dir: true, OnlyIgnored: true, DryRun: true})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"foo.log", "logs/a.log"})
+
+	paths, err = w.CleanFiles(&CleanOptions{Dir: true, Ignored: true})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{
+		"bar", "dir/untracked", "foo.log", "logs/", "qux/",
+	})
+
+	status, err := w.Status()
+	c.Assert(err, IsNil)
+	c.Assert(status.IsClean(), Equals, true)
+}
+
+func (s *WorktreeSuite) TestCleanFilesPaths(c *C) {
+	w := s.newCleanRepository(c)
+
+	paths, err := w.CleanFiles(&CleanOptions{Paths: []string{"qux/a", "dir"}})
+	c.Assert(err, IsNil)
+	c.Assert(paths, DeepEquals, []string{"dir/untracked", "qux/a"})
+
+	_, err = w.Filesystem.Lstat("qux/b")
+	c.Assert(err, IsNil)
+
+	_, err = w.Filesystem.Lstat("bar")
+	c.Assert(err, IsNil)
+}
+
 func (s *WorktreeSuite) TestAlternatesRepo(c *C) {
 	fs := fixtures.ByTag("alternates").One().Worktree()
 
@@ -0,0 +1,450 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/gitignore"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/utils/merkletrie"
+
+	"gopkg.in/src-d/go-billy.v4"
+)
+
+const (
+	// untrackedCacheDirFlags are the dir_flags used by git status, the
+	// untracked directories are recorded by name, unless they are empty.
+	untrackedCacheDirFlags = 1<<1 | 1<<2
+	gitignoreFileName      = ".gitignore"
+)
+
+// untrackedCacheSystems are the names of the operating systems, as
+// reported by uname and recorded by git at the environment of the cache.
+var untrackedCacheSystems = map[string]string{
+	"darwin":    "Darwin",
+	"dragonfly": "DragonFly",
+	"freebsd":   "FreeBSD",
+	"linux":     "Linux",
+	"netbsd":    "NetBSD",
+	"openbsd":   "OpenBSD",
+	"solaris":   "SunOS",
+	"windows":   "Windows",
+}
+
+// untrackedCache lists the directories of the worktree for the status using
+// the 'Untracked cache' extension of the index. A directory not modified
+// since it was read, with the same .gitignore files, is not read again: its
+// content is the tracked files plus the untracked files saved at the cache.
+// The ignored untracked files are never listed.
+type untrackedCache struct {
+	fs    billy.Filesystem
+	cache *index.UntrackedCache
+	// tracked contains the names of the tracked files and directories of
+	// every directory
+	tracked map[string]map[string]bool
+	// excludeFiles contains the hashes of the tracked .gitignore files
+	excludeFiles map[string]plumbing.Hash
+	dirs         map[string]*index.UntrackedCacheDirectory
+	// excludesChanged contains the directories whose exclude patterns, or
+	// the ones of their parents, changed since they were cached
+	excludesChanged map[string]bool
+	patterns        []gitignore.Pattern
+	updated         bool
+}
+
+// newUntrackedCache returns the untrackedCache of the index, nil if the cache
+// is disabled by core.untrackedCache or if the worktree has Excludes, since
+// they are not recorded at the cache.
+func (w *Worktree) newUntrackedCache(idx *index.Index) (*untrackedCache, error) {
+	cfg, err := w.r.Storer.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(cfg.Core.UntrackedCache) {
+	case "true", "yes", "on", "1":
+	case "false", "no", "off", "0":
+		if idx.UntrackedCache == nil {
+			return nil, nil
+		}
+
+		idx.UntrackedCache = nil
+		return nil, w.r.Storer.SetIndex(idx)
+	default:
+		if idx.UntrackedCache == nil {
+			return nil, nil
+		}
+	}
+
+	if len(w.Excludes) != 0 {
+		return nil, nil
+	}
+
+	ex, err := w.readExcludes()
+	if err != nil {
+		return nil, err
+	}
+
+	u := &untrackedCache{
+		fs:              w.Filesystem,
+		cache:           idx.UntrackedCache,
+		tracked:         make(map[string]map[string]bool),
+		excludeFiles:    make(map[string]plumbing.Hash),
+		dirs:            make(map[string]*index.UntrackedCacheDirectory),
+		excludesChanged: make(map[string]bool),
+		patterns:        ex.patterns(),
+	}
+
+	env := untrackedCacheEnvironment(w.Filesystem.Root())
+	if !isUntrackedCacheUsable(u.cache, env, ex) {
+		u.cache = &index.UntrackedCache{
+			Environments:     []string{env},
+			InfoExcludeStat:  ex.infoExclude.stat,
+			ExcludesFileStat: ex.excludesFile.stat,
+			DirFlags:         untrackedCacheDirFlags,
+			InfoExcludeHash:  ex.infoExclude.hash,
+			ExcludesFileHash: ex.excludesFile.hash,
+			ExcludePerDir:    gitignoreFileName,
+		}
+
+		u.updated = true
+	}
+
+	u.addDirectories("", u.cache.Root)
+	for _, e := range idx.Entries {
+		if path.Base(e.Name) == gitignoreFileName {
+			u.excludeFiles[e.Name] = e.Hash
+		}
+
+		var dir string
+		for _, name := range strings.Split(e.Name, "/") {
+			if u.tracked[dir] == nil {
+				u.tracked[dir] = make(map[string]bool)
+			}
+
+			u.tracked[dir][name] = true
+			dir = path.Join(dir, name)
+		}
+	}
+
+	if u.tracked[""] == nil {
+		u.tracked[""] = make(map[string]bool)
+	}
+
+	return u, nil
+}
+
+func untrackedCacheEnvironment(root string) string {
+	system, ok := untrackedCacheSystems[runtime.GOOS]
+	if !ok {
+		system = runtime.GOOS
+	}
+
+	return fmt.Sprintf("Location %s, system %s", root, system)
+}
+
+// isUntrackedCacheUsable returns true if the cache was built at the given
+// environment, the same way it is built by go-git, with the same exclude
+// files outside of the worktree.
+func isUntrackedCacheUsable(c *index.UntrackedCache, env string, ex *excludes) bool {
+	if c == nil || c.DirFlags != untrackedCacheDirFlags || c.ExcludePerDir != gitignoreFileName ||
+		c.InfoExcludeHash != ex.infoExclude.hash || c.ExcludesFileHash != ex.excludesFile.hash {
+		return false
+	}
+
+	for _, e := range c.Environments {
+		if e == env {
+			return true
+		}
+	}
+
+	return false
+}
+
+// readDir lists the directory at the given path, as requested by the
+// filesystem noder, from the cache if possible.
+func (u *untrackedCache) readDir(dir string) ([]os.FileInfo, error) {
+	parts := splitPath(dir)
+	hash, err := u.readExcludeFile(dir, parts)
+	if err != nil {
+		return nil, err
+	}
+
+	// the content of the untracked directories is not cached
+	if _, ok := u.tracked[dir]; !ok {
+		files, _, err := u.listDir(dir, parts)
+		return files, err
+	}
+
+	d := u.directory(dir)
+	if (dir != "" && u.excludesChanged[parentPath(dir)]) || d.ExcludeHash != hash {
+		u.excludesChanged[dir] = true
+		d.ExcludeHash = hash
+		d.Valid = false
+		u.updated = true
+	}
+
+	fi, err := u.fs.Lstat(dir)
+	if err != nil {
+		files, _, err := u.listDir(dir, parts)
+		return files, err
+	}
+
+	stat := newUntrackedCacheStat(fi)
+	if d.Valid && !d.CheckOnly && !stat.ModifiedAt.IsZero() && sameUntrackedCacheStat(&d.Stat, &stat) {
+		return u.cachedDir(dir, d)
+	}
+
+	files, untracked, err := u.listDir(dir, parts)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Untracked = untracked
+	d.Stat = stat
+	d.Valid = true
+	d.CheckOnly = false
+	u.updated = true
+
+	return files, nil
+}
+
+// cachedDir returns the tracked files of the directory plus the untracked
+// files recorded at the cache.
+func (u *untrackedCache) cachedDir(dir string, d *index.UntrackedCacheDirectory) ([]os.FileInfo, error) {
+	names := make(map[string]bool, len(u.tracked[dir])+len(d.Untracked))
+	for name := range u.tracked[dir] {
+		names[name] = true
+	}
+
+	for _, name := range d.Untracked {
+		names[strings.TrimSuffix(name, "/")] = true
+	}
+
+	var files []os.FileInfo
+	for name := range names {
+		fi, err := u.fs.Lstat(path.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, fi)
+	}
+
+	return files, nil
+}
+
+// listDir reads the directory, skipping the ignored untracked files, and
+// returns the untracked files as recorded by the cache.
+func (u *untrackedCache) listDir(dir string, parts []string) ([]os.FileInfo, []string, error) {
+	files, err := u.fs.ReadDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m := gitignore.NewMatcher(u.patterns)
+
+	var res []os.FileInfo
+	var untracked []string
+	for _, fi := range files {
+		name := fi.Name()
+		if name == GitDirName {
+			continue
+		}
+
+		if u.tracked[dir][name] {
+			res = append(res, fi)
+			continue
+		}
+
+		p := append(parts[:len(parts):len(parts)], name)
+		if m.Match(p, fi.IsDir()) {
+			continue
+		}
+
+		res = append(res, fi)
+		if !fi.IsDir() {
+			untracked = append(untracked, name)
+			continue
+		}
+
+		found, err := u.hasUntracked(path.Join(dir, name), p, u.patterns)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if found {
+			untracked = append(untracked, name+"/")
+		}
+	}
+
+	return res, untracked, nil
+}
+
+// hasUntracked returns true if the untracked directory contains any file not
+// ignored by the patterns or the .gitignore files inside it.
+func (u *untrackedCache) hasUntracked(dir string, parts []string, patterns []gitignore.Pattern) (bool, error) {
+	content, err := readFile(u.fs, path.Join(dir, gitignoreFileName))
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	patterns = append(patterns[:len(patterns):len(patterns)], parsePatterns(content, parts)...)
+	m := gitignore.NewMatcher(patterns)
+
+	files, err := u.fs.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+
+	for _, fi := range files {
+		if fi.Name() == GitDirName {
+			continue
+		}
+
+		p := append(parts[:len(parts):len(parts)], fi.Name())
+		if m.Match(p, fi.IsDir()) {
+			continue
+		}
+
+		if !fi.IsDir() {
+			return true, nil
+		}
+
+		found, err := u.hasUntracked(path.Join(dir, fi.Name()), p, patterns)
+		if found || err != nil {
+			return found, err
+		}
+	}
+
+	return false, nil
+}
+
+// readExcludeFile adds the patterns of the .gitignore file of the directory,
+// returning its hash, zero if it doesn't exist. As git does, the hash of a
+// file not matching the index is computed with a trailing newline.
+func (u *untrackedCache) readExcludeFile(dir string, parts []string) (plumbing.Hash, error) {
+	name := path.Join(dir, gitignoreFileName)
+	content, err := readFile(u.fs, name)
+	if os.IsNotExist(err) {
+		return plumbing.ZeroHash, nil
+	}
+
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	u.patterns = append(u.patterns, parsePatterns(content, parts)...)
+
+	h := plumbing.ComputeHash(plumbing.BlobObject, content)
+	if len(content) == 0 || u.excludeFiles[name] == h {
+		return h, nil
+	}
+
+	return plumbing.ComputeHash(plumbing.BlobObject, append(content, '\n')), nil
+}
+
+// directory returns the cache of the directory, creating it if needed.
+func (u *untrackedCache) directory(dir string) *index.UntrackedCacheDirectory {
+	if d, ok := u.dirs[dir]; ok {
+		return d
+	}
+
+	var d *index.UntrackedCacheDirectory
+	if dir == "" {
+		if u.cache.Root == nil {
+			u.cache.Root = &index.UntrackedCacheDirectory{}
+		}
+
+		d = u.cache.Root
+	} else {
+		parent := u.directory(parentPath(dir))
+		d = &index.UntrackedCacheDirectory{Name: path.Base(dir)}
+		parent.Directories = append(parent.Directories, d)
+	}
+
+	u.dirs[dir] = d
+	return d
+}
+
+// addDirectories indexes by path the directory and its sub-directories.
+func (u *untrackedCache) addDirectories(dir string, d *index.UntrackedCacheDirectory) {
+	if d == nil {
+		return
+	}
+
+	u.dirs[dir] = d
+	for _, sub := range d.Directories {
+		u.addDirectories(path.Join(dir, sub.Name), sub)
+	}
+}
+
+// excludeIgnoredChanges removes the changes of the files ignored by the
+// .gitignore files read while listing the worktree.
+func (u *untrackedCache) excludeIgnoredChanges(changes merkletrie.Changes) merkletrie.Changes {
+	if len(u.patterns) == 0 {
+		return changes
+	}
+
+	return excludeMatchingChanges(changes, gitignore.NewMatcher(u.patterns))
+}
+
+func newUntrackedCacheStat(fi os.FileInfo) index.UntrackedCacheStat {
+	e := &index.Entry{}
+	if fillSystemInfo != nil {
+		fillSystemInfo(e, fi.Sys())
+	}
+
+	return index.UntrackedCacheStat{
+		CreatedAt:  e.CreatedAt,
+		ModifiedAt: fi.ModTime(),
+		Dev:        e.Dev,
+		Inode:      e.Inode,
+		UID:        e.UID,
+		GID:        e.GID,
+		Size:       uint32(fi.Size()),
+	}
+}
+
+func sameUntrackedCacheStat(a, b *index.UntrackedCacheStat) bool {
+	return a.CreatedAt.Equal(b.CreatedAt) && a.ModifiedAt.Equal(b.ModifiedAt) &&
+		a.Dev == b.Dev && a.Inode == b.Inode &&
+		a.UID == b.UID && a.GID == b.GID &&
+		a.Size == b.Size
+}
+
+func parsePatterns(content []byte, domain []string) []gitignore.Pattern {
+	var ps []gitignore.Pattern
+	for _, s := range strings.Split(string(content), "\n") {
+		if !strings.HasPrefix(s, "#") && len(strings.TrimSpace(s)) > 0 {
+			ps = append(ps, gitignore.ParsePattern(s, domain))
+		}
+	}
+
+	return ps
+}
+
+func splitPath(p string) []string {
+	if p == "" {
+		return nil
+	}
+
+	return strings.Split(p, "/")
+}
+
+func parentPath(p string) string {
+	dir := path.Dir(p)
+	if dir == "." {
+		return ""
+	}
+
+	return dir
+}
@@ -0,0 +1,147 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/src-d/go-billy.v4/util"
+)
+
+type UntrackedCacheSuite struct {
+	BaseSuite
+	r   *Repository
+	w   *Worktree
+	dir string
+}
+
+var _ = Suite(&UntrackedCacheSuite{})
+
+func (s *UntrackedCacheSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+
+	var err error
+	s.r, err = PlainInit(s.dir, false)
+	c.Assert(err, IsNil)
+
+	cfg, err := s.r.Config()
+	c.Assert(err, IsNil)
+	cfg.Core.UntrackedCache = "true"
+	c.Assert(s.r.Storer.SetConfig(cfg), IsNil)
+
+	s.w, err = s.r.Worktree()
+	c.Assert(err, IsNil)
+
+	s.writeFile(c, "a/tracked")
+	_, err = s.w.Add("a/tracked")
+	c.Assert(err, IsNil)
+
+	s.writeFile(c, "a/untracked")
+	s.writeFile(c, "a/ignored.log")
+	s.writeFile(c, "b/untracked")
+	err = util.WriteFile(s.w.Filesystem, ".gitignore", []byte("*.log\n"), 0644)
+	c.Assert(err, IsNil)
+
+	// the directories are modified in the past, so any later change is
+	// noticed even with coarse timestamps
+	past := time.Now().Add(-time.Hour)
+	for _, dir := range []string{"", "a", "b"} {
+		err := os.Chtimes(filepath.Join(s.dir, dir), past, past)
+		c.Assert(err, IsNil)
+	}
+}
+
+func (s *UntrackedCacheSuite) writeFile(c *C, name string) {
+	err := util.WriteFile(s.w.Filesystem, name, []byte(name), 0644)
+	c.Assert(err, IsNil)
+}
+
+func (s *UntrackedCacheSuite) untrackedCache(c *C) *index.UntrackedCache {
+	idx, err := s.r.Storer.Index()
+	c.Assert(err, IsNil)
+	return idx.UntrackedCache
+}
+
+func (s *UntrackedCacheSuite) assertUntracked(c *C, expected ...string) {
+	status, err := s.w.Status()
+	c.Assert(err, IsNil)
+
+	var untracked []string
+	for name, fs := range status {
+		if fs.Worktree == Untracked {
+			untracked = append(untracked, name)
+		}
+	}
+
+	c.Assert(untracked, HasLen, len(expected))
+	for _, name := range expected {
+		c.Assert(status.File(name).Worktree, Equals, Untracked)
+	}
+}
+
+func (s *UntrackedCacheSuite) TestStatusCreatesCache(c *C) {
+	s.assertUntracked(c, ".gitignore", "a/untracked", "b/untracked")
+
+	u := s.untrackedCache(c)
+	c.Assert(u, NotNil)
+	c.Assert(u.Environments, DeepEquals, []string{untrackedCacheEnvironment(s.w.Filesystem.Root())})
+	c.Assert(u.DirFlags, Equals, uint32(untrackedCacheDirFlags))
+	c.Assert(u.ExcludePerDir, Equals, ".gitignore")
+
+	c.Assert(u.Root.Valid, Equals, true)
+	c.Assert(u.Root.ExcludeHash.IsZero(), Equals, false)
+	c.Assert(u.Root.Untracked, DeepEquals, []string{".gitignore", "b/"})
+	c.Assert(u.Root.Directories, HasLen, 1)
+
+	a := u.Root.Directories[0]
+	c.Assert(a.Name, Equals, "a")
+	c.Assert(a.Valid, Equals, true)
+	c.Assert(a.Untracked, DeepEquals, []string{"untracked"})
+}
+
+func (s *UntrackedCacheSuite) TestStatusUsesCache(c *C) {
+	s.assertUntracked(c, ".gitignore", "a/untracked", "b/untracked")
+
+	// the cached content is used as long as the directory doesn't change
+	idx, err := s.r.Storer.Index()
+	c.Assert(err, IsNil)
+	idx.UntrackedCache.Root.Directories[0].Untracked = nil
+	c.Assert(s.r.Storer.SetIndex(idx), IsNil)
+
+	s.assertUntracked(c, ".gitignore", "b/untracked")
+}
+
+func (s *UntrackedCacheSuite) TestStatusDirectoryChanged(c *C) {
+	s.assertUntracked(c, ".gitignore", "a/untracked", "b/untracked")
+
+	s.writeFile(c, "a/new")
+	s.assertUntracked(c, ".gitignore", "a/untracked", "a/new", "b/untracked")
+	c.Assert(s.untrackedCache(c).Root.Directories[0].Untracked, DeepEquals, []string{"new", "untracked"})
+}
+
+func (s *UntrackedCacheSuite) TestStatusExcludeFileChanged(c *C) {
+	s.assertUntracked(c, ".gitignore", "a/untracked", "b/untracked")
+
+	// changing the content of a file doesn't change its directory, the
+	// sub-directories are invalidated by the hash of the .gitignore
+	err := util.WriteFile(s.w.Filesystem, ".gitignore", []byte("*.tmp\n"), 0644)
+	c.Assert(err, IsNil)
+
+	s.assertUntracked(c, ".gitignore", "a/ignored.log", "a/untracked", "b/untracked")
+}
+
+func (s *UntrackedCacheSuite) TestStatusCacheDisabled(c *C) {
+	s.assertUntracked(c, ".gitignore", "a/untracked", "b/untracked")
+	c.Assert(s.untrackedCache(c), NotNil)
+
+	cfg, err := s.r.Config()
+	c.Assert(err, IsNil)
+	cfg.Core.UntrackedCache = "false"
+	c.Assert(s.r.Storer.SetConfig(cfg), IsNil)
+
+	s.assertUntracked(c, ".gitignore", "a/untracked", "b/untracked")
+	c.Assert(s.untrackedCache(c), IsNil)
+}